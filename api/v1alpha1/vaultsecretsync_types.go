@@ -5,10 +5,14 @@ package v1alpha1
 import (
 	"github.com/jbcom/secretsync/stores/aws"
 	"github.com/jbcom/secretsync/stores/awsidentitycenter"
+	"github.com/jbcom/secretsync/stores/azurekeyvault"
+	"github.com/jbcom/secretsync/stores/cloudflare"
 	"github.com/jbcom/secretsync/stores/doppler"
 	"github.com/jbcom/secretsync/stores/gcp"
 	"github.com/jbcom/secretsync/stores/github"
+	"github.com/jbcom/secretsync/stores/grpcstore"
 	"github.com/jbcom/secretsync/stores/httpstore"
+	"github.com/jbcom/secretsync/stores/kubernetes"
 	"github.com/jbcom/secretsync/stores/vault"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -38,6 +42,14 @@ const (
 	NotificationEventSyncFailure NotificationEvent = "failure"
 )
 
+// ClassificationLabel holds the environment tier of a sync's target (e.g.
+// production), used to route notifications
+const ClassificationLabel = "vaultsecretsync.lestak.sh/classification"
+
+// OwnersAnnotation holds the comma-separated owners of a sync's target (emails,
+// Slack handles or team names), who its notifications are addressed to
+const OwnersAnnotation = "vaultsecretsync.lestak.sh/owners"
+
 type StoreConfig struct {
 	AWS            *aws.AwsClient                            `json:"aws,omitempty" yaml:"aws,omitempty"`
 	IdentityCenter *awsidentitycenter.IdentityCenterClient   `json:"awsIdentityCenter,omitempty" yaml:"awsIdentityCenter,omitempty"`
@@ -46,6 +58,10 @@ type StoreConfig struct {
 	GitHub         *github.GitHubClient                      `json:"github,omitempty" yaml:"github,omitempty"`
 	Vault          *vault.VaultClient                        `json:"vault,omitempty" yaml:"vault,omitempty"`
 	HTTP           *httpstore.HTTPClient                     `json:"http,omitempty" yaml:"http,omitempty"`
+	Kubernetes     *kubernetes.KubernetesClient              `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty"`
+	GRPC           *grpcstore.GRPCClient                     `json:"grpc,omitempty" yaml:"grpc,omitempty"`
+	AzureKeyVault  *azurekeyvault.AzureKeyVaultClient        `json:"azureKeyVault,omitempty" yaml:"azureKeyVault,omitempty"`
+	Cloudflare     *cloudflare.CloudflareClient              `json:"cloudflare,omitempty" yaml:"cloudflare,omitempty"`
 }
 
 type RegexpFilterConfig struct {
@@ -73,6 +89,16 @@ type TransformSpec struct {
 	Exclude  []string          `yaml:"exclude,omitempty" json:"exclude,omitempty"`
 	Rename   []RenameTransform `json:"rename,omitempty"`
 	Template *string           `json:"template,omitempty"`
+	// Wasm is the path to a WebAssembly plugin whose transform function runs last
+	Wasm *string `json:"wasm,omitempty"`
+	// Reference replaces each value with a pointer to where it is stored,
+	// rendered from this Go template before the other transforms run
+	Reference *string `json:"reference,omitempty"`
+	// StripPrefix and StripSuffix are removed from each key after renaming
+	StripPrefix *string `json:"stripPrefix,omitempty"`
+	StripSuffix *string `json:"stripSuffix,omitempty"`
+	// Case converts each key to upper or lower case after stripping
+	Case *string `json:"case,omitempty"`
 }
 
 // Webhook represents the configuration for a webhook.
@@ -92,6 +118,8 @@ type EmailNotification struct {
 	From    string              `yaml:"from,omitempty" json:"from,omitempty"`
 	Subject string              `yaml:"subject,omitempty" json:"subject,omitempty"`
 	Body    string              `yaml:"body,omitempty" json:"body,omitempty"`
+	// ToOwners also addresses the email to the sync's owners that are email addresses
+	ToOwners bool `yaml:"toOwners,omitempty" json:"toOwners,omitempty"`
 
 	Host               string `yaml:"host,omitempty" json:"host,omitempty"`
 	Port               int    `yaml:"port,omitempty" json:"port,omitempty"`
@@ -112,6 +140,25 @@ type NotificationMessage struct {
 	Event           NotificationEvent `json:"event"`
 	Message         string            `json:"message"`
 	VaultSecretSync VaultSecretSync   `json:"vaultSecretSync"`
+	// Owners are read from the sync's OwnersAnnotation
+	Owners []string `json:"owners,omitempty"`
+	// Digest is set on digest notifications that batch many sync events
+	Digest *NotificationDigest `json:"digest,omitempty"`
+}
+
+// NotificationDigest summarizes the sync events batched into one notification
+type NotificationDigest struct {
+	Successes int             `json:"successes"`
+	Failures  []DigestFailure `json:"failures,omitempty"`
+	// Diff is the change summary of the pipeline run, if one was computed
+	Diff string `json:"diff,omitempty"`
+}
+
+// DigestFailure is a failed sync in a digest
+type DigestFailure struct {
+	Sync    string   `json:"sync"`
+	Message string   `json:"message"`
+	Owners  []string `json:"owners,omitempty"`
 }
 
 type NotificationSpec struct {
@@ -133,6 +180,10 @@ type VaultSecretSyncSpec struct {
 	Transforms            *TransformSpec      `json:"transforms,omitempty"`
 	Notifications         []*NotificationSpec `json:"notifications,omitempty"`
 	NotificationsTemplate *string             `json:"notificationsTemplate,omitempty"`
+	// Verify reads each written secret back and fails the path when it does
+	// not match what was written. Destinations that cannot be read back are
+	// not verified.
+	Verify *bool `yaml:"verify,omitempty" json:"verify,omitempty"`
 }
 
 // +kubebuilder:object:generate=true
@@ -57,6 +57,16 @@ func (in *FilterConfig) DeepCopy() *FilterConfig {
 func (in *NotificationMessage) DeepCopyInto(out *NotificationMessage) {
 	*out = *in
 	in.VaultSecretSync.DeepCopyInto(&out.VaultSecretSync)
+	if in.Owners != nil {
+		in, out := &in.Owners, &out.Owners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Digest != nil {
+		in, out := &in.Digest, &out.Digest
+		*out = new(NotificationDigest)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationMessage.
@@ -69,6 +79,48 @@ func (in *NotificationMessage) DeepCopy() *NotificationMessage {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DigestFailure) DeepCopyInto(out *DigestFailure) {
+	*out = *in
+	if in.Owners != nil {
+		in, out := &in.Owners, &out.Owners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DigestFailure.
+func (in *DigestFailure) DeepCopy() *DigestFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(DigestFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationDigest) DeepCopyInto(out *NotificationDigest) {
+	*out = *in
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]DigestFailure, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationDigest.
+func (in *NotificationDigest) DeepCopy() *NotificationDigest {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationDigest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NotificationSpec) DeepCopyInto(out *NotificationSpec) {
 	*out = *in
@@ -230,6 +282,22 @@ func (in *StoreConfig) DeepCopyInto(out *StoreConfig) {
 		in, out := &in.HTTP, &out.HTTP
 		*out = (*in).DeepCopy()
 	}
+	if in.Kubernetes != nil {
+		in, out := &in.Kubernetes, &out.Kubernetes
+		*out = (*in).DeepCopy()
+	}
+	if in.GRPC != nil {
+		in, out := &in.GRPC, &out.GRPC
+		*out = (*in).DeepCopy()
+	}
+	if in.AzureKeyVault != nil {
+		in, out := &in.AzureKeyVault, &out.AzureKeyVault
+		*out = (*in).DeepCopy()
+	}
+	if in.Cloudflare != nil {
+		in, out := &in.Cloudflare, &out.Cloudflare
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoreConfig.
@@ -265,6 +333,31 @@ func (in *TransformSpec) DeepCopyInto(out *TransformSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Wasm != nil {
+		in, out := &in.Wasm, &out.Wasm
+		*out = new(string)
+		**out = **in
+	}
+	if in.Reference != nil {
+		in, out := &in.Reference, &out.Reference
+		*out = new(string)
+		**out = **in
+	}
+	if in.StripPrefix != nil {
+		in, out := &in.StripPrefix, &out.StripPrefix
+		*out = new(string)
+		**out = **in
+	}
+	if in.StripSuffix != nil {
+		in, out := &in.StripSuffix, &out.StripSuffix
+		*out = new(string)
+		**out = **in
+	}
+	if in.Case != nil {
+		in, out := &in.Case, &out.Case
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransformSpec.
@@ -395,6 +488,11 @@ func (in *VaultSecretSyncSpec) DeepCopyInto(out *VaultSecretSyncSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultSecretSyncSpec.
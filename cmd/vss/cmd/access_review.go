@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	accessReviewTargets string
+	accessReviewFormat  string
+	accessReviewOutput  string
+)
+
+var accessReviewCmd = &cobra.Command{
+	Use:   "access-review",
+	Short: "Report which IAM principals can read each synced secret",
+	Long: `Reports, for every secret the pipeline syncs into Secrets Manager, which
+principals can read it (secretsmanager:GetSecretValue), for periodic access
+reviews. Secrets are taken from the merge store.
+
+Roles and users in the destination account are checked with IAM policy
+simulation against the secret's resource policy, so permissions boundaries and
+explicit denies are honoured. Principals outside the account that the
+resource policy grants are listed as well. Simulation does not evaluate
+service control policies or the KMS key policy.
+
+The role used for each account needs iam:GetAccountAuthorizationDetails,
+iam:SimulatePrincipalPolicy and secretsmanager:GetResourcePolicy.
+
+Secrets whose readers cannot be resolved are recorded with their error, and
+the command exits 2 after writing the report.
+
+Formats:
+  - json: one entry per secret with its readers
+  - csv:  one row per secret and reader, for spreadsheets
+
+Examples:
+  vss access-review --output access-review.json
+  vss access-review --targets Serverless_Prod --format csv > prod.csv`,
+	RunE: runAccessReview,
+}
+
+func init() {
+	rootCmd.AddCommand(accessReviewCmd)
+
+	accessReviewCmd.Flags().StringVar(&accessReviewTargets, "targets", "", "comma-separated targets to review (default: all)")
+	accessReviewCmd.Flags().StringVar(&accessReviewFormat, "format", "json", "report format (json, csv)")
+	accessReviewCmd.Flags().StringVar(&accessReviewOutput, "output", "", "output file (default: stdout)")
+}
+
+func runAccessReview(cmd *cobra.Command, args []string) error {
+	if accessReviewFormat != "json" && accessReviewFormat != "csv" {
+		return usageErrorf("unknown --format %q (expected json or csv)", accessReviewFormat)
+	}
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	var targetList []string
+	if accessReviewTargets != "" {
+		for _, t := range strings.Split(accessReviewTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+	review, err := p.AccessReview(ctx, targetList)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if accessReviewOutput != "" {
+		f, err := os.Create(accessReviewOutput)
+		if err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if accessReviewFormat == "csv" {
+		err = writeAccessReviewCSV(out, review)
+	} else {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(review)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	if accessReviewOutput != "" {
+		readers := 0
+		for _, s := range review.Secrets {
+			readers += len(s.Readers)
+		}
+		fmt.Fprintf(os.Stderr, "✅ Reviewed %d secrets (%d readers) to %s\n", len(review.Secrets), readers, accessReviewOutput)
+	}
+	if errs := review.Errors(); len(errs) > 0 {
+		return fmt.Errorf("access review is incomplete:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// writeAccessReviewCSV writes one row per secret and reader. Secrets without
+// readers, or whose readers could not be resolved, get a single row.
+func writeAccessReviewCSV(out io.Writer, review *pipeline.AccessReview) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"target", "destination", "account_id", "secret", "arn", "principal", "via", "error"}); err != nil {
+		return err
+	}
+	for _, s := range review.Secrets {
+		row := []string{s.Target, s.Destination, s.AccountID, s.Secret, s.ARN}
+		if len(s.Readers) == 0 {
+			if err := w.Write(append(row, "", "", s.Error)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, r := range s.Readers {
+			if err := w.Write(append(row[:5:5], r.Principal, r.Via, s.Error)); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
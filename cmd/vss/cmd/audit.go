@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditTarget         string
+	auditSince          string
+	auditEventDataStore string
+	auditOutput         string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit what the pipeline changed in the target accounts",
+}
+
+var auditCloudTrailCmd = &cobra.Command{
+	Use:   "cloudtrail",
+	Short: "List the Secrets Manager changes made by the pipeline's role sessions",
+	Long: `Lists the Secrets Manager changes (CreateSecret, PutSecretValue, DeleteSecret,
+TagResource and the like) that sessions of a target's role made in its
+Secrets Manager destination accounts, and the pipeline run each belongs to.
+
+Runs are identified by the run ID in the session's tags or source identity,
+so tag the sessions with assume_role.session_tags or source_identity (e.g.
+vss-run-id: "{{.RunID}}"). Changes without a run were made with the role
+outside a tagged pipeline run. Runs missing from pipeline.history are flagged.
+
+By default CloudTrail LookupEvents is called in each destination account and
+region with the target's role, which needs cloudtrail:LookupEvents and covers
+the last 90 days. With --event-data-store, a CloudTrail Lake event data store is
+queried with the execution identity instead; Lake attributes runs by source
+identity only.
+
+Examples:
+  vss audit cloudtrail --target Serverless_Prod --since 7d
+  vss audit cloudtrail --target Serverless_Prod --since 24h -o json
+  vss audit cloudtrail --target Serverless_Prod --since 30d --event-data-store arn:aws:cloudtrail:us-east-1:111111111111:eventdatastore/EXAMPLE-f852-4e8f-8bd1-bcf6cEXAMPLE`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if auditOutput != "human" && auditOutput != "json" {
+			return usageErrorf("--output must be human or json, got %q", auditOutput)
+		}
+		if _, err := parseSince(auditSince); err != nil {
+			return usageErrorf("--since: %v", err)
+		}
+		return nil
+	},
+	RunE: runAuditCloudTrail,
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditCloudTrailCmd)
+
+	auditCloudTrailCmd.Flags().StringVar(&auditTarget, "target", "", "target whose destination accounts to audit")
+	auditCloudTrailCmd.Flags().StringVar(&auditSince, "since", "7d", "how far back to look, e.g. 7d or 12h")
+	auditCloudTrailCmd.Flags().StringVar(&auditEventDataStore, "event-data-store", "", "query this CloudTrail Lake event data store (ID or ARN) instead of LookupEvents")
+	auditCloudTrailCmd.Flags().StringVarP(&auditOutput, "output", "o", "human", "output format: human, json")
+	auditCloudTrailCmd.MarkFlagRequired("target")
+}
+
+// parseSince parses a lookback such as 7d or 12h
+func parseSince(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %q must be positive", s)
+	}
+	return d, nil
+}
+
+func runAuditCloudTrail(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	since, _ := parseSince(auditSince)
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+	audit, err := p.AuditCloudTrail(ctx, pipeline.CloudTrailAuditOptions{
+		Target:         auditTarget,
+		Since:          time.Now().Add(-since),
+		EventDataStore: auditEventDataStore,
+	})
+	if err != nil {
+		return err
+	}
+
+	if auditOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(audit); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	} else {
+		printCloudTrailAudit(os.Stdout, audit)
+	}
+	if len(audit.Errors) > 0 {
+		return fmt.Errorf("audit is incomplete:\n  %s", strings.Join(audit.Errors, "\n  "))
+	}
+	return nil
+}
+
+func printCloudTrailAudit(w io.Writer, audit *pipeline.CloudTrailAudit) {
+	if len(audit.Events) == 0 {
+		fmt.Fprintf(w, "No Secrets Manager changes by %s's role sessions since %s\n", audit.Target, audit.Since.Format(time.RFC3339))
+		return
+	}
+	for _, e := range audit.Events {
+		run := e.RunID
+		if run == "" {
+			run = "no run"
+		}
+		status := ""
+		if e.ErrorCode != "" {
+			status = " (" + e.ErrorCode + ")"
+		}
+		fmt.Fprintf(w, "%s  %s  %-24s %s%s  [%s]\n", e.Time.UTC().Format(time.RFC3339), e.Destination, e.EventName, e.Secret, status, run)
+	}
+
+	fmt.Fprintln(w)
+	unattributed := len(audit.Events)
+	for _, r := range audit.Runs {
+		unattributed -= r.Events
+		note := ""
+		if audit.HistoryChecked && !r.Recorded {
+			note = " ⚠️  not in the run history"
+		}
+		fmt.Fprintf(w, "Run %s: %d changes%s\n", r.ID, r.Events, note)
+	}
+	if unattributed > 0 {
+		fmt.Fprintf(w, "⚠️  %d changes were made outside a tagged pipeline run\n", unattributed)
+	}
+}
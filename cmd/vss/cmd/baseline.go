@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baselineTargets string
+	baselineOutput  string
+)
+
+var baselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Capture destinations and compare the merged state with them",
+	Long: `Captures what the Secrets Manager destinations hold before vss manages them,
+e.g. the secrets Terraform wrote, and compares the merged state with that
+baseline for as long as a migration runs.
+
+Examples:
+  # Snapshot the current destinations into the merge store
+  vss baseline capture --config config.yaml
+
+  # Prove the pipeline would write exactly what the destinations held
+  vss baseline diff --config config.yaml`,
+}
+
+var baselineCaptureCmd = &cobra.Command{
+	Use:   "capture",
+	Short: "Snapshot destination secrets into the merge store",
+	Long: `Reads each target's AWS Secrets Manager destinations and stores their
+secrets in the merge store as the baseline of the account and region: the
+secrets the target would write and every other secret under its name prefix.
+Capturing again overwrites the captured secrets.
+
+Destinations that cannot be read back (GitHub, Doppler, Kubernetes, gRPC and
+envelope targets) are listed as not captured.`,
+	RunE: runBaselineCapture,
+}
+
+var baselineDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the merged state with the captured baseline",
+	Long: `Compares each target's merged secrets, transformed and named the way the
+sync phase writes them, with the captured baseline instead of the live
+destinations. A zero-sum diff proves the pipeline changes nothing compared to
+the state it replaces.
+
+Exit codes: 0 no changes, 1 changes, 2 a destination has no baseline or could
+not be compared.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := diff.ParseOutputFormat(baselineOutput); err != nil {
+			return &usageError{err: fmt.Errorf("--output: %w", err)}
+		}
+		return nil
+	},
+	RunE: runBaselineDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(baselineCmd)
+	baselineCmd.AddCommand(baselineCaptureCmd)
+	baselineCmd.AddCommand(baselineDiffCmd)
+
+	baselineCmd.PersistentFlags().StringVar(&baselineTargets, "targets", "", "comma-separated targets (default: all)")
+	baselineDiffCmd.Flags().StringVarP(&baselineOutput, "output", "o", "human", "output format: human, json, github, compact")
+}
+
+// baselinePipeline loads the config and the --targets list
+func baselinePipeline(ctx context.Context) (*pipeline.Pipeline, []string, error) {
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	var targetList []string
+	if baselineTargets != "" {
+		for _, t := range strings.Split(baselineTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+	return p, targetList, nil
+}
+
+func runBaselineCapture(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	p, targetList, err := baselinePipeline(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	capture, err := p.CaptureBaseline(ctx, targetList)
+	if err != nil {
+		return err
+	}
+
+	dests := make([]string, 0, len(capture.Secrets))
+	for d := range capture.Secrets {
+		dests = append(dests, d)
+	}
+	sort.Strings(dests)
+	for _, d := range dests {
+		fmt.Printf("✅ %s: %d secrets\n", d, capture.Secrets[d])
+	}
+	if len(capture.Unchecked) > 0 {
+		fmt.Fprintf(os.Stderr, "Not captured (destination cannot be read back): %s\n", strings.Join(capture.Unchecked, ", "))
+	}
+	if len(capture.Errors) > 0 {
+		return fmt.Errorf("baseline capture is incomplete:\n  %s", strings.Join(capture.Errors, "\n  "))
+	}
+	return nil
+}
+
+func runBaselineDiff(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	p, targetList, err := baselinePipeline(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	report, err := p.BaselineDiff(ctx, targetList)
+	if err != nil {
+		return err
+	}
+
+	report.Diff.ConfigPath = cfgFile
+	fmt.Println(diff.FormatDiff(report.Diff, parseOutputFormat(baselineOutput)))
+	if len(report.Unchecked) > 0 {
+		fmt.Fprintf(os.Stderr, "Not compared (destination cannot be read back): %s\n", strings.Join(report.Unchecked, ", "))
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("baseline diff is incomplete:\n  %s", strings.Join(report.Errors, "\n  "))
+	}
+	if report.HasDrift() {
+		return changesDetected()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	breakglassTarget      string
+	breakglassDestination string
+	breakglassReason      string
+)
+
+var breakglassCmd = &cobra.Command{
+	Use:   "breakglass",
+	Short: "Emergency access to synced secrets",
+}
+
+var breakglassGetCmd = &cobra.Command{
+	Use:   "get <secret>",
+	Short: "Read a synced secret's value with a recorded justification",
+	Long: `Reads a secret from a target's Secrets Manager destination with the
+pipeline's credentials and prints its value once, for emergencies where no
+one else can reach it.
+
+Before the value is read, the request is written to the audit log with the
+justification, the operator (GITHUB_ACTOR or the local user) and the AWS
+caller identity, and hooks on the breakglass event are called with the same
+record (see pipeline.hooks). A failing required breakglass hook refuses the
+read, so a notification can be made mandatory.
+
+Examples:
+  vss breakglass get db/password --target Serverless_Prod --reason "INC-1234: rotate leaked key"
+  vss breakglass get api --target Multi --destination aws:222222222222/eu-west-1 --reason "..."`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: validateBreakglassFlags,
+	RunE:    runBreakglassGet,
+}
+
+func init() {
+	rootCmd.AddCommand(breakglassCmd)
+	breakglassCmd.AddCommand(breakglassGetCmd)
+
+	breakglassGetCmd.Flags().StringVar(&breakglassTarget, "target", "", "target whose destination holds the secret")
+	breakglassGetCmd.Flags().StringVar(&breakglassDestination, "destination", "", "destination label, when the target has several Secrets Manager destinations")
+	breakglassGetCmd.Flags().StringVar(&breakglassReason, "reason", "", "justification recorded in the audit log and notifications")
+	breakglassGetCmd.MarkFlagRequired("target")
+	breakglassGetCmd.MarkFlagRequired("reason")
+}
+
+func validateBreakglassFlags(cmd *cobra.Command, args []string) error {
+	if strings.TrimSpace(breakglassReason) == "" {
+		return usageErrorf("--reason must not be empty")
+	}
+	return nil
+}
+
+func runBreakglassGet(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	value, err := p.Breakglass(ctx, pipeline.BreakglassRequest{
+		Target:      breakglassTarget,
+		Destination: breakglassDestination,
+		Secret:      args[0],
+		Reason:      breakglassReason,
+		Operator:    currentOperator(),
+	})
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(value)
+	if len(value) == 0 || value[len(value)-1] != '\n' {
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var claimsOutput string
+
+var claimsCmd = &cobra.Command{
+	Use:   "claims",
+	Short: "List the controllers claiming targets",
+	Long: `Lists the target claims recorded in the merge store by controllers with
+pipeline.coordination set: which controller (and host/pid, or CI run) holds
+each target, and when it last sent a heartbeat. A live claim makes other
+controllers refuse to run the target; a claim whose holder stopped sending
+heartbeats for its TTL has expired and no longer does.
+
+Examples:
+  vss claims --config config.yaml
+  vss claims --config config.yaml -o json`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if claimsOutput != "human" && claimsOutput != "json" {
+			return usageErrorf("--output must be human or json, got %q", claimsOutput)
+		}
+		return nil
+	},
+	RunE: runClaims,
+}
+
+func init() {
+	rootCmd.AddCommand(claimsCmd)
+	claimsCmd.Flags().StringVarP(&claimsOutput, "output", "o", "human", "output format: human, json")
+}
+
+func runClaims(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+	claims, err := p.Claims(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claimsOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(claims)
+	}
+	printClaims(os.Stdout, claims, time.Now())
+	return nil
+}
+
+func printClaims(w io.Writer, claims []pipeline.Claim, now time.Time) {
+	if len(claims) == 0 {
+		fmt.Fprintln(w, "No claims recorded")
+		return
+	}
+	for _, c := range claims {
+		state := "live"
+		switch {
+		case c.Released != nil:
+			state = "released " + now.Sub(*c.Released).Round(time.Second).String() + " ago"
+		case !c.Live(now):
+			state = "expired"
+		}
+		fmt.Fprintf(w, "%s: %s (%s), %s, last heartbeat %s ago\n",
+			c.Target, c.Controller, c.Instance, state, now.Sub(c.Heartbeat).Round(time.Second))
+		if c.Run != "" {
+			fmt.Fprintf(w, "   %s\n", c.Run)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	driftTargets string
+	driftOutput  string
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Compare destinations with the merged state",
+	Long: `Reads each target's merged secrets and compares them with what its
+destinations actually hold, to find secrets edited, deleted or created outside
+vss. Unlike --dry-run, which predicts what a run would change, drift inspects
+the live destinations.
+
+Merged secrets are transformed and named the way the sync phase writes them
+before comparing. A secret is reported as added when it is missing from the
+destination, modified when its value differs and removed when it exists in the
+destination but not in the merged state. Removed secrets are only found under
+a target's own name prefix (transforms.name), since other secrets in the
+account may not be managed by vss.
+
+AWS Secrets Manager destinations are checked. Destinations that cannot be read
+back (GitHub, Doppler, Kubernetes, gRPC and envelope targets) are listed as
+unchecked. Secret values are never printed.
+
+Exit codes: 0 no drift, 1 drift detected, 2 a destination could not be read.
+
+Examples:
+  vss drift --config config.yaml
+  vss drift --config config.yaml --targets Serverless_Prod --output github`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := diff.ParseOutputFormat(driftOutput); err != nil {
+			return &usageError{err: fmt.Errorf("--output: %w", err)}
+		}
+		return nil
+	},
+	RunE: runDrift,
+}
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+
+	driftCmd.Flags().StringVar(&driftTargets, "targets", "", "comma-separated targets to check (default: all)")
+	driftCmd.Flags().StringVarP(&driftOutput, "output", "o", "human", "output format: human, json, github, compact")
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	var targetList []string
+	if driftTargets != "" {
+		for _, t := range strings.Split(driftTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+	report, err := p.Drift(ctx, targetList)
+	if err != nil {
+		return err
+	}
+
+	report.Diff.ConfigPath = cfgFile
+	fmt.Println(diff.FormatDiff(report.Diff, parseOutputFormat(driftOutput)))
+	if len(report.Unchecked) > 0 {
+		fmt.Fprintf(os.Stderr, "Not checked (destination cannot be read back): %s\n", strings.Join(report.Unchecked, ", "))
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("drift check is incomplete:\n  %s", strings.Join(report.Errors, "\n  "))
+	}
+	if report.HasDrift() {
+		return changesDetected()
+	}
+	return nil
+}
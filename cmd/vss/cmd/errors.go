@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+// usageError is returned for invalid flags or arguments. Only usage errors print
+// the command's usage; runtime failures print just the error.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf formats a usageError
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+// isUsageError reports whether err was caused by invalid command-line input
+func isUsageError(err error) bool {
+	var ue *usageError
+	var oe *pipeline.OptionError
+	return errors.As(err, &ue) || errors.As(err, &oe)
+}
+
+// flagUsageError wraps cobra flag parsing errors as usage errors
+func flagUsageError(cmd *cobra.Command, err error) error {
+	return &usageError{err: err}
+}
+
+// exitError ends the command with a specific exit code. With a nil err nothing
+// is printed, e.g. when changes are detected and already reported.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// changesDetected exits 1 without an error message
+func changesDetected() error {
+	return &exitError{code: diff.ExitCodeChanges}
+}
+
+// isSilentExit reports whether err only carries an exit code
+func isSilentExit(err error) bool {
+	var ee *exitError
+	return errors.As(err, &ee) && ee.err == nil
+}
+
+// exitCode maps a command error to the exit code contract: exitErrors carry their
+// own code and every other error, including usage errors, exits 2
+func exitCode(err error) int {
+	if err == nil {
+		return diff.ExitCodeNoChanges
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return diff.ExitCodeError
+}
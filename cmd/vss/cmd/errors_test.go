@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: 0},
+		{name: "changes detected", err: changesDetected(), want: 1},
+		{name: "wrapped changes", err: fmt.Errorf("verify: %w", &exitError{code: 1, err: errors.New("not zero-sum")}), want: 1},
+		{name: "runtime error", err: errors.New("vault unreachable"), want: 2},
+		{name: "usage error", err: usageErrorf("bad flag"), want: 2},
+		{name: "option error", err: &pipeline.OptionError{Option: "target", Value: "x", Reason: "not found"}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, exitCode(tt.err))
+		})
+	}
+
+	assert.True(t, isSilentExit(changesDetected()))
+	assert.False(t, isSilentExit(&exitError{code: 1, err: errors.New("not zero-sum")}))
+	assert.True(t, isUsageError(usageErrorf("bad flag")))
+	assert.False(t, isUsageError(errors.New("vault unreachable")))
+}
+
+func writeCmdTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestExecuteExitCodes(t *testing.T) {
+	valid := writeCmdTestConfig(t, `
+vault:
+  address: https://vault.example.com
+sources:
+  analytics:
+    vault:
+      mount: analytics
+merge_store:
+  vault:
+    mount: merged
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+    imports: [analytics]
+`)
+	invalid := writeCmdTestConfig(t, `
+vault:
+  address: https://vault.example.com
+targets:
+  Serverless_Stg:
+    imports: [missing]
+`)
+	typo := writeCmdTestConfig(t, `
+vault:
+  address: https://vault.example.com
+merge_store:
+  vault:
+    mount: merged
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+    regoin: us-east-1
+`)
+	t.Cleanup(func() { mergeOnly, syncOnly, strictConfig = false, false, false })
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "valid config", args: []string{"validate", "--config", valid}, want: 0},
+		{name: "invalid config", args: []string{"validate", "--config", invalid}, want: 2},
+		{name: "unknown key", args: []string{"validate", "--config", typo}, want: 2},
+		{name: "unknown key not strict", args: []string{"validate", "--config", typo, "--strict=false"}, want: 0},
+		{name: "unknown flag", args: []string{"validate", "--no-such-flag"}, want: 2},
+		{name: "conflicting flags", args: []string{"pipeline", "--config", valid, "--merge-only", "--sync-only"}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootCmd.SetArgs(tt.args)
+			assert.Equal(t, tt.want, execute(rootCmd))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat         string
+	exportOutput         string
+	exportDiscover       bool
+	exportTrustedAccount string
+	exportBootstrapRole  string
+	exportName           string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export targets and cross-account roles for IaC tools",
+	Long: `Exports the pipeline's targets and the cross-account roles they require
+in formats consumed by infrastructure-as-code tools.
+
+Supported formats:
+  - pulumi:     Pulumi YAML program (providers, IAM roles and role policies)
+  - crossplane: Crossplane XRD, Composition, ProviderConfigs and one
+                XSecretsSyncTarget composite resource per target
+
+Target roles trust the account the pipeline runs from
+(aws.execution_context.account_id, or --trusted-account).
+
+Examples:
+  vss export --config config.yaml --format pulumi --output Pulumi.yaml
+  vss export --config config.yaml --format crossplane --discover > targets.yaml`,
+	RunE: runExport,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "pulumi", "export format (pulumi, crossplane)")
+	exportCmd.Flags().StringVar(&exportOutput, "output", "", "output file (default: stdout)")
+	exportCmd.Flags().BoolVar(&exportDiscover, "discover", false, "include dynamically discovered targets")
+	exportCmd.Flags().StringVar(&exportTrustedAccount, "trusted-account", "", "account ID target roles should trust (default: aws.execution_context.account_id)")
+	exportCmd.Flags().StringVar(&exportBootstrapRole, "bootstrap-role", "OrganizationAccountAccessRole", "existing role used to provision target roles")
+	exportCmd.Flags().StringVar(&exportName, "name", "vss-targets", "Pulumi project / Crossplane composition name")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	var cfg *pipeline.Config
+	if exportDiscover {
+		loaded, err := loadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		p, err := pipeline.NewWithContext(context.Background(), loaded)
+		if err != nil {
+			return fmt.Errorf("failed to discover targets: %w", err)
+		}
+		defer p.Close()
+		cfg = p.Config()
+	} else {
+		var err error
+		cfg, err = loadConfig(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+
+	data, err := cfg.Export(pipeline.ExportFormat(exportFormat), pipeline.ExportOptions{
+		TrustedAccountID:  exportTrustedAccount,
+		BootstrapRoleName: exportBootstrapRole,
+		Name:              exportName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+
+	if exportOutput == "" {
+		fmt.Print(string(data))
+		return nil
+	}
+	if err := os.WriteFile(exportOutput, data, 0600); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "✅ Exported %d targets to %s\n", len(cfg.Targets), exportOutput)
+	return nil
+}
@@ -20,22 +20,38 @@ The graph shows:
 - Inheritance relationships (target → target)
 - Execution order (by dependency level)
 
+With --serve, the graph is served as a web page that colors each target by
+its latest sync status in the run history (pipeline.history.dir) and refreshes
+as new runs are recorded.
+
+With --format dot --full, the diagram also shows the merge store and each
+target's destinations (AWS account and region, Doppler project, GitHub repo,
+Kubernetes cluster, gRPC store) as distinct shapes.
+
 Examples:
   vss graph --config config.yaml
-  vss graph --config config.yaml --format dot`,
+  vss graph --config config.yaml --format dot
+  vss graph --config config.yaml --format dot --full
+  vss graph --config config.yaml --serve :8080`,
 	RunE: runGraph,
 }
 
-var graphFormat string
+var (
+	graphFormat string
+	graphServe  string
+	graphFull   bool
+)
 
 func init() {
 	rootCmd.AddCommand(graphCmd)
 	graphCmd.Flags().StringVar(&graphFormat, "format", "text", "output format (text, dot)")
+	graphCmd.Flags().BoolVar(&graphFull, "full", false, "include the merge store and destinations in dot output")
+	graphCmd.Flags().StringVar(&graphServe, "serve", "", "serve the graph with live target status on this address, e.g. :8080")
 }
 
 func runGraph(cmd *cobra.Command, args []string) error {
 	// Load config
-	cfg, err := pipeline.LoadConfig(cfgFile)
+	cfg, err := loadConfig(cfgFile)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -46,9 +62,13 @@ func runGraph(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to build graph: %w", err)
 	}
 
+	if graphServe != "" {
+		return serveGraph(graphServe, cfg)
+	}
+
 	switch graphFormat {
 	case "dot":
-		printDotGraph(cfg, graph)
+		printDotGraph(cfg, graph, graphFull)
 	default:
 		printTextGraph(cfg, graph)
 	}
@@ -64,9 +84,9 @@ func printTextGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 	fmt.Println("\n📦 Sources:")
 	for name, src := range cfg.Sources {
 		if src.Vault != nil {
-			fmt.Printf("   %s (vault: %s)\n", name, src.Vault.Mount)
+			fmt.Printf("   %s (vault: %s)%s\n", name, src.Vault.Mount, ownersSuffix(src.Owners))
 		} else if src.AWS != nil {
-			fmt.Printf("   %s (aws: %s)\n", name, src.AWS.AccountID)
+			fmt.Printf("   %s (aws: %s)%s\n", name, src.AWS.AccountID, ownersSuffix(src.Owners))
 		}
 	}
 
@@ -84,20 +104,23 @@ func printTextGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 			// Categorize imports
 			var sources, inherited []string
 			for _, imp := range target.Imports {
-				if _, isTarget := cfg.Targets[imp]; isTarget {
+				if _, isTarget := cfg.Targets[pipeline.ImportName(imp)]; isTarget {
 					inherited = append(inherited, imp)
 				} else {
 					sources = append(sources, imp)
 				}
 			}
 
-			fmt.Printf("   ├── %s (account: %s)\n", name, target.AccountID)
+			fmt.Printf("   ├── %s (account: %s)\n", name, targetDestination(target))
 			if len(sources) > 0 {
 				fmt.Printf("   │   └── sources: %v\n", sources)
 			}
 			if len(inherited) > 0 {
 				fmt.Printf("   │   └── inherits: %v\n", inherited)
 			}
+			if len(target.Owners) > 0 {
+				fmt.Printf("   │   └── owners: %s\n", strings.Join(target.Owners, ", "))
+			}
 		}
 	}
 
@@ -138,7 +161,7 @@ func printInheritanceTree(cfg *pipeline.Config, graph *pipeline.Graph, name stri
 	}
 	
 	target := cfg.Targets[name]
-	fmt.Printf("%s%s %s (→ %s)\n", prefix, connector, name, target.AccountID)
+	fmt.Printf("%s%s %s (→ %s)\n", prefix, connector, name, targetDestination(target))
 
 	// Find children (targets that inherit from this one) using pre-computed graph
 	var children []string
@@ -161,7 +184,7 @@ func printInheritanceTree(cfg *pipeline.Config, graph *pipeline.Graph, name stri
 	}
 }
 
-func printDotGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
+func printDotGraph(cfg *pipeline.Config, graph *pipeline.Graph, full bool) {
 	fmt.Println("digraph secrets_pipeline {")
 	fmt.Println("  rankdir=LR;")
 	fmt.Println("  node [shape=box];")
@@ -172,7 +195,11 @@ func printDotGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 	fmt.Println("    label=\"Sources\";")
 	fmt.Println("    style=dashed;")
 	fmt.Println("    color=blue;")
-	for name := range cfg.Sources {
+	for name, src := range cfg.Sources {
+		if len(src.Owners) > 0 {
+			fmt.Printf("    \"%s\" [label=\"%s\\nowners: %s\", shape=cylinder, color=blue];\n", name, name, strings.Join(src.Owners, ", "))
+			continue
+		}
 		fmt.Printf("    \"%s\" [shape=cylinder, color=blue];\n", name)
 	}
 	fmt.Println("  }")
@@ -184,22 +211,145 @@ func printDotGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 	fmt.Println("    style=dashed;")
 	fmt.Println("    color=green;")
 	for name, target := range cfg.Targets {
-		fmt.Printf("    \"%s\" [label=\"%s\\n%s\", color=green];\n", name, name, target.AccountID)
+		label := name + "\\n" + targetDestination(target)
+		if len(target.Owners) > 0 {
+			label += "\\nowners: " + strings.Join(target.Owners, ", ")
+		}
+		fmt.Printf("    \"%s\" [label=\"%s\", color=green];\n", name, label)
 	}
 	fmt.Println("  }")
 	fmt.Println()
 
+	if full {
+		printDotArchitecture(cfg)
+	}
+
 	// Edges
 	fmt.Println("  // Dependencies")
 	for name, target := range cfg.Targets {
 		for _, imp := range target.Imports {
+			ref, _ := pipeline.ParseImportRef(imp)
 			style := "solid"
-			if _, isTarget := cfg.Targets[imp]; isTarget {
+			if _, isTarget := cfg.Targets[ref.Name]; isTarget {
 				style = "bold" // Inheritance edge
 			}
+			if ref.Pinned() {
+				fmt.Printf("  \"%s\" -> \"%s\" [style=%s, label=\"v%d\"];\n", ref.Name, name, style, ref.Version)
+				continue
+			}
 			fmt.Printf("  \"%s\" -> \"%s\" [style=%s];\n", imp, name, style)
 		}
 	}
 
 	fmt.Println("}")
 }
+
+// printDotArchitecture prints the merge store and destination nodes of a --full
+// dot graph, with edges from each target to where its secrets are written
+func printDotArchitecture(cfg *pipeline.Config) {
+	// Merge store cluster
+	fmt.Println("  subgraph cluster_merge_store {")
+	fmt.Println("    label=\"Merge Store\";")
+	fmt.Println("    style=dashed;")
+	fmt.Println("    color=purple;")
+	fmt.Printf("    \"merge_store\" [label=\"%s\", shape=folder, color=purple];\n", mergeStoreLabel(cfg.MergeStore))
+	fmt.Println("  }")
+	fmt.Println()
+
+	// Destinations cluster, one node per distinct destination
+	names := make([]string, 0, len(cfg.Targets))
+	for name := range cfg.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	shapes := make(map[string]string)
+	var edges []string
+	for _, name := range names {
+		target := cfg.Targets[name]
+		edges = append(edges, fmt.Sprintf("  \"%s\" -> \"merge_store\" [style=dotted, arrowhead=none];", name))
+		for _, d := range target.ResolvedDestinations() {
+			id, shape := dotDestination(cfg, target, d)
+			shapes[id] = shape
+			edges = append(edges, fmt.Sprintf("  \"%s\" -> \"%s\" [color=orange];", name, id))
+		}
+	}
+	ids := make([]string, 0, len(shapes))
+	for id := range shapes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	fmt.Println("  subgraph cluster_destinations {")
+	fmt.Println("    label=\"Destinations\";")
+	fmt.Println("    style=dashed;")
+	fmt.Println("    color=orange;")
+	for _, id := range ids {
+		fmt.Printf("    \"%s\" [shape=%s, color=orange];\n", id, shapes[id])
+	}
+	fmt.Println("  }")
+	fmt.Println()
+
+	fmt.Println("  // Merge store and destinations")
+	for _, e := range edges {
+		fmt.Println(e)
+	}
+	fmt.Println()
+}
+
+// dotDestination returns the node ID and shape of a destination. AWS
+// destinations show the region they resolve to.
+func dotDestination(cfg *pipeline.Config, target pipeline.Target, d pipeline.Destination) (string, string) {
+	// Identify destinations by what they write to, so targets sharing one share a node
+	d.Name = ""
+	switch {
+	case d.Doppler != nil:
+		return d.Label(), "note"
+	case d.GitHub != nil:
+		return d.Label(), "tab"
+	case d.Kubernetes != nil:
+		return d.Label(), "hexagon"
+	case d.GRPC != nil:
+		return d.Label(), "parallelogram"
+	}
+	region := d.Region
+	if region == "" {
+		region = target.Region
+	}
+	if region == "" {
+		region = cfg.AWS.Region
+	}
+	return fmt.Sprintf("aws:%s/%s", d.AccountID, region), "box3d"
+}
+
+// mergeStoreLabel describes where merged secrets are stored
+func mergeStoreLabel(ms pipeline.MergeStoreConfig) string {
+	switch {
+	case ms.Vault != nil:
+		return "vault: " + ms.Vault.Mount
+	case ms.S3 != nil:
+		return "s3: " + strings.TrimSuffix(ms.S3.Bucket+"/"+ms.S3.Prefix, "/")
+	}
+	return "not configured"
+}
+
+// targetDestination describes where a target writes: its account ID for plain AWS
+// targets, otherwise the labels of its destinations
+func targetDestination(t pipeline.Target) string {
+	if len(t.Destinations) == 0 && t.AccountID != "" {
+		return t.AccountID
+	}
+	var labels []string
+	for _, d := range t.ResolvedDestinations() {
+		labels = append(labels, d.Label())
+	}
+	return strings.Join(labels, ", ")
+}
+
+// ownersSuffix formats owners for the end of a text graph line
+func ownersSuffix(owners []string) string {
+	if len(owners) == 0 {
+		return ""
+	}
+	return " [owners: " + strings.Join(owners, ", ") + "]"
+}
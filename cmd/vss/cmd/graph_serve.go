@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+)
+
+// serveGraph serves the dependency graph as a web page whose target nodes are
+// colored by their latest sync status in the run history
+func serveGraph(addr string, cfg *pipeline.Config) error {
+	historyDir := ""
+	if cfg.Pipeline.History != nil {
+		historyDir = cfg.Pipeline.History.Dir
+	}
+	if historyDir == "" {
+		log.Warn("pipeline.history.dir is not set, target status will be unknown")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, graphPage)
+	})
+	mux.HandleFunc("/api/graph", func(w http.ResponseWriter, r *http.Request) {
+		var status map[string]pipeline.TargetStatus
+		if historyDir != "" {
+			runs, err := pipeline.LoadRunHistory(historyDir)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			status = pipeline.LatestTargetStatus(runs)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graphView{
+			Mermaid: mermaidGraph(cfg, status),
+			Status:  status,
+			Updated: time.Now().UTC(),
+		})
+	})
+
+	fmt.Printf("Serving dependency graph on http://%s\n", displayAddr(addr))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+// graphView is the /api/graph response
+type graphView struct {
+	Mermaid string                           `json:"mermaid"`
+	Status  map[string]pipeline.TargetStatus `json:"status"`
+	Updated time.Time                        `json:"updated"`
+}
+
+// mermaidGraph renders the dependency graph as a mermaid flowchart. Sources are
+// cylinders; targets are classed success, failure or unknown from status.
+func mermaidGraph(cfg *pipeline.Config, status map[string]pipeline.TargetStatus) string {
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	sb.WriteString("  classDef source fill:#dbeafe,stroke:#2563eb\n")
+	sb.WriteString("  classDef success fill:#dcfce7,stroke:#16a34a\n")
+	sb.WriteString("  classDef failure fill:#fee2e2,stroke:#dc2626\n")
+	sb.WriteString("  classDef unknown fill:#f3f4f6,stroke:#9ca3af\n")
+
+	// Mermaid IDs must be plain identifiers, so nodes are numbered in name order
+	ids := make(map[string]string)
+	sources := sortedKeys(cfg.Sources)
+	for i, name := range sources {
+		ids[name] = fmt.Sprintf("s%d", i)
+		fmt.Fprintf(&sb, "  %s[(\"%s\")]:::source\n", ids[name], mermaidLabel(name))
+	}
+	targets := sortedKeys(cfg.Targets)
+	for i, name := range targets {
+		ids[name] = fmt.Sprintf("t%d", i)
+		class := "unknown"
+		if s, ok := status[name]; ok {
+			class = "failure"
+			if s.Success {
+				class = "success"
+			}
+		}
+		label := mermaidLabel(name) + "<br/>" + mermaidLabel(targetDestination(cfg.Targets[name]))
+		fmt.Fprintf(&sb, "  %s[\"%s\"]:::%s\n", ids[name], label, class)
+	}
+
+	for _, name := range targets {
+		for _, imp := range cfg.Targets[name].Imports {
+			from, ok := ids[pipeline.ImportName(imp)]
+			if !ok {
+				continue
+			}
+			arrow := "-->"
+			if _, isTarget := cfg.Targets[pipeline.ImportName(imp)]; isTarget {
+				arrow = "==>" // Inheritance edge
+			}
+			fmt.Fprintf(&sb, "  %s %s %s\n", from, arrow, ids[name])
+		}
+	}
+	return sb.String()
+}
+
+// mermaidLabel escapes s for a quoted mermaid label
+func mermaidLabel(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// displayAddr makes a listen address such as :8080 browsable
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+const graphPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>vss dependency graph</title>
+<style>
+  body { font-family: sans-serif; margin: 1.5em; }
+  #updated { color: #6b7280; font-size: 0.9em; }
+  #failures li { color: #dc2626; }
+</style>
+</head>
+<body>
+<h1>Secrets Pipeline Dependency Graph</h1>
+<p id="updated"></p>
+<div id="graph"></div>
+<ul id="failures"></ul>
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs";
+mermaid.initialize({ startOnLoad: false, securityLevel: "strict" });
+
+let last = "";
+async function refresh() {
+  const res = await fetch("api/graph");
+  if (!res.ok) {
+    document.getElementById("updated").textContent = "Failed to load status: " + await res.text();
+    return;
+  }
+  const view = await res.json();
+  document.getElementById("updated").textContent = "Updated " + new Date(view.updated).toLocaleString();
+  if (view.mermaid !== last) {
+    const { svg } = await mermaid.render("pipeline", view.mermaid);
+    document.getElementById("graph").innerHTML = svg;
+    last = view.mermaid;
+  }
+  const failures = document.getElementById("failures");
+  failures.replaceChildren();
+  for (const s of Object.values(view.status || {})) {
+    if (!s.success) {
+      const li = document.createElement("li");
+      li.textContent = s.target + ": " + (s.error || "failed") + " (run " + s.run_id + ")";
+      failures.appendChild(li);
+    }
+  }
+}
+refresh();
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
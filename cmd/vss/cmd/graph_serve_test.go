@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMermaidGraph(t *testing.T) {
+	cfg := &pipeline.Config{
+		Sources: map[string]pipeline.Source{
+			"analytics": {Vault: &pipeline.VaultSource{Mount: "analytics"}},
+		},
+		Targets: map[string]pipeline.Target{
+			"Serverless_Stg":  {AccountID: "111111111111", Imports: []string{"analytics"}},
+			"Serverless_Prod": {AccountID: "222222222222", Imports: []string{"Serverless_Stg"}},
+			"Sandbox":         {AccountID: "333333333333", Imports: []string{"analytics"}},
+		},
+	}
+	status := map[string]pipeline.TargetStatus{
+		"Serverless_Stg":  {Target: "Serverless_Stg", Success: true},
+		"Serverless_Prod": {Target: "Serverless_Prod", Success: false, Error: "access denied"},
+	}
+
+	assert.Equal(t, `graph LR
+  classDef source fill:#dbeafe,stroke:#2563eb
+  classDef success fill:#dcfce7,stroke:#16a34a
+  classDef failure fill:#fee2e2,stroke:#dc2626
+  classDef unknown fill:#f3f4f6,stroke:#9ca3af
+  s0[("analytics")]:::source
+  t0["Sandbox<br/>333333333333"]:::unknown
+  t1["Serverless_Prod<br/>222222222222"]:::failure
+  t2["Serverless_Stg<br/>111111111111"]:::success
+  s0 --> t0
+  t2 ==> t1
+  s0 --> t2
+`, mermaidGraph(cfg, status))
+}
+
+func TestMermaidLabel(t *testing.T) {
+	assert.Equal(t, "a #quot;b#quot; #lt;c#gt;", mermaidLabel(`a "b" <c>`))
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inventoryTargets  string
+	inventoryOutput   string
+	inventoryUnsigned bool
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Write a signed inventory of every managed secret",
+	Long: `Writes a machine-readable inventory of the secrets vss manages, for
+compliance evidence. For each destination account it lists every target's
+secrets by name with the sha256 of their merged value, the imports they come
+from and when the target last synced (from pipeline.history). Secrets Manager
+destinations are listed to record whether each secret exists there and when it
+last changed. Secret values are never included.
+
+The inventory is signed with the pipeline.manifest signer (a KMS key or cosign)
+and can be checked with ` + "`vss verify-run`" + `. --unsigned writes the plain JSON
+instead.
+
+Destinations that cannot be listed are recorded in the inventory with their
+error, and the command exits 2 after writing it.
+
+Examples:
+  vss inventory --output inventory.json
+  vss inventory --targets Serverless_Prod --unsigned`,
+	RunE: runInventory,
+}
+
+func init() {
+	rootCmd.AddCommand(inventoryCmd)
+
+	inventoryCmd.Flags().StringVar(&inventoryTargets, "targets", "", "comma-separated targets to inventory (default: all)")
+	inventoryCmd.Flags().StringVar(&inventoryOutput, "output", "", "output file (default: stdout, unsigned only)")
+	inventoryCmd.Flags().BoolVar(&inventoryUnsigned, "unsigned", false, "write the inventory without signing it")
+}
+
+func runInventory(cmd *cobra.Command, args []string) error {
+	if !inventoryUnsigned && inventoryOutput == "" {
+		return usageErrorf("--output is required unless --unsigned is given")
+	}
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	var targetList []string
+	if inventoryTargets != "" {
+		for _, t := range strings.Split(inventoryTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+	inv, err := p.Inventory(ctx, targetList)
+	if err != nil {
+		return err
+	}
+
+	if inventoryUnsigned {
+		data, err := json.MarshalIndent(inv, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal inventory: %w", err)
+		}
+		if inventoryOutput == "" {
+			fmt.Println(string(data))
+		} else if err := os.WriteFile(inventoryOutput, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+	} else if err := p.WriteInventory(ctx, inv, inventoryOutput); err != nil {
+		return err
+	}
+
+	if inventoryOutput != "" {
+		printInventorySummary(os.Stderr, inv)
+	}
+	if errs := inv.Errors(); len(errs) > 0 {
+		return fmt.Errorf("inventory is incomplete:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+func printInventorySummary(out io.Writer, inv *pipeline.Inventory) {
+	secrets, destinations, missing := 0, 0, 0
+	for _, a := range inv.Accounts {
+		for _, d := range a.Destinations {
+			destinations++
+			secrets += len(d.Secrets)
+			for _, s := range d.Secrets {
+				if s.InDestination != nil && !*s.InDestination {
+					missing++
+				}
+			}
+		}
+	}
+	fmt.Fprintf(out, "Inventory: %s, %d accounts, %d destinations, %d secrets", inv.Generated.Format("2006-01-02T15:04:05Z07:00"), len(inv.Accounts), destinations, secrets)
+	if missing > 0 {
+		fmt.Fprintf(out, " (%d missing from their destination)", missing)
+	}
+	fmt.Fprintln(out)
+}
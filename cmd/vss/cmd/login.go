@@ -0,0 +1,356 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/jbcom/secretsync/internal/keychain"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	loginAddress   string
+	loginMethod    string
+	loginMount     string
+	loginRole      string
+	loginPort      int
+	loginNoBrowser bool
+	loginProfile   string
+)
+
+// loginTimeout bounds how long vss login waits for the operator to finish
+// signing in in the browser
+const loginTimeout = 10 * time.Minute
+
+// credentialStore keeps the tokens of vss login
+var credentialStore = keychain.Default()
+
+// loginServices are the services vss login signs in to
+var loginServices = []string{"vault", "doppler", "aws"}
+
+var loginCmd = &cobra.Command{
+	Use:   "login <vault|doppler|aws>",
+	Short: "Sign in to Vault, Doppler or AWS SSO for later commands",
+	Long: `Stores a Vault or Doppler token in the operating system's credential store:
+the macOS Keychain, the Secret Service (GNOME Keyring, KWallet) on Linux via
+secret-tool, or a DPAPI-encrypted file on Windows. The token is read from a
+hidden prompt, or from stdin when it is piped, never from arguments, so it
+stays out of shell history.
+
+With --method oidc, vss login vault signs in through Vault's OIDC auth method
+in the browser instead, as vault login -method=oidc does, and stores the
+token Vault issues. The role's allowed_redirect_uris must include
+http://localhost:8250/oidc/callback (or the --callback-port given).
+
+vss login aws signs in to IAM Identity Center with the device code flow of
+aws sso login, for the sso_session (or sso_start_url) of --profile, else
+AWS_PROFILE. The token goes to the AWS CLI's SSO cache, so every later
+command run with that profile gets its credentials.
+
+Later commands use a stored token wherever the config leaves one to the
+environment and the environment does not set it: vault.auth.token when its
+${VAR} is unset (or no Vault auth method is configured and VAULT_TOKEN is
+unset), and every Doppler token that is empty or whose ${VAR} is unset.
+
+Vault tokens are stored per Vault address: --address, else vault.address of
+--config, else VAULT_ADDR. Doppler has a single token.
+
+Examples:
+  vss login vault --config config.yaml
+  vault print token | vss login vault --address https://vault.example.com
+  vss login vault --method oidc --role operator --config config.yaml
+  vss login doppler
+  vss login aws --profile platform-admin`,
+	Args: loginArgs,
+	RunE: runLogin,
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout [vault|doppler|aws]",
+	Short: "Remove the tokens vss login stored",
+	Long: `Removes the tokens vss login stored: the Vault token of the Vault address
+(chosen as for vss login), the Doppler token, the cached AWS SSO token of the
+profile, or all of them without an argument.
+
+Examples:
+  vss logout vault --config config.yaml
+  vss logout`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return nil
+		}
+		return loginArgs(cmd, args)
+	},
+	RunE: runLogout,
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+
+	for _, c := range []*cobra.Command{loginCmd, logoutCmd} {
+		c.Flags().StringVar(&loginAddress, "address", "", "Vault address the token is for (default vault.address of --config, else VAULT_ADDR)")
+		c.Flags().StringVar(&loginProfile, "profile", "", "AWS profile of the SSO session (default AWS_PROFILE, else default)")
+	}
+	loginCmd.Flags().StringVar(&loginMethod, "method", "token", "How to sign in to Vault: token or oidc")
+	loginCmd.Flags().StringVar(&loginMount, "mount", "oidc", "Path the Vault OIDC auth method is mounted at")
+	loginCmd.Flags().StringVar(&loginRole, "role", "", "Vault OIDC role (default the mount's default_role)")
+	loginCmd.Flags().IntVar(&loginPort, "callback-port", 8250, "Localhost port of the Vault OIDC callback")
+	loginCmd.Flags().BoolVar(&loginNoBrowser, "no-browser", false, "Print the sign-in URL instead of opening a browser")
+}
+
+// loginArgs accepts a single service name
+func loginArgs(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("expected one of %s", strings.Join(loginServices, ", "))
+	}
+	for _, s := range loginServices {
+		if args[0] == s {
+			return nil
+		}
+	}
+	return usageErrorf("unknown service %q, expected one of %s", args[0], strings.Join(loginServices, ", "))
+}
+
+func runLogin(cmd *cobra.Command, args []string) error {
+	service := args[0]
+	switch {
+	case loginMethod != "token" && loginMethod != "oidc":
+		return usageErrorf("unknown --method %q, expected token or oidc", loginMethod)
+	case loginMethod == "oidc" && service != "vault":
+		return usageErrorf("--method oidc applies only to vault")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
+	open := openBrowser
+	if loginNoBrowser {
+		open = nil
+	}
+
+	if service == "aws" {
+		return runSSOLogin(ctx, open)
+	}
+	account, desc, err := credentialAccount(service)
+	if err != nil {
+		return err
+	}
+	var token string
+	if loginMethod == "oidc" {
+		token, err = runOIDCLogin(ctx, open)
+	} else {
+		token, err = readToken(os.Stdin, os.Stderr, desc)
+	}
+	if err != nil {
+		return err
+	}
+	return login(os.Stdout, credentialStore, account, desc, token)
+}
+
+// runOIDCLogin signs in to the Vault of vss login through its OIDC auth
+// method
+func runOIDCLogin(ctx context.Context, open func(url string) error) (string, error) {
+	address, namespace := loginVault()
+	vc := api.DefaultConfig()
+	vc.Address = address
+	client, err := api.NewClient(vc)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Vault client: %w", err)
+	}
+	// Signing in needs no token, and a stale VAULT_TOKEN would be rejected
+	client.ClearToken()
+	if namespace != "" {
+		client.SetNamespace(namespace)
+	}
+	return vaultOIDCLogin(ctx, client, oidcLoginOptions{
+		Mount:         loginMount,
+		Role:          loginRole,
+		ListenAddress: fmt.Sprintf("localhost:%d", loginPort),
+	}, open, os.Stderr)
+}
+
+func runLogout(cmd *cobra.Command, args []string) error {
+	services := loginServices
+	if len(args) == 1 {
+		services = args
+	}
+	for _, service := range services {
+		if service == "aws" {
+			profile := awsProfile()
+			session, err := loadSSOSession(context.Background(), profile)
+			if err != nil {
+				if len(args) == 0 {
+					continue
+				}
+				return err
+			}
+			if err := ssoLogout(os.Stdout, session, "AWS SSO token of profile "+profile, len(args) == 1); err != nil {
+				return err
+			}
+			continue
+		}
+		account, desc, err := credentialAccount(service)
+		if err != nil {
+			// Without an argument the Vault token is skipped when no Vault
+			// address is known, as is AWS when the profile has no SSO
+			if len(args) == 0 {
+				continue
+			}
+			return err
+		}
+		if err := logout(os.Stdout, credentialStore, account, desc, len(args) == 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// credentialAccount returns the credential store account of a service's
+// token and a description of it for messages
+func credentialAccount(service string) (account, desc string, err error) {
+	if service == "doppler" {
+		return pipeline.DopplerCredential, "Doppler token", nil
+	}
+	address, _ := loginVault()
+	if address == "" {
+		return "", "", usageErrorf("no Vault address: set --address, vault.address in --config or VAULT_ADDR")
+	}
+	return pipeline.VaultCredential(address), "Vault token for " + strings.TrimRight(address, "/"), nil
+}
+
+// loginVault returns the Vault address of --address, else the config's
+// vault.address, else VAULT_ADDR; and the config's vault.namespace, if any
+func loginVault() (address, namespace string) {
+	cfg, err := pipeline.LoadConfig(cfgFile)
+	if err == nil {
+		address, namespace = cfg.Vault.Address, cfg.Vault.Namespace
+	}
+	if loginAddress != "" {
+		address = loginAddress
+	}
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	return address, namespace
+}
+
+// readToken prompts for a token without echoing it when in is a terminal,
+// and otherwise reads its first line
+func readToken(in *os.File, prompt io.Writer, desc string) (string, error) {
+	var token string
+	if term.IsTerminal(int(in.Fd())) {
+		fmt.Fprintf(prompt, "%s: ", desc)
+		b, err := term.ReadPassword(int(in.Fd()))
+		fmt.Fprintln(prompt)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		token = string(b)
+	} else {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		token = line
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", usageErrorf("no token given")
+	}
+	return token, nil
+}
+
+// login stores a token
+func login(w io.Writer, store keychain.Store, account, desc, token string) error {
+	if err := store.Set(account, token); err != nil {
+		return fmt.Errorf("failed to store %s in the %s: %w", desc, store.Name(), err)
+	}
+	fmt.Fprintf(w, "Stored the %s in the %s\n", desc, store.Name())
+	return nil
+}
+
+// logout removes a stored token; a token that was never stored is an error
+// only when required
+func logout(w io.Writer, store keychain.Store, account, desc string, required bool) error {
+	err := store.Delete(account)
+	switch {
+	case errors.Is(err, keychain.ErrNotFound) && !required:
+		return nil
+	case errors.Is(err, keychain.ErrNotFound):
+		return fmt.Errorf("no %s is stored in the %s", desc, store.Name())
+	case err != nil:
+		return fmt.Errorf("failed to remove %s from the %s: %w", desc, store.Name(), err)
+	}
+	fmt.Fprintf(w, "Removed the %s from the %s\n", desc, store.Name())
+	return nil
+}
+
+// promptBrowser shows the operator a sign-in URL and, unless open is nil,
+// opens it in their browser
+func promptBrowser(w io.Writer, url, code string, open func(url string) error) {
+	if code != "" {
+		fmt.Fprintf(w, "Confirm the code %s in the browser to continue.\n", code)
+	}
+	if open != nil {
+		if err := open(url); err == nil {
+			fmt.Fprintf(w, "Opened the browser to sign in. If it did not open, visit:\n\n    %s\n\n", url)
+			return
+		}
+	}
+	fmt.Fprintf(w, "Visit this URL to sign in:\n\n    %s\n\n", url)
+}
+
+// openBrowser opens a URL in the operator's default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap the opener; the browser itself outlives vss
+	go cmd.Wait()
+	return nil
+}
+
+// storedCredentials looks up the tokens vss login stored for a config, at
+// most once per account. A credential store that cannot be read only costs
+// the fallback, so errors are logged, not returned.
+func storedCredentials(store keychain.Store) func(account string) string {
+	tokens := make(map[string]string)
+	return func(account string) string {
+		if token, ok := tokens[account]; ok {
+			return token
+		}
+		token, err := store.Get(account)
+		if err != nil && !errors.Is(err, keychain.ErrNotFound) && !errors.Is(err, keychain.ErrUnsupported) {
+			log.WithFields(log.Fields{
+				"action":  "storedCredentials",
+				"account": account,
+			}).WithError(err).Warn("Failed to read stored credential")
+		}
+		tokens[account] = token
+		return token
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// oidcLoginOptions configures a Vault OIDC login
+type oidcLoginOptions struct {
+	// Mount is the path the OIDC auth method is mounted at
+	Mount string
+	// Role is the OIDC role; empty uses the mount's default role
+	Role string
+	// ListenAddress is the address the callback listener binds; its port
+	// must be allowed by the role's allowed_redirect_uris
+	ListenAddress string
+}
+
+// vaultOIDCLogin signs in to Vault through the OIDC auth method the way vault
+// login -method=oidc does: it asks Vault for the provider's authorization
+// URL, has the operator open it, receives the provider's redirect on a
+// localhost callback and exchanges its code for a Vault token
+func vaultOIDCLogin(ctx context.Context, client *api.Client, opts oidcLoginOptions, open func(url string) error, w io.Writer) (string, error) {
+	mount := strings.Trim(opts.Mount, "/")
+	if mount == "" {
+		mount = "oidc"
+	}
+
+	listener, err := net.Listen("tcp", opts.ListenAddress)
+	if err != nil {
+		return "", fmt.Errorf("failed to start OIDC callback listener: %w", err)
+	}
+	defer listener.Close()
+	redirectURI := fmt.Sprintf("http://localhost:%d/oidc/callback", listener.Addr().(*net.TCPAddr).Port)
+
+	nonce, err := randomNonce()
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/oidc/auth_url", mount), map[string]interface{}{
+		"role":         opts.Role,
+		"redirect_uri": redirectURI,
+		"client_nonce": nonce,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get OIDC authorization URL: %w", err)
+	}
+	var authURL string
+	if secret != nil {
+		authURL, _ = secret.Data["auth_url"].(string)
+	}
+	if authURL == "" {
+		return "", fmt.Errorf("vault returned no OIDC authorization URL for role %q; check that %s is an allowed redirect URI", opts.Role, redirectURI)
+	}
+
+	type result struct {
+		token string
+		err   error
+	}
+	done := make(chan result, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oidc/callback", func(rw http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		if e := q.Get("error"); e != "" {
+			res.err = fmt.Errorf("OIDC provider returned an error: %s %s", e, q.Get("error_description"))
+		} else {
+			var secret *api.Secret
+			secret, res.err = client.Logical().ReadWithDataWithContext(r.Context(), fmt.Sprintf("auth/%s/oidc/callback", mount), map[string][]string{
+				"state":        {q.Get("state")},
+				"code":         {q.Get("code")},
+				"id_token":     {q.Get("id_token")},
+				"client_nonce": {nonce},
+			})
+			switch {
+			case res.err != nil:
+				res.err = fmt.Errorf("failed to complete OIDC login: %w", res.err)
+			case secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "":
+				res.err = errors.New("failed to complete OIDC login: vault returned no token")
+			default:
+				res.token = secret.Auth.ClientToken
+			}
+		}
+
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if res.err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(rw, "<p>vss login failed: %s</p>", html.EscapeString(res.err.Error()))
+		} else {
+			fmt.Fprint(rw, "<p>Signed in to Vault. You can close this window and return to vss.</p>")
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer server.Close()
+
+	promptBrowser(w, authURL, "", open)
+
+	select {
+	case res := <-done:
+		return res.token, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("timed out waiting for the OIDC login to complete: %w", ctx.Err())
+	}
+}
+
+// randomNonce returns a random value binding an OIDC login's authorization
+// URL to its callback
+func randomNonce() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate OIDC nonce: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+// ssoOIDCAPI is the subset of the IAM Identity Center OIDC API the device
+// code login uses; *ssooidc.Client implements it
+type ssoOIDCAPI interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// ssoPollInterval is how often the device code login polls for the token
+// when the service does not say
+var ssoPollInterval = 5 * time.Second
+
+// ssoDeviceGrant is the OAuth grant type of device code token requests
+const ssoDeviceGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ssoSession is the IAM Identity Center sign-in of a shared config profile
+type ssoSession struct {
+	// Name is the profile's sso-session, empty for a legacy profile that
+	// sets sso_start_url itself
+	Name     string
+	StartURL string
+	Region   string
+}
+
+// cacheKey returns what the SDK hashes into the session's token cache file
+// name: the sso-session name, or the start URL of a legacy profile
+func (s ssoSession) cacheKey() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.StartURL
+}
+
+// ssoToken is a token cache file as the AWS CLI and SDKs read and write it
+type ssoToken struct {
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	Region                string `json:"region"`
+	StartURL              string `json:"startUrl"`
+}
+
+// awsProfile returns the shared config profile of --profile, else
+// AWS_PROFILE, else default
+func awsProfile() string {
+	if loginProfile != "" {
+		return loginProfile
+	}
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+// loadSSOSession reads the IAM Identity Center sign-in of a shared config
+// profile, from AWS_CONFIG_FILE when it is set as the SDK does
+func loadSSOSession(ctx context.Context, profile string) (ssoSession, error) {
+	sc, err := config.LoadSharedConfigProfile(ctx, profile, func(o *config.LoadSharedConfigOptions) {
+		if file := os.Getenv("AWS_CONFIG_FILE"); file != "" {
+			o.ConfigFiles = []string{file}
+		}
+	})
+	if err != nil {
+		return ssoSession{}, fmt.Errorf("failed to load AWS profile %s: %w", profile, err)
+	}
+	var s ssoSession
+	if sc.SSOSession != nil {
+		s = ssoSession{Name: sc.SSOSessionName, StartURL: sc.SSOSession.SSOStartURL, Region: sc.SSOSession.SSORegion}
+	} else {
+		s = ssoSession{StartURL: sc.SSOStartURL, Region: sc.SSORegion}
+	}
+	if s.StartURL == "" || s.Region == "" {
+		return ssoSession{}, usageErrorf("AWS profile %s has no IAM Identity Center sign-in: set sso_session, or sso_start_url and sso_region", profile)
+	}
+	return s, nil
+}
+
+// ssoLogin signs in to IAM Identity Center with the OAuth device code flow
+// the way aws sso login does: it registers a public client, has the operator
+// confirm the device's code in the browser and polls until the token is
+// issued
+func ssoLogin(ctx context.Context, client ssoOIDCAPI, session ssoSession, open func(url string) error, w io.Writer) (*ssoToken, error) {
+	reg := &ssooidc.RegisterClientInput{
+		ClientName: aws.String(fmt.Sprintf("vss-%d", time.Now().Unix())),
+		ClientType: aws.String("public"),
+	}
+	if session.Name != "" {
+		// Refresh tokens, which keep a session signed in past the access
+		// token's hour, are only issued to sso-session profiles
+		reg.Scopes = []string{"sso:account:access"}
+	}
+	registration, err := client.RegisterClient(ctx, reg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register SSO client: %w", err)
+	}
+
+	auth, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     registration.ClientId,
+		ClientSecret: registration.ClientSecret,
+		StartUrl:     aws.String(session.StartURL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SSO device authorization: %w", err)
+	}
+	promptBrowser(w, aws.ToString(auth.VerificationUriComplete), aws.ToString(auth.UserCode), open)
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = ssoPollInterval
+	}
+	if auth.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(auth.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for the SSO login to complete: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		out, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     registration.ClientId,
+			ClientSecret: registration.ClientSecret,
+			GrantType:    aws.String(ssoDeviceGrant),
+			DeviceCode:   auth.DeviceCode,
+		})
+		var pending *types.AuthorizationPendingException
+		var slowDown *types.SlowDownException
+		switch {
+		case errors.As(err, &pending):
+			continue
+		case errors.As(err, &slowDown):
+			interval += 5 * time.Second
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to complete SSO login: %w", err)
+		}
+
+		token := &ssoToken{
+			AccessToken:  aws.ToString(out.AccessToken),
+			ExpiresAt:    time.Now().Add(time.Duration(out.ExpiresIn) * time.Second).UTC().Format(time.RFC3339),
+			RefreshToken: aws.ToString(out.RefreshToken),
+			Region:       session.Region,
+			StartURL:     session.StartURL,
+		}
+		if token.RefreshToken != "" {
+			token.ClientID = aws.ToString(registration.ClientId)
+			token.ClientSecret = aws.ToString(registration.ClientSecret)
+			token.RegistrationExpiresAt = time.Unix(registration.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339)
+		}
+		return token, nil
+	}
+}
+
+// writeSSOToken writes a token to the session's cache file, where the SDK's
+// SSO credential provider, and so every command run with the profile, finds
+// it
+func writeSSOToken(session ssoSession, token *ssoToken) (string, error) {
+	path, err := ssocreds.StandardCachedTokenFilepath(session.cacheKey())
+	if err != nil {
+		return "", fmt.Errorf("failed to locate SSO token cache: %w", err)
+	}
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode SSO token: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("failed to write SSO token cache: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write SSO token cache: %w", err)
+	}
+	return path, nil
+}
+
+// ssoLogout removes a session's cached token; a token that was never cached
+// is an error only when required
+func ssoLogout(w io.Writer, session ssoSession, desc string, required bool) error {
+	path, err := ssocreds.StandardCachedTokenFilepath(session.cacheKey())
+	if err != nil {
+		return fmt.Errorf("failed to locate SSO token cache: %w", err)
+	}
+	err = os.Remove(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !required:
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("no %s is cached", desc)
+	case err != nil:
+		return fmt.Errorf("failed to remove %s: %w", desc, err)
+	}
+	fmt.Fprintf(w, "Removed the cached %s\n", desc)
+	return nil
+}
+
+// runSSOLogin signs in the profile's IAM Identity Center session and caches
+// its token
+func runSSOLogin(ctx context.Context, open func(url string) error) error {
+	profile := awsProfile()
+	session, err := loadSSOSession(ctx, profile)
+	if err != nil {
+		return err
+	}
+	client := ssooidc.New(ssooidc.Options{Region: session.Region})
+	token, err := ssoLogin(ctx, client, session, open, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if _, err := writeSSOToken(session, token); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Signed in to %s; the AWS SSO token of profile %s expires at %s\n", session.StartURL, profile, token.ExpiresAt)
+	return nil
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidctypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	"github.com/hashicorp/vault/api"
+	"github.com/jbcom/secretsync/internal/keychain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memCredentialStore is a keychain.Store in memory
+type memCredentialStore map[string]string
+
+func (memCredentialStore) Name() string { return "test store" }
+
+func (s memCredentialStore) Get(account string) (string, error) {
+	if secret, ok := s[account]; ok {
+		return secret, nil
+	}
+	return "", keychain.ErrNotFound
+}
+
+func (s memCredentialStore) Set(account, secret string) error {
+	s[account] = secret
+	return nil
+}
+
+func (s memCredentialStore) Delete(account string) error {
+	if _, ok := s[account]; !ok {
+		return keychain.ErrNotFound
+	}
+	delete(s, account)
+	return nil
+}
+
+func TestLoginLogout(t *testing.T) {
+	store := memCredentialStore{}
+	oldStore, oldCfgFile := credentialStore, cfgFile
+	t.Cleanup(func() { credentialStore, cfgFile = oldStore, oldCfgFile })
+	credentialStore = store
+
+	path := writeCmdTestConfig(t, `
+vault:
+  address: https://vault.example.com/
+  auth:
+    token:
+      token: ${VSS_TEST_UNSET_VAULT_TOKEN}
+sources:
+  analytics:
+    doppler:
+      project: analytics
+      config: prd
+      token: ${VSS_TEST_UNSET_DOPPLER_TOKEN}
+merge_store:
+  vault:
+    mount: merged
+targets:
+  Stg:
+    account_id: "111111111111"
+    imports: [analytics]
+`)
+	cfgFile = path
+
+	account, desc, err := credentialAccount("vault")
+	require.NoError(t, err)
+	assert.Equal(t, "vault:https://vault.example.com", account)
+	assert.Equal(t, "Vault token for https://vault.example.com", desc)
+
+	var out bytes.Buffer
+	require.NoError(t, login(&out, store, account, desc, "hvs.stored"))
+	require.NoError(t, login(&out, store, "doppler", "Doppler token", "dp.st.stored"))
+	assert.Contains(t, out.String(), "Stored the Vault token for https://vault.example.com in the test store")
+
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hvs.stored", cfg.Vault.Auth.Token.Token)
+	assert.Equal(t, "dp.st.stored", cfg.Sources["analytics"].Doppler.Token)
+
+	// Set variables win over stored tokens
+	t.Setenv("VSS_TEST_UNSET_DOPPLER_TOKEN", "dp.st.env")
+	cfg, err = loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "dp.st.env", cfg.Sources["analytics"].Doppler.Token)
+
+	out.Reset()
+	require.NoError(t, logout(&out, store, account, desc, true))
+	assert.Equal(t, "Removed the Vault token for https://vault.example.com from the test store\n", out.String())
+	assert.EqualError(t, logout(&out, store, account, desc, true), "no Vault token for https://vault.example.com is stored in the test store")
+	assert.NoError(t, logout(&out, store, account, desc, false))
+
+	assert.Error(t, loginArgs(loginCmd, []string{"github"}))
+	assert.NoError(t, loginArgs(loginCmd, []string{"doppler"}))
+}
+
+func TestVaultOIDCLogin(t *testing.T) {
+	var nonce string
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/oidc-corp/oidc/auth_url":
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "operator", body["role"])
+			nonce = body["client_nonce"]
+			authURL := "https://idp.example.com/authorize?" + url.Values{
+				"redirect_uri": {body["redirect_uri"]},
+				"state":        {"st-1"},
+			}.Encode()
+			fmt.Fprintf(w, `{"data":{"auth_url":%q}}`, authURL)
+		case "/v1/auth/oidc-corp/oidc/callback":
+			q := r.URL.Query()
+			if q.Get("state") != "st-1" || q.Get("code") != "c-1" || q.Get("client_nonce") != nonce {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors":["invalid state"]}`)
+				return
+			}
+			fmt.Fprint(w, `{"auth":{"client_token":"hvs.oidc"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	vc := api.DefaultConfig()
+	vc.Address = vault.URL
+	client, err := api.NewClient(vc)
+	require.NoError(t, err)
+
+	// The browser signs in at the provider, which redirects to the callback
+	browser := func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		callback := u.Query().Get("redirect_uri") + "?" + url.Values{"state": {u.Query().Get("state")}, "code": {"c-1"}}.Encode()
+		go func() {
+			resp, err := http.Get(callback)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var out bytes.Buffer
+	token, err := vaultOIDCLogin(ctx, client, oidcLoginOptions{Mount: "oidc-corp/", Role: "operator", ListenAddress: "127.0.0.1:0"}, browser, &out)
+	require.NoError(t, err)
+	assert.Equal(t, "hvs.oidc", token)
+	assert.Contains(t, out.String(), "https://idp.example.com/authorize?")
+
+	// Without a browser the URL is printed and the login waits for it
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	out.Reset()
+	_, err = vaultOIDCLogin(ctx, client, oidcLoginOptions{Mount: "oidc-corp", Role: "operator", ListenAddress: "127.0.0.1:0"}, nil, &out)
+	assert.ErrorContains(t, err, "timed out waiting for the OIDC login")
+	assert.Contains(t, out.String(), "Visit this URL to sign in")
+}
+
+// fakeSSOOIDC is an IAM Identity Center OIDC API whose device authorization
+// is approved after a number of polls
+type fakeSSOOIDC struct {
+	pending  int
+	scopes   []string
+	startURL string
+}
+
+func (f *fakeSSOOIDC) RegisterClient(ctx context.Context, in *ssooidc.RegisterClientInput, _ ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error) {
+	f.scopes = in.Scopes
+	return &ssooidc.RegisterClientOutput{
+		ClientId:              aws.String("client-1"),
+		ClientSecret:          aws.String("client-secret-1"),
+		ClientSecretExpiresAt: time.Now().Add(90 * 24 * time.Hour).Unix(),
+	}, nil
+}
+
+func (f *fakeSSOOIDC) StartDeviceAuthorization(ctx context.Context, in *ssooidc.StartDeviceAuthorizationInput, _ ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error) {
+	f.startURL = aws.ToString(in.StartUrl)
+	return &ssooidc.StartDeviceAuthorizationOutput{
+		DeviceCode:              aws.String("device-1"),
+		UserCode:                aws.String("ABCD-EFGH"),
+		VerificationUriComplete: aws.String("https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH"),
+		ExpiresIn:               600,
+	}, nil
+}
+
+func (f *fakeSSOOIDC) CreateToken(ctx context.Context, in *ssooidc.CreateTokenInput, _ ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error) {
+	if aws.ToString(in.DeviceCode) != "device-1" || aws.ToString(in.GrantType) != ssoDeviceGrant {
+		return nil, &ssooidctypes.InvalidGrantException{}
+	}
+	if f.pending > 0 {
+		f.pending--
+		return nil, &ssooidctypes.AuthorizationPendingException{}
+	}
+	return &ssooidc.CreateTokenOutput{
+		AccessToken:  aws.String("sso-access"),
+		RefreshToken: aws.String("sso-refresh"),
+		ExpiresIn:    3600,
+	}, nil
+}
+
+func TestSSOLogin(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(home, "config"))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "config"), []byte(`
+[profile platform]
+sso_session = corp
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = eu-west-1
+
+[profile keys]
+region = us-east-1
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = us-east-1
+`), 0o600))
+	oldInterval := ssoPollInterval
+	t.Cleanup(func() { ssoPollInterval = oldInterval })
+	ssoPollInterval = time.Millisecond
+
+	ctx := context.Background()
+	session, err := loadSSOSession(ctx, "platform")
+	require.NoError(t, err)
+	assert.Equal(t, ssoSession{Name: "corp", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1"}, session)
+	legacy, err := loadSSOSession(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "https://legacy.awsapps.com/start", legacy.cacheKey())
+	_, err = loadSSOSession(ctx, "keys")
+	assert.ErrorContains(t, err, "has no IAM Identity Center sign-in")
+
+	fake := &fakeSSOOIDC{pending: 2}
+	var out bytes.Buffer
+	token, err := ssoLogin(ctx, fake, session, nil, &out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sso:account:access"}, fake.scopes)
+	assert.Equal(t, "https://corp.awsapps.com/start", fake.startURL)
+	assert.Zero(t, fake.pending)
+	assert.Contains(t, out.String(), "Confirm the code ABCD-EFGH")
+	assert.Equal(t, "client-1", token.ClientID)
+
+	// The SDK's SSO token provider reads the cached token
+	path, err := writeSSOToken(session, token)
+	require.NoError(t, err)
+	sdkPath, err := ssocreds.StandardCachedTokenFilepath("corp")
+	require.NoError(t, err)
+	assert.Equal(t, sdkPath, path)
+	bearer, err := ssocreds.NewSSOTokenProvider(fake, path).RetrieveBearerToken(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "sso-access", bearer.Value)
+
+	out.Reset()
+	require.NoError(t, ssoLogout(&out, session, "AWS SSO token of profile platform", true))
+	assert.Equal(t, "Removed the cached AWS SSO token of profile platform\n", out.String())
+	assert.NoFileExists(t, path)
+	assert.EqualError(t, ssoLogout(&out, session, "AWS SSO token of profile platform", true), "no AWS SSO token of profile platform is cached")
+	assert.NoError(t, ssoLogout(&out, session, "AWS SSO token of profile platform", false))
+}
@@ -12,13 +12,21 @@ import (
 )
 
 var (
-	migrateFrom     string
-	targetsFile     string
-	secretsFile     string
-	accountsFile    string
-	outputFile      string
-	vaultAddr       string
-	vaultMergeMount string
+	migrateFrom      string
+	targetsFile      string
+	secretsFile      string
+	accountsFile     string
+	outputFile       string
+	vaultAddr        string
+	vaultMergeMount  string
+	manifestsPath    string
+	migrateRegion    string
+	scanPrefix       string
+	scanDepth        int
+	scanTargetPrefix string
+	sourceMount      string
+	verifyMigration  bool
+	verifyDiff       bool
 )
 
 var migrateCmd = &cobra.Command{
@@ -28,23 +36,45 @@ var migrateCmd = &cobra.Command{
 
 Supported sources:
   - terraform-secretsmanager: Terraform-based AWS Secrets Manager pipeline
+  - external-secrets: External Secrets Operator manifests (SecretStore,
+    ClusterSecretStore, ExternalSecret, PushSecret)
+  - chamber: SSM Parameter Store /<service>/<key> layout (scans the account)
+  - asm-prefix: Secrets Manager <prefix>/<name> layout (scans the account)
 
-Example:
+Examples:
   vss migrate --from terraform-secretsmanager \
               --targets config/targets.yaml \
               --secrets config/secrets.yaml \
               --accounts config/accounts.yaml \
-              --output config.yaml`,
+              --output config.yaml
+
+  vss migrate --from external-secrets \
+              --manifests k8s/external-secrets/ \
+              --output config.yaml
+
+  # Verify the generated pipeline is zero-sum against the current layout
+  vss migrate --from terraform-secretsmanager ... --verify-diff
+
+  vss migrate --from chamber --region us-east-1 --output config.yaml
+  vss migrate --from asm-prefix --prefix apps/ --depth 2 --output config.yaml`,
 	RunE: runMigrate,
 }
 
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 
-	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Source format to migrate from (terraform-secretsmanager)")
+	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Source format to migrate from (terraform-secretsmanager, external-secrets, chamber, asm-prefix)")
 	migrateCmd.Flags().StringVar(&targetsFile, "targets", "", "Path to targets configuration file")
 	migrateCmd.Flags().StringVar(&secretsFile, "secrets", "", "Path to secrets configuration file")
 	migrateCmd.Flags().StringVar(&accountsFile, "accounts", "", "Path to accounts configuration file")
+	migrateCmd.Flags().StringVar(&manifestsPath, "manifests", "", "Path to ESO manifests file or directory (external-secrets)")
+	migrateCmd.Flags().StringVar(&migrateRegion, "region", "us-east-1", "AWS region to scan (chamber, asm-prefix)")
+	migrateCmd.Flags().StringVar(&scanPrefix, "prefix", "", "only scan names under this prefix (chamber, asm-prefix)")
+	migrateCmd.Flags().IntVar(&scanDepth, "depth", 1, "number of path segments that form a group (asm-prefix)")
+	migrateCmd.Flags().StringVar(&scanTargetPrefix, "target-prefix", "", "prefix for generated target names (chamber, asm-prefix)")
+	migrateCmd.Flags().StringVar(&sourceMount, "source-mount", "secret", "Vault mount for generated sources (chamber, asm-prefix)")
+	migrateCmd.Flags().BoolVar(&verifyMigration, "verify", false, "reload, validate and build the graph for the generated config")
+	migrateCmd.Flags().BoolVar(&verifyDiff, "verify-diff", false, "also run a dry-run diff and require zero-sum against current state (implies --verify)")
 	migrateCmd.Flags().StringVar(&outputFile, "output", "pipeline-config.yaml", "Output file path")
 	migrateCmd.Flags().StringVar(&vaultAddr, "vault-addr", "", "Vault address (or set VAULT_ADDR)")
 	migrateCmd.Flags().StringVar(&vaultMergeMount, "vault-merge-mount", "secret/merged", "Vault mount for merged secrets")
@@ -56,6 +86,10 @@ func runMigrate(cmd *cobra.Command, args []string) error {
 	switch migrateFrom {
 	case "terraform-secretsmanager":
 		return migrateTerraformSecretManager()
+	case "external-secrets":
+		return migrateExternalSecrets()
+	case "chamber", "asm-prefix":
+		return migrateScannedLayout(migrateFrom)
 	default:
 		return fmt.Errorf("unsupported migration source: %s", migrateFrom)
 	}
@@ -171,12 +205,17 @@ func migrateTerraformSecretManager() error {
 			mount = "secret"
 		}
 		sourceName := sanitizeSourceName(sec.Name)
-		cfg.Sources[sourceName] = pipeline.Source{
+		source := pipeline.Source{
 			Vault: &pipeline.VaultSource{
 				Mount: mount,
 				Paths: []string{sec.VaultPath},
 			},
 		}
+		// terraform transforms map key names to new names
+		if len(sec.Transforms) > 0 {
+			source.Transforms = &pipeline.KeyTransforms{Rename: sec.Transforms}
+		}
+		cfg.Sources[sourceName] = source
 	}
 
 	// Convert targets
@@ -206,13 +245,6 @@ func migrateTerraformSecretManager() error {
 		cfg.Targets[target.Name] = pipelineTarget
 	}
 
-	// Write output
-	data, err := yaml.Marshal(cfg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-
-	// Add header comment
 	header := `# Pipeline configuration migrated from terraform-aws-secretsmanager
 # Generated by: vss migrate --from terraform-secretsmanager
 #
@@ -220,9 +252,19 @@ func migrateTerraformSecretManager() error {
 # - Verify Vault address and authentication
 # - Check source paths and mounts
 # - Validate target account IDs and regions
-# - Add any missing transforms or filters
+# - Check key renames carried over as source transforms
+# - Add any missing filters
 
 `
+	return writeMigratedConfig(cfg, header)
+}
+
+// writeMigratedConfig writes a migrated config with a review header and prints next steps
+func writeMigratedConfig(cfg *pipeline.Config, header string) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
 
 	if err := os.WriteFile(outputFile, []byte(header+string(data)), 0600); err != nil {
 		return fmt.Errorf("failed to write output: %w", err)
@@ -239,6 +281,9 @@ func migrateTerraformSecretManager() error {
 	fmt.Println("   3. Validate: vss validate --config " + outputFile)
 	fmt.Println("   4. Dry run: vss pipeline --config " + outputFile + " --dry-run")
 
+	if verifyMigration || verifyDiff {
+		return verifyMigratedConfig(cfg)
+	}
 	return nil
 }
 
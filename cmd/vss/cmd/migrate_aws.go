@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+)
+
+// migrateScannedLayout scans an existing AWS naming layout and generates sources and targets.
+//
+// chamber:    SSM parameters laid out as /<service>/<key>; each service becomes a source
+// asm-prefix: Secrets Manager names laid out as <prefix>/.../<name>; each prefix (up to
+// --depth segments) becomes a source
+//
+// Each group also becomes a target in the scanned account whose secret_prefix preserves
+// the existing naming, so synced secrets land where consumers already read them.
+func migrateScannedLayout(layout string) error {
+	ctx := context.Background()
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(migrateRegion))
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	accountID := aws.ToString(identity.Account)
+
+	var names []string
+	switch layout {
+	case "chamber":
+		names, err = scanSSMParameterNames(ctx, ssm.NewFromConfig(awsCfg), scanPrefix)
+	case "asm-prefix":
+		names, err = scanSecretsManagerNames(ctx, secretsmanager.NewFromConfig(awsCfg), scanPrefix)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to scan %s layout: %w", layout, err)
+	}
+
+	depth := scanDepth
+	if layout == "chamber" {
+		depth = 1
+	}
+	groups := groupNamesByPrefix(names, depth)
+	if len(groups) == 0 {
+		return fmt.Errorf("no secrets found matching the %s layout under %q", layout, scanPrefix)
+	}
+
+	cfg := buildScannedLayoutConfig(groups, accountID, layout)
+
+	header := fmt.Sprintf(`# Pipeline configuration migrated from an existing %s layout
+# Generated by: vss migrate --from %s
+#
+# Scanned account %s in %s (%d secrets, %d groups).
+# Each group becomes a Vault source at %s/<group> and a target that keeps
+# the existing naming via secret_prefix.
+#
+# Review and adjust as needed:
+# - Copy the existing values into the Vault source paths before the first run
+# - Verify Vault address and authentication
+# - Collapse per-group targets into fewer targets if preferred
+
+`, layout, layout, accountID, migrateRegion, len(names), len(groups), sourceMount)
+	return writeMigratedConfig(cfg, header)
+}
+
+// buildScannedLayoutConfig converts prefix groups into sources and targets
+func buildScannedLayoutConfig(groups map[string][]string, accountID, layout string) *pipeline.Config {
+	cfg := &pipeline.Config{
+		Vault: pipeline.VaultConfig{
+			Address: getVaultAddr(),
+		},
+		MergeStore: pipeline.MergeStoreConfig{
+			Vault: &pipeline.MergeStoreVault{
+				Mount: vaultMergeMount,
+			},
+		},
+		Sources: make(map[string]pipeline.Source),
+		Targets: make(map[string]pipeline.Target),
+		AWS: pipeline.AWSConfig{
+			Region: migrateRegion,
+		},
+	}
+
+	for group := range groups {
+		sourceName := sanitizeSourceName(group)
+		cfg.Sources[sourceName] = pipeline.Source{
+			Vault: &pipeline.VaultSource{
+				Mount: sourceMount,
+				Paths: []string{group},
+			},
+		}
+
+		// chamber keys live under a leading slash; Secrets Manager names do not
+		prefix := group + "/"
+		if layout == "chamber" {
+			prefix = "/" + prefix
+		}
+		cfg.Targets[scanTargetName(group)] = pipeline.Target{
+			AccountID:    accountID,
+			Region:       migrateRegion,
+			SecretPrefix: prefix,
+			Imports:      []string{sourceName},
+		}
+	}
+	return cfg
+}
+
+// groupNamesByPrefix groups names by their first depth path segments.
+// Names with no more segments than depth are grouped under their parent path.
+func groupNamesByPrefix(names []string, depth int) map[string][]string {
+	if depth < 1 {
+		depth = 1
+	}
+	groups := make(map[string][]string)
+	for _, name := range names {
+		parts := strings.Split(strings.Trim(name, "/"), "/")
+		if len(parts) < 2 {
+			// Top-level names have no prefix to preserve
+			continue
+		}
+		n := depth
+		if n > len(parts)-1 {
+			n = len(parts) - 1
+		}
+		group := strings.Join(parts[:n], "/")
+		groups[group] = append(groups[group], name)
+	}
+	for _, members := range groups {
+		sort.Strings(members)
+	}
+	return groups
+}
+
+// scanTargetName derives a target name from a prefix group
+func scanTargetName(group string) string {
+	if scanTargetPrefix != "" {
+		return scanTargetPrefix + "_" + sanitizeSourceName(group)
+	}
+	return sanitizeSourceName(group)
+}
+
+func scanSSMParameterNames(ctx context.Context, client *ssm.Client, prefix string) ([]string, error) {
+	if prefix == "" {
+		prefix = "/"
+	}
+	var names []string
+	paginator := ssm.NewGetParametersByPathPaginator(client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(prefix),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(false),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Parameters {
+			names = append(names, aws.ToString(p.Name))
+		}
+	}
+	return names, nil
+}
+
+func scanSecretsManagerNames(ctx context.Context, client *secretsmanager.Client, prefix string) ([]string, error) {
+	var names []string
+	paginator := secretsmanager.NewListSecretsPaginator(client, &secretsmanager.ListSecretsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range page.SecretList {
+			name := aws.ToString(s.Name)
+			if prefix != "" && !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "Warning: no Secrets Manager secrets found with prefix %q\n", prefix)
+	}
+	return names, nil
+}
@@ -0,0 +1,351 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// ESOManifest is the subset of External Secrets Operator resources needed for migration.
+// It covers SecretStore, ClusterSecretStore, ExternalSecret and PushSecret.
+type ESOManifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec ESOSpec `yaml:"spec"`
+}
+
+// ESOSpec merges the spec fields of the supported ESO kinds
+type ESOSpec struct {
+	// SecretStore / ClusterSecretStore
+	Provider *ESOProvider `yaml:"provider"`
+
+	// ExternalSecret
+	SecretStoreRef ESOStoreRef `yaml:"secretStoreRef"`
+	Target         struct {
+		Name string `yaml:"name"`
+	} `yaml:"target"`
+	Data []struct {
+		SecretKey string       `yaml:"secretKey"`
+		RemoteRef ESORemoteRef `yaml:"remoteRef"`
+	} `yaml:"data"`
+	DataFrom []struct {
+		Extract *ESORemoteRef `yaml:"extract"`
+	} `yaml:"dataFrom"`
+
+	// PushSecret
+	SecretStoreRefs []ESOStoreRef `yaml:"secretStoreRefs"`
+	Selector        struct {
+		Secret struct {
+			Name string `yaml:"name"`
+		} `yaml:"secret"`
+	} `yaml:"selector"`
+}
+
+// ESOStoreRef references a SecretStore or ClusterSecretStore
+type ESOStoreRef struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+}
+
+// ESORemoteRef references a key in the provider
+type ESORemoteRef struct {
+	Key      string `yaml:"key"`
+	Property string `yaml:"property"`
+}
+
+// ESOProvider holds the provider configurations vss can map
+type ESOProvider struct {
+	Vault *struct {
+		Server    string `yaml:"server"`
+		Path      string `yaml:"path"`
+		Version   string `yaml:"version"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"vault"`
+	AWS *struct {
+		Service string `yaml:"service"`
+		Region  string `yaml:"region"`
+		Role    string `yaml:"role"`
+	} `yaml:"aws"`
+}
+
+var roleARNAccountPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::(\d{12}):role/`)
+
+func migrateExternalSecrets() error {
+	if manifestsPath == "" {
+		return fmt.Errorf("--manifests is required for external-secrets migration")
+	}
+
+	manifests, err := loadESOManifests(manifestsPath)
+	if err != nil {
+		return fmt.Errorf("failed to load manifests: %w", err)
+	}
+
+	var accounts *TerraformAccountsFile
+	if accountsFile != "" {
+		accounts, err = loadTerraformAccounts(accountsFile)
+		if err != nil {
+			return fmt.Errorf("failed to load accounts: %w", err)
+		}
+	}
+
+	cfg, warnings := convertESOManifests(manifests, accounts)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	header := `# Pipeline configuration migrated from External Secrets Operator manifests
+# Generated by: vss migrate --from external-secrets
+#
+# Mapping:
+# - ExternalSecrets reading from Vault stores become sources
+# - PushSecrets writing to AWS Secrets Manager stores become targets
+#
+# Review and adjust as needed:
+# - Verify Vault address and authentication
+# - Check source paths and mounts
+# - Validate target account IDs and regions
+
+`
+	return writeMigratedConfig(cfg, header)
+}
+
+// convertESOManifests maps ESO resources onto a pipeline config.
+// ExternalSecrets backed by Vault stores become sources (one per ExternalSecret), and
+// PushSecrets targeting AWS Secrets Manager stores become targets importing the sources
+// that produce the pushed Kubernetes secret.
+func convertESOManifests(manifests []ESOManifest, accounts *TerraformAccountsFile) (*pipeline.Config, []string) {
+	var warnings []string
+
+	cfg := &pipeline.Config{
+		MergeStore: pipeline.MergeStoreConfig{
+			Vault: &pipeline.MergeStoreVault{
+				Mount: vaultMergeMount,
+			},
+		},
+		Sources: make(map[string]pipeline.Source),
+		Targets: make(map[string]pipeline.Target),
+		AWS: pipeline.AWSConfig{
+			Region: "us-east-1",
+		},
+	}
+
+	accountMap := make(map[string]TerraformAccount)
+	if accounts != nil {
+		for _, acc := range accounts.Accounts {
+			accountMap[acc.Name] = acc
+		}
+	}
+
+	// Index stores by kind/namespace/name; ClusterSecretStores are cluster-scoped
+	stores := make(map[string]ESOManifest)
+	for _, m := range manifests {
+		switch m.Kind {
+		case "SecretStore":
+			stores[esoStoreKey("SecretStore", m.Metadata.Namespace, m.Metadata.Name)] = m
+		case "ClusterSecretStore":
+			stores[esoStoreKey("ClusterSecretStore", "", m.Metadata.Name)] = m
+		}
+	}
+	lookupStore := func(namespace string, ref ESOStoreRef) (ESOManifest, bool) {
+		if ref.Kind == "ClusterSecretStore" {
+			s, ok := stores[esoStoreKey("ClusterSecretStore", "", ref.Name)]
+			return s, ok
+		}
+		s, ok := stores[esoStoreKey("SecretStore", namespace, ref.Name)]
+		return s, ok
+	}
+
+	// ExternalSecrets -> sources, keyed by the Kubernetes secret they produce
+	producedBy := make(map[string][]string)
+	for _, m := range manifests {
+		if m.Kind != "ExternalSecret" {
+			continue
+		}
+		store, ok := lookupStore(m.Metadata.Namespace, m.Spec.SecretStoreRef)
+		if !ok || store.Spec.Provider == nil || store.Spec.Provider.Vault == nil {
+			warnings = append(warnings, fmt.Sprintf("ExternalSecret %s/%s: store %q is not a Vault store, skipping",
+				m.Metadata.Namespace, m.Metadata.Name, m.Spec.SecretStoreRef.Name))
+			continue
+		}
+		vault := store.Spec.Provider.Vault
+		if cfg.Vault.Address == "" {
+			cfg.Vault.Address = vault.Server
+			cfg.Vault.Namespace = vault.Namespace
+		}
+
+		var paths []string
+		for _, d := range m.Spec.Data {
+			paths = appendUnique(paths, d.RemoteRef.Key)
+		}
+		for _, d := range m.Spec.DataFrom {
+			if d.Extract != nil {
+				paths = appendUnique(paths, d.Extract.Key)
+			}
+		}
+		if len(paths) == 0 {
+			warnings = append(warnings, fmt.Sprintf("ExternalSecret %s/%s: no remote keys found, skipping",
+				m.Metadata.Namespace, m.Metadata.Name))
+			continue
+		}
+		sort.Strings(paths)
+
+		mount := vault.Path
+		if mount == "" {
+			mount = "secret"
+		}
+		sourceName := sanitizeSourceName(esoQualifiedName(m.Metadata.Namespace, m.Metadata.Name))
+		src := pipeline.Source{
+			Vault: &pipeline.VaultSource{
+				Mount: mount,
+				Paths: paths,
+			},
+		}
+		if vault.Server != cfg.Vault.Address {
+			src.Vault.Address = vault.Server
+		}
+		if vault.Namespace != cfg.Vault.Namespace {
+			src.Vault.Namespace = vault.Namespace
+		}
+		cfg.Sources[sourceName] = src
+
+		secretName := m.Spec.Target.Name
+		if secretName == "" {
+			secretName = m.Metadata.Name
+		}
+		key := esoQualifiedName(m.Metadata.Namespace, secretName)
+		producedBy[key] = appendUnique(producedBy[key], sourceName)
+	}
+
+	// PushSecrets -> targets
+	for _, m := range manifests {
+		if m.Kind != "PushSecret" {
+			continue
+		}
+		imports := producedBy[esoQualifiedName(m.Metadata.Namespace, m.Spec.Selector.Secret.Name)]
+		if len(imports) == 0 {
+			warnings = append(warnings, fmt.Sprintf("PushSecret %s/%s: secret %q is not produced by a migrated ExternalSecret, skipping",
+				m.Metadata.Namespace, m.Metadata.Name, m.Spec.Selector.Secret.Name))
+			continue
+		}
+		for _, ref := range m.Spec.SecretStoreRefs {
+			store, ok := lookupStore(m.Metadata.Namespace, ref)
+			if !ok || store.Spec.Provider == nil || store.Spec.Provider.AWS == nil ||
+				(store.Spec.Provider.AWS.Service != "" && store.Spec.Provider.AWS.Service != "SecretsManager") {
+				warnings = append(warnings, fmt.Sprintf("PushSecret %s/%s: store %q is not an AWS Secrets Manager store, skipping",
+					m.Metadata.Namespace, m.Metadata.Name, ref.Name))
+				continue
+			}
+			aws := store.Spec.Provider.AWS
+
+			targetName := ref.Name
+			target := cfg.Targets[targetName]
+			target.Region = aws.Region
+			target.RoleARN = aws.Role
+			if acc, ok := accountMap[targetName]; ok {
+				target.AccountID = acc.AccountID
+				if acc.Region != "" {
+					target.Region = acc.Region
+				}
+				if acc.RoleARN != "" {
+					target.RoleARN = acc.RoleARN
+				}
+			} else if match := roleARNAccountPattern.FindStringSubmatch(aws.Role); match != nil {
+				target.AccountID = match[1]
+			}
+			if target.AccountID == "" {
+				warnings = append(warnings, fmt.Sprintf("store %q: cannot determine account ID (set spec.provider.aws.role or use --accounts), skipping", ref.Name))
+				continue
+			}
+			for _, imp := range imports {
+				target.Imports = appendUnique(target.Imports, imp)
+			}
+			sort.Strings(target.Imports)
+			cfg.Targets[targetName] = target
+		}
+	}
+
+	if cfg.Vault.Address == "" {
+		cfg.Vault.Address = getVaultAddr()
+	}
+	return cfg, warnings
+}
+
+// loadESOManifests reads multi-document YAML manifests from a file or directory
+func loadESOManifests(path string) ([]ESOManifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (strings.HasSuffix(p, ".yaml") || strings.HasSuffix(p, ".yml")) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var manifests []ESOManifest
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var m ESOManifest
+			if err := dec.Decode(&m); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+			if strings.HasPrefix(m.APIVersion, "external-secrets.io/") {
+				manifests = append(manifests, m)
+			}
+		}
+	}
+	return manifests, nil
+}
+
+func esoStoreKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+func esoQualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, existing := range list {
+		if existing == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"gopkg.in/yaml.v3"
+)
+
+// verifyMigratedConfig checks that a generated config survives a round trip through
+// the config loader, validates, builds a dependency graph and, when requested,
+// produces a zero-sum dry-run diff against the current state of the target accounts.
+func verifyMigratedConfig(generated *pipeline.Config) error {
+	fmt.Println()
+	fmt.Println("Verifying generated config...")
+
+	// Generated configs must only contain keys vss understands
+	loaded, err := pipeline.LoadConfigWithOptions(outputFile, pipeline.LoadOptions{Strict: true})
+	if err != nil {
+		fmt.Printf("   ❌ Reload: %v\n", err)
+		return fmt.Errorf("generated config cannot be loaded: %w", err)
+	}
+	if !sameYAML(generated.Sources, loaded.Sources) || !sameYAML(generated.Targets, loaded.Targets) {
+		fmt.Println("   ❌ Round trip: sources or targets changed when reloaded")
+		return fmt.Errorf("generated config does not round-trip")
+	}
+	fmt.Println("   ✅ Round trip")
+
+	if err := loaded.Validate(); err != nil {
+		fmt.Printf("   ❌ Validate: %v\n", err)
+		return fmt.Errorf("generated config is invalid: %w", err)
+	}
+	fmt.Println("   ✅ Validate")
+
+	graph, err := pipeline.BuildGraph(loaded)
+	if err != nil {
+		fmt.Printf("   ❌ Graph: %v\n", err)
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
+	fmt.Printf("   ✅ Graph (%d levels)\n", len(graph.GroupByLevel()))
+
+	if !verifyDiff {
+		return nil
+	}
+
+	p, err := pipeline.New(loaded)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+	opts := pipeline.DefaultOptions()
+	opts.DryRun = true
+	opts.ComputeDiff = true
+	if _, err := p.Run(context.Background(), opts); err != nil {
+		fmt.Printf("   ❌ Dry run: %v\n", err)
+		return fmt.Errorf("dry run failed: %w", err)
+	}
+	if p.ExitCode() == diff.ExitCodeError {
+		fmt.Println("   ❌ Dry run: one or more targets failed")
+		return fmt.Errorf("dry run completed with errors")
+	}
+	return checkZeroSum(loaded.Targets, p.Results(), p.Diff())
+}
+
+// checkZeroSum passes a dry run whose diff covers every target and destination
+// and has no changes. A destination the dry run could not diff is a failure:
+// nothing shows that it matches.
+func checkZeroSum(targets map[string]pipeline.Target, results []pipeline.Result, d *diff.PipelineDiff) error {
+	if missing := undiffed(targets, results); d == nil || len(missing) > 0 {
+		fmt.Printf("   ❌ Zero-sum: no diff for %s\n", strings.Join(missing, ", "))
+		return fmt.Errorf("dry run produced no diff for %d targets or destinations", len(missing))
+	}
+	if d.IsZeroSum() {
+		fmt.Println("   ✅ Zero-sum: generated pipeline matches the current state")
+		return nil
+	}
+	fmt.Println(diff.FormatDiff(d, diff.OutputFormatHuman))
+	fmt.Printf("   ❌ Zero-sum: %d added, %d removed, %d modified\n", d.Summary.Added, d.Summary.Removed, d.Summary.Modified)
+	// Not an execution error: the dry run worked and found changes (exit 1)
+	return &exitError{code: diff.ExitCodeChanges, err: fmt.Errorf("generated pipeline is not zero-sum equivalent to the current state")}
+}
+
+// undiffed lists the targets, and destinations of multi-destination targets,
+// that the dry run's sync phase produced no diff for
+func undiffed(targets map[string]pipeline.Target, results []pipeline.Result) []string {
+	synced := make(map[string]pipeline.Result)
+	for _, r := range results {
+		if r.Phase == "sync" {
+			synced[r.Target] = r
+		}
+	}
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var missing []string
+	for _, name := range names {
+		r, ok := synced[name]
+		if !ok || r.Diff == nil {
+			missing = append(missing, name)
+			continue
+		}
+		for _, dr := range r.Details.Destinations {
+			if !dr.Diffed {
+				missing = append(missing, fmt.Sprintf("%s (%s)", name, dr.Name))
+			}
+		}
+	}
+	return missing
+}
+
+// sameYAML compares values by their YAML encoding so nil and empty collections are equal
+func sameYAML(a, b any) bool {
+	ya, errA := yaml.Marshal(a)
+	yb, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ya, yb)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckZeroSum(t *testing.T) {
+	targets := map[string]pipeline.Target{
+		"Stg":  {AccountID: "111111111111"},
+		"Prod": {AccountID: "222222222222"},
+	}
+	unchanged := diff.TargetDiff{Target: "Stg", Summary: diff.ChangeSummary{Unchanged: 2, Total: 2}}
+	modified := diff.TargetDiff{
+		Target:  "Prod",
+		Changes: []diff.SecretChange{{Path: "db", ChangeType: diff.ChangeTypeModified}},
+		Summary: diff.ChangeSummary{Modified: 1, Total: 1},
+	}
+	pipelineDiff := func(tds ...diff.TargetDiff) *diff.PipelineDiff {
+		d := &diff.PipelineDiff{DryRun: true}
+		for _, td := range tds {
+			d.AddTargetDiff(td)
+		}
+		return d
+	}
+
+	// Every target diffed, nothing changed
+	results := []pipeline.Result{
+		{Target: "Stg", Phase: "sync", Diff: &unchanged},
+		{Target: "Prod", Phase: "sync", Diff: &diff.TargetDiff{Target: "Prod"}},
+	}
+	assert.NoError(t, checkZeroSum(targets, results, pipelineDiff(unchanged)))
+
+	// The migrated config would change a destination
+	results[1].Diff = &modified
+	err := checkZeroSum(targets, results, pipelineDiff(unchanged, modified))
+	assert.Error(t, err)
+	assert.Equal(t, diff.ExitCodeChanges, exitCode(err))
+
+	// A target without a diff cannot be shown to match
+	err = checkZeroSum(targets, results[:1], pipelineDiff(unchanged))
+	assert.ErrorContains(t, err, "no diff for 1 targets or destinations")
+	assert.Equal(t, diff.ExitCodeError, exitCode(err))
+
+	// Nor can a destination that was not diffed
+	results[1] = pipeline.Result{Target: "Prod", Phase: "sync", Diff: &diff.TargetDiff{Target: "Prod"}, Details: pipeline.ResultDetails{
+		Destinations: []pipeline.DestinationResult{{Name: "aws:222222222222", Success: true, Diffed: true}, {Name: "k8s:prod/app", Success: true}},
+	}}
+	assert.Equal(t, []string{"Prod (k8s:prod/app)"}, undiffed(targets, results))
+	assert.Error(t, checkZeroSum(targets, results, pipelineDiff(unchanged)))
+}
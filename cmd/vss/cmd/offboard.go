@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	offboardTarget         string
+	offboardReason         string
+	offboardQuarantine     bool
+	offboardRecoveryWindow int
+	offboardDryRun         bool
+	offboardYes            bool
+	offboardOutput         string
+)
+
+var offboardCmd = &cobra.Command{
+	Use:   "offboard",
+	Short: "Remove a target's secrets from its destinations and the merge store",
+	Long: `Offboards a target that is being retired:
+
+  1. the secrets vss synced to its Secrets Manager destinations are scheduled
+     for deletion (restorable for --recovery-window days), or with
+     --quarantine tagged vss:quarantined and left in place
+  2. its merged secrets are deleted from the merge store
+  3. its sync configs are removed from the sync engine
+
+Destination secrets are those named after the target's merged secrets, plus
+any under the target's own name prefix (transforms.name). The plan is shown
+first and must be approved interactively unless --yes is given. Targets that
+other targets import cannot be offboarded.
+
+The offboarding is written to the audit log with the justification and the
+operator (GITHUB_ACTOR or the local user), and hooks on the offboard event are
+called with the same record before anything is removed. A failing required
+offboard hook refuses the offboarding. If a destination secret cannot be
+removed, the merged secrets are kept so the offboarding can be run again.
+
+GitHub, Doppler, Kubernetes, gRPC and envelope destinations are listed but
+must be cleaned up by hand. Remove the target from the config afterwards.
+
+Examples:
+  vss offboard --config config.yaml --target Sandbox_Old --reason "account closed" --dry-run
+  vss offboard --config config.yaml --target Sandbox_Old --reason "account closed" --quarantine --yes`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if strings.TrimSpace(offboardReason) == "" {
+			return usageErrorf("--reason must not be empty")
+		}
+		if offboardOutput != "human" && offboardOutput != "json" {
+			return usageErrorf("--output must be human or json, got %q", offboardOutput)
+		}
+		if cmd.Flags().Changed("recovery-window") && offboardQuarantine {
+			return usageErrorf("--recovery-window cannot be used with --quarantine")
+		}
+		return nil
+	},
+	RunE: runOffboard,
+}
+
+func init() {
+	rootCmd.AddCommand(offboardCmd)
+
+	offboardCmd.Flags().StringVar(&offboardTarget, "target", "", "target to offboard")
+	offboardCmd.Flags().StringVar(&offboardReason, "reason", "", "justification recorded in the audit log and notifications")
+	offboardCmd.Flags().BoolVar(&offboardQuarantine, "quarantine", false, "tag destination secrets instead of deleting them")
+	offboardCmd.Flags().IntVar(&offboardRecoveryWindow, "recovery-window", 30, "days deleted secrets can be restored (7-30)")
+	offboardCmd.Flags().BoolVar(&offboardDryRun, "dry-run", false, "show the plan without removing anything")
+	offboardCmd.Flags().BoolVarP(&offboardYes, "yes", "y", false, "skip the approval prompt")
+	offboardCmd.Flags().StringVarP(&offboardOutput, "output", "o", "human", "output format: human, json")
+	offboardCmd.MarkFlagRequired("target")
+	offboardCmd.MarkFlagRequired("reason")
+}
+
+func runOffboard(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	plan, err := p.PlanOffboard(ctx, offboardTarget)
+	if err != nil {
+		return fmt.Errorf("failed to plan offboarding: %w", err)
+	}
+	if offboardDryRun {
+		return printOffboard(os.Stdout, plan)
+	}
+	// JSON output is the record, so the plan goes to stderr for the prompt
+	planOut := io.Writer(os.Stdout)
+	if offboardOutput == "json" {
+		planOut = os.Stderr
+	}
+	printOffboardPlan(planOut, plan)
+
+	if !offboardYes {
+		ok, err := confirm(fmt.Sprintf("Offboard %s?", offboardTarget))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("offboarding cancelled")
+		}
+	}
+
+	rec, err := p.Offboard(ctx, plan, pipeline.OffboardOptions{
+		Quarantine:         offboardQuarantine,
+		RecoveryWindowDays: offboardRecoveryWindow,
+		Reason:             offboardReason,
+		Operator:           currentOperator(),
+	})
+	if rec == nil {
+		return err
+	}
+	if offboardOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(rec); encErr != nil {
+			return encErr
+		}
+	} else if len(rec.Errors) > 0 {
+		fmt.Println("\nFailed:")
+		for _, e := range rec.Errors {
+			fmt.Printf("   %s\n", e)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if offboardOutput == "human" {
+		fmt.Printf("\n✅ Offboarded %s; remove it from %s\n", offboardTarget, cfgFile)
+	}
+	return nil
+}
+
+// printOffboard prints a plan in the --output format
+func printOffboard(w io.Writer, plan *pipeline.OffboardPlan) error {
+	if offboardOutput == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+	printOffboardPlan(w, plan)
+	return nil
+}
+
+func printOffboardPlan(w io.Writer, plan *pipeline.OffboardPlan) {
+	action := fmt.Sprintf("Delete (restorable for %d days)", offboardRecoveryWindow)
+	if offboardQuarantine {
+		action = "Quarantine"
+	}
+	fmt.Fprintf(w, "Offboarding %s\n", plan.Target)
+	for _, d := range plan.Destinations {
+		fmt.Fprintf(w, "\n%s %d secrets in %s:\n", action, len(d.Secrets), d.Destination)
+		for _, s := range d.Secrets {
+			fmt.Fprintf(w, "   %s\n", s)
+		}
+	}
+	fmt.Fprintf(w, "\nDelete %d merged secrets from the merge store\n", len(plan.MergedSecrets))
+	if len(plan.SyncConfigs) > 0 {
+		fmt.Fprintf(w, "Remove sync configs: %s\n", strings.Join(plan.SyncConfigs, ", "))
+	}
+	if len(plan.Unsupported) > 0 {
+		fmt.Fprintf(w, "\n⚠️  Clean up by hand: %s\n", strings.Join(plan.Unsupported, ", "))
+	}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/internal/configpr"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	onboardAccount string
+	onboardName    string
+	onboardImports []string
+	onboardRegion  string
+	onboardOutput  string
+	onboardOpenPR  bool
+	onboardRepo    string
+	onboardBase    string
+	onboardPath    string
+)
+
+var onboardCmd = &cobra.Command{
+	Use:   "onboard",
+	Short: "Check that an account is ready to become a target",
+	Long: `Checks an AWS account before it is added as a target and prints a checklist:
+
+  - the account is not already a target
+  - vss can assume the account's execution role (role_arn pattern or Control
+    Tower execution role) and it lands in the right account
+  - Secrets Manager has room under its 500,000 secrets quota for the secrets
+    already there plus those inherited from imported targets
+  - the Vault token can list and read each imported Vault source and, with a
+    Vault merge store, write the new target's merged secrets
+
+Each failed check says how to fix it. The target's config entry is printed
+for copying into the config. With --open-pr, a pull request adding it to the
+config file in a GitHub repository is opened once every check passes;
+GITHUB_TOKEN must be set, and GITHUB_API_URL selects a GitHub Enterprise Server.
+
+Exit codes: 0 ready, 2 a check failed.
+
+Examples:
+  vss onboard --config config.yaml --account 123456789012 --import analytics --import Serverless_Stg
+  vss onboard --config config.yaml --account 123456789012 --name Sandbox_Data --import analytics \
+    --open-pr --repo acme/infra --path vss/config.yaml`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if onboardOutput != "human" && onboardOutput != "json" {
+			return usageErrorf("--output must be human or json, got %q", onboardOutput)
+		}
+		if onboardOpenPR && onboardRepo == "" {
+			return usageErrorf("--open-pr requires --repo (or GITHUB_REPOSITORY)")
+		}
+		return nil
+	},
+	RunE: runOnboard,
+}
+
+func init() {
+	rootCmd.AddCommand(onboardCmd)
+
+	onboardCmd.Flags().StringVar(&onboardAccount, "account", "", "AWS account ID to onboard")
+	onboardCmd.Flags().StringVar(&onboardName, "name", "", "target name (default: Account_<account>)")
+	onboardCmd.Flags().StringSliceVar(&onboardImports, "import", nil, "source or target the new target imports (repeatable)")
+	onboardCmd.Flags().StringVar(&onboardRegion, "region", "", "region of the target's Secrets Manager (default: aws.region)")
+	onboardCmd.Flags().StringVarP(&onboardOutput, "output", "o", "human", "output format: human, json")
+	onboardCmd.Flags().BoolVar(&onboardOpenPR, "open-pr", false, "open a pull request adding the target to the config")
+	onboardCmd.Flags().StringVar(&onboardRepo, "repo", os.Getenv("GITHUB_REPOSITORY"), "GitHub repository holding the config, as owner/name")
+	onboardCmd.Flags().StringVar(&onboardBase, "base", "", "branch the pull request merges into (default: the repository's default branch)")
+	onboardCmd.Flags().StringVar(&onboardPath, "path", "", "config file's path in the repository (default: --config)")
+	onboardCmd.MarkFlagRequired("account")
+}
+
+func runOnboard(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	report, err := p.Onboard(ctx, pipeline.OnboardOptions{
+		AccountID: onboardAccount,
+		Name:      onboardName,
+		Imports:   onboardImports,
+		Region:    onboardRegion,
+	})
+	if err != nil {
+		return err
+	}
+
+	var prURL string
+	if onboardOpenPR && report.Ready() {
+		if prURL, err = openOnboardPR(ctx, report); err != nil {
+			return err
+		}
+	}
+
+	if onboardOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			*pipeline.OnboardReport
+			Ready       bool   `json:"ready"`
+			PullRequest string `json:"pull_request,omitempty"`
+		}{report, report.Ready(), prURL}); err != nil {
+			return err
+		}
+	} else {
+		printOnboardReport(os.Stdout, report)
+		if prURL != "" {
+			fmt.Printf("\nOpened %s\n", prURL)
+		}
+	}
+
+	if !report.Ready() {
+		return fmt.Errorf("%s is not ready to onboard", report.AccountID)
+	}
+	return nil
+}
+
+func openOnboardPR(ctx context.Context, report *pipeline.OnboardReport) (string, error) {
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "https://api.github.com" {
+		apiURL = ""
+	}
+	client, err := configpr.NewClient(os.Getenv("GITHUB_TOKEN"), apiURL)
+	if err != nil {
+		return "", err
+	}
+	path := onboardPath
+	if path == "" {
+		path = cfgFile
+	}
+	return client.Open(ctx, configpr.Request{
+		Repo:   onboardRepo,
+		Base:   onboardBase,
+		Branch: "vss/onboard-" + report.AccountID,
+		Path:   path,
+		Title:  fmt.Sprintf("Onboard %s (%s)", report.Target, report.AccountID),
+		Body:   onboardPRBody(report),
+		Edit: func(content []byte) ([]byte, error) {
+			return pipeline.AddTargetToConfig(content, report.Target, report.Config)
+		},
+	})
+}
+
+func onboardPRBody(report *pipeline.OnboardReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Adds account %s as target `%s`. Checked by `vss onboard`:\n\n", report.AccountID, report.Target)
+	for _, c := range report.Checks {
+		fmt.Fprintf(&b, "- %s %s", checkIcon(c.Status), c.Name)
+		if c.Detail != "" {
+			fmt.Fprintf(&b, ": %s", c.Detail)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func checkIcon(s pipeline.CheckStatus) string {
+	switch s {
+	case pipeline.CheckPass:
+		return "✅"
+	case pipeline.CheckWarn:
+		return "⚠️"
+	case pipeline.CheckFail:
+		return "❌"
+	default:
+		return "⏭️"
+	}
+}
+
+func printOnboardReport(w io.Writer, report *pipeline.OnboardReport) {
+	fmt.Fprintf(w, "Onboarding %s as %s\n\n", report.AccountID, report.Target)
+	for _, c := range report.Checks {
+		fmt.Fprintf(w, "%s %s", checkIcon(c.Status), c.Name)
+		if c.Detail != "" {
+			fmt.Fprintf(w, ": %s", c.Detail)
+		}
+		fmt.Fprintln(w)
+		if c.Fix != "" && c.Status != pipeline.CheckPass {
+			fmt.Fprintf(w, "   → %s\n", c.Fix)
+		}
+	}
+
+	fmt.Fprintln(w, "\nAdd to targets:")
+	fmt.Fprint(w, report.Config)
+	if report.Ready() {
+		fmt.Fprintln(w, "\nReady to onboard")
+	} else {
+		fmt.Fprintln(w, "\nNot ready: fix the failed checks and run again")
+	}
+}
@@ -2,13 +2,19 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"os/signal"
+	"os/user"
+	"path/filepath"
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/jbcom/secretsync/internal/metrics"
 	"github.com/jbcom/secretsync/pkg/diff"
 	"github.com/jbcom/secretsync/pkg/pipeline"
 	"github.com/spf13/cobra"
@@ -16,14 +22,29 @@ import (
 )
 
 var (
-	targets         string
-	mergeOnly       bool
-	syncOnly        bool
-	dryRun          bool
-	discoverTargets bool
-	outputFormat    string
-	computeDiff     bool
-	exitCodeMode    bool
+	targets          string
+	mergeOnly        bool
+	syncOnly         bool
+	dryRun           bool
+	discoverTargets  bool
+	refreshDiscovery bool
+	outputFormat     string
+	computeDiff      bool
+	exitCodeMode     bool
+	overrideFreeze   string
+	parallelism      int
+	levelsFlag       string
+	labelFlags       []string
+	noDeps           bool
+	maxDepAge        time.Duration
+	probeDests       bool
+	metricsPort      int
+	metricsLinger    time.Duration
+	resultsFile      string
+	maxDiffLines     int
+	allowNewTargets  int
+	resumeRun        string
+	forceSync        bool
 )
 
 // pipelineCmd runs the full merge-then-sync pipeline
@@ -56,34 +77,148 @@ Examples:
   # CI/CD mode with exit codes
   vss pipeline --config config.yaml --dry-run --exit-code
   # Returns: 0 if no changes, 1 if changes detected, 2 on errors
+  # (errors exit 2 with or without --exit-code)
 
   # GitHub Actions compatible output
   vss pipeline --config config.yaml --dry-run --output github
 
+  # Show every change of a large diff (human and GitHub output are sampled to
+  # 1000 lines by default; JSON output is always complete)
+  vss pipeline --config config.yaml --dry-run --max-diff-lines 0
+
   # Specific targets only
   vss pipeline --config config.yaml --targets "Serverless_Stg,Serverless_Prod"
 
+  # A slice of the graph: levels 0-1, or targets labelled team=payments
+  # (selected targets do not pull in their dependencies)
+  vss pipeline --config config.yaml --levels 0-1
+  vss pipeline --config config.yaml --label team=payments
+
+  # Re-run a target without its dependencies, trusting their merged output
+  # (warns about dependencies that have not merged within --max-dep-age)
+  vss pipeline --config config.yaml --targets Serverless_Prod --no-deps
+
+  # Rediscover accounts instead of reusing aws.discovery_cache
+  vss pipeline --config config.yaml --discover --refresh-discovery
+
   # Merge only (no AWS sync)
   vss pipeline --config config.yaml --merge-only
 
   # Compute diff even when applying changes (for audit trail)
-  vss pipeline --config config.yaml --diff`,
-	RunE: runPipeline,
+  vss pipeline --config config.yaml --diff
+
+  # Skip destinations that fail a health probe (the run is reported as degraded)
+  vss pipeline --config config.yaml --probe-destinations
+
+  # Expose per-target metrics on :9090/metrics, and keep serving them for a
+  # minute after the run so the final values are scraped
+  vss pipeline --config config.yaml --metrics-port 9090 --metrics-linger 1m
+
+  # Archive every target's outcome as JSON for downstream tooling
+  vss pipeline --config config.yaml --results-file results.json
+
+  # Apply during a freeze window (recorded in the audit log)
+  vss pipeline --config config.yaml --targets Serverless_Prod --override-freeze "INC-1234 hotfix"
+
+  # Sync to 25 newly discovered accounts beyond pipeline.discovery_limits
+  vss pipeline --config config.yaml --discover --allow-new-targets 25
+
+  # Retry only what failed in a run recorded in pipeline.state
+  vss pipeline --config config.yaml --resume 20260301T120000.000000000Z`,
+	PreRunE: validatePipelineFlags,
+	RunE:    runPipeline,
 }
 
 func init() {
 	rootCmd.AddCommand(pipelineCmd)
 
 	pipelineCmd.Flags().StringVar(&targets, "targets", "", "comma-separated list of targets (default: all)")
+	pipelineCmd.Flags().StringVar(&levelsFlag, "levels", "", "also run the targets on these dependency levels, e.g. 0-1 or 1,3")
+	pipelineCmd.Flags().StringArrayVar(&labelFlags, "label", nil, "also run the targets with this label (key=value, repeatable; all must match)")
+	pipelineCmd.Flags().BoolVar(&noDeps, "no-deps", false, "do not run the dependencies of --targets; use their current merged output")
+	pipelineCmd.Flags().DurationVar(&maxDepAge, "max-dep-age", pipeline.DefaultMaxDependencyAge, "with --no-deps, warn about dependencies last merged longer ago than this")
 	pipelineCmd.Flags().BoolVar(&mergeOnly, "merge-only", false, "only run merge phase")
 	pipelineCmd.Flags().BoolVar(&syncOnly, "sync-only", false, "only run sync phase")
+	pipelineCmd.MarkFlagsMutuallyExclusive("merge-only", "sync-only")
+	pipelineCmd.Flags().IntVar(&parallelism, "parallel", 0, "max concurrent operations per phase (default: pipeline.merge.parallel, or 4)")
 	pipelineCmd.Flags().BoolVar(&dryRun, "dry-run", false, "dry run mode (no changes)")
 	pipelineCmd.Flags().BoolVar(&discoverTargets, "discover", false, "enable dynamic target discovery from AWS Organizations/Identity Center")
+	pipelineCmd.Flags().BoolVar(&refreshDiscovery, "refresh-discovery", false, "with --discover, ignore aws.discovery_cache and rediscover accounts")
 	
 	// Diff and output options
 	pipelineCmd.Flags().StringVarP(&outputFormat, "output", "o", "human", "output format: human, json, github, compact")
 	pipelineCmd.Flags().BoolVar(&computeDiff, "diff", false, "compute and show diff even when not in dry-run mode")
+	pipelineCmd.Flags().IntVar(&maxDiffLines, "max-diff-lines", diff.DefaultMaxLines, "sample human and github diff output down to this many lines (0 for no limit)")
 	pipelineCmd.Flags().BoolVar(&exitCodeMode, "exit-code", false, "use exit codes: 0=no changes, 1=changes, 2=errors (useful for CI/CD)")
+	pipelineCmd.Flags().StringVar(&overrideFreeze, "override-freeze", "", "apply during active freeze windows; the reason is recorded in the audit log")
+	pipelineCmd.Flags().BoolVar(&probeDests, "probe-destinations", false, "probe destinations before syncing and skip those that are down")
+	pipelineCmd.Flags().IntVar(&allowNewTargets, "allow-new-targets", 0, "with --discover, allow up to this many new targets beyond pipeline.discovery_limits")
+	pipelineCmd.Flags().StringVar(&resumeRun, "resume", "", "rerun the run with this ID from pipeline.state, skipping the targets that succeeded")
+	pipelineCmd.Flags().BoolVar(&forceSync, "force-sync", false, "sync destinations that pipeline.sync.skip_unchanged would skip as unchanged")
+	pipelineCmd.Flags().StringVar(&resultsFile, "results-file", "", "write every target's results as JSON to this file")
+	pipelineCmd.Flags().IntVar(&metricsPort, "metrics-port", 0, "serve /metrics and /healthz on this port while the pipeline runs (0 disables)")
+	pipelineCmd.Flags().DurationVar(&metricsLinger, "metrics-linger", 0, "with --metrics-port, keep serving metrics this long after the run")
+}
+
+// validatePipelineFlags rejects invalid flag combinations before any config is loaded
+func validatePipelineFlags(cmd *cobra.Command, args []string) error {
+	if mergeOnly && syncOnly {
+		return usageErrorf("--merge-only and --sync-only cannot be used together")
+	}
+	if parallelism < 0 {
+		return usageErrorf("--parallel must not be negative, got %d", parallelism)
+	}
+	if _, err := diff.ParseOutputFormat(outputFormat); err != nil {
+		return &usageError{err: fmt.Errorf("--output: %w", err)}
+	}
+	if maxDiffLines < 0 {
+		return usageErrorf("--max-diff-lines must not be negative, got %d", maxDiffLines)
+	}
+	if cmd.Flags().Changed("override-freeze") && strings.TrimSpace(overrideFreeze) == "" {
+		return usageErrorf("--override-freeze requires a reason")
+	}
+	if _, err := targetSelector(); err != nil {
+		return &usageError{err: err}
+	}
+	if noDeps && strings.TrimSpace(targets) == "" {
+		return usageErrorf("--no-deps requires --targets")
+	}
+	if cmd.Flags().Changed("max-dep-age") && maxDepAge <= 0 {
+		return usageErrorf("--max-dep-age must be positive, got %s", maxDepAge)
+	}
+	if refreshDiscovery && !discoverTargets {
+		return usageErrorf("--refresh-discovery requires --discover")
+	}
+	if allowNewTargets < 0 {
+		return usageErrorf("--allow-new-targets must not be negative, got %d", allowNewTargets)
+	}
+	if allowNewTargets > 0 && !discoverTargets {
+		return usageErrorf("--allow-new-targets requires --discover")
+	}
+	if cmd.Flags().Changed("resume") {
+		if strings.TrimSpace(resumeRun) == "" {
+			return usageErrorf("--resume requires a run ID")
+		}
+		for _, flag := range []string{"targets", "levels", "label", "no-deps", "merge-only", "sync-only"} {
+			if cmd.Flags().Changed(flag) {
+				return usageErrorf("--resume reruns the resumed run's targets and operation and cannot be used with --%s", flag)
+			}
+		}
+	}
+	if metricsPort < 0 {
+		return usageErrorf("--metrics-port must not be negative, got %d", metricsPort)
+	}
+	if metricsLinger < 0 || (metricsLinger > 0 && metricsPort == 0) {
+		return usageErrorf("--metrics-linger requires --metrics-port and must not be negative")
+	}
+	if cmd.Flags().Changed("targets") {
+		for _, t := range strings.Split(targets, ",") {
+			if strings.TrimSpace(t) == "" {
+				return usageErrorf("--targets contains an empty target name: %q", targets)
+			}
+		}
+	}
+	return nil
 }
 
 func runPipeline(cmd *cobra.Command, args []string) error {
@@ -96,19 +231,26 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	defer cancel()
 
 	// Create pipeline from config file
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
 	var p *pipeline.Pipeline
-	var err error
-	
 	if discoverTargets {
 		// Use context-aware constructor for dynamic target discovery
 		l.Info("Dynamic target discovery enabled")
-		p, err = pipeline.NewFromFileWithContext(ctx, cfgFile)
+		if refreshDiscovery && cfg.AWS.DiscoveryCache != nil {
+			cfg.AWS.DiscoveryCache.Refresh = true
+		}
+		p, err = pipeline.NewWithContext(ctx, cfg)
 	} else {
-		p, err = pipeline.NewFromFile(cfgFile)
+		p, err = pipeline.New(cfg)
 	}
 	if err != nil {
 		return fmt.Errorf("failed to create pipeline: %w", err)
 	}
+	defer p.Close()
 
 	// Handle signals
 	sigChan := make(chan os.Signal, 1)
@@ -119,6 +261,22 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	if metricsPort > 0 {
+		metrics.RegisterServiceHealth("pipeline", metrics.ServiceHealthStatusOK)
+		go metrics.Start(metricsPort, nil)
+		// A one-shot run may finish before its first scrape
+		defer func() {
+			if metricsLinger <= 0 {
+				return
+			}
+			l.WithField("linger", metricsLinger).Info("Serving metrics before exiting")
+			select {
+			case <-time.After(metricsLinger):
+			case <-ctx.Done():
+			}
+		}()
+	}
+
 	// Parse targets
 	var targetList []string
 	if targets != "" {
@@ -128,6 +286,11 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	selector, err := targetSelector()
+	if err != nil {
+		return err
+	}
+
 	// Determine operation
 	op := pipeline.OperationPipeline
 	if mergeOnly {
@@ -141,28 +304,55 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 
 	// Run options
 	opts := pipeline.Options{
-		Operation:       op,
-		Targets:         targetList,
-		DryRun:          dryRun,
-		ContinueOnError: true,
-		OutputFormat:    format,
-		ComputeDiff:     computeDiff || dryRun,
+		Operation:         op,
+		Targets:           targetList,
+		Selector:          selector,
+		DryRun:            dryRun,
+		ContinueOnError:   true,
+		Parallelism:       parallelism,
+		OutputFormat:      format,
+		ComputeDiff:       computeDiff || dryRun,
+		FreezeOverride:    freezeOverride(overrideFreeze),
+		NoDeps:            noDeps,
+		MaxDependencyAge:  maxDepAge,
+		ProbeDestinations: probeDests,
+		AllowNewTargets:   allowNewTargets,
+		Resume:            strings.TrimSpace(resumeRun),
+		ForceSync:         forceSync,
+		Provenance: pipeline.Provenance{
+			Version:    version,
+			ConfigFile: cfgFile,
+			Operator:   currentOperator(),
+			GitSHA:     configCommit(cfgFile),
+		},
 	}
 
 	l.WithFields(log.Fields{
 		"config":       cfgFile,
 		"targets":      targetList,
+		"selector":     selector.String(),
 		"operation":    op,
 		"dryRun":       dryRun,
 		"outputFormat": format,
 	}).Info("Starting pipeline")
 
 	// Run pipeline
+	started := time.Now()
 	results, err := p.Run(ctx, opts)
+	// Failed runs are written too, so they can be audited
+	if resultsFile != "" {
+		rr := pipeline.NewRunResults(opts, started, time.Now(), results, err)
+		if writeErr := pipeline.WriteResultsFile(resultsFile, rr); writeErr != nil {
+			err = errors.Join(err, writeErr)
+		}
+	}
+	if err != nil && len(results) == 0 {
+		return err
+	}
 
 	// Print diff output if computed
 	if d := p.Diff(); d != nil {
-		diffOutput := p.FormatDiff(format)
+		diffOutput := p.FormatDiffLines(format, maxDiffLines)
 		if diffOutput != "" {
 			fmt.Println(diffOutput)
 		}
@@ -170,21 +360,30 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		// Fall back to traditional results format
 		printResults(results)
 	}
-
-	// Determine exit behavior
-	if exitCodeMode {
-		exitCode := p.ExitCode()
-		if exitCode != 0 {
-			os.Exit(exitCode)
+	if pending := pipeline.PendingApprovalTargets(results); len(pending) > 0 {
+		fmt.Printf("\nPending approval: %d newly discovered targets were not synced:\n", len(pending))
+		for _, t := range pending {
+			fmt.Printf("  ⏸️ %s\n", t)
+		}
+		fmt.Println("Approve with: vss targets approve <target>...")
+	}
+	if id := p.ResumeID(); id != "" {
+		for _, r := range results {
+			if !r.Success {
+				resume := fmt.Sprintf("vss pipeline --config %s --resume %s", cfgFile, id)
+				if discoverTargets {
+					resume += " --discover"
+				}
+				fmt.Printf("\nRetry the failed targets with: %s\n", resume)
+				break
+			}
 		}
-		return nil
 	}
 
+	// Errors always win over change detection (exit 2)
 	if err != nil {
 		return err
 	}
-
-	// Check for any failures
 	for _, r := range results {
 		if !r.Success {
 			return fmt.Errorf("pipeline completed with errors")
@@ -192,21 +391,73 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	}
 
 	l.Info("Pipeline completed successfully")
+
+	// With --exit-code, detected changes exit 1
+	if exitCodeMode && p.ExitCode() == diff.ExitCodeChanges {
+		return changesDetected()
+	}
 	return nil
 }
 
-// parseOutputFormat converts string to OutputFormat
+// targetSelector builds the selector from --levels and --label
+func targetSelector() (pipeline.TargetSelector, error) {
+	var sel pipeline.TargetSelector
+	if levelsFlag != "" {
+		levels, err := pipeline.ParseLevels(levelsFlag)
+		if err != nil {
+			return sel, fmt.Errorf("--levels: %w", err)
+		}
+		sel.Levels = levels
+	}
+	labels, err := pipeline.ParseLabelSelector(labelFlags)
+	if err != nil {
+		return sel, fmt.Errorf("--label: %w", err)
+	}
+	sel.Labels = labels
+	return sel, nil
+}
+
+// freezeOverride builds the audit record for --override-freeze, or nil when not overriding
+func freezeOverride(reason string) *pipeline.FreezeOverride {
+	if reason == "" {
+		return nil
+	}
+	return &pipeline.FreezeOverride{By: currentOperator(), Reason: reason}
+}
+
+// currentOperator identifies who is running vss for audit records
+func currentOperator() string {
+	if actor := os.Getenv("GITHUB_ACTOR"); actor != "" {
+		return actor
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// configCommit returns the commit the config file is at: the CI's
+// (GITHUB_SHA, CI_COMMIT_SHA) or the HEAD of its Git repository, if any
+func configCommit(cfgFile string) string {
+	for _, env := range []string{"GITHUB_SHA", "CI_COMMIT_SHA"} {
+		if sha := os.Getenv(env); sha != "" {
+			return sha
+		}
+	}
+	out, err := exec.Command("git", "-C", filepath.Dir(cfgFile), "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// parseOutputFormat converts a flag value already checked with diff.ParseOutputFormat
 func parseOutputFormat(s string) diff.OutputFormat {
-	switch strings.ToLower(s) {
-	case "json":
-		return diff.OutputFormatJSON
-	case "github":
-		return diff.OutputFormatGitHub
-	case "compact":
-		return diff.OutputFormatCompact
-	default:
+	format, err := diff.ParseOutputFormat(s)
+	if err != nil {
 		return diff.OutputFormatHuman
 	}
+	return format
 }
 
 func printResults(results []pipeline.Result) {
@@ -231,7 +482,9 @@ func printResults(results []pipeline.Result) {
 		fmt.Println("\nMerge Phase:")
 		for _, r := range mergeResults {
 			status := "✅"
-			if !r.Success {
+			if r.Details.Resumed {
+				status = "⏭️"
+			} else if !r.Success {
 				status = "❌"
 			}
 			fmt.Printf("  %s %s (%.2fs)\n", status, r.Target, r.Duration.Seconds())
@@ -245,13 +498,44 @@ func printResults(results []pipeline.Result) {
 		fmt.Println("\nSync Phase:")
 		for _, r := range syncResults {
 			status := "✅"
-			if !r.Success {
+			if r.Details.PendingApproval {
+				status = "⏸️"
+			} else if r.Details.Resumed || r.Details.Unchanged {
+				status = "⏭️"
+			} else if !r.Success {
 				status = "❌"
 			}
-			fmt.Printf("  %s %s (%.2fs)\n", status, r.Target, r.Duration.Seconds())
+			note := ""
+			if r.Details.Unchanged {
+				note = " skipped (unchanged)"
+			}
+			fmt.Printf("  %s %s%s (%.2fs)\n", status, r.Target, note, r.Duration.Seconds())
 			if r.Error != nil {
 				fmt.Printf("      Error: %v\n", r.Error)
 			}
+			for _, d := range r.Details.Destinations {
+				status := "✅"
+				note := ""
+				if d.Skipped {
+					status = "⏭️"
+				} else if d.Unchanged {
+					status = "⏭️"
+					note = " skipped (unchanged)"
+				} else if !d.Success {
+					status = "❌"
+				}
+				fmt.Printf("      %s %s%s\n", status, d.Name, note)
+			}
+			for _, f := range r.Details.VerificationFailures {
+				fmt.Printf("      ⚠️ Verification failed: %s\n", f)
+			}
+		}
+	}
+
+	if degraded := pipeline.DegradedDestinations(results); len(degraded) > 0 {
+		fmt.Printf("\nDegraded: %d destinations down and skipped:\n", len(degraded))
+		for _, d := range degraded {
+			fmt.Printf("  ⏭️ %s\n", d)
 		}
 	}
 
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	preflightTargets string
+	preflightOutput  string
+)
+
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check the access a run needs before running it",
+	Long: `Checks, for each target, everything a run needs and prints a pass/fail matrix
+without writing anything:
+
+  - assume role: vss can assume the role of each AWS destination and lands
+    in the destination's account
+  - put secret value: IAM policy simulation allows the role
+    secretsmanager:PutSecretValue on the secrets the target writes
+  - role permissions: the role is allowed every action a sync needs on
+    those secrets; warns when it may also change other secrets (e.g.
+    secretsmanager:* on *) or their resource policies
+  - vault sources: the Vault token can list and read each Vault source the
+    target imports
+  - merge store: the target's merged secrets can be listed and, with a Vault
+    merge store, written
+
+The policy simulation needs iam:SimulatePrincipalPolicy in each destination
+account. Failed checks are listed below the matrix with how to fix them.
+
+Exit codes: 0 every check passed or was skipped, 2 a check failed.
+
+Examples:
+  vss preflight --config config.yaml
+  vss preflight --config config.yaml --targets Serverless_Stg,Serverless_Prod --output json`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if preflightOutput != "human" && preflightOutput != "json" {
+			return usageErrorf("--output must be human or json, got %q", preflightOutput)
+		}
+		return nil
+	},
+	RunE: runPreflight,
+}
+
+func init() {
+	rootCmd.AddCommand(preflightCmd)
+
+	preflightCmd.Flags().StringVar(&preflightTargets, "targets", "", "comma-separated targets (default: all)")
+	preflightCmd.Flags().StringVarP(&preflightOutput, "output", "o", "human", "output format: human, json")
+}
+
+func runPreflight(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+	var targetList []string
+	if preflightTargets != "" {
+		for _, t := range strings.Split(preflightTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+
+	report, err := p.Preflight(ctx, targetList)
+	if err != nil {
+		return err
+	}
+
+	if preflightOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			*pipeline.PreflightReport
+			Passed bool `json:"passed"`
+		}{report, report.Passed()}); err != nil {
+			return err
+		}
+	} else {
+		printPreflightReport(os.Stdout, report)
+	}
+
+	if !report.Passed() {
+		return fmt.Errorf("preflight failed")
+	}
+	return nil
+}
+
+func printPreflightReport(w io.Writer, report *pipeline.PreflightReport) {
+	width := len("TARGET")
+	for _, t := range report.Targets {
+		width = max(width, len(t.Target))
+	}
+
+	// Statuses are words rather than icons so the columns line up
+	fmt.Fprintf(w, "%-*s", width, "TARGET")
+	for _, c := range pipeline.PreflightChecks {
+		fmt.Fprintf(w, "  %s", strings.ToUpper(c))
+	}
+	fmt.Fprintln(w)
+	for _, t := range report.Targets {
+		fmt.Fprintf(w, "%-*s", width, t.Target)
+		for i, c := range t.Checks {
+			fmt.Fprintf(w, "  %-*s", len(pipeline.PreflightChecks[i]), c.Status)
+		}
+		fmt.Fprintln(w)
+	}
+
+	failed := false
+	for _, t := range report.Targets {
+		for _, c := range t.Checks {
+			if c.Status != pipeline.CheckFail && c.Status != pipeline.CheckWarn {
+				continue
+			}
+			if !failed {
+				fmt.Fprintln(w)
+				failed = true
+			}
+			fmt.Fprintf(w, "%s %s %s: %s\n", checkIcon(c.Status), t.Target, c.Name, c.Detail)
+			if c.Fix != "" {
+				fmt.Fprintf(w, "   → %s\n", c.Fix)
+			}
+		}
+	}
+	if report.Passed() {
+		fmt.Fprintln(w, "\nPreflight passed")
+	} else {
+		fmt.Fprintln(w, "\nPreflight failed: fix the failed checks and run again")
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promoteFrom   string
+	promoteTo     string
+	promoteYes    bool
+	promoteDryRun bool
+	promoteSync   bool
+	promoteOutput string
+	promoteFreeze string
+)
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Promote one target's merged secrets to another",
+	Long: `Copies the exact merged snapshot of one target over another target's
+merged secrets, bypassing the destination's own merge. This promotes what was
+tested in staging rather than re-merging production from its sources.
+
+The diff against the destination's current merged secrets is shown first and
+must be approved interactively unless --yes is given. Secrets missing from the
+source snapshot are removed from the destination.
+
+Examples:
+  # Preview a promotion
+  vss promote --from Serverless_Stg --to Serverless_Prod --dry-run
+
+  # Promote and push the result to Serverless_Prod's destinations
+  vss promote --from Serverless_Stg --to Serverless_Prod --sync
+
+  # Non-interactive (CI) promotion
+  vss promote --from Serverless_Stg --to Serverless_Prod --yes`,
+	PreRunE: validatePromoteFlags,
+	RunE:    runPromote,
+}
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().StringVar(&promoteFrom, "from", "", "target whose merged snapshot is promoted")
+	promoteCmd.Flags().StringVar(&promoteTo, "to", "", "target that receives the snapshot")
+	promoteCmd.Flags().BoolVarP(&promoteYes, "yes", "y", false, "skip the approval prompt")
+	promoteCmd.Flags().BoolVar(&promoteDryRun, "dry-run", false, "show the diff without promoting")
+	promoteCmd.Flags().BoolVar(&promoteSync, "sync", false, "run the sync phase for the destination target after promoting")
+	promoteCmd.Flags().StringVarP(&promoteOutput, "output", "o", "human", "diff output format: human, json, github, compact")
+	promoteCmd.Flags().StringVar(&promoteFreeze, "override-freeze", "", "promote during active freeze windows; the reason is recorded in the audit log")
+	promoteCmd.MarkFlagRequired("from")
+	promoteCmd.MarkFlagRequired("to")
+	promoteCmd.MarkFlagsMutuallyExclusive("dry-run", "sync")
+}
+
+func validatePromoteFlags(cmd *cobra.Command, args []string) error {
+	if promoteFrom != "" && promoteFrom == promoteTo {
+		return usageErrorf("--from and --to must be different targets")
+	}
+	if promoteDryRun && promoteSync {
+		return usageErrorf("--dry-run and --sync cannot be used together")
+	}
+	if _, err := diff.ParseOutputFormat(promoteOutput); err != nil {
+		return &usageError{err: fmt.Errorf("--output: %w", err)}
+	}
+	if cmd.Flags().Changed("override-freeze") && strings.TrimSpace(promoteFreeze) == "" {
+		return usageErrorf("--override-freeze requires a reason")
+	}
+	return nil
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runPromote",
+		"from":   promoteFrom,
+		"to":     promoteTo,
+	})
+	ctx := context.Background()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	plan, err := p.PlanPromotion(ctx, promoteFrom, promoteTo)
+	if err != nil {
+		return fmt.Errorf("failed to plan promotion: %w", err)
+	}
+
+	d := &diff.PipelineDiff{DryRun: promoteDryRun, ConfigPath: cfgFile}
+	d.AddTargetDiff(plan.Diff)
+	fmt.Println(diff.FormatDiff(d, parseOutputFormat(promoteOutput)))
+
+	if !plan.HasChanges() {
+		fmt.Printf("%s already matches %s; nothing to promote\n", promoteTo, promoteFrom)
+		return nil
+	}
+	if promoteDryRun {
+		return nil
+	}
+
+	override := freezeOverride(promoteFreeze)
+	if err := p.Config().CheckFreeze(time.Now(), []string{promoteTo}, override); err != nil {
+		return err
+	}
+
+	if !promoteYes {
+		ok, err := confirm(fmt.Sprintf("Promote %s to %s?", promoteFrom, promoteTo))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("promotion cancelled")
+		}
+	}
+
+	if err := p.Promote(ctx, plan); err != nil {
+		return err
+	}
+	l.Info("Promoted merged snapshot")
+	fmt.Printf("✅ Promoted %s to %s\n", promoteFrom, promoteTo)
+
+	if !promoteSync {
+		fmt.Printf("Run 'vss pipeline --sync-only --targets %s' to sync the promoted secrets\n", promoteTo)
+		return nil
+	}
+	results, err := p.Run(ctx, pipeline.Options{
+		Operation:       pipeline.OperationSync,
+		Targets:         []string{promoteTo},
+		ContinueOnError: true,
+		FreezeOverride:  override,
+	})
+	printResults(results)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Success {
+			return fmt.Errorf("sync of %s failed after promotion", promoteTo)
+		}
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal, refusing when stdin is not interactive
+func confirm(question string) (bool, error) {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("approval required: re-run with --yes in non-interactive sessions")
+	}
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
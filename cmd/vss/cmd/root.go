@@ -1,8 +1,13 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
+	"github.com/jbcom/secretsync/internal/fips"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/jbcom/secretsync/pkg/redact"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	log "github.com/sirupsen/logrus"
@@ -12,6 +17,10 @@ var (
 	cfgFile  string
 	logLevel string
 	logFormat string
+	strictConfig bool
+
+	// baseFormatter formats logs before redaction, per --log-format
+	baseFormatter log.Formatter = &log.TextFormatter{}
 )
 
 // rootCmd represents the base command
@@ -36,12 +45,36 @@ Examples:
   # Merge only (no AWS sync)
   vss pipeline --config config.yaml --merge-only
 
+  # Run every 15 minutes
+  vss serve --config config.yaml --schedule "*/15 * * * *"
+
+  # Check destinations for drift
+  vss drift --config config.yaml
+
+  # Check that a new account is ready to become a target
+  vss onboard --config config.yaml --account 123456789012 --import analytics
+
+  # Retire a target
+  vss offboard --config config.yaml --target Sandbox_Old --reason "account closed"
+
+  # Show which controllers hold claims on targets
+  vss claims --config config.yaml
+
   # Validate configuration
   vss validate --config config.yaml
 
+  # Store a Vault token in the OS keychain instead of exporting VAULT_TOKEN
+  vss login vault --config config.yaml
+
+  # Load-test with a synthetic config
+  vss simulate --targets 500 --secrets-per-target 200
+
   # Show dependency graph
   vss graph --config config.yaml`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	// Errors and usage are reported by execute
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// Set log level
 		level, err := log.ParseLevel(logLevel)
 		if err != nil {
@@ -49,34 +82,89 @@ Examples:
 		}
 		log.SetLevel(level)
 
-		// Set log format
+		// Set log format, redacting secret values until a config says otherwise
 		if logFormat == "json" {
-			log.SetFormatter(&log.JSONFormatter{})
+			baseFormatter = &log.JSONFormatter{}
+		}
+		applyLogRedaction(pipeline.RedactConfig{})
+
+		// version --fips reports FIPS problems itself
+		if cmd == versionCmd {
+			return nil
 		}
+		return fips.Init()
 	},
 }
 
-// Execute runs the root command
+// Execute runs the root command and exits following the exit code contract:
+// 0 = success / no changes, 1 = changes detected, 2 = errors
 func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	os.Exit(execute(rootCmd))
+}
+
+// execute runs a command tree, reports any error and returns the process exit code
+func execute(root *cobra.Command) int {
+	cmd, err := root.ExecuteC()
+	if err == nil {
+		return diff.ExitCodeNoChanges
+	}
+	if !isSilentExit(err) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	if isUsageError(err) {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprint(os.Stderr, cmd.UsageString())
 	}
+	return exitCode(err)
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	rootCmd.SetFlagErrorFunc(flagUsageError)
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "config.yaml", "config file path")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text, json)")
+	rootCmd.PersistentFlags().BoolVar(&strictConfig, "strict", false, "reject unknown config keys instead of ignoring them (default true for validate)")
 
 	// Bind to viper
 	viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
 	viper.BindPFlag("log.format", rootCmd.PersistentFlags().Lookup("log-format"))
 }
 
+// loadConfig loads a pipeline config file, rejecting unknown keys with --strict
+// and falling back to the tokens of vss login, and applies its log.redact
+// settings
+func loadConfig(path string) (*pipeline.Config, error) {
+	cfg, err := pipeline.LoadConfigWithOptions(path, pipeline.LoadOptions{
+		Strict:           strictConfig,
+		Version:          version,
+		StoredCredential: storedCredentials(credentialStore),
+	})
+	if err != nil {
+		return nil, err
+	}
+	applyLogRedaction(cfg.Log.Redact)
+	return cfg, nil
+}
+
+// applyLogRedaction sets the log formatter to baseFormatter, redacting the
+// values of secret fields unless redaction is disabled
+func applyLogRedaction(rc pipeline.RedactConfig) {
+	if rc.Disabled {
+		log.SetFormatter(baseFormatter)
+		return
+	}
+	f, err := redact.NewRedactingFormatter(baseFormatter, rc.Fields)
+	if err != nil {
+		// Validated configs compile, so fall back to the built-in fields
+		log.WithError(err).Warn("Invalid log.redact.fields, redacting the built-in fields only")
+		f, _ = redact.NewRedactingFormatter(baseFormatter, nil)
+	}
+	log.SetFormatter(f)
+}
+
 func initConfig() {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Inspect recorded pipeline runs",
+	Long: `Inspects runs recorded in the run history (pipeline.history.dir).
+
+Examples:
+  vss runs diff --config config.yaml
+  vss runs diff --config config.yaml 20260301T120000.000000000Z 20260308T120000.000000000Z`,
+}
+
+var (
+	runsDiffOutput      string
+	runsDiffMinSlowdown float64
+	runsDiffMinDelta    time.Duration
+)
+
+var runsDiffCmd = &cobra.Command{
+	Use:   "diff [<from-run> <to-run>]",
+	Short: "Compare two recorded runs",
+	Long: `Compares two recorded runs target by target and phase by phase: what newly
+failed, what recovered, what is still failing, targets only one of the runs
+processed, phases that got slower, and targets whose secret changes differ
+(for runs that computed a diff, e.g. with --dry-run or --diff).
+
+A phase is reported as slower when it took at least --min-slowdown longer
+(0.2 = 20%) and at least --min-delta longer. Only phases that succeeded in both
+runs are timed.
+
+Without arguments, the two most recent runs are compared. Run IDs are the file
+names in pipeline.history.dir.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return usageErrorf("expected two run IDs, or none to compare the two most recent runs")
+		}
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if runsDiffOutput != "human" && runsDiffOutput != "json" {
+			return usageErrorf("--output must be human or json, got %q", runsDiffOutput)
+		}
+		if runsDiffMinSlowdown < 0 || runsDiffMinDelta < 0 {
+			return usageErrorf("--min-slowdown and --min-delta must not be negative")
+		}
+		return nil
+	},
+	RunE: runRunsDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.AddCommand(runsDiffCmd)
+
+	runsDiffCmd.Flags().StringVarP(&runsDiffOutput, "output", "o", "human", "output format: human, json")
+	runsDiffCmd.Flags().Float64Var(&runsDiffMinSlowdown, "min-slowdown", 0.2, "smallest slowdown reported, as a fraction of the earlier duration")
+	runsDiffCmd.Flags().DurationVar(&runsDiffMinDelta, "min-delta", time.Second, "ignore slowdowns shorter than this")
+}
+
+func runRunsDiff(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Pipeline.History == nil || cfg.Pipeline.History.Dir == "" {
+		return fmt.Errorf("pipeline.history.dir is not set, so no runs have been recorded")
+	}
+	runs, err := pipeline.LoadRunHistory(cfg.Pipeline.History.Dir)
+	if err != nil {
+		return err
+	}
+
+	var from, to pipeline.RunRecord
+	if len(args) == 2 {
+		if from, err = selectRun(runs, args[0]); err != nil {
+			return err
+		}
+		if to, err = selectRun(runs, args[1]); err != nil {
+			return err
+		}
+	} else {
+		if len(runs) < 2 {
+			return fmt.Errorf("need at least two recorded runs to compare, found %d", len(runs))
+		}
+		from, to = runs[len(runs)-2], runs[len(runs)-1]
+	}
+
+	c := pipeline.CompareRuns(from, to, pipeline.CompareOptions{
+		MinSlowdown: runsDiffMinSlowdown,
+		MinDelta:    runsDiffMinDelta,
+	})
+	if runsDiffOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	printRunComparison(os.Stdout, c)
+	return nil
+}
+
+func printRunComparison(w io.Writer, c *pipeline.RunComparison) {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+
+	fmt.Fprintf(w, "Run %s → %s\n", c.From, c.To)
+	if c.IsEmpty() {
+		fmt.Fprintln(w, "\nNo differences")
+		return
+	}
+
+	outcomes := func(title string, list []pipeline.PhaseOutcome) {
+		if len(list) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s (%d):\n", title, len(list))
+		for _, o := range list {
+			if o.Error != "" {
+				fmt.Fprintf(w, "   %s %s: %s\n", o.Target, o.Phase, o.Error)
+			} else {
+				fmt.Fprintf(w, "   %s %s\n", o.Target, o.Phase)
+			}
+		}
+	}
+	outcomes("Newly failing", c.NewlyFailed)
+	outcomes("Recovered (previous error)", c.Recovered)
+	outcomes("Still failing", c.StillFailing)
+	outcomes("Only in "+c.From, c.OnlyInFrom)
+	outcomes("Only in "+c.To, c.OnlyInTo)
+
+	if len(c.Slower) > 0 {
+		fmt.Fprintf(w, "\nSlower (%d):\n", len(c.Slower))
+		for _, d := range c.Slower {
+			fmt.Fprintf(w, "   %s %s: %s → %s (+%.0f%%)\n", d.Target, d.Phase, round(d.From), round(d.To), d.Increase()*100)
+		}
+	}
+	if len(c.Changed) > 0 {
+		fmt.Fprintf(w, "\nSecret changes differ (%d):\n", len(c.Changed))
+		for _, d := range c.Changed {
+			fmt.Fprintf(w, "   %s %s: %s\n", d.Target, d.Phase, d)
+		}
+	}
+}
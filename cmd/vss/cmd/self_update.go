@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	goversion "github.com/hashicorp/go-version"
+	"github.com/jbcom/secretsync/internal/selfupdate"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selfUpdateVersion string
+	selfUpdateCheck   bool
+	selfUpdateForce   bool
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update vss to the latest signed release",
+	Long: `Replaces the running vss binary with the latest release, or the release
+given with --version, from GitHub.
+
+Release checksums are signed keylessly by the release workflow. Before
+installing, self-update verifies the signature with cosign (which must be on
+PATH) against the workflow's identity for the release tag, then checks the
+downloaded archive against the signed checksums. Nothing is replaced unless
+both checks pass.
+
+Configs can require a minimum release with min_vss_version; vss refuses to
+load them until it is updated.
+
+GITHUB_TOKEN, when set, authenticates release lookups.
+
+Examples:
+  # Update to the latest release
+  vss self-update
+
+  # Report whether an update is available without installing it
+  vss self-update --check
+
+  # Install a specific release, even if it is older
+  vss self-update --version v1.5.0 --force`,
+	RunE: runSelfUpdate,
+}
+
+func init() {
+	rootCmd.AddCommand(selfUpdateCmd)
+	selfUpdateCmd.Flags().StringVar(&selfUpdateVersion, "version", "", "release tag to install (default: latest)")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateCheck, "check", false, "only report whether an update is available")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateForce, "force", false, "install even if the release is not newer, or this is a development build")
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runSelfUpdate",
+	})
+	ctx := context.Background()
+
+	u := selfupdate.New()
+	u.Token = os.Getenv("GITHUB_TOKEN")
+	rel, err := u.Release(ctx, selfUpdateVersion)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	newer, err := releaseIsNewer(version, rel.Tag)
+	if err != nil {
+		return err
+	}
+	_, err = goversion.NewVersion(version)
+	dev := err != nil
+	switch {
+	case selfUpdateCheck && newer:
+		fmt.Fprintf(out, "Update available: %s -> %s (%s)\n", version, rel.Tag, rel.URL)
+		return nil
+	case dev && (selfUpdateCheck || !selfUpdateForce):
+		fmt.Fprintf(out, "vss %s is a development build (latest release: %s); use --force to replace it\n", version, rel.Tag)
+		return nil
+	case !newer && (selfUpdateCheck || !selfUpdateForce):
+		fmt.Fprintf(out, "vss %s is up to date (latest: %s)\n", version, rel.Tag)
+		return nil
+	}
+
+	if _, err := exec.LookPath("cosign"); err != nil {
+		return fmt.Errorf("cosign is required to verify releases: %w", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+
+	l.WithFields(log.Fields{
+		"from": version,
+		"to":   rel.Tag,
+	}).Info("Downloading release")
+	binary, err := u.Download(ctx, rel)
+	if err != nil {
+		return err
+	}
+	if err := selfupdate.Replace(exe, binary); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Updated vss %s -> %s\n", version, rel.Tag)
+	return nil
+}
+
+// releaseIsNewer reports whether tag is a newer release than current.
+// Development builds are never older than a release.
+func releaseIsNewer(current, tag string) (bool, error) {
+	latest, err := goversion.NewVersion(tag)
+	if err != nil {
+		return false, fmt.Errorf("release has an invalid tag %q: %w", tag, err)
+	}
+	running, err := goversion.NewVersion(current)
+	if err != nil {
+		return false, nil
+	}
+	return latest.GreaterThan(running), nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jbcom/secretsync/internal/metrics"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveSchedule    string
+	serveJitter      time.Duration
+	serveMetricsPort int
+	serveDryRun      bool
+	serveDiscover    bool
+	serveParallelism int
+	serveProbeDests  bool
+)
+
+// serveCmd runs the pipeline continuously on a schedule
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the pipeline continuously on a cron schedule",
+	Long: `Runs the pipeline on the cron schedule in pipeline.schedule, for hosts
+outside Kubernetes. Targets listed under pipeline.schedule.targets run on their
+own expression; every other target runs on --schedule (or pipeline.schedule.cron).
+
+Runs never overlap: a target due while another run is going starts when that
+run finishes, and ticks that pass while its own run is going are skipped and
+counted. Each run can be delayed by a random --jitter.
+
+Prometheus metrics and /healthz are served on --metrics-port, including each
+target's last and next run. With --probe-destinations, destinations are
+probed every pipeline.destination_health.interval and runs skip those that
+are down.
+
+Examples:
+  # Every 15 minutes, up to 30s late
+  vss serve --config config.yaml --schedule "*/15 * * * *" --jitter 30s
+
+  # Use pipeline.schedule from the config, skipping unhealthy destinations
+  vss serve --config config.yaml --probe-destinations`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if serveJitter < 0 {
+			return usageErrorf("--jitter must not be negative, got %s", serveJitter)
+		}
+		if serveParallelism < 0 {
+			return usageErrorf("--parallel must not be negative, got %d", serveParallelism)
+		}
+		if serveMetricsPort < 0 || serveMetricsPort > 65535 {
+			return usageErrorf("--metrics-port must be between 0 and 65535, got %d", serveMetricsPort)
+		}
+		return nil
+	},
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveSchedule, "schedule", "", "cron expression for targets without their own (overrides pipeline.schedule.cron)")
+	serveCmd.Flags().DurationVar(&serveJitter, "jitter", 0, "delay each run by up to this long (overrides pipeline.schedule.jitter)")
+	serveCmd.Flags().IntVar(&serveMetricsPort, "metrics-port", 9090, "port for /metrics and /healthz (0 disables)")
+	serveCmd.Flags().BoolVar(&serveDryRun, "dry-run", false, "dry run mode (no changes)")
+	serveCmd.Flags().BoolVar(&serveDiscover, "discover", false, "enable dynamic target discovery at startup")
+	serveCmd.Flags().IntVar(&serveParallelism, "parallel", 0, "max concurrent operations per phase (default: pipeline.merge.parallel, or 4)")
+	serveCmd.Flags().BoolVar(&serveProbeDests, "probe-destinations", false, "probe destinations continuously and skip those that are down")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runServe",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if serveSchedule != "" || cmd.Flags().Changed("jitter") {
+		if cfg.Pipeline.Schedule == nil {
+			cfg.Pipeline.Schedule = &pipeline.ScheduleSettings{}
+		}
+		if serveSchedule != "" {
+			cfg.Pipeline.Schedule.Cron = serveSchedule
+		}
+		if cmd.Flags().Changed("jitter") {
+			cfg.Pipeline.Schedule.Jitter = serveJitter
+		}
+	}
+	if cfg.Pipeline.Schedule == nil {
+		return usageErrorf("--schedule is required when the config has no pipeline.schedule")
+	}
+
+	var p *pipeline.Pipeline
+	if serveDiscover {
+		p, err = pipeline.NewWithContext(ctx, cfg)
+	} else {
+		p, err = pipeline.New(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	defer p.Close()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		l.Warn("Received shutdown signal")
+		cancel()
+	}()
+
+	if serveMetricsPort > 0 {
+		metrics.RegisterServiceHealth("scheduler", metrics.ServiceHealthStatusOK)
+		go metrics.Start(serveMetricsPort, nil)
+	}
+	if serveProbeDests {
+		go func() {
+			if err := p.WatchDestinations(ctx, nil); err != nil {
+				l.WithError(err).Error("Destination health probes stopped")
+			}
+		}()
+	}
+
+	opts := pipeline.Options{
+		Operation:       pipeline.OperationPipeline,
+		DryRun:          serveDryRun,
+		ContinueOnError: true,
+		Parallelism:     serveParallelism,
+		Provenance: pipeline.Provenance{
+			Version:    version,
+			ConfigFile: cfgFile,
+			Operator:   currentOperator(),
+			GitSHA:     configCommit(cfgFile),
+		},
+	}
+	l.WithFields(log.Fields{
+		"config": cfgFile,
+		"dryRun": serveDryRun,
+	}).Info("Starting scheduled pipeline")
+	return p.RunScheduled(ctx, opts)
+}
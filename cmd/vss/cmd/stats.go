@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Analyze recorded pipeline runs",
+	Long: `Analyzes runs recorded in the run history (pipeline.history.dir).
+
+Examples:
+  vss stats critical-path --config config.yaml
+  vss stats critical-path --config config.yaml --run 20260301T120000.000000000Z`,
+}
+
+var criticalPathCmd = &cobra.Command{
+	Use:   "critical-path",
+	Short: "Show per-level timing and the critical path of a run",
+	Long: `Shows how long each dependency level of the merge phase and the sync phase
+took in a recorded run, and the critical path: the chain of dependent targets
+whose merges took longest end to end. Targets at one level wait for the whole
+previous level, so the merge phase can never be shorter than the critical path.
+
+Suggestions point out levels whose targets queued for a worker, where a higher
+--parallel would help, and runs bound by the critical path, where it would not.
+
+Dependencies are taken from the current config; levels are as recorded.`,
+	RunE: runCriticalPath,
+}
+
+var statsRunID string
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.AddCommand(criticalPathCmd)
+	criticalPathCmd.Flags().StringVar(&statsRunID, "run", "", "run ID to analyze (default: the most recent run)")
+}
+
+func runCriticalPath(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Pipeline.History == nil || cfg.Pipeline.History.Dir == "" {
+		return fmt.Errorf("pipeline.history.dir is not set, so no runs have been recorded")
+	}
+	graph, err := pipeline.BuildGraph(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
+
+	runs, err := pipeline.LoadRunHistory(cfg.Pipeline.History.Dir)
+	if err != nil {
+		return err
+	}
+	run, err := selectRun(runs, statsRunID)
+	if err != nil {
+		return err
+	}
+
+	printRunStats(os.Stdout, pipeline.AnalyzeRun(run, graph))
+	return nil
+}
+
+// selectRun returns the run with the given ID, or the most recent run if id is empty
+func selectRun(runs []pipeline.RunRecord, id string) (pipeline.RunRecord, error) {
+	if len(runs) == 0 {
+		return pipeline.RunRecord{}, fmt.Errorf("no runs recorded yet")
+	}
+	if id == "" {
+		return runs[len(runs)-1], nil
+	}
+	for _, run := range runs {
+		if run.ID == id {
+			return run, nil
+		}
+	}
+	return pipeline.RunRecord{}, fmt.Errorf("run %q not found in the run history", id)
+}
+
+func printRunStats(w io.Writer, stats pipeline.RunStats) {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+
+	fmt.Fprintf(w, "Run %s", stats.RunID)
+	if stats.Parallelism > 0 {
+		fmt.Fprintf(w, " (parallel %d)", stats.Parallelism)
+	}
+	fmt.Fprintln(w)
+
+	if len(stats.Levels) > 0 {
+		fmt.Fprintf(w, "\nMerge phase: %s\n", round(stats.MergeWall))
+		for _, lt := range stats.Levels {
+			fmt.Fprintf(w, "   Level %d: %s, %d targets (slowest: %s %s)\n",
+				lt.Level, round(lt.Wall), lt.Targets, lt.Slowest.Target, round(lt.Slowest.Duration))
+		}
+	}
+	if stats.SyncTargets > 0 {
+		fmt.Fprintf(w, "\nSync phase: %s, %d targets (slowest: %s %s)\n",
+			round(stats.SyncWall), stats.SyncTargets, stats.SlowestSync.Target, round(stats.SlowestSync.Duration))
+	}
+
+	if len(stats.CriticalPath) > 0 {
+		fmt.Fprintf(w, "\nCritical path: %s\n", round(stats.CriticalPathDuration))
+		for i, step := range stats.CriticalPath {
+			arrow := "  "
+			if i > 0 {
+				arrow = "→ "
+			}
+			fmt.Fprintf(w, "   %s%s (%s)\n", arrow, step.Target, round(step.Duration))
+		}
+	}
+
+	if len(stats.Suggestions) > 0 {
+		fmt.Fprintln(w, "\nSuggestions:")
+		for _, s := range stats.Suggestions {
+			fmt.Fprintf(w, "   - %s\n", s)
+		}
+	}
+}
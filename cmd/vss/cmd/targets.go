@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	targetsOutput     string
+	targetsApproveAll bool
+)
+
+var targetsCmd = &cobra.Command{
+	Use:   "targets",
+	Short: "Manage discovered targets",
+}
+
+var targetsPendingCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "List discovered targets awaiting approval",
+	Long: `Lists the targets discovered by dynamic targets with require_approval that
+have not been approved. Runs merge their secrets into the merge store but do
+not sync them until they are approved with vss targets approve.
+
+Examples:
+  vss targets pending --config config.yaml
+  vss targets pending --config config.yaml -o json`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if targetsOutput != "human" && targetsOutput != "json" {
+			return usageErrorf("--output must be human or json, got %q", targetsOutput)
+		}
+		return nil
+	},
+	RunE: runTargetsPending,
+}
+
+var targetsApproveCmd = &cobra.Command{
+	Use:   "approve [<target>...]",
+	Short: "Approve discovered targets for syncing",
+	Long: `Approves targets discovered by dynamic targets with require_approval, so the
+next run syncs them. A target discovery finds that no run has seen yet can
+be approved ahead of its first run. Approvals are recorded in the merge store
+with who approved them and logged to the audit log.
+
+Examples:
+  vss targets approve --config config.yaml Sandbox_Alice Sandbox_Bob
+  vss targets approve --config config.yaml --all`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if targetsApproveAll == (len(args) > 0) {
+			return usageErrorf("pass the targets to approve or --all")
+		}
+		return nil
+	},
+	RunE: runTargetsApprove,
+}
+
+func init() {
+	rootCmd.AddCommand(targetsCmd)
+	targetsCmd.AddCommand(targetsPendingCmd)
+	targetsCmd.AddCommand(targetsApproveCmd)
+
+	targetsPendingCmd.Flags().StringVarP(&targetsOutput, "output", "o", "human", "output format: human, json")
+	targetsApproveCmd.Flags().BoolVar(&targetsApproveAll, "all", false, "approve every pending target")
+}
+
+// newTargetsPipeline creates a pipeline with discovery, so approvals see the
+// targets discovery finds
+func newTargetsPipeline(ctx context.Context) (*pipeline.Pipeline, error) {
+	cfg, err := loadConfig(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	p, err := pipeline.NewWithContext(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	return p, nil
+}
+
+// pendingApprovals returns the recorded approvals that are still pending
+func pendingApprovals(ctx context.Context, p *pipeline.Pipeline) ([]pipeline.TargetApproval, error) {
+	approvals, err := p.TargetApprovals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []pipeline.TargetApproval
+	for _, a := range approvals {
+		if a.Approved == nil {
+			pending = append(pending, a)
+		}
+	}
+	return pending, nil
+}
+
+func runTargetsPending(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	p, err := newTargetsPipeline(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	pending, err := pendingApprovals(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	if targetsOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pending)
+	}
+	printPendingApprovals(os.Stdout, pending, time.Now())
+	return nil
+}
+
+func printPendingApprovals(w io.Writer, pending []pipeline.TargetApproval, now time.Time) {
+	if len(pending) == 0 {
+		fmt.Fprintln(w, "No targets pending approval")
+		return
+	}
+	for _, a := range pending {
+		account := ""
+		if a.AccountID != "" {
+			account = " account " + a.AccountID + ","
+		}
+		fmt.Fprintf(w, "%s:%s discovered by %s %s ago\n",
+			a.Target, account, a.DynamicTarget, now.Sub(a.Discovered).Round(time.Second))
+	}
+	fmt.Fprintln(w, "\nApprove with: vss targets approve <target>... or --all")
+}
+
+func runTargetsApprove(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	p, err := newTargetsPipeline(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+
+	targetList := args
+	if targetsApproveAll {
+		pending, err := pendingApprovals(ctx, p)
+		if err != nil {
+			return err
+		}
+		for _, a := range pending {
+			targetList = append(targetList, a.Target)
+		}
+		if len(targetList) == 0 {
+			fmt.Println("No targets pending approval")
+			return nil
+		}
+	}
+
+	approved, err := p.ApproveTargets(ctx, targetList, currentOperator(), time.Now())
+	for _, a := range approved {
+		fmt.Printf("✅ %s approved by %s\n", a.Target, a.ApprovedBy)
+	}
+	return err
+}
@@ -2,11 +2,16 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jbcom/secretsync/pkg/diff"
 	"github.com/jbcom/secretsync/pkg/pipeline"
-	"github.com/spf13/cobra"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
 )
 
 var validateCmd = &cobra.Command{
@@ -16,22 +21,92 @@ var validateCmd = &cobra.Command{
 
 Checks:
 - YAML syntax
+- Minimum vss version (min_vss_version)
+- Unknown keys (strict mode, on by default; --strict=false to only warn)
+- Literal credentials (secret_id, tokens, private keys must be ${VAR} references)
 - Required fields
 - Target references (sources exist)
 - Dependency graph (no cycles)
+- Active freeze windows (warning)
 - AWS execution context (optional)
 
+Output formats:
+- human:  checklist with a configuration summary (default)
+- json:   structured report with per-check status, severity and message
+- github: GitHub Actions ::error/::warning annotations
+
 Examples:
   vss validate --config config.yaml
-  vss validate --config config.yaml --check-aws`,
-	RunE: runValidate,
+  vss validate --config config.yaml --check-aws
+  vss validate --config config.yaml -o json | jq '.checks[] | select(.status == "fail")'`,
+	PreRunE: validateValidateFlags,
+	RunE:    runValidate,
 }
 
-var checkAWS bool
+var (
+	checkAWS       bool
+	validateOutput string
+)
 
 func init() {
 	rootCmd.AddCommand(validateCmd)
 	validateCmd.Flags().BoolVar(&checkAWS, "check-aws", false, "also validate AWS credentials and access")
+	validateCmd.Flags().StringVarP(&validateOutput, "output", "o", "human", "output format: human, json, github")
+}
+
+// Validation check statuses
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkWarn = "warn"
+	checkSkip = "skip"
+)
+
+// validationCheck is the outcome of a single validation step
+type validationCheck struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Severity string `json:"severity"` // error or warning
+	Message  string `json:"message"`
+	// Line and Column locate the problem in the config file when known
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+// validationSummary describes a configuration that loaded successfully
+type validationSummary struct {
+	Sources        int        `json:"sources"`
+	Targets        int        `json:"targets"`
+	DynamicTargets int        `json:"dynamic_targets"`
+	VaultAddress   string     `json:"vault_address"`
+	AWSRegion      string     `json:"aws_region,omitempty"`
+	ControlTower   bool       `json:"control_tower"`
+	Levels         [][]string `json:"levels,omitempty"`
+}
+
+// validationReport is the machine-readable result of vss validate
+type validationReport struct {
+	Config  string             `json:"config"`
+	Valid   bool               `json:"valid"`
+	Checks  []validationCheck  `json:"checks"`
+	Summary *validationSummary `json:"summary,omitempty"`
+
+	awsSummary string
+}
+
+func (r *validationReport) add(name, status, severity, message string) {
+	r.Checks = append(r.Checks, validationCheck{Name: name, Status: status, Severity: severity, Message: message})
+	if status == checkFail && severity == "error" {
+		r.Valid = false
+	}
+}
+
+func validateValidateFlags(cmd *cobra.Command, args []string) error {
+	switch validateOutput {
+	case "human", "json", "github":
+		return nil
+	}
+	return usageErrorf("--output: unknown format %q (must be human, json or github)", validateOutput)
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
@@ -39,63 +114,219 @@ func runValidate(cmd *cobra.Command, args []string) error {
 		"action": "runValidate",
 	})
 
-	fmt.Printf("Validating configuration: %s\n\n", cfgFile)
+	// validate is strict unless --strict=false is given explicitly
+	strict := strictConfig || !cmd.Flags().Changed("strict")
+	report := buildValidationReport(context.Background(), cfgFile, checkAWS, strict)
 
-	// Load config
-	cfg, err := pipeline.LoadConfig(cfgFile)
+	switch validateOutput {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "github":
+		printValidationGitHub(report)
+	default:
+		printValidationHuman(report)
+	}
+
+	if !report.Valid {
+		// Failures are already in the report; exit 2 without repeating them
+		return &exitError{code: diff.ExitCodeError}
+	}
+	l.Info("Validation completed successfully")
+	return nil
+}
+
+// buildValidationReport runs every check that applies, stopping at the first
+// failure that makes later checks meaningless
+func buildValidationReport(ctx context.Context, path string, withAWS, strict bool) *validationReport {
+	report := &validationReport{Config: path, Valid: true}
+
+	cfg, err := pipeline.LoadConfigWithOptions(path, pipeline.LoadOptions{Strict: strict, Version: version})
+	var tooOld *pipeline.MinVersionError
+	if errors.As(err, &tooOld) {
+		report.add("min_vss_version", checkFail, "error", err.Error())
+		return report
+	}
+	var unknown *pipeline.UnknownFieldsError
+	if errors.As(err, &unknown) {
+		for _, f := range unknown.Fields {
+			report.Checks = append(report.Checks, validationCheck{
+				Name:     "unknown_key",
+				Status:   checkFail,
+				Severity: "error",
+				Message:  fmt.Sprintf("unknown key %q", f.Key),
+				Line:     f.Line,
+				Column:   f.Column,
+			})
+		}
+		report.Valid = false
+		return report
+	}
 	if err != nil {
-		fmt.Printf("❌ Config load failed: %v\n", err)
-		return err
+		report.add("load", checkFail, "error", err.Error())
+		return report
 	}
-	fmt.Println("✅ Config file parsed successfully")
+	report.add("load", checkPass, "error", "Config file parsed successfully")
 
-	// Validate config structure
-	if err := cfg.Validate(); err != nil {
-		fmt.Printf("❌ Config validation failed: %v\n", err)
-		return err
+	err = cfg.Validate()
+	var literal *pipeline.LiteralSecretsError
+	if errors.As(err, &literal) {
+		for _, s := range literal.Secrets {
+			report.Checks = append(report.Checks, validationCheck{
+				Name:     "literal_secret",
+				Status:   checkFail,
+				Severity: "error",
+				Message:  fmt.Sprintf("%s contains a literal %s; %s", s.Key, s.Kind, s.Guidance()),
+				Line:     s.Line,
+				Column:   s.Column,
+			})
+		}
+		report.Valid = false
+		return report
 	}
-	fmt.Println("✅ Config structure validated")
+	if err != nil {
+		report.add("structure", checkFail, "error", err.Error())
+		return report
+	}
+	report.add("structure", checkPass, "error", "Config structure validated")
 
-	// Build dependency graph
 	graph, err := pipeline.BuildGraph(cfg)
 	if err != nil {
-		fmt.Printf("❌ Dependency graph failed: %v\n", err)
-		return err
+		report.add("graph", checkFail, "error", err.Error())
+		return report
 	}
-	fmt.Println("✅ Dependency graph validated (no cycles)")
+	report.add("graph", checkPass, "error", "Dependency graph validated (no cycles)")
 
-	// Print summary
-	fmt.Printf("\nConfiguration Summary:\n")
-	fmt.Printf("  Sources: %d\n", len(cfg.Sources))
-	fmt.Printf("  Targets: %d\n", len(cfg.Targets))
-	fmt.Printf("  Dynamic Targets: %d\n", len(cfg.DynamicTargets))
-	fmt.Printf("  Vault Address: %s\n", cfg.Vault.Address)
-	fmt.Printf("  AWS Region: %s\n", cfg.AWS.Region)
-	fmt.Printf("  Control Tower: %v\n", cfg.AWS.ControlTower.Enabled)
+	report.Summary = &validationSummary{
+		Sources:        len(cfg.Sources),
+		Targets:        len(cfg.Targets),
+		DynamicTargets: len(cfg.DynamicTargets),
+		VaultAddress:   cfg.Vault.Address,
+		AWSRegion:      cfg.AWS.Region,
+		ControlTower:   cfg.AWS.ControlTower.Enabled,
+		Levels:         graph.GroupByLevel(),
+	}
 
-	// Print dependency levels
-	levels := graph.GroupByLevel()
-	fmt.Printf("\nDependency Levels:\n")
-	for i, level := range levels {
-		fmt.Printf("  Level %d: %v\n", i, level)
+	targets := make([]string, 0, len(cfg.Targets))
+	for name := range cfg.Targets {
+		targets = append(targets, name)
+	}
+	frozen, err := cfg.FrozenTargets(time.Now(), targets)
+	switch {
+	case err != nil:
+		report.add("freeze_windows", checkFail, "warning", err.Error())
+	case len(frozen) > 0:
+		blocked := make([]string, 0, len(frozen))
+		for _, f := range frozen {
+			blocked = append(blocked, f.String())
+		}
+		report.add("freeze_windows", checkWarn, "warning", "Apply runs are currently blocked for "+strings.Join(blocked, ", "))
+	default:
+		report.add("freeze_windows", checkPass, "warning", "No active freeze windows")
 	}
 
-	// Check AWS if requested
-	if checkAWS {
-		fmt.Println("\nValidating AWS access...")
-		ctx := context.Background()
+	if !withAWS {
+		report.add("aws", checkSkip, "error", "AWS access not checked (use --check-aws)")
+		return report
+	}
+	awsCtx, err := pipeline.NewAWSExecutionContext(ctx, &cfg.AWS)
+	if err != nil {
+		report.add("aws", checkFail, "error", err.Error())
+		return report
+	}
+	report.add("aws", checkPass, "error", "AWS credentials valid")
+	report.awsSummary = awsCtx.Summary()
+	return report
+}
 
-		awsCtx, err := pipeline.NewAWSExecutionContext(ctx, &cfg.AWS)
-		if err != nil {
-			fmt.Printf("❌ AWS validation failed: %v\n", err)
-			return err
+func printValidationHuman(report *validationReport) {
+	fmt.Printf("Validating configuration: %s\n\n", report.Config)
+
+	for _, c := range report.Checks {
+		switch c.Status {
+		case checkPass:
+			fmt.Printf("✅ %s\n", c.Message)
+		case checkWarn:
+			fmt.Printf("⚠️  %s\n", c.Message)
+		case checkSkip:
+			continue
+		default:
+			if c.Line > 0 {
+				fmt.Printf("❌ %s:%d:%d: %s\n", report.Config, c.Line, c.Column, c.Message)
+				continue
+			}
+			fmt.Printf("❌ %s failed: %s\n", c.Name, c.Message)
 		}
+	}
 
-		fmt.Println("✅ AWS credentials valid")
-		fmt.Printf("\n%s", awsCtx.Summary())
+	if s := report.Summary; s != nil {
+		fmt.Printf("\nConfiguration Summary:\n")
+		fmt.Printf("  Sources: %d\n", s.Sources)
+		fmt.Printf("  Targets: %d\n", s.Targets)
+		fmt.Printf("  Dynamic Targets: %d\n", s.DynamicTargets)
+		fmt.Printf("  Vault Address: %s\n", s.VaultAddress)
+		fmt.Printf("  AWS Region: %s\n", s.AWSRegion)
+		fmt.Printf("  Control Tower: %v\n", s.ControlTower)
+
+		fmt.Printf("\nDependency Levels:\n")
+		for i, level := range s.Levels {
+			fmt.Printf("  Level %d: %v\n", i, level)
+		}
+	}
+	if report.awsSummary != "" {
+		fmt.Printf("\n%s", report.awsSummary)
 	}
 
-	l.Info("Validation completed successfully")
-	fmt.Println("\n✅ All validations passed")
-	return nil
+	if report.Valid {
+		fmt.Println("\n✅ All validations passed")
+	}
+}
+
+// printValidationGitHub emits workflow commands that annotate the config file
+func printValidationGitHub(report *validationReport) {
+	for _, c := range report.Checks {
+		switch c.Status {
+		case checkFail:
+			level := "error"
+			if c.Severity == "warning" {
+				level = "warning"
+			}
+			fmt.Printf("::%s file=%s%s,title=%s::%s\n", level, escapeProperty(report.Config), annotationPosition(c),
+				escapeProperty("vss validate ("+c.Name+")"), escapeData(c.Message))
+		case checkWarn:
+			fmt.Printf("::warning file=%s,title=%s::%s\n", escapeProperty(report.Config),
+				escapeProperty("vss validate ("+c.Name+")"), escapeData(c.Message))
+		}
+	}
+	if report.Valid {
+		fmt.Println("::notice title=vss validate::Configuration is valid")
+	}
+}
+
+// annotationPosition returns the line/col properties of a workflow command
+func annotationPosition(c validationCheck) string {
+	if c.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf(",line=%d,col=%d", c.Line, c.Column)
+}
+
+// Workflow commands end at a newline and their properties at , or ::, so
+// those characters are percent-encoded as the runner expects
+var (
+	dataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	propertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	return dataEscaper.Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	return propertyEscaper.Replace(s)
 }
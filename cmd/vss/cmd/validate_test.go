@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildValidationReport(t *testing.T) {
+	valid := writeCmdTestConfig(t, `
+vault:
+  address: https://vault.example.com
+sources:
+  analytics:
+    vault:
+      mount: analytics
+merge_store:
+  vault:
+    mount: merged
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+    imports: [analytics]
+  Serverless_Prod:
+    account_id: "222222222222"
+    imports: [Serverless_Stg]
+`)
+	report := buildValidationReport(context.Background(), valid, false, true)
+	assert.True(t, report.Valid)
+	require.NotNil(t, report.Summary)
+	assert.Equal(t, 2, report.Summary.Targets)
+	assert.Equal(t, [][]string{{}, {"Serverless_Stg"}, {"Serverless_Prod"}}, report.Summary.Levels)
+
+	statuses := make(map[string]string)
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	assert.Equal(t, map[string]string{
+		"load":           checkPass,
+		"structure":      checkPass,
+		"graph":          checkPass,
+		"freeze_windows": checkPass,
+		"aws":            checkSkip,
+	}, statuses)
+
+	invalid := writeCmdTestConfig(t, `
+vault:
+  address: https://vault.example.com
+merge_store:
+  vault:
+    mount: merged
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+    imports: [missing]
+`)
+	report = buildValidationReport(context.Background(), invalid, false, true)
+	assert.False(t, report.Valid)
+	assert.Nil(t, report.Summary)
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	var decoded struct {
+		Valid  bool `json:"valid"`
+		Checks []struct {
+			Name     string `json:"name"`
+			Status   string `json:"status"`
+			Severity string `json:"severity"`
+			Message  string `json:"message"`
+		} `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.False(t, decoded.Valid)
+	last := decoded.Checks[len(decoded.Checks)-1]
+	assert.Equal(t, "structure", last.Name)
+	assert.Equal(t, "fail", last.Status)
+	assert.Equal(t, "error", last.Severity)
+	assert.Contains(t, last.Message, `import "missing" not found`)
+}
+
+func TestBuildValidationReportStrict(t *testing.T) {
+	path := writeCmdTestConfig(t, `
+vault:
+  address: https://vault.example.com
+merge_stor:
+  vault:
+    mount: merged
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+`)
+	report := buildValidationReport(context.Background(), path, false, true)
+	assert.False(t, report.Valid)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, validationCheck{
+		Name:     "unknown_key",
+		Status:   checkFail,
+		Severity: "error",
+		Message:  `unknown key "merge_stor"`,
+		Line:     4,
+		Column:   1,
+	}, report.Checks[0])
+
+	// Without strict mode the typo is only logged and the merge store goes missing
+	report = buildValidationReport(context.Background(), path, false, false)
+	assert.False(t, report.Valid)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, checkPass, report.Checks[0].Status)
+	assert.Equal(t, "structure", report.Checks[1].Name)
+	assert.Contains(t, report.Checks[1].Message, "merge_store")
+}
+
+func TestEscapeAnnotation(t *testing.T) {
+	msg := "target \"Prod\": 100% invalid\r\nsee docs"
+	assert.Equal(t, "target \"Prod\": 100%25 invalid%0D%0Asee docs", escapeData(msg))
+	assert.Equal(t, "configs/a%2Cb%3A1.yaml", escapeProperty("configs/a,b:1.yaml"))
+	assert.Equal(t, "vss validate (load)", escapeProperty("vss validate (load)"))
+}
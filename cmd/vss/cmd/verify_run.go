@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyPublicKey      string
+	verifyRegion         string
+	verifyBundle         string
+	verifyCertIdentity   string
+	verifyCertOIDCIssuer string
+	verifyBinary         string
+)
+
+var verifyRunCmd = &cobra.Command{
+	Use:   "verify-run <manifest>",
+	Short: "Verify a signed pipeline run manifest or inventory",
+	Long: `Verifies the signature of a run manifest written by ` + "`vss pipeline`" + ` when
+pipeline.manifest is configured, then prints which vss binary and config file
+produced the run and the changes it made. Inventories written by
+` + "`vss inventory`" + ` are verified the same way.
+
+KMS-signed manifests are verified with kms:Verify, or offline with --public-key
+(the DER or PEM output of ` + "`aws kms get-public-key`" + `). Cosign-signed manifests are
+verified with the cosign CLI against the expected signing identity.
+
+--config and --binary additionally fail unless the given files hash to the
+config and binary recorded in the manifest.
+
+Examples:
+  # KMS signature, checked by KMS
+  vss verify-run /var/lib/vss/manifests/20250101T120000.000000000Z.manifest.json
+
+  # KMS signature, checked offline
+  vss verify-run run.manifest.json --public-key vss-manifests.pem
+
+  # Keyless signature from GitHub Actions, and the config it claims to use
+  vss verify-run run.manifest.json \
+    --certificate-identity https://github.com/org/secrets/.github/workflows/sync.yml@refs/heads/main \
+    --certificate-oidc-issuer https://token.actions.githubusercontent.com \
+    --config config.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVerifyRun,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyRunCmd)
+
+	verifyRunCmd.Flags().StringVar(&verifyPublicKey, "public-key", "", "verify KMS signatures offline with this public key file")
+	verifyRunCmd.Flags().StringVar(&verifyRegion, "region", "", "AWS region of the signing key (default: from the AWS environment)")
+	verifyRunCmd.Flags().StringVar(&verifyBundle, "bundle", "", "Sigstore bundle of a cosign-signed manifest (default: <manifest>.sigstore.json)")
+	verifyRunCmd.Flags().StringVar(&verifyCertIdentity, "certificate-identity", "", "expected signer identity of a cosign-signed manifest")
+	verifyRunCmd.Flags().StringVar(&verifyCertOIDCIssuer, "certificate-oidc-issuer", "", "expected OIDC issuer of a cosign-signed manifest")
+	verifyRunCmd.Flags().StringVar(&verifyBinary, "binary", "", "fail unless this vss binary matches the manifest")
+	verifyRunCmd.MarkFlagsRequiredTogether("certificate-identity", "certificate-oidc-issuer")
+}
+
+func runVerifyRun(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	path := args[0]
+	sm, err := pipeline.LoadSignedManifest(path)
+	if err != nil {
+		return err
+	}
+	if sm.PayloadType != pipeline.ManifestPayloadType && sm.PayloadType != pipeline.InventoryPayloadType {
+		return fmt.Errorf("%s is not a vss run manifest or inventory", path)
+	}
+
+	signer, err := verifyManifestSignature(ctx, path, sm)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Signature: valid (%s)\n", signer)
+	if sm.PayloadType == pipeline.InventoryPayloadType {
+		inv, err := sm.Inventory()
+		if err != nil {
+			return err
+		}
+		printInventorySummary(out, inv)
+		return nil
+	}
+	m, err := sm.Manifest()
+	if err != nil {
+		return err
+	}
+	printManifest(out, m)
+
+	// --config is only compared when given, not its config.yaml default
+	if cmd.Flags().Changed("config") {
+		if err := matchDigest("config", cfgFile, m.Config.SHA256); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Config %s matches\n", cfgFile)
+	}
+	if verifyBinary != "" {
+		if err := matchDigest("binary", verifyBinary, m.Binary.SHA256); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Binary %s matches\n", verifyBinary)
+	}
+	return nil
+}
+
+// verifyManifestSignature checks the manifest's KMS signatures or cosign
+// bundle and describes the signer
+func verifyManifestSignature(ctx context.Context, path string, sm *pipeline.SignedManifest) (string, error) {
+	if len(sm.Signatures) == 0 {
+		if verifyCertIdentity == "" {
+			return "", usageErrorf("manifest is signed with cosign: --certificate-identity and --certificate-oidc-issuer are required")
+		}
+		bundle := verifyBundle
+		if bundle == "" {
+			bundle = pipeline.CosignBundlePath(path)
+		}
+		if err := pipeline.VerifyManifestCosign(ctx, path, bundle, verifyCertIdentity, verifyCertOIDCIssuer); err != nil {
+			return "", err
+		}
+		return "cosign " + verifyCertIdentity, nil
+	}
+
+	signer := "kms " + sm.Signatures[0].KeyID
+	if verifyPublicKey != "" {
+		key, err := os.ReadFile(verifyPublicKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to read public key: %w", err)
+		}
+		return signer, pipeline.VerifyManifestPublicKey(sm, key)
+	}
+	var opts []func(*config.LoadOptions) error
+	if verifyRegion != "" {
+		opts = append(opts, config.WithRegion(verifyRegion))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return signer, pipeline.VerifyManifestKMS(ctx, kms.NewFromConfig(awsCfg), sm)
+}
+
+// matchDigest fails unless the file hashes to want
+func matchDigest(what, path, want string) error {
+	got, err := pipeline.DigestFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", what, err)
+	}
+	if got.SHA256 != want {
+		return fmt.Errorf("%s %s does not match the manifest: sha256 %s, manifest has %s", what, path, got.SHA256, want)
+	}
+	return nil
+}
+
+func printManifest(out io.Writer, m *pipeline.RunManifest) {
+	mode := "apply"
+	if m.Run.DryRun {
+		mode = "dry run"
+	}
+	failed := 0
+	for _, r := range m.Run.Results {
+		if !r.Success {
+			failed++
+		}
+	}
+	fmt.Fprintf(out, "Run:       %s %s (%s), %d results, %d failed\n", m.Run.ID, m.Run.Operation, mode, len(m.Run.Results), failed)
+	if m.Operator != "" {
+		fmt.Fprintf(out, "Operator:  %s\n", m.Operator)
+	}
+	fmt.Fprintf(out, "Binary:    vss %s (%s, %s, fips %s) sha256:%s\n", m.Binary.Version, m.Binary.GoVersion, m.Binary.Platform, m.Binary.FIPS, m.Binary.SHA256)
+	fmt.Fprintf(out, "Config:    %s sha256:%s\n", m.Config.Path, m.Config.SHA256)
+	if m.Changes != nil {
+		s := m.Changes.Summary
+		fmt.Fprintf(out, "Changes:   %d added, %d modified, %d removed, %d unchanged\n", s.Added, s.Modified, s.Removed, s.Unchanged)
+	}
+	if m.Run.Error != "" {
+		fmt.Fprintf(out, "Error:     %s\n", m.Run.Error)
+	}
+}
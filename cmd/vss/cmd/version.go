@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/jbcom/secretsync/internal/fips"
+	"github.com/jbcom/secretsync/internal/wasm"
+	"github.com/jbcom/secretsync/pkg/driver"
+	"github.com/jbcom/secretsync/stores/grpcstore"
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set by release builds with
+//
+//	-ldflags "-X github.com/jbcom/secretsync/cmd/vss/cmd.version=v1.4.0
+//	          -X github.com/jbcom/secretsync/cmd/vss/cmd.commit=<sha>
+//	          -X github.com/jbcom/secretsync/cmd/vss/cmd.buildDate=<RFC 3339>"
+//
+// Local builds fall back to the VCS stamp Go embeds in the binary.
+var (
+	// version is the vss release, "dev" for local builds
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the vss version",
+	Long: `Prints the vss version, the commit and date it was built from, the Go
+toolchain and platform, the drivers compiled in and the versions of the plugin
+APIs (the grpc SecretStore service and the wasm plugin ABI) it speaks.
+
+--format json prints the same as a JSON object for fleet inventory.
+
+With --fips, also reports the FIPS 140-3 crypto module in use and runs the
+startup self-check, exiting with an error unless the binary is in FIPS mode.
+
+Examples:
+  vss version
+  vss version --format json
+  vss version --fips`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if versionFormat != "text" && versionFormat != "json" {
+			return usageErrorf("unknown --format %q (expected text or json)", versionFormat)
+		}
+		return nil
+	},
+	RunE: runVersion,
+}
+
+var (
+	versionFIPS   bool
+	versionFormat string
+)
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().BoolVar(&versionFIPS, "fips", false, "report FIPS mode and run the FIPS self-check")
+	versionCmd.Flags().StringVar(&versionFormat, "format", "text", "output format (text, json)")
+}
+
+// VersionInfo describes a vss binary
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	// Drivers are the store drivers compiled in
+	Drivers []string `json:"drivers"`
+	// PluginAPIs maps each out-of-process plugin interface to the version
+	// this binary speaks; plugins must implement the same version
+	PluginAPIs map[string]string `json:"plugin_apis"`
+	FIPS       *FIPSInfo         `json:"fips,omitempty"`
+}
+
+// FIPSInfo is the FIPS status reported by version --fips
+type FIPSInfo struct {
+	Mode      string `json:"mode"`
+	Required  bool   `json:"required"`
+	SelfCheck string `json:"self_check"`
+}
+
+// buildVersionInfo collects the build metadata, falling back to the VCS
+// stamp for builds without ldflags
+func buildVersionInfo() VersionInfo {
+	info := VersionInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		PluginAPIs: map[string]string{
+			"grpc": grpcstore.ServiceName,
+			"wasm": wasm.ABIVersion,
+		},
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	for _, d := range driver.DriverNames {
+		info.Drivers = append(info.Drivers, string(d))
+	}
+	return info
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	info := buildVersionInfo()
+	var checkErr error
+	if versionFIPS {
+		info.FIPS = &FIPSInfo{Mode: fips.Mode(), Required: fips.Required(), SelfCheck: "passed"}
+		if checkErr = fips.SelfCheck(); checkErr != nil {
+			info.FIPS.SelfCheck = "failed"
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if versionFormat == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			return err
+		}
+		return checkErr
+	}
+	printVersion(out, info)
+	return checkErr
+}
+
+func printVersion(out io.Writer, info VersionInfo) {
+	fmt.Fprintf(out, "vss %s (%s, %s)\n", info.Version, info.GoVersion, info.Platform)
+	if info.Commit != "" {
+		fmt.Fprintf(out, "Commit:     %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Fprintf(out, "Built:      %s\n", info.BuildDate)
+	}
+	fmt.Fprintf(out, "Drivers:    %s\n", strings.Join(info.Drivers, ", "))
+	fmt.Fprintf(out, "Plugins:    grpc %s, wasm abi %s\n", info.PluginAPIs["grpc"], info.PluginAPIs["wasm"])
+	if info.FIPS == nil {
+		return
+	}
+
+	fmt.Fprintf(out, "FIPS mode:  %s\n", info.FIPS.Mode)
+	fmt.Fprintf(out, "Required:   %t (%s)\n", info.FIPS.Required, fips.RequiredEnv)
+	fmt.Fprintf(out, "Self-check: %s\n", info.FIPS.SelfCheck)
+	if info.FIPS.SelfCheck == "passed" {
+		fmt.Fprintln(out, "TLS:        TLS 1.2+, ECDHE AES-GCM cipher suites, P-256/P-384")
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionJSON(t *testing.T) {
+	version, commit, buildDate = "v1.4.0", "0123abc", "2026-10-01T12:00:00Z"
+	t.Cleanup(func() { version, commit, buildDate = "dev", "", "" })
+
+	var out bytes.Buffer
+	versionCmd.SetOut(&out)
+	t.Cleanup(func() { versionCmd.SetOut(nil) })
+	versionFormat = "json"
+	t.Cleanup(func() { versionFormat = "text" })
+	require.NoError(t, runVersion(versionCmd, nil))
+
+	var info map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &info))
+	assert.Equal(t, "v1.4.0", info["version"])
+	assert.Equal(t, "0123abc", info["commit"])
+	assert.Equal(t, "2026-10-01T12:00:00Z", info["build_date"])
+	assert.Contains(t, info["drivers"], "aws")
+	assert.Equal(t, map[string]any{"grpc": "vss.secretstore.v1.SecretStore", "wasm": "v1"}, info["plugin_apis"])
+	assert.NotContains(t, info, "fips")
+}
@@ -11,8 +11,10 @@ import (
 	"syscall"
 
 	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/cmd/vss/cmd"
 	"github.com/jbcom/secretsync/internal/backend"
 	"github.com/jbcom/secretsync/internal/config"
+	"github.com/jbcom/secretsync/internal/fips"
 	"github.com/jbcom/secretsync/internal/metrics"
 	"github.com/jbcom/secretsync/internal/queue"
 	"github.com/jbcom/secretsync/internal/server"
@@ -37,6 +39,7 @@ func init() {
 	// set the log format
 	//log.SetFormatter(&log.JSONFormatter{})
 	backend.ManualTrigger = sync.ManualTrigger
+	backend.ManualTriggerAndWait = sync.ManualTriggerAndWait
 }
 
 func initQueue() error {
@@ -86,6 +89,13 @@ func cleanup() {
 }
 
 func main() {
+	// A subcommand (vss pipeline, vss version, ...) runs the pipeline CLI;
+	// flags alone start the operator and event server
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		cmd.Execute()
+		return
+	}
+
 	l := log.WithFields(log.Fields{
 		"action": "main",
 	})
@@ -105,6 +115,9 @@ func main() {
 	if config.Config.Log.Level != "" {
 		setLogLevelStr(config.Config.Log.Level, config.Config.Log.Format)
 	}
+	if err := fips.Init(); err != nil {
+		l.Fatal(err)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // Make sure all resources are cleaned up
 
@@ -0,0 +1,140 @@
+// Package awsrate limits AWS API calls per service across every AWS client in
+// the process, so the Secrets Manager store, discovery and the pipeline's own
+// calls share one budget. A service's rate is halved when AWS throttles it and
+// recovers gradually as calls succeed.
+package awsrate
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// slowdownFactor scales a service's rate when AWS throttles it
+	slowdownFactor = 0.5
+	// minFraction is the lowest share of the configured rate slowdowns reach
+	minFraction = 0.05
+	// recoverySteps is how many successful calls restore the configured rate
+	// from zero
+	recoverySteps = 20
+)
+
+// Config sets the request rates of AWS services
+type Config struct {
+	// RPS maps a service to its requests per second. Services are named as in
+	// the SDK (Secrets Manager, STS, SSO Admin), case, spaces and dashes
+	// ignored. Services without a rate are not limited.
+	RPS map[string]float64
+	// Burst is how many calls to a service may be made at once (default 1)
+	Burst int
+	// DisableAdaptive keeps the configured rates when AWS throttles
+	DisableAdaptive bool
+}
+
+var (
+	mu       sync.RWMutex
+	limiters map[string]*limiter
+)
+
+// Configure replaces the process's rate limits. A zero Config removes them.
+func Configure(cfg Config) {
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	next := make(map[string]*limiter, len(cfg.RPS))
+	for service, rps := range cfg.RPS {
+		if rps <= 0 {
+			continue
+		}
+		key := normalize(service)
+		next[key] = &limiter{
+			service:  key,
+			max:      rate.Limit(rps),
+			adaptive: !cfg.DisableAdaptive,
+			limiter:  rate.NewLimiter(rate.Limit(rps), burst),
+		}
+	}
+
+	mu.Lock()
+	limiters = next
+	mu.Unlock()
+}
+
+// Limit returns the current rate of service, or 0 when it is not limited
+func Limit(service string) float64 {
+	l := limiterFor(service)
+	if l == nil {
+		return 0
+	}
+	return float64(l.limiter.Limit())
+}
+
+func limiterFor(service string) *limiter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return limiters[normalize(service)]
+}
+
+// normalize maps service names such as "Secrets Manager" and
+// "secretsmanager" to the same key
+func normalize(service string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(service))
+}
+
+// limiter is one service's token bucket
+type limiter struct {
+	service  string
+	max      rate.Limit
+	adaptive bool
+	limiter  *rate.Limiter
+
+	mu sync.Mutex
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	return l.limiter.Wait(ctx)
+}
+
+// observe adapts the rate to the outcome of a call: throttling slows the
+// service down, success speeds it back up towards the configured rate
+func (l *limiter) observe(throttled bool) {
+	if !l.adaptive {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := l.limiter.Limit()
+	var next rate.Limit
+	switch {
+	case throttled:
+		next = max(current*slowdownFactor, l.max*minFraction)
+	case current < l.max:
+		next = min(current+l.max/recoverySteps, l.max)
+	default:
+		return
+	}
+	if next == current {
+		return
+	}
+	l.limiter.SetLimit(next)
+
+	if throttled {
+		log.WithFields(log.Fields{
+			"action":  "awsrate.observe",
+			"service": l.service,
+			"rps":     float64(next),
+		}).Warn("AWS throttled calls, slowing down")
+	} else if next == l.max {
+		log.WithFields(log.Fields{
+			"action":  "awsrate.observe",
+			"service": l.service,
+			"rps":     float64(next),
+		}).Info("AWS call rate recovered")
+	}
+}
@@ -0,0 +1,65 @@
+package awsrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigure(t *testing.T) {
+	defer Configure(Config{})
+
+	Configure(Config{RPS: map[string]float64{"secretsmanager": 20, "sso-admin": 5, "sts": 0}})
+	assert.Equal(t, 20.0, Limit("Secrets Manager"))
+	assert.Equal(t, 5.0, Limit("SSO Admin"))
+	assert.Zero(t, Limit("STS"))
+	assert.Zero(t, Limit("Organizations"))
+
+	Configure(Config{})
+	assert.Zero(t, Limit("secretsmanager"))
+}
+
+func TestAdaptiveSlowdown(t *testing.T) {
+	defer Configure(Config{})
+
+	Configure(Config{RPS: map[string]float64{"secretsmanager": 40}})
+	l := limiterFor("secretsmanager")
+	require.NotNil(t, l)
+
+	l.observe(true)
+	assert.Equal(t, 20.0, Limit("secretsmanager"))
+	l.observe(true)
+	assert.Equal(t, 10.0, Limit("secretsmanager"))
+
+	// Slowdowns stop at a floor
+	for range 10 {
+		l.observe(true)
+	}
+	assert.Equal(t, 2.0, Limit("secretsmanager"))
+
+	// Successful calls recover the configured rate, and no more
+	for range recoverySteps {
+		l.observe(false)
+	}
+	assert.Equal(t, 40.0, Limit("secretsmanager"))
+
+	Configure(Config{RPS: map[string]float64{"secretsmanager": 40}, DisableAdaptive: true})
+	limiterFor("secretsmanager").observe(true)
+	assert.Equal(t, 40.0, Limit("secretsmanager"))
+}
+
+func TestWait(t *testing.T) {
+	defer Configure(Config{})
+
+	Configure(Config{RPS: map[string]float64{"sts": 1}})
+	l := limiterFor("sts")
+	require.NoError(t, l.wait(context.Background()))
+
+	// The bucket is empty, so the next call waits past the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, l.wait(ctx))
+}
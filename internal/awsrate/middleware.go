@@ -0,0 +1,50 @@
+package awsrate
+
+import (
+	"context"
+	"errors"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// AddMiddleware adds the rate limiter to an AWS client's middleware stack. Use
+// it as an API option:
+//
+//	config.LoadDefaultConfig(ctx, config.WithAPIOptions([]func(*middleware.Stack) error{awsrate.AddMiddleware}))
+//
+// It runs once per attempt, after the SDK's own retries, so retried calls
+// also wait for a token.
+func AddMiddleware(stack *middleware.Stack) error {
+	if _, ok := stack.Finalize.Get("Retry"); ok {
+		return stack.Finalize.Insert(rateLimitMiddleware, "Retry", middleware.After)
+	}
+	return stack.Finalize.Add(rateLimitMiddleware, middleware.Before)
+}
+
+var rateLimitMiddleware = middleware.FinalizeMiddlewareFunc("AWSRateLimit", func(
+	ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	l := limiterFor(awsmiddleware.GetServiceID(ctx))
+	if l == nil {
+		return next.HandleFinalize(ctx, in)
+	}
+	if err := l.wait(ctx); err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+	}
+	out, md, err := next.HandleFinalize(ctx, in)
+	l.observe(throttled(err))
+	return out, md, err
+})
+
+// throttled reports whether err is an AWS throttling error
+func throttled(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	_, ok := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]
+	return ok
+}
@@ -2,6 +2,7 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/hashicorp/vault/sdk/logical"
@@ -13,8 +14,50 @@ import (
 var (
 	B             Backend
 	ManualTrigger func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) error
+	// ManualTriggerAndWait runs a sync to completion instead of queueing it.
+	// The completion is returned even when the sync fails.
+	ManualTriggerAndWait func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*SyncCompletion, error)
 )
 
+// PathResult is the outcome of syncing one source path to one destination path
+type PathResult struct {
+	SourcePath string
+	Driver     string
+	DestPath   string
+	Error      error
+}
+
+// SyncCompletion is the outcome of a sync that ran to completion
+type SyncCompletion struct {
+	Name  string
+	Paths []PathResult
+}
+
+// Failed returns the paths that failed to sync
+func (c *SyncCompletion) Failed() []PathResult {
+	var failed []PathResult
+	for _, p := range c.Paths {
+		if p.Error != nil {
+			failed = append(failed, p)
+		}
+	}
+	return failed
+}
+
+// Unverified returns the paths that were written but failed read-back verification
+func (c *SyncCompletion) Unverified() []PathResult {
+	var unverified []PathResult
+	for _, p := range c.Paths {
+		if errors.Is(p.Error, ErrVerificationFailed) {
+			unverified = append(unverified, p)
+		}
+	}
+	return unverified
+}
+
+// ErrVerificationFailed marks a path whose secret did not read back as written
+var ErrVerificationFailed = errors.New("read-back verification failed")
+
 const (
 	BackendTypeKubernetes BackendType = "kubernetes"
 	BackendTypeFile       BackendType = "file"
@@ -70,6 +70,33 @@ type NotificationsConfig struct {
 	Email   *EmailNotificationConfig   `json:"email" yaml:"email"`
 	Slack   *SlackNotificationConfig   `json:"slack" yaml:"slack"`
 	Webhook *WebhookNotificationConfig `json:"webhook" yaml:"webhook"`
+	// Routes send sync events to channels chosen by event, classification and
+	// tenant, in addition to the notifications configured on each sync
+	Routes []NotificationRoute `json:"routes" yaml:"routes"`
+	// Digest batches sync events into one notification per channel
+	Digest *NotificationDigestConfig `json:"digest" yaml:"digest"`
+}
+
+// NotificationDigestConfig batches the events a channel receives during the
+// window into a single digest (successes count, failures list and diff
+// summary). Pipeline runs send their digest as soon as the run completes.
+type NotificationDigestConfig struct {
+	Window string `json:"window" yaml:"window"`
+}
+
+// NotificationRoute sends the sync events it matches to its notifications.
+// Empty match lists match everything; tenants are glob patterns matched
+// against the sync's source Vault address. Routes are evaluated in order and
+// the first match wins unless it sets continue.
+type NotificationRoute struct {
+	Name            string                       `json:"name" yaml:"name"`
+	Events          []v1alpha1.NotificationEvent `json:"events" yaml:"events"`
+	Classifications []string                     `json:"classifications" yaml:"classifications"`
+	Tenants         []string                     `json:"tenants" yaml:"tenants"`
+	// Notifications are the channels to notify; their bodies are templates
+	// rendered with the notification message
+	Notifications []*v1alpha1.NotificationSpec `json:"notifications" yaml:"notifications"`
+	Continue      bool                         `json:"continue" yaml:"continue"`
 }
 
 type ServerSecurity struct {
@@ -0,0 +1,116 @@
+// Package configpr opens GitHub pull requests that change a pipeline config
+// file kept in a repository
+package configpr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+)
+
+// Request describes a pull request that edits one file
+type Request struct {
+	// Repo is owner/name
+	Repo string
+	// Base is the branch the pull request merges into (default: the
+	// repository's default branch)
+	Base string
+	// Branch is created from Base for the change
+	Branch string
+	// Path is the file within the repository
+	Path  string
+	Title string
+	Body  string
+	// Edit returns the file's new content
+	Edit func(content []byte) ([]byte, error)
+}
+
+// Client opens pull requests with a token
+type Client struct {
+	gh *github.Client
+}
+
+// NewClient returns a client authenticating with token. baseURL is the API
+// URL of a GitHub Enterprise Server, or empty for github.com.
+func NewClient(token, baseURL string) (*Client, error) {
+	if token == "" {
+		return nil, errors.New("a GitHub token is required to open a pull request")
+	}
+	gh := github.NewClient(nil).WithAuthToken(token)
+	if baseURL != "" {
+		var err error
+		if gh, err = gh.WithEnterpriseURLs(baseURL, baseURL); err != nil {
+			return nil, fmt.Errorf("failed to set GitHub URL: %w", err)
+		}
+	}
+	return &Client{gh: gh}, nil
+}
+
+// Open creates req.Branch, commits the edited file to it and opens a pull
+// request, returning its URL
+func (c *Client) Open(ctx context.Context, req Request) (string, error) {
+	owner, name, ok := strings.Cut(req.Repo, "/")
+	if !ok || owner == "" || name == "" {
+		return "", fmt.Errorf("invalid repository %q: must be owner/name", req.Repo)
+	}
+
+	base := req.Base
+	if base == "" {
+		repo, _, err := c.gh.Repositories.Get(ctx, owner, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to get repository: %w", err)
+		}
+		base = repo.GetDefaultBranch()
+	}
+	ref, _, err := c.gh.Git.GetRef(ctx, owner, name, "refs/heads/"+base)
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch %s: %w", base, err)
+	}
+
+	file, _, _, err := c.gh.Repositories.GetContents(ctx, owner, name, req.Path, &github.RepositoryContentGetOptions{Ref: base})
+	if err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", req.Path, err)
+	}
+	if file == nil {
+		return "", fmt.Errorf("%s is a directory", req.Path)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", req.Path, err)
+	}
+	edited, err := req.Edit([]byte(content))
+	if err != nil {
+		return "", err
+	}
+
+	_, _, err = c.gh.Git.CreateRef(ctx, owner, name, &github.Reference{
+		Ref:    github.String("refs/heads/" + req.Branch),
+		Object: &github.GitObject{SHA: ref.Object.SHA},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create branch %s: %w", req.Branch, err)
+	}
+	_, _, err = c.gh.Repositories.UpdateFile(ctx, owner, name, req.Path, &github.RepositoryContentFileOptions{
+		Message: github.String(req.Title),
+		Content: edited,
+		SHA:     file.SHA,
+		Branch:  github.String(req.Branch),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit %s: %w", req.Path, err)
+	}
+
+	pr, _, err := c.gh.PullRequests.Create(ctx, owner, name, &github.NewPullRequest{
+		Title: github.String(req.Title),
+		Head:  github.String(req.Branch),
+		Base:  github.String(base),
+		Body:  github.String(req.Body),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to open pull request: %w", err)
+	}
+	return pr.GetHTMLURL(), nil
+}
@@ -0,0 +1,85 @@
+package configpr
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	var branch, committed, prHead, prBase string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/repos/acme/infra", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"default_branch": "main"}`))
+	})
+	mux.HandleFunc("GET /api/v3/repos/acme/infra/git/ref/heads/main", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ref": "refs/heads/main", "object": {"sha": "abc123"}}`))
+	})
+	mux.HandleFunc("GET /api/v3/repos/acme/infra/contents/vss/config.yaml", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "main", r.URL.Query().Get("ref"))
+		json.NewEncoder(w).Encode(map[string]string{
+			"type": "file", "encoding": "base64", "sha": "file1",
+			"content": base64.StdEncoding.EncodeToString([]byte("targets:\n")),
+		})
+	})
+	mux.HandleFunc("POST /api/v3/repos/acme/infra/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Ref, SHA string }
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "abc123", body.SHA)
+		branch = body.Ref
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("PUT /api/v3/repos/acme/infra/contents/vss/config.yaml", func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Content, SHA, Branch string }
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "file1", body.SHA)
+		assert.Equal(t, "vss/onboard", body.Branch)
+		content, _ := base64.StdEncoding.DecodeString(body.Content)
+		committed = string(content)
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("POST /api/v3/repos/acme/infra/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Head, Base string }
+		json.NewDecoder(r.Body).Decode(&body)
+		prHead, prBase = body.Head, body.Base
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"html_url": "https://github.com/acme/infra/pull/7"}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := NewClient("token", srv.URL+"/")
+	require.NoError(t, err)
+	url, err := c.Open(context.Background(), Request{
+		Repo:   "acme/infra",
+		Branch: "vss/onboard",
+		Path:   "vss/config.yaml",
+		Title:  "Onboard account",
+		Edit: func(content []byte) ([]byte, error) {
+			return append(content, "  Sandbox: {}\n"...), nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/acme/infra/pull/7", url)
+	assert.Equal(t, "refs/heads/vss/onboard", branch)
+	assert.Equal(t, "targets:\n  Sandbox: {}\n", committed)
+	assert.Equal(t, "vss/onboard", prHead)
+	assert.Equal(t, "main", prBase)
+}
+
+func TestOpenInvalidRepo(t *testing.T) {
+	c, err := NewClient("token", "")
+	require.NoError(t, err)
+	_, err = c.Open(context.Background(), Request{Repo: "infra"})
+	assert.ErrorContains(t, err, "must be owner/name")
+
+	_, err = NewClient("", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,10 @@
+//go:build boringcrypto
+
+package fips
+
+// fipsonly restricts crypto/tls to FIPS-approved settings process-wide
+import _ "crypto/tls/fipsonly"
+
+func init() {
+	boring = true
+}
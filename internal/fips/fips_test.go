@@ -0,0 +1,40 @@
+package fips
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnownAnswerTests(t *testing.T) {
+	assert.NoError(t, knownAnswerTests())
+}
+
+func TestRestrictTLS(t *testing.T) {
+	cfg := restrictTLS(&tls.Config{ServerName: "vault.example.com", MinVersion: tls.VersionTLS10})
+	assert.Equal(t, "vault.example.com", cfg.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, CipherSuites, cfg.CipherSuites)
+	assert.Equal(t, CurvePreferences, cfg.CurvePreferences)
+
+	cfg = restrictTLS(&tls.Config{MinVersion: tls.VersionTLS13})
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	require.NotNil(t, restrictTLS(nil))
+}
+
+func TestInitRequired(t *testing.T) {
+	if Enabled() {
+		t.Skip("running in FIPS mode")
+	}
+	t.Setenv(RequiredEnv, "true")
+	assert.ErrorContains(t, Init(), "not running in FIPS mode")
+
+	t.Setenv(RequiredEnv, "false")
+	assert.NoError(t, Init())
+
+	// TLS settings are left alone outside FIPS mode
+	cfg := &tls.Config{MinVersion: tls.VersionTLS10}
+	assert.Same(t, cfg, TLSConfig(cfg))
+}
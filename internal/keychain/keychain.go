@@ -0,0 +1,47 @@
+// Package keychain stores CLI credentials, such as the Vault and Doppler
+// tokens saved by vss login, in the operating system's credential store: the
+// macOS Keychain, the Secret Service (GNOME Keyring, KWallet) on Linux, and
+// files encrypted with DPAPI on Windows, so they need not be exported in a
+// shell.
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Service groups vss's credentials in the store
+const Service = "vss"
+
+// ErrNotFound is returned for accounts with no stored credential
+var ErrNotFound = errors.New("credential not found")
+
+// ErrUnsupported is returned where no credential store is available
+var ErrUnsupported = errors.New("no OS credential store available")
+
+// Store keeps one secret per account
+type Store interface {
+	// Name describes the store in messages, e.g. "macOS Keychain"
+	Name() string
+	// Get returns an account's secret, or ErrNotFound
+	Get(account string) (string, error)
+	// Set stores an account's secret, replacing any stored before
+	Set(account, secret string) error
+	// Delete removes an account's secret, or returns ErrNotFound
+	Delete(account string) error
+}
+
+// Default returns the operating system's credential store
+func Default() Store {
+	return platformStore()
+}
+
+// validAccount rejects account names the store tools cannot take as a
+// single argument
+func validAccount(account string) error {
+	if account == "" || strings.ContainsAny(account, "\"'\\ \t\r\n") {
+		return fmt.Errorf("invalid account name %q", account)
+	}
+	return nil
+}
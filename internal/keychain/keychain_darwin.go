@@ -0,0 +1,68 @@
+package keychain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityNotFound is the exit code of security(1) for a missing item
+const securityNotFound = 44
+
+// keychainStore keeps generic passwords in the login keychain with
+// security(1)
+type keychainStore struct{}
+
+func platformStore() Store {
+	return keychainStore{}
+}
+
+func (keychainStore) Name() string {
+	return "macOS Keychain"
+}
+
+func (keychainStore) Get(account string) (string, error) {
+	if err := validAccount(account); err != nil {
+		return "", err
+	}
+	out, err := exec.Command("/usr/bin/security", "find-generic-password", "-s", Service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", securityError("read", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// Set passes the secret on stdin (security -i) rather than as an argument,
+// where other users could see it in the process list
+func (keychainStore) Set(account, secret string) error {
+	if err := validAccount(account); err != nil {
+		return err
+	}
+	cmd := exec.Command("/usr/bin/security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", Service, account, hex.EncodeToString([]byte(secret))))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store credential: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (keychainStore) Delete(account string) error {
+	if err := validAccount(account); err != nil {
+		return err
+	}
+	if err := exec.Command("/usr/bin/security", "delete-generic-password", "-s", Service, "-a", account).Run(); err != nil {
+		return securityError("delete", err)
+	}
+	return nil
+}
+
+// securityError maps security(1)'s missing item exit code to ErrNotFound
+func securityError(op string, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("failed to %s credential: %w", op, err)
+}
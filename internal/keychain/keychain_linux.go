@@ -0,0 +1,85 @@
+package keychain
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretServiceStore keeps secrets in the Secret Service (GNOME Keyring,
+// KWallet) with secret-tool(1) from libsecret
+type secretServiceStore struct{}
+
+func platformStore() Store {
+	return secretServiceStore{}
+}
+
+func (secretServiceStore) Name() string {
+	return "Secret Service"
+}
+
+// tool returns secret-tool's path, or ErrUnsupported without it
+func (secretServiceStore) tool() (string, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return "", fmt.Errorf("%w: install secret-tool (libsecret-tools) and a Secret Service such as GNOME Keyring", ErrUnsupported)
+	}
+	return path, nil
+}
+
+func (s secretServiceStore) Get(account string) (string, error) {
+	if err := validAccount(account); err != nil {
+		return "", err
+	}
+	tool, err := s.tool()
+	if err != nil {
+		return "", err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(tool, "lookup", "service", Service, "account", account)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	// secret-tool exits 1 with no output for a missing item
+	if err != nil && len(out) == 0 && stderr.Len() == 0 {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// Set passes the secret on stdin rather than as an argument, where other
+// users could see it in the process list
+func (s secretServiceStore) Set(account, secret string) error {
+	if err := validAccount(account); err != nil {
+		return err
+	}
+	tool, err := s.tool()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(tool, "store", "--label", Service+" "+account, "service", Service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store credential: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Delete looks the secret up first, since secret-tool clear succeeds whether
+// or not anything was removed
+func (s secretServiceStore) Delete(account string) error {
+	if _, err := s.Get(account); err != nil {
+		return err
+	}
+	tool, err := s.tool()
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command(tool, "clear", "service", Service, "account", account).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete credential: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !darwin && !linux && !windows
+
+package keychain
+
+// unsupportedStore is the store of platforms without a supported credential
+// store
+type unsupportedStore struct{}
+
+func platformStore() Store {
+	return unsupportedStore{}
+}
+
+func (unsupportedStore) Name() string {
+	return "no credential store"
+}
+
+func (unsupportedStore) Get(string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (unsupportedStore) Set(string, string) error {
+	return ErrUnsupported
+}
+
+func (unsupportedStore) Delete(string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,106 @@
+package keychain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// dpapiStore keeps each secret in a file under the user's config directory,
+// encrypted with DPAPI so only the same Windows user can decrypt it
+type dpapiStore struct{}
+
+func platformStore() Store {
+	return dpapiStore{}
+}
+
+func (dpapiStore) Name() string {
+	return "Windows DPAPI store"
+}
+
+// path returns the file an account's secret is kept in. Account names are
+// hex encoded, as they may contain characters file names cannot.
+func (dpapiStore) path(account string) (string, error) {
+	if err := validAccount(account); err != nil {
+		return "", err
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	return filepath.Join(dir, Service, "credentials", hex.EncodeToString([]byte(account))), nil
+}
+
+func (s dpapiStore) Get(account string) (string, error) {
+	path, err := s.path(account)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential: %w", err)
+	}
+	secret, err := dpapi(data, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt credential: %w", err)
+	}
+	return string(secret), nil
+}
+
+func (s dpapiStore) Set(account, secret string) error {
+	path, err := s.path(account)
+	if err != nil {
+		return err
+	}
+	data, err := dpapi([]byte(secret), true)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to store credential: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to store credential: %w", err)
+	}
+	return nil
+}
+
+func (s dpapiStore) Delete(account string) error {
+	path, err := s.path(account)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return nil
+}
+
+// dpapi encrypts or decrypts data for the current user, never prompting
+func dpapi(data []byte, encrypt bool) ([]byte, error) {
+	in := windows.DataBlob{Size: uint32(len(data)), Data: unsafe.SliceData(data)}
+	var out windows.DataBlob
+	var err error
+	if encrypt {
+		err = windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
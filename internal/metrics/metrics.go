@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -77,6 +78,60 @@ var (
 		Help:    "The duration of a manual sync",
 		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
 	}, []string{"namespace", "name"})
+	DestinationUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vault_secret_sync_destination_up",
+		Help: "Whether a pipeline destination passed its last health probe",
+	}, []string{"target", "destination"})
+	ScheduledRunLast = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vault_secret_sync_scheduled_last_run_timestamp_seconds",
+		Help: "When a target's last scheduled run finished",
+	}, []string{"target"})
+	ScheduledRunSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vault_secret_sync_scheduled_last_run_success",
+		Help: "Whether a target's last scheduled run succeeded",
+	}, []string{"target"})
+	ScheduledRunNext = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vault_secret_sync_scheduled_next_run_timestamp_seconds",
+		Help: "When a target's next scheduled run starts",
+	}, []string{"target"})
+	ScheduledRunsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_scheduled_runs_skipped_total",
+		Help: "Scheduled runs of a target skipped because its previous run was still going",
+	}, []string{"target"})
+	PipelineMergeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vault_secret_sync_pipeline_merge_duration_seconds",
+		Help:    "How long a target's merge phase took",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"target"})
+	PipelineSyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vault_secret_sync_pipeline_sync_duration_seconds",
+		Help:    "How long a target's sync phase took",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"target"})
+	PipelineMergeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_pipeline_merge_failures_total",
+		Help: "Failed merges of a target",
+	}, []string{"target"})
+	PipelineSyncFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_pipeline_sync_failures_total",
+		Help: "Failed syncs of a target",
+	}, []string{"target"})
+	PipelineTargets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_pipeline_targets_total",
+		Help: "Targets processed by a pipeline phase, by result",
+	}, []string{"target", "phase", "result"})
+	PipelineRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_pipeline_retries_total",
+		Help: "Retries of a target's merge or sync after a transient failure",
+	}, []string{"target", "phase"})
+	PipelineClaimConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_pipeline_claim_conflicts_total",
+		Help: "Targets found claimed by another controller",
+	}, []string{"target"})
+	PipelineDiscoveredTargetChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_pipeline_discovered_target_changes_total",
+		Help: "Discovered targets added, updated or removed by Organizations events",
+	}, []string{"change"})
 )
 
 type ServiceHealthStatus string
@@ -85,11 +140,17 @@ const (
 	ServiceHealthStatusOK       ServiceHealthStatus = "ok"
 	ServiceHealthStatusWarning  ServiceHealthStatus = "warning"
 	ServiceHealthStatusCritical ServiceHealthStatus = "critical"
+	// ServiceHealthStatusDegraded means the service is healthy but some
+	// destinations are down; syncs to the rest continue
+	ServiceHealthStatusDegraded ServiceHealthStatus = "degraded"
 )
 
 type ServiceHealth struct {
 	Services map[string]ServiceHealthStatus
-	Status   ServiceHealthStatus
+	// Destinations holds the probed health of pipeline destinations, keyed
+	// "<target>/<destination>"
+	Destinations map[string]ServiceHealthStatus `json:",omitempty"`
+	Status       ServiceHealthStatus
 }
 
 func init() {
@@ -98,6 +159,19 @@ func init() {
 	prometheus.MustRegister(SyncErrors)
 	prometheus.MustRegister(SyncsTotal)
 	prometheus.MustRegister(SyncStatus)
+	prometheus.MustRegister(DestinationUp)
+	prometheus.MustRegister(ScheduledRunLast)
+	prometheus.MustRegister(ScheduledRunSuccess)
+	prometheus.MustRegister(ScheduledRunNext)
+	prometheus.MustRegister(ScheduledRunsSkipped)
+	prometheus.MustRegister(PipelineMergeDuration)
+	prometheus.MustRegister(PipelineSyncDuration)
+	prometheus.MustRegister(PipelineMergeFailures)
+	prometheus.MustRegister(PipelineSyncFailures)
+	prometheus.MustRegister(PipelineTargets)
+	prometheus.MustRegister(PipelineRetries)
+	prometheus.MustRegister(PipelineClaimConflicts)
+	prometheus.MustRegister(PipelineDiscoveredTargetChanges)
 }
 
 func NewServiceHealth() *ServiceHealth {
@@ -117,6 +191,75 @@ func RegisterServiceHealth(name string, status ServiceHealthStatus) {
 	Health.Services[name] = status
 }
 
+// RegisterDestinationHealth records the probed health of a pipeline
+// destination. A down destination degrades the service without failing it.
+func RegisterDestinationHealth(target, destination string, up bool) {
+	healthMutex.Lock()
+	defer healthMutex.Unlock()
+	if Health == nil {
+		NewServiceHealth()
+	}
+	if Health.Destinations == nil {
+		Health.Destinations = make(map[string]ServiceHealthStatus)
+	}
+	status, value := ServiceHealthStatusOK, 1.0
+	if !up {
+		status, value = ServiceHealthStatusCritical, 0
+	}
+	Health.Destinations[target+"/"+destination] = status
+	DestinationUp.WithLabelValues(target, destination).Set(value)
+}
+
+// RegisterScheduledRun records when a target's scheduled run finished and
+// whether it succeeded
+func RegisterScheduledRun(target string, finished time.Time, success bool) {
+	ScheduledRunLast.WithLabelValues(target).Set(float64(finished.Unix()))
+	value := 0.0
+	if success {
+		value = 1
+	}
+	ScheduledRunSuccess.WithLabelValues(target).Set(value)
+}
+
+// RegisterPipelineResult records the outcome of one target's merge or sync
+// phase in a pipeline run
+func RegisterPipelineResult(target, phase string, success bool, duration time.Duration) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	PipelineTargets.WithLabelValues(target, phase, result).Inc()
+
+	switch phase {
+	case "merge":
+		PipelineMergeDuration.WithLabelValues(target).Observe(duration.Seconds())
+		if !success {
+			PipelineMergeFailures.WithLabelValues(target).Inc()
+		}
+	case "sync":
+		PipelineSyncDuration.WithLabelValues(target).Observe(duration.Seconds())
+		if !success {
+			PipelineSyncFailures.WithLabelValues(target).Inc()
+		}
+	}
+}
+
+// RegisterPipelineRetry records a retry of one target's merge or sync phase
+func RegisterPipelineRetry(target, phase string) {
+	PipelineRetries.WithLabelValues(target, phase).Inc()
+}
+
+// RegisterClaimConflict records a target found claimed by another controller
+func RegisterClaimConflict(target string) {
+	PipelineClaimConflicts.WithLabelValues(target).Inc()
+}
+
+// RegisterDiscoveredTargetChange records a discovered target added, updated
+// or removed between runs
+func RegisterDiscoveredTargetChange(change string) {
+	PipelineDiscoveredTargetChanges.WithLabelValues(change).Inc()
+}
+
 func DetermineOverallHealth() ServiceHealthStatus {
 	healthMutex.Lock()
 	defer healthMutex.Unlock()
@@ -133,6 +276,12 @@ func DetermineOverallHealth() ServiceHealthStatus {
 			return ServiceHealthStatusWarning
 		}
 	}
+	for _, v := range Health.Destinations {
+		if v != ServiceHealthStatusOK {
+			Health.Status = ServiceHealthStatusDegraded
+			return ServiceHealthStatusDegraded
+		}
+	}
 	Health.Status = ServiceHealthStatusOK
 	return ServiceHealthStatusOK
 }
@@ -148,7 +297,7 @@ func Start(port int, tls *srvutils.TLSConfig) {
 	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		h := DetermineOverallHealth()
 		switch h {
-		case ServiceHealthStatusOK:
+		case ServiceHealthStatusOK, ServiceHealthStatusDegraded:
 			w.WriteHeader(http.StatusOK)
 			json.NewEncoder(w).Encode(Health)
 		case ServiceHealthStatusWarning:
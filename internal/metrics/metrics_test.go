@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterPipelineResult(t *testing.T) {
+	RegisterPipelineResult("Metrics_Stg", "merge", true, 2*time.Second)
+	RegisterPipelineResult("Metrics_Stg", "sync", false, time.Second)
+	RegisterPipelineResult("Metrics_Stg", "sync", true, time.Second)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(PipelineTargets.WithLabelValues("Metrics_Stg", "merge", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(PipelineTargets.WithLabelValues("Metrics_Stg", "sync", "failure")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(PipelineTargets.WithLabelValues("Metrics_Stg", "sync", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(PipelineSyncFailures.WithLabelValues("Metrics_Stg")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(PipelineMergeFailures.WithLabelValues("Metrics_Stg")))
+	assert.Equal(t, 1, testutil.CollectAndCount(PipelineMergeDuration, "vault_secret_sync_pipeline_merge_duration_seconds"))
+}
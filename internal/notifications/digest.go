@@ -0,0 +1,221 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/config"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// digests buffers notifications while a digest window is configured
+var digests = &digestBuffer{send: send}
+
+// digestWindow returns the configured digest window, or 0 when digests are disabled
+func digestWindow() time.Duration {
+	if config.Config.Notifications == nil || config.Config.Notifications.Digest == nil {
+		return 0
+	}
+	window, err := time.ParseDuration(config.Config.Notifications.Digest.Window)
+	if err != nil || window <= 0 {
+		log.WithFields(log.Fields{
+			"action": "digestWindow",
+			"window": config.Config.Notifications.Digest.Window,
+		}).Warn("invalid notifications.digest.window, sending notifications individually")
+		return 0
+	}
+	return window
+}
+
+// FlushDigest sends the pending digest now rather than when its window ends.
+// diffSummary, if not empty, is included in the digest.
+func FlushDigest(ctx context.Context, diffSummary string) error {
+	return digests.flush(ctx, diffSummary)
+}
+
+type digestBuffer struct {
+	send func(context.Context, v1alpha1.NotificationMessage) error
+
+	mu       sync.Mutex
+	channels []*digestChannel
+	timer    *time.Timer
+}
+
+// digestChannel holds the messages batched for a single notification channel
+type digestChannel struct {
+	key      string
+	spec     *v1alpha1.NotificationSpec
+	messages []v1alpha1.NotificationMessage
+}
+
+// add batches message for each channel subscribed to its event, starting the
+// window if this is the first message of the digest
+func (d *digestBuffer) add(message v1alpha1.NotificationMessage, window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, spec := range message.VaultSecretSync.Spec.Notifications {
+		for _, channel := range splitChannels(spec) {
+			if !subscribed(channel, message.Event) {
+				continue
+			}
+			channel = withoutEvents(channel)
+			key := channelKey(channel)
+			var dc *digestChannel
+			for _, c := range d.channels {
+				if c.key == key {
+					dc = c
+					break
+				}
+			}
+			if dc == nil {
+				dc = &digestChannel{key: key, spec: channel}
+				d.channels = append(d.channels, dc)
+			}
+			dc.messages = append(dc.messages, message)
+		}
+	}
+	if d.timer == nil && len(d.channels) > 0 {
+		d.timer = time.AfterFunc(window, func() {
+			if err := d.flush(context.Background(), ""); err != nil {
+				log.WithError(err).Error("failed to send notification digest")
+			}
+		})
+	}
+}
+
+// flush sends one digest to each channel with pending messages
+func (d *digestBuffer) flush(ctx context.Context, diffSummary string) error {
+	d.mu.Lock()
+	channels := d.channels
+	d.channels = nil
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.mu.Unlock()
+
+	var errs []error
+	for _, c := range channels {
+		if err := d.send(ctx, digestMessage(c, diffSummary)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send %d/%d digests: %v", len(errs), len(channels), errs)
+	}
+	return nil
+}
+
+// digestMessage summarizes a channel's batched messages in one message
+func digestMessage(c *digestChannel, diffSummary string) v1alpha1.NotificationMessage {
+	digest := &v1alpha1.NotificationDigest{Diff: diffSummary}
+	// The digest is addressed to the owners of the failed syncs
+	var owners []string
+	for _, m := range c.messages {
+		if m.Event == v1alpha1.NotificationEventSyncFailure {
+			digest.Failures = append(digest.Failures, v1alpha1.DigestFailure{
+				Sync:    m.VaultSecretSync.Namespace + "/" + m.VaultSecretSync.Name,
+				Message: m.Message,
+				Owners:  m.Owners,
+			})
+			for _, owner := range m.Owners {
+				if !slices.Contains(owners, owner) {
+					owners = append(owners, owner)
+				}
+			}
+		} else {
+			digest.Successes++
+		}
+	}
+	event := v1alpha1.NotificationEventSyncSuccess
+	if len(digest.Failures) > 0 {
+		event = v1alpha1.NotificationEventSyncFailure
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d syncs succeeded, %d failed", digest.Successes, len(digest.Failures))
+	for _, f := range digest.Failures {
+		fmt.Fprintf(&sb, "\n- %s: %s", f.Sync, f.Message)
+		if len(f.Owners) > 0 {
+			fmt.Fprintf(&sb, " (owners: %s)", strings.Join(f.Owners, ", "))
+		}
+	}
+	if diffSummary != "" {
+		sb.WriteString("\n" + diffSummary)
+	}
+
+	first := c.messages[0].VaultSecretSync
+	spec := withDefaultEvent(c.spec, event)
+	return v1alpha1.NotificationMessage{
+		Event:   event,
+		Message: sb.String(),
+		Owners:  owners,
+		Digest:  digest,
+		VaultSecretSync: v1alpha1.VaultSecretSync{
+			ObjectMeta: metav1.ObjectMeta{Name: "digest", Namespace: first.Namespace},
+			Spec: v1alpha1.VaultSecretSyncSpec{
+				Source:        first.Spec.Source,
+				Notifications: []*v1alpha1.NotificationSpec{spec},
+			},
+		},
+	}
+}
+
+// splitChannels returns one spec per channel configured in spec
+func splitChannels(spec *v1alpha1.NotificationSpec) []*v1alpha1.NotificationSpec {
+	if spec == nil {
+		return nil
+	}
+	var out []*v1alpha1.NotificationSpec
+	if spec.Webhook != nil {
+		out = append(out, &v1alpha1.NotificationSpec{Webhook: spec.Webhook})
+	}
+	if spec.Email != nil {
+		out = append(out, &v1alpha1.NotificationSpec{Email: spec.Email})
+	}
+	if spec.Slack != nil {
+		out = append(out, &v1alpha1.NotificationSpec{Slack: spec.Slack})
+	}
+	return out
+}
+
+func subscribed(channel *v1alpha1.NotificationSpec, event v1alpha1.NotificationEvent) bool {
+	var events []v1alpha1.NotificationEvent
+	switch {
+	case channel.Webhook != nil:
+		events = channel.Webhook.Events
+	case channel.Email != nil:
+		events = channel.Email.Events
+	case channel.Slack != nil:
+		events = channel.Slack.Events
+	}
+	return slices.Contains(events, event)
+}
+
+// withoutEvents copies channel with its events cleared, so the digest decides
+// which event it is sent for
+func withoutEvents(channel *v1alpha1.NotificationSpec) *v1alpha1.NotificationSpec {
+	c := withDefaultEvent(channel, "")
+	if c.Webhook != nil {
+		c.Webhook.Events = nil
+	}
+	if c.Email != nil {
+		c.Email.Events = nil
+	}
+	if c.Slack != nil {
+		c.Slack.Events = nil
+	}
+	return c
+}
+
+func channelKey(channel *v1alpha1.NotificationSpec) string {
+	b, _ := json.Marshal(channel)
+	return string(b)
+}
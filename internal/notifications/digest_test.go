@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []v1alpha1.NotificationMessage
+}
+
+func (r *recordingSender) send(_ context.Context, message v1alpha1.NotificationMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, message)
+	return nil
+}
+
+func (r *recordingSender) messages() []v1alpha1.NotificationMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]v1alpha1.NotificationMessage(nil), r.sent...)
+}
+
+func digestTestMessage(name string, event v1alpha1.NotificationEvent, specs ...*v1alpha1.NotificationSpec) v1alpha1.NotificationMessage {
+	return v1alpha1.NotificationMessage{
+		Event:   event,
+		Message: "sync " + string(event),
+		VaultSecretSync: v1alpha1.VaultSecretSync{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "pipeline"},
+			Spec:       v1alpha1.VaultSecretSyncSpec{Notifications: specs},
+		},
+	}
+}
+
+func TestDigestBatchesPerChannel(t *testing.T) {
+	rec := &recordingSender{}
+	d := &digestBuffer{send: rec.send}
+
+	url := "https://hooks.slack.example/sync"
+	slack := &v1alpha1.NotificationSpec{Slack: &v1alpha1.SlackNotification{
+		URL:    &url,
+		Events: []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncSuccess, v1alpha1.NotificationEventSyncFailure},
+	}}
+	pager := &v1alpha1.NotificationSpec{Webhook: &v1alpha1.WebhookNotification{
+		URL:    "https://pager.example/enqueue",
+		Events: []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure},
+	}}
+
+	for i := 0; i < 300; i++ {
+		d.add(digestTestMessage(fmt.Sprintf("sync-%d", i), v1alpha1.NotificationEventSyncSuccess, slack, pager), time.Hour)
+	}
+	d.add(digestTestMessage("sync-bad", v1alpha1.NotificationEventSyncFailure, slack, pager), time.Hour)
+	require.NoError(t, d.flush(context.Background(), "CHANGES: +1 -0 ~2 =297 (total: 300)"))
+
+	sent := rec.messages()
+	require.Len(t, sent, 2)
+
+	slackDigest := sent[0]
+	assert.Equal(t, v1alpha1.NotificationEventSyncFailure, slackDigest.Event)
+	assert.Equal(t, 300, slackDigest.Digest.Successes)
+	assert.Equal(t, []v1alpha1.DigestFailure{{Sync: "pipeline/sync-bad", Message: "sync failure"}}, slackDigest.Digest.Failures)
+	assert.Equal(t, "300 syncs succeeded, 1 failed\n- pipeline/sync-bad: sync failure\nCHANGES: +1 -0 ~2 =297 (total: 300)", slackDigest.Message)
+	require.Len(t, slackDigest.VaultSecretSync.Spec.Notifications, 1)
+	assert.Equal(t, []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure}, slackDigest.VaultSecretSync.Spec.Notifications[0].Slack.Events)
+
+	// The pager only subscribed to failures, so its digest has no successes
+	pagerDigest := sent[1]
+	assert.Equal(t, "https://pager.example/enqueue", pagerDigest.VaultSecretSync.Spec.Notifications[0].Webhook.URL)
+	assert.Equal(t, 0, pagerDigest.Digest.Successes)
+	assert.Len(t, pagerDigest.Digest.Failures, 1)
+
+	// Flushing again sends nothing
+	require.NoError(t, d.flush(context.Background(), ""))
+	assert.Len(t, rec.messages(), 2)
+}
+
+func TestDigestWindow(t *testing.T) {
+	rec := &recordingSender{}
+	d := &digestBuffer{send: rec.send}
+
+	url := "https://hooks.slack.example/sync"
+	slack := &v1alpha1.NotificationSpec{Slack: &v1alpha1.SlackNotification{
+		URL:    &url,
+		Events: []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncSuccess},
+	}}
+	d.add(digestTestMessage("sync-a", v1alpha1.NotificationEventSyncSuccess, slack), 50*time.Millisecond)
+	d.add(digestTestMessage("sync-b", v1alpha1.NotificationEventSyncSuccess, slack), 50*time.Millisecond)
+	// Unsubscribed events are not batched
+	d.add(digestTestMessage("sync-c", v1alpha1.NotificationEventSyncFailure, slack), 50*time.Millisecond)
+
+	assert.Eventually(t, func() bool { return len(rec.messages()) == 1 }, time.Second, 10*time.Millisecond)
+	digest := rec.messages()[0]
+	assert.Equal(t, v1alpha1.NotificationEventSyncSuccess, digest.Event)
+	assert.Equal(t, 2, digest.Digest.Successes)
+	assert.Empty(t, digest.Digest.Failures)
+}
@@ -15,7 +15,14 @@ import (
 func createEmail(message v1alpha1.NotificationMessage, email v1alpha1.EmailNotification) (*gomail.Message, error) {
 	l := log.WithFields(log.Fields{"action": "createEmail"})
 	l.Debugf("creating email notification: %v", email)
-	if email.To == "" {
+	var to []string
+	if email.To != "" {
+		to = append(to, email.To)
+	}
+	if email.ToOwners {
+		to = append(to, ownerEmails(message.Owners)...)
+	}
+	if len(to) == 0 {
 		return nil, fmt.Errorf("email notification is missing required 'To' field")
 	}
 	if config.Config.Notifications == nil {
@@ -52,7 +59,7 @@ func createEmail(message v1alpha1.NotificationMessage, email v1alpha1.EmailNotif
 	l.Debugf("email message payload: %v", mp)
 	m := gomail.NewMessage()
 	m.SetHeader("From", email.From)
-	m.SetHeader("To", email.To)
+	m.SetHeader("To", to...)
 	m.SetHeader("Subject", sub)
 	m.SetBody("text/html", mp)
 	l.Debugf("email notification created: %+v", m)
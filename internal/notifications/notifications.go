@@ -3,10 +3,12 @@ package notifications
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 
 	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/config"
 	"github.com/jbcom/secretsync/internal/kube"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
@@ -88,10 +90,36 @@ func Trigger(ctx context.Context, message v1alpha1.NotificationMessage) error {
 			return fmt.Errorf("failed to handle notifications template: %w", err)
 		}
 	}
+	if message.Owners == nil {
+		message.Owners = syncOwners(message.VaultSecretSync)
+	}
+	if config.Config.Notifications != nil && len(config.Config.Notifications.Routes) > 0 {
+		routed := routedNotifications(config.Config.Notifications.Routes, message)
+		l.Debugf("%d notifications matched by routes", len(routed))
+		// copy so routed channels never leak into the sync config
+		message.VaultSecretSync.Spec.Notifications = append(slices.Clip(message.VaultSecretSync.Spec.Notifications), routed...)
+	}
 	if len(message.VaultSecretSync.Spec.Notifications) == 0 {
 		l.Debug("no notifications configured")
 		return nil
 	}
+	if window := digestWindow(); window > 0 {
+		l.Debug("adding notification to digest")
+		digests.add(message, window)
+		return nil
+	}
+	return send(ctx, message)
+}
+
+// send delivers message to each of its notification channels
+func send(ctx context.Context, message v1alpha1.NotificationMessage) error {
+	l := log.WithFields(log.Fields{
+		"pkg":           "notifications",
+		"action":        "notifications.send",
+		"syncConfig":    message.VaultSecretSync.Name,
+		"syncNamespace": message.VaultSecretSync.Namespace,
+		"notifications": len(message.VaultSecretSync.Spec.Notifications),
+	})
 	wg := &sync.WaitGroup{}
 	var mu sync.Mutex
 	var errs []error
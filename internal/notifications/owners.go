@@ -0,0 +1,34 @@
+package notifications
+
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+)
+
+// syncOwners returns the owners recorded in the sync's OwnersAnnotation
+func syncOwners(sync v1alpha1.VaultSecretSync) []string {
+	var owners []string
+	for _, owner := range strings.Split(sync.Annotations[v1alpha1.OwnersAnnotation], ",") {
+		if owner = strings.TrimSpace(owner); owner != "" {
+			owners = append(owners, owner)
+		}
+	}
+	return owners
+}
+
+// ownerEmails returns the owners that are email addresses; Slack handles and
+// team names are skipped
+func ownerEmails(owners []string) []string {
+	var emails []string
+	for _, owner := range owners {
+		if strings.HasPrefix(owner, "@") {
+			continue
+		}
+		if addr, err := mail.ParseAddress(owner); err == nil {
+			emails = append(emails, addr.Address)
+		}
+	}
+	return emails
+}
@@ -0,0 +1,42 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOwners(t *testing.T) {
+	sync := v1alpha1.VaultSecretSync{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{v1alpha1.OwnersAnnotation: "alice@example.com, @payments-oncall,platform-team,Bob <bob@example.com>"},
+	}}
+	owners := syncOwners(sync)
+	assert.Equal(t, []string{"alice@example.com", "@payments-oncall", "platform-team", "Bob <bob@example.com>"}, owners)
+	assert.Equal(t, []string{"alice@example.com", "bob@example.com"}, ownerEmails(owners))
+	assert.Empty(t, syncOwners(v1alpha1.VaultSecretSync{}))
+}
+
+func TestCreateEmailToOwners(t *testing.T) {
+	message := v1alpha1.NotificationMessage{
+		Event:   v1alpha1.NotificationEventSyncFailure,
+		Message: "sync failed",
+		Owners:  []string{"alice@example.com", "@payments-oncall"},
+	}
+
+	m, err := createEmail(message, v1alpha1.EmailNotification{To: "oncall@example.com", ToOwners: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"oncall@example.com", "alice@example.com"}, m.GetHeader("To"))
+
+	// Owners alone are enough recipients
+	m, err = createEmail(message, v1alpha1.EmailNotification{ToOwners: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice@example.com"}, m.GetHeader("To"))
+
+	// Without email owners there is no one to send to
+	message.Owners = []string{"@payments-oncall"}
+	_, err = createEmail(message, v1alpha1.EmailNotification{ToOwners: true})
+	assert.Error(t, err)
+}
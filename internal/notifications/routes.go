@@ -0,0 +1,92 @@
+package notifications
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/config"
+)
+
+// routedNotifications returns the notifications of every route that matches
+// message. Channels without events of their own fire for the matched event.
+func routedNotifications(routes []config.NotificationRoute, message v1alpha1.NotificationMessage) []*v1alpha1.NotificationSpec {
+	var specs []*v1alpha1.NotificationSpec
+	for _, route := range routes {
+		if !routeMatches(route, message) {
+			continue
+		}
+		for _, spec := range route.Notifications {
+			if spec != nil {
+				specs = append(specs, withDefaultEvent(spec, message.Event))
+			}
+		}
+		if !route.Continue {
+			break
+		}
+	}
+	return specs
+}
+
+func routeMatches(route config.NotificationRoute, message v1alpha1.NotificationMessage) bool {
+	if len(route.Events) > 0 && !slices.Contains(route.Events, message.Event) {
+		return false
+	}
+	sync := message.VaultSecretSync
+	if len(route.Classifications) > 0 && !slices.Contains(route.Classifications, sync.Labels[v1alpha1.ClassificationLabel]) {
+		return false
+	}
+	if len(route.Tenants) > 0 {
+		tenant := ""
+		if sync.Spec.Source != nil {
+			tenant = sync.Spec.Source.Address
+		}
+		if !slices.ContainsFunc(route.Tenants, func(pattern string) bool { return globMatch(pattern, tenant) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// globMatch matches s against a pattern in which * matches any run of characters
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(s)
+}
+
+// withDefaultEvent copies spec, subscribing channels without events to event
+func withDefaultEvent(spec *v1alpha1.NotificationSpec, event v1alpha1.NotificationEvent) *v1alpha1.NotificationSpec {
+	out := &v1alpha1.NotificationSpec{}
+	if spec.Webhook != nil {
+		w := *spec.Webhook
+		if len(w.Events) == 0 {
+			w.Events = []v1alpha1.NotificationEvent{event}
+		}
+		if w.Headers != nil {
+			w.Headers = make(map[string]string, len(spec.Webhook.Headers))
+			for k, v := range spec.Webhook.Headers {
+				w.Headers[k] = v
+			}
+		}
+		out.Webhook = &w
+	}
+	if spec.Email != nil {
+		e := *spec.Email
+		if len(e.Events) == 0 {
+			e.Events = []v1alpha1.NotificationEvent{event}
+		}
+		out.Email = &e
+	}
+	if spec.Slack != nil {
+		s := *spec.Slack
+		if len(s.Events) == 0 {
+			s.Events = []v1alpha1.NotificationEvent{event}
+		}
+		out.Slack = &s
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/config"
+	"github.com/jbcom/secretsync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func routeTestMessage(event v1alpha1.NotificationEvent, classification, tenant string) v1alpha1.NotificationMessage {
+	sync := v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Name: "sync-web", Namespace: "pipeline"},
+		Spec:       v1alpha1.VaultSecretSyncSpec{Source: &vault.VaultClient{Address: tenant}},
+	}
+	if classification != "" {
+		sync.Labels = map[string]string{v1alpha1.ClassificationLabel: classification}
+	}
+	return v1alpha1.NotificationMessage{Event: event, VaultSecretSync: sync}
+}
+
+func slackRoute(name, url string) *v1alpha1.NotificationSpec {
+	return &v1alpha1.NotificationSpec{Slack: &v1alpha1.SlackNotification{URL: &url, Body: name + ": {{ .Message }}"}}
+}
+
+func TestRoutedNotifications(t *testing.T) {
+	routes := []config.NotificationRoute{
+		{
+			Name:            "prod-failures",
+			Events:          []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure},
+			Classifications: []string{"production"},
+			Notifications: []*v1alpha1.NotificationSpec{
+				{Webhook: &v1alpha1.WebhookNotification{URL: "https://events.pagerduty.example/v2/enqueue"}},
+				slackRoute("prod", "https://hooks.slack.example/prod"),
+			},
+			Continue: true,
+		},
+		{
+			Name:          "prod-audit",
+			Tenants:       []string{"https://vault-prod.*"},
+			Notifications: []*v1alpha1.NotificationSpec{slackRoute("audit", "https://hooks.slack.example/audit")},
+		},
+		{
+			Name:          "catch-all",
+			Events:        []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure},
+			Notifications: []*v1alpha1.NotificationSpec{slackRoute("dev", "https://hooks.slack.example/dev")},
+		},
+	}
+
+	// Prod failures page on-call, then continue to the tenant audit route,
+	// which stops evaluation before the catch-all
+	specs := routedNotifications(routes, routeTestMessage(v1alpha1.NotificationEventSyncFailure, "production", "https://vault-prod.example.com"))
+	require.Len(t, specs, 3)
+	assert.Equal(t, "https://events.pagerduty.example/v2/enqueue", specs[0].Webhook.URL)
+	assert.Equal(t, []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure}, specs[0].Webhook.Events)
+	assert.Equal(t, "https://hooks.slack.example/prod", *specs[1].Slack.URL)
+	assert.Equal(t, "https://hooks.slack.example/audit", *specs[2].Slack.URL)
+
+	// Dev failures only reach the catch-all Slack channel
+	specs = routedNotifications(routes, routeTestMessage(v1alpha1.NotificationEventSyncFailure, "development", "https://vault-dev.example.com"))
+	require.Len(t, specs, 1)
+	assert.Equal(t, "https://hooks.slack.example/dev", *specs[0].Slack.URL)
+
+	// Dev successes are not routed anywhere
+	assert.Empty(t, routedNotifications(routes, routeTestMessage(v1alpha1.NotificationEventSyncSuccess, "development", "https://vault-dev.example.com")))
+
+	// Routed copies never modify the configured channels
+	assert.Empty(t, routes[0].Notifications[0].Webhook.Events)
+}
+
+func TestGlobMatch(t *testing.T) {
+	assert.True(t, globMatch("https://vault-prod.*", "https://vault-prod.example.com"))
+	assert.True(t, globMatch("*", ""))
+	assert.False(t, globMatch("https://vault-prod.*", "https://vault-dev.example.com"))
+	assert.False(t, globMatch("vault.example.com", "vaultxexample.com"))
+}
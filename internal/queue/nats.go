@@ -12,6 +12,7 @@ import (
 
 	"github.com/nats-io/nats.go"
 	"github.com/jbcom/secretsync/internal/event"
+	"github.com/jbcom/secretsync/internal/fips"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -48,9 +49,9 @@ func (q *NATSQueue) Start(params map[string]any) error {
 			}
 			caCertPool := x509.NewCertPool()
 			caCertPool.AppendCertsFromPEM(caCert)
-			opts.TLSConfig = &tls.Config{
+			opts.TLSConfig = fips.TLSConfig(&tls.Config{
 				RootCAs: caCertPool,
-			}
+			})
 		}
 		if q.TLS.Cert != "" && q.TLS.Key != "" {
 			cert, err := tls.LoadX509KeyPair(q.TLS.Cert, q.TLS.Key)
@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-redis/redis"
 	"github.com/jbcom/secretsync/internal/event"
+	"github.com/jbcom/secretsync/internal/fips"
 )
 
 type TLSConfig struct {
@@ -60,10 +61,10 @@ func (q *RedisQueue) Start(params map[string]any) error {
 		ReadTimeout: 30 * time.Second,
 	}
 	if q.TLS != nil {
-		opts.TLSConfig = &tls.Config{
+		opts.TLSConfig = fips.TLSConfig(&tls.Config{
 			RootCAs:    x509.NewCertPool(),
 			ServerName: q.Host,
-		}
+		})
 		if q.TLS.CA != "" {
 			caCert, err := os.ReadFile(q.TLS.CA)
 			if err != nil {
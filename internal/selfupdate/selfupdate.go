@@ -0,0 +1,344 @@
+// Package selfupdate replaces the running vss binary with a signed release.
+//
+// Release builds publish checksums.txt alongside the archives, signed
+// keylessly by the release workflow with a Sigstore bundle
+// (checksums.txt.sigstore.json). An update verifies the bundle with the
+// cosign CLI against the workflow's certificate identity, checks the archive
+// against checksums.txt and only then swaps the binary in place.
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Repository is the GitHub repository releases are published to
+	Repository = "jbcom/secretsync"
+	// CertificateIssuer is the OIDC issuer of the release signing certificate
+	CertificateIssuer = "https://token.actions.githubusercontent.com"
+
+	defaultAPIURL = "https://api.github.com"
+	checksumsFile = "checksums.txt"
+	bundleSuffix  = ".sigstore.json"
+	// maxDownload bounds every download; release archives are a few tens of MB
+	maxDownload = 256 << 20
+)
+
+// Release is a published vss release
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+func (r *Release) asset(name string) (Asset, error) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return Asset{}, fmt.Errorf("release %s has no asset %s", r.Tag, name)
+}
+
+// CertificateIdentity is the signing identity of a release: the release
+// workflow run for its tag
+func CertificateIdentity(tag string) string {
+	return fmt.Sprintf("https://github.com/%s/.github/workflows/ci.yml@refs/tags/%s", Repository, tag)
+}
+
+// ArchiveName is the name of the release archive for a platform, matching
+// the archive name_template in .goreleaser.yml
+func ArchiveName(tag, goos, goarch string) string {
+	arch := goarch
+	if arch == "amd64" {
+		arch = "x86_64"
+	}
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("secretsync_%s_%s_%s.%s", strings.TrimPrefix(tag, "v"), goos, arch, ext)
+}
+
+// runCosign runs the cosign CLI; tests replace it
+var runCosign = func(ctx context.Context, args ...string) error {
+	out, err := exec.CommandContext(ctx, "cosign", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cosign %s: %w: %s", args[0], err, out)
+	}
+	return nil
+}
+
+// Updater fetches and installs vss releases
+type Updater struct {
+	// APIURL is the GitHub API base URL (default https://api.github.com)
+	APIURL string
+	// Token authenticates GitHub API requests, raising the rate limit
+	Token  string
+	GOOS   string
+	GOARCH string
+
+	client *http.Client
+}
+
+// New creates an Updater for the running platform
+func New() *Updater {
+	return &Updater{
+		APIURL: defaultAPIURL,
+		GOOS:   runtime.GOOS,
+		GOARCH: runtime.GOARCH,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// Release looks up a release by tag, or the latest release when tag is empty
+func (u *Updater) Release(ctx context.Context, tag string) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(u.APIURL, "/"), Repository)
+	if tag != "" {
+		url = fmt.Sprintf("%s/repos/%s/releases/tags/%s", strings.TrimSuffix(u.APIURL, "/"), Repository, tag)
+	}
+	body, err := u.get(ctx, url, "application/vnd.github+json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up release: %w", err)
+	}
+	var rel Release
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	if rel.Tag == "" {
+		return nil, fmt.Errorf("failed to parse release: no tag_name")
+	}
+	return &rel, nil
+}
+
+// Download fetches the release's vss binary for the Updater's platform,
+// verifying the signature on checksums.txt and the archive's checksum
+func (u *Updater) Download(ctx context.Context, rel *Release) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action":  "selfupdate.Download",
+		"release": rel.Tag,
+	})
+	archiveName := ArchiveName(rel.Tag, u.GOOS, u.GOARCH)
+	archive, err := rel.asset(archiveName)
+	if err != nil {
+		return nil, err
+	}
+	checksums, err := rel.asset(checksumsFile)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := rel.asset(checksumsFile + bundleSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("%w; releases before signing was added cannot be installed with self-update", err)
+	}
+
+	sums, err := u.get(ctx, checksums.DownloadURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", checksumsFile, err)
+	}
+	bundleData, err := u.get(ctx, bundle.DownloadURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", bundle.Name, err)
+	}
+	if err := verifyChecksums(ctx, rel.Tag, sums, bundleData); err != nil {
+		return nil, err
+	}
+	l.Debug("Verified release signature")
+
+	want, err := checksumFor(sums, archiveName)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.get(ctx, archive.DownloadURL, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", archiveName, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", archiveName, got, want)
+	}
+	l.WithField("archive", archiveName).Debug("Verified archive checksum")
+
+	return extractBinary(archiveName, data, binaryName(u.GOOS))
+}
+
+// verifyChecksums checks the Sigstore bundle over checksums.txt with cosign
+func verifyChecksums(ctx context.Context, tag string, sums, bundle []byte) error {
+	dir, err := os.MkdirTemp("", "vss-selfupdate-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sumsPath := filepath.Join(dir, checksumsFile)
+	bundlePath := sumsPath + bundleSuffix
+	if err := os.WriteFile(sumsPath, sums, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", checksumsFile, err)
+	}
+	if err := os.WriteFile(bundlePath, bundle, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(bundlePath), err)
+	}
+	if err := runCosign(ctx, "verify-blob",
+		"--bundle", bundlePath,
+		"--certificate-identity", CertificateIdentity(tag),
+		"--certificate-oidc-issuer", CertificateIssuer,
+		sumsPath); err != nil {
+		return fmt.Errorf("release signature verification failed: %w", err)
+	}
+	return nil
+}
+
+// checksumFor finds a file's sha256 in checksums.txt ("<hex>  <name>" lines)
+func checksumFor(sums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsFile, name)
+}
+
+func binaryName(goos string) string {
+	if goos == "windows" {
+		return "vss.exe"
+	}
+	return "vss"
+}
+
+// extractBinary reads the named file from the root of a tar.gz or zip archive
+func extractBinary(archiveName string, data []byte, name string) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", archiveName, err)
+		}
+		for _, f := range zr.File {
+			if f.Name != name {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s in %s: %w", name, archiveName, err)
+			}
+			defer rc.Close()
+			return io.ReadAll(io.LimitReader(rc, maxDownload))
+		}
+		return nil, fmt.Errorf("%s does not contain %s", archiveName, name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", archiveName, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s does not contain %s", archiveName, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", archiveName, err)
+		}
+		if hdr.Typeflag == tar.TypeReg && hdr.Name == name {
+			return io.ReadAll(io.LimitReader(tr, maxDownload))
+		}
+	}
+}
+
+func (u *Updater) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if u.Token != "" && strings.HasPrefix(url, u.APIURL) {
+		req.Header.Set("Authorization", "Bearer "+u.Token)
+	}
+	client := u.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxDownload))
+}
+
+// Replace atomically replaces the executable at path with binary. The new
+// file is written next to it and renamed over it, so a failed update leaves
+// the old binary in place. Windows cannot overwrite a running executable, so
+// there the old binary is first moved aside to <path>.old.
+func Replace(path string, binary []byte) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	dir := filepath.Dir(resolved)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(resolved)+".new-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file in %s: %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0o755); err != nil {
+		return fmt.Errorf("failed to make new binary executable: %w", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := resolved + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(resolved, old); err != nil {
+			return fmt.Errorf("failed to move aside %s: %w", resolved, err)
+		}
+		if err := os.Rename(tmpPath, resolved); err != nil {
+			_ = os.Rename(old, resolved)
+			return fmt.Errorf("failed to replace %s: %w", resolved, err)
+		}
+		return nil
+	}
+	if err := os.Rename(tmpPath, resolved); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", resolved, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarGz(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"README.md", []byte("# secretsync")}, {name, content}} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o755, Size: int64(len(f.data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(f.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// releaseServer serves a v1.5.0 release for linux/amd64 from a fake GitHub
+func releaseServer(t *testing.T, archive []byte, sums string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	archiveName := ArchiveName("v1.5.0", "linux", "amd64")
+	files := map[string][]byte{
+		archiveName:                  archive,
+		checksumsFile:                []byte(sums),
+		checksumsFile + bundleSuffix: []byte(`{"mediaType":"application/vnd.dev.sigstore.bundle.v0.3+json"}`),
+	}
+	rel := Release{Tag: "v1.5.0", URL: "https://github.com/jbcom/secretsync/releases/tag/v1.5.0"}
+	for name := range files {
+		rel.Assets = append(rel.Assets, Asset{Name: name, DownloadURL: srv.URL + "/download/" + name})
+	}
+	mux.HandleFunc("/repos/jbcom/secretsync/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(rel)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[filepath.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	return srv
+}
+
+func TestArchiveName(t *testing.T) {
+	assert.Equal(t, "secretsync_1.5.0_linux_x86_64.tar.gz", ArchiveName("v1.5.0", "linux", "amd64"))
+	assert.Equal(t, "secretsync_1.5.0_darwin_arm64.tar.gz", ArchiveName("v1.5.0", "darwin", "arm64"))
+	assert.Equal(t, "secretsync_1.5.0_windows_x86_64.zip", ArchiveName("v1.5.0", "windows", "amd64"))
+}
+
+func TestDownload(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho vss v1.5.0\n")
+	archive := tarGz(t, "vss", binary)
+	sum := sha256.Sum256(archive)
+	sums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), ArchiveName("v1.5.0", "linux", "amd64"))
+
+	var cosignArgs []string
+	orig := runCosign
+	runCosign = func(_ context.Context, args ...string) error {
+		cosignArgs = args
+		signed, err := os.ReadFile(args[len(args)-1])
+		require.NoError(t, err)
+		assert.Equal(t, sums, string(signed))
+		return nil
+	}
+	t.Cleanup(func() { runCosign = orig })
+
+	srv := releaseServer(t, archive, sums)
+	u := New()
+	u.APIURL = srv.URL
+	u.Token = "gh-token"
+	u.GOOS, u.GOARCH = "linux", "amd64"
+
+	rel, err := u.Release(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.5.0", rel.Tag)
+
+	got, err := u.Download(context.Background(), rel)
+	require.NoError(t, err)
+	assert.Equal(t, binary, got)
+	assert.Contains(t, cosignArgs, "https://github.com/jbcom/secretsync/.github/workflows/ci.yml@refs/tags/v1.5.0")
+	assert.Contains(t, cosignArgs, CertificateIssuer)
+
+	// A bad signature stops the update before the archive is fetched
+	runCosign = func(context.Context, ...string) error { return fmt.Errorf("no matching signatures") }
+	_, err = u.Download(context.Background(), rel)
+	assert.ErrorContains(t, err, "release signature verification failed")
+
+	// So does an archive that does not match the signed checksums
+	runCosign = func(context.Context, ...string) error { return nil }
+	tampered := releaseServer(t, tarGz(t, "vss", []byte("evil")), sums)
+	u.APIURL = tampered.URL
+	rel, err = u.Release(context.Background(), "")
+	require.NoError(t, err)
+	_, err = u.Download(context.Background(), rel)
+	assert.ErrorContains(t, err, "checksum mismatch")
+
+	// Platforms without an archive are reported
+	u.GOOS = "freebsd"
+	_, err = u.Download(context.Background(), rel)
+	assert.ErrorContains(t, err, "has no asset secretsync_1.5.0_freebsd_x86_64.tar.gz")
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "vss")
+	require.NoError(t, os.WriteFile(exe, []byte("old"), 0o755))
+	link := filepath.Join(dir, "vss-link")
+	require.NoError(t, os.Symlink(exe, link))
+
+	// Replacing through a symlink replaces its target
+	require.NoError(t, Replace(link, []byte("new")))
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(exe)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "temp file left behind")
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/jbcom/secretsync/internal/event"
 	"github.com/jbcom/secretsync/internal/metrics"
 	"github.com/jbcom/secretsync/internal/queue"
+	"github.com/jbcom/secretsync/internal/spiffe"
 	"github.com/jbcom/secretsync/internal/srvutils"
 	"github.com/jbcom/secretsync/internal/sync"
 	log "github.com/sirupsen/logrus"
@@ -135,12 +136,27 @@ func eventAuthValid(r *http.Request) bool {
 		return true
 	}
 	// security is enabled
+	tlsEnabled := config.Config.Events.Security.TLS != nil
+	if tlsEnabled && config.Config.Events.Security.TLS.SPIFFE != nil {
+		// the TLS handshake has already verified the caller's SVID against the
+		// trust bundle and the allowed SPIFFE IDs
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			l.Debug("SPIFFE SVID required but not provided")
+			return false
+		}
+		id, err := spiffe.IDFromCert(r.TLS.PeerCertificates[0])
+		if err != nil {
+			l.WithError(err).Debug("invalid SPIFFE SVID")
+			return false
+		}
+		l.WithField("spiffeId", id).Trace("end")
+		return true
+	}
 	if config.Config.Events.Security.Token == "" && (config.Config.Events.Security.TLS == nil || config.Config.Events.Security.TLS.ClientAuth == nil) {
 		l.Warn("security enabled but no token or client cert provided")
 		return false
 	}
 	token := r.Header.Get("X-Vault-Secret-Sync-Token")
-	tlsEnabled := config.Config.Events.Security.TLS != nil
 	clientAuthEnabled := tlsEnabled && config.Config.Events.Security.TLS.ClientAuth != nil
 	if clientAuthEnabled && (*config.Config.Events.Security.TLS.ClientAuth == "require" || *config.Config.Events.Security.TLS.ClientAuth == "verify") {
 		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
@@ -235,7 +251,7 @@ func EventServer(port int, tlsConfig *srvutils.TLSConfig) {
 	r := mux.NewRouter()
 	r.HandleFunc("/events", handleVaultEvents)
 	port = cmp.Or(port, 8080)
-	if tlsConfig != nil && tlsConfig.Cert != "" && tlsConfig.Key != "" {
+	if tlsConfig.Enabled() {
 		l.Infof("starting server on port %d with tls", port)
 	} else {
 		l.Infof("starting server on port %d", port)
@@ -245,7 +261,10 @@ func EventServer(port int, tlsConfig *srvutils.TLSConfig) {
 		l.Fatal(err)
 	}
 	metrics.RegisterServiceHealth("events", metrics.ServiceHealthStatusOK)
-	if tlsConfig != nil && tlsConfig.Cert != "" && tlsConfig.Key != "" {
+	if tlsConfig.Enabled() && tlsConfig.SPIFFE != nil {
+		// the SVID is served from srv.TLSConfig and reloaded as it rotates
+		l.Fatal(srv.ListenAndServeTLS("", ""))
+	} else if tlsConfig.Enabled() {
 		l.Fatal(srv.ListenAndServeTLS(tlsConfig.Cert, tlsConfig.Key))
 	} else {
 		l.Fatal(srv.ListenAndServe())
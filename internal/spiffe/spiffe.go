@@ -0,0 +1,257 @@
+// Package spiffe authenticates the sync plane with SPIFFE X.509 SVIDs (mTLS)
+// instead of distributed API tokens. SVIDs are read from files kept up to date
+// by the SPIRE agent (spiffe-helper) or cert-manager's csi-driver-spiffe, and
+// are re-read when they rotate.
+package spiffe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config selects the local SVID and the peers it trusts
+type Config struct {
+	// CertFile is the PEM SVID certificate, followed by any intermediates
+	CertFile string `yaml:"certFile,omitempty" json:"certFile,omitempty"`
+	KeyFile  string `yaml:"keyFile,omitempty" json:"keyFile,omitempty"`
+	// BundleFile is the PEM trust bundle used to verify peer SVIDs
+	BundleFile string `yaml:"bundleFile,omitempty" json:"bundleFile,omitempty"`
+
+	// TrustDomain restricts peers to spiffe://<trustDomain>/...
+	TrustDomain string `yaml:"trustDomain,omitempty" json:"trustDomain,omitempty"`
+	// AllowedIDs are the peer SPIFFE IDs accepted; an ID ending in /* accepts
+	// everything below that path. Empty accepts any ID in TrustDomain.
+	AllowedIDs []string `yaml:"allowedIds,omitempty" json:"allowedIds,omitempty"`
+}
+
+// DeepCopy returns a copy of c
+func (c *Config) DeepCopy() *Config {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	if c.AllowedIDs != nil {
+		out.AllowedIDs = make([]string, len(c.AllowedIDs))
+		copy(out.AllowedIDs, c.AllowedIDs)
+	}
+	return &out
+}
+
+// Validate checks that the SVID files and peer policy are configured
+func (c *Config) Validate() error {
+	if c.CertFile == "" || c.KeyFile == "" || c.BundleFile == "" {
+		return errors.New("spiffe.certFile, spiffe.keyFile and spiffe.bundleFile are required")
+	}
+	if c.TrustDomain == "" && len(c.AllowedIDs) == 0 {
+		return errors.New("spiffe.trustDomain or spiffe.allowedIds is required")
+	}
+	if strings.Contains(c.TrustDomain, "/") {
+		return fmt.Errorf("invalid spiffe.trustDomain %q: use the bare domain, e.g. example.org", c.TrustDomain)
+	}
+	for _, id := range c.AllowedIDs {
+		if _, err := parseID(strings.TrimSuffix(id, "/*")); err != nil {
+			return fmt.Errorf("invalid spiffe.allowedIds entry %q: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// ClientTLSConfig presents the SVID to servers and accepts only servers whose
+// SVID chains to the bundle and whose SPIFFE ID is allowed. SVIDs carry no DNS
+// names, so the ID replaces hostname verification.
+func (c *Config) ClientTLSConfig() (*tls.Config, error) {
+	s, err := c.source()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.certificate()
+		},
+		// Verification is done by VerifyPeerCertificate against the SPIFFE bundle
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: s.verifyPeer,
+	}, nil
+}
+
+// ServerTLSConfig presents the SVID to clients and requires every client to
+// present an allowed SVID
+func (c *Config) ServerTLSConfig() (*tls.Config, error) {
+	s, err := c.source()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.certificate()
+		},
+		ClientAuth:            tls.RequireAnyClientCert,
+		VerifyPeerCertificate: s.verifyPeer,
+	}, nil
+}
+
+// Authorize reports whether a peer with the given SPIFFE ID is allowed
+func (c *Config) Authorize(id string) error {
+	u, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	if c.TrustDomain != "" && u.Host != c.TrustDomain {
+		return fmt.Errorf("SPIFFE ID %s is not in trust domain %s", id, c.TrustDomain)
+	}
+	if len(c.AllowedIDs) == 0 {
+		return nil
+	}
+	for _, allowed := range c.AllowedIDs {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(id, prefix+"/") {
+				return nil
+			}
+		} else if id == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("SPIFFE ID %s is not allowed", id)
+}
+
+// IDFromCert returns the SPIFFE ID in an SVID's URI SAN
+func IDFromCert(cert *x509.Certificate) (string, error) {
+	if len(cert.URIs) != 1 {
+		return "", fmt.Errorf("certificate has %d URI SANs, an SVID must have exactly one", len(cert.URIs))
+	}
+	id := cert.URIs[0].String()
+	if _, err := parseID(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func parseID(id string) (*url.URL, error) {
+	u, err := url.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "spiffe" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not a SPIFFE ID (spiffe://<trust-domain>/<path>)", id)
+	}
+	if u.User != nil || u.Port() != "" || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("SPIFFE ID %q must not have a user, port, query or fragment", id)
+	}
+	return u, nil
+}
+
+// source caches the SVID and bundle, reloading them when the files change
+type source struct {
+	cfg *Config
+
+	mu       sync.Mutex
+	modTimes [3]time.Time
+	cert     *tls.Certificate
+	bundle   *x509.CertPool
+}
+
+func (c *Config) source() (*source, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	s := &source{cfg: c.DeepCopy()}
+	if _, _, err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load returns the current SVID and bundle, re-reading the files if any of
+// them has been modified since the last load
+func (s *source) load() (*tls.Certificate, *x509.CertPool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var modTimes [3]time.Time
+	for i, f := range []string{s.cfg.CertFile, s.cfg.KeyFile, s.cfg.BundleFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("spiffe: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if s.cert != nil && modTimes == s.modTimes {
+		return s.cert, s.bundle, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("spiffe: load SVID: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("spiffe: parse SVID: %w", err)
+	}
+	if _, err := IDFromCert(leaf); err != nil {
+		return nil, nil, fmt.Errorf("spiffe: %s: %w", s.cfg.CertFile, err)
+	}
+	pem, err := os.ReadFile(s.cfg.BundleFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("spiffe: read bundle: %w", err)
+	}
+	bundle := x509.NewCertPool()
+	if !bundle.AppendCertsFromPEM(pem) {
+		return nil, nil, fmt.Errorf("spiffe: no certificates in bundle %s", s.cfg.BundleFile)
+	}
+
+	s.cert, s.bundle, s.modTimes = &cert, bundle, modTimes
+	return s.cert, s.bundle, nil
+}
+
+func (s *source) certificate() (*tls.Certificate, error) {
+	cert, _, err := s.load()
+	return cert, err
+}
+
+// verifyPeer verifies the peer's SVID chain against the bundle and authorizes its ID
+func (s *source) verifyPeer(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("spiffe: peer presented no certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("spiffe: parse peer certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	id, err := IDFromCert(certs[0])
+	if err != nil {
+		return fmt.Errorf("spiffe: peer: %w", err)
+	}
+
+	_, bundle, err := s.load()
+	if err != nil {
+		return err
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         bundle,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("spiffe: peer %s: %w", id, err)
+	}
+	if err := s.cfg.Authorize(id); err != nil {
+		return fmt.Errorf("spiffe: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package spiffe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test trust domain"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// writeSVID issues an SVID for id and writes it with the bundle into dir
+func (ca *testCA) writeSVID(t *testing.T, dir, id string) *Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	u, err := url.Parse(id)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{u},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	cfg := &Config{
+		CertFile:   filepath.Join(dir, "svid.pem"),
+		KeyFile:    filepath.Join(dir, "svid_key.pem"),
+		BundleFile: filepath.Join(dir, "bundle.pem"),
+	}
+	require.NoError(t, os.WriteFile(cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(cfg.BundleFile, ca.pem, 0o600))
+	return cfg
+}
+
+// newSPIFFEServer starts an HTTPS server that echoes the caller's SPIFFE ID
+func newSPIFFEServer(t *testing.T, cfg *Config) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := IDFromCert(r.TLS.PeerCertificates[0])
+		_, _ = w.Write([]byte(id))
+	}))
+	tlsCfg, err := cfg.ServerTLSConfig()
+	require.NoError(t, err)
+	// StartTLS would replace the SVID with httptest's own certificate
+	srv.Listener = tls.NewListener(srv.Listener, tlsCfg)
+	srv.Start()
+	srv.URL = "https://" + srv.Listener.Addr().String()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, cfg *Config, url string) (string, error) {
+	t.Helper()
+	tlsCfg, err := cfg.ClientTLSConfig()
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 256)
+	n, _ := resp.Body.Read(buf)
+	return string(buf[:n]), nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverCfg := ca.writeSVID(t, t.TempDir(), "spiffe://example.org/store")
+	serverCfg.AllowedIDs = []string{"spiffe://example.org/vss/*"}
+	srv := newSPIFFEServer(t, serverCfg)
+
+	clientCfg := ca.writeSVID(t, t.TempDir(), "spiffe://example.org/vss/operator")
+	clientCfg.AllowedIDs = []string{"spiffe://example.org/store"}
+	body, err := get(t, clientCfg, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://example.org/vss/operator", body)
+
+	// The server rejects callers outside spiffe://example.org/vss/
+	other := ca.writeSVID(t, t.TempDir(), "spiffe://example.org/ci")
+	other.TrustDomain = "example.org"
+	_, err = get(t, other, srv.URL)
+	assert.Error(t, err)
+
+	// The client rejects servers with an unexpected ID
+	wrongServer := ca.writeSVID(t, t.TempDir(), "spiffe://example.org/vss/operator")
+	wrongServer.AllowedIDs = []string{"spiffe://example.org/other-store"}
+	_, err = get(t, wrongServer, srv.URL)
+	assert.ErrorContains(t, err, "not allowed")
+
+	// SVIDs from another CA are rejected even with an allowed ID
+	foreign := newTestCA(t).writeSVID(t, t.TempDir(), "spiffe://example.org/vss/operator")
+	foreign.AllowedIDs = []string{"spiffe://example.org/store"}
+	_, err = get(t, foreign, srv.URL)
+	assert.Error(t, err)
+}
+
+func TestRotatedSVIDIsReloaded(t *testing.T) {
+	ca := newTestCA(t)
+	serverCfg := ca.writeSVID(t, t.TempDir(), "spiffe://example.org/store")
+	serverCfg.TrustDomain = "example.org"
+	srv := newSPIFFEServer(t, serverCfg)
+
+	dir := t.TempDir()
+	clientCfg := ca.writeSVID(t, dir, "spiffe://example.org/vss/a")
+	clientCfg.TrustDomain = "example.org"
+	tlsCfg, err := clientCfg.ClientTLSConfig()
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg, DisableKeepAlives: true}}
+
+	read := func() string {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		buf := make([]byte, 256)
+		n, _ := resp.Body.Read(buf)
+		return string(buf[:n])
+	}
+	assert.Equal(t, "spiffe://example.org/vss/a", read())
+
+	ca.writeSVID(t, dir, "spiffe://example.org/vss/b")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(clientCfg.CertFile, future, future))
+	assert.Equal(t, "spiffe://example.org/vss/b", read())
+}
+
+func TestAuthorize(t *testing.T) {
+	cfg := &Config{TrustDomain: "example.org"}
+	assert.NoError(t, cfg.Authorize("spiffe://example.org/anything"))
+	assert.Error(t, cfg.Authorize("spiffe://evil.org/anything"))
+	assert.Error(t, cfg.Authorize("https://example.org/anything"))
+
+	cfg.AllowedIDs = []string{"spiffe://example.org/vss", "spiffe://example.org/ci/*"}
+	assert.NoError(t, cfg.Authorize("spiffe://example.org/vss"))
+	assert.NoError(t, cfg.Authorize("spiffe://example.org/ci/deploy"))
+	assert.Error(t, cfg.Authorize("spiffe://example.org/vss/child"))
+	assert.Error(t, cfg.Authorize("spiffe://example.org/ci"))
+}
+
+func TestValidate(t *testing.T) {
+	files := Config{CertFile: "svid.pem", KeyFile: "key.pem", BundleFile: "bundle.pem"}
+	assert.Error(t, (&Config{TrustDomain: "example.org"}).Validate())
+	assert.Error(t, files.DeepCopy().Validate())
+
+	cfg := files
+	cfg.TrustDomain = "spiffe://example.org"
+	assert.Error(t, cfg.Validate())
+
+	cfg = files
+	cfg.AllowedIDs = []string{"example.org/vss"}
+	assert.Error(t, cfg.Validate())
+
+	cfg.AllowedIDs = []string{"spiffe://example.org/vss/*"}
+	assert.NoError(t, cfg.Validate())
+}
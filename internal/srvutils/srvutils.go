@@ -7,6 +7,9 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+
+	"github.com/jbcom/secretsync/internal/fips"
+	"github.com/jbcom/secretsync/internal/spiffe"
 )
 
 type TLSConfig struct {
@@ -14,6 +17,14 @@ type TLSConfig struct {
 	Cert       string  `json:"cert" yaml:"cert"`
 	Key        string  `json:"key" yaml:"key"`
 	ClientAuth *string `json:"clientAuth" yaml:"clientAuth"`
+	// SPIFFE serves the workload's X.509 SVID and requires callers to present
+	// an allowed SVID, replacing cert/key/ca
+	SPIFFE *spiffe.Config `json:"spiffe" yaml:"spiffe"`
+}
+
+// Enabled reports whether the server should listen with TLS
+func (t *TLSConfig) Enabled() bool {
+	return t != nil && ((t.Cert != "" && t.Key != "") || t.SPIFFE != nil)
 }
 
 func SetupServer(handler http.Handler, port int, tlsConfig *TLSConfig) (*http.Server, error) {
@@ -22,7 +33,13 @@ func SetupServer(handler http.Handler, port int, tlsConfig *TLSConfig) (*http.Se
 		Handler: handler,
 	}
 
-	if tlsConfig != nil && tlsConfig.Cert != "" && tlsConfig.Key != "" {
+	if tlsConfig != nil && tlsConfig.SPIFFE != nil {
+		config, err := tlsConfig.SPIFFE.ServerTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("server: %s", err)
+		}
+		srv.TLSConfig = fips.TLSConfig(config)
+	} else if tlsConfig != nil && tlsConfig.Cert != "" && tlsConfig.Key != "" {
 		cert, err := tls.LoadX509KeyPair(tlsConfig.Cert, tlsConfig.Key)
 		if err != nil {
 			return nil, fmt.Errorf("server: loadkeys: %s", err)
@@ -52,7 +69,7 @@ func SetupServer(handler http.Handler, port int, tlsConfig *TLSConfig) (*http.Se
 				}
 			}
 		}
-		srv.TLSConfig = &config
+		srv.TLSConfig = fips.TLSConfig(&config)
 	}
 
 	return srv, nil
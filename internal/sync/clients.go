@@ -3,15 +3,20 @@ package sync
 import (
 	"context"
 	"errors"
+	gosync "sync"
 
 	"github.com/jbcom/secretsync/api/v1alpha1"
 	"github.com/jbcom/secretsync/pkg/driver"
 	"github.com/jbcom/secretsync/stores/aws"
 	"github.com/jbcom/secretsync/stores/awsidentitycenter"
+	"github.com/jbcom/secretsync/stores/azurekeyvault"
+	"github.com/jbcom/secretsync/stores/cloudflare"
 	"github.com/jbcom/secretsync/stores/doppler"
 	"github.com/jbcom/secretsync/stores/gcp"
 	"github.com/jbcom/secretsync/stores/github"
+	"github.com/jbcom/secretsync/stores/grpcstore"
 	"github.com/jbcom/secretsync/stores/httpstore"
+	"github.com/jbcom/secretsync/stores/kubernetes"
 	"github.com/jbcom/secretsync/stores/vault"
 	log "github.com/sirupsen/logrus"
 )
@@ -78,6 +83,18 @@ func setStoreGlobalDefaults(s *v1alpha1.VaultSecretSync) error {
 		if d.Vault != nil && DefaultConfigs[driver.DriverNameVault] != nil {
 			err = d.Vault.SetDefaults(DefaultConfigs[driver.DriverNameVault].Vault)
 		}
+		if d.Kubernetes != nil && DefaultConfigs[driver.DriverNameKubernetes] != nil {
+			err = d.Kubernetes.SetDefaults(DefaultConfigs[driver.DriverNameKubernetes].Kubernetes)
+		}
+		if d.GRPC != nil && DefaultConfigs[driver.DriverNameGRPC] != nil {
+			err = d.GRPC.SetDefaults(DefaultConfigs[driver.DriverNameGRPC].GRPC)
+		}
+		if d.AzureKeyVault != nil && DefaultConfigs[driver.DriverNameAzureKeyVault] != nil {
+			err = d.AzureKeyVault.SetDefaults(DefaultConfigs[driver.DriverNameAzureKeyVault].AzureKeyVault)
+		}
+		if d.Cloudflare != nil && DefaultConfigs[driver.DriverNameCloudflare] != nil {
+			err = d.Cloudflare.SetDefaults(DefaultConfigs[driver.DriverNameCloudflare].Cloudflare)
+		}
 		if err != nil {
 			l.Error(err)
 			return err
@@ -86,6 +103,31 @@ func setStoreGlobalDefaults(s *v1alpha1.VaultSecretSync) error {
 	return nil
 }
 
+var (
+	sourcesMu gosync.RWMutex
+	sources   = make(map[string]SyncClient)
+)
+
+// SetSource makes the sync config namespace/name read its secrets from src
+// instead of its Vault source, for secrets that are not kept in Vault such as
+// those of the pipeline's S3 and GCP merge stores. A nil src removes it.
+func SetSource(namespace, name string, src SyncClient) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	key := namespace + "/" + name
+	if src == nil {
+		delete(sources, key)
+		return
+	}
+	sources[key] = src
+}
+
+func sourceFor(sc v1alpha1.VaultSecretSync) SyncClient {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	return sources[sc.Namespace+"/"+sc.Name]
+}
+
 func InitSyncConfigClients(sc v1alpha1.VaultSecretSync) (*SyncClients, error) {
 	l := log.WithFields(log.Fields{
 		"action": "sc.InitSyncConfigClients",
@@ -105,8 +147,9 @@ func InitSyncConfigClients(sc v1alpha1.VaultSecretSync) (*SyncClients, error) {
 		l.Error(err)
 		return nil, err
 	}
-	scs.Source, err = vault.NewClient(sc.Spec.Source)
-	if err != nil {
+	if src := sourceFor(sc); src != nil {
+		scs.Source = src
+	} else if scs.Source, err = vault.NewClient(sc.Spec.Source); err != nil {
 		l.Error(err)
 		return nil, err
 	}
@@ -160,6 +203,34 @@ func InitSyncConfigClients(sc v1alpha1.VaultSecretSync) (*SyncClients, error) {
 				return nil, err
 			}
 			scs.Dest = append(scs.Dest, client)
+		} else if d.Kubernetes != nil {
+			client, err := kubernetes.NewClient(d.Kubernetes)
+			if err != nil {
+				l.Error(err)
+				return nil, err
+			}
+			scs.Dest = append(scs.Dest, client)
+		} else if d.GRPC != nil {
+			client, err := grpcstore.NewClient(d.GRPC)
+			if err != nil {
+				l.Error(err)
+				return nil, err
+			}
+			scs.Dest = append(scs.Dest, client)
+		} else if d.AzureKeyVault != nil {
+			client, err := azurekeyvault.NewClient(d.AzureKeyVault)
+			if err != nil {
+				l.Error(err)
+				return nil, err
+			}
+			scs.Dest = append(scs.Dest, client)
+		} else if d.Cloudflare != nil {
+			client, err := cloudflare.NewClient(d.Cloudflare)
+			if err != nil {
+				l.Error(err)
+				return nil, err
+			}
+			scs.Dest = append(scs.Dest, client)
 		}
 		l.WithField("dest", scs.Dest).Trace("added dest")
 	}
@@ -56,6 +56,18 @@ func SetStoreDefaults(sc *v1alpha1.StoreConfig) {
 	if sc.HTTP != nil {
 		DefaultConfigs[driver.DriverNameHttp] = sc
 	}
+	if sc.Kubernetes != nil {
+		DefaultConfigs[driver.DriverNameKubernetes] = sc
+	}
+	if sc.GRPC != nil {
+		DefaultConfigs[driver.DriverNameGRPC] = sc
+	}
+	if sc.AzureKeyVault != nil {
+		DefaultConfigs[driver.DriverNameAzureKeyVault] = sc
+	}
+	if sc.Cloudflare != nil {
+		DefaultConfigs[driver.DriverNameCloudflare] = sc
+	}
 }
 
 func DestinationStoreNames(sc v1alpha1.VaultSecretSync) []driver.DriverName {
@@ -82,6 +94,18 @@ func DestinationStoreNames(sc v1alpha1.VaultSecretSync) []driver.DriverName {
 		if d.HTTP != nil {
 			destDrivers = append(destDrivers, driver.DriverNameHttp)
 		}
+		if d.Kubernetes != nil {
+			destDrivers = append(destDrivers, driver.DriverNameKubernetes)
+		}
+		if d.GRPC != nil {
+			destDrivers = append(destDrivers, driver.DriverNameGRPC)
+		}
+		if d.AzureKeyVault != nil {
+			destDrivers = append(destDrivers, driver.DriverNameAzureKeyVault)
+		}
+		if d.Cloudflare != nil {
+			destDrivers = append(destDrivers, driver.DriverNameCloudflare)
+		}
 	}
 	return destDrivers
 }
@@ -2,6 +2,7 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -35,20 +36,27 @@ func eventWorker(ctx context.Context, workerID int, events <-chan event.VaultEve
 }
 
 func EventProcessor(ctx context.Context, workerPoolSize, numSubscriptions int) error {
+	return EventProcessorWithReady(ctx, workerPoolSize, numSubscriptions, nil)
+}
+
+// EventProcessorWithReady runs the event processor like EventProcessor and
+// closes ready once every subscription and its workers are running. It
+// returns an error without closing ready if a subscription fails.
+func EventProcessorWithReady(ctx context.Context, workerPoolSize, numSubscriptions int, ready chan<- struct{}) error {
 	l := log.WithFields(log.Fields{
 		"action": "eventProcessor",
 	})
 	l.Trace("Starting eventProcessor")
 
 	// Function to start a subscription and its workers
-	startSubscription := func(subID int) {
+	startSubscription := func(subID int) error {
 		l := log.WithFields(log.Fields{
 			"subscription": subID,
 		})
 		ch, err := queue.Q.Subscribe(ctx)
 		if err != nil {
 			l.Error("Failed to subscribe to queue:", err)
-			return
+			return fmt.Errorf("subscription %d: failed to subscribe to queue: %w", subID, err)
 		}
 		l.Trace("Subscribed to queue")
 
@@ -66,12 +74,19 @@ func EventProcessor(ctx context.Context, workerPoolSize, numSubscriptions int) e
 			}
 			close(eventChannel) // Close channel to stop workers after all events are processed
 		}()
+		return nil
 	}
 
 	// Start multiple subscriptions
 	for i := 0; i < numSubscriptions; i++ {
-		startSubscription(i)
+		if err := startSubscription(i); err != nil {
+			return err
+		}
+	}
+	if ready != nil {
+		close(ready)
 	}
+	l.Trace("eventProcessor ready")
 
 	<-ctx.Done()
 	l.Trace("Stopping eventProcessor")
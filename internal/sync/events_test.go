@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/internal/event"
+	"github.com/jbcom/secretsync/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribeQueue is a memory queue whose subscriptions fail after the first failAfter
+type subscribeQueue struct {
+	*queue.MemoryQueue
+	failAfter     int
+	subscriptions int
+}
+
+func (q *subscribeQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error) {
+	if q.subscriptions >= q.failAfter {
+		return nil, errors.New("connection refused")
+	}
+	q.subscriptions++
+	return q.MemoryQueue.Subscribe(ctx)
+}
+
+func TestEventProcessorWithReady(t *testing.T) {
+	q := queue.Q
+	t.Cleanup(func() { queue.Q = q })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fq := &subscribeQueue{MemoryQueue: queue.NewMemoryQueue(), failAfter: 3}
+	queue.Q = fq
+
+	ready := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() { errCh <- EventProcessorWithReady(ctx, 2, 3, ready) }()
+
+	select {
+	case <-ready:
+	case err := <-errCh:
+		t.Fatalf("event processor failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event processor did not become ready")
+	}
+	assert.Equal(t, 3, fq.subscriptions)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestEventProcessorWithReadySubscribeError(t *testing.T) {
+	q := queue.Q
+	t.Cleanup(func() { queue.Q = q })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue.Q = &subscribeQueue{MemoryQueue: queue.NewMemoryQueue(), failAfter: 1}
+
+	ready := make(chan struct{})
+	err := EventProcessorWithReady(ctx, 2, 3, ready)
+	require.EqualError(t, err, "subscription 1: failed to subscribe to queue: connection refused")
+	select {
+	case <-ready:
+		t.Fatal("ready closed after a failed subscription")
+	default:
+	}
+}
@@ -14,18 +14,26 @@ func Operator(ctx context.Context, backendParams map[string]any, workerPoolSize,
 	})
 	l.Info("starting operator")
 
-	metrics.RegisterServiceHealth("operator", metrics.ServiceHealthStatusOK)
 	if err := backend.InitBackend(ctx, backendParams); err != nil {
 		l.Error(err)
 		return
 	}
-	// start the event queue with error handling
+	// start the event queue and report healthy once it is consuming
+	ready := make(chan struct{})
+	errCh := make(chan error, 1)
 	go func() {
-		if err := EventProcessor(ctx, workerPoolSize, numSubscriptions); err != nil {
-			l.WithError(err).Error("event processor failed")
-			metrics.RegisterServiceHealth("operator", metrics.ServiceHealthStatusCritical)
-		}
+		errCh <- EventProcessorWithReady(ctx, workerPoolSize, numSubscriptions, ready)
 	}()
+	select {
+	case <-ready:
+		metrics.RegisterServiceHealth("operator", metrics.ServiceHealthStatusOK)
+		l.Info("operator ready")
+	case err := <-errCh:
+		l.WithError(err).Error("event processor failed")
+		metrics.RegisterServiceHealth("operator", metrics.ServiceHealthStatusCritical)
+		return
+	case <-ctx.Done():
+	}
 	// wait for context to be done
 	<-ctx.Done()
 	metrics.RegisterServiceHealth("operator", metrics.ServiceHealthStatusCritical)
@@ -3,10 +3,12 @@ package sync
 import (
 	"context"
 	"fmt"
+	gosync "sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
 	"github.com/jbcom/secretsync/internal/event"
 	"github.com/jbcom/secretsync/internal/metrics"
 	"github.com/jbcom/secretsync/pkg/driver"
@@ -115,6 +117,31 @@ type SyncJob struct {
 	VaultEvent event.VaultEvent
 	SyncConfig v1alpha1.VaultSecretSync
 	Error      error
+
+	// paths collects per-path outcomes for ManualTriggerAndWait
+	paths *pathRecorder
+}
+
+// pathRecorder collects the outcome of each path a sync job writes. Jobs are
+// passed by value to concurrent workers, so they share it by pointer.
+type pathRecorder struct {
+	mu      gosync.Mutex
+	results []backend.PathResult
+}
+
+// recordPath records a path's outcome when the job is being waited on
+func (j SyncJob) recordPath(sourcePath string, dest SyncClient, destPath string, err error) {
+	if j.paths == nil {
+		return
+	}
+	j.paths.mu.Lock()
+	defer j.paths.mu.Unlock()
+	j.paths.results = append(j.paths.results, backend.PathResult{
+		SourcePath: sourcePath,
+		Driver:     string(dest.Driver()),
+		DestPath:   destPath,
+		Error:      err,
+	})
 }
 
 func singleSyncWorker(ctx context.Context, sc *SyncClients, j SyncJob, dest chan SyncClient, errChan chan error) {
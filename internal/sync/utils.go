@@ -164,6 +164,11 @@ func CreateOne(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePa
 		return handleCreateOneError(ctx, serr, j, dest, sourcePath, destPath)
 	}
 
+	ssecret, serr = transforms.ExecuteReferenceTransform(j.SyncConfig, sourcePath, ssecret)
+	if serr != nil {
+		return handleCreateOneError(ctx, serr, j, dest, sourcePath, destPath)
+	}
+
 	ssecret, serr = transforms.ExecuteTransforms(j.SyncConfig, ssecret)
 	if serr != nil {
 		return handleCreateOneError(ctx, serr, j, dest, sourcePath, destPath)
@@ -177,6 +182,9 @@ func CreateOne(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePa
 	if werr != nil {
 		return handleCreateOneError(ctx, werr, j, dest, sourcePath, destPath)
 	}
+	if verr := verifyWrite(ctx, j, dest, destPath, ssecret); verr != nil {
+		return handleCreateOneError(ctx, verr, j, dest, sourcePath, destPath)
+	}
 
 	return handleCreateOneSuccess(ctx, j, dest, sourcePath, destPath)
 }
@@ -184,6 +192,7 @@ func CreateOne(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePa
 func handleCreateOneError(ctx context.Context, err error, j SyncJob, dest SyncClient, sourcePath, destPath string) error {
 	l := log.WithFields(log.Fields{"action": "handleCreateOneError", "error": err})
 	l.Error("failed to sync secret")
+	j.recordPath(sourcePath, dest, destPath, err)
 	backend.WriteEvent(
 		ctx,
 		j.SyncConfig.Namespace,
@@ -198,6 +207,7 @@ func handleCreateOneError(ctx context.Context, err error, j SyncJob, dest SyncCl
 func handleCreateOneSuccess(ctx context.Context, j SyncJob, dest SyncClient, sourcePath, destPath string) error {
 	l := log.WithFields(log.Fields{"action": "handleCreateOneSuccess"})
 	l.Trace("end")
+	j.recordPath(sourcePath, dest, destPath, nil)
 	backend.WriteEvent(
 		ctx,
 		j.SyncConfig.Namespace,
@@ -325,6 +335,38 @@ func ManualTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical
 	return queue.Q.Push(evt)
 }
 
+// ManualTriggerAndWait runs a sync in the calling goroutine instead of
+// queueing it, and returns the outcome of each path it wrote. The returned
+// error is the sync's overall error; the completion is returned either way.
+func ManualTriggerAndWait(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*backend.SyncCompletion, error) {
+	name := backend.InternalName(cfg.Namespace, cfg.Name)
+	l := log.WithFields(log.Fields{"action": "ManualTriggerAndWait", "name": name})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	id := uuid.New().String()
+	evt := event.VaultEvent{
+		ID:        id,
+		EventId:   fmt.Sprintf("manual-%s", id),
+		SyncName:  name,
+		Operation: op,
+		Manual:    true,
+	}
+	TrackSyncStart(id)
+	defer TrackSyncEnd(id)
+
+	j := SyncJob{VaultEvent: evt, SyncConfig: cfg, paths: &pathRecorder{}}
+	err := doSync(ctx, j)
+
+	j.paths.mu.Lock()
+	defer j.paths.mu.Unlock()
+	completion := &backend.SyncCompletion{Name: name, Paths: j.paths.results}
+	if err != nil {
+		l.WithError(err).WithField("failedPaths", len(completion.Failed())).Debug("sync failed")
+	}
+	return completion, err
+}
+
 func doSync(ctx context.Context, j SyncJob) error {
 	l := log.WithFields(log.Fields{"action": "sync", "name": j.SyncConfig.Name, "namespace": j.SyncConfig.Namespace})
 	l.Trace("start")
@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jbcom/secretsync/internal/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+// SecretVerifier is implemented by destinations that can read a secret back
+// after writing it. VerifySecret reports whether the secret at path holds what
+// was written.
+type SecretVerifier interface {
+	VerifySecret(ctx context.Context, path string, written []byte) (bool, error)
+}
+
+var (
+	// verifyAttempts is how many times a mismatch is read back before the
+	// path fails, for stores that are eventually consistent
+	verifyAttempts = 3
+	// verifyInterval is the wait between read-back attempts
+	verifyInterval = time.Second
+)
+
+// verifyWrite reads back a secret the job wrote when the sync asks for
+// verification. A secret that never matches fails with ErrVerificationFailed.
+func verifyWrite(ctx context.Context, j SyncJob, dest SyncClient, destPath string, written []byte) error {
+	if j.SyncConfig.Spec.Verify == nil || !*j.SyncConfig.Spec.Verify {
+		return nil
+	}
+	l := log.WithFields(log.Fields{
+		"action":    "verifyWrite",
+		"driver":    dest.Driver(),
+		"dest.Path": destPath,
+	})
+	v, ok := dest.(SecretVerifier)
+	if !ok {
+		l.Debug("destination cannot be read back, skipping verification")
+		return nil
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= verifyAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(verifyInterval):
+			}
+		}
+		match, err := v.VerifySecret(ctx, destPath, written)
+		if err == nil && match {
+			if attempt > 1 {
+				l.WithField("attempts", attempt).Info("secret matched after retrying read-back")
+			}
+			return nil
+		}
+		lastErr = err
+		l.WithField("attempt", attempt).WithError(err).Warn("secret did not read back as written")
+	}
+	if lastErr != nil {
+		return fmt.Errorf("%w: %s: %v", backend.ErrVerificationFailed, destPath, lastErr)
+	}
+	return fmt.Errorf("%w: %s does not match what was written", backend.ErrVerificationFailed, destPath)
+}
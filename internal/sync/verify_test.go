@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
+	"github.com/jbcom/secretsync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// readBackClient is a destination whose reads return stale content for the
+// first few reads after a write
+type readBackClient struct {
+	stored     []byte
+	staleReads int
+	readErr    error
+	reads      int
+}
+
+func (c *readBackClient) Meta() map[string]any                       { return nil }
+func (c *readBackClient) Init(context.Context) error                 { return nil }
+func (c *readBackClient) Validate() error                            { return nil }
+func (c *readBackClient) Driver() driver.DriverName                  { return driver.DriverNameAws }
+func (c *readBackClient) GetPath() string                            { return "app" }
+func (c *readBackClient) DeleteSecret(context.Context, string) error { return nil }
+func (c *readBackClient) SetDefaults(any) error                      { return nil }
+func (c *readBackClient) Close() error                               { return nil }
+
+func (c *readBackClient) GetSecret(context.Context, string) ([]byte, error) {
+	return c.stored, nil
+}
+
+func (c *readBackClient) WriteSecret(_ context.Context, _ metav1.ObjectMeta, _ string, b []byte) ([]byte, error) {
+	c.stored = b
+	return nil, nil
+}
+
+func (c *readBackClient) ListSecrets(context.Context, string) ([]string, error) {
+	return []string{"app"}, nil
+}
+
+func (c *readBackClient) VerifySecret(_ context.Context, _ string, written []byte) (bool, error) {
+	c.reads++
+	if c.readErr != nil {
+		return false, c.readErr
+	}
+	return c.reads > c.staleReads && string(c.stored) == string(written), nil
+}
+
+func TestVerifyWrite(t *testing.T) {
+	defer func(interval time.Duration) { verifyInterval = interval }(verifyInterval)
+	verifyInterval = time.Millisecond
+
+	verify := true
+	job := SyncJob{SyncConfig: v1alpha1.VaultSecretSync{Spec: v1alpha1.VaultSecretSyncSpec{Verify: &verify}}}
+	ctx := context.Background()
+
+	// An eventually consistent read matches within the attempts
+	dest := &readBackClient{stored: []byte(`{"a":"1"}`), staleReads: 2}
+	require.NoError(t, verifyWrite(ctx, job, dest, "app", []byte(`{"a":"1"}`)))
+	assert.Equal(t, 3, dest.reads)
+
+	// Content that never matches fails verification
+	dest = &readBackClient{stored: []byte(`{"a":"corrupt"}`)}
+	err := verifyWrite(ctx, job, dest, "app", []byte(`{"a":"1"}`))
+	assert.ErrorIs(t, err, backend.ErrVerificationFailed)
+	assert.Equal(t, verifyAttempts, dest.reads)
+
+	dest = &readBackClient{readErr: errors.New("AccessDeniedException")}
+	err = verifyWrite(ctx, job, dest, "app", []byte(`{"a":"1"}`))
+	assert.ErrorIs(t, err, backend.ErrVerificationFailed)
+	assert.ErrorContains(t, err, "AccessDeniedException")
+
+	// Without verify nothing is read back
+	dest = &readBackClient{stored: []byte(`{"a":"corrupt"}`)}
+	require.NoError(t, verifyWrite(ctx, SyncJob{}, dest, "app", []byte(`{"a":"1"}`)))
+	assert.Zero(t, dest.reads)
+}
@@ -0,0 +1,68 @@
+package transforms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+)
+
+// ReferenceData is what a reference template is rendered with
+type ReferenceData struct {
+	// Address is the Vault address the secret is read from
+	Address string
+	// Path is the secret's Vault path, e.g. merged/Payments_Prod/db
+	Path string
+	// Name is the last element of Path, e.g. db
+	Name string
+	// Key is the key within a JSON object secret; empty for other secrets
+	Key string
+}
+
+// ExecuteReferenceTransform replaces the secret's values with pointers to
+// sourcePath so the destination never holds the values themselves. Each key
+// of a JSON object gets its own pointer; any other secret becomes one pointer.
+func ExecuteReferenceTransform(sc v1alpha1.VaultSecretSync, sourcePath string, secret []byte) ([]byte, error) {
+	if sc.Spec.Transforms == nil || sc.Spec.Transforms.Reference == nil || *sc.Spec.Transforms.Reference == "" {
+		return secret, nil
+	}
+	t, err := template.New("reference").Option("missingkey=error").Parse(strings.TrimSpace(*sc.Spec.Transforms.Reference))
+	if err != nil {
+		return secret, fmt.Errorf("invalid reference template: %w", err)
+	}
+	data := ReferenceData{Path: sourcePath, Name: path.Base(sourcePath)}
+	if sc.Spec.Source != nil {
+		data.Address = sc.Spec.Source.Address
+	}
+	render := func(d ReferenceData) (string, error) {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, d); err != nil {
+			return "", fmt.Errorf("failed to render reference: %w", err)
+		}
+		return buf.String(), nil
+	}
+
+	var secretData map[string]any
+	if err := json.Unmarshal(secret, &secretData); err != nil {
+		ref, err := render(data)
+		if err != nil {
+			return secret, err
+		}
+		return []byte(ref), nil
+	}
+	refs := make(map[string]string, len(secretData))
+	for k := range secretData {
+		d := data
+		d.Key = k
+		ref, err := render(d)
+		if err != nil {
+			return secret, err
+		}
+		refs[k] = ref
+	}
+	return json.Marshal(refs)
+}
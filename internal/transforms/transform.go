@@ -9,6 +9,7 @@ import (
 	"text/template"
 
 	"github.com/jbcom/secretsync/api/v1alpha1"
+	keytransforms "github.com/jbcom/secretsync/pkg/transforms"
 )
 
 func ExecuteTransformTemplate(sc v1alpha1.VaultSecretSync, secret []byte) ([]byte, error) {
@@ -74,6 +75,43 @@ func ExecuteRenameTransforms(sc v1alpha1.VaultSecretSync, secret []byte) ([]byte
 	return jd, nil
 }
 
+// ExecuteKeyTransforms strips the configured prefix and suffix from each key
+// of a JSON object secret and converts its case. Renames have already run.
+func ExecuteKeyTransforms(sc v1alpha1.VaultSecretSync, secret []byte) ([]byte, error) {
+	if sc.Spec.Transforms == nil {
+		return secret, nil
+	}
+	var rules keytransforms.KeyRules
+	if sc.Spec.Transforms.StripPrefix != nil {
+		rules.StripPrefix = *sc.Spec.Transforms.StripPrefix
+	}
+	if sc.Spec.Transforms.StripSuffix != nil {
+		rules.StripSuffix = *sc.Spec.Transforms.StripSuffix
+	}
+	if sc.Spec.Transforms.Case != nil {
+		rules.Case = *sc.Spec.Transforms.Case
+	}
+	if rules.IsZero() {
+		return secret, nil
+	}
+	if err := rules.Validate(); err != nil {
+		return secret, err
+	}
+	secretData := make(map[string]any)
+	if err := json.Unmarshal(secret, &secretData); err != nil {
+		return secret, nil
+	}
+	newSecret, err := rules.Apply(secretData)
+	if err != nil {
+		return secret, err
+	}
+	jd, err := json.Marshal(newSecret)
+	if err != nil {
+		return secret, nil
+	}
+	return jd, nil
+}
+
 // isRegex determines if the provided string is a regex or a literal string
 func isRegex(path string) bool {
 	if !strings.ContainsAny(path, "[](){}+*?|") {
@@ -176,9 +214,17 @@ func ExecuteTransforms(sc v1alpha1.VaultSecretSync, secret []byte) ([]byte, erro
 	if err != nil {
 		return secret, err
 	}
+	ns, err = ExecuteKeyTransforms(sc, ns)
+	if err != nil {
+		return secret, err
+	}
 	ns, err = ExecuteTransformTemplate(sc, ns)
 	if err != nil {
 		return secret, err
 	}
+	ns, err = ExecuteWasmTransform(sc, ns)
+	if err != nil {
+		return secret, err
+	}
 	return ns, nil
 }
@@ -270,6 +270,62 @@ func TestExecuteTransforms(t *testing.T) {
 	}
 }
 
+func TestExecuteKeyTransforms(t *testing.T) {
+	sc := v1alpha1.VaultSecretSync{
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			Transforms: &v1alpha1.TransformSpec{
+				Rename: []v1alpha1.RenameTransform{
+					{From: "legacy_token", To: "APP_token"},
+				},
+				StripPrefix: ptrToString("APP_"),
+				Case:        ptrToString("upper"),
+			},
+		},
+	}
+
+	result, err := ExecuteTransforms(sc, []byte(`{"APP_user":"u","legacy_token":"t"}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"USER":"u","TOKEN":"t"}`, string(result))
+
+	result, err = ExecuteKeyTransforms(sc, []byte("hunter2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", string(result))
+
+	_, err = ExecuteKeyTransforms(sc, []byte(`{"APP_USER":"u","user":"v"}`))
+	assert.ErrorContains(t, err, "both transform to")
+
+	sc.Spec.Transforms.Case = ptrToString("title")
+	_, err = ExecuteKeyTransforms(sc, []byte(`{"user":"u"}`))
+	assert.Error(t, err)
+}
+
+func TestExecuteReferenceTransform(t *testing.T) {
+	sc := v1alpha1.VaultSecretSync{
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			Transforms: &v1alpha1.TransformSpec{
+				Reference: ptrToString(`vault:{{ .Path }}{{ if .Key }}#{{ .Key }}{{ end }}`),
+			},
+		},
+	}
+
+	result, err := ExecuteReferenceTransform(sc, "merged/Payments_Prod/db", []byte(`{"password":"hunter2","user":"app"}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"password":"vault:merged/Payments_Prod/db#password","user":"vault:merged/Payments_Prod/db#user"}`, string(result))
+
+	result, err = ExecuteReferenceTransform(sc, "merged/Payments_Prod/token", []byte("hunter2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "vault:merged/Payments_Prod/token", string(result))
+
+	sc.Spec.Transforms.Reference = ptrToString(`arn:aws:secretsmanager:us-east-1:111111111111:secret:{{ .Name }}`)
+	result, err = ExecuteReferenceTransform(sc, "merged/Payments_Prod/token", []byte("hunter2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:secretsmanager:us-east-1:111111111111:secret:token", string(result))
+
+	sc.Spec.Transforms.Reference = ptrToString(`{{ .Secret }}`)
+	_, err = ExecuteReferenceTransform(sc, "merged/Payments_Prod/token", []byte("hunter2"))
+	assert.ErrorContains(t, err, "failed to render reference")
+}
+
 func ptrToString(s string) *string {
 	return &s
 }
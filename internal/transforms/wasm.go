@@ -0,0 +1,32 @@
+package transforms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/wasm"
+)
+
+// ExecuteWasmTransform passes the secret through the transform function of
+// the sync's WebAssembly plugin. JSON secrets must come back as JSON.
+func ExecuteWasmTransform(sc v1alpha1.VaultSecretSync, secret []byte) ([]byte, error) {
+	if sc.Spec.Transforms == nil || sc.Spec.Transforms.Wasm == nil || *sc.Spec.Transforms.Wasm == "" {
+		return secret, nil
+	}
+	path := *sc.Spec.Transforms.Wasm
+	ctx := context.Background()
+	plugin, err := wasm.Load(ctx, path)
+	if err != nil {
+		return secret, err
+	}
+	out, err := plugin.Call(ctx, "transform", secret)
+	if err != nil {
+		return secret, err
+	}
+	if json.Valid(secret) && !json.Valid(out) {
+		return secret, fmt.Errorf("wasm transform %s returned invalid JSON", path)
+	}
+	return out, nil
+}
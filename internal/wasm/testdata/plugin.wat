@@ -0,0 +1,14 @@
+;; Source of plugin.wasm: an identity transform and an import policy that
+;; denies everything
+(module
+  (memory (export "memory") 1)
+  (data (i32.const 0) "{\"allow\":false,\"reason\":\"denied by test\"}")
+  ;; Inputs are written at a fixed offset after the data
+  (func (export "alloc") (param i32) (result i32)
+    i32.const 1024)
+  (func (export "transform") (param $ptr i32) (param $len i32) (result i64)
+    (i64.or
+      (i64.shl (i64.extend_i32_u (local.get $ptr)) (i64.const 32))
+      (i64.extend_i32_u (local.get $len))))
+  (func (export "check_import") (param i32) (param i32) (result i64)
+    i64.const 41))
@@ -0,0 +1,41 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall(t *testing.T) {
+	ctx := context.Background()
+	p, err := Load(ctx, "testdata/plugin.wasm")
+	require.NoError(t, err)
+
+	again, err := Load(ctx, "testdata/plugin.wasm")
+	require.NoError(t, err)
+	assert.Same(t, p, again, "modules are compiled once")
+
+	assert.True(t, p.Exports("transform"))
+	assert.False(t, p.Exports("validate"))
+
+	out, err := p.Call(ctx, "transform", []byte(`{"db_password":"hunter2"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"db_password":"hunter2"}`, string(out))
+
+	out, err = p.Call(ctx, "check_import", []byte(`{}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"allow":false,"reason":"denied by test"}`, string(out))
+
+	_, err = p.Call(ctx, "validate", []byte(`{}`))
+	assert.ErrorContains(t, err, "must export memory, alloc and validate")
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(context.Background(), "testdata/missing.wasm")
+	assert.ErrorContains(t, err, "failed to read wasm plugin")
+
+	_, err = Load(context.Background(), "testdata/plugin.wat")
+	assert.ErrorContains(t, err, "failed to compile wasm plugin")
+}
@@ -37,9 +37,19 @@ type SecretChange struct {
 	CurrentKeys []string `json:"current_keys,omitempty"`
 	DesiredKeys []string `json:"desired_keys,omitempty"`
 	
-	// Hash comparison for change detection without exposing values
+	// Hash comparison for change detection without exposing values, set by
+	// Hasher.DiffSecrets
 	CurrentHash string `json:"current_hash,omitempty"`
 	DesiredHash string `json:"desired_hash,omitempty"`
+
+	// The same for each key's value
+	CurrentKeyHashes map[string]string `json:"current_key_hashes,omitempty"`
+	DesiredKeyHashes map[string]string `json:"desired_key_hashes,omitempty"`
+
+	// Masked previews of the changed values by key ("<value>" for values
+	// that are not maps), set by AddPreviews
+	CurrentPreviews map[string]string `json:"current_previews,omitempty"`
+	DesiredPreviews map[string]string `json:"desired_previews,omitempty"`
 }
 
 // TargetDiff represents all changes for a single target
@@ -47,6 +57,8 @@ type TargetDiff struct {
 	Target  string          `json:"target"`
 	Changes []SecretChange  `json:"changes"`
 	Summary ChangeSummary   `json:"summary"`
+	// Owners are accountable for the target's changes
+	Owners []string `json:"owners,omitempty"`
 }
 
 // ChangeSummary provides statistics about changes
@@ -74,6 +86,20 @@ type PipelineDiff struct {
 	Summary     ChangeSummary `json:"summary"`
 	DryRun      bool          `json:"dry_run"`
 	ConfigPath  string        `json:"config_path,omitempty"`
+	// Names previews the destination names of renamed or colliding secrets
+	Names []DestinationName `json:"names,omitempty"`
+}
+
+// DestinationName is the name a merged secret is written under in a destination
+type DestinationName struct {
+	Target      string `json:"target"`
+	Destination string `json:"destination"`
+	// Secret is the secret's name in the merge store
+	Secret string `json:"secret"`
+	Name   string `json:"name"`
+	// CollidesWith lists the other targets writing the same name to the
+	// same account and region
+	CollidesWith []string `json:"collides_with,omitempty"`
 }
 
 // IsZeroSum returns true if the entire pipeline has no changes
@@ -81,15 +107,22 @@ func (p *PipelineDiff) IsZeroSum() bool {
 	return p.Summary.IsZeroSum()
 }
 
+// Exit codes shared by every vss command
+const (
+	ExitCodeNoChanges = 0 // Success, nothing changed (zero-sum)
+	ExitCodeChanges   = 1 // Changes detected
+	ExitCodeError     = 2 // Execution, validation or usage error
+)
+
 // ExitCode returns an appropriate exit code for CI/CD:
 //   - 0: No changes (zero-sum)
 //   - 1: Changes detected
 //   - 2: Errors occurred (not handled here)
 func (p *PipelineDiff) ExitCode() int {
 	if p.IsZeroSum() {
-		return 0
+		return ExitCodeNoChanges
 	}
-	return 1
+	return ExitCodeChanges
 }
 
 // AddTargetDiff adds a target diff and updates the summary
@@ -102,7 +135,8 @@ func (p *PipelineDiff) AddTargetDiff(td TargetDiff) {
 	p.Summary.Total += td.Summary.Total
 }
 
-// DiffSecrets compares two secret maps and returns the changes
+// DiffSecrets compares two secret maps and returns the changes, without
+// hashes (see Hasher.DiffSecrets)
 func DiffSecrets(current, desired map[string]interface{}) []SecretChange {
 	var changes []SecretChange
 	seen := make(map[string]bool)
@@ -239,17 +273,35 @@ const (
 	OutputFormatCompact OutputFormat = "compact" // One-line summary
 )
 
-// FormatDiff formats the pipeline diff according to the specified format
+// ParseOutputFormat converts a string to an OutputFormat, rejecting unknown formats
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case OutputFormatHuman, OutputFormatJSON, OutputFormatGitHub, OutputFormatCompact:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (must be human, json, github or compact)", s)
+}
+
+// FormatDiff formats the pipeline diff according to the specified format,
+// sampling human and GitHub output down to DefaultMaxLines
 func FormatDiff(diff *PipelineDiff, format OutputFormat) string {
+	return FormatDiffLines(diff, format, DefaultMaxLines)
+}
+
+// FormatDiffLines formats the pipeline diff according to the specified format.
+// Human and GitHub output longer than maxLines shows only the first changes
+// of each type per target, with counts of the rest; maxLines <= 0 shows every
+// change. JSON output is always complete.
+func FormatDiffLines(diff *PipelineDiff, format OutputFormat, maxLines int) string {
 	switch format {
 	case OutputFormatJSON:
 		return formatJSON(diff)
 	case OutputFormatGitHub:
-		return formatGitHub(diff)
+		return sampleToFit(maxLines, func(perType int) string { return formatGitHub(diff, perType) })
 	case OutputFormatCompact:
 		return formatCompact(diff)
 	default:
-		return formatHuman(diff)
+		return sampleToFit(maxLines, func(perType int) string { return formatHuman(diff, perType) })
 	}
 }
 
@@ -261,8 +313,11 @@ func formatJSON(diff *PipelineDiff) string {
 	return string(data)
 }
 
-func formatHuman(diff *PipelineDiff) string {
+// formatHuman shows at most perType changes of each type per target, and
+// every change when perType is negative
+func formatHuman(diff *PipelineDiff, perType int) string {
 	var sb strings.Builder
+	sampled := false
 
 	// Header
 	if diff.DryRun {
@@ -279,6 +334,23 @@ func formatHuman(diff *PipelineDiff) string {
 	sb.WriteString(fmt.Sprintf("  Total:     %d\n", diff.Summary.Total))
 	sb.WriteString("\n")
 
+	if len(diff.Names) > 0 {
+		sb.WriteString("Destination Names\n")
+		sb.WriteString(strings.Repeat("-", 40) + "\n")
+		names, omitted := sampleNames(diff.Names, perType)
+		for _, n := range names {
+			sb.WriteString(fmt.Sprintf("  %s %s -> %s:%s\n", n.Target, n.Secret, n.Destination, n.Name))
+			if len(n.CollidesWith) > 0 {
+				sb.WriteString(fmt.Sprintf("    ⚠️  collides with %s\n", strings.Join(n.CollidesWith, ", ")))
+			}
+		}
+		if omitted > 0 {
+			sampled = true
+			sb.WriteString(fmt.Sprintf("  ... %d more names not shown\n", omitted))
+		}
+		sb.WriteString("\n")
+	}
+
 	if diff.IsZeroSum() {
 		sb.WriteString("✅ ZERO-SUM: No changes detected\n")
 		return sb.String()
@@ -293,9 +365,13 @@ func formatHuman(diff *PipelineDiff) string {
 		}
 
 		sb.WriteString(fmt.Sprintf("Target: %s\n", td.Target))
+		if len(td.Owners) > 0 {
+			sb.WriteString(fmt.Sprintf("Owners: %s\n", strings.Join(td.Owners, ", ")))
+		}
 		sb.WriteString(strings.Repeat("-", 40) + "\n")
 
-		for _, c := range td.Changes {
+		changes, omitted := sampleChanges(td.Changes, perType)
+		for _, c := range changes {
 			if c.ChangeType == ChangeTypeUnchanged {
 				continue
 			}
@@ -306,8 +382,10 @@ func formatHuman(diff *PipelineDiff) string {
 				if len(c.DesiredKeys) > 0 {
 					sb.WriteString(fmt.Sprintf("    keys: %v\n", c.DesiredKeys))
 				}
+				writePreviews(&sb, c)
 			case ChangeTypeRemoved:
 				sb.WriteString(fmt.Sprintf("  - %s (removed)\n", c.Path))
+				writePreviews(&sb, c)
 			case ChangeTypeModified:
 				sb.WriteString(fmt.Sprintf("  ~ %s (modified)\n", c.Path))
 				if len(c.KeysAdded) > 0 {
@@ -319,15 +397,53 @@ func formatHuman(diff *PipelineDiff) string {
 				if len(c.KeysModified) > 0 {
 					sb.WriteString(fmt.Sprintf("    ~ keys: %v\n", c.KeysModified))
 				}
+				writePreviews(&sb, c)
 			}
 		}
+		for _, o := range omitted {
+			sampled = true
+			sb.WriteString(fmt.Sprintf("  ... %d more %s not shown\n", o.count, o.changeType))
+		}
 		sb.WriteString("\n")
 	}
 
+	if sampled && perType > 0 {
+		sb.WriteString(fmt.Sprintf("Output sampled to %d changes of each type per target; JSON output lists every change\n", perType))
+	} else if sampled {
+		sb.WriteString("Output sampled to counts only; JSON output lists every change\n")
+	}
+
 	return sb.String()
 }
 
-func formatGitHub(diff *PipelineDiff) string {
+// writePreviews lists a change's masked value previews by key, as
+// "current -> desired" with "(none)" for a side the key does not exist on
+func writePreviews(sb *strings.Builder, c SecretChange) {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, previews := range []map[string]string{c.CurrentPreviews, c.DesiredPreviews} {
+		for k := range previews {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	side := func(previews map[string]string, k string) string {
+		if p, ok := previews[k]; ok {
+			return p
+		}
+		return "(none)"
+	}
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("      %s: %s -> %s\n", k, side(c.CurrentPreviews, k), side(c.DesiredPreviews, k)))
+	}
+}
+
+// formatGitHub annotates at most perType changes of each type per target, and
+// every change when perType is negative
+func formatGitHub(diff *PipelineDiff, perType int) string {
 	var sb strings.Builder
 
 	// Summary as workflow output
@@ -352,10 +468,15 @@ func formatGitHub(diff *PipelineDiff) string {
 			continue
 		}
 
-		sb.WriteString(fmt.Sprintf("::group::Target: %s (%d changes)\n", td.Target,
-			td.Summary.Added+td.Summary.Removed+td.Summary.Modified))
+		owners := ""
+		if len(td.Owners) > 0 {
+			owners = fmt.Sprintf(" [owners: %s]", strings.Join(td.Owners, ", "))
+		}
+		sb.WriteString(fmt.Sprintf("::group::Target: %s (%d changes)%s\n", td.Target,
+			td.Summary.Added+td.Summary.Removed+td.Summary.Modified, owners))
 
-		for _, c := range td.Changes {
+		changes, omitted := sampleChanges(td.Changes, perType)
+		for _, c := range changes {
 			switch c.ChangeType {
 			case ChangeTypeAdded:
 				sb.WriteString(fmt.Sprintf("::notice::+ %s (new secret)\n", c.Path))
@@ -365,6 +486,9 @@ func formatGitHub(diff *PipelineDiff) string {
 				sb.WriteString(fmt.Sprintf("::notice::~ %s (modified)\n", c.Path))
 			}
 		}
+		for _, o := range omitted {
+			sb.WriteString(fmt.Sprintf("::notice::... %d more %s not shown\n", o.count, o.changeType))
+		}
 
 		sb.WriteString("::endgroup::\n")
 	}
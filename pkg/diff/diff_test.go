@@ -2,6 +2,7 @@ package diff
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -165,6 +166,94 @@ func TestDiffSecrets_ComplexScenario(t *testing.T) {
 	}
 }
 
+func TestHasherDiffSecrets(t *testing.T) {
+	current := map[string]interface{}{
+		"db":  map[string]interface{}{"password": "old", "user": "app"},
+		"old": "legacy",
+	}
+	desired := map[string]interface{}{
+		"db": map[string]interface{}{"password": "new", "user": "app"},
+	}
+
+	h := NewHasher("audit-salt")
+	changes := h.DiffSecrets(current, desired)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+	db := changes[0]
+	if db.CurrentHash == "" || db.DesiredHash == "" || db.CurrentHash == db.DesiredHash {
+		t.Errorf("expected differing secret hashes, got %q and %q", db.CurrentHash, db.DesiredHash)
+	}
+	if db.CurrentKeyHashes["user"] != db.DesiredKeyHashes["user"] {
+		t.Error("expected an unchanged key to hash the same")
+	}
+	if db.CurrentKeyHashes["password"] == db.DesiredKeyHashes["password"] {
+		t.Error("expected a changed key to hash differently")
+	}
+	if strings.Contains(db.CurrentKeyHashes["password"], "old") {
+		t.Error("hash must not contain the value")
+	}
+	if removed := changes[1]; removed.CurrentHash == "" || removed.DesiredHash != "" || removed.CurrentKeyHashes != nil {
+		t.Errorf("expected only a current hash for a removed string secret, got %+v", removed)
+	}
+
+	// Hashes are stable for a salt and differ between salts
+	if again := NewHasher("audit-salt").DiffSecrets(current, desired); again[0].DesiredHash != db.DesiredHash {
+		t.Error("expected the same hash across runs")
+	}
+	if other := NewHasher("other-salt").DiffSecrets(current, desired); other[0].DesiredHash == db.DesiredHash {
+		t.Error("expected a different hash under another salt")
+	}
+
+	// Without a salt nothing is hashed
+	if changes := NewHasher("").DiffSecrets(current, desired); changes[0].CurrentHash != "" || changes[0].CurrentKeyHashes != nil {
+		t.Error("expected no hashes without a salt")
+	}
+}
+
+func TestAddPreviews(t *testing.T) {
+	current := map[string]interface{}{
+		"db":  map[string]interface{}{"password": "correct-horse", "user": "app-reader"},
+		"old": "legacy-api-token",
+	}
+	desired := map[string]interface{}{
+		"db":  map[string]interface{}{"password": "battery-staple", "user": "app-reader", "port": 5432},
+		"new": map[string]interface{}{"pin": "1234"},
+	}
+
+	changes := DiffSecrets(current, desired)
+	AddPreviews(changes, current, desired)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	db := changes[0]
+	if db.CurrentPreviews["password"] != "co****se" || db.DesiredPreviews["password"] != "ba****le" {
+		t.Errorf("expected masked previews of the changed password, got %v and %v", db.CurrentPreviews, db.DesiredPreviews)
+	}
+	if _, ok := db.DesiredPreviews["user"]; ok {
+		t.Error("expected no preview of an unchanged key")
+	}
+	if db.DesiredPreviews["port"] != "****" {
+		t.Errorf("expected short values masked entirely, got %q", db.DesiredPreviews["port"])
+	}
+	if changes[1].DesiredPreviews["pin"] != "****" || changes[1].CurrentPreviews != nil {
+		t.Errorf("expected only desired previews of an added secret, got %+v", changes[1])
+	}
+	if changes[2].CurrentPreviews["<value>"] != "le****en" {
+		t.Errorf("expected a preview of a removed string secret, got %v", changes[2].CurrentPreviews)
+	}
+
+	pd := &PipelineDiff{}
+	pd.AddTargetDiff(TargetDiff{Target: "Prod", Changes: changes, Summary: ComputeSummary(changes)})
+	out := FormatDiff(pd, OutputFormatHuman)
+	if !strings.Contains(out, "password: co****se -> ba****le") || !strings.Contains(out, "port: (none) -> ****") {
+		t.Errorf("expected previews in human output, got:\n%s", out)
+	}
+	if strings.Contains(out, "correct-horse") || strings.Contains(out, "battery-staple") {
+		t.Error("output must not contain values")
+	}
+}
+
 func TestPipelineDiff_ZeroSum(t *testing.T) {
 	diff := &PipelineDiff{
 		Summary: ChangeSummary{
@@ -210,6 +299,7 @@ func TestFormatDiff_Human(t *testing.T) {
 					{Path: "api-keys/old", ChangeType: ChangeTypeRemoved},
 				},
 				Summary: ChangeSummary{Added: 1, Removed: 1, Total: 2},
+				Owners:  []string{"platform@example.com", "@serverless-team"},
 			},
 		},
 		Summary: ChangeSummary{Added: 1, Removed: 1, Total: 2},
@@ -229,6 +319,74 @@ func TestFormatDiff_Human(t *testing.T) {
 	if !strings.Contains(output, "- api-keys/old") {
 		t.Error("expected removed secret")
 	}
+	if !strings.Contains(output, "Owners: platform@example.com, @serverless-team") {
+		t.Error("expected target owners")
+	}
+}
+
+func TestFormatDiff_HumanNames(t *testing.T) {
+	diff := &PipelineDiff{
+		DryRun: true,
+		Names: []DestinationName{
+			{Target: "Api", Destination: "aws:111111111111", Secret: "db", Name: "apps/db", CollidesWith: []string{"Web"}},
+		},
+	}
+
+	output := FormatDiff(diff, OutputFormatHuman)
+
+	if !strings.Contains(output, "Api db -> aws:111111111111:apps/db") {
+		t.Error("expected destination name preview")
+	}
+	if !strings.Contains(output, "collides with Web") {
+		t.Error("expected collision warning")
+	}
+	if !strings.Contains(output, "ZERO-SUM") {
+		t.Error("names alone are not changes")
+	}
+}
+
+func TestFormatDiff_Sampled(t *testing.T) {
+	td := TargetDiff{Target: "Serverless_Prod"}
+	for i := 0; i < 5000; i++ {
+		td.Changes = append(td.Changes, SecretChange{Path: fmt.Sprintf("app/%04d", i), ChangeType: ChangeTypeModified})
+	}
+	td.Changes = append(td.Changes, SecretChange{Path: "app/new", ChangeType: ChangeTypeAdded})
+	td.Summary = ComputeSummary(td.Changes)
+	diff := &PipelineDiff{}
+	diff.AddTargetDiff(td)
+
+	output := FormatDiffLines(diff, OutputFormatHuman, 100)
+	if lines := strings.Count(output, "\n"); lines > 100 {
+		t.Errorf("expected at most 100 lines, got %d", lines)
+	}
+	if !strings.Contains(output, "Modified:  5000") {
+		t.Error("expected the full modified count in the summary")
+	}
+	if !strings.Contains(output, "~ app/0000 (modified)") || strings.Contains(output, "~ app/4999 (modified)") {
+		t.Error("expected only the first modified secrets")
+	}
+	if !strings.Contains(output, "+ app/new (new secret)") {
+		t.Error("expected every change type to be sampled")
+	}
+	if !strings.Contains(output, "more modified not shown") || !strings.Contains(output, "Output sampled to") {
+		t.Error("expected counts of the omitted changes")
+	}
+
+	output = FormatDiffLines(diff, OutputFormatGitHub, 100)
+	if lines := strings.Count(output, "\n"); lines > 100 {
+		t.Errorf("expected at most 100 GitHub lines, got %d", lines)
+	}
+	if !strings.Contains(output, "::notice::... ") {
+		t.Error("expected a GitHub notice for the omitted changes")
+	}
+
+	// JSON and unlimited output keep every change
+	if !strings.Contains(FormatDiffLines(diff, OutputFormatJSON, 100), "app/4999") {
+		t.Error("expected complete JSON output")
+	}
+	if output := FormatDiffLines(diff, OutputFormatHuman, 0); !strings.Contains(output, "~ app/4999 (modified)") || strings.Contains(output, "not shown") {
+		t.Error("expected every change without a limit")
+	}
 }
 
 func TestFormatDiff_JSON(t *testing.T) {
@@ -326,3 +484,14 @@ func TestChangeSummary_IsZeroSum(t *testing.T) {
 		})
 	}
 }
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, s := range []string{"human", "json", "GitHub", " compact "} {
+		if _, err := ParseOutputFormat(s); err != nil {
+			t.Errorf("ParseOutputFormat(%q) unexpected error: %v", s, err)
+		}
+	}
+	if _, err := ParseOutputFormat("yaml"); err == nil {
+		t.Error("ParseOutputFormat(\"yaml\") expected error")
+	}
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Hasher fingerprints secret values for diffs with HMAC-SHA256 under a salt,
+// so runs hashed with the same salt can be compared value by value without
+// the diff ever holding plaintext. Without the salt a hash cannot be checked
+// against guessed values.
+type Hasher struct {
+	salt []byte
+}
+
+// NewHasher returns a Hasher for the salt, or nil (no hashes) if it is empty
+func NewHasher(salt string) *Hasher {
+	if salt == "" {
+		return nil
+	}
+	return &Hasher{salt: []byte(salt)}
+}
+
+// Hash returns the hex HMAC of a value: strings as they are, anything else
+// as JSON (whose object keys are sorted, so equal values hash the same). A
+// nil Hasher returns "".
+func (h *Hasher) Hash(v interface{}) string {
+	if h == nil {
+		return ""
+	}
+	var b []byte
+	if s, ok := v.(string); ok {
+		b = []byte(s)
+	} else {
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return ""
+		}
+	}
+	mac := hmac.New(sha256.New, h.salt)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// keyHashes hashes each key of a map value; other values have no keys
+func (h *Hasher) keyHashes(v interface{}) map[string]string {
+	m, ok := v.(map[string]interface{})
+	if h == nil || !ok || len(m) == 0 {
+		return nil
+	}
+	hashes := make(map[string]string, len(m))
+	for k, kv := range m {
+		hashes[k] = h.Hash(kv)
+	}
+	return hashes
+}
+
+// DiffSecrets compares two secret maps like DiffSecrets, adding the hash of
+// each secret and of each of its keys on the side(s) it exists
+func (h *Hasher) DiffSecrets(current, desired map[string]interface{}) []SecretChange {
+	changes := DiffSecrets(current, desired)
+	if h == nil {
+		return changes
+	}
+	for i := range changes {
+		c := &changes[i]
+		if v, ok := current[c.Path]; ok {
+			c.CurrentHash = h.Hash(v)
+			c.CurrentKeyHashes = h.keyHashes(v)
+		}
+		if v, ok := desired[c.Path]; ok {
+			c.DesiredHash = h.Hash(v)
+			c.DesiredKeyHashes = h.keyHashes(v)
+		}
+	}
+	return changes
+}
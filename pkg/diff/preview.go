@@ -0,0 +1,68 @@
+package diff
+
+import (
+	"encoding/json"
+
+	"github.com/jbcom/secretsync/pkg/redact"
+)
+
+// valueKey is the preview key of a secret whose value is not a map
+const valueKey = "<value>"
+
+// AddPreviews sets the masked previews (see redact.Preview) of the values
+// that changed: each added, removed or modified key of a modified secret and
+// every key of an added or removed one, on the side(s) it exists. Unchanged
+// secrets and keys get none.
+func AddPreviews(changes []SecretChange, current, desired map[string]interface{}) {
+	for i := range changes {
+		c := &changes[i]
+		var keys []string
+		switch c.ChangeType {
+		case ChangeTypeAdded:
+			keys = c.DesiredKeys
+		case ChangeTypeRemoved:
+			keys = c.CurrentKeys
+		case ChangeTypeModified:
+			keys = append(append(append([]string{}, c.KeysAdded...), c.KeysRemoved...), c.KeysModified...)
+		default:
+			continue
+		}
+		c.CurrentPreviews = previews(current[c.Path], keys)
+		c.DesiredPreviews = previews(desired[c.Path], keys)
+	}
+}
+
+// previews masks the given keys of a map value, or a value that is not a map
+// under valueKey
+func previews(v interface{}, keys []string) map[string]string {
+	if v == nil {
+		return nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return map[string]string{valueKey: preview(v)}
+	}
+	out := make(map[string]string)
+	for _, k := range keys {
+		if kv, ok := m[k]; ok {
+			out[k] = preview(kv)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// preview masks a string as it is and anything else as JSON
+func preview(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return redact.Preview("")
+		}
+		s = string(b)
+	}
+	return redact.Preview(s)
+}
@@ -0,0 +1,80 @@
+package diff
+
+import "strings"
+
+// DefaultMaxLines is the length human and GitHub output is sampled down to
+// unless the caller picks another limit
+const DefaultMaxLines = 1000
+
+// omittedChanges counts the changes of one type left out of sampled output
+type omittedChanges struct {
+	changeType ChangeType
+	count      int
+}
+
+// sampleChanges keeps the first perType changes of each type, in order, and
+// counts the rest by type (added, removed, then modified). A negative perType
+// keeps every change. Unchanged secrets are never shown, so never counted.
+func sampleChanges(changes []SecretChange, perType int) ([]SecretChange, []omittedChanges) {
+	if perType < 0 {
+		return changes, nil
+	}
+	shown := make(map[ChangeType]int)
+	left := make(map[ChangeType]int)
+	var kept []SecretChange
+	for _, c := range changes {
+		if c.ChangeType == ChangeTypeUnchanged {
+			continue
+		}
+		if shown[c.ChangeType] < perType {
+			shown[c.ChangeType]++
+			kept = append(kept, c)
+			continue
+		}
+		left[c.ChangeType]++
+	}
+	var omitted []omittedChanges
+	for _, ct := range []ChangeType{ChangeTypeAdded, ChangeTypeRemoved, ChangeTypeModified} {
+		if left[ct] > 0 {
+			omitted = append(omitted, omittedChanges{changeType: ct, count: left[ct]})
+		}
+	}
+	return kept, omitted
+}
+
+// sampleNames keeps the first perType destination names of each target, and
+// counts the rest. A negative perType keeps every name.
+func sampleNames(names []DestinationName, perType int) ([]DestinationName, int) {
+	if perType < 0 {
+		return names, 0
+	}
+	shown := make(map[string]int)
+	var kept []DestinationName
+	omitted := 0
+	for _, n := range names {
+		if shown[n.Target] < perType {
+			shown[n.Target]++
+			kept = append(kept, n)
+			continue
+		}
+		omitted++
+	}
+	return kept, omitted
+}
+
+// sampleToFit renders output with every change and, when that is longer than
+// maxLines, halves the changes shown of each type per target (starting from
+// maxLines) until it fits, down to counts alone. maxLines <= 0 never samples.
+func sampleToFit(maxLines int, render func(perType int) string) string {
+	out := render(-1)
+	if maxLines <= 0 || strings.Count(out, "\n") <= maxLines {
+		return out
+	}
+	for perType := maxLines; perType > 0; perType /= 2 {
+		out = render(perType)
+		if strings.Count(out, "\n") <= maxLines {
+			return out
+		}
+	}
+	return render(0)
+}
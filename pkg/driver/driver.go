@@ -13,6 +13,10 @@ var (
 		DriverNameHttp,
 		DriverNameDoppler,
 		DriverNameIdentityCenter,
+		DriverNameKubernetes,
+		DriverNameGRPC,
+		DriverNameAzureKeyVault,
+		DriverNameCloudflare,
 	}
 )
 
@@ -26,6 +30,10 @@ const (
 	DriverNameHttp           DriverName = "http"
 	DriverNameDoppler        DriverName = "doppler"
 	DriverNameIdentityCenter DriverName = "awsIdentityCenter"
+	DriverNameKubernetes     DriverName = "kubernetes"
+	DriverNameGRPC           DriverName = "grpc"
+	DriverNameAzureKeyVault  DriverName = "azureKeyVault"
+	DriverNameCloudflare     DriverName = "cloudflare"
 )
 
 func DriverIsSupported(driver DriverName) bool {
@@ -0,0 +1,343 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	log "github.com/sirupsen/logrus"
+)
+
+// AccessReviewAction is the action an access review checks principals for
+const AccessReviewAction = "secretsmanager:GetSecretValue"
+
+// AccessReview lists who can read each secret the pipeline syncs into
+// Secrets Manager, for periodic access reviews
+type AccessReview struct {
+	Generated time.Time      `json:"generated"`
+	Action    string         `json:"action"`
+	Secrets   []SecretAccess `json:"secrets"`
+}
+
+// SecretAccess is one synced secret in a destination account and the
+// principals able to read it
+type SecretAccess struct {
+	Target      string         `json:"target"`
+	Destination string         `json:"destination"`
+	AccountID   string         `json:"account_id"`
+	Secret      string         `json:"secret"`
+	ARN         string         `json:"arn,omitempty"`
+	Readers     []SecretReader `json:"readers"`
+	// Error is why the secret's readers could not be resolved
+	Error string `json:"error,omitempty"`
+}
+
+// SecretReader is a principal that can read a secret
+type SecretReader struct {
+	Principal string `json:"principal"`
+	// Via is "iam" for a role or user in the secret's account that policy
+	// simulation allows (identity and resource policy together), or
+	// "resource_policy" for a principal outside the account that the
+	// secret's resource policy grants
+	Via string `json:"via"`
+}
+
+// Errors returns the secrets whose readers could not be resolved
+func (r *AccessReview) Errors() []string {
+	var errs []string
+	for _, s := range r.Secrets {
+		if s.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s -> %s: %s: %s", s.Target, s.Destination, s.Secret, s.Error))
+		}
+	}
+	return errs
+}
+
+// accessSimulator resolves the principals in a destination account and
+// whether they can read a secret
+type accessSimulator interface {
+	// IAMPrincipals lists the ARNs of the roles and users in the destination account
+	IAMPrincipals(ctx context.Context, target Target, d Destination) ([]string, error)
+	// SecretPolicy returns a secret's ARN and resource policy ("" if it has none)
+	SecretPolicy(ctx context.Context, target Target, d Destination, name string) (arn, policy string, err error)
+	// CanRead simulates AccessReviewAction by principal on the secret
+	CanRead(ctx context.Context, target Target, d Destination, principal, secretARN, policy string) (bool, error)
+}
+
+// AccessReview resolves the readers of every secret the given targets (all
+// when empty) sync into Secrets Manager. Secrets are taken from the merge
+// store; failures are recorded on the affected secrets rather than aborting
+// the review.
+func (p *Pipeline) AccessReview(ctx context.Context, targets []string) (*AccessReview, error) {
+	targets, err := p.selectTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.accessSimulator == nil {
+		p.accessSimulator = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	return buildAccessReview(ctx, p.config, store, p.accessSimulator, targets, time.Now().UTC()), nil
+}
+
+func buildAccessReview(ctx context.Context, c *Config, store mergeStore, sim accessSimulator, targets []string, now time.Time) *AccessReview {
+	review := &AccessReview{Generated: now, Action: AccessReviewAction, Secrets: []SecretAccess{}}
+	// Principals are listed once per account; IAM is global
+	type principalList struct {
+		arns []string
+		err  error
+	}
+	principals := make(map[string]principalList)
+
+	for _, name := range targets {
+		target := c.Targets[name]
+		l := log.WithFields(log.Fields{
+			"action": "buildAccessReview",
+			"target": name,
+		})
+
+		secrets, listErr := store.ListSecrets(ctx, name)
+		if listErr != nil {
+			l.WithError(listErr).Error("Failed to list merged secrets")
+		}
+		for _, d := range target.ResolvedDestinations() {
+			if !d.requiresAccountID() || d.Kubernetes != nil {
+				continue
+			}
+			if listErr != nil {
+				review.Secrets = append(review.Secrets, SecretAccess{
+					Target:      name,
+					Destination: d.Label(),
+					AccountID:   d.AccountID,
+					Readers:     []SecretReader{},
+					Error:       listErr.Error(),
+				})
+				continue
+			}
+			pl, ok := principals[d.AccountID]
+			if !ok {
+				pl.arns, pl.err = sim.IAMPrincipals(ctx, target, d)
+				if pl.err != nil {
+					l.WithError(pl.err).WithField("account_id", d.AccountID).Error("Failed to list IAM principals")
+				}
+				principals[d.AccountID] = pl
+			}
+			for _, secret := range secrets {
+				access := SecretAccess{
+					Target:      name,
+					Destination: d.Label(),
+					AccountID:   d.AccountID,
+					Secret:      secret,
+					Readers:     []SecretReader{},
+				}
+				if pl.err != nil {
+					access.Error = pl.err.Error()
+				} else if err := resolveReaders(ctx, sim, target, d, pl.arns, &access); err != nil {
+					l.WithError(err).WithField("secret", secret).Error("Failed to resolve secret readers")
+					access.Error = err.Error()
+				}
+				review.Secrets = append(review.Secrets, access)
+			}
+		}
+	}
+	return review
+}
+
+// resolveReaders fills in the ARN and readers of one secret
+func resolveReaders(ctx context.Context, sim accessSimulator, target Target, d Destination, principals []string, access *SecretAccess) error {
+	arn, policy, err := sim.SecretPolicy(ctx, target, d, access.Secret)
+	if err != nil {
+		return err
+	}
+	access.ARN = arn
+	for _, principal := range principals {
+		allowed, err := sim.CanRead(ctx, target, d, principal, arn, policy)
+		if err != nil {
+			return fmt.Errorf("failed to simulate %s: %w", principal, err)
+		}
+		if allowed {
+			access.Readers = append(access.Readers, SecretReader{Principal: principal, Via: "iam"})
+		}
+	}
+	external, err := resourcePolicyReaders(policy, d.AccountID)
+	if err != nil {
+		return err
+	}
+	for _, principal := range external {
+		access.Readers = append(access.Readers, SecretReader{Principal: principal, Via: "resource_policy"})
+	}
+	return nil
+}
+
+// policyStrings is an IAM policy element that may be a string or a list
+type policyStrings []string
+
+func (s *policyStrings) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*s = policyStrings{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*s = many
+	return nil
+}
+
+type policyStatement struct {
+	Effect    string          `json:"Effect"`
+	Action    policyStrings   `json:"Action"`
+	Principal json.RawMessage `json:"Principal"`
+}
+
+// resourcePolicyReaders returns the principals outside accountID that a
+// resource policy allows AccessReviewAction. Principals in the account are
+// covered by policy simulation instead. "*" is returned as is.
+func resourcePolicyReaders(policy, accountID string) ([]string, error) {
+	if policy == "" {
+		return nil, nil
+	}
+	var doc struct {
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse resource policy: %w", err)
+	}
+	var statements []policyStatement
+	if err := json.Unmarshal(doc.Statement, &statements); err != nil {
+		var one policyStatement
+		if err := json.Unmarshal(doc.Statement, &one); err != nil {
+			return nil, fmt.Errorf("failed to parse resource policy: %w", err)
+		}
+		statements = []policyStatement{one}
+	}
+
+	seen := make(map[string]bool)
+	var readers []string
+	for _, st := range statements {
+		if st.Effect != "Allow" || !policyAllowsAction(st.Action, AccessReviewAction) {
+			continue
+		}
+		for _, principal := range policyPrincipals(st.Principal) {
+			if strings.HasPrefix(principal, "arn:aws:iam::"+accountID+":") || seen[principal] {
+				continue
+			}
+			seen[principal] = true
+			readers = append(readers, principal)
+		}
+	}
+	sort.Strings(readers)
+	return readers, nil
+}
+
+// policyAllowsAction matches an action against a statement's actions,
+// which may use wildcards
+func policyAllowsAction(actions policyStrings, action string) bool {
+	for _, a := range actions {
+		if ok, _ := path.Match(strings.ToLower(a), strings.ToLower(action)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// policyPrincipals returns the AWS principals of a statement as ARNs.
+// Account IDs become the account's root ARN.
+func policyPrincipals(raw json.RawMessage) []string {
+	var wildcard string
+	if json.Unmarshal(raw, &wildcard) == nil {
+		return []string{wildcard}
+	}
+	var principal struct {
+		AWS policyStrings `json:"AWS"`
+	}
+	if json.Unmarshal(raw, &principal) != nil {
+		return nil
+	}
+	out := make([]string, 0, len(principal.AWS))
+	for _, p := range principal.AWS {
+		if len(p) == 12 && strings.Trim(p, "0123456789") == "" {
+			p = fmt.Sprintf("arn:aws:iam::%s:root", p)
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func (r *liveReadinessProbe) IAMPrincipals(ctx context.Context, target Target, d Destination) ([]string, error) {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return nil, err
+	}
+	var arns []string
+	paginator := iam.NewGetAccountAuthorizationDetailsPaginator(iam.NewFromConfig(cfg), &iam.GetAccountAuthorizationDetailsInput{
+		Filter: []iamtypes.EntityType{iamtypes.EntityTypeRole, iamtypes.EntityTypeUser},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list IAM principals: %w", err)
+		}
+		for _, role := range page.RoleDetailList {
+			arns = append(arns, aws.ToString(role.Arn))
+		}
+		for _, user := range page.UserDetailList {
+			arns = append(arns, aws.ToString(user.Arn))
+		}
+	}
+	sort.Strings(arns)
+	return arns, nil
+}
+
+func (r *liveReadinessProbe) SecretPolicy(ctx context.Context, target Target, d Destination, name string) (string, string, error) {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return "", "", err
+	}
+	output, err := secretsmanager.NewFromConfig(cfg).GetResourcePolicy(ctx, &secretsmanager.GetResourcePolicyInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get resource policy: %w", err)
+	}
+	return aws.ToString(output.ARN), aws.ToString(output.ResourcePolicy), nil
+}
+
+func (r *liveReadinessProbe) CanRead(ctx context.Context, target Target, d Destination, principal, secretARN, policy string) (bool, error) {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return false, err
+	}
+	input := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     []string{AccessReviewAction},
+		ResourceArns:    []string{secretARN},
+		ResourceOwner:   aws.String(fmt.Sprintf("arn:aws:iam::%s:root", d.AccountID)),
+	}
+	if policy != "" {
+		input.ResourcePolicy = aws.String(policy)
+	}
+	output, err := iam.NewFromConfig(cfg).SimulatePrincipalPolicy(ctx, input)
+	if err != nil {
+		return false, err
+	}
+	for _, result := range output.EvaluationResults {
+		if result.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			return false, nil
+		}
+	}
+	return len(output.EvaluationResults) > 0, nil
+}
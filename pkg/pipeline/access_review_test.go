@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAccessSimulator grants reads from a fixed principal -> secret ARN table
+type fakeAccessSimulator struct {
+	principals map[string][]string
+	policies   map[string]string
+	readers    map[string][]string
+	listed     int
+}
+
+func (f *fakeAccessSimulator) IAMPrincipals(_ context.Context, _ Target, d Destination) ([]string, error) {
+	f.listed++
+	p, ok := f.principals[d.AccountID]
+	if !ok {
+		return nil, fmt.Errorf("AccessDenied")
+	}
+	return p, nil
+}
+
+func (f *fakeAccessSimulator) SecretPolicy(_ context.Context, _ Target, d Destination, name string) (string, string, error) {
+	arn := fmt.Sprintf("arn:aws:secretsmanager:us-east-1:%s:secret:%s", d.AccountID, name)
+	return arn, f.policies[arn], nil
+}
+
+func (f *fakeAccessSimulator) CanRead(_ context.Context, _ Target, _ Destination, principal, secretARN, _ string) (bool, error) {
+	for _, arn := range f.readers[principal] {
+		if arn == secretARN {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestBuildAccessReview(t *testing.T) {
+	admin := "arn:aws:iam::111111111111:role/Admin"
+	app := "arn:aws:iam::111111111111:role/App"
+	cfg := &Config{
+		Targets: map[string]Target{
+			"Stg": {Destinations: []Destination{
+				{AccountID: "111111111111"},
+				{GitHub: &GitHubDestination{Owner: "org", Repo: "app"}},
+			}},
+			"Dev":  {AccountID: "111111111111"},
+			"Prod": {AccountID: "222222222222"},
+		},
+	}
+	store := memMergeStore{
+		"Stg":  {"db": {"password": "hunter2"}, "api": {"token": "abc"}},
+		"Dev":  {"db": {"password": "dev"}},
+		"Prod": {"db": {"password": "prod"}},
+	}
+	sim := &fakeAccessSimulator{
+		principals: map[string][]string{"111111111111": {admin, app}},
+		policies: map[string]string{
+			"arn:aws:secretsmanager:us-east-1:111111111111:secret:db": `{"Statement":{"Effect":"Allow","Action":"secretsmanager:Get*","Principal":{"AWS":["333333333333","arn:aws:iam::111111111111:role/App"]}}}`,
+		},
+		readers: map[string][]string{
+			admin: {"arn:aws:secretsmanager:us-east-1:111111111111:secret:db", "arn:aws:secretsmanager:us-east-1:111111111111:secret:api"},
+			app:   {"arn:aws:secretsmanager:us-east-1:111111111111:secret:db"},
+		},
+	}
+
+	review := buildAccessReview(context.Background(), cfg, store, sim, []string{"Dev", "Prod", "Stg"}, time.Now())
+	// The GitHub destination is skipped; the shared account is listed once
+	require.Len(t, review.Secrets, 4)
+	assert.Equal(t, 2, sim.listed)
+
+	dev := review.Secrets[0]
+	assert.Equal(t, "Dev", dev.Target)
+	assert.Equal(t, "arn:aws:secretsmanager:us-east-1:111111111111:secret:db", dev.ARN)
+	assert.Equal(t, []SecretReader{
+		{Principal: admin, Via: "iam"},
+		{Principal: app, Via: "iam"},
+		{Principal: "arn:aws:iam::333333333333:root", Via: "resource_policy"},
+	}, dev.Readers)
+
+	assert.Equal(t, "Prod", review.Secrets[1].Target)
+	assert.Equal(t, "AccessDenied", review.Secrets[1].Error)
+	assert.Equal(t, []string{"Prod -> aws:222222222222: db: AccessDenied"}, review.Errors())
+
+	api := review.Secrets[2]
+	assert.Equal(t, "api", api.Secret)
+	assert.Equal(t, []SecretReader{{Principal: admin, Via: "iam"}}, api.Readers)
+}
+
+func TestResourcePolicyReaders(t *testing.T) {
+	policy := `{"Version":"2012-10-17","Statement":[
+		{"Effect":"Allow","Action":["secretsmanager:DescribeSecret"],"Principal":{"AWS":"arn:aws:iam::222222222222:role/Audit"}},
+		{"Effect":"Deny","Action":"*","Principal":"*"},
+		{"Effect":"Allow","Action":"secretsmanager:*","Principal":{"AWS":["arn:aws:iam::222222222222:role/Reader","arn:aws:iam::111111111111:root"]}},
+		{"Effect":"Allow","Action":"SecretsManager:GetSecretValue","Principal":{"Service":"lambda.amazonaws.com"}}
+	]}`
+	readers, err := resourcePolicyReaders(policy, "111111111111")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:aws:iam::222222222222:role/Reader"}, readers)
+
+	readers, err = resourcePolicyReaders(`{"Statement":{"Effect":"Allow","Action":"*","Principal":"*"}}`, "111111111111")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*"}, readers)
+
+	_, err = resourcePolicyReaders("not json", "111111111111")
+	assert.ErrorContains(t, err, "failed to parse resource policy")
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Account Factory events that vend a new account
+const (
+	ctEventCreateManagedAccount = "CreateManagedAccount"
+	// aftEventAccountProvisioned names the events built from the input of
+	// AFT's account provisioning customizations
+	aftEventAccountProvisioned = "AFTAccountProvisioned"
+)
+
+// listening reports whether vss serve consumes Account Factory events
+func (af *AccountFactoryConfig) listening() bool {
+	return af.Enabled && (af.OnAccountCreation || af.AFTIntegration)
+}
+
+func (af *AccountFactoryConfig) validate(c *Config) error {
+	if !af.listening() {
+		return nil
+	}
+	if err := validateQueueURL(af.QueueURL); err != nil {
+		return err
+	}
+	if af.DynamicTarget != "" {
+		if _, ok := c.DynamicTargets[af.DynamicTarget]; !ok {
+			return fmt.Errorf("dynamic_target %q not found in dynamic_targets", af.DynamicTarget)
+		}
+	}
+	return nil
+}
+
+// controlTowerEvent is the part of an EventBridge Control Tower lifecycle
+// event that identifies the account it vended
+type controlTowerEvent struct {
+	Source string `json:"source"`
+	Detail struct {
+		EventName           string `json:"eventName"`
+		ServiceEventDetails struct {
+			CreateManagedAccountStatus struct {
+				Account struct {
+					AccountID string `json:"accountId"`
+				} `json:"account"`
+				State string `json:"state"`
+			} `json:"createManagedAccountStatus"`
+		} `json:"serviceEventDetails"`
+	} `json:"detail"`
+}
+
+// aftProvisioningInput is the part of the input of AFT's account provisioning
+// customizations state machine that identifies the account and its request
+type aftProvisioningInput struct {
+	AccountInfo struct {
+		Account struct {
+			ID string `json:"id"`
+		} `json:"account"`
+	} `json:"account_info"`
+	AccountRequest struct {
+		AccountCustomizationsName string `json:"account_customizations_name"`
+	} `json:"account_request"`
+}
+
+// parseAccountFactoryEvent reads the account a message vended. With
+// aft_integration only AFT's provisioning input counts, since Control
+// Tower's event arrives before AFT has customized the account.
+func parseAccountFactoryEvent(body []byte, af *AccountFactoryConfig) (accountEvent, bool) {
+	var ev accountEvent
+	if af.AFTIntegration {
+		var in aftProvisioningInput
+		if err := json.Unmarshal(body, &in); err != nil {
+			return accountEvent{}, false
+		}
+		ev = accountEvent{
+			AccountID: in.AccountInfo.Account.ID,
+			EventName: aftEventAccountProvisioned,
+			Profile:   in.AccountRequest.AccountCustomizationsName,
+		}
+	} else {
+		var e controlTowerEvent
+		if err := json.Unmarshal(body, &e); err != nil || e.Source != "aws.controltower" || e.Detail.EventName != ctEventCreateManagedAccount {
+			return accountEvent{}, false
+		}
+		status := e.Detail.ServiceEventDetails.CreateManagedAccountStatus
+		if status.State != "SUCCEEDED" {
+			return accountEvent{}, false
+		}
+		ev = accountEvent{AccountID: status.Account.AccountID, EventName: ctEventCreateManagedAccount}
+	}
+	if !isAccountID(ev.AccountID) {
+		return accountEvent{}, false
+	}
+	ev.Vended = true
+	return ev, true
+}
+
+// vendedProfile returns the dynamic target a vended account is added to: the
+// one named like its AFT account customizations, else
+// account_factory.dynamic_target. Without either, only dynamic targets whose
+// Organizations discovery matches the account add it.
+func (c *Config) vendedProfile(customizations string) string {
+	if _, ok := c.DynamicTargets[customizations]; ok && customizations != "" {
+		return customizations
+	}
+	return c.AWS.ControlTower.AccountFactory.DynamicTarget
+}
+
+// accountTargets returns the targets in an account
+func (c *Config) accountTargets(accountID string) []string {
+	var names []string
+	for name, target := range c.Targets {
+		if target.AccountID == accountID {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
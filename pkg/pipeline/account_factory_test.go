@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccountFactoryEvent(t *testing.T) {
+	created := `{"source": "aws.controltower", "detail": {"eventName": "CreateManagedAccount", "serviceEventDetails": {"createManagedAccountStatus": {"account": {"accountName": "Sandbox Alice", "accountId": "111111111111"}, "organizationalUnit": {"organizationalUnitId": "ou-sandbox"}, "state": "SUCCEEDED"}}}}`
+	failed := `{"source": "aws.controltower", "detail": {"eventName": "CreateManagedAccount", "serviceEventDetails": {"createManagedAccountStatus": {"account": {"accountId": "111111111111"}, "state": "FAILED"}}}}`
+	aft := `{"account_info": {"account": {"id": "222222222222", "name": "Analytics Alice"}}, "account_request": {"account_customizations_name": "analytics-sandboxes"}, "control_tower_event": {}}`
+
+	factory := &AccountFactoryConfig{Enabled: true, OnAccountCreation: true}
+	ev, ok := parseAccountFactoryEvent([]byte(created), factory)
+	require.True(t, ok)
+	assert.Equal(t, accountEvent{AccountID: "111111111111", EventName: ctEventCreateManagedAccount, Vended: true}, ev)
+	_, ok = parseAccountFactoryEvent([]byte(failed), factory)
+	assert.False(t, ok)
+	_, ok = parseAccountFactoryEvent([]byte(aft), factory)
+	assert.False(t, ok)
+
+	// With AFT, only its provisioning input vends accounts
+	factory.AFTIntegration = true
+	ev, ok = parseAccountFactoryEvent([]byte(aft), factory)
+	require.True(t, ok)
+	assert.Equal(t, accountEvent{AccountID: "222222222222", EventName: aftEventAccountProvisioned, Vended: true, Profile: "analytics-sandboxes"}, ev)
+	_, ok = parseAccountFactoryEvent([]byte(created), factory)
+	assert.False(t, ok)
+}
+
+func TestAccountFactoryValidate(t *testing.T) {
+	cfg := &Config{DynamicTargets: map[string]DynamicTarget{"sandboxes": {}}}
+	queue := "https://sqs.us-east-1.amazonaws.com/123456789012/vss-account-factory"
+
+	assert.NoError(t, (&AccountFactoryConfig{Enabled: true}).validate(cfg))
+	assert.NoError(t, (&AccountFactoryConfig{Enabled: true, OnAccountCreation: true, QueueURL: queue, DynamicTarget: "sandboxes"}).validate(cfg))
+	assert.ErrorContains(t, (&AccountFactoryConfig{Enabled: true, AFTIntegration: true}).validate(cfg), "queue_url must be an https SQS queue URL")
+	assert.ErrorContains(t, (&AccountFactoryConfig{Enabled: true, OnAccountCreation: true, QueueURL: queue, DynamicTarget: "missing"}).validate(cfg), `dynamic_target "missing" not found`)
+}
+
+func TestApplyVendedAccount(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{
+		AWS:     AWSConfig{Region: "us-east-1"},
+		Sources: map[string]Source{"analytics": {}},
+		DynamicTargets: map[string]DynamicTarget{
+			"sandboxes": {
+				Discovery: DiscoveryConfig{Organizations: &OrganizationsDiscovery{OU: "ou-sandbox"}},
+				Imports:   []string{"analytics"},
+			},
+			"analytics-sandboxes": {
+				Discovery: DiscoveryConfig{AccountsList: &AccountsListDiscovery{Source: "ssm:/platform/analytics-sandboxes"}},
+				Imports:   []string{"analytics"},
+				RoleARN:   "arn:aws:iam::{{.AccountID}}:role/AnalyticsSync",
+			},
+		},
+	}
+	cfg.AWS.ControlTower.AccountFactory = AccountFactoryConfig{Enabled: true, AFTIntegration: true, DynamicTarget: "sandboxes"}
+	graph, err := BuildGraph(cfg)
+	require.NoError(t, err)
+	p := &Pipeline{config: cfg, graph: graph}
+
+	lookup := fakeAccountLookup{
+		accounts: map[string]AccountInfo{
+			"111111111111": {ID: "111111111111", Name: "Analytics Alice", Status: "ACTIVE"},
+			"222222222222": {ID: "222222222222", Name: "Sandbox Bob", Status: "ACTIVE"},
+		},
+		parents: map[string][]string{
+			"111111111111": {"ou-workloads", "r-root"},
+			"222222222222": {"ou-workloads", "r-root"},
+		},
+	}
+
+	// The AFT customizations name the profile, whatever its discovery finds
+	changed, err := p.applyAccountEvent(ctx, lookup, accountEvent{AccountID: "111111111111", EventName: aftEventAccountProvisioned, Vended: true, Profile: "analytics-sandboxes"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/AnalyticsSync", cfg.Targets["Analytics_Alice"].RoleARN)
+	assert.Equal(t, []string{"Analytics_Alice"}, cfg.accountTargets("111111111111"))
+
+	// Other customizations fall back to account_factory.dynamic_target
+	changed, err = p.applyAccountEvent(ctx, lookup, accountEvent{AccountID: "222222222222", EventName: aftEventAccountProvisioned, Vended: true, Profile: "default"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"Sandbox_Bob"}, cfg.accountTargets("222222222222"))
+	assert.Contains(t, p.graph.Nodes, "Sandbox_Bob")
+}
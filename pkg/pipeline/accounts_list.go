@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxAccountsListSize bounds the response read from an accounts list endpoint
+const maxAccountsListSize = 10 << 20
+
+// accountsListHTTPClient fetches https accounts lists
+var accountsListHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// idAttribute returns the attribute or JSON field holding account IDs
+func (a *AccountsListDiscovery) idAttribute() string {
+	if a.IDAttribute != "" {
+		return a.IDAttribute
+	}
+	return "id"
+}
+
+// nameAttribute returns the attribute or JSON field holding account names
+func (a *AccountsListDiscovery) nameAttribute() string {
+	if a.NameAttribute != "" {
+		return a.NameAttribute
+	}
+	return "name"
+}
+
+// validate checks the source, and that only the settings it uses are set
+func (a *AccountsListDiscovery) validate() error {
+	switch {
+	case strings.HasPrefix(a.Source, "ssm:"):
+		name := strings.TrimPrefix(a.Source, "ssm:")
+		if name == "" {
+			return fmt.Errorf("source %q: parameter name is empty", a.Source)
+		}
+		if arn.IsARN(name) {
+			parsed, err := arn.Parse(name)
+			if err != nil || parsed.Service != "ssm" || parsed.Region == "" || !isValidAWSAccountID(parsed.AccountID) ||
+				!strings.HasPrefix(parsed.Resource, "parameter/") {
+				return fmt.Errorf("source %q: not an SSM parameter ARN (arn:aws:ssm:<region>:<account>:parameter/<name>)", a.Source)
+			}
+		}
+	case strings.HasPrefix(a.Source, "dynamodb:"):
+		table := strings.TrimPrefix(a.Source, "dynamodb:")
+		if table == "" {
+			return fmt.Errorf("source %q: table name is empty", a.Source)
+		}
+		if arn.IsARN(table) {
+			parsed, err := arn.Parse(table)
+			if err != nil || parsed.Service != "dynamodb" || parsed.Region == "" || !isValidAWSAccountID(parsed.AccountID) ||
+				!strings.HasPrefix(parsed.Resource, "table/") {
+				return fmt.Errorf("source %q: not a DynamoDB table ARN (arn:aws:dynamodb:<region>:<account>:table/<name>)", a.Source)
+			}
+		}
+	case strings.HasPrefix(a.Source, "https://"):
+		u, err := url.Parse(a.Source)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("source %q: not a valid URL", a.Source)
+		}
+		if a.RoleARN != "" {
+			return fmt.Errorf("role_arn only applies to ssm: and dynamodb: sources")
+		}
+	default:
+		return fmt.Errorf("source must be ssm:<parameter name or ARN>, dynamodb:<table name or ARN> or an https:// URL, got %q", a.Source)
+	}
+	if len(a.Headers) > 0 && !strings.HasPrefix(a.Source, "https://") {
+		return fmt.Errorf("headers only apply to https:// sources")
+	}
+	if a.RoleARN != "" {
+		parsed, err := arn.Parse(a.RoleARN)
+		if err != nil || parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+			return fmt.Errorf("role_arn %q: not an IAM role ARN", a.RoleARN)
+		}
+	}
+	return nil
+}
+
+// arnRegion returns the region of an ARN, or "" for a plain name
+func arnRegion(s string) string {
+	if parsed, err := arn.Parse(s); err == nil {
+		return parsed.Region
+	}
+	return ""
+}
+
+// parseAccountsList parses an accounts list, which can be:
+//   - A comma-separated list of account IDs: "111111111111,222222222222,333333333333"
+//   - A JSON array: ["111111111111","222222222222","333333333333"]
+//   - A JSON array of objects: [{"id": "111111111111", "name": "Account1"}, ...],
+//     with the ID and name in the idAttr and nameAttr fields
+func parseAccountsList(value []byte, idAttr, nameAttr string) ([]AccountInfo, error) {
+	value = bytes.TrimSpace(value)
+	var accounts []AccountInfo
+
+	if bytes.HasPrefix(value, []byte("[")) {
+		dec := json.NewDecoder(bytes.NewReader(value))
+		dec.UseNumber()
+		var items []interface{}
+		if err := dec.Decode(&items); err != nil {
+			return nil, fmt.Errorf("failed to parse accounts list: %w", err)
+		}
+		for i, item := range items {
+			var acct AccountInfo
+			switch v := item.(type) {
+			case map[string]interface{}:
+				acct = AccountInfo{ID: jsonAccountID(v[idAttr]), Name: jsonString(v[nameAttr])}
+			default:
+				acct = AccountInfo{ID: jsonAccountID(v)}
+			}
+			if acct.ID == "" {
+				return nil, fmt.Errorf("accounts list item %d has no %s", i, idAttr)
+			}
+			accounts = append(accounts, acct)
+		}
+		return accounts, nil
+	}
+
+	for _, part := range strings.Split(string(value), ",") {
+		id := strings.TrimSpace(part)
+		if id != "" {
+			accounts = append(accounts, AccountInfo{ID: id})
+		}
+	}
+	return accounts, nil
+}
+
+// jsonAccountID returns an account ID from a JSON string or number, restoring
+// the leading zeros a number loses
+func jsonAccountID(v interface{}) string {
+	switch id := v.(type) {
+	case string:
+		return strings.TrimSpace(id)
+	case json.Number:
+		return fmt.Sprintf("%012s", id.String())
+	}
+	return ""
+}
+
+func jsonString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// getAccountsFromDynamoDB scans a DynamoDB table, read as cfg.RoleARN when
+// set, for the account ID and name attributes of its items. The table may be
+// given by ARN, which is read in the ARN's region.
+func (d *DiscoveryService) getAccountsFromDynamoDB(cfg *AccountsListDiscovery) ([]AccountInfo, error) {
+	table := strings.TrimPrefix(cfg.Source, "dynamodb:")
+	l := log.WithFields(log.Fields{
+		"action":  "getAccountsFromDynamoDB",
+		"table":   table,
+		"roleARN": cfg.RoleARN,
+	})
+	l.Debug("Scanning accounts from DynamoDB")
+
+	client := dynamodb.NewFromConfig(d.awsCtx.configAs(arnRegion(table), cfg.RoleARN))
+	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{
+		TableName:                aws.String(table),
+		ProjectionExpression:     aws.String("#id, #name"),
+		ExpressionAttributeNames: map[string]string{"#id": cfg.idAttribute(), "#name": cfg.nameAttribute()},
+	})
+	var accounts []AccountInfo
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(d.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DynamoDB table %s: %w", table, err)
+		}
+		for _, item := range page.Items {
+			acct, ok := dynamoDBAccount(item, cfg.idAttribute(), cfg.nameAttribute())
+			if !ok {
+				l.WithField("item", item).Warn("Skipping item without an account ID")
+				continue
+			}
+			accounts = append(accounts, acct)
+		}
+	}
+
+	l.WithField("count", len(accounts)).Debug("Scanned DynamoDB table")
+	return accounts, nil
+}
+
+// dynamoDBAccount reads an account from a DynamoDB item's string or number
+// attributes
+func dynamoDBAccount(item map[string]ddbtypes.AttributeValue, idAttr, nameAttr string) (AccountInfo, bool) {
+	var acct AccountInfo
+	switch v := item[idAttr].(type) {
+	case *ddbtypes.AttributeValueMemberS:
+		acct.ID = strings.TrimSpace(v.Value)
+	case *ddbtypes.AttributeValueMemberN:
+		acct.ID = fmt.Sprintf("%012s", v.Value)
+	}
+	if v, ok := item[nameAttr].(*ddbtypes.AttributeValueMemberS); ok {
+		acct.Name = v.Value
+	}
+	return acct, acct.ID != ""
+}
+
+// fetchAccountsList GETs an accounts list from an HTTPS endpoint with the
+// configured headers, e.g. an Authorization header for a platform inventory.
+// The response is parsed by parseAccountsList.
+func fetchAccountsList(ctx context.Context, client *http.Client, cfg *AccountsListDiscovery) ([]AccountInfo, error) {
+	l := log.WithFields(log.Fields{
+		"action": "fetchAccountsList",
+		"url":    cfg.Source,
+	})
+	l.Debug("Fetching accounts from HTTPS endpoint")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch accounts list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to fetch accounts list from %s: %s", cfg.Source, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAccountsListSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts list: %w", err)
+	}
+	if len(body) > maxAccountsListSize {
+		return nil, fmt.Errorf("accounts list from %s is larger than %d bytes", cfg.Source, maxAccountsListSize)
+	}
+
+	accounts, err := parseAccountsList(body, cfg.idAttribute(), cfg.nameAttribute())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Source, err)
+	}
+	l.WithField("count", len(accounts)).Debug("Fetched accounts list")
+	return accounts, nil
+}
@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccountsList(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []AccountInfo
+	}{
+		{"comma-separated", " 111111111111, 222222222222 ,", []AccountInfo{{ID: "111111111111"}, {ID: "222222222222"}}},
+		{"string array", `["111111111111","222222222222"]`, []AccountInfo{{ID: "111111111111"}, {ID: "222222222222"}}},
+		{"object array", `[{"account":"111111111111","owner":"data"},{"account":22222222222}]`,
+			[]AccountInfo{{ID: "111111111111", Name: "data"}, {ID: "022222222222"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAccountsList([]byte(tt.value), "account", "owner")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := parseAccountsList([]byte(`[{"id":"111111111111"}]`), "account", "owner")
+	assert.ErrorContains(t, err, "item 0 has no account")
+	_, err = parseAccountsList([]byte(`[1,`), "id", "name")
+	assert.ErrorContains(t, err, "failed to parse accounts list")
+}
+
+func TestDynamoDBAccount(t *testing.T) {
+	acct, ok := dynamoDBAccount(map[string]ddbtypes.AttributeValue{
+		"account_id": &ddbtypes.AttributeValueMemberN{Value: "11111111111"},
+		"owner":      &ddbtypes.AttributeValueMemberS{Value: "data"},
+	}, "account_id", "owner")
+	assert.True(t, ok)
+	assert.Equal(t, AccountInfo{ID: "011111111111", Name: "data"}, acct)
+
+	_, ok = dynamoDBAccount(map[string]ddbtypes.AttributeValue{
+		"owner": &ddbtypes.AttributeValueMemberS{Value: "data"},
+	}, "account_id", "owner")
+	assert.False(t, ok)
+}
+
+func TestFetchAccountsList(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[{"id":"111111111111","name":"sandbox-a"}]`))
+	}))
+	defer srv.Close()
+
+	cfg := &AccountsListDiscovery{Source: srv.URL, Headers: map[string]string{"Authorization": "Bearer s3cret"}}
+	accounts, err := fetchAccountsList(context.Background(), srv.Client(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []AccountInfo{{ID: "111111111111", Name: "sandbox-a"}}, accounts)
+
+	cfg.Headers = nil
+	_, err = fetchAccountsList(context.Background(), srv.Client(), cfg)
+	assert.ErrorContains(t, err, "401 Unauthorized")
+}
+
+func TestAccountsListDiscoveryValidate(t *testing.T) {
+	valid := []AccountsListDiscovery{
+		{Source: "ssm:/platform/sandboxes"},
+		{Source: "dynamodb:platform-accounts", IDAttribute: "account_id"},
+		{Source: "dynamodb:arn:aws:dynamodb:us-east-1:123456789012:table/platform-accounts", RoleARN: "arn:aws:iam::123456789012:role/reader"},
+		{Source: "https://inventory.example.com/accounts", Headers: map[string]string{"Authorization": "Bearer x"}},
+	}
+	for _, a := range valid {
+		assert.NoError(t, a.validate(), a.Source)
+	}
+
+	invalid := map[string]AccountsListDiscovery{
+		"source must be":                         {Source: "http://inventory.example.com/accounts"},
+		"table name is empty":                    {Source: "dynamodb:"},
+		"not a DynamoDB table ARN":               {Source: "dynamodb:arn:aws:s3:::bucket"},
+		"role_arn only applies":                  {Source: "https://inventory.example.com", RoleARN: "arn:aws:iam::123456789012:role/reader"},
+		"headers only apply to https:// sources": {Source: "ssm:/platform/sandboxes", Headers: map[string]string{"X": "y"}},
+	}
+	for want, a := range invalid {
+		assert.ErrorContains(t, a.validate(), want)
+	}
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// approvalsNamespace is where target approvals are kept in the merge store
+const approvalsNamespace = "_vss_approvals"
+
+// TargetApproval records when a target discovered by a dynamic target with
+// require_approval was first seen and who approved it. Until it is approved
+// the target is merged but not synced.
+type TargetApproval struct {
+	Target        string    `json:"target"`
+	DynamicTarget string    `json:"dynamic_target"`
+	AccountID     string    `json:"account_id,omitempty"`
+	Discovered    time.Time `json:"discovered"`
+	// Approved and ApprovedBy are set by `vss targets approve`
+	Approved   *time.Time `json:"approved,omitempty"`
+	ApprovedBy string     `json:"approved_by,omitempty"`
+}
+
+// PendingApprovalTargets returns the targets whose sync was held for approval
+func PendingApprovalTargets(results []Result) []string {
+	var pending []string
+	for _, r := range results {
+		if r.Details.PendingApproval {
+			pending = append(pending, r.Target)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// requiresApproval returns the dynamic target that discovered a target when
+// it holds new targets for approval
+func (c *Config) requiresApproval(target string) (string, bool) {
+	origin, ok := c.discoveredFrom[target]
+	if !ok {
+		return "", false
+	}
+	dt, ok := c.DynamicTargets[origin]
+	return origin, ok && dt.RequireApproval
+}
+
+func readApprovals(ctx context.Context, store mergeStore) (map[string]TargetApproval, error) {
+	names, err := store.ListSecrets(ctx, approvalsNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list target approvals: %w", err)
+	}
+	approvals := make(map[string]TargetApproval, len(names))
+	for _, name := range names {
+		data, err := store.ReadSecret(ctx, approvalsNamespace, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read approval of %s: %w", name, err)
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		var a TargetApproval
+		if err := json.Unmarshal(b, &a); err != nil {
+			return nil, fmt.Errorf("failed to decode approval of %s: %w", name, err)
+		}
+		approvals[name] = a
+	}
+	return approvals, nil
+}
+
+func writeApproval(ctx context.Context, store mergeStore, a TargetApproval) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	if err := store.WriteSecret(ctx, approvalsNamespace, a.Target, data); err != nil {
+		return fmt.Errorf("failed to write approval of %s: %w", a.Target, err)
+	}
+	return nil
+}
+
+// pendingApprovals returns the targets that need approval and have none.
+// With record set, targets seen for the first time are recorded as pending
+// so `vss targets approve` can list them; dry runs only report them.
+func (p *Pipeline) pendingApprovals(ctx context.Context, targets []string, record bool, now time.Time) (map[string]bool, error) {
+	var gated []string
+	for _, name := range targets {
+		if _, ok := p.config.requiresApproval(name); ok {
+			gated = append(gated, name)
+		}
+	}
+	if len(gated) == 0 {
+		return nil, nil
+	}
+
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := readApprovals(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]bool)
+	for _, name := range gated {
+		a, ok := approvals[name]
+		if ok && a.Approved != nil {
+			continue
+		}
+		pending[name] = true
+		if ok || !record {
+			continue
+		}
+		origin, _ := p.config.requiresApproval(name)
+		a = TargetApproval{
+			Target:        name,
+			DynamicTarget: origin,
+			AccountID:     p.config.Targets[name].AccountID,
+			Discovered:    now,
+		}
+		if err := writeApproval(ctx, store, a); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(pending) > 0 {
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		log.WithFields(log.Fields{
+			"action":  "Pipeline.pendingApprovals",
+			"targets": names,
+		}).Warnf("%d newly discovered targets are pending approval and will not be synced; approve them with vss targets approve", len(names))
+	}
+	return pending, nil
+}
+
+// TargetApprovals returns the approvals recorded in the merge store, sorted
+// by target
+func (p *Pipeline) TargetApprovals(ctx context.Context) ([]TargetApproval, error) {
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byTarget, err := readApprovals(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	approvals := make([]TargetApproval, 0, len(byTarget))
+	for _, a := range byTarget {
+		approvals = append(approvals, a)
+	}
+	sort.Slice(approvals, func(i, j int) bool { return approvals[i].Target < approvals[j].Target })
+	return approvals, nil
+}
+
+// ApproveTargets approves targets so the next run syncs them. Targets a run
+// has not seen yet can be approved ahead of their first sync if the current
+// discovery finds them; targets that were already approved keep their
+// approval.
+func (p *Pipeline) ApproveTargets(ctx context.Context, targets []string, by string, now time.Time) ([]TargetApproval, error) {
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := readApprovals(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var approved []TargetApproval
+	for _, name := range targets {
+		a, ok := approvals[name]
+		if !ok {
+			origin, gated := p.config.requiresApproval(name)
+			if !gated {
+				return approved, fmt.Errorf("target %q is not pending approval", name)
+			}
+			a = TargetApproval{
+				Target:        name,
+				DynamicTarget: origin,
+				AccountID:     p.config.Targets[name].AccountID,
+				Discovered:    now,
+			}
+		}
+		if a.Approved == nil {
+			approvedAt := now
+			a.Approved, a.ApprovedBy = &approvedAt, by
+			if err := writeApproval(ctx, store, a); err != nil {
+				return approved, err
+			}
+			log.WithFields(log.Fields{
+				"action":    "Pipeline.ApproveTargets",
+				"audit":     true,
+				"target":    name,
+				"accountID": a.AccountID,
+				"by":        by,
+			}).Info("Target approved")
+		}
+		approved = append(approved, a)
+	}
+	return approved, nil
+}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memDirectStore is a memMergeStore the pipeline can use as its merge store
+type memDirectStore struct {
+	memMergeStore
+}
+
+func (memDirectStore) GetMergePath(targetName string) string {
+	return "mem://" + targetName
+}
+
+func TestTargetApproval(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := memMergeStore{}
+	p := &Pipeline{
+		config: &Config{
+			DynamicTargets: map[string]DynamicTarget{
+				"sandboxes": {RequireApproval: true},
+				"shared":    {},
+			},
+			Targets: map[string]Target{
+				"Sandbox_A": {AccountID: "111111111111"},
+				"Sandbox_B": {AccountID: "222222222222"},
+				"Shared_C":  {AccountID: "333333333333"},
+				"Prod":      {AccountID: "444444444444"},
+			},
+			discoveredFrom: map[string]string{
+				"Sandbox_A": "sandboxes",
+				"Sandbox_B": "sandboxes",
+				"Shared_C":  "shared",
+			},
+		},
+		memStore: memDirectStore{store},
+	}
+	targets := []string{"Sandbox_A", "Sandbox_B", "Shared_C", "Prod"}
+
+	// Dry runs report new targets without recording them
+	pending, err := p.pendingApprovals(ctx, targets, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"Sandbox_A": true, "Sandbox_B": true}, pending)
+	assert.Empty(t, store[approvalsNamespace])
+
+	pending, err = p.pendingApprovals(ctx, targets, true, now)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+	approvals, err := p.TargetApprovals(ctx)
+	require.NoError(t, err)
+	require.Len(t, approvals, 2)
+	assert.Equal(t, TargetApproval{Target: "Sandbox_A", DynamicTarget: "sandboxes", AccountID: "111111111111", Discovered: now}, approvals[0])
+
+	// Approving keeps when the target was discovered
+	later := now.Add(time.Hour)
+	approved, err := p.ApproveTargets(ctx, []string{"Sandbox_A"}, "alice", later)
+	require.NoError(t, err)
+	require.Len(t, approved, 1)
+	assert.Equal(t, now, approved[0].Discovered)
+	assert.Equal(t, "alice", approved[0].ApprovedBy)
+	assert.Equal(t, later, *approved[0].Approved)
+
+	pending, err = p.pendingApprovals(ctx, targets, true, later)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"Sandbox_B": true}, pending)
+
+	// Approving again keeps the first approval
+	approved, err = p.ApproveTargets(ctx, []string{"Sandbox_A"}, "bob", later.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "alice", approved[0].ApprovedBy)
+
+	_, err = p.ApproveTargets(ctx, []string{"Prod"}, "alice", later)
+	assert.ErrorContains(t, err, `target "Prod" is not pending approval`)
+}
+
+func TestTargetApprovalAheadOfFirstRun(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := &Pipeline{
+		config: &Config{
+			DynamicTargets: map[string]DynamicTarget{"sandboxes": {RequireApproval: true}},
+			Targets:        map[string]Target{"Sandbox_A": {AccountID: "111111111111"}},
+			discoveredFrom: map[string]string{"Sandbox_A": "sandboxes"},
+		},
+		memStore: memDirectStore{memMergeStore{}},
+	}
+
+	_, err := p.ApproveTargets(ctx, []string{"Sandbox_A"}, "alice", now)
+	require.NoError(t, err)
+	pending, err := p.pendingApprovals(ctx, []string{"Sandbox_A"}, true, now)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestPendingApprovalTargets(t *testing.T) {
+	results := []Result{
+		{Target: "Sandbox_B", Phase: "sync", Success: true, Details: ResultDetails{PendingApproval: true}},
+		{Target: "Prod", Phase: "sync", Success: true},
+		{Target: "Sandbox_A", Phase: "sync", Success: true, Details: ResultDetails{PendingApproval: true}},
+	}
+	assert.Equal(t, []string{"Sandbox_A", "Sandbox_B"}, PendingApprovalTargets(results))
+}
@@ -0,0 +1,277 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	awsstore "github.com/jbcom/secretsync/stores/aws"
+)
+
+// defaultSessionName names role sessions without an assume_role.session_name
+const defaultSessionName = "vault-secret-sync"
+
+const (
+	minSessionDuration = 15 * time.Minute
+	maxSessionDuration = 12 * time.Hour
+	// maxChainedSessionDuration is the longest session STS grants a role
+	// assumed with another role's credentials
+	maxChainedSessionDuration = time.Hour
+)
+
+var (
+	sessionNameChars = regexp.MustCompile(`[^\w+=,.@-]`)
+	externalIDChars  = regexp.MustCompile(`^[\w+=,.@:/-]*$`)
+	sessionTagChars  = regexp.MustCompile(`[^\p{L}\p{Z}\p{N}_.:/=+@-]`)
+)
+
+// AssumeRoleOptions configures the session of a target account's role
+type AssumeRoleOptions struct {
+	// ExternalID is passed for trust policies with an sts:ExternalId condition
+	ExternalID string `mapstructure:"external_id" yaml:"external_id,omitempty"`
+	// SessionName names the session in the target account's CloudTrail and
+	// supports {{.Target}} and {{.AccountID}} (default vault-secret-sync)
+	SessionName string `mapstructure:"session_name" yaml:"session_name,omitempty"`
+	// Duration of the session, 15m to 12h; sessions reached through a
+	// role_chain are limited to 1h (default 15m)
+	Duration time.Duration `mapstructure:"duration" yaml:"duration,omitempty"`
+	// ScopeDown passes a session policy that only allows Secrets Manager on
+	// the target's secret_prefix, so a broad role such as
+	// AWSControlTowerExecution cannot be used for anything else by a sync
+	ScopeDown bool `mapstructure:"scope_down" yaml:"scope_down,omitempty"`
+	// SessionTags are passed on the session, so the target account's
+	// CloudTrail records them with every call the sync makes. Values support
+	// {{.RunID}}, {{.Pipeline}}, {{.GitSHA}}, {{.Target}} and {{.AccountID}}.
+	// The role must trust sts:TagSession.
+	SessionTags map[string]string `mapstructure:"session_tags" yaml:"session_tags,omitempty"`
+	// SourceIdentity is set on the session, and the hops of its role_chain,
+	// with the same templates. Unlike a session name it cannot be changed by
+	// roles assumed later. The role must trust sts:SetSourceIdentity.
+	SourceIdentity string `mapstructure:"source_identity" yaml:"source_identity,omitempty"`
+}
+
+// roleSession identifies the run a role session is for in session_tags and
+// source_identity
+type roleSession struct {
+	RunID    string
+	Pipeline string
+	GitSHA   string
+}
+
+// runSession identifies the run with ID id in the sessions of the roles it
+// assumes
+func (c *Config) runSession(id string, prov Provenance) roleSession {
+	name := c.Pipeline.Name
+	if name == "" && prov.ConfigFile != "" {
+		name = strings.TrimSuffix(filepath.Base(prov.ConfigFile), filepath.Ext(prov.ConfigFile))
+	}
+	return roleSession{RunID: id, Pipeline: name, GitSHA: prov.GitSHA}
+}
+
+// render replaces the session template variables in s
+func (r roleSession) render(s, targetName, accountID string) string {
+	return strings.NewReplacer(
+		"{{.Target}}", targetName,
+		"{{.AccountID}}", accountID,
+		"{{.RunID}}", r.RunID,
+		"{{.Pipeline}}", r.Pipeline,
+		"{{.GitSHA}}", r.GitSHA,
+	).Replace(s)
+}
+
+func (o *AssumeRoleOptions) validate(chain []RoleChainHop) error {
+	if n := len(o.ExternalID); n > 0 && (n < 2 || n > 1224 || !externalIDChars.MatchString(o.ExternalID)) {
+		return fmt.Errorf("external_id must be 2 to 1224 letters, digits or +=,.@:/-_ characters")
+	}
+	if o.Duration != 0 && (o.Duration < minSessionDuration || o.Duration > maxSessionDuration) {
+		return fmt.Errorf("duration must be between %s and %s", minSessionDuration, maxSessionDuration)
+	}
+	if len(chain) > 0 && o.Duration > maxChainedSessionDuration {
+		return fmt.Errorf("duration must be at most %s with a role_chain", maxChainedSessionDuration)
+	}
+	if len(o.SessionTags) > maxSessionTags {
+		return fmt.Errorf("at most %d session_tags are allowed", maxSessionTags)
+	}
+	for k, v := range o.SessionTags {
+		if err := validateSessionTag(k, v); err != nil {
+			return fmt.Errorf("session_tags: %w", err)
+		}
+		// The hops pass their tags on as transitive tags, which later
+		// sessions cannot set again
+		for i, hop := range chain {
+			for hk := range hop.SessionTags {
+				if strings.EqualFold(k, hk) {
+					return fmt.Errorf("session_tags: session tag %q is already passed on by role_chain hop %d", k, i)
+				}
+			}
+		}
+	}
+	// The hops are assumed with both their own tags and session_tags
+	for i, hop := range chain {
+		if len(o.SessionTags)+len(hop.SessionTags) > maxSessionTags {
+			return fmt.Errorf("session_tags: at most %d session tags are allowed with role_chain hop %d's", maxSessionTags, i)
+		}
+	}
+	return nil
+}
+
+// assumeRole returns a target's assume_role, or aws.execution_context's
+func (c *Config) assumeRole(target Target) *AssumeRoleOptions {
+	if target.AssumeRole != nil {
+		return target.AssumeRole
+	}
+	return c.AWS.ExecutionContext.AssumeRole
+}
+
+// sessionName renders session_name for a target, replacing characters STS
+// does not allow in session names
+func (o *AssumeRoleOptions) sessionName(targetName, accountID string) string {
+	if o == nil || o.SessionName == "" {
+		return defaultSessionName
+	}
+	name := strings.NewReplacer("{{.Target}}", targetName, "{{.AccountID}}", accountID).Replace(o.SessionName)
+	name = sessionNameChars.ReplaceAllString(name, "-")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if len(name) < 2 {
+		return defaultSessionName
+	}
+	return name
+}
+
+// stsOptions returns the options a target's role is assumed with. The
+// session policy only applies to Secrets Manager destinations (see applyTo).
+func (o *AssumeRoleOptions) stsOptions(session roleSession, targetName, accountID string) func(*stscreds.AssumeRoleOptions) {
+	return func(so *stscreds.AssumeRoleOptions) {
+		so.RoleSessionName = o.sessionName(targetName, accountID)
+		if o == nil {
+			return
+		}
+		if o.ExternalID != "" {
+			so.ExternalID = aws.String(o.ExternalID)
+		}
+		if o.Duration > 0 {
+			so.Duration = o.Duration
+		}
+		o.tagSession(so, session, targetName, accountID)
+	}
+}
+
+// tagSession sets the session tags and source identity on a session of a
+// target's role, for the run session identifies
+func (o *AssumeRoleOptions) tagSession(so *stscreds.AssumeRoleOptions, session roleSession, targetName, accountID string) {
+	if o == nil {
+		return
+	}
+	tags := o.renderSessionTags(session, targetName, accountID)
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		so.Tags = append(so.Tags, ststypes.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	if id := o.sourceIdentity(session, targetName, accountID); id != "" {
+		so.SourceIdentity = aws.String(id)
+	}
+}
+
+// renderSessionTags renders session_tags for a target, replacing characters
+// STS does not allow in tag values
+func (o *AssumeRoleOptions) renderSessionTags(session roleSession, targetName, accountID string) map[string]string {
+	if o == nil || len(o.SessionTags) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(o.SessionTags))
+	for k, v := range o.SessionTags {
+		v = sessionTagChars.ReplaceAllString(session.render(v, targetName, accountID), "-")
+		if len(v) > 256 {
+			v = v[:256]
+		}
+		tags[k] = v
+	}
+	return tags
+}
+
+// sourceIdentity renders source_identity for a target like a session name,
+// or returns "" when none is set
+func (o *AssumeRoleOptions) sourceIdentity(session roleSession, targetName, accountID string) string {
+	if o == nil || o.SourceIdentity == "" {
+		return ""
+	}
+	id := sessionNameChars.ReplaceAllString(session.render(o.SourceIdentity, targetName, accountID), "-")
+	if len(id) > 64 {
+		id = id[:64]
+	}
+	if len(id) < 2 {
+		return ""
+	}
+	return id
+}
+
+// applyTo sets the session options of a Secrets Manager destination that
+// assumes client.RoleArn in accountID
+func (o *AssumeRoleOptions) applyTo(client *awsstore.AwsClient, session roleSession, targetName, accountID, secretPrefix string) {
+	client.SessionName = o.sessionName(targetName, accountID)
+	if o == nil {
+		return
+	}
+	client.ExternalID = o.ExternalID
+	client.SessionTags = o.renderSessionTags(session, targetName, accountID)
+	client.SourceIdentity = o.sourceIdentity(session, targetName, accountID)
+	if o.Duration > 0 {
+		client.SessionDuration = o.Duration.String()
+	}
+	if o.ScopeDown {
+		client.SessionPolicy = scopeDownPolicy(client.RoleArn, accountID, secretPrefix)
+	}
+}
+
+// scopeDownPolicy returns a session policy that allows Secrets Manager only
+// on the secrets under prefix in accountID, and KMS only through Secrets
+// Manager. The session's permissions are the intersection of this and the
+// role's policies.
+func scopeDownPolicy(roleARN, accountID, prefix string) string {
+	partition := "aws"
+	if parsed, err := arn.Parse(roleARN); err == nil {
+		partition = parsed.Partition
+	}
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Sid":      "TargetSecrets",
+				"Effect":   "Allow",
+				"Action":   "secretsmanager:*",
+				"Resource": fmt.Sprintf("arn:%s:secretsmanager:*:%s:secret:%s*", partition, accountID, prefix),
+			},
+			{
+				"Sid":      "ListSecrets",
+				"Effect":   "Allow",
+				"Action":   []string{"secretsmanager:ListSecrets", "secretsmanager:BatchGetSecretValue"},
+				"Resource": "*",
+			},
+			{
+				"Sid":      "SecretsManagerKMS",
+				"Effect":   "Allow",
+				"Action":   []string{"kms:Decrypt", "kms:Encrypt", "kms:GenerateDataKey", "kms:DescribeKey"},
+				"Resource": "*",
+				"Condition": map[string]interface{}{
+					"StringLike": map[string]string{"kms:ViaService": "secretsmanager.*.amazonaws.com"},
+				},
+			},
+		},
+	}
+	b, _ := json.Marshal(policy)
+	return string(b)
+}
@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	awsstore "github.com/jbcom/secretsync/stores/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssumeRoleOptionsValidate(t *testing.T) {
+	hub := []RoleChainHop{{RoleARN: "arn:aws:iam::111111111111:role/secrets-hub"}}
+	tests := []struct {
+		name    string
+		opts    AssumeRoleOptions
+		chain   []RoleChainHop
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "valid", opts: AssumeRoleOptions{ExternalID: "vss-prod", Duration: time.Hour, ScopeDown: true}, chain: hub},
+		{name: "external id with spaces", opts: AssumeRoleOptions{ExternalID: "vss prod"}, wantErr: "external_id must be"},
+		{name: "too short", opts: AssumeRoleOptions{Duration: time.Minute}, wantErr: "duration must be between 15m0s and 12h0m0s"},
+		{name: "too long for a chain", opts: AssumeRoleOptions{Duration: 2 * time.Hour}, chain: hub, wantErr: "at most 1h0m0s with a role_chain"},
+		{name: "reserved session tag", opts: AssumeRoleOptions{SessionTags: map[string]string{"aws:run": "x"}}, wantErr: "session_tags: session tag \"aws:run\": the aws: prefix is reserved"},
+		{
+			name:    "session tag passed on by a hop",
+			opts:    AssumeRoleOptions{SessionTags: map[string]string{"Team": "{{.Pipeline}}"}},
+			chain:   []RoleChainHop{{RoleARN: hub[0].RoleARN, SessionTags: map[string]string{"team": "platform"}}},
+			wantErr: "already passed on by role_chain hop 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validate(tt.chain)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssumeRoleSessionName(t *testing.T) {
+	var unset *AssumeRoleOptions
+	assert.Equal(t, "vault-secret-sync", unset.sessionName("Serverless_Stg", "111111111111"))
+
+	opts := &AssumeRoleOptions{SessionName: "vss-{{.Target}}-{{.AccountID}}"}
+	assert.Equal(t, "vss-Serverless_Stg-111111111111", opts.sessionName("Serverless_Stg", "111111111111"))
+	assert.Equal(t, "vss-Team-A-111111111111", opts.sessionName("Team A", "111111111111"), "spaces are not allowed")
+
+	opts.SessionName = "{{.Target}}"
+	assert.Len(t, opts.sessionName(strings.Repeat("x", 100), ""), 64)
+	assert.Equal(t, "vault-secret-sync", opts.sessionName("", ""))
+}
+
+func TestAssumeRoleScopeDown(t *testing.T) {
+	cfg := &Config{
+		AWS: AWSConfig{
+			Region: "us-east-1",
+			ExecutionContext: ExecutionContextConfig{
+				AssumeRole: &AssumeRoleOptions{SessionName: "vss-{{.Target}}"},
+			},
+			ControlTower: ControlTowerConfig{Enabled: true},
+		},
+	}
+	p := &Pipeline{config: cfg}
+	target := Target{
+		AccountID:    "111111111111",
+		SecretPrefix: "analytics/",
+		AssumeRole:   &AssumeRoleOptions{ExternalID: "vss-prod", Duration: time.Hour, ScopeDown: true},
+	}
+
+	sync, roleARN := p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", target, Destination{AccountID: "111111111111"}, false)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/AWSControlTowerExecution", roleARN)
+	client := sync.Spec.Dest[0].AWS
+	assert.Equal(t, "vault-secret-sync", client.SessionName, "the target's assume_role replaces the execution context's")
+	assert.Equal(t, "vss-prod", client.ExternalID)
+	assert.Equal(t, "1h0m0s", client.SessionDuration)
+
+	var policy struct {
+		Statement []struct {
+			Sid      string
+			Resource interface{}
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(client.SessionPolicy), &policy))
+	require.Len(t, policy.Statement, 3)
+	assert.Equal(t, "TargetSecrets", policy.Statement[0].Sid)
+	assert.Equal(t, "arn:aws:secretsmanager:*:111111111111:secret:analytics/*", policy.Statement[0].Resource)
+
+	sync, _ = p.destinationSync("Analytics_Stg", "merged/Analytics_Stg", Target{AccountID: "222222222222"}, Destination{AccountID: "222222222222"}, false)
+	assert.Equal(t, "vss-Analytics_Stg", sync.Spec.Dest[0].AWS.SessionName)
+	assert.Empty(t, sync.Spec.Dest[0].AWS.SessionPolicy)
+}
+
+func TestAssumeRoleSessionTags(t *testing.T) {
+	cfg := &Config{}
+	session := cfg.runSession("20260301T120000.000000000Z", Provenance{ConfigFile: "/repo/pipelines/prod.yaml", GitSHA: "0123abc"})
+	assert.Equal(t, roleSession{RunID: "20260301T120000.000000000Z", Pipeline: "prod", GitSHA: "0123abc"}, session)
+	cfg.Pipeline.Name = "secrets-prod"
+	assert.Equal(t, "secrets-prod", cfg.runSession("", Provenance{ConfigFile: "prod.yaml"}).Pipeline)
+
+	opts := &AssumeRoleOptions{
+		SessionTags: map[string]string{
+			"vss:run":      "{{.RunID}}",
+			"vss:pipeline": "{{.Pipeline}} <{{.Target}}>",
+			"vss:commit":   "{{.GitSHA}}",
+		},
+		SourceIdentity: "vss-{{.RunID}}",
+	}
+	var so stscreds.AssumeRoleOptions
+	opts.stsOptions(session, "Analytics_Prod", "111111111111")(&so)
+	tags := make(map[string]string)
+	var keys []string
+	for _, tag := range so.Tags {
+		keys = append(keys, aws.ToString(tag.Key))
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	assert.Equal(t, []string{"vss:commit", "vss:pipeline", "vss:run"}, keys, "tags are passed in key order")
+	assert.Equal(t, map[string]string{
+		"vss:run":      "20260301T120000.000000000Z",
+		"vss:pipeline": "prod -Analytics_Prod-",
+		"vss:commit":   "0123abc",
+	}, tags, "characters STS does not allow in tag values are replaced")
+	assert.Equal(t, "vss-20260301T120000.000000000Z", aws.ToString(so.SourceIdentity))
+
+	client := &awsstore.AwsClient{RoleArn: "arn:aws:iam::111111111111:role/AWSControlTowerExecution"}
+	opts.applyTo(client, session, "Analytics_Prod", "111111111111", "")
+	assert.Equal(t, tags, client.SessionTags)
+	assert.Equal(t, "vss-20260301T120000.000000000Z", client.SourceIdentity)
+
+	// Without a run, e.g. during discovery, the source identity is too short
+	// to set and is left out
+	opts.SourceIdentity = "{{.RunID}}"
+	so = stscreds.AssumeRoleOptions{}
+	opts.stsOptions(roleSession{}, "", "111111111111")(&so)
+	assert.Nil(t, so.SourceIdentity)
+	assert.Len(t, so.Tags, 3)
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
@@ -42,10 +43,45 @@ type AWSExecutionContext struct {
 	OrganizationInfo *OrganizationInfo
 	
 	// Cached clients
-	stsClient  *sts.Client
-	orgClient  *organizations.Client
-	ssoClient  *ssoadmin.Client
-	ssmClient  *ssm.Client
+	stsClient stsAPI
+	orgClient organizationsAPI
+	ssoClient ssoAdminAPI
+	ssmClient ssmAPI
+}
+
+// stsAPI is the subset of the STS API the execution context uses;
+// *sts.Client implements it
+type stsAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+	AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error)
+}
+
+// organizationsAPI is the subset of the Organizations API the execution
+// context uses for delegation checks and account discovery
+type organizationsAPI interface {
+	DescribeOrganization(ctx context.Context, params *organizations.DescribeOrganizationInput, optFns ...func(*organizations.Options)) (*organizations.DescribeOrganizationOutput, error)
+	ListDelegatedAdministrators(ctx context.Context, params *organizations.ListDelegatedAdministratorsInput, optFns ...func(*organizations.Options)) (*organizations.ListDelegatedAdministratorsOutput, error)
+	ListDelegatedServicesForAccount(ctx context.Context, params *organizations.ListDelegatedServicesForAccountInput, optFns ...func(*organizations.Options)) (*organizations.ListDelegatedServicesForAccountOutput, error)
+	ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error)
+	ListAccountsForParent(ctx context.Context, params *organizations.ListAccountsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error)
+	ListOrganizationalUnitsForParent(ctx context.Context, params *organizations.ListOrganizationalUnitsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error)
+	DescribeAccount(ctx context.Context, params *organizations.DescribeAccountInput, optFns ...func(*organizations.Options)) (*organizations.DescribeAccountOutput, error)
+	ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error)
+	ListParents(ctx context.Context, params *organizations.ListParentsInput, optFns ...func(*organizations.Options)) (*organizations.ListParentsOutput, error)
+}
+
+// ssoAdminAPI is the subset of the Identity Center admin API discovery uses
+type ssoAdminAPI interface {
+	ListInstances(ctx context.Context, params *ssoadmin.ListInstancesInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error)
+	ListPermissionSets(ctx context.Context, params *ssoadmin.ListPermissionSetsInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsOutput, error)
+	DescribePermissionSet(ctx context.Context, params *ssoadmin.DescribePermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error)
+	ListAccountAssignments(ctx context.Context, params *ssoadmin.ListAccountAssignmentsInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListAccountAssignmentsOutput, error)
+	ListAccountsForProvisionedPermissionSet(ctx context.Context, params *ssoadmin.ListAccountsForProvisionedPermissionSetInput, optFns ...func(*ssoadmin.Options)) (*ssoadmin.ListAccountsForProvisionedPermissionSetOutput, error)
+}
+
+// ssmAPI is the subset of the SSM API accounts_list discovery uses
+type ssmAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
 }
 
 // CallerIdentity contains AWS STS GetCallerIdentity information
@@ -63,6 +99,10 @@ type OrganizationInfo struct {
 	IsManagementAccount bool
 	IsDelegatedAdmin    bool
 	DelegatedServices   []string
+	// DelegationProbed is set when ListDelegatedAdministrators was denied and
+	// IsDelegatedAdmin and DelegatedServices were inferred by
+	// probeDelegatedServices instead
+	DelegationProbed    bool
 }
 
 // redactARN extracts the type of identity from an ARN for safe logging
@@ -82,12 +122,8 @@ func redactARN(arn string) string {
 
 // NewAWSExecutionContext creates and initializes an AWS execution context
 func NewAWSExecutionContext(ctx context.Context, cfg *AWSConfig) (*AWSExecutionContext, error) {
-	l := log.WithFields(log.Fields{
-		"action": "NewAWSExecutionContext",
-	})
-
 	// Load base AWS config from environment (supports OIDC, instance profile, etc.)
-	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region), withRateLimit())
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -96,11 +132,24 @@ func NewAWSExecutionContext(ctx context.Context, cfg *AWSConfig) (*AWSExecutionC
 		Config:     cfg,
 		BaseConfig: awsCfg,
 		stsClient:  sts.NewFromConfig(awsCfg),
+		orgClient:  organizations.NewFromConfig(awsCfg),
 	}
+	if err := ec.discover(ctx); err != nil {
+		return nil, err
+	}
+	return ec, nil
+}
+
+// discover fills in the caller identity and organization context with the
+// context's clients and validates them against the configuration
+func (ec *AWSExecutionContext) discover(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action": "NewAWSExecutionContext",
+	})
 
 	// Get caller identity
 	if err := ec.discoverCallerIdentity(ctx); err != nil {
-		return nil, fmt.Errorf("failed to get caller identity: %w", err)
+		return fmt.Errorf("failed to get caller identity: %w", err)
 	}
 
 	l.WithFields(log.Fields{
@@ -115,11 +164,7 @@ func NewAWSExecutionContext(ctx context.Context, cfg *AWSConfig) (*AWSExecutionC
 	}
 
 	// Validate execution context
-	if err := ec.validateExecutionContext(); err != nil {
-		return nil, err
-	}
-
-	return ec, nil
+	return ec.validateExecutionContext()
 }
 
 // discoverCallerIdentity gets the current AWS identity
@@ -144,8 +189,6 @@ func (ec *AWSExecutionContext) discoverOrganizationContext(ctx context.Context)
 		"action": "discoverOrganizationContext",
 	})
 
-	ec.orgClient = organizations.NewFromConfig(ec.BaseConfig)
-
 	// Get organization info
 	orgOutput, err := ec.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
 	if err != nil {
@@ -168,10 +211,13 @@ func (ec *AWSExecutionContext) discoverOrganizationContext(ctx context.Context)
 		"isManagementAccount": ec.OrganizationInfo.IsManagementAccount,
 	}).Debug("Organization info discovered")
 
-	// If not management account, check delegated admin status
+	// If not management account, check delegated admin status. Member
+	// accounts are usually denied ListDelegatedAdministrators, in which case
+	// delegation is inferred from the delegated services' own APIs.
 	if !ec.OrganizationInfo.IsManagementAccount {
 		if err := ec.discoverDelegatedServices(ctx); err != nil {
-			l.WithError(err).Debug("Could not discover delegated services")
+			l.WithError(err).Debug("Could not discover delegated services, probing service APIs")
+			ec.probeDelegatedServices(ctx)
 		}
 	}
 
@@ -214,6 +260,47 @@ func (ec *AWSExecutionContext) discoverDelegatedServices(ctx context.Context) er
 	return nil
 }
 
+// probeDelegatedServices infers delegated administration from the APIs only
+// a delegated administrator (or the management account) can use:
+//
+//   - sso.amazonaws.com: sso-admin ListInstances returns the organization's
+//     Identity Center instance, owned by the management account. Instances
+//     the account owns itself are account instances, not delegation.
+//   - organizations.amazonaws.com: Organizations ListAccounts succeeds.
+//
+// A denied or failed probe means the service is not delegated.
+func (ec *AWSExecutionContext) probeDelegatedServices(ctx context.Context) {
+	l := log.WithFields(log.Fields{
+		"action": "probeDelegatedServices",
+	})
+	ec.OrganizationInfo.DelegationProbed = true
+
+	if ec.ssoClient == nil {
+		ec.ssoClient = ssoadmin.NewFromConfig(ec.BaseConfig)
+	}
+	instances, err := ec.ssoClient.ListInstances(ctx, &ssoadmin.ListInstancesInput{})
+	if err != nil {
+		l.WithError(err).Debug("sso-admin ListInstances denied, sso.amazonaws.com not delegated")
+	} else {
+		for _, instance := range instances.Instances {
+			owner := aws.ToString(instance.OwnerAccountId)
+			if owner == "" || owner == ec.OrganizationInfo.MasterAccountID {
+				ec.OrganizationInfo.DelegatedServices = append(ec.OrganizationInfo.DelegatedServices, "sso.amazonaws.com")
+				break
+			}
+		}
+	}
+
+	if _, err := ec.orgClient.ListAccounts(ctx, &organizations.ListAccountsInput{MaxResults: aws.Int32(1)}); err != nil {
+		l.WithError(err).Debug("Organizations ListAccounts denied, organizations.amazonaws.com not delegated")
+	} else {
+		ec.OrganizationInfo.DelegatedServices = append(ec.OrganizationInfo.DelegatedServices, "organizations.amazonaws.com")
+	}
+
+	ec.OrganizationInfo.IsDelegatedAdmin = len(ec.OrganizationInfo.DelegatedServices) > 0
+	l.WithField("services", ec.OrganizationInfo.DelegatedServices).Debug("Delegated services inferred")
+}
+
 // validateExecutionContext validates the execution context matches configuration
 func (ec *AWSExecutionContext) validateExecutionContext() error {
 	l := log.WithFields(log.Fields{
@@ -326,16 +413,16 @@ func (ec *AWSExecutionContext) AssumeRoleConfig(ctx context.Context, accountID s
 		"roleARN":   roleARN,
 	})
 
+	chain := ec.Config.ExecutionContext.RoleChain
+	if len(chain) > 0 {
+		l = l.WithField("roleChain", len(chain))
+	}
 	l.Debug("Assuming role for cross-account access")
 
-	// Create STS assume role provider
-	provider := stscreds.NewAssumeRoleProvider(ec.stsClient, roleARN, func(o *stscreds.AssumeRoleOptions) {
-		o.RoleSessionName = "vault-secret-sync"
-	})
-
-	// Create new config with assumed role credentials
+	// Create new config with assumed role credentials, through the role
+	// chain when the target roles only trust a hub account role
 	assumedConfig := ec.BaseConfig.Copy()
-	assumedConfig.Credentials = aws.NewCredentialsCache(provider)
+	assumedConfig.Credentials = assumeRoleCredentials(ec.BaseConfig, chain, roleARN, ec.Config.ExecutionContext.AssumeRole.stsOptions(ec.Config.session, "", accountID))
 
 	return assumedConfig, nil
 }
@@ -379,7 +466,7 @@ func (ec *AWSExecutionContext) CanAccessOrganizations() bool {
 }
 
 // GetIdentityCenterClient returns an Identity Center client if accessible
-func (ec *AWSExecutionContext) GetIdentityCenterClient(ctx context.Context) (*ssoadmin.Client, error) {
+func (ec *AWSExecutionContext) GetIdentityCenterClient(ctx context.Context) (ssoAdminAPI, error) {
 	if !ec.Config.IdentityCenter.Enabled {
 		return nil, fmt.Errorf("identity center not enabled in config")
 	}
@@ -478,6 +565,90 @@ func (ec *AWSExecutionContext) ListChildOUs(ctx context.Context, parentID string
 	return childOUs, nil
 }
 
+// DescribeAccount returns one account with its tags and parent OU
+func (ec *AWSExecutionContext) DescribeAccount(ctx context.Context, accountID string) (AccountInfo, error) {
+	if !ec.CanAccessOrganizations() {
+		return AccountInfo{}, fmt.Errorf("no access to Organizations API from this execution context")
+	}
+
+	output, err := ec.orgClient.DescribeAccount(ctx, &organizations.DescribeAccountInput{
+		AccountId: aws.String(accountID),
+	})
+	if err != nil {
+		return AccountInfo{}, fmt.Errorf("failed to describe account %s: %w", accountID, err)
+	}
+	acct := AccountInfo{
+		ID:     aws.ToString(output.Account.Id),
+		Name:   aws.ToString(output.Account.Name),
+		Email:  aws.ToString(output.Account.Email),
+		Status: string(output.Account.Status),
+	}
+
+	acct.Tags, err = ec.AccountTags(ctx, accountID)
+	if err != nil {
+		return AccountInfo{}, err
+	}
+	parents, err := ec.orgClient.ListParents(ctx, &organizations.ListParentsInput{
+		ChildId: aws.String(accountID),
+	})
+	if err != nil {
+		return AccountInfo{}, fmt.Errorf("failed to list parents of account %s: %w", accountID, err)
+	}
+	if len(parents.Parents) > 0 {
+		acct.OU = aws.ToString(parents.Parents[0].Id)
+	}
+	return acct, nil
+}
+
+// AccountTags returns an account's tags
+func (ec *AWSExecutionContext) AccountTags(ctx context.Context, accountID string) (map[string]string, error) {
+	if !ec.CanAccessOrganizations() {
+		return nil, fmt.Errorf("no access to Organizations API from this execution context")
+	}
+
+	tags := make(map[string]string)
+	paginator := organizations.NewListTagsForResourcePaginator(ec.orgClient, &organizations.ListTagsForResourceInput{
+		ResourceId: aws.String(accountID),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of account %s: %w", accountID, err)
+		}
+		for _, tag := range output.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// ParentOUs returns the OUs above an account or OU, nearest first, ending
+// with the organization root
+func (ec *AWSExecutionContext) ParentOUs(ctx context.Context, childID string) ([]string, error) {
+	if !ec.CanAccessOrganizations() {
+		return nil, fmt.Errorf("no access to Organizations API from this execution context")
+	}
+
+	var parents []string
+	for id := childID; ; {
+		output, err := ec.orgClient.ListParents(ctx, &organizations.ListParentsInput{
+			ChildId: aws.String(id),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parents of %s: %w", id, err)
+		}
+		if len(output.Parents) == 0 {
+			return parents, nil
+		}
+		parent := output.Parents[0]
+		parents = append(parents, aws.ToString(parent.Id))
+		if parent.Type == orgtypes.ParentTypeRoot {
+			return parents, nil
+		}
+		id = aws.ToString(parent.Id)
+	}
+}
+
 // AccountInfo contains basic AWS account information
 type AccountInfo struct {
 	ID     string
@@ -485,6 +656,8 @@ type AccountInfo struct {
 	Email  string
 	Status string
 	Tags   map[string]string
+	// OU is the ID of the account's parent OU, when discovered through one
+	OU string
 }
 
 // GetSSMParameter retrieves a parameter value from SSM Parameter Store
@@ -492,8 +665,41 @@ func (ec *AWSExecutionContext) GetSSMParameter(ctx context.Context, name string)
 	if ec.ssmClient == nil {
 		ec.ssmClient = ssm.NewFromConfig(ec.BaseConfig)
 	}
+	return getSSMParameter(ctx, ec.ssmClient, name)
+}
 
-	output, err := ec.ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+// GetSSMParameterAs retrieves a parameter value from SSM Parameter Store in
+// region with roleARN's credentials, as configAs. name may be a parameter ARN.
+func (ec *AWSExecutionContext) GetSSMParameterAs(ctx context.Context, name, region, roleARN string) (string, error) {
+	if roleARN == "" && (region == "" || region == ec.BaseConfig.Region) {
+		return ec.GetSSMParameter(ctx, name)
+	}
+	return getSSMParameter(ctx, ssm.NewFromConfig(ec.configAs(region, roleARN)), name)
+}
+
+// configAs returns the execution config in region (default: the execution
+// region) with roleARN's credentials (default: the execution identity's)
+func (ec *AWSExecutionContext) configAs(region, roleARN string) aws.Config {
+	cfg := ec.BaseConfig.Copy()
+	if region != "" {
+		cfg.Region = region
+	}
+	if roleARN != "" {
+		log.WithFields(log.Fields{
+			"action":  "configAs",
+			"roleARN": roleARN,
+		}).Debug("Assuming role")
+		provider := stscreds.NewAssumeRoleProvider(ec.stsClient, roleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "vault-secret-sync"
+			ec.Config.ExecutionContext.AssumeRole.tagSession(o, ec.Config.session, "", "")
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return cfg
+}
+
+func getSSMParameter(ctx context.Context, client ssmAPI, name string) (string, error) {
+	output, err := client.GetParameter(ctx, &ssm.GetParameterInput{
 		Name:           aws.String(name),
 		WithDecryption: aws.Bool(true),
 	})
@@ -528,6 +734,11 @@ func (ec *AWSExecutionContext) Summary() string {
 		} else {
 			sb.WriteString("  Role: Member Account\n")
 		}
+		if ec.OrganizationInfo.DelegationProbed {
+			sb.WriteString("  Delegation: inferred (ListDelegatedAdministrators was denied; sso.amazonaws.com is\n")
+			sb.WriteString("    assumed delegated when sso-admin ListInstances returns the organization's instance,\n")
+			sb.WriteString("    organizations.amazonaws.com when Organizations ListAccounts succeeds)\n")
+		}
 	}
 
 	sb.WriteString(fmt.Sprintf("  Control Tower: %v\n", ec.Config.ControlTower.Enabled))
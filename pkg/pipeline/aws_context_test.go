@@ -0,0 +1,525 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/ssoadmin/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSTS struct {
+	accountID string
+}
+
+func (f *fakeSTS) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String(f.accountID),
+		Arn:     aws.String(fmt.Sprintf("arn:aws:sts::%s:assumed-role/secrets-sync/ci", f.accountID)),
+		UserId:  aws.String("AROAEXAMPLE:ci"),
+	}, nil
+}
+
+func (f *fakeSTS) AssumeRole(context.Context, *sts.AssumeRoleInput, ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	return nil, fmt.Errorf("AccessDenied")
+}
+
+// fakeOrganizations serves an organization from maps keyed by parent or
+// account ID. Delegated administrators are returned one per page so callers
+// must paginate to find them.
+type fakeOrganizations struct {
+	managementID string
+	delegated    map[string][]string
+	accounts     map[string][]string
+	ous          map[string][]string
+	parents      map[string]string
+	tags         map[string]map[string]string
+	// failChildren makes listing the child OUs of these parents fail
+	failChildren map[string]bool
+	describeErr  error
+	// delegatedErr and accountsErr fail ListDelegatedAdministrators and
+	// ListAccounts, as they do for most member accounts
+	delegatedErr error
+	accountsErr  error
+}
+
+func (f *fakeOrganizations) DescribeOrganization(context.Context, *organizations.DescribeOrganizationInput, ...func(*organizations.Options)) (*organizations.DescribeOrganizationOutput, error) {
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+	return &organizations.DescribeOrganizationOutput{Organization: &orgtypes.Organization{
+		Id:              aws.String("o-example"),
+		MasterAccountId: aws.String(f.managementID),
+	}}, nil
+}
+
+func (f *fakeOrganizations) ListDelegatedAdministrators(_ context.Context, in *organizations.ListDelegatedAdministratorsInput, _ ...func(*organizations.Options)) (*organizations.ListDelegatedAdministratorsOutput, error) {
+	if f.delegatedErr != nil {
+		return nil, f.delegatedErr
+	}
+	ids := make([]string, 0, len(f.delegated))
+	for id := range f.delegated {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	page := 0
+	if in.NextToken != nil {
+		page, _ = strconv.Atoi(*in.NextToken)
+	}
+	out := &organizations.ListDelegatedAdministratorsOutput{}
+	if page < len(ids) {
+		out.DelegatedAdministrators = []orgtypes.DelegatedAdministrator{{Id: aws.String(ids[page])}}
+	}
+	if page+1 < len(ids) {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func (f *fakeOrganizations) ListDelegatedServicesForAccount(_ context.Context, in *organizations.ListDelegatedServicesForAccountInput, _ ...func(*organizations.Options)) (*organizations.ListDelegatedServicesForAccountOutput, error) {
+	out := &organizations.ListDelegatedServicesForAccountOutput{}
+	for _, svc := range f.delegated[aws.ToString(in.AccountId)] {
+		out.DelegatedServices = append(out.DelegatedServices, orgtypes.DelegatedService{ServicePrincipal: aws.String(svc)})
+	}
+	return out, nil
+}
+
+func (f *fakeOrganizations) account(id string) orgtypes.Account {
+	return orgtypes.Account{Id: aws.String(id), Name: aws.String("acct-" + id), Status: orgtypes.AccountStatusActive}
+}
+
+func (f *fakeOrganizations) ListAccounts(context.Context, *organizations.ListAccountsInput, ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	if f.accountsErr != nil {
+		return nil, f.accountsErr
+	}
+	out := &organizations.ListAccountsOutput{}
+	for _, ids := range f.accounts {
+		for _, id := range ids {
+			out.Accounts = append(out.Accounts, f.account(id))
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeOrganizations) ListAccountsForParent(_ context.Context, in *organizations.ListAccountsForParentInput, _ ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error) {
+	out := &organizations.ListAccountsForParentOutput{}
+	for _, id := range f.accounts[aws.ToString(in.ParentId)] {
+		out.Accounts = append(out.Accounts, f.account(id))
+	}
+	return out, nil
+}
+
+func (f *fakeOrganizations) ListOrganizationalUnitsForParent(_ context.Context, in *organizations.ListOrganizationalUnitsForParentInput, _ ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error) {
+	parent := aws.ToString(in.ParentId)
+	if f.failChildren[parent] {
+		return nil, fmt.Errorf("AccessDeniedException")
+	}
+	out := &organizations.ListOrganizationalUnitsForParentOutput{}
+	for _, id := range f.ous[parent] {
+		out.OrganizationalUnits = append(out.OrganizationalUnits, orgtypes.OrganizationalUnit{Id: aws.String(id)})
+	}
+	return out, nil
+}
+
+func (f *fakeOrganizations) DescribeAccount(_ context.Context, in *organizations.DescribeAccountInput, _ ...func(*organizations.Options)) (*organizations.DescribeAccountOutput, error) {
+	acct := f.account(aws.ToString(in.AccountId))
+	return &organizations.DescribeAccountOutput{Account: &acct}, nil
+}
+
+func (f *fakeOrganizations) ListTagsForResource(_ context.Context, in *organizations.ListTagsForResourceInput, _ ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	out := &organizations.ListTagsForResourceOutput{}
+	for k, v := range f.tags[aws.ToString(in.ResourceId)] {
+		out.Tags = append(out.Tags, orgtypes.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return out, nil
+}
+
+func (f *fakeOrganizations) ListParents(_ context.Context, in *organizations.ListParentsInput, _ ...func(*organizations.Options)) (*organizations.ListParentsOutput, error) {
+	parent, ok := f.parents[aws.ToString(in.ChildId)]
+	if !ok {
+		return &organizations.ListParentsOutput{}, nil
+	}
+	typ := orgtypes.ParentTypeOrganizationalUnit
+	if parent[:2] == "r-" {
+		typ = orgtypes.ParentTypeRoot
+	}
+	return &organizations.ListParentsOutput{Parents: []orgtypes.Parent{{Id: aws.String(parent), Type: typ}}}, nil
+}
+
+// fakeSSOAdmin serves one Identity Center instance whose permission sets are
+// keyed by name
+type fakeSSOAdmin struct {
+	// provisioned maps permission set names to the accounts they are
+	// provisioned in
+	provisioned map[string][]string
+	// assignments maps permission set names to account/principal pairs
+	assignments map[string][][2]string
+	// owner is the instance's owner account; no instance is listed when
+	// instancesErr is set
+	owner        string
+	instancesErr error
+}
+
+func (f *fakeSSOAdmin) ListInstances(context.Context, *ssoadmin.ListInstancesInput, ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error) {
+	if f.instancesErr != nil {
+		return nil, f.instancesErr
+	}
+	return &ssoadmin.ListInstancesOutput{Instances: []ssotypes.InstanceMetadata{{
+		InstanceArn:     aws.String("arn:aws:sso:::instance/ssoins-example"),
+		IdentityStoreId: aws.String("d-example"),
+		OwnerAccountId:  aws.String(f.owner),
+	}}}, nil
+}
+
+func (f *fakeSSOAdmin) names() []string {
+	var names []string
+	for name := range f.provisioned {
+		names = append(names, name)
+	}
+	for name := range f.assignments {
+		if _, ok := f.provisioned[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *fakeSSOAdmin) ListPermissionSets(context.Context, *ssoadmin.ListPermissionSetsInput, ...func(*ssoadmin.Options)) (*ssoadmin.ListPermissionSetsOutput, error) {
+	out := &ssoadmin.ListPermissionSetsOutput{}
+	for _, name := range f.names() {
+		out.PermissionSets = append(out.PermissionSets, "arn:aws:sso:::permissionSet/ssoins-example/"+name)
+	}
+	return out, nil
+}
+
+func permissionSetName(psARN string) string {
+	return psARN[len("arn:aws:sso:::permissionSet/ssoins-example/"):]
+}
+
+func (f *fakeSSOAdmin) DescribePermissionSet(_ context.Context, in *ssoadmin.DescribePermissionSetInput, _ ...func(*ssoadmin.Options)) (*ssoadmin.DescribePermissionSetOutput, error) {
+	return &ssoadmin.DescribePermissionSetOutput{PermissionSet: &ssotypes.PermissionSet{
+		PermissionSetArn: in.PermissionSetArn,
+		Name:             aws.String(permissionSetName(aws.ToString(in.PermissionSetArn))),
+	}}, nil
+}
+
+func (f *fakeSSOAdmin) ListAccountAssignments(_ context.Context, in *ssoadmin.ListAccountAssignmentsInput, _ ...func(*ssoadmin.Options)) (*ssoadmin.ListAccountAssignmentsOutput, error) {
+	out := &ssoadmin.ListAccountAssignmentsOutput{}
+	for _, a := range f.assignments[permissionSetName(aws.ToString(in.PermissionSetArn))] {
+		out.AccountAssignments = append(out.AccountAssignments, ssotypes.AccountAssignment{
+			AccountId:   aws.String(a[0]),
+			PrincipalId: aws.String(a[1]),
+		})
+	}
+	return out, nil
+}
+
+func (f *fakeSSOAdmin) ListAccountsForProvisionedPermissionSet(_ context.Context, in *ssoadmin.ListAccountsForProvisionedPermissionSetInput, _ ...func(*ssoadmin.Options)) (*ssoadmin.ListAccountsForProvisionedPermissionSetOutput, error) {
+	return &ssoadmin.ListAccountsForProvisionedPermissionSetOutput{
+		AccountIds: f.provisioned[permissionSetName(aws.ToString(in.PermissionSetArn))],
+	}, nil
+}
+
+type fakeSSM map[string]string
+
+func (f fakeSSM) GetParameter(_ context.Context, in *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	value, ok := f[aws.ToString(in.Name)]
+	if !ok {
+		return nil, fmt.Errorf("ParameterNotFound")
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Name: in.Name, Value: aws.String(value)}}, nil
+}
+
+func TestAWSExecutionContextDiscover(t *testing.T) {
+	org := &fakeOrganizations{
+		managementID: "111111111111",
+		delegated: map[string][]string{
+			"222222222222": {"sso.amazonaws.com", "member.org.stacksets.cloudformation.amazonaws.com"},
+			"333333333333": {"organizations.amazonaws.com"},
+		},
+	}
+	tests := []struct {
+		name       string
+		accountID  string
+		config     ExecutionContextConfig
+		describe   error
+		management bool
+		delegated  bool
+		services   []string
+		identity   bool
+		orgs       bool
+		wantErr    string
+	}{
+		{name: "management account", accountID: "111111111111", management: true, identity: true, orgs: true},
+		{
+			name: "delegated for sso", accountID: "222222222222", config: ExecutionContextConfig{Type: ExecutionContextDelegated},
+			delegated: true, services: org.delegated["222222222222"], identity: true,
+		},
+		{
+			name: "delegated on a later page", accountID: "333333333333",
+			delegated: true, services: org.delegated["333333333333"], orgs: true,
+		},
+		{name: "member account", accountID: "444444444444", config: ExecutionContextConfig{Type: ExecutionContextHub}},
+		{
+			name: "member configured as management", accountID: "444444444444", config: ExecutionContextConfig{Type: ExecutionContextManagement},
+			wantErr: "configured as management_account but running in member account 444444444444 (management is 111111111111)",
+		},
+		{
+			name: "account mismatch", accountID: "111111111111", config: ExecutionContextConfig{AccountID: "999999999999"},
+			wantErr: "config specifies account 999999999999 but running as 111111111111",
+		},
+		{name: "no organizations access", accountID: "444444444444", describe: fmt.Errorf("AWSOrganizationsNotInUseException")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org.describeErr = tt.describe
+			ec := &AWSExecutionContext{
+				Config:    &AWSConfig{ExecutionContext: tt.config},
+				stsClient: &fakeSTS{accountID: tt.accountID},
+				orgClient: org,
+			}
+			err := ec.discover(context.Background())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.accountID, ec.CallerIdentity.AccountID)
+			if tt.describe != nil {
+				assert.Nil(t, ec.OrganizationInfo, "a missing organization is not fatal")
+			} else {
+				require.NotNil(t, ec.OrganizationInfo)
+				assert.Equal(t, tt.management, ec.OrganizationInfo.IsManagementAccount)
+				assert.Equal(t, tt.delegated, ec.OrganizationInfo.IsDelegatedAdmin)
+				assert.Equal(t, tt.services, ec.OrganizationInfo.DelegatedServices)
+			}
+			assert.Equal(t, tt.identity, ec.CanAccessIdentityCenter())
+			assert.Equal(t, tt.orgs, ec.CanAccessOrganizations())
+		})
+	}
+}
+
+func TestProbeDelegatedServices(t *testing.T) {
+	denied := fmt.Errorf("AccessDeniedException")
+	tests := []struct {
+		name     string
+		sso      *fakeSSOAdmin
+		orgs     error
+		services []string
+	}{
+		{name: "delegated sso admin", sso: &fakeSSOAdmin{owner: "111111111111"}, orgs: denied, services: []string{"sso.amazonaws.com"}},
+		{name: "delegated for both", sso: &fakeSSOAdmin{owner: "111111111111"}, services: []string{"sso.amazonaws.com", "organizations.amazonaws.com"}},
+		{name: "account instance", sso: &fakeSSOAdmin{owner: "444444444444"}, orgs: denied},
+		{name: "nothing delegated", sso: &fakeSSOAdmin{instancesErr: denied}, orgs: denied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec := &AWSExecutionContext{
+				Config:    &AWSConfig{},
+				stsClient: &fakeSTS{accountID: "444444444444"},
+				orgClient: &fakeOrganizations{managementID: "111111111111", delegatedErr: denied, accountsErr: tt.orgs},
+				ssoClient: tt.sso,
+			}
+			require.NoError(t, ec.discover(context.Background()))
+			assert.True(t, ec.OrganizationInfo.DelegationProbed)
+			assert.Equal(t, tt.services, ec.OrganizationInfo.DelegatedServices)
+			assert.Equal(t, len(tt.services) > 0, ec.OrganizationInfo.IsDelegatedAdmin)
+			assert.Contains(t, ec.Summary(), "Delegation: inferred")
+		})
+	}
+
+	// A successful ListDelegatedAdministrators is authoritative
+	ec := &AWSExecutionContext{
+		Config:    &AWSConfig{},
+		stsClient: &fakeSTS{accountID: "444444444444"},
+		orgClient: &fakeOrganizations{managementID: "111111111111"},
+		ssoClient: &fakeSSOAdmin{owner: "111111111111"},
+	}
+	require.NoError(t, ec.discover(context.Background()))
+	assert.False(t, ec.OrganizationInfo.IsDelegatedAdmin)
+	assert.NotContains(t, ec.Summary(), "Delegation: inferred")
+}
+
+func TestAWSExecutionContextGetRoleARN(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    AWSConfig
+		accountID string
+		want      string
+	}{
+		{name: "same account", accountID: "111111111111"},
+		{name: "organization default", accountID: "222222222222", want: "arn:aws:iam::222222222222:role/OrganizationAccountAccessRole"},
+		{
+			name:      "control tower",
+			config:    AWSConfig{ControlTower: ControlTowerConfig{Enabled: true}},
+			accountID: "222222222222",
+			want:      "arn:aws:iam::222222222222:role/AWSControlTowerExecution",
+		},
+		{
+			name: "control tower role with path",
+			config: AWSConfig{ControlTower: ControlTowerConfig{
+				Enabled:       true,
+				ExecutionRole: ExecutionRoleConfig{Name: "SecretsSync", Path: "platform"},
+			}},
+			accountID: "222222222222",
+			want:      "arn:aws:iam::222222222222:role/platform/SecretsSync",
+		},
+		{
+			name: "custom pattern wins",
+			config: AWSConfig{
+				ExecutionContext: ExecutionContextConfig{CustomRolePattern: "arn:aws:iam::{{.AccountID}}:role/SecretsHubAccess"},
+				ControlTower:     ControlTowerConfig{Enabled: true},
+			},
+			accountID: "222222222222",
+			want:      "arn:aws:iam::222222222222:role/SecretsHubAccess",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ec := &AWSExecutionContext{Config: &tt.config, CallerIdentity: &CallerIdentity{AccountID: "111111111111"}}
+			assert.Equal(t, tt.want, ec.GetRoleARN(tt.accountID))
+		})
+	}
+}
+
+// managementDiscovery returns a discovery service running in the management
+// account of org
+func managementDiscovery(cfg *Config, org organizationsAPI) *DiscoveryService {
+	ec := &AWSExecutionContext{
+		Config:           &cfg.AWS,
+		CallerIdentity:   &CallerIdentity{AccountID: "111111111111"},
+		OrganizationInfo: &OrganizationInfo{ID: "o-example", MasterAccountID: "111111111111", IsManagementAccount: true},
+		orgClient:        org,
+	}
+	return NewDiscoveryService(context.Background(), ec, cfg)
+}
+
+func TestDiscoverFromOrganizationsRecursive(t *testing.T) {
+	org := &fakeOrganizations{
+		accounts: map[string][]string{
+			"ou-workloads": {"222222222222"},
+			"ou-prod":      {"333333333333"},
+			"ou-prod-eu":   {"444444444444"},
+			"ou-sandbox":   {"555555555555"},
+		},
+		ous: map[string][]string{
+			"ou-workloads": {"ou-prod", "ou-sandbox"},
+			"ou-prod":      {"ou-prod-eu"},
+		},
+		tags: map[string]map[string]string{
+			"333333333333": {"env": "prod"},
+			"444444444444": {"env": "prod"},
+		},
+		failChildren: map[string]bool{"ou-sandbox": true},
+	}
+	d := managementDiscovery(&Config{}, org)
+
+	accounts, err := d.discoverFromOrganizations(&OrganizationsDiscovery{OU: "ou-workloads", Recursive: true})
+	require.NoError(t, err)
+	ous := map[string]string{}
+	for _, a := range accounts {
+		ous[a.ID] = a.OU
+	}
+	assert.Equal(t, map[string]string{
+		"222222222222": "ou-workloads",
+		"333333333333": "ou-prod",
+		"444444444444": "ou-prod-eu",
+		"555555555555": "ou-sandbox",
+	}, ous, "accounts keep their direct OU, and an OU whose children cannot be listed still contributes its accounts")
+
+	accounts, err = d.discoverFromOrganizations(&OrganizationsDiscovery{OU: "ou-workloads"})
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "222222222222", accounts[0].ID)
+
+	accounts, err = d.discoverFromOrganizations(&OrganizationsDiscovery{OU: "ou-workloads", Recursive: true, Tags: map[string]string{"env": "prod"}})
+	require.NoError(t, err)
+	ids := []string{accounts[0].ID, accounts[1].ID}
+	sort.Strings(ids)
+	assert.Equal(t, []string{"333333333333", "444444444444"}, ids)
+
+	d.awsCtx.OrganizationInfo = &OrganizationInfo{IsDelegatedAdmin: true, DelegatedServices: []string{"sso.amazonaws.com"}}
+	_, err = d.discoverFromOrganizations(&OrganizationsDiscovery{OU: "ou-workloads"})
+	assert.ErrorContains(t, err, "no access to Organizations API")
+}
+
+func TestAWSExecutionContextParentOUs(t *testing.T) {
+	org := &fakeOrganizations{
+		parents: map[string]string{
+			"444444444444": "ou-prod-eu",
+			"ou-prod-eu":   "ou-prod",
+			"ou-prod":      "r-root",
+		},
+		tags: map[string]map[string]string{"444444444444": {"team": "payments"}},
+	}
+	ec := managementDiscovery(&Config{}, org).awsCtx
+
+	parents, err := ec.ParentOUs(context.Background(), "444444444444")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ou-prod-eu", "ou-prod", "r-root"}, parents)
+
+	acct, err := ec.DescribeAccount(context.Background(), "444444444444")
+	require.NoError(t, err)
+	assert.Equal(t, "ou-prod-eu", acct.OU)
+	assert.Equal(t, map[string]string{"team": "payments"}, acct.Tags)
+}
+
+func TestDiscoverFromIdentityCenterPermissionSet(t *testing.T) {
+	cfg := &Config{AWS: AWSConfig{IdentityCenter: IdentityCenterConfig{Enabled: true}}}
+	d := managementDiscovery(cfg, &fakeOrganizations{})
+	d.awsCtx.ssoClient = &fakeSSOAdmin{
+		provisioned: map[string][]string{
+			"SecretsReader": {"222222222222", "333333333333"},
+			"Admin":         {"444444444444"},
+		},
+	}
+
+	accounts, err := d.discoverFromIdentityCenter(&IdentityCenterDiscovery{PermissionSet: "SecretsReader"})
+	require.NoError(t, err)
+	assert.Equal(t, []AccountInfo{{ID: "222222222222"}, {ID: "333333333333"}}, accounts)
+
+	_, err = d.discoverFromIdentityCenter(&IdentityCenterDiscovery{PermissionSet: "Missing"})
+	assert.ErrorContains(t, err, "permission set not found: Missing")
+
+	// Group assignments are filtered by principal and enriched from Organizations
+	sso := &fakeSSOAdmin{assignments: map[string][][2]string{
+		"SecretsReader": {{"222222222222", "group-1"}, {"333333333333", "group-2"}},
+		"Admin":         {{"222222222222", "group-1"}},
+	}}
+	d.awsCtx.orgClient = &fakeOrganizations{accounts: map[string][]string{"ou-workloads": {"222222222222"}}}
+	accounts, err = d.getAccountsForGroup(sso, "arn:aws:sso:::instance/ssoins-example", "group-1")
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "acct-222222222222", accounts[0].Name)
+}
+
+func TestGetAccountsFromSSM(t *testing.T) {
+	d := managementDiscovery(&Config{}, &fakeOrganizations{})
+	d.awsCtx.ssmClient = fakeSSM{
+		"/platform/sandboxes": "111111111111, 222222222222",
+		"/platform/empty":     "  ",
+	}
+
+	accounts, err := d.discoverFromAccountsList(&AccountsListDiscovery{Source: "ssm:/platform/sandboxes"})
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "222222222222", accounts[1].ID)
+
+	_, err = d.discoverFromAccountsList(&AccountsListDiscovery{Source: "ssm:/platform/empty"})
+	assert.ErrorContains(t, err, "SSM parameter /platform/empty is empty")
+
+	_, err = d.discoverFromAccountsList(&AccountsListDiscovery{Source: "ssm:/platform/missing"})
+	assert.ErrorContains(t, err, "failed to get SSM parameter /platform/missing")
+}
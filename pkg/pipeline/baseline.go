@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// baselineValueKey holds a captured secret's value in the merge store
+const baselineValueKey = "value"
+
+// baselineStoreName is the merge store name the baseline of an account and
+// region is captured under
+func baselineStoreName(accountID, region string) string {
+	return fmt.Sprintf("baseline.%s.%s", accountID, region)
+}
+
+// BaselineCapture reports what CaptureBaseline captured
+type BaselineCapture struct {
+	// Secrets counts the secrets captured from each destination, by
+	// "<target>/<destination>"
+	Secrets map[string]int `json:"secrets"`
+	// Unchecked are destinations whose contents cannot be read back, as
+	// "<target>/<destination>"
+	Unchecked []string `json:"unchecked,omitempty"`
+	// Errors are destinations that could not be captured, as
+	// "<target>/<destination>: <error>"
+	Errors []string `json:"errors,omitempty"`
+}
+
+// CaptureBaseline copies what the Secrets Manager destinations of the given
+// targets (all when empty) hold now into the merge store: the secrets the
+// targets would write and every other secret under their name prefix.
+// BaselineDiff later compares the merged state with this baseline, e.g. to
+// prove a migration changes nothing compared to the state it replaces.
+// Capturing again overwrites the captured secrets.
+func (p *Pipeline) CaptureBaseline(ctx context.Context, targets []string) (*BaselineCapture, error) {
+	targets, err := p.selectTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.driftReader == nil {
+		p.driftReader = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	return p.captureBaseline(ctx, store, p.driftReader, targets), nil
+}
+
+func (p *Pipeline) captureBaseline(ctx context.Context, store mergeStore, reader destinationReader, targets []string) *BaselineCapture {
+	capture := &BaselineCapture{Secrets: make(map[string]int)}
+	for _, name := range targets {
+		target := p.config.Targets[name]
+		l := log.WithFields(log.Fields{
+			"action": "captureBaseline",
+			"target": name,
+		})
+		dests := target.ResolvedDestinations()
+		if target.Envelope != nil {
+			for _, d := range dests {
+				capture.Unchecked = append(capture.Unchecked, name+"/"+d.Label())
+			}
+			continue
+		}
+		sourcePath, err := p.syncSourcePath(name)
+		if err != nil {
+			capture.Errors = append(capture.Errors, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		// Targets that have not merged yet only capture their name prefix
+		snapshot, err := readSnapshot(ctx, store, name)
+		if err != nil {
+			capture.Errors = append(capture.Errors, fmt.Sprintf("%s: failed to read merged secrets: %s", name, err))
+			continue
+		}
+
+		for _, d := range dests {
+			label := d.Label()
+			if !d.requiresAccountID() || d.Kubernetes != nil {
+				capture.Unchecked = append(capture.Unchecked, name+"/"+label)
+				continue
+			}
+			_, values, err := p.destinationSecrets(ctx, reader, name, sourcePath, target, d, snapshot)
+			if err == nil {
+				err = writeBaseline(ctx, store, baselineStoreName(d.AccountID, p.config.destinationRegion(target, d)), values)
+			}
+			if err != nil {
+				l.WithError(err).WithField("destination", label).Error("Failed to capture baseline")
+				capture.Errors = append(capture.Errors, fmt.Sprintf("%s/%s: %s", name, label, err))
+				continue
+			}
+			capture.Secrets[name+"/"+label] = len(values)
+		}
+	}
+	return capture
+}
+
+// writeBaseline stores captured secrets under a baseline's merge store name,
+// escaping their names so a / stays part of the name
+func writeBaseline(ctx context.Context, store mergeStore, storeName string, values map[string][]byte) error {
+	for destName, b := range values {
+		data := map[string]interface{}{baselineValueKey: string(b)}
+		if err := store.WriteSecret(ctx, storeName, url.PathEscape(destName), data); err != nil {
+			return fmt.Errorf("failed to write baseline of %s: %w", destName, err)
+		}
+	}
+	return nil
+}
+
+// BaselineDiff compares the merged secrets of the given targets (all when
+// empty), as their destinations should hold them, with the captured baseline
+// instead of the live destinations. Destinations without a baseline are
+// recorded as errors.
+func (p *Pipeline) BaselineDiff(ctx context.Context, targets []string) (*DriftReport, error) {
+	targets, err := p.selectTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.drift(ctx, store, baselineReader{config: p.config, store: store}, targets), nil
+}
+
+// baselineReader reads Secrets Manager destinations from their captured
+// baselines
+type baselineReader struct {
+	config *Config
+	store  mergeStore
+}
+
+func (r baselineReader) SecretsManagerValues(ctx context.Context, target Target, d Destination, names []string, prefix string) (map[string][]byte, error) {
+	storeName := baselineStoreName(d.AccountID, r.config.destinationRegion(target, d))
+	captured, err := r.store.ListSecrets(ctx, storeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list baseline: %w", err)
+	}
+	if len(captured) == 0 {
+		return nil, fmt.Errorf("no baseline captured for %s; run vss baseline capture first", storeName)
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+	values := make(map[string][]byte)
+	for _, escaped := range captured {
+		name, err := url.PathUnescape(escaped)
+		if err != nil {
+			continue
+		}
+		if !wanted[name] && (prefix == "" || !strings.HasPrefix(name, prefix)) {
+			continue
+		}
+		data, err := r.store.ReadSecret(ctx, storeName, escaped)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read baseline of %s: %w", name, err)
+		}
+		value, _ := data[baselineValueKey].(string)
+		values[name] = []byte(value)
+	}
+	return values, nil
+}
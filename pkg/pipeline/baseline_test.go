@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseline(t *testing.T) {
+	ctx := context.Background()
+	p := &Pipeline{config: &Config{
+		AWS:        AWSConfig{Region: "us-east-1"},
+		Vault:      VaultConfig{Address: "https://vault.example.com"},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg":  {AccountID: "111111111111", SecretPrefix: "app/"},
+			"Prod": {AccountID: "222222222222"},
+			"Repo": {GitHub: &GitHubDestination{Owner: "org", Repo: "app"}},
+		},
+	}}
+	store := memMergeStore{
+		"Stg": {"db": {"password": "hunter2"}},
+	}
+	// The state the pipeline replaces, e.g. written by Terraform
+	reader := fakeDestinationReader{
+		"111111111111": {
+			"app/db":     `{"password":"hunter2"}`,
+			"app/legacy": `{"key":"old"}`,
+			"unrelated":  `{"x":"y"}`,
+		},
+	}
+
+	capture := p.captureBaseline(ctx, store, reader, []string{"Prod", "Repo", "Stg"})
+	assert.Equal(t, map[string]int{"Stg/aws:111111111111": 2}, capture.Secrets)
+	assert.Equal(t, []string{"Repo/github:org/app"}, capture.Unchecked)
+	assert.Equal(t, []string{"Prod/aws:222222222222: AccessDenied"}, capture.Errors)
+	assert.Equal(t, map[string]interface{}{"value": `{"key":"old"}`}, store["baseline.111111111111.us-east-1"]["app%2Flegacy"])
+
+	// Later edits to the destination do not change the baseline
+	reader["111111111111"]["app/db"] = `{"password":"edited"}`
+
+	report := p.drift(ctx, store, baselineReader{config: p.config, store: store}, []string{"Prod", "Stg"})
+	assert.Equal(t, []string{"Prod/aws:222222222222: no baseline captured for baseline.222222222222.us-east-1; run vss baseline capture first"}, report.Errors)
+	require.Len(t, report.Diff.Targets, 1)
+	changes := report.Diff.Targets[0].Changes
+	require.Len(t, changes, 2)
+	assert.Equal(t, "app/db", changes[0].Path)
+	assert.Equal(t, diff.ChangeTypeUnchanged, changes[0].ChangeType)
+	assert.Equal(t, "app/legacy", changes[1].Path)
+	assert.Equal(t, diff.ChangeTypeRemoved, changes[1].ChangeType)
+
+	// Merging the legacy secret makes the pipeline zero-sum against the baseline
+	store["Stg"]["legacy"] = map[string]interface{}{"key": "old"}
+	report = p.drift(ctx, store, baselineReader{config: p.config, store: store}, []string{"Stg"})
+	assert.False(t, report.HasDrift())
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+// BreakglassRequest is an emergency read of a synced secret's value
+type BreakglassRequest struct {
+	Target string
+	// Destination picks one of the target's Secrets Manager destinations by
+	// label; it may be empty when the target has only one
+	Destination string
+	Secret      string
+	// Reason is the mandatory justification recorded with the read
+	Reason string
+	// Operator is who asked for the read (GITHUB_ACTOR or the local user)
+	Operator string
+}
+
+// BreakglassRecord is the audit record of a break-glass read, sent to
+// breakglass hooks and written to the audit log. It never holds the value.
+type BreakglassRecord struct {
+	Target      string    `json:"target"`
+	Destination string    `json:"destination"`
+	Secret      string    `json:"secret"`
+	Reason      string    `json:"reason"`
+	Operator    string    `json:"operator"`
+	CallerARN   string    `json:"caller_arn,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// breakglassReader reads secret values with the pipeline's credentials
+type breakglassReader interface {
+	CallerARN(ctx context.Context) (string, error)
+	SecretValue(ctx context.Context, target Target, d Destination, name string) ([]byte, error)
+}
+
+// Breakglass reads a secret from one of a target's Secrets Manager
+// destinations. The read is written to the audit log and breakglass hooks are
+// called before the value is read; a failing required hook refuses the read.
+func (p *Pipeline) Breakglass(ctx context.Context, req BreakglassRequest) ([]byte, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, &OptionError{Option: "reason", Value: `""`, Reason: "a justification is required"}
+	}
+	if req.Secret == "" {
+		return nil, &OptionError{Option: "secret", Value: `""`, Reason: "a secret name is required"}
+	}
+	target, ok := p.config.Targets[req.Target]
+	if !ok {
+		return nil, &OptionError{Option: "target", Value: fmt.Sprintf("%q", req.Target), Reason: "not found in configuration"}
+	}
+	d, err := breakglassDestination(target, req.Destination)
+	if err != nil {
+		return nil, err
+	}
+	if p.breakglass == nil {
+		p.breakglass = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+
+	rec := BreakglassRecord{
+		Target:      req.Target,
+		Destination: d.Label(),
+		Secret:      req.Secret,
+		Reason:      req.Reason,
+		Operator:    req.Operator,
+		Time:        time.Now().UTC(),
+	}
+	if p.awsCtx != nil && p.awsCtx.CallerIdentity != nil {
+		rec.CallerARN = p.awsCtx.CallerIdentity.ARN
+	} else if rec.CallerARN, err = p.breakglass.CallerARN(ctx); err != nil {
+		// A read that cannot be attributed is not allowed
+		return nil, fmt.Errorf("failed to identify caller: %w", err)
+	}
+
+	l := log.WithFields(log.Fields{
+		"action":      "Pipeline.Breakglass",
+		"audit":       true,
+		"target":      rec.Target,
+		"destination": rec.Destination,
+		"secret":      rec.Secret,
+		"reason":      rec.Reason,
+		"operator":    rec.Operator,
+		"callerArn":   rec.CallerARN,
+	})
+	l.Warn("Break-glass read requested")
+
+	if err := p.runHooks(ctx, HookPayload{Event: HookBreakglass, Target: req.Target, Breakglass: &rec}); err != nil {
+		l.WithError(err).Error("Break-glass read refused")
+		return nil, err
+	}
+	value, err := p.breakglass.SecretValue(ctx, target, d, req.Secret)
+	if err != nil {
+		l.WithError(err).Error("Break-glass read failed")
+		return nil, err
+	}
+	l.Warn("Break-glass read completed")
+	return value, nil
+}
+
+// breakglassDestination picks the Secrets Manager destination to read from
+func breakglassDestination(target Target, label string) (Destination, error) {
+	var candidates []Destination
+	for _, d := range target.ResolvedDestinations() {
+		if !d.requiresAccountID() || d.Kubernetes != nil {
+			continue
+		}
+		if label == "" || d.Label() == label {
+			candidates = append(candidates, d)
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case label != "":
+		return Destination{}, &OptionError{Option: "destination", Value: fmt.Sprintf("%q", label), Reason: "not a Secrets Manager destination of the target"}
+	case len(candidates) == 0:
+		return Destination{}, fmt.Errorf("target has no Secrets Manager destination")
+	}
+	labels := make([]string, 0, len(candidates))
+	for _, d := range candidates {
+		labels = append(labels, d.Label())
+	}
+	return Destination{}, fmt.Errorf("target has several Secrets Manager destinations, pick one of: %s", strings.Join(labels, ", "))
+}
+
+func (r *liveReadinessProbe) CallerARN(ctx context.Context) (string, error) {
+	cfg, err := r.awsConfig(ctx, Target{})
+	if err != nil {
+		return "", err
+	}
+	output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.Arn), nil
+}
+
+func (r *liveReadinessProbe) SecretValue(ctx context.Context, target Target, d Destination, name string) ([]byte, error) {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return nil, err
+	}
+	output, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret value: %w", err)
+	}
+	if output.SecretString != nil {
+		return []byte(aws.ToString(output.SecretString)), nil
+	}
+	return output.SecretBinary, nil
+}
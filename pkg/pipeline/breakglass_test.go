@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBreakglassReader serves secret values keyed by account ID then name
+type fakeBreakglassReader struct {
+	values map[string]map[string]string
+	reads  int
+}
+
+func (f *fakeBreakglassReader) CallerARN(context.Context) (string, error) {
+	return "arn:aws:sts::999999999999:assumed-role/vss/ci", nil
+}
+
+func (f *fakeBreakglassReader) SecretValue(_ context.Context, _ Target, d Destination, name string) ([]byte, error) {
+	f.reads++
+	return []byte(f.values[d.AccountID][name]), nil
+}
+
+func TestBreakglass(t *testing.T) {
+	var received []HookPayload
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload HookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received = append(received, payload)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	reader := &fakeBreakglassReader{values: map[string]map[string]string{
+		"111111111111": {"db": `{"password":"hunter2"}`},
+	}}
+	p := &Pipeline{
+		config: &Config{
+			Targets: map[string]Target{
+				"Prod":  {AccountID: "111111111111"},
+				"Multi": {Destinations: []Destination{{AccountID: "111111111111"}, {AccountID: "222222222222", Region: "eu-west-1"}}},
+				"Repo":  {GitHub: &GitHubDestination{Owner: "org", Repo: "app"}},
+			},
+			Pipeline: PipelineSettings{Hooks: []Hook{
+				{Name: "page", On: []HookEvent{HookBreakglass}, HTTP: srv.URL, Required: true},
+			}},
+		},
+		breakglass: reader,
+	}
+	ctx := context.Background()
+
+	value, err := p.Breakglass(ctx, BreakglassRequest{Target: "Prod", Secret: "db", Reason: "INC-42 database down", Operator: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"password":"hunter2"}`, string(value))
+	require.Len(t, received, 1)
+	assert.Equal(t, HookBreakglass, received[0].Event)
+	assert.Equal(t, "Prod", received[0].Target)
+	rec := received[0].Breakglass
+	require.NotNil(t, rec)
+	assert.Equal(t, "INC-42 database down", rec.Reason)
+	assert.Equal(t, "alice", rec.Operator)
+	assert.Equal(t, "arn:aws:sts::999999999999:assumed-role/vss/ci", rec.CallerARN)
+	assert.Equal(t, "aws:111111111111", rec.Destination)
+
+	// A failing required hook refuses the read
+	status = http.StatusInternalServerError
+	_, err = p.Breakglass(ctx, BreakglassRequest{Target: "Prod", Secret: "db", Reason: "INC-43"})
+	var hookErr *HookError
+	assert.True(t, errors.As(err, &hookErr))
+	assert.Equal(t, 1, reader.reads)
+
+	_, err = p.Breakglass(ctx, BreakglassRequest{Target: "Prod", Secret: "db", Reason: "  "})
+	assert.ErrorContains(t, err, "a justification is required")
+	_, err = p.Breakglass(ctx, BreakglassRequest{Target: "Multi", Secret: "db", Reason: "INC-44"})
+	assert.ErrorContains(t, err, "pick one of: aws:111111111111, aws:222222222222/eu-west-1")
+	_, err = p.Breakglass(ctx, BreakglassRequest{Target: "Repo", Secret: "db", Reason: "INC-45"})
+	assert.ErrorContains(t, err, "no Secrets Manager destination")
+	assert.Equal(t, 1, reader.reads)
+}
@@ -0,0 +1,384 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbcom/secretsync/internal/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// claimsNamespace is where claims are kept in the merge store, alongside the
+// targets' merged secrets
+const claimsNamespace = "_vss_claims"
+
+// DefaultClaimTTL is how long a claim lasts without a heartbeat
+const DefaultClaimTTL = 5 * time.Minute
+
+// Coordination modes
+const (
+	// CoordinationEnforce refuses runs against targets another controller claims
+	CoordinationEnforce = "enforce"
+	// CoordinationWarn reports such targets and runs them anyway
+	CoordinationWarn = "warn"
+)
+
+// CoordinationSettings make controllers sharing a merge store, such as a CI
+// pipeline and `vss serve` in a cluster, claim the targets they write so two
+// of them never write the same target at once
+type CoordinationSettings struct {
+	// Controller names this deployment in claims, e.g. ci or cluster (default: vss)
+	Controller string `mapstructure:"controller" yaml:"controller,omitempty"`
+	// TTL is how long a claim lasts without a heartbeat; heartbeats are sent
+	// every third of it (default 5m)
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl,omitempty"`
+	// Mode is enforce (default) or warn
+	Mode string `mapstructure:"mode" yaml:"mode,omitempty"`
+}
+
+func (s *CoordinationSettings) validate() error {
+	if s.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	if s.TTL > 0 && s.TTL < 30*time.Second {
+		return fmt.Errorf("ttl must be at least 30s, got %s", s.TTL)
+	}
+	switch s.Mode {
+	case "", CoordinationEnforce, CoordinationWarn:
+	default:
+		return fmt.Errorf("mode must be enforce or warn, got %q", s.Mode)
+	}
+	return nil
+}
+
+func (s *CoordinationSettings) ttl() time.Duration {
+	if s.TTL == 0 {
+		return DefaultClaimTTL
+	}
+	return s.TTL
+}
+
+func (s *CoordinationSettings) controller() string {
+	if s.Controller == "" {
+		return "vss"
+	}
+	return s.Controller
+}
+
+// Claim records that a controller is writing a target
+type Claim struct {
+	Target     string `json:"target"`
+	Controller string `json:"controller"`
+	// Instance identifies the process holding the claim (host/pid)
+	Instance string `json:"instance"`
+	// Run links to the CI run holding the claim, when there is one
+	Run       string        `json:"run,omitempty"`
+	Acquired  time.Time     `json:"acquired"`
+	Heartbeat time.Time     `json:"heartbeat"`
+	TTL       time.Duration `json:"ttl"`
+	// Released is set when the holder finished with the target
+	Released *time.Time `json:"released,omitempty"`
+}
+
+// Live reports whether the claim still holds the target at now
+func (c Claim) Live(now time.Time) bool {
+	return c.Released == nil && now.Sub(c.Heartbeat) <= c.TTL
+}
+
+func (c Claim) holder() string {
+	s := fmt.Sprintf("%s (%s)", c.Controller, c.Instance)
+	if c.Run != "" {
+		s += " " + c.Run
+	}
+	return s
+}
+
+// ClaimConflict is a target claimed by another controller
+type ClaimConflict struct {
+	Target string `json:"target"`
+	Holder Claim  `json:"holder"`
+}
+
+// ClaimConflictError reports targets that other controllers are writing
+type ClaimConflictError struct {
+	Conflicts []ClaimConflict
+}
+
+func (e *ClaimConflictError) Error() string {
+	now := time.Now()
+	parts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		parts[i] = fmt.Sprintf("%s is claimed by %s, last heartbeat %s ago",
+			c.Target, c.Holder.holder(), now.Sub(c.Holder.Heartbeat).Round(time.Second))
+	}
+	return fmt.Sprintf("split brain: %d targets are claimed by other controllers: %s",
+		len(e.Conflicts), strings.Join(parts, "; "))
+}
+
+// claimInstance identifies this process in claims
+func claimInstance() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+// ciRunURL links to the GitHub Actions run of this process, if any
+func ciRunURL() string {
+	server, repo, id := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+	if server == "" || repo == "" || id == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, id)
+}
+
+// claimer holds one run's claims and keeps them alive
+type claimer struct {
+	store    mergeStore
+	settings CoordinationSettings
+	instance string
+	run      string
+	now      func() time.Time
+
+	mu   sync.Mutex
+	held map[string]Claim
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newClaimer(store mergeStore, settings CoordinationSettings) *claimer {
+	return &claimer{
+		store:    store,
+		settings: settings,
+		instance: claimInstance(),
+		run:      ciRunURL(),
+		now:      time.Now,
+		held:     make(map[string]Claim),
+	}
+}
+
+// readClaims returns the claims in the store by target
+func readClaims(ctx context.Context, store mergeStore) (map[string]Claim, error) {
+	names, err := store.ListSecrets(ctx, claimsNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claims: %w", err)
+	}
+	claims := make(map[string]Claim, len(names))
+	for _, name := range names {
+		c, err := readClaim(ctx, store, name)
+		if err != nil {
+			return nil, err
+		}
+		claims[name] = c
+	}
+	return claims, nil
+}
+
+func readClaim(ctx context.Context, store mergeStore, target string) (Claim, error) {
+	data, err := store.ReadSecret(ctx, claimsNamespace, target)
+	if err != nil {
+		return Claim{}, fmt.Errorf("failed to read claim on %s: %w", target, err)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return Claim{}, err
+	}
+	var c Claim
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Claim{}, fmt.Errorf("failed to decode claim on %s: %w", target, err)
+	}
+	return c, nil
+}
+
+func writeClaim(ctx context.Context, store mergeStore, c Claim) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	if err := store.WriteSecret(ctx, claimsNamespace, c.Target, data); err != nil {
+		return fmt.Errorf("failed to write claim on %s: %w", c.Target, err)
+	}
+	return nil
+}
+
+// acquire claims each target that no other live claim holds, returning those
+// that another controller holds. Each claim is read back after it is
+// written, so of two controllers claiming a target at once only the last
+// writer keeps it.
+func (c *claimer) acquire(ctx context.Context, targets []string) ([]ClaimConflict, error) {
+	existing, err := readClaims(ctx, c.store)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []ClaimConflict
+	for _, target := range targets {
+		now := c.now()
+		if prev, ok := existing[target]; ok && prev.Instance != c.instance && prev.Live(now) {
+			conflicts = append(conflicts, ClaimConflict{Target: target, Holder: prev})
+			continue
+		}
+		claim := Claim{
+			Target:     target,
+			Controller: c.settings.controller(),
+			Instance:   c.instance,
+			Run:        c.run,
+			Acquired:   now,
+			Heartbeat:  now,
+			TTL:        c.settings.ttl(),
+		}
+		if err := writeClaim(ctx, c.store, claim); err != nil {
+			return conflicts, err
+		}
+		got, err := readClaim(ctx, c.store, target)
+		if err != nil {
+			return conflicts, err
+		}
+		if got.Instance != c.instance {
+			conflicts = append(conflicts, ClaimConflict{Target: target, Holder: got})
+			continue
+		}
+		c.mu.Lock()
+		c.held[target] = claim
+		c.mu.Unlock()
+	}
+	return conflicts, nil
+}
+
+// heartbeat renews the held claims. A claim another controller has taken
+// over in the meantime is a split brain: it is reported and given up.
+func (c *claimer) heartbeat(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for target, claim := range c.held {
+		l := log.WithFields(log.Fields{
+			"action": "claimer.heartbeat",
+			"target": target,
+		})
+		current, err := readClaim(ctx, c.store, target)
+		if err != nil {
+			l.WithError(err).Warn("Failed to renew claim")
+			continue
+		}
+		if current.Instance != c.instance && current.Live(c.now()) {
+			l.WithField("holder", current.holder()).Error("Split brain: another controller claimed a target this run is writing")
+			metrics.RegisterClaimConflict(target)
+			delete(c.held, target)
+			continue
+		}
+		claim.Heartbeat = c.now()
+		if err := writeClaim(ctx, c.store, claim); err != nil {
+			l.WithError(err).Warn("Failed to renew claim")
+			continue
+		}
+		c.held[target] = claim
+	}
+}
+
+// start sends heartbeats until release
+func (c *claimer) start(ctx context.Context) {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.settings.ttl() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.heartbeat(ctx)
+			}
+		}
+	}()
+}
+
+// release stops the heartbeats and marks the held claims released
+func (c *claimer) release(ctx context.Context) {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for target, claim := range c.held {
+		current, err := readClaim(ctx, c.store, target)
+		if err == nil && current.Instance != c.instance {
+			continue
+		}
+		released := c.now()
+		claim.Released = &released
+		if err := writeClaim(ctx, c.store, claim); err != nil {
+			log.WithFields(log.Fields{
+				"action": "claimer.release",
+				"target": target,
+			}).WithError(err).Warn("Failed to release claim; it expires after its TTL")
+		}
+		delete(c.held, target)
+	}
+}
+
+// claimTargets claims the run's targets under the pipeline's coordination
+// settings and starts renewing the claims. Targets another controller holds
+// fail the run in enforce mode; in warn mode they are reported and run
+// unclaimed.
+func (p *Pipeline) claimTargets(ctx context.Context, targets []string) (*claimer, error) {
+	settings := *p.config.Pipeline.Coordination
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := newClaimer(store, settings)
+	conflicts, err := c.acquire(ctx, targets)
+	if err == nil && len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			metrics.RegisterClaimConflict(conflict.Target)
+		}
+		conflictErr := &ClaimConflictError{Conflicts: conflicts}
+		if settings.Mode != CoordinationWarn {
+			err = conflictErr
+		} else {
+			log.WithField("action", "claimTargets").Warn(conflictErr.Error())
+		}
+	}
+	if err != nil {
+		c.release(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	c.start(ctx)
+	return c, nil
+}
+
+// Claims returns the claims recorded in the merge store, sorted by target
+func (p *Pipeline) Claims(ctx context.Context) ([]Claim, error) {
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byTarget, err := readClaims(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	claims := make([]Claim, 0, len(byTarget))
+	for _, c := range byTarget {
+		claims = append(claims, c)
+	}
+	sort.Slice(claims, func(i, j int) bool { return claims[i].Target < claims[j].Target })
+	return claims, nil
+}
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testClaimer(store mergeStore, instance string, now time.Time) *claimer {
+	c := newClaimer(store, CoordinationSettings{Controller: instance})
+	c.instance = instance
+	c.now = func() time.Time { return now }
+	return c
+}
+
+func TestClaimAcquire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := memMergeStore{}
+
+	ci := testClaimer(store, "ci", now)
+	conflicts, err := ci.acquire(ctx, []string{"Stg", "Prod"})
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	claims, err := readClaims(ctx, store)
+	require.NoError(t, err)
+	require.Len(t, claims, 2)
+	assert.Equal(t, "ci", claims["Stg"].Controller)
+	assert.Equal(t, DefaultClaimTTL, claims["Stg"].TTL)
+	assert.True(t, claims["Stg"].Live(now))
+
+	// Another controller cannot claim live targets
+	cluster := testClaimer(store, "cluster", now.Add(time.Minute))
+	conflicts, err = cluster.acquire(ctx, []string{"Prod", "Dev"})
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "Prod", conflicts[0].Target)
+	assert.Equal(t, "ci", conflicts[0].Holder.Instance)
+	assert.Contains(t, cluster.held, "Dev")
+
+	// Claims whose holder stopped heartbeating expire
+	late := testClaimer(store, "cluster", now.Add(DefaultClaimTTL+time.Second))
+	conflicts, err = late.acquire(ctx, []string{"Prod"})
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+
+	// A heartbeat finds the target taken over
+	ci.now = func() time.Time { return now.Add(DefaultClaimTTL + 2*time.Second) }
+	ci.heartbeat(ctx)
+	assert.NotContains(t, ci.held, "Prod")
+	assert.Contains(t, ci.held, "Stg")
+
+	// Released claims free the target for others
+	ci.release(ctx)
+	claims, err = readClaims(ctx, store)
+	require.NoError(t, err)
+	require.NotNil(t, claims["Stg"].Released)
+	assert.Equal(t, "cluster", claims["Prod"].Instance)
+	conflicts, err = testClaimer(store, "cluster", now.Add(DefaultClaimTTL)).acquire(ctx, []string{"Stg"})
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+}
+
+func TestClaimConflictError(t *testing.T) {
+	err := &ClaimConflictError{Conflicts: []ClaimConflict{{
+		Target: "Prod",
+		Holder: Claim{Controller: "cluster", Instance: "vss-0/1", Heartbeat: time.Now().Add(-40 * time.Second), TTL: DefaultClaimTTL},
+	}}}
+	assert.Contains(t, err.Error(), "split brain: 1 targets are claimed by other controllers: Prod is claimed by cluster (vss-0/1), last heartbeat 40s ago")
+}
+
+func TestCoordinationSettingsValidate(t *testing.T) {
+	assert.NoError(t, (&CoordinationSettings{}).validate())
+	assert.NoError(t, (&CoordinationSettings{TTL: time.Minute, Mode: CoordinationWarn}).validate())
+	assert.ErrorContains(t, (&CoordinationSettings{TTL: time.Second}).validate(), "at least 30s")
+	assert.ErrorContains(t, (&CoordinationSettings{Mode: "strict"}).validate(), "mode must be enforce or warn")
+}
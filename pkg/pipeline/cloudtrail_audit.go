@@ -0,0 +1,476 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// secretsManagerMutations are the Secrets Manager calls a CloudTrail audit
+// reports: those that change a secret, its versions, tags or policy
+var secretsManagerMutations = []string{
+	"CreateSecret",
+	"PutSecretValue",
+	"UpdateSecret",
+	"UpdateSecretVersionStage",
+	"DeleteSecret",
+	"RestoreSecret",
+	"TagResource",
+	"UntagResource",
+	"PutResourcePolicy",
+	"DeleteResourcePolicy",
+	"ReplicateSecretToRegions",
+	"RemoveRegionsFromReplication",
+}
+
+const (
+	secretsManagerEventSource = "secretsmanager.amazonaws.com"
+	lakePollInterval          = 2 * time.Second
+)
+
+var (
+	// sessionVar matches the template variables of session tags and source
+	// identities
+	sessionVar = regexp.MustCompile(`\{\{\.\w+\}\}`)
+	// eventDataStoreID matches event data store IDs and ARNs
+	eventDataStoreID = regexp.MustCompile(`^(arn:[\w-]+:cloudtrail:[\w-]+:\d{12}:eventdatastore/)?[\w-]+$`)
+)
+
+// CloudTrailAudit lists the Secrets Manager changes the pipeline's role
+// sessions made in a target's destination accounts, attributed to runs
+type CloudTrailAudit struct {
+	Target    string    `json:"target"`
+	Since     time.Time `json:"since"`
+	Generated time.Time `json:"generated"`
+	// Source is lookup_events or lake
+	Source string            `json:"source"`
+	Events []CloudTrailWrite `json:"events"`
+	Runs   []CloudTrailRun   `json:"runs"`
+	// HistoryChecked is whether the runs were looked up in the run history
+	HistoryChecked bool `json:"history_checked"`
+	// Errors are the destinations whose events could not be read
+	Errors []string `json:"errors,omitempty"`
+}
+
+// CloudTrailWrite is a Secrets Manager change made with a target role session
+type CloudTrailWrite struct {
+	Time        time.Time `json:"time"`
+	EventID     string    `json:"event_id"`
+	EventName   string    `json:"event_name"`
+	Destination string    `json:"destination"`
+	AccountID   string    `json:"account_id"`
+	Region      string    `json:"region"`
+	Secret      string    `json:"secret,omitempty"`
+	// Principal is the assumed role session's ARN
+	Principal      string `json:"principal"`
+	SessionName    string `json:"session_name"`
+	SourceIdentity string `json:"source_identity,omitempty"`
+	// RunID is the run the session was tagged with, "" when the session
+	// carries no run
+	RunID     string `json:"run_id,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// CloudTrailRun is a run the audited changes are attributed to
+type CloudTrailRun struct {
+	ID     string `json:"id"`
+	Events int    `json:"events"`
+	// Recorded is whether the run is in the run history (pipeline.history)
+	Recorded bool `json:"recorded"`
+}
+
+// CloudTrailAuditOptions select what a CloudTrail audit reads
+type CloudTrailAuditOptions struct {
+	Target string
+	Since  time.Time
+	// EventDataStore queries a CloudTrail Lake event data store, by ID or
+	// ARN, with the execution identity instead of calling LookupEvents in
+	// each destination account with the target's role
+	EventDataStore string
+}
+
+// cloudTrailEvent is the part of a CloudTrail event an audit reads
+type cloudTrailEvent struct {
+	EventID            string    `json:"eventID"`
+	EventTime          time.Time `json:"eventTime"`
+	EventSource        string    `json:"eventSource"`
+	EventName          string    `json:"eventName"`
+	AWSRegion          string    `json:"awsRegion"`
+	RecipientAccountID string    `json:"recipientAccountId"`
+	ErrorCode          string    `json:"errorCode"`
+	UserIdentity       struct {
+		ARN            string `json:"arn"`
+		AccessKeyID    string `json:"accessKeyId"`
+		SessionContext struct {
+			SourceIdentity string `json:"sourceIdentity"`
+		} `json:"sessionContext"`
+	} `json:"userIdentity"`
+	RequestParameters struct {
+		SecretID string `json:"secretId"`
+		Name     string `json:"name"`
+		RoleARN  string `json:"roleArn"`
+		Tags     []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"tags"`
+	} `json:"requestParameters"`
+	ResponseElements struct {
+		Credentials struct {
+			AccessKeyID string `json:"accessKeyId"`
+		} `json:"credentials"`
+	} `json:"responseElements"`
+}
+
+// cloudTrailSource reads CloudTrail events for audits
+type cloudTrailSource interface {
+	// LookupEvents returns the events matching attr in a destination
+	// account's region since a time
+	LookupEvents(ctx context.Context, target Target, region string, attr cttypes.LookupAttribute, since time.Time) ([]cloudTrailEvent, error)
+	// QueryLake returns the Secrets Manager changes in accountID since a time
+	// from a CloudTrail Lake event data store
+	QueryLake(ctx context.Context, eventDataStore, accountID string, since time.Time) ([]cloudTrailEvent, error)
+}
+
+// AuditCloudTrail lists the Secrets Manager changes made by the target's role
+// sessions in its Secrets Manager destinations, attributing each to the run
+// whose ID the session was tagged with (assume_role.session_tags) or carries
+// in its source identity (assume_role.source_identity). Destinations whose
+// events cannot be read are recorded in Errors rather than failing the audit.
+func (p *Pipeline) AuditCloudTrail(ctx context.Context, opts CloudTrailAuditOptions) (*CloudTrailAudit, error) {
+	target, ok := p.config.Targets[opts.Target]
+	if !ok {
+		return nil, &OptionError{Option: "target", Value: fmt.Sprintf("%q", opts.Target), Reason: "not found in configuration"}
+	}
+	if opts.EventDataStore != "" && !eventDataStoreID.MatchString(opts.EventDataStore) {
+		return nil, &OptionError{Option: "event data store", Value: fmt.Sprintf("%q", opts.EventDataStore), Reason: "not an event data store ID or ARN"}
+	}
+	if p.cloudTrail == nil {
+		p.cloudTrail = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+
+	audit := &CloudTrailAudit{
+		Target:    opts.Target,
+		Since:     opts.Since.UTC(),
+		Generated: time.Now().UTC(),
+		Source:    "lookup_events",
+		Events:    []CloudTrailWrite{},
+		Runs:      []CloudTrailRun{},
+	}
+	if opts.EventDataStore != "" {
+		audit.Source = "lake"
+	}
+
+	assumeRole := p.config.assumeRole(target)
+	var (
+		tagPatterns     []*regexp.Regexp
+		identityPattern *regexp.Regexp
+	)
+	if assumeRole != nil {
+		for _, v := range assumeRole.SessionTags {
+			if re := runIDPattern(v, sessionTagChars); re != nil {
+				tagPatterns = append(tagPatterns, re)
+			}
+		}
+		identityPattern = runIDPattern(assumeRole.SourceIdentity, sessionNameChars)
+	}
+
+	for _, d := range target.ResolvedDestinations() {
+		if !d.isAWS() || d.AccountID == "" {
+			continue
+		}
+		dt := destinationTarget(target, d)
+		region := p.config.destinationRegion(target, d)
+		roleARN := dt.RoleARN
+		if roleARN == "" {
+			roleARN = p.config.GetRoleARN(d.AccountID)
+		}
+		l := log.WithFields(log.Fields{
+			"action":      "Pipeline.AuditCloudTrail",
+			"target":      opts.Target,
+			"destination": d.Label(),
+			"accountID":   d.AccountID,
+		})
+
+		events, err := p.readCloudTrail(ctx, opts, dt, region)
+		if err != nil {
+			l.WithError(err).Error("Failed to read CloudTrail events")
+			audit.Errors = append(audit.Errors, fmt.Sprintf("%s: %s", d.Label(), err))
+			continue
+		}
+		for _, w := range attributeCloudTrailWrites(events, roleARN, tagPatterns, identityPattern) {
+			w.Destination = d.Label()
+			audit.Events = append(audit.Events, w)
+		}
+	}
+	sort.SliceStable(audit.Events, func(i, j int) bool { return audit.Events[i].Time.Before(audit.Events[j].Time) })
+
+	var recorded map[string]bool
+	if h := p.config.Pipeline.History; h != nil && h.Dir != "" {
+		runs, err := LoadRunHistory(h.Dir)
+		if err != nil {
+			log.WithError(err).Warn("Failed to load run history, runs are not checked against it")
+		} else {
+			recorded = make(map[string]bool, len(runs))
+			audit.HistoryChecked = true
+			for _, r := range runs {
+				recorded[r.ID] = true
+			}
+		}
+	}
+	runs := make(map[string]*CloudTrailRun)
+	for _, w := range audit.Events {
+		if w.RunID == "" {
+			continue
+		}
+		if runs[w.RunID] == nil {
+			runs[w.RunID] = &CloudTrailRun{ID: w.RunID, Recorded: recorded[w.RunID]}
+		}
+		runs[w.RunID].Events++
+	}
+	for _, r := range runs {
+		audit.Runs = append(audit.Runs, *r)
+	}
+	sort.Slice(audit.Runs, func(i, j int) bool { return audit.Runs[i].ID < audit.Runs[j].ID })
+	return audit, nil
+}
+
+// readCloudTrail returns a destination account's Secrets Manager events and,
+// from LookupEvents, the AssumeRole events of the sessions that made them.
+// Sessions can start up to the longest session duration before since.
+func (p *Pipeline) readCloudTrail(ctx context.Context, opts CloudTrailAuditOptions, t Target, region string) ([]cloudTrailEvent, error) {
+	if opts.EventDataStore != "" {
+		return p.cloudTrail.QueryLake(ctx, opts.EventDataStore, t.AccountID, opts.Since)
+	}
+	events, err := p.cloudTrail.LookupEvents(ctx, t, region, cttypes.LookupAttribute{
+		AttributeKey:   cttypes.LookupAttributeKeyEventSource,
+		AttributeValue: aws.String(secretsManagerEventSource),
+	}, opts.Since)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := p.cloudTrail.LookupEvents(ctx, t, region, cttypes.LookupAttribute{
+		AttributeKey:   cttypes.LookupAttributeKeyEventName,
+		AttributeValue: aws.String("AssumeRole"),
+	}, opts.Since.Add(-maxSessionDuration))
+	if err != nil {
+		return nil, err
+	}
+	return append(events, sessions...), nil
+}
+
+// attributeCloudTrailWrites returns the Secrets Manager changes made with
+// sessions of roleARN. Each is attributed to the run ID in the session's tags,
+// from the session's AssumeRole event, or else in its source identity.
+func attributeCloudTrailWrites(events []cloudTrailEvent, roleARN string, tagPatterns []*regexp.Regexp, identityPattern *regexp.Regexp) []CloudTrailWrite {
+	roleName := roleARN[strings.LastIndex(roleARN, "/")+1:]
+
+	// Sessions by the access key AssumeRole issued them
+	sessionRuns := make(map[string]string)
+	for _, e := range events {
+		key := e.ResponseElements.Credentials.AccessKeyID
+		if e.EventName != "AssumeRole" || key == "" || !strings.EqualFold(e.RequestParameters.RoleARN, roleARN) {
+			continue
+		}
+		for _, tag := range e.RequestParameters.Tags {
+			if id := matchRunID(tagPatterns, tag.Value); id != "" {
+				sessionRuns[key] = id
+				break
+			}
+		}
+	}
+
+	mutation := make(map[string]bool, len(secretsManagerMutations))
+	for _, name := range secretsManagerMutations {
+		mutation[name] = true
+	}
+	var writes []CloudTrailWrite
+	for _, e := range events {
+		if e.EventSource != secretsManagerEventSource || !mutation[e.EventName] {
+			continue
+		}
+		// arn:<partition>:sts::<account>:assumed-role/<role>/<session>
+		parts := strings.SplitN(e.UserIdentity.ARN, ":assumed-role/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		role, session, _ := strings.Cut(parts[1], "/")
+		if role != roleName {
+			continue
+		}
+		w := CloudTrailWrite{
+			Time:           e.EventTime,
+			EventID:        e.EventID,
+			EventName:      e.EventName,
+			AccountID:      e.RecipientAccountID,
+			Region:         e.AWSRegion,
+			Secret:         e.RequestParameters.SecretID,
+			Principal:      e.UserIdentity.ARN,
+			SessionName:    session,
+			SourceIdentity: e.UserIdentity.SessionContext.SourceIdentity,
+			RunID:          sessionRuns[e.UserIdentity.AccessKeyID],
+			ErrorCode:      e.ErrorCode,
+		}
+		if w.Secret == "" {
+			w.Secret = e.RequestParameters.Name
+		}
+		if w.RunID == "" && identityPattern != nil {
+			w.RunID = matchRunID([]*regexp.Regexp{identityPattern}, w.SourceIdentity)
+		}
+		writes = append(writes, w)
+	}
+	return writes
+}
+
+// runIDPattern returns a pattern matching what a session tag or source
+// identity template renders to, capturing the run ID, or nil when the
+// template does not contain {{.RunID}}. disallowed are the characters
+// rendering replaces.
+func runIDPattern(template string, disallowed *regexp.Regexp) *regexp.Regexp {
+	before, after, ok := strings.Cut(template, "{{.RunID}}")
+	if !ok {
+		return nil
+	}
+	literal := func(s string) string {
+		parts := sessionVar.Split(s, -1)
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(disallowed.ReplaceAllString(part, "-"))
+		}
+		return strings.Join(parts, ".*")
+	}
+	// Rendered values may be truncated, so the end is not anchored
+	return regexp.MustCompile("^" + literal(before) + `(\d{8}T\d{6}\.\d{9}Z)` + literal(after))
+}
+
+// matchRunID returns the run ID the first matching pattern captures in s
+func matchRunID(patterns []*regexp.Regexp, s string) string {
+	for _, re := range patterns {
+		if m := re.FindStringSubmatch(s); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func (r *liveReadinessProbe) LookupEvents(ctx context.Context, target Target, region string, attr cttypes.LookupAttribute, since time.Time) ([]cloudTrailEvent, error) {
+	cfg, err := r.awsConfig(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Region = region
+	var events []cloudTrailEvent
+	paginator := cloudtrail.NewLookupEventsPaginator(cloudtrail.NewFromConfig(cfg), &cloudtrail.LookupEventsInput{
+		LookupAttributes: []cttypes.LookupAttribute{attr},
+		StartTime:        aws.Time(since),
+		EndTime:          aws.Time(time.Now()),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up CloudTrail events: %w", err)
+		}
+		for _, raw := range page.Events {
+			var e cloudTrailEvent
+			if err := json.Unmarshal([]byte(aws.ToString(raw.CloudTrailEvent)), &e); err != nil {
+				return nil, fmt.Errorf("failed to decode CloudTrail event %s: %w", aws.ToString(raw.EventId), err)
+			}
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// lakeTimeLayout is how CloudTrail Lake returns eventTime
+const lakeTimeLayout = "2006-01-02 15:04:05.000"
+
+func (r *liveReadinessProbe) QueryLake(ctx context.Context, eventDataStore, accountID string, since time.Time) ([]cloudTrailEvent, error) {
+	cfg, err := r.awsConfig(ctx, Target{})
+	if err != nil {
+		return nil, err
+	}
+	client := cloudtrail.NewFromConfig(cfg)
+
+	// The table is the event data store's ID; the values are parameters
+	table := eventDataStore[strings.LastIndex(eventDataStore, "/")+1:]
+	mutations := make([]string, len(secretsManagerMutations))
+	params := []string{secretsManagerEventSource, accountID, since.UTC().Format(lakeTimeLayout)}
+	for i, name := range secretsManagerMutations {
+		mutations[i] = "?"
+		params = append(params, name)
+	}
+	query := fmt.Sprintf(`SELECT eventID AS event_id, eventTime AS event_time, eventName AS event_name,
+  awsRegion AS region, recipientAccountId AS account_id, errorCode AS error_code,
+  userIdentity.arn AS principal, userIdentity.sessionContext.sourceIdentity AS source_identity,
+  element_at(requestParameters, 'secretId') AS secret_id, element_at(requestParameters, 'name') AS name
+FROM %s
+WHERE eventSource = ? AND recipientAccountId = ? AND eventTime >= ? AND eventName IN (%s)`, table, strings.Join(mutations, ", "))
+
+	started, err := client.StartQuery(ctx, &cloudtrail.StartQueryInput{
+		QueryStatement:  aws.String(query),
+		QueryParameters: params,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start CloudTrail Lake query: %w", err)
+	}
+
+	var events []cloudTrailEvent
+	input := &cloudtrail.GetQueryResultsInput{QueryId: started.QueryId}
+	for {
+		output, err := client.GetQueryResults(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CloudTrail Lake query results: %w", err)
+		}
+		switch output.QueryStatus {
+		case cttypes.QueryStatusQueued, cttypes.QueryStatusRunning:
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(lakePollInterval):
+			}
+			continue
+		case cttypes.QueryStatusFinished:
+		default:
+			return nil, fmt.Errorf("CloudTrail Lake query %s: %s", output.QueryStatus, aws.ToString(output.ErrorMessage))
+		}
+		for _, row := range output.QueryResultRows {
+			events = append(events, lakeEvent(row))
+		}
+		if output.NextToken == nil {
+			return events, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// lakeEvent converts a CloudTrail Lake result row, a list of single-column
+// maps, to an event
+func lakeEvent(row []map[string]string) cloudTrailEvent {
+	cols := make(map[string]string)
+	for _, col := range row {
+		for k, v := range col {
+			cols[k] = v
+		}
+	}
+	e := cloudTrailEvent{
+		EventID:            cols["event_id"],
+		EventSource:        secretsManagerEventSource,
+		EventName:          cols["event_name"],
+		AWSRegion:          cols["region"],
+		RecipientAccountID: cols["account_id"],
+		ErrorCode:          cols["error_code"],
+	}
+	e.EventTime, _ = time.Parse(lakeTimeLayout, cols["event_time"])
+	e.UserIdentity.ARN = cols["principal"]
+	e.UserIdentity.SessionContext.SourceIdentity = cols["source_identity"]
+	e.RequestParameters.SecretID = cols["secret_id"]
+	e.RequestParameters.Name = cols["name"]
+	return e
+}
@@ -0,0 +1,218 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCloudTrail serves raw CloudTrail events by account, event source and
+// event name
+type fakeCloudTrail struct {
+	events map[string][]string
+	err    map[string]error
+	lake   []cloudTrailEvent
+	since  []time.Time
+}
+
+func (f *fakeCloudTrail) LookupEvents(_ context.Context, target Target, region string, attr cttypes.LookupAttribute, since time.Time) ([]cloudTrailEvent, error) {
+	if err := f.err[target.AccountID]; err != nil {
+		return nil, err
+	}
+	f.since = append(f.since, since)
+	var events []cloudTrailEvent
+	for _, raw := range f.events[target.AccountID] {
+		var e cloudTrailEvent
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			return nil, err
+		}
+		if e.AWSRegion != region {
+			continue
+		}
+		if (attr.AttributeKey == cttypes.LookupAttributeKeyEventSource && e.EventSource == aws.ToString(attr.AttributeValue)) ||
+			(attr.AttributeKey == cttypes.LookupAttributeKeyEventName && e.EventName == aws.ToString(attr.AttributeValue)) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (f *fakeCloudTrail) QueryLake(_ context.Context, eventDataStore, accountID string, since time.Time) ([]cloudTrailEvent, error) {
+	var events []cloudTrailEvent
+	for _, e := range f.lake {
+		if e.RecipientAccountID == accountID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+const (
+	auditAssumeRole = `{
+  "eventID": "a1", "eventTime": "2026-03-01T11:59:58Z", "eventSource": "sts.amazonaws.com", "eventName": "AssumeRole",
+  "awsRegion": "us-east-1", "recipientAccountId": "111111111111",
+  "requestParameters": {
+    "roleArn": "arn:aws:iam::111111111111:role/AWSControlTowerExecution", "roleSessionName": "vss-Analytics_Prod",
+    "tags": [{"key": "vss-pipeline", "value": "prod"}, {"key": "vss-run-id", "value": "run-20260301T115957.000000000Z"}]
+  },
+  "responseElements": {"credentials": {"accessKeyId": "ASIARUN1"}}
+}`
+	auditPutSecretValue = `{
+  "eventID": "e1", "eventTime": "2026-03-01T12:00:00Z", "eventSource": "secretsmanager.amazonaws.com", "eventName": "PutSecretValue",
+  "awsRegion": "us-east-1", "recipientAccountId": "111111111111",
+  "userIdentity": {"arn": "arn:aws:sts::111111111111:assumed-role/AWSControlTowerExecution/vss-Analytics_Prod", "accessKeyId": "ASIARUN1"},
+  "requestParameters": {"secretId": "analytics/db"}
+}`
+	auditCreateSecret = `{
+  "eventID": "e2", "eventTime": "2026-03-01T12:00:01Z", "eventSource": "secretsmanager.amazonaws.com", "eventName": "CreateSecret",
+  "awsRegion": "us-east-1", "recipientAccountId": "111111111111", "errorCode": "AccessDenied",
+  "userIdentity": {
+    "arn": "arn:aws:sts::111111111111:assumed-role/AWSControlTowerExecution/vault-secret-sync", "accessKeyId": "ASIAOTHER",
+    "sessionContext": {"sourceIdentity": "vss-20260302T080000.000000000Z"}
+  },
+  "requestParameters": {"name": "analytics/api"}
+}`
+	auditManualWrite = `{
+  "eventID": "e3", "eventTime": "2026-03-01T13:00:00Z", "eventSource": "secretsmanager.amazonaws.com", "eventName": "UpdateSecret",
+  "awsRegion": "us-east-1", "recipientAccountId": "111111111111",
+  "userIdentity": {"arn": "arn:aws:sts::111111111111:assumed-role/AWSControlTowerExecution/alice", "accessKeyId": "ASIAALICE"},
+  "requestParameters": {"secretId": "analytics/db"}
+}`
+	auditOtherRole = `{
+  "eventID": "e4", "eventTime": "2026-03-01T12:30:00Z", "eventSource": "secretsmanager.amazonaws.com", "eventName": "PutSecretValue",
+  "awsRegion": "us-east-1", "recipientAccountId": "111111111111",
+  "userIdentity": {"arn": "arn:aws:sts::111111111111:assumed-role/rotation-lambda/rotate", "accessKeyId": "ASIAROT"},
+  "requestParameters": {"secretId": "analytics/db"}
+}`
+	auditRead = `{
+  "eventID": "e5", "eventTime": "2026-03-01T12:00:02Z", "eventSource": "secretsmanager.amazonaws.com", "eventName": "GetSecretValue",
+  "awsRegion": "us-east-1", "recipientAccountId": "111111111111",
+  "userIdentity": {"arn": "arn:aws:sts::111111111111:assumed-role/AWSControlTowerExecution/vss-Analytics_Prod", "accessKeyId": "ASIARUN1"},
+  "requestParameters": {"secretId": "analytics/db"}
+}`
+)
+
+func cloudTrailAuditPipeline(t *testing.T) (*Pipeline, *fakeCloudTrail) {
+	historyDir := t.TempDir()
+	require.NoError(t, WriteRunRecord(historyDir, 0, RunRecord{ID: "20260301T115957.000000000Z"}))
+	ct := &fakeCloudTrail{
+		events: map[string][]string{
+			"111111111111": {auditManualWrite, auditCreateSecret, auditRead, auditPutSecretValue, auditOtherRole, auditAssumeRole},
+		},
+		err: map[string]error{"222222222222": errors.New("AccessDenied: cloudtrail:LookupEvents")},
+	}
+	cfg := &Config{
+		AWS: AWSConfig{
+			Region:       "us-east-1",
+			ControlTower: ControlTowerConfig{Enabled: true},
+			ExecutionContext: ExecutionContextConfig{AssumeRole: &AssumeRoleOptions{
+				SessionTags:    map[string]string{"vss-run-id": "run-{{.RunID}}", "vss-pipeline": "{{.Pipeline}}"},
+				SourceIdentity: "vss-{{.RunID}}",
+			}},
+		},
+		Targets: map[string]Target{
+			"Analytics_Prod": {
+				AccountID: "111111111111",
+				Destinations: []Destination{
+					{AccountID: "111111111111"},
+					{AccountID: "222222222222", Region: "eu-west-1"},
+					{GitHub: &GitHubDestination{Owner: "acme", Repo: "api"}},
+				},
+			},
+		},
+		Pipeline: PipelineSettings{History: &HistorySettings{Dir: historyDir}},
+	}
+	return &Pipeline{config: cfg, cloudTrail: ct}, ct
+}
+
+func TestAuditCloudTrail(t *testing.T) {
+	p, ct := cloudTrailAuditPipeline(t)
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	audit, err := p.AuditCloudTrail(context.Background(), CloudTrailAuditOptions{Target: "Analytics_Prod", Since: since})
+	require.NoError(t, err)
+	assert.Equal(t, "lookup_events", audit.Source)
+	assert.Equal(t, []time.Time{since, since.Add(-maxSessionDuration)}, ct.since, "sessions may start before the audited window")
+
+	var got []CloudTrailWrite
+	for _, e := range audit.Events {
+		e.Time = e.Time.UTC()
+		got = append(got, e)
+	}
+	assert.Equal(t, []CloudTrailWrite{
+		{
+			Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), EventID: "e1", EventName: "PutSecretValue",
+			Destination: "aws:111111111111", AccountID: "111111111111", Region: "us-east-1", Secret: "analytics/db",
+			Principal:   "arn:aws:sts::111111111111:assumed-role/AWSControlTowerExecution/vss-Analytics_Prod",
+			SessionName: "vss-Analytics_Prod", RunID: "20260301T115957.000000000Z",
+		},
+		{
+			Time: time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC), EventID: "e2", EventName: "CreateSecret",
+			Destination: "aws:111111111111", AccountID: "111111111111", Region: "us-east-1", Secret: "analytics/api",
+			Principal:   "arn:aws:sts::111111111111:assumed-role/AWSControlTowerExecution/vault-secret-sync",
+			SessionName: "vault-secret-sync", SourceIdentity: "vss-20260302T080000.000000000Z",
+			RunID: "20260302T080000.000000000Z", ErrorCode: "AccessDenied",
+		},
+		{
+			Time: time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC), EventID: "e3", EventName: "UpdateSecret",
+			Destination: "aws:111111111111", AccountID: "111111111111", Region: "us-east-1", Secret: "analytics/db",
+			Principal:   "arn:aws:sts::111111111111:assumed-role/AWSControlTowerExecution/alice",
+			SessionName: "alice",
+		},
+	}, got, "reads and other roles' changes are left out")
+
+	assert.True(t, audit.HistoryChecked)
+	assert.Equal(t, []CloudTrailRun{
+		{ID: "20260301T115957.000000000Z", Events: 1, Recorded: true},
+		{ID: "20260302T080000.000000000Z", Events: 1},
+	}, audit.Runs)
+	require.Len(t, audit.Errors, 1)
+	assert.Contains(t, audit.Errors[0], "aws:222222222222/eu-west-1: AccessDenied")
+}
+
+func TestAuditCloudTrailLake(t *testing.T) {
+	p, ct := cloudTrailAuditPipeline(t)
+	ct.lake = []cloudTrailEvent{lakeEvent([]map[string]string{
+		{"event_id": "e1"},
+		{"event_time": "2026-03-01 12:00:00.000"},
+		{"event_name": "PutSecretValue"},
+		{"region": "eu-west-1"},
+		{"account_id": "222222222222"},
+		{"principal": "arn:aws:sts::222222222222:assumed-role/AWSControlTowerExecution/vss-Analytics_Prod"},
+		{"source_identity": "vss-20260301T115957.000000000Z"},
+		{"secret_id": "analytics/db"},
+	})}
+
+	audit, err := p.AuditCloudTrail(context.Background(), CloudTrailAuditOptions{
+		Target:         "Analytics_Prod",
+		EventDataStore: "arn:aws:cloudtrail:us-east-1:999999999999:eventdatastore/EXAMPLE-f852-4e8f",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "lake", audit.Source)
+	assert.Empty(t, audit.Errors)
+	require.Len(t, audit.Events, 1)
+	assert.Equal(t, "aws:222222222222/eu-west-1", audit.Events[0].Destination)
+	assert.Equal(t, "20260301T115957.000000000Z", audit.Events[0].RunID)
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), audit.Events[0].Time)
+
+	_, err = p.AuditCloudTrail(context.Background(), CloudTrailAuditOptions{Target: "Analytics_Prod", EventDataStore: "eds; DROP"})
+	assert.ErrorContains(t, err, "not an event data store ID or ARN")
+	_, err = p.AuditCloudTrail(context.Background(), CloudTrailAuditOptions{Target: "Missing"})
+	assert.ErrorContains(t, err, "not found in configuration")
+}
+
+func TestRunIDPattern(t *testing.T) {
+	re := runIDPattern("{{.Pipeline}}/{{.RunID}} (vss)", sessionTagChars)
+	require.NotNil(t, re)
+	assert.Equal(t, "20260301T120000.000000000Z", matchRunID([]*regexp.Regexp{re}, "prod/20260301T120000.000000000Z -vss-"))
+	assert.Empty(t, matchRunID([]*regexp.Regexp{re}, "prod/manual"))
+	assert.Nil(t, runIDPattern("{{.Pipeline}}", sessionTagChars))
+}
@@ -3,13 +3,15 @@
 package pipeline
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
 
+	"github.com/jbcom/secretsync/pkg/redact"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,17 +20,55 @@ type Config struct {
 	Log        LogConfig        `mapstructure:"log" yaml:"log"`
 	Vault      VaultConfig      `mapstructure:"vault" yaml:"vault"`
 	AWS        AWSConfig        `mapstructure:"aws" yaml:"aws"`
+	GitHub     *GitHubConfig    `mapstructure:"github" yaml:"github,omitempty"`
 	Sources    map[string]Source `mapstructure:"sources" yaml:"sources"`
 	MergeStore MergeStoreConfig `mapstructure:"merge_store" yaml:"merge_store"`
 	Targets    map[string]Target `mapstructure:"targets" yaml:"targets"`
+	TargetTemplates map[string]TargetTemplate `mapstructure:"target_templates" yaml:"target_templates,omitempty"`
 	DynamicTargets map[string]DynamicTarget `mapstructure:"dynamic_targets" yaml:"dynamic_targets"`
 	Pipeline   PipelineSettings `mapstructure:"pipeline" yaml:"pipeline"`
+
+	// MinVSSVersion is the oldest vss release that may load this config, e.g.
+	// v1.5.0 for a config that uses features added in it
+	MinVSSVersion string `mapstructure:"min_vss_version" yaml:"min_vss_version,omitempty"`
+
+	// literalSecrets are credentials found in the file before ${VAR} expansion
+	literalSecrets []LiteralSecret
+	// discoveredFrom maps account targets found by discovery to the dynamic
+	// target that found them
+	discoveredFrom map[string]string
 }
 
 // LogConfig controls logging behavior
 type LogConfig struct {
 	Level  string `mapstructure:"level" yaml:"level"`
 	Format string `mapstructure:"format" yaml:"format"`
+
+	// Redact masks secret values in logs and diffs
+	Redact RedactConfig `mapstructure:"redact" yaml:"redact,omitempty"`
+}
+
+// RedactConfig controls how secret values are kept out of logs and diffs
+type RedactConfig struct {
+	// Disabled logs field values as they are. Only for debugging against
+	// non-production secrets.
+	Disabled bool `mapstructure:"disabled" yaml:"disabled,omitempty"`
+
+	// Fields are regular expressions matched against whole log field names,
+	// case-insensitively, redacted on top of the built-in ones
+	// (redact.DefaultFields)
+	Fields []string `mapstructure:"fields" yaml:"fields,omitempty"`
+
+	// DiffPreviews adds masked previews of changed values to diffs, their
+	// first and last two characters, so operators can tell what changed
+	// without seeing the values
+	DiffPreviews bool `mapstructure:"diff_previews" yaml:"diff_previews,omitempty"`
+}
+
+// validate checks that the field patterns compile
+func (r RedactConfig) validate() error {
+	_, err := redact.Fields(r.Fields)
+	return err
 }
 
 // VaultConfig configures Vault connection and authentication
@@ -43,6 +83,8 @@ type VaultAuthConfig struct {
 	AppRole    *AppRoleAuth    `mapstructure:"approle" yaml:"approle"`
 	Token      *TokenAuth      `mapstructure:"token" yaml:"token"`
 	Kubernetes *KubernetesAuth `mapstructure:"kubernetes" yaml:"kubernetes"`
+	AWS        *VaultAWSAuth   `mapstructure:"aws" yaml:"aws"`
+	JWT        *JWTAuth        `mapstructure:"jwt" yaml:"jwt"`
 }
 
 // AppRoleAuth configures AppRole authentication
@@ -63,6 +105,29 @@ type KubernetesAuth struct {
 	MountPath string `mapstructure:"mount_path" yaml:"mount_path"`
 }
 
+// VaultAWSAuth configures AWS IAM authentication with the pipeline's AWS
+// credentials
+type VaultAWSAuth struct {
+	// Role is the Vault role; Vault defaults it to the IAM principal's name
+	Role  string `mapstructure:"role" yaml:"role"`
+	Mount string `mapstructure:"mount" yaml:"mount"`
+	// Region selects a regional STS endpoint, which Vault must be configured
+	// to accept (default: the global endpoint)
+	Region string `mapstructure:"region" yaml:"region"`
+	// ServerIDHeader is the auth method's iam_server_id_header_value
+	ServerIDHeader string `mapstructure:"server_id_header" yaml:"server_id_header"`
+}
+
+// JWTAuth configures JWT/OIDC authentication with a workload identity token
+type JWTAuth struct {
+	Role  string `mapstructure:"role" yaml:"role"`
+	Mount string `mapstructure:"mount" yaml:"mount"`
+	// TokenFile holds the token (default: $AWS_WEB_IDENTITY_TOKEN_FILE, or
+	// a GitHub Actions OIDC token requested for Audience)
+	TokenFile string `mapstructure:"token_file" yaml:"token_file"`
+	Audience  string `mapstructure:"audience" yaml:"audience"`
+}
+
 // AWSConfig configures AWS with Control Tower / Organizations awareness
 type AWSConfig struct {
 	Region           string                  `mapstructure:"region" yaml:"region"`
@@ -70,6 +135,27 @@ type AWSConfig struct {
 	ControlTower     ControlTowerConfig      `mapstructure:"control_tower" yaml:"control_tower"`
 	Organizations    OrganizationsConfig     `mapstructure:"organizations" yaml:"organizations"`
 	IdentityCenter   IdentityCenterConfig    `mapstructure:"identity_center" yaml:"identity_center"`
+
+	// RateLimits caps AWS API calls per service
+	RateLimits *RateLimitConfig `mapstructure:"rate_limits" yaml:"rate_limits,omitempty"`
+
+	// DiscoveryCache reuses Organizations and Identity Center discovery results
+	DiscoveryCache *DiscoveryCacheSettings `mapstructure:"discovery_cache" yaml:"discovery_cache,omitempty"`
+
+	// DiscoveryEvents updates discovered targets from Organizations events in `vss serve`
+	DiscoveryEvents *DiscoveryEventsSettings `mapstructure:"discovery_events" yaml:"discovery_events,omitempty"`
+
+	// session is the run that roles are currently assumed for
+	session roleSession
+}
+
+// GitHubConfig configures GitHub App credentials used for GitHub discovery and
+// GitHub destinations
+type GitHubConfig struct {
+	AppID          int    `mapstructure:"app_id" yaml:"app_id"`
+	InstallID      int    `mapstructure:"install_id" yaml:"install_id"`
+	PrivateKeyPath string `mapstructure:"private_key_path" yaml:"private_key_path"`
+	PrivateKey     string `mapstructure:"private_key" yaml:"private_key"` // Supports ${VAR}
 }
 
 // ExecutionContextType defines where the pipeline runs from
@@ -90,6 +176,23 @@ type ExecutionContextConfig struct {
 	AccountID         string               `mapstructure:"account_id" yaml:"account_id"`
 	Delegation        *DelegationConfig    `mapstructure:"delegation" yaml:"delegation"`
 	CustomRolePattern string               `mapstructure:"custom_role_pattern" yaml:"custom_role_pattern"`
+
+	// RoleChain is assumed, in order, before each target account's role, for
+	// organizations where target roles only trust a hub account role
+	RoleChain []RoleChainHop `mapstructure:"role_chain" yaml:"role_chain,omitempty"`
+
+	// AssumeRole configures the sessions of the target account roles
+	AssumeRole *AssumeRoleOptions `mapstructure:"assume_role" yaml:"assume_role,omitempty"`
+}
+
+// RoleChainHop is a role assumed on the way to a target account's role
+type RoleChainHop struct {
+	RoleARN    string `mapstructure:"role_arn" yaml:"role_arn"`
+	ExternalID string `mapstructure:"external_id" yaml:"external_id,omitempty"`
+	// SessionTags are passed as transitive session tags, so they stay on the
+	// sessions of the roles assumed after this one. The role's trust policy
+	// must allow sts:TagSession.
+	SessionTags map[string]string `mapstructure:"session_tags" yaml:"session_tags,omitempty"`
 }
 
 // DelegationConfig defines delegated administrator settings
@@ -110,11 +213,22 @@ type ExecutionRoleConfig struct {
 	Path string `mapstructure:"path" yaml:"path"`
 }
 
-// AccountFactoryConfig configures Account Factory integration
+// AccountFactoryConfig configures Account Factory integration. With
+// on_account_creation, `vss serve --discover` adds each account Control Tower
+// vends to its dynamic target and syncs it at once; with aft_integration it
+// does so once Account Factory for Terraform has customized the account.
 type AccountFactoryConfig struct {
 	Enabled           bool `mapstructure:"enabled" yaml:"enabled"`
 	OnAccountCreation bool `mapstructure:"on_account_creation" yaml:"on_account_creation"`
 	AFTIntegration    bool `mapstructure:"aft_integration" yaml:"aft_integration"`
+
+	// QueueURL is the SQS queue the lifecycle events are delivered to
+	QueueURL string `mapstructure:"queue_url" yaml:"queue_url,omitempty"`
+	// Region is the queue's region (default: aws.region)
+	Region string `mapstructure:"region" yaml:"region,omitempty"`
+	// DynamicTarget is the profile vended accounts are added to, unless their
+	// AFT account customizations name another dynamic target
+	DynamicTarget string `mapstructure:"dynamic_target" yaml:"dynamic_target,omitempty"`
 }
 
 // OrganizationsConfig configures AWS Organizations integration
@@ -129,6 +243,9 @@ type OUConfig struct {
 	ID       string            `mapstructure:"id" yaml:"id"`
 	Accounts []string          `mapstructure:"accounts" yaml:"accounts"`
 	Children map[string]OUConfig `mapstructure:"children" yaml:"children"`
+
+	// Defaults are inherited by targets in this OU and its children
+	Defaults OUDefaults `mapstructure:"defaults" yaml:"defaults,omitempty"`
 }
 
 // IdentityCenterConfig configures AWS Identity Center (SSO) integration
@@ -141,16 +258,38 @@ type IdentityCenterConfig struct {
 
 // Source defines where secrets can be imported from
 type Source struct {
-	Vault *VaultSource `mapstructure:"vault" yaml:"vault"`
-	AWS   *AWSSource   `mapstructure:"aws" yaml:"aws"`
+	Vault   *VaultSource   `mapstructure:"vault" yaml:"vault"`
+	AWS     *AWSSource     `mapstructure:"aws" yaml:"aws"`
+	Doppler *DopplerSource `mapstructure:"doppler" yaml:"doppler,omitempty"`
+
+	// Owners are accountable for the source (emails, Slack handles or team names)
+	Owners []string `mapstructure:"owners" yaml:"owners,omitempty"`
+
+	// Transforms reshape the source's keys as it is merged into a target
+	Transforms *KeyTransforms `mapstructure:"transforms" yaml:"transforms,omitempty"`
+
+	// Tags are put on the Secrets Manager secrets of every target that
+	// imports the source
+	Tags map[string]string `mapstructure:"tags" yaml:"tags,omitempty"`
 }
 
 // VaultSource imports secrets from a Vault KV2 mount
 type VaultSource struct {
-	Address   string   `mapstructure:"address" yaml:"address"`
-	Namespace string   `mapstructure:"namespace" yaml:"namespace"`
-	Mount     string   `mapstructure:"mount" yaml:"mount"`
-	Paths     []string `mapstructure:"paths" yaml:"paths"`
+	Address   string `mapstructure:"address" yaml:"address"`
+	Namespace string `mapstructure:"namespace" yaml:"namespace"`
+	Mount     string `mapstructure:"mount" yaml:"mount"`
+	// Paths are secrets, or directories read recursively when they end in
+	// "/", within the mount (default: the whole mount)
+	Paths []string `mapstructure:"paths" yaml:"paths"`
+	// Include keeps only secrets whose path within the mount matches one of
+	// these globs ("*" within a path segment, "**" across segments) or
+	// "regex:"-prefixed regular expressions
+	Include []string `mapstructure:"include" yaml:"include,omitempty"`
+	// Exclude drops secrets matching any of these patterns
+	Exclude []string `mapstructure:"exclude" yaml:"exclude,omitempty"`
+	// PageSize lists each directory this many keys at a time (default: all
+	// keys in one request)
+	PageSize int `mapstructure:"page_size" yaml:"page_size,omitempty"`
 }
 
 // AWSSource imports secrets from AWS Secrets Manager
@@ -161,10 +300,20 @@ type AWSSource struct {
 	Tags      map[string]string `mapstructure:"tags" yaml:"tags"`
 }
 
+// DopplerSource imports all secrets from a Doppler project config.
+// The config's secrets are written to the merge store as a single secret.
+type DopplerSource struct {
+	Project    string `mapstructure:"project" yaml:"project"`
+	Config     string `mapstructure:"config" yaml:"config"`
+	Token      string `mapstructure:"token" yaml:"token"`             // Supports ${VAR}
+	SecretName string `mapstructure:"secret_name" yaml:"secret_name"` // Defaults to the project name
+}
+
 // MergeStoreConfig defines intermediate storage for merged secrets
 type MergeStoreConfig struct {
 	Vault *MergeStoreVault `mapstructure:"vault" yaml:"vault"`
 	S3    *MergeStoreS3    `mapstructure:"s3" yaml:"s3"`
+	GCP   *MergeStoreGCP   `mapstructure:"gcp" yaml:"gcp,omitempty"`
 }
 
 // MergeStoreVault uses Vault as the merge store
@@ -177,6 +326,18 @@ type MergeStoreS3 struct {
 	Bucket    string `mapstructure:"bucket" yaml:"bucket"`
 	Prefix    string `mapstructure:"prefix" yaml:"prefix"`
 	KMSKeyID  string `mapstructure:"kms_key_id" yaml:"kms_key_id"`
+	// EnvelopeKeyID is the KMS key that data keys for envelope targets are
+	// generated from and decrypt grants are made on
+	EnvelopeKeyID string `mapstructure:"envelope_key_id" yaml:"envelope_key_id,omitempty"`
+}
+
+// MergeStoreGCP uses GCP Secret Manager as the merge store
+type MergeStoreGCP struct {
+	Project string `mapstructure:"project" yaml:"project"`
+	// Prefix starts every secret ID, e.g. "merged-"
+	Prefix string `mapstructure:"prefix" yaml:"prefix,omitempty"`
+	// ReplicationLocations pins secrets to these regions (default: automatic)
+	ReplicationLocations []string `mapstructure:"replication_locations" yaml:"replication_locations,omitempty"`
 }
 
 // Target defines a sync destination.
@@ -189,6 +350,87 @@ type Target struct {
 	Region       string   `mapstructure:"region" yaml:"region"`
 	SecretPrefix string   `mapstructure:"secret_prefix" yaml:"secret_prefix"`
 	RoleARN      string   `mapstructure:"role_arn" yaml:"role_arn"`
+
+	// Classification groups targets by environment tier (e.g. production, staging)
+	// so policies such as freeze windows can apply to a whole tier
+	Classification string `mapstructure:"classification" yaml:"classification,omitempty"`
+
+	// Owners are accountable for the target (emails, Slack handles or team
+	// names); its notifications are addressed to them
+	Owners []string `mapstructure:"owners" yaml:"owners,omitempty"`
+	// Tags are put on the target's Secrets Manager secrets, over those of its
+	// imports, for IAM conditions and cost allocation. import_policies match
+	// on them too, e.g. data-classification=pci.
+	Tags map[string]string `mapstructure:"tags" yaml:"tags,omitempty"`
+	// Labels are key/value pairs for selecting targets, e.g. --label team=payments
+	Labels map[string]string `mapstructure:"labels" yaml:"labels,omitempty"`
+	// RoleChain and AssumeRole replace aws.execution_context's for this target
+	RoleChain  []RoleChainHop     `mapstructure:"role_chain" yaml:"role_chain,omitempty"`
+	AssumeRole *AssumeRoleOptions `mapstructure:"assume_role" yaml:"assume_role,omitempty"`
+
+	// GitHub syncs to GitHub Actions secrets instead of an AWS account
+	GitHub *GitHubDestination `mapstructure:"github" yaml:"github,omitempty"`
+	// Kubernetes syncs to Kubernetes Secrets in a cluster instead of Secrets Manager.
+	// EKS clusters are reached through the target's account_id/role_arn.
+	Kubernetes *KubernetesDestination `mapstructure:"kubernetes" yaml:"kubernetes,omitempty"`
+
+	// Destinations fans the merged payload out to several destinations of mixed
+	// types. account_id, github and kubernetes above are shorthand for a single
+	// destination and cannot be combined with this list.
+	Destinations []Destination `mapstructure:"destinations" yaml:"destinations,omitempty"`
+	// Parallelism is how many of the destinations are synced at once (default 1)
+	Parallelism int `mapstructure:"parallelism" yaml:"parallelism,omitempty"`
+
+	// Template names an entry in target_templates whose fields fill in anything
+	// this target leaves unset; Params are substituted into the template
+	Template string            `mapstructure:"template" yaml:"template,omitempty"`
+	Params   map[string]string `mapstructure:"params" yaml:"params,omitempty"`
+	// OU places the target in aws.organizations.ous, by path (workloads/prod)
+	// or OU ID, so it inherits that OU's defaults. Targets whose account is
+	// listed under an OU are placed there without it.
+	OU string `mapstructure:"ou" yaml:"ou,omitempty"`
+
+	// Preconditions must all pass before the target is synced, e.g. to wait
+	// for an account's bootstrap to finish
+	Preconditions []Precondition `mapstructure:"preconditions" yaml:"preconditions,omitempty"`
+
+	// Envelope distributes the target's secrets as a KMS-encrypted envelope in
+	// the S3 merge store plus decrypt grants, instead of copying values
+	Envelope *TargetEnvelope `mapstructure:"envelope" yaml:"envelope,omitempty"`
+
+	// Transforms reshape the keys and names of the secrets written to the
+	// target's destinations
+	Transforms *KeyTransforms `mapstructure:"transforms" yaml:"transforms,omitempty"`
+
+	// Staging holds new merged secrets back from the target's destinations
+	// until an activation time
+	Staging *TargetStaging `mapstructure:"staging" yaml:"staging,omitempty"`
+}
+
+// GitHubDestination writes merged secrets to a repository's (or environment's)
+// GitHub Actions secrets
+type GitHubDestination struct {
+	Owner string `mapstructure:"owner" yaml:"owner"`
+	// Repo is the repository whose secrets are written; without it the
+	// owner's organization secrets are, which requires Visibility
+	Repo string `mapstructure:"repo" yaml:"repo,omitempty"`
+	// Environment writes the repository's environment secrets instead
+	Environment string `mapstructure:"environment" yaml:"environment,omitempty"`
+	// Visibility sets which repositories can use organization secrets: all,
+	// private (private and internal repositories) or selected (Repositories)
+	Visibility   string   `mapstructure:"visibility" yaml:"visibility,omitempty"`
+	Repositories []string `mapstructure:"repositories" yaml:"repositories,omitempty"`
+}
+
+// KubernetesDestination writes each merged secret to a Kubernetes Secret in a cluster
+type KubernetesDestination struct {
+	// Provider is eks, gke, or empty to use the local kubeconfig / in-cluster config
+	Provider string `mapstructure:"provider" yaml:"provider,omitempty"`
+	Cluster  string `mapstructure:"cluster" yaml:"cluster,omitempty"`
+	// Location is the GKE location; EKS clusters use the target region
+	Location  string `mapstructure:"location" yaml:"location,omitempty"`
+	Project   string `mapstructure:"project" yaml:"project,omitempty"` // GKE project
+	Namespace string `mapstructure:"namespace" yaml:"namespace"`
 }
 
 // UnmarshalYAML implements custom YAML unmarshaling to support shorthand format.
@@ -228,6 +470,24 @@ type DynamicTarget struct {
 	Region       string `mapstructure:"region" yaml:"region"`
 	SecretPrefix string `mapstructure:"secret_prefix" yaml:"secret_prefix"`
 	RoleARN      string `mapstructure:"role_arn" yaml:"role_arn"` // Supports {{.AccountID}} template
+
+	// Classification, Owners, Tags and Labels are copied to every discovered
+	// target
+	Classification string            `mapstructure:"classification" yaml:"classification,omitempty"`
+	Owners         []string          `mapstructure:"owners" yaml:"owners,omitempty"`
+	Tags           map[string]string `mapstructure:"tags" yaml:"tags,omitempty"`
+	Labels         map[string]string `mapstructure:"labels" yaml:"labels,omitempty"`
+	// RoleChain and AssumeRole are copied to every discovered account target
+	RoleChain  []RoleChainHop     `mapstructure:"role_chain" yaml:"role_chain,omitempty"`
+	AssumeRole *AssumeRoleOptions `mapstructure:"assume_role" yaml:"assume_role,omitempty"`
+
+	// Preconditions are copied to every discovered account target, with
+	// {{.AccountID}} substituted
+	Preconditions []Precondition `mapstructure:"preconditions" yaml:"preconditions,omitempty"`
+
+	// RequireApproval merges newly discovered targets but holds their sync
+	// until they are approved with `vss targets approve`
+	RequireApproval bool `mapstructure:"require_approval" yaml:"require_approval,omitempty"`
 }
 
 // DiscoveryConfig defines how to discover dynamic targets
@@ -235,6 +495,9 @@ type DiscoveryConfig struct {
 	IdentityCenter *IdentityCenterDiscovery `mapstructure:"identity_center" yaml:"identity_center"`
 	Organizations  *OrganizationsDiscovery  `mapstructure:"organizations" yaml:"organizations"`
 	AccountsList   *AccountsListDiscovery   `mapstructure:"accounts_list" yaml:"accounts_list"`
+	Doppler        *DopplerDiscovery        `mapstructure:"doppler" yaml:"doppler,omitempty"`
+	GitHub         *GitHubDiscovery         `mapstructure:"github" yaml:"github,omitempty"`
+	Kubernetes     *KubernetesDiscovery     `mapstructure:"kubernetes" yaml:"kubernetes,omitempty"`
 }
 
 // IdentityCenterDiscovery discovers accounts from Identity Center
@@ -252,60 +515,220 @@ type OrganizationsDiscovery struct {
 
 // AccountsListDiscovery discovers accounts from an external source (e.g., SSM Parameter Store)
 type AccountsListDiscovery struct {
-	Source string `mapstructure:"source" yaml:"source"` // e.g., "ssm:/platform/analytics-engineer-sandboxes"
+	// Source is an SSM parameter name or ARN, e.g. "ssm:/platform/analytics-engineer-sandboxes"
+	// or "ssm:arn:aws:ssm:us-east-1:123456789012:parameter/platform/sandboxes",
+	// a DynamoDB table name or ARN, e.g. "dynamodb:platform-accounts", or an
+	// https:// URL returning JSON
+	Source string `mapstructure:"source" yaml:"source"`
+	// RoleARN is assumed to read ssm: and dynamodb: sources, e.g. a role in the
+	// management account whose Parameter Store holds the list (default: the
+	// execution identity)
+	RoleARN string `mapstructure:"role_arn" yaml:"role_arn,omitempty"`
+	// IDAttribute and NameAttribute are the DynamoDB attributes or JSON object
+	// fields holding each account's ID and name (default: id and name)
+	IDAttribute   string `mapstructure:"id_attribute" yaml:"id_attribute,omitempty"`
+	NameAttribute string `mapstructure:"name_attribute" yaml:"name_attribute,omitempty"`
+	// Headers are sent with requests to https:// sources, e.g.
+	// Authorization: "Bearer ${INVENTORY_TOKEN}". Supports ${VAR}.
+	Headers map[string]string `mapstructure:"headers" yaml:"headers,omitempty"`
+}
+
+// DopplerDiscovery enumerates a Doppler project's configs and maps each one to a target.
+// Root configs (dev, stg, prd) import their own Doppler source; branch configs import
+// their root config's target plus their own source, so branch overrides are flattened
+// on top of the root during the merge phase.
+type DopplerDiscovery struct {
+	Project string `mapstructure:"project" yaml:"project"`
+	Token   string `mapstructure:"token" yaml:"token"` // Supports ${VAR}
+	// Accounts maps Doppler environment slugs (dev, stg, prd) to AWS account IDs
+	Accounts map[string]string `mapstructure:"accounts" yaml:"accounts"`
+	// Branches includes branch configs; when false only root configs are mapped
+	Branches bool `mapstructure:"branches" yaml:"branches"`
+}
+
+// GitHubDiscovery lists repositories in a GitHub org and creates a GitHub
+// destination target for each one
+type GitHubDiscovery struct {
+	Org string `mapstructure:"org" yaml:"org"`
+	// Topics keeps only repositories with at least one of these topics
+	Topics []string `mapstructure:"topics" yaml:"topics"`
+	// Team keeps only repositories the team (slug) has access to
+	Team string `mapstructure:"team" yaml:"team"`
+	// Environment writes to this GitHub environment in each repository instead of repo secrets
+	Environment string `mapstructure:"environment" yaml:"environment"`
+	// IncludeArchived includes archived repositories (excluded by default)
+	IncludeArchived bool `mapstructure:"include_archived" yaml:"include_archived"`
+}
+
+// KubernetesDiscovery lists EKS clusters per account/region (or GKE clusters per
+// project) and creates a Kubernetes destination target for each active cluster
+type KubernetesDiscovery struct {
+	Provider string `mapstructure:"provider" yaml:"provider"` // eks or gke
+	// Accounts are the AWS accounts to scan (EKS); defaults to the current account
+	Accounts []string `mapstructure:"accounts" yaml:"accounts"`
+	// Regions are the AWS regions to scan (EKS); defaults to the dynamic target region
+	Regions []string `mapstructure:"regions" yaml:"regions"`
+	// Projects are the GCP projects to scan (GKE)
+	Projects []string `mapstructure:"projects" yaml:"projects"`
+	// Location limits GKE discovery to one location (default: all locations)
+	Location string `mapstructure:"location" yaml:"location"`
+	// Tags keeps only clusters with all of these EKS tags / GKE resource labels
+	Tags map[string]string `mapstructure:"tags" yaml:"tags"`
+	// Namespace the Secrets are written to in every cluster (default: "default")
+	Namespace string `mapstructure:"namespace" yaml:"namespace"`
 }
 
 // PipelineSettings configures pipeline execution
 type PipelineSettings struct {
+	// Name identifies the pipeline in role session tags (default: the config
+	// file's name without its extension)
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
+
 	Merge           MergeSettings `mapstructure:"merge" yaml:"merge"`
 	Sync            SyncSettings  `mapstructure:"sync" yaml:"sync"`
 	DryRun          bool          `mapstructure:"dry_run" yaml:"dry_run"`
 	ContinueOnError bool          `mapstructure:"continue_on_error" yaml:"continue_on_error"`
+
+	// MaxWorkers bounds the target operations running at once across all
+	// phases, levels and destinations (default 64)
+	MaxWorkers int `mapstructure:"max_workers" yaml:"max_workers,omitempty"`
+
+	// FreezeWindows block apply-mode runs against matching targets while active
+	FreezeWindows []FreezeWindow `mapstructure:"freeze_windows" yaml:"freeze_windows,omitempty"`
+
+	// ImportPolicies restrict which targets may import sensitive sources
+	ImportPolicies []ImportPolicy `mapstructure:"import_policies" yaml:"import_policies,omitempty"`
+
+	// History records each run on disk
+	History *HistorySettings `mapstructure:"history" yaml:"history,omitempty"`
+
+	// Manifest writes a signed manifest of each run for auditors
+	Manifest *ManifestSettings `mapstructure:"manifest" yaml:"manifest,omitempty"`
+
+	// Hooks call local commands or HTTP endpoints before and after the run,
+	// each phase and each target
+	Hooks []Hook `mapstructure:"hooks" yaml:"hooks,omitempty"`
+
+	// DestinationHealth configures destination health probes
+	DestinationHealth *DestinationHealthSettings `mapstructure:"destination_health" yaml:"destination_health,omitempty"`
+
+	// Schedule configures when `vss serve` runs the pipeline
+	Schedule *ScheduleSettings `mapstructure:"schedule" yaml:"schedule,omitempty"`
+
+	// Notifications are sent when a run starts and ends
+	Notifications *NotificationSettings `mapstructure:"notifications" yaml:"notifications,omitempty"`
+
+	// Retry retries targets that fail with transient errors
+	Retry *RetryPolicy `mapstructure:"retry" yaml:"retry,omitempty"`
+
+	// Coordination claims targets in the merge store so controllers sharing
+	// it do not write the same target at once
+	Coordination *CoordinationSettings `mapstructure:"coordination" yaml:"coordination,omitempty"`
+
+	// DiffHashSalt salts the hashes of each secret and key in diffs, so
+	// auditors can tell which values changed between runs without plaintext.
+	// Keep it stable to compare runs; diffs have no hashes without it.
+	DiffHashSalt string `mapstructure:"diff_hash_salt" yaml:"diff_hash_salt,omitempty"`
+
+	// DiscoveryLimits stop runs that would sync to more discovered accounts
+	// than expected
+	DiscoveryLimits *DiscoveryLimits `mapstructure:"discovery_limits" yaml:"discovery_limits,omitempty"`
+
+	// State records each target's phase outcomes as a run progresses, so a
+	// failed run can be resumed with --resume
+	State *StateSettings `mapstructure:"state" yaml:"state,omitempty"`
 }
 
 // MergeSettings configures the merge phase
 type MergeSettings struct {
 	Parallel int `mapstructure:"parallel" yaml:"parallel"`
+	// Levels caps the concurrency of individual dependency levels below Parallel
+	Levels []LevelLimit `mapstructure:"levels" yaml:"levels,omitempty"`
 }
 
 // SyncSettings configures the sync phase
 type SyncSettings struct {
 	Parallel      int  `mapstructure:"parallel" yaml:"parallel"`
 	DeleteOrphans bool `mapstructure:"delete_orphans" yaml:"delete_orphans"`
+	// Verify reads every written secret back from destinations that support
+	// it and fails the paths that do not match what was written
+	Verify bool `mapstructure:"verify" yaml:"verify,omitempty"`
+	// SkipUnchanged skips syncing destinations last synced with the target's
+	// current merged secrets and config, recording what each was synced with
+	// in the merge store
+	SkipUnchanged bool `mapstructure:"skip_unchanged" yaml:"skip_unchanged,omitempty"`
 }
 
 // LoadConfig loads configuration from file
 func LoadConfig(path string) (*Config, error) {
+	return LoadConfigWithOptions(path, LoadOptions{})
+}
+
+// LoadConfigWithOptions loads configuration from a YAML file. In strict mode
+// unknown keys, e.g. a misspelled merge_stor:, fail with an UnknownFieldsError.
+func LoadConfigWithOptions(path string, opts LoadOptions) (*Config, error) {
 	// Read file directly for better YAML parsing
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	if err := checkMinVersion(data, opts.Version); err != nil {
+		return nil, err
+	}
+
+	unknown, err := FindUnknownFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(unknown) > 0 {
+		if opts.Strict {
+			return nil, &UnknownFieldsError{Fields: unknown}
+		}
+		for _, f := range unknown {
+			log.WithFields(log.Fields{
+				"action": "LoadConfig",
+				"key":    f.Key,
+				"line":   f.Line,
+				"column": f.Column,
+			}).Warn("Ignoring unknown config key")
+		}
+	}
+
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(opts.Strict)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	// Look for committed credentials while ${VAR} references are still unexpanded
+	if cfg.literalSecrets, err = FindLiteralSecrets(data); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
 	// Apply defaults
 	cfg.applyDefaults()
 
+	// Expand target templates before anything inspects targets
+	if err := cfg.expandTargetTemplates(); err != nil {
+		return nil, err
+	}
+
+	// Fill in what targets leave unset from the defaults of their OU
+	if err := cfg.applyOUDefaults(); err != nil {
+		return nil, err
+	}
+
 	// Expand environment variables in sensitive fields
 	cfg.expandEnvVars()
-
-	// Also load via Viper for env var override support
-	v := viper.New()
-	v.SetConfigFile(path)
-	v.SetEnvPrefix("VSS")
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	v.AutomaticEnv()
-	
-	// Override from environment if set
-	if v.IsSet("log.level") {
-		cfg.Log.Level = v.GetString("log.level")
+	if opts.StoredCredential != nil {
+		cfg.applyStoredCredentials(opts.StoredCredential)
 	}
-	if v.IsSet("aws.region") {
-		cfg.AWS.Region = v.GetString("aws.region")
+
+	// Any field can be overridden from the environment, e.g. VSS_PIPELINE_SYNC_PARALLEL
+	if err := cfg.applyEnvOverrides(); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
@@ -364,16 +787,70 @@ func (c *Config) expandEnvVars() {
 	if c.Vault.Auth.Token != nil {
 		c.Vault.Auth.Token.Token = expand(c.Vault.Auth.Token.Token)
 	}
+	if c.Vault.Auth.JWT != nil {
+		c.Vault.Auth.JWT.TokenFile = expand(c.Vault.Auth.JWT.TokenFile)
+	}
+
+	// Expand GitHub App private key
+	if c.GitHub != nil {
+		c.GitHub.PrivateKey = expand(c.GitHub.PrivateKey)
+	}
+
+	// Expand Doppler tokens
+	for name, src := range c.Sources {
+		if src.Doppler != nil {
+			src.Doppler.Token = expand(src.Doppler.Token)
+			c.Sources[name] = src
+		}
+	}
+	for _, dt := range c.DynamicTargets {
+		if dt.Discovery.Doppler != nil {
+			dt.Discovery.Doppler.Token = expand(dt.Discovery.Doppler.Token)
+		}
+		if al := dt.Discovery.AccountsList; al != nil {
+			for k, v := range al.Headers {
+				al.Headers[k] = expand(v)
+			}
+		}
+	}
+	for _, target := range c.Targets {
+		for _, d := range target.Destinations {
+			if d.Doppler != nil {
+				d.Doppler.Token = expand(d.Doppler.Token)
+			}
+		}
+	}
+
+	c.Pipeline.DiffHashSalt = expand(c.Pipeline.DiffHashSalt)
+
+	// Expand notification webhook URLs (Slack's embed a token) and headers
+	if n := c.Pipeline.Notifications; n != nil {
+		for i := range n.Webhooks {
+			w := &n.Webhooks[i]
+			w.URL = expand(w.URL)
+			for k, v := range w.Headers {
+				w.Headers[k] = expand(v)
+			}
+		}
+	}
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
+	// Refuse committed credentials before anything else so they are fixed first
+	if len(c.literalSecrets) > 0 {
+		return &LiteralSecretsError{Secrets: c.literalSecrets}
+	}
+
 	if c.Vault.Address == "" {
 		return fmt.Errorf("vault.address is required")
 	}
+	if err := c.Vault.Auth.validate(); err != nil {
+		return fmt.Errorf("vault.auth: %w", err)
+	}
 
-	if c.MergeStore.Vault == nil && c.MergeStore.S3 == nil {
-		return fmt.Errorf("merge_store must specify either vault or s3")
+	if c.MergeStore.Vault == nil && c.MergeStore.S3 == nil && c.MergeStore.GCP == nil {
+		return fmt.Errorf("merge_store must specify vault, s3 or gcp")
 	}
 
 	// Validate S3 merge store config if specified
@@ -382,35 +859,329 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("merge_store.s3.bucket is required")
 		}
 	}
+	if c.MergeStore.GCP != nil {
+		if c.MergeStore.GCP.Project == "" {
+			return fmt.Errorf("merge_store.gcp.project is required")
+		}
+		if gcpInvalidIDChars.MatchString(c.MergeStore.GCP.Prefix) {
+			return fmt.Errorf("merge_store.gcp.prefix may only contain letters, digits, underscores and dashes")
+		}
+	}
+
+	if err := c.Log.Redact.validate(); err != nil {
+		return fmt.Errorf("log.redact: %w", err)
+	}
+
+	if r := c.AWS.RateLimits; r != nil {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("aws.rate_limits: %w", err)
+		}
+	}
+
+	if dc := c.AWS.DiscoveryCache; dc != nil {
+		if err := dc.validate(); err != nil {
+			return fmt.Errorf("aws.discovery_cache: %w", err)
+		}
+	}
+
+	if de := c.AWS.DiscoveryEvents; de != nil {
+		if err := de.validate(); err != nil {
+			return fmt.Errorf("aws.discovery_events: %w", err)
+		}
+	}
+
+	if err := validateRoleChain(c.AWS.ExecutionContext.RoleChain); err != nil {
+		return fmt.Errorf("aws.execution_context.role_chain: %w", err)
+	}
+	if ar := c.AWS.ExecutionContext.AssumeRole; ar != nil {
+		if err := ar.validate(c.AWS.ExecutionContext.RoleChain); err != nil {
+			return fmt.Errorf("aws.execution_context.assume_role: %w", err)
+		}
+	}
+
+	if err := c.AWS.ControlTower.AccountFactory.validate(c); err != nil {
+		return fmt.Errorf("aws.control_tower.account_factory: %w", err)
+	}
 
 	// At least one target is required (static or dynamic)
 	if len(c.Targets) == 0 && len(c.DynamicTargets) == 0 {
 		return fmt.Errorf("at least one target or dynamic_target is required")
 	}
 
+	// Validate sources
+	for name, src := range c.Sources {
+		if src.Doppler != nil && (src.Doppler.Project == "" || src.Doppler.Config == "") {
+			return fmt.Errorf("source %q: doppler.project and doppler.config are required", name)
+		}
+		if src.Vault != nil {
+			if err := src.Vault.validate(); err != nil {
+				return fmt.Errorf("source %q: vault: %w", name, err)
+			}
+		}
+		if src.Transforms != nil {
+			if err := src.Transforms.validate(""); err != nil {
+				return fmt.Errorf("source %q: transforms: %w", name, err)
+			}
+		}
+		if err := validateSecretTags(src.Tags); err != nil {
+			return fmt.Errorf("source %q: tags: %w", name, err)
+		}
+	}
+
 	// Validate targets
 	for name, target := range c.Targets {
-		if target.AccountID == "" {
-			return fmt.Errorf("target %q: account_id is required", name)
+		if target.Template != "" {
+			if _, ok := c.TargetTemplates[target.Template]; !ok {
+				return fmt.Errorf("target %q: template %q not found in target_templates", name, target.Template)
+			}
+		}
+		if len(target.Destinations) > 0 {
+			if target.AccountID != "" || target.GitHub != nil || target.Kubernetes != nil {
+				return fmt.Errorf("target %q: account_id, github and kubernetes cannot be combined with destinations", name)
+			}
+			seen := make(map[string]bool)
+			for _, d := range target.Destinations {
+				label := d.Label()
+				if seen[label] {
+					return fmt.Errorf("target %q: duplicate destination %q", name, label)
+				}
+				seen[label] = true
+				if err := c.validateDestination(fmt.Sprintf("target %q: destination %q", name, label), d); err != nil {
+					return err
+				}
+			}
+		} else if err := c.validateDestination(fmt.Sprintf("target %q", name), target.ResolvedDestinations()[0]); err != nil {
+			return err
+		}
+		if target.Parallelism < 0 {
+			return fmt.Errorf("target %q: parallelism must not be negative", name)
+		}
+		if err := validateSecretTags(target.Tags); err != nil {
+			return fmt.Errorf("target %q: tags: %w", name, err)
 		}
-		// Validate AWS account ID format (must be 12 digits)
-		if !isValidAWSAccountID(target.AccountID) {
-			return fmt.Errorf("target %q: invalid account_id format %q (must be 12 digits)", name, target.AccountID)
+		if err := validateRoleChain(target.RoleChain); err != nil {
+			return fmt.Errorf("target %q: role_chain: %w", name, err)
+		}
+		if ar := c.assumeRole(target); ar != nil {
+			if err := ar.validate(c.roleChain(target)); err != nil {
+				return fmt.Errorf("target %q: assume_role: %w", name, err)
+			}
+		}
+		for i, pc := range target.Preconditions {
+			if err := pc.validate(); err != nil {
+				return fmt.Errorf("target %q: preconditions[%d]: %w", name, i, err)
+			}
+		}
+		if target.Envelope != nil {
+			if err := target.Envelope.validate(c, target); err != nil {
+				return fmt.Errorf("target %q: envelope: %w", name, err)
+			}
+		}
+		if target.Transforms != nil {
+			if target.Envelope != nil {
+				return fmt.Errorf("target %q: transforms cannot be combined with envelope", name)
+			}
+			if err := target.Transforms.validate(name); err != nil {
+				return fmt.Errorf("target %q: transforms: %w", name, err)
+			}
+		}
+		if target.Staging != nil {
+			if err := target.Staging.validate(); err != nil {
+				return fmt.Errorf("target %q: staging: %w", name, err)
+			}
+			if _, ok := c.Targets[stagedTargetName(name)]; ok {
+				return fmt.Errorf("target %q: staging: target %q would clash with its staged secrets", name, stagedTargetName(name))
+			}
 		}
 		// Validate imports reference valid sources or other targets
 		for _, imp := range target.Imports {
-			if _, ok := c.Sources[imp]; !ok {
-				if _, ok := c.Targets[imp]; !ok {
-					return fmt.Errorf("target %q: import %q not found in sources or targets", name, imp)
+			ref, err := ParseImportRef(imp)
+			if err != nil {
+				return fmt.Errorf("target %q: %w", name, err)
+			}
+			src, isSource := c.Sources[ref.Name]
+			if !isSource {
+				if _, ok := c.Targets[ref.Name]; !ok {
+					return fmt.Errorf("target %q: import %q not found in sources or targets", name, ref.Name)
+				}
+			}
+			if ref.Pinned() {
+				if isSource && src.Doppler != nil {
+					return fmt.Errorf("target %q: import %q: doppler sources cannot be pinned to a version", name, imp)
+				}
+				if c.MergeStore.Vault == nil {
+					return fmt.Errorf("target %q: import %q: version pinning requires a vault merge store", name, imp)
 				}
+				// A version number only names the same state across
+				// secrets when there is one secret
+				if !isSource || src.Vault == nil || !src.Vault.singleSecret() {
+					return fmt.Errorf("target %q: import %q: only vault sources of a single secret (one entry in paths) can be pinned to a version", name, imp)
+				}
+			}
+		}
+	}
+
+	if err := c.validateSecretNames(); err != nil {
+		return err
+	}
+
+	// Validate freeze windows
+	for i, w := range c.Pipeline.FreezeWindows {
+		if w.Name == "" {
+			return fmt.Errorf("pipeline.freeze_windows[%d]: name is required", i)
+		}
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("freeze window %q: %w", w.Name, err)
+		}
+		for _, t := range w.Targets {
+			if _, ok := c.Targets[t]; !ok {
+				return fmt.Errorf("freeze window %q: target %q not found", w.Name, t)
 			}
 		}
 	}
 
+	// Validate import policies
+	for i, p := range c.Pipeline.ImportPolicies {
+		if p.Name == "" {
+			return fmt.Errorf("pipeline.import_policies[%d]: name is required", i)
+		}
+		if err := p.validate(c); err != nil {
+			return fmt.Errorf("import policy %q: %w", p.Name, err)
+		}
+	}
+	if err := c.authorizeImports(); err != nil {
+		return err
+	}
+
+	// Validate hooks
+	for i, h := range c.Pipeline.Hooks {
+		if h.Name == "" {
+			return fmt.Errorf("pipeline.hooks[%d]: name is required", i)
+		}
+		if err := h.validate(c); err != nil {
+			return fmt.Errorf("hook %q: %w", h.Name, err)
+		}
+	}
+
+	if n := c.Pipeline.Notifications; n != nil {
+		for i, w := range n.Webhooks {
+			if w.Name == "" {
+				return fmt.Errorf("pipeline.notifications.webhooks[%d]: name is required", i)
+			}
+			if err := w.validate(); err != nil {
+				return fmt.Errorf("notification webhook %q: %w", w.Name, err)
+			}
+		}
+	}
+
+	if c.Pipeline.MaxWorkers < 0 {
+		return fmt.Errorf("pipeline.max_workers must not be negative")
+	}
+	if err := validateLevelLimits(c.Pipeline.Merge.Levels); err != nil {
+		return fmt.Errorf("pipeline.merge.levels: %w", err)
+	}
+	if r := c.Pipeline.Retry; r != nil {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("pipeline.retry: %w", err)
+		}
+	}
+	if co := c.Pipeline.Coordination; co != nil {
+		if err := co.validate(); err != nil {
+			return fmt.Errorf("pipeline.coordination: %w", err)
+		}
+	}
+	if m := c.Pipeline.Manifest; m != nil {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("pipeline.manifest: %w", err)
+		}
+	}
+	if h := c.Pipeline.DestinationHealth; h != nil {
+		if err := h.validate(); err != nil {
+			return fmt.Errorf("pipeline.destination_health: %w", err)
+		}
+	}
+	if sc := c.Pipeline.Schedule; sc != nil {
+		if err := sc.validate(c); err != nil {
+			return fmt.Errorf("pipeline.schedule: %w", err)
+		}
+	}
+	if dl := c.Pipeline.DiscoveryLimits; dl != nil {
+		if err := dl.validate(); err != nil {
+			return fmt.Errorf("pipeline.discovery_limits: %w", err)
+		}
+	}
+	if st := c.Pipeline.State; st != nil {
+		if err := st.validate(); err != nil {
+			return fmt.Errorf("pipeline.state: %w", err)
+		}
+	}
+
 	// Validate dynamic targets
 	for name, dt := range c.DynamicTargets {
-		if dt.Discovery.IdentityCenter == nil && dt.Discovery.Organizations == nil && dt.Discovery.AccountsList == nil {
-			return fmt.Errorf("dynamic_target %q: must specify identity_center, organizations, or accounts_list discovery", name)
+		if dt.Discovery.IdentityCenter == nil && dt.Discovery.Organizations == nil && dt.Discovery.AccountsList == nil &&
+			dt.Discovery.Doppler == nil && dt.Discovery.GitHub == nil && dt.Discovery.Kubernetes == nil {
+			return fmt.Errorf("dynamic_target %q: must specify identity_center, organizations, accounts_list, doppler, github, or kubernetes discovery", name)
+		}
+		if al := dt.Discovery.AccountsList; al != nil {
+			if err := al.validate(); err != nil {
+				return fmt.Errorf("dynamic_target %q: accounts_list: %w", name, err)
+			}
+		}
+		if kd := dt.Discovery.Kubernetes; kd != nil {
+			switch kd.Provider {
+			case "eks":
+				for _, accountID := range kd.Accounts {
+					if !isValidAWSAccountID(accountID) {
+						return fmt.Errorf("dynamic_target %q: invalid account_id %q in kubernetes.accounts", name, accountID)
+					}
+				}
+			case "gke":
+				if len(kd.Projects) == 0 {
+					return fmt.Errorf("dynamic_target %q: kubernetes.projects is required for gke", name)
+				}
+			default:
+				return fmt.Errorf("dynamic_target %q: kubernetes.provider must be eks or gke", name)
+			}
+		}
+		if gd := dt.Discovery.GitHub; gd != nil {
+			if gd.Org == "" {
+				return fmt.Errorf("dynamic_target %q: github.org is required", name)
+			}
+			if c.GitHub == nil {
+				return fmt.Errorf("dynamic_target %q: github discovery requires top-level github app configuration", name)
+			}
+		}
+		if dd := dt.Discovery.Doppler; dd != nil {
+			if dd.Project == "" {
+				return fmt.Errorf("dynamic_target %q: doppler.project is required", name)
+			}
+			for env, accountID := range dd.Accounts {
+				if !isValidAWSAccountID(accountID) {
+					return fmt.Errorf("dynamic_target %q: invalid account_id %q for doppler environment %q", name, accountID, env)
+				}
+			}
+		}
+		if len(dt.Preconditions) > 0 {
+			if dt.Discovery.IdentityCenter == nil && dt.Discovery.Organizations == nil && dt.Discovery.AccountsList == nil {
+				return fmt.Errorf("dynamic_target %q: preconditions require identity_center, organizations or accounts_list discovery", name)
+			}
+			// Checked as they will be once an account ID is substituted
+			for i, pc := range preconditionsFor(dt.Preconditions, "000000000000") {
+				if err := pc.validate(); err != nil {
+					return fmt.Errorf("dynamic_target %q: preconditions[%d]: %w", name, i, err)
+				}
+			}
+		}
+		if err := validateRoleChain(dt.RoleChain); err != nil {
+			return fmt.Errorf("dynamic_target %q: role_chain: %w", name, err)
+		}
+		if ar := c.assumeRole(Target{AssumeRole: dt.AssumeRole}); ar != nil {
+			if err := ar.validate(c.roleChain(Target{RoleChain: dt.RoleChain})); err != nil {
+				return fmt.Errorf("dynamic_target %q: assume_role: %w", name, err)
+			}
+		}
+		if dt.RequireApproval && dt.Discovery.IdentityCenter == nil && dt.Discovery.Organizations == nil && dt.Discovery.AccountsList == nil {
+			return fmt.Errorf("dynamic_target %q: require_approval requires identity_center, organizations or accounts_list discovery", name)
 		}
 	}
 
@@ -484,15 +1255,26 @@ func (c *Config) IsInheritedTarget(targetName string) bool {
 		return false
 	}
 	for _, imp := range target.Imports {
-		if _, isTarget := c.Targets[imp]; isTarget {
+		if _, isTarget := c.Targets[ImportName(imp)]; isTarget {
 			return true
 		}
 	}
 	return false
 }
 
+// Owners returns the owners of a target or source
+func (c *Config) Owners(name string) []string {
+	name = ImportName(name)
+	if t, ok := c.Targets[name]; ok {
+		return t.Owners
+	}
+	return c.Sources[name].Owners
+}
+
 // GetSourcePath returns the full path for a source or inherited target
 func (c *Config) GetSourcePath(importName string) string {
+	importName = ImportName(importName)
+
 	// Check if it's a direct source
 	if src, ok := c.Sources[importName]; ok {
 		if src.Vault != nil {
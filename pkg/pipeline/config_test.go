@@ -230,6 +230,50 @@ func TestConfigValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "merge_store.s3.bucket is required",
 		},
+		{
+			name: "valid GCP merge store",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{GCP: &MergeStoreGCP{Project: "secrets-prod", Prefix: "merged-"}},
+				Targets: map[string]Target{
+					"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "GCP merge store missing project",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{GCP: &MergeStoreGCP{Prefix: "merged-"}},
+				Targets: map[string]Target{
+					"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "merge_store.gcp.project is required",
+		},
+		{
+			name: "GCP merge store invalid prefix",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{GCP: &MergeStoreGCP{Project: "secrets-prod", Prefix: "merged/"}},
+				Targets: map[string]Target{
+					"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "merge_store.gcp.prefix may only contain",
+		},
 		{
 			name: "valid dynamic target",
 			config: Config{
@@ -249,6 +293,43 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "require_approval without account discovery",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				DynamicTargets: map[string]DynamicTarget{
+					"api": {
+						Discovery:       DiscoveryConfig{Doppler: &DopplerDiscovery{Project: "api"}},
+						Imports:         []string{"analytics"},
+						RequireApproval: true,
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "require_approval requires identity_center, organizations or accounts_list discovery",
+		},
+		{
+			name: "role_chain hop is not a role",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				AWS: AWSConfig{ExecutionContext: ExecutionContextConfig{
+					RoleChain: []RoleChainHop{{RoleARN: "secrets-hub"}},
+				}},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"Serverless_Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `aws.execution_context.role_chain: [0]: role_arn "secrets-hub": not an IAM role ARN`,
+		},
 		{
 			name: "dynamic target missing discovery config",
 			config: Config{
@@ -265,7 +346,7 @@ func TestConfigValidate(t *testing.T) {
 				},
 			},
 			wantErr: true,
-			errMsg:  "must specify identity_center, organizations, or accounts_list discovery",
+			errMsg:  "must specify identity_center, organizations, accounts_list, doppler, github, or kubernetes discovery",
 		},
 		{
 			name: "dynamic target with accounts_list",
@@ -286,6 +367,293 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "dynamic target with cross-account accounts_list",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				DynamicTargets: map[string]DynamicTarget{
+					"sandboxes": {
+						Discovery: DiscoveryConfig{
+							AccountsList: &AccountsListDiscovery{
+								Source:  "ssm:arn:aws:ssm:us-east-1:123456789012:parameter/platform/sandboxes",
+								RoleARN: "arn:aws:iam::123456789012:role/vss-sandbox-list-reader",
+							},
+						},
+						Imports: []string{"analytics"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "accounts_list with a non-SSM ARN",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				DynamicTargets: map[string]DynamicTarget{
+					"sandboxes": {
+						Discovery: DiscoveryConfig{
+							AccountsList: &AccountsListDiscovery{Source: "ssm:arn:aws:s3:::platform/sandboxes"},
+						},
+						Imports: []string{"analytics"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "not an SSM parameter ARN",
+		},
+		{
+			name: "accounts_list with an invalid role_arn",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				DynamicTargets: map[string]DynamicTarget{
+					"sandboxes": {
+						Discovery: DiscoveryConfig{
+							AccountsList: &AccountsListDiscovery{Source: "ssm:/platform/sandboxes", RoleARN: "vss-reader"},
+						},
+						Imports: []string{"analytics"},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  `accounts_list: role_arn "vss-reader": not an IAM role ARN`,
+		},
+		{
+			name: "github destination without app config",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"payments": {Imports: []string{"analytics"}, GitHub: &GitHubDestination{Owner: "acme", Repo: "payments"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "requires top-level github app configuration",
+		},
+		{
+			name: "github destination without account_id",
+			config: Config{
+				Vault:  VaultConfig{Address: "https://vault.example.com"},
+				GitHub: &GitHubConfig{AppID: 1, InstallID: 2, PrivateKeyPath: "/keys/app.pem"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"payments": {Imports: []string{"analytics"}, GitHub: &GitHubDestination{Owner: "acme", Repo: "payments"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "gke destination without account_id",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"web": {Imports: []string{"analytics"}, Kubernetes: &KubernetesDestination{
+						Provider: "gke", Cluster: "web", Project: "acme-prod", Location: "us-central1",
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "eks destination requires account_id",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"web": {Imports: []string{"analytics"}, Kubernetes: &KubernetesDestination{Provider: "eks", Cluster: "web"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "account_id is required",
+		},
+		{
+			name: "mixed destinations",
+			config: Config{
+				Vault:  VaultConfig{Address: "https://vault.example.com"},
+				GitHub: &GitHubConfig{AppID: 1, InstallID: 2, PrivateKeyPath: "/keys/app.pem"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"analytics": {Imports: []string{"analytics"}, Destinations: []Destination{
+						{AccountID: "111111111111"},
+						{Doppler: &DopplerDestination{Project: "analytics", Config: "prd"}},
+						{GitHub: &GitHubDestination{Owner: "acme", Repo: "analytics"}},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "destinations combined with account_id",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"analytics": {AccountID: "111111111111", Imports: []string{"analytics"}, Destinations: []Destination{
+						{AccountID: "222222222222"},
+					}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "cannot be combined with destinations",
+		},
+		{
+			name: "destination with invalid account_id",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"analytics": {Imports: []string{"analytics"}, Destinations: []Destination{
+						{AccountID: "111111111111"},
+						{AccountID: "1234"},
+					}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `destination "aws:1234": invalid account_id format`,
+		},
+		{
+			name: "duplicate destinations",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"analytics": {Imports: []string{"analytics"}, Destinations: []Destination{
+						{AccountID: "111111111111", Region: "us-west-2"},
+						{AccountID: "111111111111", Region: "us-west-2"},
+					}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "duplicate destination",
+		},
+		{
+			name: "pinned imports",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics":   {Vault: &VaultSource{Mount: "analytics"}},
+					"db-password": {Vault: &VaultSource{Mount: "analytics", Paths: []string{"prod/db"}}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"Stg":  {AccountID: "111111111111", Imports: []string{"analytics"}},
+					"Prod": {AccountID: "222222222222", Imports: []string{"Stg", "db-password@v42"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "pinned import of a whole mount",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics", Paths: []string{"prod/"}}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"Prod": {AccountID: "222222222222", Imports: []string{"analytics@v42"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "only vault sources of a single secret",
+		},
+		{
+			name: "pinned target import",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"Stg":  {AccountID: "111111111111", Imports: []string{"analytics"}},
+					"Prod": {AccountID: "222222222222", Imports: []string{"Stg@3"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `import "Stg@3": only vault sources of a single secret`,
+		},
+		{
+			name: "invalid pinned version",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"Prod": {AccountID: "222222222222", Imports: []string{"analytics@latest"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `invalid version "latest"`,
+		},
+		{
+			name: "pinned import with S3 merge store",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{S3: &MergeStoreS3{Bucket: "merged"}},
+				Targets: map[string]Target{
+					"Prod": {AccountID: "222222222222", Imports: []string{"analytics@v42"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "version pinning requires a vault merge store",
+		},
+		{
+			name: "invalid vault source filter",
+			config: Config{
+				Vault: VaultConfig{Address: "https://vault.example.com"},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Mount: "analytics", Exclude: []string{"regex:[unclosed"}}},
+				},
+				MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+				Targets: map[string]Target{
+					"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `source "analytics": vault: exclude: invalid pattern`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,262 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/jbcom/secretsync/internal/metrics"
+	"github.com/jbcom/secretsync/stores/doppler"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultProbeInterval separates probe rounds in long-running modes
+	defaultProbeInterval = time.Minute
+	// defaultProbeTimeout bounds a single destination probe
+	defaultProbeTimeout = 10 * time.Second
+)
+
+// DestinationHealthSettings configures the destination probes run by
+// long-running modes and by `vss pipeline --probe-destinations`
+//
+//	pipeline:
+//	  destination_health:
+//	    interval: 1m
+//	    timeout: 10s
+type DestinationHealthSettings struct {
+	// Interval separates probe rounds (default 1m)
+	Interval time.Duration `mapstructure:"interval" yaml:"interval,omitempty"`
+	// Timeout bounds each probe (default 10s)
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
+}
+
+func (s *DestinationHealthSettings) validate() error {
+	if s.Interval < 0 {
+		return fmt.Errorf("interval must not be negative")
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// DestinationStatus is the outcome of a destination's last probe
+type DestinationStatus string
+
+const (
+	DestinationUp   DestinationStatus = "up"
+	DestinationDown DestinationStatus = "down"
+	// DestinationUnprobed destinations have no lightweight probe and are
+	// never skipped
+	DestinationUnprobed DestinationStatus = "unprobed"
+)
+
+// DestinationHealth is the probed health of one destination of a target
+type DestinationHealth struct {
+	Target      string            `json:"target"`
+	Destination string            `json:"destination"`
+	Status      DestinationStatus `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	Checked     time.Time         `json:"checked"`
+	// Since is when the destination entered its current status
+	Since time.Time `json:"since"`
+}
+
+// errProbeUnsupported is returned by probers for destination kinds without
+// a lightweight probe
+var errProbeUnsupported = errors.New("no probe for this destination kind")
+
+// destinationProber checks that a destination is reachable and its
+// credentials are accepted, without reading or writing secret values
+type destinationProber interface {
+	ProbeDestination(ctx context.Context, target Target, d Destination) error
+}
+
+type destinationKey struct {
+	target, destination string
+}
+
+// destinationHealthTracker holds the latest probe of each destination
+type destinationHealthTracker struct {
+	mu     sync.Mutex
+	health map[destinationKey]DestinationHealth
+}
+
+func (t *destinationHealthTracker) record(h DestinationHealth) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.health == nil {
+		t.health = make(map[destinationKey]DestinationHealth)
+	}
+	key := destinationKey{h.Target, h.Destination}
+	if prev, ok := t.health[key]; ok && prev.Status == h.Status {
+		h.Since = prev.Since
+	} else {
+		h.Since = h.Checked
+		if ok {
+			log.WithFields(log.Fields{
+				"action":      "destinationHealth",
+				"target":      h.Target,
+				"destination": h.Destination,
+				"from":        prev.Status,
+				"to":          h.Status,
+			}).Warn("Destination health changed")
+		}
+	}
+	t.health[key] = h
+}
+
+// down returns the last probe of a destination known to be down
+func (t *destinationHealthTracker) down(target, destination string) (DestinationHealth, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.health[destinationKey{target, destination}]
+	return h, ok && h.Status == DestinationDown
+}
+
+func (t *destinationHealthTracker) snapshot() []DestinationHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]DestinationHealth, 0, len(t.health))
+	for _, h := range t.health {
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Target != out[j].Target {
+			return out[i].Target < out[j].Target
+		}
+		return out[i].Destination < out[j].Destination
+	})
+	return out
+}
+
+// ProbeDestinations probes every destination of the given targets (all when
+// empty) once. Later syncs skip destinations whose last probe failed until a
+// probe succeeds again.
+func (p *Pipeline) ProbeDestinations(ctx context.Context, targets []string) ([]DestinationHealth, error) {
+	targets, err := p.selectTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	if p.destinationProber == nil {
+		p.destinationProber = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	timeout := defaultProbeTimeout
+	if s := p.config.Pipeline.DestinationHealth; s != nil && s.Timeout > 0 {
+		timeout = s.Timeout
+	}
+
+	var results []DestinationHealth
+	for _, name := range targets {
+		target := p.config.Targets[name]
+		for _, d := range target.ResolvedDestinations() {
+			h := probeDestination(ctx, p.destinationProber, timeout, name, target, d)
+			p.destHealth.record(h)
+			if h.Status != DestinationUnprobed {
+				metrics.RegisterDestinationHealth(h.Target, h.Destination, h.Status == DestinationUp)
+			}
+			results = append(results, h)
+		}
+	}
+	return results, nil
+}
+
+func probeDestination(ctx context.Context, prober destinationProber, timeout time.Duration, name string, target Target, d Destination) DestinationHealth {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	h := DestinationHealth{Target: name, Destination: d.Label(), Status: DestinationUp}
+	err := prober.ProbeDestination(ctx, target, d)
+	h.Checked = time.Now().UTC()
+	switch {
+	case errors.Is(err, errProbeUnsupported):
+		h.Status = DestinationUnprobed
+	case err != nil:
+		h.Status = DestinationDown
+		h.Error = err.Error()
+	}
+	return h
+}
+
+// WatchDestinations probes the destinations of the given targets (all when
+// empty) every pipeline.destination_health.interval until ctx is done, for
+// long-running modes
+func (p *Pipeline) WatchDestinations(ctx context.Context, targets []string) error {
+	interval := defaultProbeInterval
+	if s := p.config.Pipeline.DestinationHealth; s != nil && s.Interval > 0 {
+		interval = s.Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.ProbeDestinations(ctx, targets); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// DestinationHealth returns the latest probe of each destination, sorted by
+// target and destination
+func (p *Pipeline) DestinationHealth() []DestinationHealth {
+	return p.destHealth.snapshot()
+}
+
+// DegradedDestinations lists the destinations a run skipped because they
+// were known to be down, as "<target>/<destination>"
+func DegradedDestinations(results []Result) []string {
+	var skipped []string
+	for _, r := range results {
+		for _, d := range r.Details.SkippedDestinations {
+			skipped = append(skipped, r.Target+"/"+d)
+		}
+	}
+	sort.Strings(skipped)
+	return skipped
+}
+
+// ProbeDestination lists at most one secret in Secrets Manager destinations,
+// introspects Doppler tokens and lists a secret of GitHub repositories,
+// environments and organizations
+func (r *liveReadinessProbe) ProbeDestination(ctx context.Context, target Target, d Destination) error {
+	switch {
+	case d.Doppler != nil:
+		client := &doppler.DopplerClient{
+			Project: d.Doppler.Project,
+			Config:  d.Doppler.Config,
+			Token:   d.Doppler.Token,
+		}
+		if err := client.Init(ctx); err != nil {
+			return err
+		}
+		return client.CheckToken(ctx)
+	case d.GitHub != nil:
+		client := r.config.githubDestinationClient(d.GitHub)
+		if err := client.Init(ctx); err != nil {
+			return fmt.Errorf("failed to initialize github client: %w", err)
+		}
+		return client.CheckScope(ctx)
+	case d.Kubernetes != nil, d.GRPC != nil:
+		return errProbeUnsupported
+	}
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return err
+	}
+	_, err = secretsmanager.NewFromConfig(cfg).ListSecrets(ctx, &secretsmanager.ListSecretsInput{
+		MaxResults: aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+	return nil
+}
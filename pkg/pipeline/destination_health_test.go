@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDestinationProber fails probes of the listed destination labels
+type fakeDestinationProber struct {
+	down   map[string]bool
+	probes int
+}
+
+func (f *fakeDestinationProber) ProbeDestination(_ context.Context, _ Target, d Destination) error {
+	f.probes++
+	if d.Kubernetes != nil {
+		return errProbeUnsupported
+	}
+	if f.down[d.Label()] {
+		return errors.New("AccessDeniedException")
+	}
+	return nil
+}
+
+func TestProbeDestinations(t *testing.T) {
+	prober := &fakeDestinationProber{down: map[string]bool{"aws:222222222222": true, "aws:333333333333": true}}
+	p := &Pipeline{
+		config: &Config{
+			MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+			Targets: map[string]Target{
+				"Cluster": {Kubernetes: &KubernetesDestination{Cluster: "dev", Namespace: "app"}},
+				"Multi":   {Destinations: []Destination{{AccountID: "222222222222"}, {AccountID: "333333333333"}}},
+				"Prod":    {AccountID: "111111111111"},
+			},
+		},
+		destinationProber: prober,
+	}
+	ctx := context.Background()
+
+	health, err := p.ProbeDestinations(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, health, 4)
+	assert.Equal(t, DestinationUnprobed, health[0].Status)
+	assert.Equal(t, DestinationDown, health[1].Status)
+	assert.Equal(t, "AccessDeniedException", health[1].Error)
+	assert.Equal(t, "Prod", health[3].Target)
+	assert.Equal(t, DestinationUp, health[3].Status)
+	_, down := p.destHealth.down("Cluster", "kubernetes:dev/app")
+	assert.False(t, down, "unprobed destinations are never skipped")
+
+	_, err = p.ProbeDestinations(ctx, []string{"Missing"})
+	assert.Error(t, err)
+
+	// A sync skips down destinations without failing the target
+	result := p.syncTarget(ctx, "Multi", true)
+	assert.True(t, result.Success)
+	require.Len(t, result.Details.Destinations, 2)
+	assert.True(t, result.Details.Destinations[0].Skipped)
+	assert.Equal(t, "destination down: AccessDeniedException", result.Details.Destinations[0].Error)
+	assert.Equal(t, []string{"aws:222222222222", "aws:333333333333"}, result.Details.SkippedDestinations)
+	assert.Equal(t, []string{"Multi/aws:222222222222", "Multi/aws:333333333333"}, DegradedDestinations([]Result{result}))
+
+	// Status changes are timestamped; repeated probes keep the timestamp
+	since := p.DestinationHealth()[1].Since
+	_, err = p.ProbeDestinations(ctx, []string{"Multi"})
+	require.NoError(t, err)
+	assert.Equal(t, since, p.DestinationHealth()[1].Since)
+	prober.down = nil
+	_, err = p.ProbeDestinations(ctx, []string{"Multi"})
+	require.NoError(t, err)
+	recovered := p.DestinationHealth()[1]
+	assert.Equal(t, DestinationUp, recovered.Status)
+	assert.Equal(t, recovered.Checked, recovered.Since)
+	_, down = p.destHealth.down("Multi", "aws:222222222222")
+	assert.False(t, down)
+}
@@ -0,0 +1,383 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/stores/doppler"
+	"github.com/jbcom/secretsync/stores/grpcstore"
+	"github.com/jbcom/secretsync/stores/vault"
+)
+
+// Destination is one place a target's merged secrets are written. Every destination
+// of a target receives the same merged payload; transforms are applied per destination.
+// With no doppler, github, kubernetes or grpc block the destination is AWS Secrets
+// Manager in account_id.
+type Destination struct {
+	// Name labels the destination in results (default: "<kind>:<identifier>")
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
+
+	AccountID string `mapstructure:"account_id" yaml:"account_id,omitempty"`
+	Region    string `mapstructure:"region" yaml:"region,omitempty"`
+	RoleARN   string `mapstructure:"role_arn" yaml:"role_arn,omitempty"`
+
+	Doppler    *DopplerDestination    `mapstructure:"doppler" yaml:"doppler,omitempty"`
+	GitHub     *GitHubDestination     `mapstructure:"github" yaml:"github,omitempty"`
+	Kubernetes *KubernetesDestination `mapstructure:"kubernetes" yaml:"kubernetes,omitempty"`
+	GRPC       *GRPCDestination       `mapstructure:"grpc" yaml:"grpc,omitempty"`
+
+	Transforms *DestinationTransforms `mapstructure:"transforms" yaml:"transforms,omitempty"`
+
+	// Reference writes a pointer to each secret instead of its value, for
+	// consumers that resolve indirections. It is a Go template rendered per
+	// key with .Address, .Path (the merge store path), .Name and .Key, e.g.
+	// "vault:{{.Path}}#{{.Key}}". Transforms apply to the pointers.
+	Reference string `mapstructure:"reference" yaml:"reference,omitempty"`
+
+	// RotationOverlap keeps the value a sync replaces readable for this long
+	// after a rotation, for zero-downtime cutover: as AWSPREVIOUS in Secrets
+	// Manager, or as <NAME>_OLD in Doppler. Only those two kinds support it.
+	RotationOverlap time.Duration `mapstructure:"rotation_overlap" yaml:"rotation_overlap,omitempty"`
+}
+
+// DopplerDestination writes merged secrets to a Doppler project config
+type DopplerDestination struct {
+	Project string `mapstructure:"project" yaml:"project"`
+	Config  string `mapstructure:"config" yaml:"config"`
+	Token   string `mapstructure:"token" yaml:"token"` // Supports ${VAR}
+}
+
+// GRPCDestination writes merged secrets to a store that serves the SecretStore
+// gRPC service, so bespoke stores can be targeted without a dedicated driver
+type GRPCDestination struct {
+	Address string `mapstructure:"address" yaml:"address"`
+	// Path is sent with each secret; $1 is the secret name (default: "$1")
+	Path       string `mapstructure:"path" yaml:"path,omitempty"`
+	Insecure   bool   `mapstructure:"insecure" yaml:"insecure,omitempty"`
+	CAFile     string `mapstructure:"ca_file" yaml:"ca_file,omitempty"`
+	ServerName string `mapstructure:"server_name" yaml:"server_name,omitempty"`
+	// Metadata is sent with every call; values support ${VAR}
+	Metadata map[string]string `mapstructure:"metadata" yaml:"metadata,omitempty"`
+	Timeout  string            `mapstructure:"timeout" yaml:"timeout,omitempty"`
+}
+
+// DestinationTransforms filters and reshapes secret keys for a single destination
+type DestinationTransforms struct {
+	// Include keeps only keys matching these names or regexes
+	Include []string `mapstructure:"include" yaml:"include,omitempty"`
+	// Exclude drops keys matching these names or regexes
+	Exclude []string `mapstructure:"exclude" yaml:"exclude,omitempty"`
+	// Rename maps existing key names to new names
+	Rename map[string]string `mapstructure:"rename" yaml:"rename,omitempty"`
+	// Template renders the secret through a Go template
+	Template string `mapstructure:"template" yaml:"template,omitempty"`
+	// Wasm is the path to a WebAssembly plugin whose transform function
+	// reshapes the secret after the other transforms
+	Wasm string `mapstructure:"wasm" yaml:"wasm,omitempty"`
+}
+
+// DestinationResult is the outcome of syncing a target to one of its destinations
+type DestinationResult struct {
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	// Skipped is set when the destination was down and not synced
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+	RoleARN string `json:"role_arn,omitempty"`
+	// Unverified is set when a written secret did not read back as written
+	Unverified bool `json:"unverified,omitempty"`
+	// Unchanged is set when the destination was not synced because it was
+	// last synced with the same merged secrets and config
+	Unchanged bool `json:"unchanged,omitempty"`
+	// Diffed is set when the run computed a diff of the destination
+	Diffed bool `json:"diffed,omitempty"`
+}
+
+// ResolvedDestinations returns the target's destinations. Targets without a
+// destinations list have a single destination built from their own account_id,
+// github or kubernetes fields (the target region still applies as the default).
+func (t Target) ResolvedDestinations() []Destination {
+	if len(t.Destinations) > 0 {
+		return t.Destinations
+	}
+	return []Destination{{
+		AccountID:  t.AccountID,
+		RoleARN:    t.RoleARN,
+		GitHub:     t.GitHub,
+		Kubernetes: t.Kubernetes,
+	}}
+}
+
+// requiresAccountID reports whether the destination writes into an AWS account
+func (d Destination) requiresAccountID() bool {
+	switch {
+	case d.GitHub != nil, d.Doppler != nil, d.GRPC != nil:
+		return false
+	case d.Kubernetes != nil:
+		return d.Kubernetes.Provider == "eks"
+	}
+	return true
+}
+
+// Label returns the destination name used in logs and results
+func (d Destination) Label() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	switch {
+	case d.Doppler != nil:
+		return fmt.Sprintf("doppler:%s/%s", d.Doppler.Project, d.Doppler.Config)
+	case d.GitHub != nil && d.GitHub.Repo == "":
+		return fmt.Sprintf("github:%s", d.GitHub.Owner)
+	case d.GitHub != nil && d.GitHub.Environment != "":
+		return fmt.Sprintf("github:%s/%s/%s", d.GitHub.Owner, d.GitHub.Repo, d.GitHub.Environment)
+	case d.GitHub != nil:
+		return fmt.Sprintf("github:%s/%s", d.GitHub.Owner, d.GitHub.Repo)
+	case d.Kubernetes != nil:
+		return fmt.Sprintf("kubernetes:%s/%s", d.Kubernetes.Cluster, d.Kubernetes.Namespace)
+	case d.GRPC != nil:
+		return fmt.Sprintf("grpc:%s", d.GRPC.Address)
+	}
+	if d.Region != "" {
+		return fmt.Sprintf("aws:%s/%s", d.AccountID, d.Region)
+	}
+	return fmt.Sprintf("aws:%s", d.AccountID)
+}
+
+// validateDestination checks a single destination; prefix identifies it in errors
+func (c *Config) validateDestination(prefix string, d Destination) error {
+	kinds := 0
+	for _, set := range []bool{d.Doppler != nil, d.GitHub != nil, d.Kubernetes != nil, d.GRPC != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("%s: only one of doppler, github, kubernetes or grpc may be set", prefix)
+	}
+
+	if d.GitHub != nil {
+		if err := d.GitHub.validate(); err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		if c.GitHub == nil {
+			return fmt.Errorf("%s: github destination requires top-level github app configuration", prefix)
+		}
+	}
+	if d.Doppler != nil && (d.Doppler.Project == "" || d.Doppler.Config == "") {
+		return fmt.Errorf("%s: doppler.project and doppler.config are required", prefix)
+	}
+	if d.GRPC != nil && d.GRPC.Address == "" {
+		return fmt.Errorf("%s: grpc.address is required", prefix)
+	}
+	if d.RotationOverlap < 0 {
+		return fmt.Errorf("%s: rotation_overlap must not be negative", prefix)
+	}
+	if d.RotationOverlap > 0 && (d.GitHub != nil || d.Kubernetes != nil || d.GRPC != nil) {
+		return fmt.Errorf("%s: rotation_overlap is only supported for aws and doppler destinations", prefix)
+	}
+	if d.Reference != "" {
+		if _, err := template.New("reference").Parse(d.Reference); err != nil {
+			return fmt.Errorf("%s: invalid reference template: %w", prefix, err)
+		}
+	}
+	if k := d.Kubernetes; k != nil {
+		switch k.Provider {
+		case "":
+		case "eks":
+			if k.Cluster == "" {
+				return fmt.Errorf("%s: kubernetes.cluster is required for eks", prefix)
+			}
+		case "gke":
+			if k.Cluster == "" || k.Project == "" || k.Location == "" {
+				return fmt.Errorf("%s: kubernetes.cluster, kubernetes.project and kubernetes.location are required for gke", prefix)
+			}
+		default:
+			return fmt.Errorf("%s: unsupported kubernetes.provider %q (must be eks or gke)", prefix, k.Provider)
+		}
+	}
+
+	if d.requiresAccountID() {
+		if d.AccountID == "" {
+			return fmt.Errorf("%s: account_id is required", prefix)
+		}
+		// Validate AWS account ID format (must be 12 digits)
+		if !isValidAWSAccountID(d.AccountID) {
+			return fmt.Errorf("%s: invalid account_id format %q (must be 12 digits)", prefix, d.AccountID)
+		}
+	}
+	return nil
+}
+
+// destinationSync builds the VaultSecretSync that writes a target's merged secrets
+// to one destination, returning it with the role it assumes (if any)
+func (p *Pipeline) destinationSync(targetName, sourcePath string, target Target, dest Destination, dryRun bool) (v1alpha1.VaultSecretSync, string) {
+	region := p.config.destinationRegion(target, dest)
+	roleARN := dest.RoleARN
+	if roleARN == "" && dest.requiresAccountID() {
+		roleARN = p.config.GetRoleARN(dest.AccountID)
+	}
+
+	var sync v1alpha1.VaultSecretSync
+	switch {
+	case dest.Doppler != nil:
+		roleARN = ""
+		sync = p.createDopplerSync(targetName, sourcePath, dest.Doppler, dryRun)
+	case dest.GitHub != nil:
+		roleARN = ""
+		sync = p.createGitHubSync(targetName, sourcePath, dest.GitHub, dryRun)
+	case dest.Kubernetes != nil:
+		// EKS clusters are reached through the destination account's role
+		if dest.Kubernetes.Provider != "eks" {
+			roleARN = ""
+		}
+		sync = p.createKubernetesSync(targetName, sourcePath, roleARN, region, dest.Kubernetes, dryRun)
+	case dest.GRPC != nil:
+		roleARN = ""
+		sync = p.createGRPCSync(targetName, sourcePath, dest.GRPC, dryRun)
+	default:
+		sync = p.createAWSSync(targetName, sourcePath, roleARN, region, dryRun)
+		if target.SecretPrefix != "" {
+			sync.Spec.Dest[0].AWS.Name = target.SecretPrefix + "$1"
+		}
+		sync.Spec.Dest[0].AWS.Tags = p.config.SecretTags(targetName)
+		if roleARN != "" {
+			sync.Spec.Dest[0].AWS.RoleChain = storeRoleChain(p.config.roleChain(target))
+			p.config.assumeRole(target).applyTo(sync.Spec.Dest[0].AWS, p.config.AWS.session, targetName, dest.AccountID, target.SecretPrefix)
+		}
+	}
+	if dest.RotationOverlap > 0 {
+		for _, sc := range sync.Spec.Dest {
+			switch {
+			case sc.AWS != nil:
+				sc.AWS.RotationOverlap = dest.RotationOverlap.String()
+			case sc.Doppler != nil:
+				sc.Doppler.RotationOverlap = dest.RotationOverlap.String()
+			}
+		}
+	}
+	if p.config.Pipeline.Sync.Verify {
+		sync.Spec.Verify = boolPtr(true)
+	}
+	sync.Spec.Transforms = dest.Transforms.spec()
+	if dest.Reference != "" {
+		if sync.Spec.Transforms == nil {
+			sync.Spec.Transforms = &v1alpha1.TransformSpec{}
+		}
+		ref := dest.Reference
+		sync.Spec.Transforms.Reference = &ref
+	}
+	target.Transforms.applyTo(&sync)
+	target.Transforms.applyName(&sync, targetName, target.SecretPrefix)
+	if target.Classification != "" {
+		sync.Labels = map[string]string{v1alpha1.ClassificationLabel: target.Classification}
+	}
+	setOwners(&sync, target.Owners)
+	return sync, roleARN
+}
+
+// setOwners records owners on a sync so its notifications can be addressed to them
+func setOwners(sync *v1alpha1.VaultSecretSync, owners []string) {
+	if len(owners) == 0 {
+		return
+	}
+	if sync.Annotations == nil {
+		sync.Annotations = map[string]string{}
+	}
+	sync.Annotations[v1alpha1.OwnersAnnotation] = strings.Join(owners, ",")
+}
+
+// spec converts destination transforms to the sync engine's transform spec
+func (t *DestinationTransforms) spec() *v1alpha1.TransformSpec {
+	if t == nil {
+		return nil
+	}
+	spec := &v1alpha1.TransformSpec{
+		Include: t.Include,
+		Exclude: t.Exclude,
+	}
+	from := make([]string, 0, len(t.Rename))
+	for k := range t.Rename {
+		from = append(from, k)
+	}
+	sort.Strings(from)
+	for _, k := range from {
+		spec.Rename = append(spec.Rename, v1alpha1.RenameTransform{From: k, To: t.Rename[k]})
+	}
+	if t.Template != "" {
+		tmpl := t.Template
+		spec.Template = &tmpl
+	}
+	if t.Wasm != "" {
+		wasm := t.Wasm
+		spec.Wasm = &wasm
+	}
+	return spec
+}
+
+// createDopplerSync creates a VaultSecretSync for syncing to a Doppler config
+func (p *Pipeline) createDopplerSync(targetName, sourcePath string, dest *DopplerDestination, dryRun bool) v1alpha1.VaultSecretSync {
+	sync := v1alpha1.VaultSecretSync{
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			DryRun:     boolPtr(dryRun),
+			SyncDelete: boolPtr(p.config.Pipeline.Sync.DeleteOrphans),
+			Source: &vault.VaultClient{
+				Address:   p.config.Vault.Address,
+				Namespace: p.config.Vault.Namespace,
+				Path:      fmt.Sprintf("%s/(.*)", sourcePath),
+			},
+			Dest: []*v1alpha1.StoreConfig{
+				{
+					Doppler: &doppler.DopplerClient{
+						Project: dest.Project,
+						Config:  dest.Config,
+						Token:   dest.Token,
+						Merge:   boolPtr(true),
+					},
+				},
+			},
+		},
+	}
+	sync.Name = fmt.Sprintf("sync-%s", targetName)
+	sync.Namespace = "pipeline"
+	return sync
+}
+
+// createGRPCSync creates a VaultSecretSync for syncing to a SecretStore gRPC server
+func (p *Pipeline) createGRPCSync(targetName, sourcePath string, dest *GRPCDestination, dryRun bool) v1alpha1.VaultSecretSync {
+	path := dest.Path
+	if path == "" {
+		path = "$1"
+	}
+	sync := v1alpha1.VaultSecretSync{
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			DryRun:     boolPtr(dryRun),
+			SyncDelete: boolPtr(p.config.Pipeline.Sync.DeleteOrphans),
+			Source: &vault.VaultClient{
+				Address:   p.config.Vault.Address,
+				Namespace: p.config.Vault.Namespace,
+				Path:      fmt.Sprintf("%s/(.*)", sourcePath),
+			},
+			Dest: []*v1alpha1.StoreConfig{
+				{
+					GRPC: &grpcstore.GRPCClient{
+						Address:    dest.Address,
+						Path:       path,
+						Insecure:   dest.Insecure,
+						CAFile:     dest.CAFile,
+						ServerName: dest.ServerName,
+						Metadata:   dest.Metadata,
+						Timeout:    dest.Timeout,
+					},
+				},
+			},
+		},
+	}
+	sync.Name = fmt.Sprintf("sync-%s", targetName)
+	sync.Namespace = "pipeline"
+	return sync
+}
@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTargetDestinationsYAML(t *testing.T) {
+	content := `
+imports: [analytics]
+destinations:
+  - account_id: "111111111111"
+    region: us-west-2
+  - name: doppler-prd
+    doppler:
+      project: analytics
+      config: prd
+      token: ${DOPPLER_TOKEN}
+    transforms:
+      exclude: [AWS_.*]
+      rename:
+        db_password: DB_PASSWORD
+  - github:
+      owner: acme
+      repo: analytics
+  - grpc:
+      address: secrets.internal:8443
+      metadata:
+        authorization: Bearer ${STORE_TOKEN}
+`
+	var target Target
+	require.NoError(t, yaml.Unmarshal([]byte(content), &target))
+	require.Len(t, target.Destinations, 4)
+
+	dests := target.ResolvedDestinations()
+	require.Len(t, dests, 4)
+	assert.Equal(t, "aws:111111111111/us-west-2", dests[0].Label())
+	assert.Equal(t, "doppler-prd", dests[1].Label())
+	assert.Equal(t, "github:acme/analytics", dests[2].Label())
+	assert.Equal(t, "grpc:secrets.internal:8443", dests[3].Label())
+	assert.False(t, dests[3].requiresAccountID())
+	assert.Equal(t, []string{"AWS_.*"}, dests[1].Transforms.Exclude)
+}
+
+func TestResolvedDestinationsShorthand(t *testing.T) {
+	target := Target{
+		AccountID: "111111111111",
+		Region:    "us-west-2",
+		Imports:   []string{"analytics"},
+	}
+	dests := target.ResolvedDestinations()
+	require.Len(t, dests, 1)
+	assert.Equal(t, "111111111111", dests[0].AccountID)
+	assert.Equal(t, "aws:111111111111", dests[0].Label())
+	assert.True(t, dests[0].requiresAccountID())
+
+	target = Target{GitHub: &GitHubDestination{Owner: "acme", Repo: "web"}}
+	dests = target.ResolvedDestinations()
+	require.Len(t, dests, 1)
+	assert.Equal(t, "github:acme/web", dests[0].Label())
+	assert.False(t, dests[0].requiresAccountID())
+}
+
+func TestDestinationTransformsSpec(t *testing.T) {
+	var nilTransforms *DestinationTransforms
+	assert.Nil(t, nilTransforms.spec())
+
+	spec := (&DestinationTransforms{
+		Include:  []string{"DB_.*"},
+		Rename:   map[string]string{"b": "B", "a": "A"},
+		Template: "{{ .DB_HOST }}",
+	}).spec()
+	require.NotNil(t, spec)
+	assert.Equal(t, []string{"DB_.*"}, spec.Include)
+	assert.Equal(t, []v1alpha1.RenameTransform{{From: "a", To: "A"}, {From: "b", To: "B"}}, spec.Rename)
+	require.NotNil(t, spec.Template)
+	assert.Equal(t, "{{ .DB_HOST }}", *spec.Template)
+}
+
+func TestDestinationReference(t *testing.T) {
+	cfg := &Config{Vault: VaultConfig{Address: "https://vault.example.com"}}
+	dest := Destination{
+		Doppler:   &DopplerDestination{Project: "analytics", Config: "prd"},
+		Reference: "vault:{{.Path}}#{{.Key}}",
+	}
+	require.NoError(t, cfg.validateDestination("destinations[0]", dest))
+
+	p := &Pipeline{config: cfg}
+	sync, _ := p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", Target{}, dest, false)
+	require.NotNil(t, sync.Spec.Transforms)
+	require.NotNil(t, sync.Spec.Transforms.Reference)
+	assert.Equal(t, "vault:{{.Path}}#{{.Key}}", *sync.Spec.Transforms.Reference)
+
+	dest.Reference = "vault:{{.Path"
+	assert.ErrorContains(t, cfg.validateDestination("destinations[0]", dest), "destinations[0]: invalid reference template")
+}
+
+func TestDestinationRotationOverlap(t *testing.T) {
+	cfg := &Config{AWS: AWSConfig{Region: "us-east-1"}}
+	p := &Pipeline{config: cfg}
+
+	dest := Destination{AccountID: "111111111111", RotationOverlap: 24 * time.Hour}
+	require.NoError(t, cfg.validateDestination("destinations[0]", dest))
+	sync, _ := p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", Target{}, dest, false)
+	assert.Equal(t, "24h0m0s", sync.Spec.Dest[0].AWS.RotationOverlap)
+
+	dest = Destination{Doppler: &DopplerDestination{Project: "analytics", Config: "prd"}, RotationOverlap: time.Hour}
+	require.NoError(t, cfg.validateDestination("destinations[0]", dest))
+	sync, _ = p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", Target{}, dest, false)
+	assert.Equal(t, "1h0m0s", sync.Spec.Dest[0].Doppler.RotationOverlap)
+
+	dest = Destination{GitHub: &GitHubDestination{Owner: "acme", Repo: "web"}, RotationOverlap: time.Hour}
+	cfg.GitHub = &GitHubConfig{}
+	assert.ErrorContains(t, cfg.validateDestination("destinations[0]", dest), "rotation_overlap is only supported for aws and doppler destinations")
+
+	dest = Destination{AccountID: "111111111111", RotationOverlap: -time.Hour}
+	assert.ErrorContains(t, cfg.validateDestination("destinations[0]", dest), "must not be negative")
+}
+
+func TestGitHubDestinationScopes(t *testing.T) {
+	cfg := &Config{GitHub: &GitHubConfig{AppID: 1}}
+	p := &Pipeline{config: cfg}
+
+	env := Destination{GitHub: &GitHubDestination{Owner: "acme", Repo: "web", Environment: "prod"}}
+	require.NoError(t, cfg.validateDestination("destinations[0]", env))
+	assert.Equal(t, "github:acme/web/prod", env.Label())
+	sync, _ := p.destinationSync("Web", "merged/Web", Target{}, env, false)
+	assert.Equal(t, "prod", sync.Spec.Dest[0].GitHub.Env)
+	assert.False(t, sync.Spec.Dest[0].GitHub.Org)
+
+	org := Destination{GitHub: &GitHubDestination{Owner: "acme", Visibility: "selected", Repositories: []string{"api", "web"}}}
+	require.NoError(t, cfg.validateDestination("destinations[1]", org))
+	assert.Equal(t, "github:acme", org.Label())
+	sync, _ = p.destinationSync("Web", "merged/Web", Target{}, org, false)
+	client := sync.Spec.Dest[0].GitHub
+	assert.True(t, client.Org)
+	assert.Equal(t, "selected", client.Visibility)
+	assert.Equal(t, []string{"api", "web"}, client.SelectedRepos)
+	require.NoError(t, client.Validate())
+
+	for dest, msg := range map[*GitHubDestination]string{
+		{Owner: "acme"}: "github.repo is required, or github.visibility for organization secrets",
+		{Owner: "acme", Environment: "prod", Visibility: "all"}:           "github.environment requires github.repo",
+		{Owner: "acme", Repo: "web", Visibility: "private"}:               "github.visibility and github.repositories only apply to organization secrets",
+		{Owner: "acme", Visibility: "selected"}:                           "github.visibility selected requires github.repositories",
+		{Owner: "acme", Visibility: "all", Repositories: []string{"web"}}: "github.repositories requires github.visibility selected",
+		{Owner: "acme", Visibility: "internal"}:                           `github.visibility must be all, private or selected, got "internal"`,
+	} {
+		assert.ErrorContains(t, cfg.validateDestination("destinations[0]", Destination{GitHub: dest}), "destinations[0]: "+msg)
+	}
+}
@@ -2,13 +2,22 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/identitystore"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
 	log "github.com/sirupsen/logrus"
 )
@@ -18,15 +27,33 @@ type DiscoveryService struct {
 	ctx     context.Context
 	awsCtx  *AWSExecutionContext
 	config  *Config
+	sources map[string]Source
+	// origins maps discovered account targets to their dynamic target
+	origins map[string]string
+
+	// cache holds Organizations and Identity Center results when
+	// aws.discovery_cache is set
+	cache *discoveryCache
 }
 
 // NewDiscoveryService creates a new discovery service
 func NewDiscoveryService(ctx context.Context, awsCtx *AWSExecutionContext, cfg *Config) *DiscoveryService {
-	return &DiscoveryService{
-		ctx:    ctx,
-		awsCtx: awsCtx,
-		config: cfg,
+	d := &DiscoveryService{
+		ctx:     ctx,
+		awsCtx:  awsCtx,
+		config:  cfg,
+		sources: make(map[string]Source),
+		origins: make(map[string]string),
+	}
+	if settings := cfg.AWS.DiscoveryCache; settings != nil && awsCtx != nil {
+		d.cache = sharedDiscoveryCache(settings, awsCtx)
 	}
+	return d
+}
+
+// DiscoveredSources returns sources created during discovery (e.g. one per Doppler config)
+func (d *DiscoveryService) DiscoveredSources() map[string]Source {
+	return d.sources
 }
 
 // DiscoverTargets discovers and expands dynamic targets into concrete targets
@@ -42,12 +69,70 @@ func (d *DiscoveryService) DiscoverTargets() (map[string]Target, error) {
 		l := l.WithField("dynamicTarget", dynamicName)
 		l.Debug("Processing dynamic target")
 
+		// Discover from Doppler project configs (targets and sources are mapped directly)
+		if dynamicTarget.Discovery.Doppler != nil {
+			targets, sources, err := d.discoverFromDoppler(dynamicName, dynamicTarget)
+			if err != nil {
+				l.WithError(err).Warn("Failed to discover from Doppler")
+				continue
+			}
+			for name, target := range targets {
+				discoveredTargets[name] = target
+			}
+			for name, src := range sources {
+				d.sources[name] = src
+			}
+		}
+
+		// Discover from GitHub org repositories (GitHub destination targets)
+		if dynamicTarget.Discovery.GitHub != nil {
+			targets, err := d.discoverFromGitHub(dynamicTarget)
+			if err != nil {
+				l.WithError(err).Warn("Failed to discover from GitHub")
+				continue
+			}
+			for name, target := range targets {
+				if _, exists := discoveredTargets[name]; exists {
+					l.WithField("target", name).Warn("Discovered GitHub target name already in use, skipping")
+					continue
+				}
+				discoveredTargets[name] = target
+			}
+		}
+
+		// Discover EKS/GKE clusters (Kubernetes destination targets)
+		if dynamicTarget.Discovery.Kubernetes != nil {
+			targets, err := d.discoverFromKubernetes(dynamicName, dynamicTarget)
+			if err != nil {
+				l.WithError(err).Warn("Failed to discover Kubernetes clusters")
+				continue
+			}
+			for name, target := range targets {
+				if _, exists := discoveredTargets[name]; exists {
+					l.WithField("target", name).Warn("Discovered Kubernetes target name already in use, skipping")
+					continue
+				}
+				discoveredTargets[name] = target
+			}
+		}
+
+		discovery := dynamicTarget.Discovery
+		if discovery.IdentityCenter == nil && discovery.Organizations == nil && discovery.AccountsList == nil {
+			continue
+		}
+		if d.awsCtx == nil {
+			l.Warn("AWS execution context unavailable, skipping account discovery")
+			continue
+		}
+
 		var accounts []AccountInfo
 		var err error
 
 		// Discover from Identity Center
-		if dynamicTarget.Discovery.IdentityCenter != nil {
-			accounts, err = d.discoverFromIdentityCenter(dynamicTarget.Discovery.IdentityCenter)
+		if ic := dynamicTarget.Discovery.IdentityCenter; ic != nil {
+			accounts, err = d.cachedAccounts("identity_center", ic, func() ([]AccountInfo, error) {
+				return d.discoverFromIdentityCenter(ic)
+			})
 			if err != nil {
 				l.WithError(err).Warn("Failed to discover from Identity Center")
 				continue
@@ -55,8 +140,10 @@ func (d *DiscoveryService) DiscoverTargets() (map[string]Target, error) {
 		}
 
 		// Discover from Organizations
-		if dynamicTarget.Discovery.Organizations != nil {
-			orgAccounts, err := d.discoverFromOrganizations(dynamicTarget.Discovery.Organizations)
+		if org := dynamicTarget.Discovery.Organizations; org != nil {
+			orgAccounts, err := d.cachedAccounts("organizations", org, func() ([]AccountInfo, error) {
+				return d.discoverFromOrganizations(org)
+			})
 			if err != nil {
 				l.WithError(err).Warn("Failed to discover from Organizations")
 				continue
@@ -85,41 +172,22 @@ func (d *DiscoveryService) DiscoverTargets() (map[string]Target, error) {
 				continue
 			}
 
-			// Create target name from account name or ID
-			targetName := sanitizeTargetName(acct.Name)
-			if targetName == "" {
-				targetName = fmt.Sprintf("account_%s", acct.ID)
-			}
-
-			// Ensure uniqueness by appending account ID suffix
+			// Name the target after the account, appending an account ID
+			// suffix to keep names unique
+			targetName := accountTargetName(acct)
 			if _, exists := discoveredTargets[targetName]; exists {
 				targetName = fmt.Sprintf("%s_%s", targetName, acct.ID[:6])
 			}
 
-			// Apply dynamic target options with fallbacks to config defaults
-			region := dynamicTarget.Region
-			if region == "" {
-				region = d.config.AWS.Region
-			}
-
-			// Process role ARN template (supports {{.AccountID}})
-			roleARN := dynamicTarget.RoleARN
-			if roleARN != "" {
-				roleARN = strings.ReplaceAll(roleARN, "{{.AccountID}}", acct.ID)
-			}
-
-			discoveredTargets[targetName] = Target{
-				AccountID:    acct.ID,
-				Imports:      dynamicTarget.Imports,
-				Region:       region,
-				SecretPrefix: dynamicTarget.SecretPrefix,
-				RoleARN:      roleARN,
-			}
+			target := d.accountTarget(dynamicTarget, acct)
+			discoveredTargets[targetName] = target
+			d.origins[targetName] = dynamicName
 
 			l.WithFields(log.Fields{
 				"targetName": targetName,
 				"accountID":  acct.ID,
-				"region":     region,
+				"region":     target.Region,
+				"ou":         target.OU,
 			}).Debug("Discovered target")
 		}
 	}
@@ -128,6 +196,45 @@ func (d *DiscoveryService) DiscoverTargets() (map[string]Target, error) {
 	return discoveredTargets, nil
 }
 
+// accountTargetName names an account's target after the account, or its ID
+func accountTargetName(acct AccountInfo) string {
+	if name := sanitizeTargetName(acct.Name); name != "" {
+		return name
+	}
+	return fmt.Sprintf("account_%s", acct.ID)
+}
+
+// accountTarget builds the target a dynamic target discovers for an account
+func (d *DiscoveryService) accountTarget(dynamicTarget DynamicTarget, acct AccountInfo) Target {
+	// Process role ARN template (supports {{.AccountID}})
+	roleARN := dynamicTarget.RoleARN
+	if roleARN != "" {
+		roleARN = strings.ReplaceAll(roleARN, "{{.AccountID}}", acct.ID)
+	}
+
+	target := Target{
+		AccountID:      acct.ID,
+		Imports:        dynamicTarget.Imports,
+		Region:         dynamicTarget.Region,
+		SecretPrefix:   dynamicTarget.SecretPrefix,
+		RoleARN:        roleARN,
+		Classification: dynamicTarget.Classification,
+		Owners:         dynamicTarget.Owners,
+		Tags:           dynamicTarget.Tags,
+		Labels:         dynamicTarget.Labels,
+		RoleChain:      dynamicTarget.RoleChain,
+		AssumeRole:     dynamicTarget.AssumeRole,
+		Preconditions:  preconditionsFor(dynamicTarget.Preconditions, acct.ID),
+	}
+
+	// Apply dynamic target options with fallbacks to OU and config defaults
+	target = withOUDefaults(target, d.config.ouPath(acct.OU, acct.ID))
+	if target.Region == "" {
+		target.Region = d.config.AWS.Region
+	}
+	return target
+}
+
 // discoverFromIdentityCenter discovers accounts from AWS Identity Center
 func (d *DiscoveryService) discoverFromIdentityCenter(cfg *IdentityCenterDiscovery) ([]AccountInfo, error) {
 	l := log.WithFields(log.Fields{
@@ -225,7 +332,7 @@ func (d *DiscoveryService) discoverFromOrganizations(cfg *OrganizationsDiscovery
 			if err != nil {
 				return nil, err
 			}
-			accounts = append(accounts, ouAccounts...)
+			accounts = append(accounts, inOU(ouAccounts, cfg.OU)...)
 		}
 	}
 
@@ -240,6 +347,13 @@ func (d *DiscoveryService) discoverFromOrganizations(cfg *OrganizationsDiscovery
 
 	// Filter by tags if specified
 	if len(cfg.Tags) > 0 {
+		for i := range accounts {
+			tags, err := d.awsCtx.AccountTags(d.ctx, accounts[i].ID)
+			if err != nil {
+				return nil, err
+			}
+			accounts[i].Tags = tags
+		}
 		accounts = filterAccountsByTags(accounts, cfg.Tags)
 	}
 
@@ -256,7 +370,7 @@ func (d *DiscoveryService) listAccountsInOURecursive(ouID string) ([]AccountInfo
 	if err != nil {
 		return nil, fmt.Errorf("failed to list accounts in OU %s: %w", ouID, err)
 	}
-	accounts = append(accounts, ouAccounts...)
+	accounts = append(accounts, inOU(ouAccounts, ouID)...)
 
 	// Get child OUs and recurse
 	childOUs, err := d.awsCtx.ListChildOUs(d.ctx, ouID)
@@ -278,7 +392,8 @@ func (d *DiscoveryService) listAccountsInOURecursive(ouID string) ([]AccountInfo
 	return accounts, nil
 }
 
-// discoverFromAccountsList discovers accounts from an external source (e.g., SSM Parameter Store)
+// discoverFromAccountsList discovers accounts from an external source: an SSM
+// Parameter Store parameter, a DynamoDB table or an HTTPS endpoint
 func (d *DiscoveryService) discoverFromAccountsList(cfg *AccountsListDiscovery) ([]AccountInfo, error) {
 	l := log.WithFields(log.Fields{
 		"action": "discoverFromAccountsList",
@@ -286,29 +401,33 @@ func (d *DiscoveryService) discoverFromAccountsList(cfg *AccountsListDiscovery)
 	})
 	l.Debug("Discovering accounts from external list")
 
-	// Parse the source - currently supports SSM Parameter Store
-	if strings.HasPrefix(cfg.Source, "ssm:") {
-		paramName := strings.TrimPrefix(cfg.Source, "ssm:")
-		return d.getAccountsFromSSM(paramName)
+	switch {
+	case strings.HasPrefix(cfg.Source, "ssm:"):
+		return d.getAccountsFromSSM(cfg)
+	case strings.HasPrefix(cfg.Source, "dynamodb:"):
+		return d.getAccountsFromDynamoDB(cfg)
+	case strings.HasPrefix(cfg.Source, "https://"):
+		return fetchAccountsList(d.ctx, accountsListHTTPClient, cfg)
 	}
 
-	return nil, fmt.Errorf("unsupported accounts list source: %s (supported: ssm:)", cfg.Source)
+	return nil, fmt.Errorf("unsupported accounts list source: %s (supported: ssm:, dynamodb:, https://)", cfg.Source)
 }
 
-// getAccountsFromSSM retrieves account IDs from an SSM Parameter Store parameter.
-// The parameter value can be:
-//   - A comma-separated list of account IDs: "111111111111,222222222222,333333333333"
-//   - A JSON array: ["111111111111","222222222222","333333333333"]
-//   - A JSON array of objects: [{"id": "111111111111", "name": "Account1"}, ...]
-func (d *DiscoveryService) getAccountsFromSSM(paramName string) ([]AccountInfo, error) {
+// getAccountsFromSSM retrieves account IDs from an SSM Parameter Store parameter,
+// read as cfg.RoleARN when set. The parameter may be given by ARN, which is read
+// in the ARN's region, e.g. one in the management account. Its value is parsed
+// by parseAccountsList.
+func (d *DiscoveryService) getAccountsFromSSM(cfg *AccountsListDiscovery) ([]AccountInfo, error) {
+	paramName := strings.TrimPrefix(cfg.Source, "ssm:")
 	l := log.WithFields(log.Fields{
-		"action": "getAccountsFromSSM",
-		"param":  paramName,
+		"action":  "getAccountsFromSSM",
+		"param":   paramName,
+		"roleARN": cfg.RoleARN,
 	})
 	l.Debug("Fetching accounts from SSM Parameter Store")
 
 	// Get parameter value
-	value, err := d.awsCtx.GetSSMParameter(d.ctx, paramName)
+	value, err := d.awsCtx.GetSSMParameterAs(d.ctx, paramName, arnRegion(paramName), cfg.RoleARN)
 	if err != nil {
 		return nil, err
 	}
@@ -318,50 +437,11 @@ func (d *DiscoveryService) getAccountsFromSSM(paramName string) ([]AccountInfo,
 		return nil, fmt.Errorf("SSM parameter %s is empty", paramName)
 	}
 
-	var accounts []AccountInfo
-
-	// Try to parse as JSON array first
-	if strings.HasPrefix(value, "[") {
-		// Try as array of objects with id/name fields
-		var objArray []struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-		}
-		if err := json.Unmarshal([]byte(value), &objArray); err == nil && len(objArray) > 0 && objArray[0].ID != "" {
-			for _, obj := range objArray {
-				accounts = append(accounts, AccountInfo{
-					ID:   obj.ID,
-					Name: obj.Name,
-				})
-			}
-			l.WithField("count", len(accounts)).Debug("Parsed SSM parameter as JSON object array")
-			return accounts, nil
-		}
-
-		// Try as simple string array
-		var strArray []string
-		if err := json.Unmarshal([]byte(value), &strArray); err == nil {
-			for _, id := range strArray {
-				id = strings.TrimSpace(id)
-				if id != "" {
-					accounts = append(accounts, AccountInfo{ID: id})
-				}
-			}
-			l.WithField("count", len(accounts)).Debug("Parsed SSM parameter as JSON string array")
-			return accounts, nil
-		}
-	}
-
-	// Fall back to comma-separated list
-	parts := strings.Split(value, ",")
-	for _, part := range parts {
-		id := strings.TrimSpace(part)
-		if id != "" {
-			accounts = append(accounts, AccountInfo{ID: id})
-		}
+	accounts, err := parseAccountsList([]byte(value), cfg.idAttribute(), cfg.nameAttribute())
+	if err != nil {
+		return nil, fmt.Errorf("SSM parameter %s: %w", paramName, err)
 	}
-
-	l.WithField("count", len(accounts)).Debug("Parsed SSM parameter as comma-separated list")
+	l.WithField("count", len(accounts)).Debug("Parsed SSM parameter")
 	return accounts, nil
 }
 
@@ -388,7 +468,7 @@ func (d *DiscoveryService) findGroupByName(client *identitystore.Client, storeID
 }
 
 // getAccountsForGroup gets AWS accounts assigned to an Identity Center group
-func (d *DiscoveryService) getAccountsForGroup(client *ssoadmin.Client, instanceARN, groupID string) ([]AccountInfo, error) {
+func (d *DiscoveryService) getAccountsForGroup(client ssoAdminAPI, instanceARN, groupID string) ([]AccountInfo, error) {
 	var accounts []AccountInfo
 	seen := make(map[string]bool)
 
@@ -450,7 +530,7 @@ func (d *DiscoveryService) getAccountsForGroup(client *ssoadmin.Client, instance
 }
 
 // getAccountsWithPermissionSet gets accounts with a specific permission set
-func (d *DiscoveryService) getAccountsWithPermissionSet(client *ssoadmin.Client, instanceARN, permissionSetName string) ([]AccountInfo, error) {
+func (d *DiscoveryService) getAccountsWithPermissionSet(client ssoAdminAPI, instanceARN, permissionSetName string) ([]AccountInfo, error) {
 	// First, find the permission set ARN by name
 	var permissionSetARN string
 	paginator := ssoadmin.NewListPermissionSetsPaginator(client, &ssoadmin.ListPermissionSetsInput{
@@ -536,6 +616,14 @@ func sanitizeTargetName(name string) string {
 	return result.String()
 }
 
+// inOU records ouID as the parent OU of accounts
+func inOU(accounts []AccountInfo, ouID string) []AccountInfo {
+	for i := range accounts {
+		accounts[i].OU = ouID
+	}
+	return accounts
+}
+
 func deduplicateAccounts(accounts []AccountInfo) []AccountInfo {
 	seen := make(map[string]bool)
 	var result []AccountInfo
@@ -585,6 +673,18 @@ func ExpandDynamicTargets(ctx context.Context, cfg *Config, awsCtx *AWSExecution
 		return fmt.Errorf("failed to discover dynamic targets: %w", err)
 	}
 
+	// Merge discovered sources (e.g. Doppler configs) with static sources
+	if cfg.Sources == nil {
+		cfg.Sources = make(map[string]Source)
+	}
+	for name, src := range discovery.DiscoveredSources() {
+		if _, exists := cfg.Sources[name]; !exists {
+			cfg.Sources[name] = src
+		} else {
+			l.WithField("source", name).Warn("Discovered source name conflicts with static source, skipping")
+		}
+	}
+
 	// Merge discovered targets with static targets
 	if cfg.Targets == nil {
 		cfg.Targets = make(map[string]Target)
@@ -594,6 +694,12 @@ func ExpandDynamicTargets(ctx context.Context, cfg *Config, awsCtx *AWSExecution
 		// Don't overwrite static targets
 		if _, exists := cfg.Targets[name]; !exists {
 			cfg.Targets[name] = target
+			if origin, ok := discovery.origins[name]; ok {
+				if cfg.discoveredFrom == nil {
+					cfg.discoveredFrom = make(map[string]string)
+				}
+				cfg.discoveredFrom[name] = origin
+			}
 		} else {
 			l.WithField("target", name).Warn("Dynamic target name conflicts with static target, skipping")
 		}
@@ -602,3 +708,319 @@ func ExpandDynamicTargets(ctx context.Context, cfg *Config, awsCtx *AWSExecution
 	l.WithField("totalTargets", len(cfg.Targets)).Info("Dynamic targets expanded")
 	return nil
 }
+
+// DefaultDiscoveryCacheTTL is how long discovered accounts are reused when
+// aws.discovery_cache sets no ttl
+const DefaultDiscoveryCacheTTL = time.Hour
+
+// DiscoveryCacheSettings caches the accounts found by Organizations and
+// Identity Center discovery, so runs do not re-walk every OU and permission
+// set. Entries are kept in memory for the life of the process and, with file
+// or s3, shared between runs.
+//
+//	aws:
+//	  discovery_cache:
+//	    ttl: 6h
+//	    s3:
+//	      bucket: vss-state
+//	      key: discovery-cache.json
+type DiscoveryCacheSettings struct {
+	// TTL is how long discovered accounts are reused (default 1h)
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl,omitempty"`
+	// File keeps the cache in a local JSON file
+	File string `mapstructure:"file" yaml:"file,omitempty"`
+	// S3 keeps the cache in an S3 object
+	S3 *DiscoveryCacheS3 `mapstructure:"s3" yaml:"s3,omitempty"`
+
+	// Refresh ignores cached entries and rediscovers, caching the new results
+	// (set by --refresh-discovery)
+	Refresh bool `mapstructure:"-" yaml:"-"`
+}
+
+// DiscoveryCacheS3 is the S3 object holding the discovery cache
+type DiscoveryCacheS3 struct {
+	Bucket   string `mapstructure:"bucket" yaml:"bucket"`
+	Key      string `mapstructure:"key" yaml:"key"`
+	KMSKeyID string `mapstructure:"kms_key_id" yaml:"kms_key_id,omitempty"`
+}
+
+func (s *DiscoveryCacheSettings) validate() error {
+	if s.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	if s.File != "" && s.S3 != nil {
+		return fmt.Errorf("file and s3 are mutually exclusive")
+	}
+	if s.S3 != nil && (s.S3.Bucket == "" || s.S3.Key == "") {
+		return fmt.Errorf("s3.bucket and s3.key are required")
+	}
+	return nil
+}
+
+func (s *DiscoveryCacheSettings) ttl() time.Duration {
+	if s.TTL == 0 {
+		return DefaultDiscoveryCacheTTL
+	}
+	return s.TTL
+}
+
+// discoveryCacheEntry holds the accounts one discovery query returned
+type discoveryCacheEntry struct {
+	Accounts   []AccountInfo `json:"accounts"`
+	Discovered time.Time     `json:"discovered"`
+}
+
+// discoveryCacheStore persists the discovery cache between runs
+type discoveryCacheStore interface {
+	load(ctx context.Context) (map[string]discoveryCacheEntry, error)
+	save(ctx context.Context, entries map[string]discoveryCacheEntry) error
+}
+
+// discoveryCache reuses discovered accounts until they are older than a TTL.
+// Entries live in memory; a store, when there is one, is read on first use
+// and rewritten whenever an entry is added.
+type discoveryCache struct {
+	store discoveryCacheStore
+	now   func() time.Time
+
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]discoveryCacheEntry
+}
+
+func newDiscoveryCache(store discoveryCacheStore) *discoveryCache {
+	return &discoveryCache{
+		store:   store,
+		now:     time.Now,
+		entries: make(map[string]discoveryCacheEntry),
+	}
+}
+
+var (
+	discoveryCachesMu sync.Mutex
+	// discoveryCaches are the process's caches by location, so pipelines
+	// created by one process share their entries
+	discoveryCaches = make(map[string]*discoveryCache)
+)
+
+// sharedDiscoveryCache returns the process's cache for the settings' location
+func sharedDiscoveryCache(settings *DiscoveryCacheSettings, awsCtx *AWSExecutionContext) *discoveryCache {
+	location := "memory"
+	var store discoveryCacheStore
+	switch {
+	case settings.File != "":
+		location = settings.File
+		store = fileDiscoveryCacheStore{path: settings.File}
+	case settings.S3 != nil:
+		location = fmt.Sprintf("s3://%s/%s", settings.S3.Bucket, settings.S3.Key)
+		store = &s3DiscoveryCacheStore{
+			location: *settings.S3,
+			client:   s3.NewFromConfig(awsCtx.BaseConfig),
+		}
+	}
+
+	discoveryCachesMu.Lock()
+	defer discoveryCachesMu.Unlock()
+	c, ok := discoveryCaches[location]
+	if !ok {
+		c = newDiscoveryCache(store)
+		discoveryCaches[location] = c
+	}
+	return c
+}
+
+// load reads the store once, keeping entries already in memory
+func (c *discoveryCache) load(ctx context.Context) {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	if c.store == nil {
+		return
+	}
+	entries, err := c.store.load(ctx)
+	if err != nil {
+		log.WithError(err).WithField("action", "discoveryCache.load").Warn("Failed to load discovery cache, discovering again")
+		return
+	}
+	for key, e := range entries {
+		if _, ok := c.entries[key]; !ok {
+			c.entries[key] = e
+		}
+	}
+}
+
+// get returns the accounts cached for key if they are younger than ttl
+func (c *discoveryCache) get(ctx context.Context, key string, ttl time.Duration) ([]AccountInfo, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load(ctx)
+	e, ok := c.entries[key]
+	if !ok || c.now().Sub(e.Discovered) > ttl {
+		return nil, time.Time{}, false
+	}
+	return e.Accounts, e.Discovered, true
+}
+
+// put caches the accounts for key, dropping entries older than ttl. Failing
+// to persist the cache only costs the next run a rediscovery.
+func (c *discoveryCache) put(ctx context.Context, key string, accounts []AccountInfo, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load(ctx)
+	now := c.now()
+	c.entries[key] = discoveryCacheEntry{Accounts: accounts, Discovered: now}
+	for k, e := range c.entries {
+		if now.Sub(e.Discovered) > ttl {
+			delete(c.entries, k)
+		}
+	}
+	if c.store == nil {
+		return
+	}
+	if err := c.store.save(ctx, c.entries); err != nil {
+		log.WithError(err).WithField("action", "discoveryCache.put").Warn("Failed to save discovery cache")
+	}
+}
+
+// discoveryCacheKey identifies a discovery query within the organization
+// being walked, so caches shared between organizations do not mix
+func (d *DiscoveryService) discoveryCacheKey(kind string, query interface{}) (string, error) {
+	b, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	scope := "unknown"
+	switch {
+	case d.awsCtx.OrganizationInfo != nil && d.awsCtx.OrganizationInfo.ID != "":
+		scope = d.awsCtx.OrganizationInfo.ID
+	case d.awsCtx.CallerIdentity != nil:
+		scope = d.awsCtx.CallerIdentity.AccountID
+	}
+	return fmt.Sprintf("%s/%s:%s", scope, kind, b), nil
+}
+
+// cachedAccounts returns the query's cached accounts, or runs discover and
+// caches what it returns. Failed discoveries are not cached.
+func (d *DiscoveryService) cachedAccounts(kind string, query interface{}, discover func() ([]AccountInfo, error)) ([]AccountInfo, error) {
+	if d.cache == nil {
+		return discover()
+	}
+	settings := d.config.AWS.DiscoveryCache
+	key, err := d.discoveryCacheKey(kind, query)
+	if err != nil {
+		return discover()
+	}
+	l := log.WithFields(log.Fields{
+		"action": "DiscoveryService.cachedAccounts",
+		"kind":   kind,
+	})
+	if !settings.Refresh {
+		if accounts, discovered, ok := d.cache.get(d.ctx, key, settings.ttl()); ok {
+			l.WithFields(log.Fields{
+				"count": len(accounts),
+				"age":   d.cache.now().Sub(discovered).Round(time.Second),
+			}).Info("Using cached discovery results")
+			return accounts, nil
+		}
+	}
+	accounts, err := discover()
+	if err != nil {
+		return nil, err
+	}
+	d.cache.put(d.ctx, key, accounts, settings.ttl())
+	return accounts, nil
+}
+
+// fileDiscoveryCacheStore keeps the discovery cache in a local JSON file
+type fileDiscoveryCacheStore struct {
+	path string
+}
+
+func (f fileDiscoveryCacheStore) load(ctx context.Context) (map[string]discoveryCacheEntry, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read discovery cache: %w", err)
+	}
+	var entries map[string]discoveryCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery cache %s: %w", f.path, err)
+	}
+	return entries, nil
+}
+
+func (f fileDiscoveryCacheStore) save(ctx context.Context, entries map[string]discoveryCacheEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create discovery cache directory: %w", err)
+	}
+	// Write and rename so concurrent runs never read a partial file
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write discovery cache: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write discovery cache: %w", err)
+	}
+	return nil
+}
+
+// s3DiscoveryCacheStore keeps the discovery cache in an S3 object
+type s3DiscoveryCacheStore struct {
+	location DiscoveryCacheS3
+	client   *s3.Client
+}
+
+func (s *s3DiscoveryCacheStore) load(ctx context.Context) (map[string]discoveryCacheEntry, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.location.Bucket),
+		Key:    aws.String(s.location.Key),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discovery cache: %w", err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read discovery cache: %w", err)
+	}
+	var entries map[string]discoveryCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery cache: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *s3DiscoveryCacheStore) save(ctx context.Context, entries map[string]discoveryCacheEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.location.Bucket),
+		Key:         aws.String(s.location.Key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if s.location.KMSKeyID != "" {
+		input.ServerSideEncryption = "aws:kms"
+		input.SSEKMSKeyId = aws.String(s.location.KMSKeyID)
+	} else {
+		input.ServerSideEncryption = "AES256"
+	}
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put discovery cache: %w", err)
+	}
+	return nil
+}
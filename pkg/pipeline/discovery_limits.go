@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// knownTargetsNamespace is where the discovered account targets that runs
+// have seen are kept in the merge store
+const knownTargetsNamespace = "_vss_known_targets"
+
+// DiscoveryLimits bound how far account discovery may expand a run, so an
+// Organizations query that matches too much cannot push secrets into
+// hundreds of unintended accounts. Runs that would exceed them fail unless
+// --allow-new-targets covers the new targets.
+//
+//	pipeline:
+//	  discovery_limits:
+//	    max_new_targets: 10
+//	    max_targets: 200
+type DiscoveryLimits struct {
+	// MaxNewTargets is how many discovered account targets that no earlier
+	// run has seen one run may add (0 for no limit)
+	MaxNewTargets int `mapstructure:"max_new_targets" yaml:"max_new_targets,omitempty"`
+	// MaxTargets is how many account targets discovery may find in total
+	// (0 for no limit)
+	MaxTargets int `mapstructure:"max_targets" yaml:"max_targets,omitempty"`
+}
+
+func (l *DiscoveryLimits) validate() error {
+	if l.MaxNewTargets < 0 {
+		return fmt.Errorf("max_new_targets must not be negative")
+	}
+	if l.MaxTargets < 0 {
+		return fmt.Errorf("max_targets must not be negative")
+	}
+	return nil
+}
+
+// DiscoveryLimitError is returned by Run when discovery exceeds
+// pipeline.discovery_limits by more new targets than Options.AllowNewTargets
+type DiscoveryLimitError struct {
+	// New are the discovered targets no earlier run has seen
+	New []string
+	// Total is how many account targets discovery found
+	Total  int
+	Limits DiscoveryLimits
+}
+
+func (e *DiscoveryLimitError) Error() string {
+	var exceeded []string
+	if e.Limits.MaxNewTargets > 0 && len(e.New) > e.Limits.MaxNewTargets {
+		exceeded = append(exceeded, fmt.Sprintf("%d new targets (max_new_targets %d)", len(e.New), e.Limits.MaxNewTargets))
+	}
+	if e.Limits.MaxTargets > 0 && e.Total > e.Limits.MaxTargets {
+		exceeded = append(exceeded, fmt.Sprintf("%d targets in total (max_targets %d)", e.Total, e.Limits.MaxTargets))
+	}
+	names := e.New
+	if len(names) > 10 {
+		names = append(names[:10:10], fmt.Sprintf("and %d more", len(e.New)-10))
+	}
+	return fmt.Sprintf("discovery exceeds pipeline.discovery_limits with %s; new targets: %s. Check the discovery queries, then rerun with --allow-new-targets %d if these targets are intended",
+		strings.Join(exceeded, " and "), strings.Join(names, ", "), len(e.New))
+}
+
+// checkDiscoveryLimits fails the run when discovery exceeds the limits by more
+// new targets than allowNew. With record set, the new targets are recorded as
+// known so later runs do not count them again.
+func (p *Pipeline) checkDiscoveryLimits(ctx context.Context, targets []string, allowNew int, record bool, now time.Time) error {
+	limits := p.config.Pipeline.DiscoveryLimits
+	if limits == nil || len(p.config.discoveredFrom) == 0 {
+		return nil
+	}
+
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return err
+	}
+	names, err := store.ListSecrets(ctx, knownTargetsNamespace)
+	if err != nil {
+		return fmt.Errorf("failed to list known targets: %w", err)
+	}
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+
+	var newTargets []string
+	for _, name := range targets {
+		if _, ok := p.config.discoveredFrom[name]; ok && !known[name] {
+			newTargets = append(newTargets, name)
+		}
+	}
+	sort.Strings(newTargets)
+	total := len(p.config.discoveredFrom)
+
+	exceeded := (limits.MaxNewTargets > 0 && len(newTargets) > limits.MaxNewTargets) ||
+		(limits.MaxTargets > 0 && total > limits.MaxTargets)
+	if exceeded && len(newTargets) > 0 {
+		if len(newTargets) > allowNew {
+			return &DiscoveryLimitError{New: newTargets, Total: total, Limits: *limits}
+		}
+		log.WithFields(log.Fields{
+			"action":          "Pipeline.checkDiscoveryLimits",
+			"audit":           true,
+			"targets":         newTargets,
+			"total":           total,
+			"allowNewTargets": allowNew,
+		}).Warn("Discovery limits overridden with --allow-new-targets")
+	}
+
+	if !record {
+		return nil
+	}
+	for _, name := range newTargets {
+		data := map[string]interface{}{
+			"dynamic_target": p.config.discoveredFrom[name],
+			"account_id":     p.config.Targets[name].AccountID,
+			"first_seen":     now.UTC().Format(time.RFC3339),
+		}
+		if err := store.WriteSecret(ctx, knownTargetsNamespace, name, data); err != nil {
+			return fmt.Errorf("failed to record known target %s: %w", name, err)
+		}
+	}
+	return nil
+}
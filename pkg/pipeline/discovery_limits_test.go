@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDiscoveryLimits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := memMergeStore{}
+	p := &Pipeline{
+		config: &Config{
+			Pipeline: PipelineSettings{DiscoveryLimits: &DiscoveryLimits{MaxNewTargets: 1, MaxTargets: 3}},
+			Targets: map[string]Target{
+				"Sandbox_A": {AccountID: "111111111111"},
+				"Sandbox_B": {AccountID: "222222222222"},
+				"Prod":      {AccountID: "444444444444"},
+			},
+			discoveredFrom: map[string]string{
+				"Sandbox_A": "sandboxes",
+				"Sandbox_B": "sandboxes",
+			},
+		},
+		memStore: memDirectStore{store},
+	}
+	targets := []string{"Prod", "Sandbox_A", "Sandbox_B"}
+
+	err := p.checkDiscoveryLimits(ctx, targets, 0, true, now)
+	var limitErr *DiscoveryLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, []string{"Sandbox_A", "Sandbox_B"}, limitErr.New, "static targets are never new")
+	assert.ErrorContains(t, err, "2 new targets (max_new_targets 1)")
+	assert.ErrorContains(t, err, "--allow-new-targets 2")
+	assert.Empty(t, store[knownTargetsNamespace], "nothing is recorded for a blocked run")
+
+	// Dry runs check the limits without recording the new targets
+	require.NoError(t, p.checkDiscoveryLimits(ctx, targets, 2, false, now))
+	assert.Empty(t, store[knownTargetsNamespace])
+
+	require.NoError(t, p.checkDiscoveryLimits(ctx, targets, 2, true, now))
+	assert.Equal(t, map[string]interface{}{
+		"dynamic_target": "sandboxes",
+		"account_id":     "111111111111",
+		"first_seen":     "2026-03-01T12:00:00Z",
+	}, store[knownTargetsNamespace]["Sandbox_A"])
+
+	// Known targets count towards max_targets but need no override
+	p.config.discoveredFrom["Sandbox_C"] = "sandboxes"
+	p.config.discoveredFrom["Sandbox_D"] = "sandboxes"
+	p.config.Targets["Sandbox_C"] = Target{AccountID: "555555555555"}
+	require.NoError(t, p.checkDiscoveryLimits(ctx, targets, 0, true, now))
+	err = p.checkDiscoveryLimits(ctx, append(targets, "Sandbox_C"), 0, true, now)
+	assert.ErrorContains(t, err, "4 targets in total (max_targets 3)")
+	assert.NotContains(t, err.Error(), "max_new_targets")
+}
+
+func TestDiscoveryLimitsValidate(t *testing.T) {
+	assert.NoError(t, (&DiscoveryLimits{MaxNewTargets: 5}).validate())
+	assert.ErrorContains(t, (&DiscoveryLimits{MaxTargets: -1}).validate(), "max_targets must not be negative")
+}
@@ -1,9 +1,17 @@
 package pipeline
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/jbcom/secretsync/stores/doppler"
+	"github.com/jbcom/secretsync/stores/github"
+	"github.com/jbcom/secretsync/stores/kubernetes"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsExcluded(t *testing.T) {
@@ -147,3 +155,250 @@ func TestFilterAccountsByTags(t *testing.T) {
 		assert.Len(t, result, 0)
 	})
 }
+
+func TestMapDopplerConfigs(t *testing.T) {
+	configs := []doppler.ConfigInfo{
+		{Name: "dev", Environment: "dev", Root: true},
+		{Name: "dev_feature_x", Environment: "dev", Root: false},
+		{Name: "stg", Environment: "stg", Root: true},
+		{Name: "prd", Environment: "prd", Root: true},
+	}
+	dt := DynamicTarget{
+		Discovery: DiscoveryConfig{
+			Doppler: &DopplerDiscovery{
+				Project: "api",
+				Token:   "dp.sa.test",
+				Accounts: map[string]string{
+					"dev": "111111111111",
+					"stg": "222222222222",
+				},
+				Branches: true,
+			},
+		},
+		Imports:      []string{"shared"},
+		SecretPrefix: "api/",
+		RoleARN:      "arn:aws:iam::{{.AccountID}}:role/Sync",
+	}
+
+	targets, sources := mapDopplerConfigs("Api", dt, configs, "us-east-1")
+
+	// prd has no account mapping and is skipped
+	require.Len(t, targets, 3)
+	assert.NotContains(t, targets, "Api_prd")
+	assert.Len(t, sources, 3)
+
+	dev := targets["Api_dev"]
+	assert.Equal(t, "111111111111", dev.AccountID)
+	assert.Equal(t, []string{"shared", "doppler_api_dev"}, dev.Imports)
+	assert.Equal(t, "api/", dev.SecretPrefix)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/Sync", dev.RoleARN)
+	assert.Equal(t, "us-east-1", dev.Region)
+
+	// Branch configs inherit from their root target and override with their own source
+	branch := targets["Api_dev_feature_x"]
+	assert.Equal(t, []string{"Api_dev", "doppler_api_dev_feature_x"}, branch.Imports)
+	assert.Equal(t, "api/dev_feature_x/", branch.SecretPrefix)
+
+	assert.Equal(t, "dev_feature_x", sources["doppler_api_dev_feature_x"].Doppler.Config)
+}
+
+func TestMapDopplerConfigsExcludedRoot(t *testing.T) {
+	configs := []doppler.ConfigInfo{
+		{Name: "dev", Environment: "dev", Root: true},
+		{Name: "dev_feature_x", Environment: "dev", Root: false},
+	}
+	dt := DynamicTarget{
+		Discovery: DiscoveryConfig{
+			Doppler: &DopplerDiscovery{
+				Project:  "api",
+				Accounts: map[string]string{"dev": "111111111111"},
+				Branches: true,
+			},
+		},
+		Exclude: []string{"dev"},
+	}
+
+	targets, _ := mapDopplerConfigs("Api", dt, configs, "us-east-1")
+	assert.Empty(t, targets)
+
+	// Branches are ignored unless enabled
+	dt.Exclude = nil
+	dt.Discovery.Doppler.Branches = false
+	targets, _ = mapDopplerConfigs("Api", dt, configs, "us-east-1")
+	assert.Len(t, targets, 1)
+	assert.Contains(t, targets, "Api_dev")
+}
+
+func TestMapGitHubRepos(t *testing.T) {
+	repos := []github.RepoInfo{
+		{Name: "payments-api", Topics: []string{"service", "go"}},
+		{Name: "orders-api", Topics: []string{"service"}},
+		{Name: "old-service", Topics: []string{"service"}, Archived: true},
+		{Name: "docs", Topics: []string{"documentation"}},
+		{Name: "legacy-api", Topics: []string{"service"}},
+	}
+	dt := DynamicTarget{
+		Discovery: DiscoveryConfig{
+			GitHub: &GitHubDiscovery{
+				Org:         "acme",
+				Topics:      []string{"service"},
+				Environment: "production",
+			},
+		},
+		Imports: []string{"shared"},
+		Exclude: []string{"legacy-api"},
+	}
+
+	targets := mapGitHubRepos(dt, repos)
+	require.Len(t, targets, 2)
+
+	target := targets["payments_api"]
+	require.NotNil(t, target.GitHub)
+	assert.Equal(t, "acme", target.GitHub.Owner)
+	assert.Equal(t, "payments-api", target.GitHub.Repo)
+	assert.Equal(t, "production", target.GitHub.Environment)
+	assert.Equal(t, []string{"shared"}, target.Imports)
+	assert.Empty(t, target.AccountID)
+	assert.Contains(t, targets, "orders_api")
+
+	// Archived repositories are included on request
+	dt.Discovery.GitHub.IncludeArchived = true
+	assert.Len(t, mapGitHubRepos(dt, repos), 3)
+
+	// No topic filter keeps every unarchived, non-excluded repository
+	dt.Discovery.GitHub.Topics = nil
+	dt.Discovery.GitHub.IncludeArchived = false
+	assert.Len(t, mapGitHubRepos(dt, repos), 3)
+}
+
+func TestMapKubernetesClusters(t *testing.T) {
+	clusters := []discoveredCluster{
+		{AccountID: "111111111111", ClusterInfo: kubernetes.ClusterInfo{Name: "web", Location: "us-east-1", Status: "ACTIVE", Labels: map[string]string{"fleet": "apps"}}},
+		{AccountID: "111111111111", ClusterInfo: kubernetes.ClusterInfo{Name: "batch", Location: "us-west-2", Status: "ACTIVE", Labels: map[string]string{"fleet": "apps"}}},
+		{AccountID: "222222222222", ClusterInfo: kubernetes.ClusterInfo{Name: "creating", Location: "us-east-1", Status: "CREATING", Labels: map[string]string{"fleet": "apps"}}},
+		{AccountID: "222222222222", ClusterInfo: kubernetes.ClusterInfo{Name: "sandbox", Location: "us-east-1", Status: "ACTIVE"}},
+		{AccountID: "222222222222", ClusterInfo: kubernetes.ClusterInfo{Name: "legacy", Location: "us-east-1", Status: "ACTIVE", Labels: map[string]string{"fleet": "apps"}}},
+	}
+	dt := DynamicTarget{
+		Discovery: DiscoveryConfig{
+			Kubernetes: &KubernetesDiscovery{
+				Provider: "eks",
+				Tags:     map[string]string{"fleet": "apps"},
+			},
+		},
+		Imports: []string{"shared"},
+		Exclude: []string{"legacy"},
+		RoleARN: "arn:aws:iam::{{.AccountID}}:role/SecretsSync",
+	}
+
+	targets := mapKubernetesClusters("eks", dt, clusters)
+	require.Len(t, targets, 2)
+
+	target := targets["eks_web"]
+	require.NotNil(t, target.Kubernetes)
+	assert.Equal(t, "eks", target.Kubernetes.Provider)
+	assert.Equal(t, "web", target.Kubernetes.Cluster)
+	assert.Equal(t, "default", target.Kubernetes.Namespace)
+	assert.Equal(t, "111111111111", target.AccountID)
+	assert.Equal(t, "us-east-1", target.Region)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/SecretsSync", target.RoleARN)
+	assert.Equal(t, []string{"shared"}, target.Imports)
+	assert.Equal(t, "us-west-2", targets["eks_batch"].Region)
+
+	// GKE clusters carry project and location instead of an account
+	gke := DynamicTarget{
+		Discovery: DiscoveryConfig{
+			Kubernetes: &KubernetesDiscovery{Provider: "gke", Namespace: "apps"},
+		},
+	}
+	targets = mapKubernetesClusters("gke", gke, []discoveredCluster{
+		{Project: "acme-prod", ClusterInfo: kubernetes.ClusterInfo{Name: "prod-1", Location: "us-central1", Status: "RUNNING"}},
+	})
+	require.Contains(t, targets, "gke_prod_1")
+	target = targets["gke_prod_1"]
+	assert.Empty(t, target.AccountID)
+	assert.Equal(t, "acme-prod", target.Kubernetes.Project)
+	assert.Equal(t, "us-central1", target.Kubernetes.Location)
+	assert.Equal(t, "apps", target.Kubernetes.Namespace)
+}
+
+func TestDiscoveryCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := fileDiscoveryCacheStore{path: filepath.Join(t.TempDir(), "cache", "discovery.json")}
+	accounts := []AccountInfo{{ID: "111111111111", Name: "Sandbox", OU: "ou-abc"}}
+
+	c := newDiscoveryCache(store)
+	c.now = func() time.Time { return now }
+	_, _, ok := c.get(ctx, "o-1/organizations:{}", time.Hour)
+	assert.False(t, ok)
+	c.put(ctx, "o-1/organizations:{}", accounts, time.Hour)
+
+	// Another process reads the persisted entry until it expires
+	later := newDiscoveryCache(store)
+	later.now = func() time.Time { return now.Add(30 * time.Minute) }
+	got, discovered, ok := later.get(ctx, "o-1/organizations:{}", time.Hour)
+	require.True(t, ok)
+	assert.Equal(t, accounts, got)
+	assert.True(t, discovered.Equal(now))
+
+	expired := newDiscoveryCache(store)
+	expired.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, _, ok = expired.get(ctx, "o-1/organizations:{}", time.Hour)
+	assert.False(t, ok)
+}
+
+func TestCachedAccounts(t *testing.T) {
+	settings := &DiscoveryCacheSettings{}
+	d := &DiscoveryService{
+		ctx:    context.Background(),
+		awsCtx: &AWSExecutionContext{OrganizationInfo: &OrganizationInfo{ID: "o-1"}},
+		config: &Config{AWS: AWSConfig{DiscoveryCache: settings}},
+		cache:  newDiscoveryCache(nil),
+	}
+	calls := 0
+	discover := func() ([]AccountInfo, error) {
+		calls++
+		return []AccountInfo{{ID: fmt.Sprintf("%012d", calls)}}, nil
+	}
+	query := &OrganizationsDiscovery{OU: "ou-abc", Recursive: true}
+
+	first, err := d.cachedAccounts("organizations", query, discover)
+	require.NoError(t, err)
+	second, err := d.cachedAccounts("organizations", query, discover)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first, second)
+
+	// Another query is discovered separately
+	_, err = d.cachedAccounts("organizations", &OrganizationsDiscovery{OU: "ou-def"}, discover)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Refresh rediscovers and caches the new result
+	settings.Refresh = true
+	refreshed, err := d.cachedAccounts("organizations", query, discover)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	settings.Refresh = false
+	cached, err := d.cachedAccounts("organizations", query, discover)
+	require.NoError(t, err)
+	assert.Equal(t, refreshed, cached)
+
+	// Failures are not cached
+	_, err = d.cachedAccounts("identity_center", &IdentityCenterDiscovery{Group: "Sandboxes"}, func() ([]AccountInfo, error) {
+		return nil, fmt.Errorf("AccessDenied")
+	})
+	assert.Error(t, err)
+	_, err = d.cachedAccounts("identity_center", &IdentityCenterDiscovery{Group: "Sandboxes"}, discover)
+	require.NoError(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func TestDiscoveryCacheSettingsValidate(t *testing.T) {
+	assert.NoError(t, (&DiscoveryCacheSettings{}).validate())
+	assert.NoError(t, (&DiscoveryCacheSettings{TTL: time.Hour, S3: &DiscoveryCacheS3{Bucket: "vss-state", Key: "discovery.json"}}).validate())
+	assert.ErrorContains(t, (&DiscoveryCacheSettings{TTL: -time.Hour}).validate(), "must not be negative")
+	assert.ErrorContains(t, (&DiscoveryCacheSettings{File: "cache.json", S3: &DiscoveryCacheS3{Bucket: "b", Key: "k"}}).validate(), "mutually exclusive")
+	assert.ErrorContains(t, (&DiscoveryCacheSettings{S3: &DiscoveryCacheS3{Bucket: "vss-state"}}).validate(), "s3.bucket and s3.key are required")
+}
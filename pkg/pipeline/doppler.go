@@ -0,0 +1,209 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/stores/doppler"
+	"github.com/jbcom/secretsync/stores/vault"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// discoverFromDoppler enumerates the project's configs and maps them to targets and sources
+func (d *DiscoveryService) discoverFromDoppler(dynamicName string, dt DynamicTarget) (map[string]Target, map[string]Source, error) {
+	cfg := dt.Discovery.Doppler
+	l := log.WithFields(log.Fields{
+		"action":  "discoverFromDoppler",
+		"project": cfg.Project,
+	})
+	l.Debug("Discovering configs from Doppler")
+
+	client := &doppler.DopplerClient{
+		Project: cfg.Project,
+		Token:   cfg.Token,
+	}
+	configs, err := client.ListConfigs(d.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets, sources := mapDopplerConfigs(dynamicName, dt, configs, d.config.AWS.Region)
+	l.WithField("count", len(targets)).Debug("Mapped Doppler configs to targets")
+	return targets, sources, nil
+}
+
+// mapDopplerConfigs converts Doppler configs into targets and sources.
+//
+// Each config gets a Doppler source. Root configs import their source (plus the dynamic
+// target's imports); branch configs import their environment's root target followed by
+// their own source, so the graph merges the root first and the branch overrides on top.
+// Branch targets write under "<secret_prefix><config>/" to avoid clobbering the root.
+func mapDopplerConfigs(dynamicName string, dt DynamicTarget, configs []doppler.ConfigInfo, defaultRegion string) (map[string]Target, map[string]Source) {
+	cfg := dt.Discovery.Doppler
+	targets := make(map[string]Target)
+	sources := make(map[string]Source)
+
+	region := dt.Region
+	if region == "" {
+		region = defaultRegion
+	}
+
+	targetName := func(config string) string {
+		return sanitizeTargetName(fmt.Sprintf("%s_%s", dynamicName, config))
+	}
+
+	roots := make(map[string]string) // environment -> root config name
+	for _, c := range configs {
+		if c.Root {
+			roots[c.Environment] = c.Name
+		}
+	}
+
+	// Process in a stable order so logs and warnings are deterministic
+	sorted := append([]doppler.ConfigInfo(nil), configs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, c := range sorted {
+		if !c.Root && !cfg.Branches {
+			continue
+		}
+		if isExcluded(c.Name, dt.Exclude) {
+			continue
+		}
+		accountID, ok := cfg.Accounts[c.Environment]
+		if !ok {
+			log.WithFields(log.Fields{
+				"config":      c.Name,
+				"environment": c.Environment,
+			}).Warn("No account mapped for Doppler environment, skipping config")
+			continue
+		}
+		if isExcluded(accountID, dt.Exclude) {
+			continue
+		}
+
+		sourceName := sanitizeTargetName(fmt.Sprintf("doppler_%s_%s", cfg.Project, c.Name))
+		sources[sourceName] = Source{
+			Doppler: &DopplerSource{
+				Project: cfg.Project,
+				Config:  c.Name,
+				Token:   cfg.Token,
+			},
+		}
+
+		roleARN := strings.ReplaceAll(dt.RoleARN, "{{.AccountID}}", accountID)
+
+		target := Target{
+			AccountID:      accountID,
+			Region:         region,
+			SecretPrefix:   dt.SecretPrefix,
+			RoleARN:        roleARN,
+			Classification: dt.Classification,
+			Owners:         dt.Owners,
+			Tags:           dt.Tags,
+			Labels:         dt.Labels,
+		}
+		if c.Root {
+			target.Imports = append(append([]string{}, dt.Imports...), sourceName)
+		} else {
+			root, ok := roots[c.Environment]
+			if !ok {
+				log.WithField("config", c.Name).Warn("Branch config has no root config, skipping")
+				delete(sources, sourceName)
+				continue
+			}
+			target.Imports = []string{targetName(root), sourceName}
+			target.SecretPrefix = fmt.Sprintf("%s%s/", dt.SecretPrefix, c.Name)
+		}
+		targets[targetName(c.Name)] = target
+	}
+
+	// Drop branch targets whose root was excluded or unmapped
+	for name, t := range targets {
+		for _, imp := range t.Imports {
+			if _, isSource := sources[imp]; isSource {
+				continue
+			}
+			if _, isTarget := targets[imp]; !isTarget && !containsString(dt.Imports, imp) {
+				delete(targets, name)
+				break
+			}
+		}
+	}
+
+	return targets, sources
+}
+
+// mergeDopplerSource downloads a Doppler config, applies the source's key
+// transforms and writes it into the merge store
+func (p *Pipeline) mergeDopplerSource(ctx context.Context, src *DopplerSource, keys *KeyTransforms, targetName, mergePath string, dryRun bool) error {
+	l := log.WithFields(log.Fields{
+		"action":  "mergeDopplerSource",
+		"target":  targetName,
+		"project": src.Project,
+		"config":  src.Config,
+	})
+
+	client := &doppler.DopplerClient{
+		Project: src.Project,
+		Config:  src.Config,
+		Token:   src.Token,
+	}
+	secrets, err := client.DownloadSecrets(ctx)
+	if err != nil {
+		return err
+	}
+
+	secretName := src.SecretName
+	if secretName == "" {
+		secretName = src.Project
+	}
+
+	data := make(map[string]interface{}, len(secrets))
+	for k, v := range secrets {
+		data[k] = v
+	}
+	data, err = keys.rules().Apply(data)
+	if err != nil {
+		return fmt.Errorf("failed to transform keys: %w", err)
+	}
+
+	if dryRun {
+		l.WithField("keys", len(data)).Info("Dry run: would merge Doppler secrets")
+		return nil
+	}
+
+	if p.config.MergeStore.Vault != nil {
+		vc := &vault.VaultClient{
+			Address:   p.config.Vault.Address,
+			Namespace: p.config.Vault.Namespace,
+			Merge:     true,
+		}
+		if err := vc.Init(ctx); err != nil {
+			return fmt.Errorf("failed to initialize vault client: %w", err)
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = vc.WriteSecret(ctx, metav1.ObjectMeta{Namespace: "pipeline"}, fmt.Sprintf("%s/%s", mergePath, secretName), b)
+		return err
+	}
+	if store := p.directStore(); store != nil {
+		return store.WriteSecret(ctx, targetName, secretName, data)
+	}
+	return fmt.Errorf("no merge store configured")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,274 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/transforms"
+	"github.com/jbcom/secretsync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// DriftReport compares each target's merged secrets, as its destinations
+// should hold them, with what the destinations actually hold. Added secrets
+// are missing from the destination, removed ones are only in the destination
+// and modified ones differ from the merged state.
+type DriftReport struct {
+	Diff *diff.PipelineDiff `json:"diff"`
+	// Unchecked are destinations whose contents cannot be read back, as
+	// "<target>/<destination>"
+	Unchecked []string `json:"unchecked,omitempty"`
+	// Errors are destinations that could not be read, as
+	// "<target>/<destination>: <error>"
+	Errors []string `json:"errors,omitempty"`
+}
+
+// HasDrift reports whether any checked destination differs from the merged state
+func (r *DriftReport) HasDrift() bool {
+	return !r.Diff.IsZeroSum()
+}
+
+// destinationReader reads live secrets from Secrets Manager destinations
+type destinationReader interface {
+	// SecretsManagerValues returns the values of the named secrets that exist
+	// in the destination and, when prefix is set, of every secret whose name
+	// starts with it
+	SecretsManagerValues(ctx context.Context, target Target, d Destination, names []string, prefix string) (map[string][]byte, error)
+}
+
+// Drift reads the merged secrets of the given targets (all when empty) and
+// compares them with their Secrets Manager destinations. Destinations that
+// cannot be read are recorded in the report rather than aborting it.
+func (p *Pipeline) Drift(ctx context.Context, targets []string) (*DriftReport, error) {
+	targets, err := p.selectTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.driftReader == nil {
+		p.driftReader = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	return p.drift(ctx, store, p.driftReader, targets), nil
+}
+
+func (p *Pipeline) drift(ctx context.Context, store mergeStore, reader destinationReader, targets []string) *DriftReport {
+	report := &DriftReport{Diff: &diff.PipelineDiff{}}
+	for _, name := range targets {
+		target := p.config.Targets[name]
+		l := log.WithFields(log.Fields{
+			"action": "drift",
+			"target": name,
+		})
+		dests := target.ResolvedDestinations()
+
+		// Envelope targets hold ciphertext, which is re-encrypted on every sync
+		if target.Envelope != nil {
+			for _, d := range dests {
+				report.Unchecked = append(report.Unchecked, name+"/"+d.Label())
+			}
+			continue
+		}
+		sourcePath, err := p.syncSourcePath(name)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		snapshot, err := readSnapshot(ctx, store, name)
+		if err != nil {
+			l.WithError(err).Error("Failed to read merged secrets")
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: failed to read merged secrets: %s", name, err))
+			continue
+		}
+
+		for _, d := range dests {
+			label := d.Label()
+			if !d.requiresAccountID() || d.Kubernetes != nil {
+				report.Unchecked = append(report.Unchecked, name+"/"+label)
+				continue
+			}
+			td, err := p.destinationDrift(ctx, reader, name, sourcePath, target, d, snapshot)
+			if err != nil {
+				l.WithError(err).WithField("destination", label).Error("Failed to check destination for drift")
+				report.Errors = append(report.Errors, fmt.Sprintf("%s/%s: %s", name, label, err))
+				continue
+			}
+			if len(dests) > 1 {
+				td.Target = fmt.Sprintf("%s (%s)", name, label)
+			}
+			report.Diff.AddTargetDiff(td)
+		}
+	}
+	return report
+}
+
+// destinationDrift diffs one Secrets Manager destination against the merged
+// secrets, transformed and named the way the sync phase writes them
+func (p *Pipeline) destinationDrift(ctx context.Context, reader destinationReader, targetName, sourcePath string, target Target, d Destination, snapshot map[string]interface{}) (diff.TargetDiff, error) {
+	rendered, values, err := p.destinationSecrets(ctx, reader, targetName, sourcePath, target, d, snapshot)
+	if err != nil {
+		return diff.TargetDiff{}, err
+	}
+	desired := make(map[string]interface{}, len(rendered))
+	for destName, b := range rendered {
+		desired[destName] = decodeSecretValue(b)
+	}
+	current := make(map[string]interface{}, len(values))
+	for destName, b := range values {
+		current[destName] = decodeSecretValue(b)
+	}
+
+	changes := p.diffHasher().DiffSecrets(current, desired)
+	if p.config.Log.Redact.DiffPreviews {
+		diff.AddPreviews(changes, current, desired)
+	}
+	for i := range changes {
+		changes[i].Target = targetName
+	}
+	return diff.TargetDiff{
+		Target:  targetName,
+		Changes: changes,
+		Summary: diff.ComputeSummary(changes),
+		Owners:  p.config.Owners(targetName),
+	}, nil
+}
+
+// destinationSecrets renders a target's merged secrets the way the sync phase
+// writes them to a Secrets Manager destination, and reads the destination's
+// values of those secrets and of every other secret under the target's name
+// prefix
+func (p *Pipeline) destinationSecrets(ctx context.Context, reader destinationReader, targetName, sourcePath string, target Target, d Destination, snapshot map[string]interface{}) (rendered, values map[string][]byte, err error) {
+	sync, _ := p.destinationSync(targetName, sourcePath, target, d, true)
+	pattern := sync.Spec.Dest[0].AWS.Name
+
+	rendered, err = renderSecrets(sync, sourcePath, snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(rendered))
+	for destName := range rendered {
+		names = append(names, destName)
+	}
+
+	// Secrets under a target's own name prefix are all its; anything else in
+	// the account may belong to someone else, so only the merged names are read
+	prefix, _, _ := strings.Cut(pattern, "$1")
+	values, err = reader.SecretsManagerValues(ctx, target, d, names, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rendered, values, nil
+}
+
+// renderSecrets transforms merged secrets and names them the way the sync
+// phase writes them to a Secrets Manager destination, keyed by that name
+func renderSecrets(sync v1alpha1.VaultSecretSync, sourcePath string, snapshot map[string]interface{}) (map[string][]byte, error) {
+	pattern := sync.Spec.Dest[0].AWS.Name
+	rendered := make(map[string][]byte, len(snapshot))
+	for secretName, data := range snapshot {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %q: %w", secretName, err)
+		}
+		if b, err = transforms.ExecuteReferenceTransform(sync, sourcePath+"/"+secretName, b); err != nil {
+			return nil, fmt.Errorf("failed to transform %q: %w", secretName, err)
+		}
+		if b, err = transforms.ExecuteTransforms(sync, b); err != nil {
+			return nil, fmt.Errorf("failed to transform %q: %w", secretName, err)
+		}
+		rendered[strings.ReplaceAll(pattern, "$1", secretName)] = b
+	}
+	return rendered, nil
+}
+
+// decodeSecretValue decodes a JSON object secret so it is diffed per key;
+// any other secret is compared as a whole
+func decodeSecretValue(b []byte) interface{} {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err == nil {
+		return m
+	}
+	return string(b)
+}
+
+// syncSourcePath is the path the sync engine reads a target's merged secrets
+// from: its Vault merge store path, or for an S3 or GCP merge store the target
+// name a mergeStoreSource serves them under
+func (p *Pipeline) syncSourcePath(targetName string) (string, error) {
+	if p.config.MergeStore.Vault != nil {
+		return fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName), nil
+	}
+	if p.directStore() != nil {
+		return targetName, nil
+	}
+	return "", fmt.Errorf("no merge store configured")
+}
+
+// mergePath describes where a target synced from sourcePath keeps its merged
+// secrets, for reports
+func (p *Pipeline) mergePath(targetName, sourcePath string) string {
+	if store := p.directStore(); store != nil {
+		return store.GetMergePath(targetName)
+	}
+	return sourcePath
+}
+
+// SecretsManagerValues reads secrets from a destination's account and region,
+// reached the same way as the target's preconditions
+func (r *liveReadinessProbe) SecretsManagerValues(ctx context.Context, target Target, d Destination, names []string, prefix string) (map[string][]byte, error) {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return nil, err
+	}
+	client := secretsmanager.NewFromConfig(cfg)
+
+	if prefix != "" {
+		wanted := make(map[string]bool, len(names))
+		for _, n := range names {
+			wanted[n] = true
+		}
+		paginator := secretsmanager.NewListSecretsPaginator(client, &secretsmanager.ListSecretsInput{
+			Filters: []smtypes.Filter{{Key: smtypes.FilterNameStringTypeName, Values: []string{prefix}}},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list secrets: %w", err)
+			}
+			for _, s := range page.SecretList {
+				// The name filter is case-insensitive
+				if n := aws.ToString(s.Name); strings.HasPrefix(n, prefix) && !wanted[n] {
+					wanted[n] = true
+					names = append(names, n)
+				}
+			}
+		}
+	}
+
+	values := make(map[string][]byte, len(names))
+	for _, name := range names {
+		out, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(name)})
+		var notFound *smtypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		if out.SecretString != nil {
+			values[name] = []byte(aws.ToString(out.SecretString))
+		} else {
+			values[name] = out.SecretBinary
+		}
+	}
+	return values, nil
+}
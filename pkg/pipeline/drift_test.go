@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDestinationReader holds Secrets Manager contents keyed by account ID
+// then secret name
+type fakeDestinationReader map[string]map[string]string
+
+func (f fakeDestinationReader) SecretsManagerValues(_ context.Context, _ Target, d Destination, names []string, prefix string) (map[string][]byte, error) {
+	secrets, ok := f[d.AccountID]
+	if !ok {
+		return nil, fmt.Errorf("AccessDenied")
+	}
+	values := make(map[string][]byte)
+	for name, v := range secrets {
+		if prefix != "" && strings.HasPrefix(name, prefix) {
+			values[name] = []byte(v)
+		}
+	}
+	for _, name := range names {
+		if v, ok := secrets[name]; ok {
+			values[name] = []byte(v)
+		}
+	}
+	return values, nil
+}
+
+func TestDrift(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		Vault:      VaultConfig{Address: "https://vault.example.com"},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg": {
+				AccountID:  "111111111111",
+				Owners:     []string{"platform"},
+				Transforms: &KeyTransforms{Case: "upper", Name: "/{{.Target}}/app/{{.Name}}"},
+			},
+			"Prod": {AccountID: "222222222222"},
+			"Repo": {GitHub: &GitHubDestination{Owner: "org", Repo: "app"}},
+		},
+	}}
+	store := memMergeStore{
+		"Stg": {
+			"db":  {"password": "hunter2", "host": "db.internal"},
+			"api": {"token": "abc"},
+		},
+		"Prod": {"db": {"password": "hunter2"}},
+		"Repo": {"db": {"password": "hunter2"}},
+	}
+	reader := fakeDestinationReader{
+		"111111111111": {
+			// Edited by hand, and a secret vss no longer merges
+			"/Stg/app/db":     `{"PASSWORD":"changed","HOST":"db.internal"}`,
+			"/Stg/app/legacy": `{"KEY":"old"}`,
+			// Outside the target's prefix, so not its secret
+			"unrelated": `{"x":"y"}`,
+		},
+	}
+
+	report := p.drift(context.Background(), store, reader, []string{"Prod", "Repo", "Stg"})
+	assert.Equal(t, []string{"Repo/github:org/app"}, report.Unchecked)
+	assert.Equal(t, []string{"Prod/aws:222222222222: AccessDenied"}, report.Errors)
+	require.Len(t, report.Diff.Targets, 1)
+
+	stg := report.Diff.Targets[0]
+	assert.Equal(t, "Stg", stg.Target)
+	assert.Equal(t, []string{"platform"}, stg.Owners)
+	require.Len(t, stg.Changes, 3)
+	assert.Equal(t, "/Stg/app/api", stg.Changes[0].Path)
+	assert.Equal(t, diff.ChangeTypeAdded, stg.Changes[0].ChangeType)
+	assert.Equal(t, []string{"TOKEN"}, stg.Changes[0].DesiredKeys)
+	assert.Equal(t, "/Stg/app/db", stg.Changes[1].Path)
+	assert.Equal(t, diff.ChangeTypeModified, stg.Changes[1].ChangeType)
+	assert.Equal(t, []string{"PASSWORD"}, stg.Changes[1].KeysModified)
+	assert.Equal(t, "/Stg/app/legacy", stg.Changes[2].Path)
+	assert.Equal(t, diff.ChangeTypeRemoved, stg.Changes[2].ChangeType)
+	assert.True(t, report.HasDrift())
+
+	// A destination that matches the merged state has no drift
+	reader["222222222222"] = map[string]string{"db": `{"password":"hunter2"}`, "unrelated": `{"x":"y"}`}
+	report = p.drift(context.Background(), store, reader, []string{"Prod"})
+	assert.Empty(t, report.Errors)
+	assert.False(t, report.HasDrift())
+	assert.Equal(t, 1, report.Diff.Summary.Unchanged)
+}
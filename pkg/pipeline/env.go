@@ -0,0 +1,181 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// applyEnvOverrides sets every config field whose VSS_* environment variable is
+// set. The variable name is the field's YAML path joined with underscores and
+// upper-cased, so pipeline.sync.parallel is VSS_PIPELINE_SYNC_PARALLEL and
+// targets.Serverless_Stg.region is VSS_TARGETS_SERVERLESS_STG_REGION. Map
+// entries can only be overridden if they exist in the file; lists of strings
+// are comma-separated.
+func (c *Config) applyEnvOverrides() error {
+	v := viper.New()
+	v.SetEnvPrefix("VSS")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	v.AutomaticEnv()
+
+	_, err := applyEnvOverrides(v, reflect.ValueOf(c).Elem(), "")
+	return err
+}
+
+// applyEnvOverrides walks val and reports whether anything under it was set
+func applyEnvOverrides(v *viper.Viper, val reflect.Value, key string) (bool, error) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if !val.IsNil() {
+			return applyEnvOverrides(v, val.Elem(), key)
+		}
+		// Only allocate optional sections such as vault.auth.token when a
+		// variable below them is set
+		elem := reflect.New(val.Type().Elem())
+		changed, err := applyEnvOverrides(v, elem.Elem(), key)
+		if changed {
+			val.Set(elem)
+		}
+		return changed, err
+	case reflect.Struct:
+		if val.Type() != timeType {
+			return applyStructEnvOverrides(v, val, key)
+		}
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return false, nil
+		}
+		changed := false
+		iter := val.MapRange()
+		for iter.Next() {
+			// Map values are not addressable; override a copy and store it back
+			elem := reflect.New(val.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			set, err := applyEnvOverrides(v, elem, joinKey(key, iter.Key().String()))
+			if err != nil {
+				return false, err
+			}
+			if set {
+				val.SetMapIndex(iter.Key(), elem)
+				changed = true
+			}
+		}
+		return changed, nil
+	case reflect.Slice:
+		if val.Type().Elem().Kind() != reflect.String {
+			return false, nil
+		}
+	}
+
+	if key == "" || !v.IsSet(key) {
+		return false, nil
+	}
+	if err := setFromEnv(val, v.GetString(key)); err != nil {
+		return false, fmt.Errorf("invalid value for %s: %w", envVarName(key), err)
+	}
+	log.WithFields(log.Fields{
+		"action": "applyEnvOverrides",
+		"key":    key,
+		"env":    envVarName(key),
+	}).Debug("Config value overridden from environment")
+	return true, nil
+}
+
+func applyStructEnvOverrides(v *viper.Viper, val reflect.Value, key string) (bool, error) {
+	changed := false
+	for i := 0; i < val.NumField(); i++ {
+		name, inline, ok := yamlFieldName(val.Type().Field(i))
+		if !ok {
+			continue
+		}
+		fieldKey := key
+		if !inline {
+			fieldKey = joinKey(key, name)
+		}
+		set, err := applyEnvOverrides(v, val.Field(i), fieldKey)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || set
+	}
+	return changed, nil
+}
+
+// setFromEnv parses an environment variable value into a scalar field
+func setFromEnv(val reflect.Value, s string) error {
+	switch {
+	case val.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		val.SetInt(int64(d))
+		return nil
+	case val.Type() == timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		val.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch val.Kind() {
+	case reflect.String:
+		val.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, val.Type().Bits())
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(val.Type(), len(items), len(items))
+		for i, item := range items {
+			list.Index(i).SetString(item)
+		}
+		val.Set(list)
+	default:
+		return fmt.Errorf("unsupported field type %s", val.Type())
+	}
+	return nil
+}
+
+// envVarName returns the environment variable that overrides a config key
+func envVarName(key string) string {
+	return "VSS_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
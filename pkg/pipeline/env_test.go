@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const envTestConfig = `
+vault:
+  address: https://vault.example.com
+merge_store:
+  vault:
+    mount: merged
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+  analytics-sandbox:
+    account_id: "222222222222"
+pipeline:
+  freeze_windows:
+    - name: weekends
+      cron: "0 18 * * FRI"
+      duration: 62h
+`
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	path := writeTestConfig(t, envTestConfig)
+	t.Setenv("VSS_LOG_LEVEL", "debug")
+	t.Setenv("VSS_AWS_REGION", "eu-west-1")
+	t.Setenv("VSS_PIPELINE_SYNC_PARALLEL", "16")
+	t.Setenv("VSS_PIPELINE_DRY_RUN", "true")
+	t.Setenv("VSS_VAULT_AUTH_TOKEN_TOKEN", "s.override")
+	t.Setenv("VSS_TARGETS_SERVERLESS_STG_REGION", "us-west-2")
+	t.Setenv("VSS_TARGETS_ANALYTICS_SANDBOX_IMPORTS", "analytics, analytics-engineers")
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, "eu-west-1", cfg.AWS.Region)
+	assert.Equal(t, 16, cfg.Pipeline.Sync.Parallel)
+	assert.Equal(t, 4, cfg.Pipeline.Merge.Parallel)
+	assert.True(t, cfg.Pipeline.DryRun)
+	require.NotNil(t, cfg.Vault.Auth.Token)
+	assert.Equal(t, "s.override", cfg.Vault.Auth.Token.Token)
+	assert.Nil(t, cfg.Vault.Auth.Kubernetes)
+	assert.Equal(t, "us-west-2", cfg.Targets["Serverless_Stg"].Region)
+	assert.Equal(t, "111111111111", cfg.Targets["Serverless_Stg"].AccountID)
+	assert.Equal(t, []string{"analytics", "analytics-engineers"}, cfg.Targets["analytics-sandbox"].Imports)
+	// Lists of structs are not addressable from the environment
+	assert.Equal(t, 62*time.Hour, cfg.Pipeline.FreezeWindows[0].Duration)
+}
+
+func TestLoadConfigEnvOverrideInvalid(t *testing.T) {
+	path := writeTestConfig(t, envTestConfig)
+	t.Setenv("VSS_PIPELINE_SYNC_PARALLEL", "lots")
+
+	_, err := LoadConfig(path)
+	assert.ErrorContains(t, err, "invalid value for VSS_PIPELINE_SYNC_PARALLEL")
+}
+
+func TestEnvVarName(t *testing.T) {
+	assert.Equal(t, "VSS_PIPELINE_SYNC_PARALLEL", envVarName("pipeline.sync.parallel"))
+	assert.Equal(t, "VSS_TARGETS_ANALYTICS_SANDBOX_REGION", envVarName("targets.analytics-sandbox.region"))
+}
@@ -0,0 +1,306 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// envelopeContextKey binds each data key to its target in the KMS encryption
+// context, so a grant for one target cannot decrypt another target's envelope
+const envelopeContextKey = "vss:target"
+
+var invalidGrantNameChars = regexp.MustCompile(`[^a-zA-Z0-9:/_-]`)
+
+// TargetEnvelope distributes a target's secrets as a single KMS-encrypted
+// envelope in the S3 merge store instead of copying values into the target.
+// The sync phase encrypts the target's merged secrets under a fresh data key,
+// writes the ciphertext next to the merge store, and grants the grantees
+// kms:Decrypt for that target's envelopes only.
+//
+//	targets:
+//	  Analytics_Prod:
+//	    account_id: "111111111111"
+//	    imports: [analytics]
+//	    envelope:
+//	      grantees: ["arn:aws:iam::111111111111:role/analytics-app"]
+type TargetEnvelope struct {
+	// Grantees are the IAM principals allowed to decrypt (default: the target's role)
+	Grantees []string `mapstructure:"grantees" yaml:"grantees,omitempty"`
+}
+
+func (e *TargetEnvelope) validate(c *Config, target Target) error {
+	if c.MergeStore.S3 == nil || c.MergeStore.S3.EnvelopeKeyID == "" {
+		return fmt.Errorf("requires merge_store.s3.envelope_key_id")
+	}
+	if len(target.Destinations) > 0 || target.GitHub != nil || target.Kubernetes != nil {
+		return fmt.Errorf("cannot be combined with destinations, github or kubernetes")
+	}
+	for _, g := range e.Grantees {
+		if !strings.HasPrefix(g, "arn:") {
+			return fmt.Errorf("grantee %q must be an IAM principal ARN", g)
+		}
+	}
+	return nil
+}
+
+// grantees returns the principals to grant decryption to
+func (e *TargetEnvelope) grantees(c *Config, target Target) []string {
+	if len(e.Grantees) > 0 {
+		return e.Grantees
+	}
+	if target.RoleARN != "" {
+		return []string{target.RoleARN}
+	}
+	return []string{c.GetRoleARN(target.AccountID)}
+}
+
+// Envelope is a target's merged secrets encrypted under a KMS data key. It is
+// stored as JSON; []byte fields are base64 encoded.
+type Envelope struct {
+	Version int    `json:"version"`
+	Target  string `json:"target"`
+	KeyID   string `json:"key_id"`
+	// EncryptedDataKey is the data key encrypted by KMS under KeyID
+	EncryptedDataKey  []byte            `json:"encrypted_data_key"`
+	EncryptionContext map[string]string `json:"encryption_context"`
+	// Ciphertext is the JSON secrets map (name to key/values) sealed with
+	// AES-256-GCM under the data key, with the target name as additional data
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+	Created    time.Time `json:"created"`
+}
+
+// envelopeKMS is the subset of the KMS API envelopes need
+type envelopeKMS interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	CreateGrant(ctx context.Context, params *kms.CreateGrantInput, optFns ...func(*kms.Options)) (*kms.CreateGrantOutput, error)
+}
+
+// EnvelopeDecrypter is the subset of the KMS API needed to open an envelope;
+// *kms.Client implements it
+type EnvelopeDecrypter interface {
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+func newEnvelopeKMS(ctx context.Context, region string) (envelopeKMS, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), withRateLimit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return kms.NewFromConfig(awsCfg), nil
+}
+
+// sealEnvelope encrypts secrets for a target under a new data key from keyID
+func sealEnvelope(ctx context.Context, client envelopeKMS, keyID, targetName string, secrets map[string]map[string]interface{}) (*Envelope, error) {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	encCtx := map[string]string{envelopeContextKey: targetName}
+	dk, err := client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(keyID),
+		KeySpec:           kmstypes.DataKeySpecAes256,
+		EncryptionContext: encCtx,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer zero(dk.Plaintext)
+
+	gcm, err := newGCM(dk.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return &Envelope{
+		Version:           1,
+		Target:            targetName,
+		KeyID:             aws.ToString(dk.KeyId),
+		EncryptedDataKey:  dk.CiphertextBlob,
+		EncryptionContext: encCtx,
+		Nonce:             nonce,
+		Ciphertext:        gcm.Seal(nil, nonce, plaintext, []byte(targetName)),
+		Created:           time.Now().UTC(),
+	}, nil
+}
+
+// OpenEnvelope decrypts an envelope's data key with KMS and returns the
+// target's secrets
+func OpenEnvelope(ctx context.Context, client EnvelopeDecrypter, env *Envelope) (map[string]map[string]interface{}, error) {
+	out, err := client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    env.EncryptedDataKey,
+		EncryptionContext: env.EncryptionContext,
+		KeyId:             aws.String(env.KeyID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	defer zero(out.Plaintext)
+
+	gcm, err := newGCM(out.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(env.Target))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt envelope for target %q: %w", env.Target, err)
+	}
+	var secrets map[string]map[string]interface{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// grantEnvelope lets each grantee decrypt data keys bound to the target.
+// CreateGrant with the same name and parameters is idempotent, so repeated
+// syncs do not pile up grants.
+func grantEnvelope(ctx context.Context, client envelopeKMS, keyID, targetName string, grantees []string) error {
+	for _, grantee := range grantees {
+		_, err := client.CreateGrant(ctx, &kms.CreateGrantInput{
+			KeyId:            aws.String(keyID),
+			GranteePrincipal: aws.String(grantee),
+			Operations:       []kmstypes.GrantOperation{kmstypes.GrantOperationDecrypt},
+			Constraints: &kmstypes.GrantConstraints{
+				EncryptionContextEquals: map[string]string{envelopeContextKey: targetName},
+			},
+			Name: aws.String(invalidGrantNameChars.ReplaceAllString("vss-"+targetName, "-")),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to grant %s decrypt: %w", grantee, err)
+		}
+	}
+	return nil
+}
+
+// syncEnvelope seals a target's merged secrets into its envelope and grants
+// decryption, in place of copying the values to the target
+func (p *Pipeline) syncEnvelope(ctx context.Context, targetName string, target Target, dryRun bool) Result {
+	start := time.Now()
+	l := log.WithFields(log.Fields{
+		"action": "syncEnvelope",
+		"target": targetName,
+		"dryRun": dryRun,
+	})
+	fail := func(err error) Result {
+		l.WithError(err).Error("Envelope sync failed")
+		return Result{Target: targetName, Phase: "sync", Operation: string(OperationSync), Success: false, Error: err, Duration: time.Since(start)}
+	}
+	if p.s3Store == nil || p.envelopeKMS == nil {
+		return fail(fmt.Errorf("envelope distribution requires an S3 merge store with envelope_key_id"))
+	}
+
+	names, err := p.s3Store.ListSecrets(ctx, targetName)
+	if err != nil {
+		return fail(err)
+	}
+	secrets := make(map[string]map[string]interface{}, len(names))
+	for _, name := range names {
+		data, err := p.s3Store.ReadSecret(ctx, targetName, name)
+		if err != nil {
+			return fail(fmt.Errorf("failed to read %q: %w", name, err))
+		}
+		secrets[name] = data
+	}
+
+	keyID := p.config.MergeStore.S3.EnvelopeKeyID
+	grantees := target.Envelope.grantees(p.config, target)
+	result := Result{
+		Target:    targetName,
+		Phase:     "sync",
+		Operation: string(OperationSync),
+		Success:   true,
+		Details: ResultDetails{
+			SecretsProcessed: len(secrets),
+			SourcePaths:      []string{p.s3Store.GetMergePath(targetName)},
+			DestinationPath:  fmt.Sprintf("s3://%s/%s", p.s3Store.Bucket, p.s3Store.envelopeKey(targetName)),
+		},
+	}
+	if dryRun {
+		l.WithFields(log.Fields{"secrets": len(secrets), "grantees": grantees}).Info("Would seal envelope")
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	env, err := sealEnvelope(ctx, p.envelopeKMS, keyID, targetName, secrets)
+	if err != nil {
+		return fail(err)
+	}
+	if err := p.s3Store.WriteEnvelope(ctx, env); err != nil {
+		return fail(err)
+	}
+	if err := grantEnvelope(ctx, p.envelopeKMS, keyID, targetName, grantees); err != nil {
+		return fail(err)
+	}
+
+	l.WithFields(log.Fields{"secrets": len(secrets), "grantees": grantees}).Info("Envelope sealed")
+	result.Duration = time.Since(start)
+	return result
+}
+
+// envelopeKey returns the S3 key of a target's envelope. It sits beside the
+// target's prefix rather than in it so it is not listed as a merged secret.
+func (s *S3MergeStore) envelopeKey(targetName string) string {
+	prefix := s.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return fmt.Sprintf("%s%s.envelope.json", prefix, targetName)
+}
+
+// WriteEnvelope writes a target's envelope to S3
+func (s *S3MergeStore) WriteEnvelope(ctx context.Context, env *Envelope) error {
+	jsonData, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(s.Bucket),
+		Key:                  aws.String(s.envelopeKey(env.Target)),
+		Body:                 bytes.NewReader(jsonData),
+		ContentType:          aws.String("application/json"),
+		ServerSideEncryption: "AES256",
+	}
+	if s.KMSKeyID != "" {
+		input.ServerSideEncryption = "aws:kms"
+		input.SSEKMSKeyId = aws.String(s.KMSKeyID)
+	}
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put envelope: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKMS wraps data keys by handle and enforces encryption contexts
+type fakeKMS struct {
+	keys   map[string][]byte
+	ctxs   map[string]map[string]string
+	grants []*kms.CreateGrantInput
+}
+
+func newFakeKMS() *fakeKMS {
+	return &fakeKMS{keys: map[string][]byte{}, ctxs: map[string]map[string]string{}}
+}
+
+func (f *fakeKMS) GenerateDataKey(_ context.Context, in *kms.GenerateDataKeyInput, _ ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	handle := fmt.Sprintf("wrapped-%d", len(f.keys))
+	f.keys[handle] = append([]byte(nil), key...)
+	f.ctxs[handle] = in.EncryptionContext
+	return &kms.GenerateDataKeyOutput{KeyId: in.KeyId, Plaintext: key, CiphertextBlob: []byte(handle)}, nil
+}
+
+func (f *fakeKMS) CreateGrant(_ context.Context, in *kms.CreateGrantInput, _ ...func(*kms.Options)) (*kms.CreateGrantOutput, error) {
+	f.grants = append(f.grants, in)
+	return &kms.CreateGrantOutput{}, nil
+}
+
+func (f *fakeKMS) Decrypt(_ context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	handle := string(in.CiphertextBlob)
+	key, ok := f.keys[handle]
+	if !ok || fmt.Sprint(f.ctxs[handle]) != fmt.Sprint(in.EncryptionContext) {
+		return nil, fmt.Errorf("InvalidCiphertextException")
+	}
+	return &kms.DecryptOutput{Plaintext: append([]byte(nil), key...)}, nil
+}
+
+func TestSealAndOpenEnvelope(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeKMS()
+	secrets := map[string]map[string]interface{}{
+		"db": {"password": "hunter2"},
+	}
+
+	env, err := sealEnvelope(ctx, client, "alias/vss-envelopes", "Analytics_Prod", secrets)
+	require.NoError(t, err)
+	assert.Equal(t, "Analytics_Prod", env.Target)
+	assert.Equal(t, "alias/vss-envelopes", env.KeyID)
+	assert.Equal(t, map[string]string{"vss:target": "Analytics_Prod"}, env.EncryptionContext)
+	assert.NotContains(t, string(env.Ciphertext), "hunter2")
+
+	opened, err := OpenEnvelope(ctx, client, env)
+	require.NoError(t, err)
+	assert.Equal(t, secrets, opened)
+
+	// An envelope relabelled for another target does not open
+	env.EncryptionContext = map[string]string{"vss:target": "Sandbox_Alice"}
+	_, err = OpenEnvelope(ctx, client, env)
+	assert.ErrorContains(t, err, "failed to decrypt data key")
+}
+
+func TestGrantEnvelope(t *testing.T) {
+	client := newFakeKMS()
+	grantees := []string{"arn:aws:iam::111111111111:role/analytics-app"}
+	require.NoError(t, grantEnvelope(context.Background(), client, "alias/vss-envelopes", "Analytics.Prod", grantees))
+	require.Len(t, client.grants, 1)
+	g := client.grants[0]
+	assert.Equal(t, grantees[0], aws.ToString(g.GranteePrincipal))
+	assert.Equal(t, []kmstypes.GrantOperation{kmstypes.GrantOperationDecrypt}, g.Operations)
+	assert.Equal(t, map[string]string{"vss:target": "Analytics.Prod"}, g.Constraints.EncryptionContextEquals)
+	assert.Equal(t, "vss-Analytics-Prod", aws.ToString(g.Name))
+}
+
+func TestTargetEnvelopeValidate(t *testing.T) {
+	cfg := &Config{MergeStore: MergeStoreConfig{S3: &MergeStoreS3{Bucket: "secrets", EnvelopeKeyID: "alias/vss-envelopes"}}}
+	target := Target{AccountID: "111111111111", Envelope: &TargetEnvelope{}}
+	assert.NoError(t, target.Envelope.validate(cfg, target))
+	assert.Equal(t, []string{"arn:aws:iam::111111111111:role/analytics"}, target.Envelope.grantees(cfg, Target{AccountID: "111111111111", RoleARN: "arn:aws:iam::111111111111:role/analytics"}))
+
+	target.Envelope.Grantees = []string{"analytics-app"}
+	assert.EqualError(t, target.Envelope.validate(cfg, target), `grantee "analytics-app" must be an IAM principal ARN`)
+
+	target = Target{GitHub: &GitHubDestination{Owner: "acme", Repo: "web"}, Envelope: &TargetEnvelope{}}
+	assert.EqualError(t, target.Envelope.validate(cfg, target), "cannot be combined with destinations, github or kubernetes")
+
+	cfg.MergeStore.S3.EnvelopeKeyID = ""
+	assert.EqualError(t, target.Envelope.validate(cfg, target), "requires merge_store.s3.envelope_key_id")
+}
+
+func TestS3MergeStoreEnvelopeKey(t *testing.T) {
+	store := &S3MergeStore{Bucket: "secrets", Prefix: "merged"}
+	assert.Equal(t, "merged/Analytics_Prod.envelope.json", store.envelopeKey("Analytics_Prod"))
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// TestExamples checks that every shipped example is accepted by what reads
+// it: pipeline configs by vss validate, VaultSecretSync manifests by the
+// operator's API types and the operator config by its loader
+func TestExamples(t *testing.T) {
+	files, err := filepath.Glob("../../examples/*.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			require.NoError(t, err)
+			var doc map[string]interface{}
+			require.NoError(t, yaml.Unmarshal(data, &doc))
+
+			switch {
+			case doc["apiVersion"] != nil:
+				// Manifests are read as JSON by the API server
+				js, err := json.Marshal(doc)
+				require.NoError(t, err)
+				dec := json.NewDecoder(bytes.NewReader(js))
+				dec.DisallowUnknownFields()
+				var sync v1alpha1.VaultSecretSync
+				require.NoError(t, dec.Decode(&sync))
+				assert.Equal(t, "VaultSecretSync", sync.Kind)
+			case doc["targets"] != nil:
+				cfg, err := LoadConfigWithOptions(file, LoadOptions{Strict: true})
+				require.NoError(t, err)
+				assert.NoError(t, cfg.Validate())
+			default:
+				var cfg config.ConfigFile
+				assert.NoError(t, yaml.Unmarshal(data, &cfg))
+			}
+		})
+	}
+}
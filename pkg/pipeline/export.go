@@ -0,0 +1,445 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportFormat identifies an infrastructure-as-code export format
+type ExportFormat string
+
+const (
+	// ExportFormatPulumi renders a Pulumi YAML program
+	ExportFormatPulumi ExportFormat = "pulumi"
+	// ExportFormatCrossplane renders a Crossplane XRD, Composition and one composite resource per target
+	ExportFormatCrossplane ExportFormat = "crossplane"
+)
+
+// ExportOptions controls how targets are rendered for external IaC tools
+type ExportOptions struct {
+	// TrustedAccountID is the account the pipeline runs from and that target roles must trust.
+	// Defaults to aws.execution_context.account_id.
+	TrustedAccountID string
+	// BootstrapRoleName is the pre-existing role used to create target roles (Pulumi providers).
+	BootstrapRoleName string
+	// Name is used for the Pulumi project and Crossplane composition names.
+	Name string
+}
+
+// ExportTarget describes the cross-account access a single target requires
+type ExportTarget struct {
+	Name         string
+	AccountID    string
+	Region       string
+	RoleARN      string
+	RoleName     string
+	RolePath     string
+	SecretPrefix string
+}
+
+// SecretsResourceARN returns the Secrets Manager ARN pattern the target role must manage
+func (t ExportTarget) SecretsResourceARN() string {
+	return fmt.Sprintf("arn:aws:secretsmanager:%s:%s:secret:%s*", t.Region, t.AccountID, t.SecretPrefix)
+}
+
+// ExportTargets resolves every target (including discovered ones already expanded into
+// the config) into the role and account information needed by exporters, sorted by name.
+func (c *Config) ExportTargets() []ExportTarget {
+	names := make([]string, 0, len(c.Targets))
+	for name := range c.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	targets := make([]ExportTarget, 0, len(names))
+	for _, name := range names {
+		t := c.Targets[name]
+		for _, d := range t.ResolvedDestinations() {
+			if d.Doppler != nil || d.GitHub != nil || d.Kubernetes != nil {
+				// Only Secrets Manager destinations need a cross-account secrets role
+				continue
+			}
+			exportName := name
+			if len(t.Destinations) > 0 {
+				exportName = fmt.Sprintf("%s_%s", name, sanitizeTargetName(d.Label()))
+			}
+			region := d.Region
+			if region == "" {
+				region = t.Region
+			}
+			if region == "" {
+				region = c.AWS.Region
+			}
+			roleARN := d.RoleARN
+			if roleARN == "" {
+				roleARN = c.GetRoleARN(d.AccountID)
+			}
+			rolePath, roleName := splitRoleARN(roleARN)
+			targets = append(targets, ExportTarget{
+				Name:         exportName,
+				AccountID:    d.AccountID,
+				Region:       region,
+				RoleARN:      roleARN,
+				RoleName:     roleName,
+				RolePath:     rolePath,
+				SecretPrefix: t.SecretPrefix,
+			})
+		}
+	}
+	return targets
+}
+
+// splitRoleARN splits an IAM role ARN into its path and name
+func splitRoleARN(arn string) (string, string) {
+	idx := strings.Index(arn, ":role/")
+	if idx < 0 {
+		return "/", arn
+	}
+	full := arn[idx+len(":role"):]
+	last := strings.LastIndex(full, "/")
+	return full[:last+1], full[last+1:]
+}
+
+// Export renders the config's targets and their cross-account roles in the given format
+func (c *Config) Export(format ExportFormat, opts ExportOptions) ([]byte, error) {
+	if opts.TrustedAccountID == "" {
+		opts.TrustedAccountID = c.AWS.ExecutionContext.AccountID
+	}
+	if opts.TrustedAccountID == "" {
+		return nil, fmt.Errorf("trusted account ID is required (set aws.execution_context.account_id)")
+	}
+	if !isValidAWSAccountID(opts.TrustedAccountID) {
+		return nil, fmt.Errorf("invalid trusted account ID %q (must be 12 digits)", opts.TrustedAccountID)
+	}
+	if opts.BootstrapRoleName == "" {
+		opts.BootstrapRoleName = "OrganizationAccountAccessRole"
+	}
+	if opts.Name == "" {
+		opts.Name = "vss-targets"
+	}
+
+	targets := c.ExportTargets()
+	switch format {
+	case ExportFormatPulumi:
+		return exportPulumi(targets, opts)
+	case ExportFormatCrossplane:
+		return exportCrossplane(targets, opts)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// trustPolicy returns the assume-role policy document trusting the pipeline account
+func trustPolicy(trustedAccountID string) map[string]any {
+	return map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []any{
+			map[string]any{
+				"Effect":    "Allow",
+				"Action":    "sts:AssumeRole",
+				"Principal": map[string]any{"AWS": fmt.Sprintf("arn:aws:iam::%s:root", trustedAccountID)},
+			},
+		},
+	}
+}
+
+// secretsPolicy returns the permissions a target role needs for the sync phase
+func secretsPolicy(resourceARN string) map[string]any {
+	return map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []any{
+			map[string]any{
+				"Effect": "Allow",
+				"Action": []string{
+					"secretsmanager:CreateSecret",
+					"secretsmanager:DeleteSecret",
+					"secretsmanager:DescribeSecret",
+					"secretsmanager:GetSecretValue",
+					"secretsmanager:ListSecretVersionIds",
+					"secretsmanager:PutSecretValue",
+					"secretsmanager:TagResource",
+					"secretsmanager:UntagResource",
+					"secretsmanager:UpdateSecret",
+					"secretsmanager:UpdateSecretVersionStage",
+				},
+				"Resource": resourceARN,
+			},
+			map[string]any{
+				"Effect":   "Allow",
+				"Action":   "secretsmanager:ListSecrets",
+				"Resource": "*",
+			},
+		},
+	}
+}
+
+// exportResourceName converts a target name into a lowercase, hyphenated resource name
+func exportResourceName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", " ", "-", ".", "-").Replace(name)
+}
+
+// exportRoleResourceName names the resource of a target's role, which is
+// unique to its account, path and name
+func exportRoleResourceName(t ExportTarget) string {
+	role := strings.ReplaceAll(strings.Trim(t.RolePath+t.RoleName, "/"), "/", "-")
+	return fmt.Sprintf("account-%s-%s-role", t.AccountID, exportResourceName(role))
+}
+
+type pulumiProgram struct {
+	Name        string                    `yaml:"name"`
+	Runtime     string                    `yaml:"runtime"`
+	Description string                    `yaml:"description"`
+	Resources   map[string]pulumiResource `yaml:"resources"`
+	Outputs     map[string]any            `yaml:"outputs"`
+}
+
+type pulumiResource struct {
+	Type       string         `yaml:"type"`
+	Properties map[string]any `yaml:"properties"`
+	Options    map[string]any `yaml:"options,omitempty"`
+}
+
+func exportPulumi(targets []ExportTarget, opts ExportOptions) ([]byte, error) {
+	prog := pulumiProgram{
+		Name:        opts.Name,
+		Runtime:     "yaml",
+		Description: "Cross-account roles required by vss pipeline targets",
+		Resources:   make(map[string]pulumiResource),
+		Outputs:     make(map[string]any),
+	}
+
+	// Targets in the same account that use the same role share one role
+	// resource; each attaches its own secrets policy to it
+	roleTargets := make(map[string][]string)
+	for _, t := range targets {
+		role := exportRoleResourceName(t)
+		roleTargets[role] = append(roleTargets[role], t.Name)
+	}
+
+	roleArns := make(map[string]any)
+	for _, t := range targets {
+		provider := fmt.Sprintf("account-%s-provider", t.AccountID)
+		role := exportRoleResourceName(t)
+
+		if _, ok := prog.Resources[provider]; !ok {
+			prog.Resources[provider] = pulumiResource{
+				Type: "pulumi:providers:aws",
+				Properties: map[string]any{
+					"region": t.Region,
+					"assumeRole": map[string]any{
+						"roleArn": fmt.Sprintf("arn:aws:iam::%s:role/%s", t.AccountID, opts.BootstrapRoleName),
+					},
+				},
+			}
+		}
+		if _, ok := prog.Resources[role]; !ok {
+			tags := map[string]string{"vss:managed-by": "vss"}
+			if names := roleTargets[role]; len(names) == 1 {
+				tags["vss:target"] = names[0]
+			}
+			prog.Resources[role] = pulumiResource{
+				Type: "aws:iam:Role",
+				Properties: map[string]any{
+					"name":             t.RoleName,
+					"path":             t.RolePath,
+					"assumeRolePolicy": map[string]any{"fn::toJSON": trustPolicy(opts.TrustedAccountID)},
+					"tags":             tags,
+				},
+				Options: map[string]any{"provider": fmt.Sprintf("${%s}", provider)},
+			}
+		}
+		prog.Resources[exportResourceName(t.Name)+"-secrets-policy"] = pulumiResource{
+			Type: "aws:iam:RolePolicy",
+			Properties: map[string]any{
+				"role":   fmt.Sprintf("${%s.name}", role),
+				"policy": map[string]any{"fn::toJSON": secretsPolicy(t.SecretsResourceARN())},
+			},
+			Options: map[string]any{"provider": fmt.Sprintf("${%s}", provider)},
+		}
+		roleArns[t.Name] = fmt.Sprintf("${%s.arn}", role)
+	}
+	prog.Outputs["roleArns"] = roleArns
+
+	return yaml.Marshal(prog)
+}
+
+const (
+	crossplaneGroup = "secretsync.jbcom.dev"
+	crossplaneKind  = "XSecretsSyncTarget"
+)
+
+func exportCrossplane(targets []ExportTarget, opts ExportOptions) ([]byte, error) {
+	docs := []any{
+		crossplaneXRD(),
+		crossplaneComposition(opts),
+	}
+
+	// One ProviderConfig per account so composed resources land in the right account
+	seen := make(map[string]bool)
+	for _, t := range targets {
+		if seen[t.AccountID] {
+			continue
+		}
+		seen[t.AccountID] = true
+		docs = append(docs, map[string]any{
+			"apiVersion": "aws.upbound.io/v1beta1",
+			"kind":       "ProviderConfig",
+			"metadata":   map[string]any{"name": "vss-" + t.AccountID},
+			"spec": map[string]any{
+				"credentials": map[string]string{"source": "IRSA"},
+				"assumeRoleChain": []any{
+					map[string]string{"roleARN": fmt.Sprintf("arn:aws:iam::%s:role/%s", t.AccountID, opts.BootstrapRoleName)},
+				},
+			},
+		})
+	}
+
+	for _, t := range targets {
+		docs = append(docs, map[string]any{
+			"apiVersion": crossplaneGroup + "/v1alpha1",
+			"kind":       crossplaneKind,
+			"metadata": map[string]any{
+				"name":   exportResourceName(t.Name),
+				"labels": map[string]string{"secretsync.jbcom.dev/target": exportResourceName(t.Name)},
+			},
+			"spec": map[string]any{
+				"targetName":         t.Name,
+				"accountId":          t.AccountID,
+				"region":             t.Region,
+				"roleName":           t.RoleName,
+				"rolePath":           t.RolePath,
+				"trustedAccountId":   opts.TrustedAccountID,
+				"secretsResourceArn": t.SecretsResourceARN(),
+				"compositionRef":     map[string]string{"name": opts.Name},
+			},
+		})
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to encode crossplane document: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func crossplaneXRD() map[string]any {
+	stringProp := map[string]string{"type": "string"}
+	return map[string]any{
+		"apiVersion": "apiextensions.crossplane.io/v1",
+		"kind":       "CompositeResourceDefinition",
+		"metadata":   map[string]any{"name": "xsecretssynctargets." + crossplaneGroup},
+		"spec": map[string]any{
+			"group": crossplaneGroup,
+			"names": map[string]string{"kind": crossplaneKind, "plural": "xsecretssynctargets"},
+			"versions": []any{
+				map[string]any{
+					"name":          "v1alpha1",
+					"served":        true,
+					"referenceable": true,
+					"schema": map[string]any{
+						"openAPIV3Schema": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"spec": map[string]any{
+									"type": "object",
+									"required": []string{
+										"accountId", "region", "roleName", "trustedAccountId", "secretsResourceArn",
+									},
+									"properties": map[string]any{
+										"targetName":         stringProp,
+										"accountId":          stringProp,
+										"region":             stringProp,
+										"roleName":           stringProp,
+										"rolePath":           stringProp,
+										"trustedAccountId":   stringProp,
+										"secretsResourceArn": stringProp,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func crossplaneComposition(opts ExportOptions) map[string]any {
+	patch := func(from, to string) map[string]any {
+		return map[string]any{"type": "FromCompositeFieldPath", "fromFieldPath": from, "toFieldPath": to}
+	}
+	format := func(from, to, fmtStr string) map[string]any {
+		return map[string]any{
+			"type":          "FromCompositeFieldPath",
+			"fromFieldPath": from,
+			"toFieldPath":   to,
+			"transforms": []any{
+				map[string]any{"type": "string", "string": map[string]string{"type": "Format", "fmt": fmtStr}},
+			},
+		}
+	}
+	providerConfigPatch := format("spec.accountId", "spec.providerConfigRef.name", "vss-%s")
+
+	role := map[string]any{
+		"name": "role",
+		"base": map[string]any{
+			"apiVersion": "iam.aws.upbound.io/v1beta1",
+			"kind":       "Role",
+			"spec": map[string]any{
+				"forProvider": map[string]any{
+					"tags": map[string]string{"vss:managed-by": "vss"},
+				},
+			},
+		},
+		"patches": []any{
+			patch("spec.roleName", "metadata.annotations[crossplane.io/external-name]"),
+			patch("spec.rolePath", "spec.forProvider.path"),
+			patch("spec.targetName", "spec.forProvider.tags[vss:target]"),
+			format("spec.trustedAccountId", "spec.forProvider.assumeRolePolicy",
+				`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"AWS":"arn:aws:iam::%s:root"}}]}`),
+			providerConfigPatch,
+		},
+	}
+	policy := map[string]any{
+		"name": "secrets-policy",
+		"base": map[string]any{
+			"apiVersion": "iam.aws.upbound.io/v1beta1",
+			"kind":       "RolePolicy",
+			"spec": map[string]any{
+				"forProvider": map[string]any{
+					"roleSelector": map[string]any{"matchControllerRef": true},
+				},
+			},
+		},
+		"patches": []any{
+			format("spec.secretsResourceArn", "spec.forProvider.policy",
+				`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["secretsmanager:CreateSecret","secretsmanager:DeleteSecret","secretsmanager:DescribeSecret","secretsmanager:GetSecretValue","secretsmanager:ListSecretVersionIds","secretsmanager:PutSecretValue","secretsmanager:TagResource","secretsmanager:UntagResource","secretsmanager:UpdateSecret","secretsmanager:UpdateSecretVersionStage"],"Resource":"%s"},{"Effect":"Allow","Action":"secretsmanager:ListSecrets","Resource":"*"}]}`),
+			providerConfigPatch,
+		},
+	}
+
+	return map[string]any{
+		"apiVersion": "apiextensions.crossplane.io/v1",
+		"kind":       "Composition",
+		"metadata":   map[string]any{"name": opts.Name},
+		"spec": map[string]any{
+			"compositeTypeRef": map[string]string{
+				"apiVersion": crossplaneGroup + "/v1alpha1",
+				"kind":       crossplaneKind,
+			},
+			"resources": []any{role, policy},
+		},
+	}
+}
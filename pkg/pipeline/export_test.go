@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func exportTestConfig() *Config {
+	return &Config{
+		AWS: AWSConfig{
+			Region: "us-east-1",
+			ExecutionContext: ExecutionContextConfig{
+				AccountID: "999999999999",
+			},
+			ControlTower: ControlTowerConfig{
+				Enabled:       true,
+				ExecutionRole: ExecutionRoleConfig{Name: "AWSControlTowerExecution"},
+			},
+		},
+		Targets: map[string]Target{
+			"Serverless_Stg": {AccountID: "111111111111", SecretPrefix: "app/"},
+			"Serverless_Prod": {
+				AccountID: "222222222222",
+				Region:    "eu-west-1",
+				RoleARN:   "arn:aws:iam::222222222222:role/platform/SecretsSync",
+			},
+		},
+	}
+}
+
+func TestExportTargets(t *testing.T) {
+	targets := exportTestConfig().ExportTargets()
+	require.Len(t, targets, 2)
+
+	// Sorted by name
+	assert.Equal(t, "Serverless_Prod", targets[0].Name)
+	assert.Equal(t, "eu-west-1", targets[0].Region)
+	assert.Equal(t, "SecretsSync", targets[0].RoleName)
+	assert.Equal(t, "/platform/", targets[0].RolePath)
+
+	assert.Equal(t, "Serverless_Stg", targets[1].Name)
+	assert.Equal(t, "us-east-1", targets[1].Region)
+	assert.Equal(t, "AWSControlTowerExecution", targets[1].RoleName)
+	assert.Equal(t, "/", targets[1].RolePath)
+	assert.Equal(t, "arn:aws:secretsmanager:us-east-1:111111111111:secret:app/*", targets[1].SecretsResourceARN())
+}
+
+func TestExportPulumi(t *testing.T) {
+	out, err := exportTestConfig().Export(ExportFormatPulumi, ExportOptions{})
+	require.NoError(t, err)
+
+	var prog map[string]any
+	require.NoError(t, yaml.Unmarshal(out, &prog))
+	assert.Equal(t, "yaml", prog["runtime"])
+
+	resources := prog["resources"].(map[string]any)
+	assert.Contains(t, resources, "account-111111111111-provider")
+	assert.Contains(t, resources, "account-111111111111-awscontroltowerexecution-role")
+	assert.Contains(t, resources, "account-222222222222-platform-secretssync-role")
+	assert.Contains(t, resources, "serverless-prod-secrets-policy")
+	assert.Contains(t, string(out), "arn:aws:iam::999999999999:root")
+	assert.Contains(t, string(out), "arn:aws:iam::111111111111:role/OrganizationAccountAccessRole")
+}
+
+func TestExportPulumiSharedRole(t *testing.T) {
+	cfg := exportTestConfig()
+	cfg.Targets["Analytics_Stg"] = Target{AccountID: "111111111111", SecretPrefix: "analytics/"}
+	out, err := cfg.Export(ExportFormatPulumi, ExportOptions{})
+	require.NoError(t, err)
+
+	var prog struct {
+		Resources map[string]pulumiResource    `yaml:"resources"`
+		Outputs   map[string]map[string]string `yaml:"outputs"`
+	}
+	require.NoError(t, yaml.Unmarshal(out, &prog))
+
+	// Both targets use AWSControlTowerExecution in 111111111111: one role, two policies
+	var roles []string
+	for name, r := range prog.Resources {
+		if r.Type == "aws:iam:Role" {
+			roles = append(roles, name)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"account-111111111111-awscontroltowerexecution-role",
+		"account-222222222222-platform-secretssync-role",
+	}, roles)
+	shared := prog.Resources["account-111111111111-awscontroltowerexecution-role"]
+	assert.Equal(t, map[string]any{"vss:managed-by": "vss"}, shared.Properties["tags"])
+
+	for _, name := range []string{"analytics-stg-secrets-policy", "serverless-stg-secrets-policy"} {
+		policy := prog.Resources[name]
+		assert.Equal(t, "${account-111111111111-awscontroltowerexecution-role.name}", policy.Properties["role"])
+		assert.Equal(t, "${account-111111111111-provider}", policy.Options["provider"])
+	}
+	assert.Contains(t, string(out), "arn:aws:secretsmanager:us-east-1:111111111111:secret:analytics/*")
+	assert.Contains(t, string(out), "arn:aws:secretsmanager:us-east-1:111111111111:secret:app/*")
+	assert.Equal(t, "${account-111111111111-awscontroltowerexecution-role.arn}", prog.Outputs["roleArns"]["Analytics_Stg"])
+	assert.Equal(t, "${account-111111111111-awscontroltowerexecution-role.arn}", prog.Outputs["roleArns"]["Serverless_Stg"])
+}
+
+func TestExportCrossplane(t *testing.T) {
+	out, err := exportTestConfig().Export(ExportFormatCrossplane, ExportOptions{Name: "vss"})
+	require.NoError(t, err)
+
+	var kinds []string
+	dec := yaml.NewDecoder(bytes.NewReader(out))
+	for {
+		var doc map[string]any
+		if err := dec.Decode(&doc); err != nil {
+			break
+		}
+		kinds = append(kinds, doc["kind"].(string))
+	}
+	assert.Equal(t, []string{
+		"CompositeResourceDefinition",
+		"Composition",
+		"ProviderConfig",
+		"ProviderConfig",
+		"XSecretsSyncTarget",
+		"XSecretsSyncTarget",
+	}, kinds)
+	assert.True(t, strings.Contains(string(out), "trustedAccountId: \"999999999999\""))
+}
+
+func TestExportErrors(t *testing.T) {
+	cfg := exportTestConfig()
+	cfg.AWS.ExecutionContext.AccountID = ""
+
+	_, err := cfg.Export(ExportFormatPulumi, ExportOptions{})
+	assert.Error(t, err)
+
+	_, err = cfg.Export(ExportFormatPulumi, ExportOptions{TrustedAccountID: "123"})
+	assert.Error(t, err)
+
+	_, err = cfg.Export("terraform", ExportOptions{TrustedAccountID: "999999999999"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,275 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// FreezeWindow blocks apply-mode runs against matching targets while it is active.
+// A window is either a fixed start/end range, a recurring cron schedule that opens
+// the window for a duration, or an iCalendar file whose events are the windows.
+//
+//	pipeline:
+//	  freeze_windows:
+//	    - name: year-end
+//	      start: "2026-12-20T00:00:00Z"
+//	      end: "2027-01-04T00:00:00Z"
+//	      classifications: [production]
+//	    - name: weekends
+//	      cron: "CRON_TZ=America/New_York 0 18 * * FRI"
+//	      duration: 62h
+//	      classifications: [production]
+//	    - name: change-calendar
+//	      ical: /etc/vss/freeze.ics
+//	      targets: [Serverless_Prod]
+//
+// Windows without classifications or targets apply to every target.
+type FreezeWindow struct {
+	Name string `mapstructure:"name" yaml:"name"`
+
+	Start time.Time `mapstructure:"start" yaml:"start,omitempty"`
+	End   time.Time `mapstructure:"end" yaml:"end,omitempty"`
+
+	Cron     string        `mapstructure:"cron" yaml:"cron,omitempty"`
+	Duration time.Duration `mapstructure:"duration" yaml:"duration,omitempty"`
+
+	// ICal is the path to an .ics file; each VEVENT's DTSTART/DTEND is a window
+	ICal string `mapstructure:"ical" yaml:"ical,omitempty"`
+
+	Classifications []string `mapstructure:"classifications" yaml:"classifications,omitempty"`
+	Targets         []string `mapstructure:"targets" yaml:"targets,omitempty"`
+}
+
+// FreezeOverride records who bypassed an active freeze window and why
+type FreezeOverride struct {
+	By     string
+	Reason string
+}
+
+// FrozenTarget is a target blocked by an active freeze window
+type FrozenTarget struct {
+	Target string
+	Window string
+	Until  time.Time
+}
+
+func (f FrozenTarget) String() string {
+	return fmt.Sprintf("%s (freeze window %q until %s)", f.Target, f.Window, f.Until.UTC().Format(time.RFC3339))
+}
+
+func (w FreezeWindow) validate() error {
+	kinds := 0
+	if !w.Start.IsZero() || !w.End.IsZero() {
+		kinds++
+		if w.Start.IsZero() || w.End.IsZero() {
+			return fmt.Errorf("start and end are both required")
+		}
+		if !w.End.After(w.Start) {
+			return fmt.Errorf("end must be after start")
+		}
+	}
+	if w.Cron != "" {
+		kinds++
+		if _, err := cron.ParseStandard(w.Cron); err != nil {
+			return fmt.Errorf("invalid cron %q: %w", w.Cron, err)
+		}
+		if w.Duration <= 0 {
+			return fmt.Errorf("duration is required with cron")
+		}
+	}
+	if w.ICal != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of start/end, cron or ical is required")
+	}
+	return nil
+}
+
+// activeUntil reports whether the window is active at now and when it ends
+func (w FreezeWindow) activeUntil(now time.Time) (time.Time, bool, error) {
+	switch {
+	case w.Cron != "":
+		sched, err := cron.ParseStandard(w.Cron)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		// The most recent opening is the first one after now-duration
+		opened := sched.Next(now.Add(-w.Duration))
+		if opened.After(now) {
+			return time.Time{}, false, nil
+		}
+		return opened.Add(w.Duration), true, nil
+	case w.ICal != "":
+		events, err := readICalEvents(w.ICal)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		for _, ev := range events {
+			if !now.Before(ev[0]) && now.Before(ev[1]) {
+				return ev[1], true, nil
+			}
+		}
+		return time.Time{}, false, nil
+	default:
+		return w.End, !now.Before(w.Start) && now.Before(w.End), nil
+	}
+}
+
+// appliesTo reports whether the window covers the target
+func (w FreezeWindow) appliesTo(name string, target Target) bool {
+	if len(w.Classifications) == 0 && len(w.Targets) == 0 {
+		return true
+	}
+	return containsString(w.Targets, name) ||
+		(target.Classification != "" && containsString(w.Classifications, target.Classification))
+}
+
+// FrozenTargets returns the targets that an active freeze window blocks at now
+func (c *Config) FrozenTargets(now time.Time, targets []string) ([]FrozenTarget, error) {
+	var frozen []FrozenTarget
+	for _, w := range c.Pipeline.FreezeWindows {
+		until, active, err := w.activeUntil(now)
+		if err != nil {
+			return nil, fmt.Errorf("freeze window %q: %w", w.Name, err)
+		}
+		if !active {
+			continue
+		}
+		for _, name := range targets {
+			if w.appliesTo(name, c.Targets[name]) {
+				frozen = append(frozen, FrozenTarget{Target: name, Window: w.Name, Until: until})
+			}
+		}
+	}
+	sort.Slice(frozen, func(i, j int) bool {
+		if frozen[i].Target != frozen[j].Target {
+			return frozen[i].Target < frozen[j].Target
+		}
+		return frozen[i].Window < frozen[j].Window
+	})
+	return frozen, nil
+}
+
+// CheckFreeze returns an error if an active freeze window blocks any of targets.
+// With an override the run is allowed and the override is written to the audit log.
+func (c *Config) CheckFreeze(now time.Time, targets []string, override *FreezeOverride) error {
+	frozen, err := c.FrozenTargets(now, targets)
+	if err != nil {
+		return err
+	}
+	if len(frozen) == 0 {
+		return nil
+	}
+
+	if override == nil {
+		blocked := make([]string, 0, len(frozen))
+		for _, f := range frozen {
+			blocked = append(blocked, f.String())
+		}
+		return fmt.Errorf("apply blocked by freeze window: %s", strings.Join(blocked, ", "))
+	}
+
+	for _, f := range frozen {
+		log.WithFields(log.Fields{
+			"action":     "CheckFreeze",
+			"audit":      true,
+			"target":     f.Target,
+			"window":     f.Window,
+			"until":      f.Until.UTC().Format(time.RFC3339),
+			"overrideBy": override.By,
+			"reason":     override.Reason,
+		}).Warn("Freeze window overridden")
+	}
+	return nil
+}
+
+// readICalEvents returns the [start, end) range of each VEVENT in an .ics file.
+// Recurrence rules are not expanded; each occurrence must be its own event.
+func readICalEvents(path string) ([][2]time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ical file: %w", err)
+	}
+	defer f.Close()
+
+	// Unfold continuation lines (RFC 5545 section 3.1)
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ical file: %w", err)
+	}
+
+	var events [][2]time.Time
+	var start, end time.Time
+	inEvent := false
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(key, ";")
+		switch {
+		case line == "BEGIN:VEVENT":
+			inEvent = true
+			start, end = time.Time{}, time.Time{}
+		case line == "END:VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				continue
+			}
+			if end.IsZero() {
+				// An all-day event without DTEND lasts one day
+				end = start.AddDate(0, 0, 1)
+			}
+			events = append(events, [2]time.Time{start, end})
+		case inEvent && (name == "DTSTART" || name == "DTEND"):
+			t, err := parseICalTime(params, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			if name == "DTSTART" {
+				start = t
+			} else {
+				end = t
+			}
+		}
+	}
+	return events, nil
+}
+
+func parseICalTime(params, value string) (time.Time, error) {
+	loc := time.UTC
+	for _, p := range strings.Split(params, ";") {
+		if tz, ok := strings.CutPrefix(p, "TZID="); ok {
+			l, err := time.LoadLocation(tz)
+			if err != nil {
+				return time.Time{}, err
+			}
+			loc = l
+		}
+	}
+	switch {
+	case len(value) == 8:
+		return time.ParseInLocation("20060102", value, loc)
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	default:
+		return time.ParseInLocation("20060102T150405", value, loc)
+	}
+}
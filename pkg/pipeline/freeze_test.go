@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func freezeTestConfig(windows ...FreezeWindow) *Config {
+	return &Config{
+		Targets: map[string]Target{
+			"Serverless_Stg":  {Classification: "staging"},
+			"Serverless_Prod": {Classification: "production"},
+			"livequery_demos": {},
+		},
+		Pipeline: PipelineSettings{FreezeWindows: windows},
+	}
+}
+
+func TestFreezeWindowYAML(t *testing.T) {
+	content := `
+freeze_windows:
+  - name: year-end
+    start: "2026-12-20T00:00:00Z"
+    end: "2027-01-04T00:00:00Z"
+    classifications: [production]
+  - name: weekends
+    cron: "0 18 * * FRI"
+    duration: 62h
+`
+	var settings PipelineSettings
+	require.NoError(t, yaml.Unmarshal([]byte(content), &settings))
+	require.Len(t, settings.FreezeWindows, 2)
+	assert.Equal(t, time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC), settings.FreezeWindows[0].Start.UTC())
+	assert.Equal(t, 62*time.Hour, settings.FreezeWindows[1].Duration)
+	for _, w := range settings.FreezeWindows {
+		assert.NoError(t, w.validate())
+	}
+}
+
+func TestFrozenTargetsRange(t *testing.T) {
+	cfg := freezeTestConfig(FreezeWindow{
+		Name:            "year-end",
+		Start:           time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC),
+		End:             time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC),
+		Classifications: []string{"production"},
+		Targets:         []string{"livequery_demos"},
+	})
+	all := []string{"Serverless_Stg", "Serverless_Prod", "livequery_demos"}
+
+	frozen, err := cfg.FrozenTargets(time.Date(2026, 12, 24, 12, 0, 0, 0, time.UTC), all)
+	require.NoError(t, err)
+	require.Len(t, frozen, 2)
+	assert.Equal(t, "Serverless_Prod", frozen[0].Target)
+	assert.Equal(t, "livequery_demos", frozen[1].Target)
+
+	frozen, err = cfg.FrozenTargets(time.Date(2027, 1, 4, 0, 0, 0, 0, time.UTC), all)
+	require.NoError(t, err)
+	assert.Empty(t, frozen)
+}
+
+func TestFrozenTargetsCron(t *testing.T) {
+	// Friday 18:00 UTC through Monday 08:00 UTC
+	cfg := freezeTestConfig(FreezeWindow{Name: "weekends", Cron: "0 18 * * FRI", Duration: 62 * time.Hour})
+
+	saturday := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	frozen, err := cfg.FrozenTargets(saturday, []string{"Serverless_Stg"})
+	require.NoError(t, err)
+	require.Len(t, frozen, 1)
+	assert.Equal(t, time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC), frozen[0].Until)
+
+	wednesday := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	frozen, err = cfg.FrozenTargets(wednesday, []string{"Serverless_Stg"})
+	require.NoError(t, err)
+	assert.Empty(t, frozen)
+}
+
+func TestFrozenTargetsICal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "freeze.ics")
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Launch\r\nDTSTART:20261101T000000Z\r\nDTEND:20261103T000000Z\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261225\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	require.NoError(t, os.WriteFile(path, []byte(ics), 0o600))
+	cfg := freezeTestConfig(FreezeWindow{Name: "calendar", ICal: path, Classifications: []string{"production"}})
+
+	for _, now := range []time.Time{
+		time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 12, 25, 18, 0, 0, 0, time.UTC),
+	} {
+		frozen, err := cfg.FrozenTargets(now, []string{"Serverless_Stg", "Serverless_Prod"})
+		require.NoError(t, err)
+		require.Len(t, frozen, 1, now)
+		assert.Equal(t, "Serverless_Prod", frozen[0].Target)
+	}
+
+	frozen, err := cfg.FrozenTargets(time.Date(2026, 12, 26, 0, 0, 0, 0, time.UTC), []string{"Serverless_Prod"})
+	require.NoError(t, err)
+	assert.Empty(t, frozen)
+}
+
+func TestCheckFreeze(t *testing.T) {
+	cfg := freezeTestConfig(FreezeWindow{
+		Name:  "all",
+		Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	err := cfg.CheckFreeze(now, []string{"Serverless_Prod"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `Serverless_Prod (freeze window "all" until 2027-01-01T00:00:00Z)`)
+
+	assert.NoError(t, cfg.CheckFreeze(now, []string{"Serverless_Prod"}, &FreezeOverride{By: "alice", Reason: "INC-1"}))
+}
+
+func TestFreezeWindowValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		window FreezeWindow
+		errMsg string
+	}{
+		{name: "no schedule", window: FreezeWindow{Name: "x"}, errMsg: "exactly one of"},
+		{name: "missing end", window: FreezeWindow{Name: "x", Start: time.Now()}, errMsg: "start and end are both required"},
+		{name: "bad cron", window: FreezeWindow{Name: "x", Cron: "every friday", Duration: time.Hour}, errMsg: "invalid cron"},
+		{name: "cron without duration", window: FreezeWindow{Name: "x", Cron: "0 18 * * FRI"}, errMsg: "duration is required"},
+		{name: "two schedules", window: FreezeWindow{Name: "x", Cron: "0 18 * * FRI", Duration: time.Hour, ICal: "f.ics"}, errMsg: "exactly one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.window.validate(), tt.errMsg)
+		})
+	}
+}
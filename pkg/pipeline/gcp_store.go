@@ -0,0 +1,281 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"regexp"
+	"sort"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// gcpTargetLabel labels every merged secret with its target, so a target's
+	// secrets can be listed and access granted with IAM conditions per target
+	gcpTargetLabel = "vss-target"
+	// gcpTargetAnnotation and gcpSecretAnnotation keep the exact names, which
+	// labels and secret IDs cannot always represent
+	gcpTargetAnnotation = "vss.lestak.sh/target"
+	gcpSecretAnnotation = "vss.lestak.sh/secret"
+
+	// gcpSecretIDMax is the longest secret ID Secret Manager accepts
+	gcpSecretIDMax = 255
+)
+
+var (
+	gcpInvalidIDChars    = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+	gcpInvalidLabelChars = regexp.MustCompile(`[^a-z0-9_-]`)
+)
+
+// GCPMergeStore implements a merge store using GCP Secret Manager for
+// intermediate secret storage, for teams running the pipeline from GCP without
+// an S3 bucket. Each merged secret is one Secret Manager secret labelled with
+// its target.
+type GCPMergeStore struct {
+	Project              string
+	Prefix               string
+	ReplicationLocations []string
+
+	client *secretmanager.Client
+}
+
+// NewGCPMergeStore creates a new GCP Secret Manager-based merge store using
+// application default credentials
+func NewGCPMergeStore(ctx context.Context, cfg *MergeStoreGCP) (*GCPMergeStore, error) {
+	l := log.WithFields(log.Fields{
+		"action":  "NewGCPMergeStore",
+		"project": cfg.Project,
+		"prefix":  cfg.Prefix,
+	})
+	l.Debug("Creating GCP Secret Manager merge store")
+
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+	}
+
+	store := &GCPMergeStore{
+		Project:              cfg.Project,
+		Prefix:               cfg.Prefix,
+		ReplicationLocations: cfg.ReplicationLocations,
+		client:               client,
+	}
+
+	return store, nil
+}
+
+// secretID returns the Secret Manager secret ID for a target and secret name.
+// Names with characters secret IDs do not allow are sanitized and suffixed
+// with a hash of the exact names so they cannot collide.
+func (s *GCPMergeStore) secretID(targetName, secretName string) string {
+	raw := fmt.Sprintf("%s%s--%s", s.Prefix, targetName, secretName)
+	id := gcpInvalidIDChars.ReplaceAllString(raw, "-")
+	if id == raw && len(id) <= gcpSecretIDMax {
+		return id
+	}
+	sum := sha256.Sum256([]byte(targetName + "/" + secretName))
+	suffix := "-" + hex.EncodeToString(sum[:])[:12]
+	if len(id) > gcpSecretIDMax-len(suffix) {
+		id = id[:gcpSecretIDMax-len(suffix)]
+	}
+	return id + suffix
+}
+
+// secretName returns the full resource name of a merged secret
+func (s *GCPMergeStore) secretName(targetName, secretName string) string {
+	return fmt.Sprintf("projects/%s/secrets/%s", s.Project, s.secretID(targetName, secretName))
+}
+
+// targetLabel converts a target name to a label value: lowercase letters,
+// digits, underscores and dashes, at most 63 characters
+func targetLabel(targetName string) string {
+	v := gcpInvalidLabelChars.ReplaceAllString(strings.ToLower(targetName), "-")
+	if len(v) > 63 {
+		v = v[:63]
+	}
+	return v
+}
+
+// WriteSecret writes a secret to Secret Manager as a new version, creating the
+// secret on first write
+func (s *GCPMergeStore) WriteSecret(ctx context.Context, targetName, secretName string, data map[string]interface{}) error {
+	l := log.WithFields(log.Fields{
+		"action":     "GCPMergeStore.WriteSecret",
+		"project":    s.Project,
+		"target":     targetName,
+		"secretName": secretName,
+	})
+	l.Debug("Writing secret to GCP Secret Manager")
+
+	// Marshal secret data to JSON
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret data: %w", err)
+	}
+
+	name := s.secretName(targetName, secretName)
+	if _, err := s.client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: name}); err != nil {
+		if status.Code(err) != codes.NotFound {
+			return fmt.Errorf("failed to get secret: %w", err)
+		}
+		if err := s.createSecret(ctx, targetName, secretName); err != nil {
+			l.WithError(err).Error("Failed to create secret in GCP Secret Manager")
+			return err
+		}
+	}
+
+	checksum := int64(crc32.Checksum(jsonData, crc32.MakeTable(crc32.Castagnoli)))
+	_, err = s.client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
+		Parent: name,
+		Payload: &secretmanagerpb.SecretPayload{
+			Data:       jsonData,
+			DataCrc32C: &checksum,
+		},
+	})
+	if err != nil {
+		l.WithError(err).Error("Failed to write secret to GCP Secret Manager")
+		return fmt.Errorf("failed to add secret version: %w", err)
+	}
+
+	l.Debug("Successfully wrote secret to GCP Secret Manager")
+	return nil
+}
+
+// createSecret creates the secret that holds a merged secret's versions
+func (s *GCPMergeStore) createSecret(ctx context.Context, targetName, secretName string) error {
+	replication := &secretmanagerpb.Replication{
+		Replication: &secretmanagerpb.Replication_Automatic_{
+			Automatic: &secretmanagerpb.Replication_Automatic{},
+		},
+	}
+	if len(s.ReplicationLocations) > 0 {
+		var replicas []*secretmanagerpb.Replication_UserManaged_Replica
+		for _, loc := range s.ReplicationLocations {
+			replicas = append(replicas, &secretmanagerpb.Replication_UserManaged_Replica{Location: loc})
+		}
+		replication = &secretmanagerpb.Replication{
+			Replication: &secretmanagerpb.Replication_UserManaged_{
+				UserManaged: &secretmanagerpb.Replication_UserManaged{Replicas: replicas},
+			},
+		}
+	}
+
+	_, err := s.client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
+		Parent:   "projects/" + s.Project,
+		SecretId: s.secretID(targetName, secretName),
+		Secret: &secretmanagerpb.Secret{
+			Labels: map[string]string{
+				"managed-by":   "vault-secret-sync",
+				gcpTargetLabel: targetLabel(targetName),
+			},
+			Annotations: map[string]string{
+				gcpTargetAnnotation: targetName,
+				gcpSecretAnnotation: secretName,
+			},
+			Replication: replication,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
+	}
+	return nil
+}
+
+// ReadSecret reads the latest version of a secret from Secret Manager
+func (s *GCPMergeStore) ReadSecret(ctx context.Context, targetName, secretName string) (map[string]interface{}, error) {
+	l := log.WithFields(log.Fields{
+		"action":     "GCPMergeStore.ReadSecret",
+		"project":    s.Project,
+		"target":     targetName,
+		"secretName": secretName,
+	})
+	l.Debug("Reading secret from GCP Secret Manager")
+
+	output, err := s.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{
+		Name: s.secretName(targetName, secretName) + "/versions/latest",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to access secret version: %w", err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(output.Payload.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret: %w", err)
+	}
+
+	return data, nil
+}
+
+// ListSecrets lists all secrets for a target by its label
+func (s *GCPMergeStore) ListSecrets(ctx context.Context, targetName string) ([]string, error) {
+	l := log.WithFields(log.Fields{
+		"action":  "GCPMergeStore.ListSecrets",
+		"project": s.Project,
+		"target":  targetName,
+	})
+	l.Debug("Listing secrets from GCP Secret Manager")
+
+	var secrets []string
+	it := s.client.ListSecrets(ctx, &secretmanagerpb.ListSecretsRequest{
+		Parent: "projects/" + s.Project,
+		Filter: fmt.Sprintf("labels.%s=%s", gcpTargetLabel, targetLabel(targetName)),
+	})
+	for {
+		secret, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		// Label values are lowercased, so other targets can share a label
+		if secret.Annotations[gcpTargetAnnotation] != targetName {
+			continue
+		}
+		if !strings.HasPrefix(secret.Name, fmt.Sprintf("projects/%s/secrets/%s", s.Project, s.Prefix)) {
+			continue
+		}
+		if name := secret.Annotations[gcpSecretAnnotation]; name != "" {
+			secrets = append(secrets, name)
+		}
+	}
+	sort.Strings(secrets)
+
+	return secrets, nil
+}
+
+// DeleteSecret deletes a secret and all its versions from Secret Manager
+func (s *GCPMergeStore) DeleteSecret(ctx context.Context, targetName, secretName string) error {
+	l := log.WithFields(log.Fields{
+		"action":     "GCPMergeStore.DeleteSecret",
+		"project":    s.Project,
+		"target":     targetName,
+		"secretName": secretName,
+	})
+	l.Debug("Deleting secret from GCP Secret Manager")
+
+	err := s.client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{
+		Name: s.secretName(targetName, secretName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	return nil
+}
+
+// GetMergePath returns the Secret Manager "path" representation for a target
+// This is used for logging and reporting purposes
+func (s *GCPMergeStore) GetMergePath(targetName string) string {
+	return fmt.Sprintf("gcpsm://%s/%s%s", s.Project, s.Prefix, targetName)
+}
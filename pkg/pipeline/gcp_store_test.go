@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
+	internalSync "github.com/jbcom/secretsync/internal/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGCPMergeStoreSecretID(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		targetName string
+		secretName string
+		expected   string
+	}{
+		{
+			name:       "no prefix",
+			targetName: "Serverless_Stg",
+			secretName: "api-key",
+			expected:   "Serverless_Stg--api-key",
+		},
+		{
+			name:       "with prefix",
+			prefix:     "merged-",
+			targetName: "Serverless_Stg",
+			secretName: "api-key",
+			expected:   "merged-Serverless_Stg--api-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &GCPMergeStore{Project: "secrets-prod", Prefix: tt.prefix}
+			result := store.secretID(tt.targetName, tt.secretName)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	// Nested names are sanitized, and a hash keeps them from colliding
+	store := &GCPMergeStore{Project: "secrets-prod"}
+	id := store.secretID("Serverless_Stg", "db/password")
+	assert.True(t, strings.HasPrefix(id, "Serverless_Stg--db-password-"), id)
+	assert.Len(t, id, len("Serverless_Stg--db-password-")+12)
+	assert.NotEqual(t, store.secretID("Stg", "db/password"), store.secretID("Stg", "db.password"))
+	assert.LessOrEqual(t, len(store.secretID("Stg", strings.Repeat("x", 300))), gcpSecretIDMax)
+}
+
+func TestGCPMergeStoreGetMergePath(t *testing.T) {
+	store := &GCPMergeStore{Project: "secrets-prod", Prefix: "merged-"}
+	assert.Equal(t, "gcpsm://secrets-prod/merged-Serverless_Prod", store.GetMergePath("Serverless_Prod"))
+	assert.Equal(t, "projects/secrets-prod/secrets/merged-Serverless_Prod--api-key", store.secretName("Serverless_Prod", "api-key"))
+}
+
+func TestTargetLabel(t *testing.T) {
+	assert.Equal(t, "serverless_stg", targetLabel("Serverless_Stg"))
+	assert.Equal(t, "payments-eu", targetLabel("Payments.EU"))
+	assert.Len(t, targetLabel(strings.Repeat("A", 80)), 63)
+}
+
+// fakeSecretManager serves the Secret Manager reads of GCPMergeStore
+type fakeSecretManager struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+	secrets []*secretmanagerpb.Secret
+	data    map[string]string
+}
+
+func (f *fakeSecretManager) ListSecrets(ctx context.Context, req *secretmanagerpb.ListSecretsRequest) (*secretmanagerpb.ListSecretsResponse, error) {
+	return &secretmanagerpb.ListSecretsResponse{Secrets: f.secrets}, nil
+}
+
+func (f *fakeSecretManager) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Payload: &secretmanagerpb.SecretPayload{Data: []byte(f.data[strings.TrimSuffix(req.Name, "/versions/latest")])},
+	}, nil
+}
+
+// newFakeGCPMergeStore returns a GCPMergeStore backed by fake
+func newFakeGCPMergeStore(t *testing.T, fake *fakeSecretManager) *GCPMergeStore {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(server, fake)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	ctx := context.Background()
+	client, err := secretmanager.NewClient(ctx,
+		option.WithEndpoint("bufnet"),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() })),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return &GCPMergeStore{Project: "secrets-prod", Prefix: "merged-", client: client}
+}
+
+func TestSyncTargetFromGCPMergeStore(t *testing.T) {
+	store := newFakeGCPMergeStore(t, &fakeSecretManager{
+		secrets: []*secretmanagerpb.Secret{{
+			Name:        "projects/secrets-prod/secrets/merged-Prod--db",
+			Annotations: map[string]string{gcpTargetAnnotation: "Prod", gcpSecretAnnotation: "db"},
+		}},
+		data: map[string]string{"projects/secrets-prod/secrets/merged-Prod--db": `{"password":"s3cret"}`},
+	})
+
+	// The sync engine reads the source its clients are given; record what
+	// it would write
+	wait := backend.ManualTriggerAndWait
+	t.Cleanup(func() { backend.ManualTriggerAndWait = wait })
+	written := make(map[string]string)
+	backend.ManualTriggerAndWait = func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*backend.SyncCompletion, error) {
+		scs, err := internalSync.InitSyncConfigClients(cfg)
+		if err != nil {
+			return nil, err
+		}
+		paths, err := internalSync.LoopWildcardRecursive(ctx, scs.Source, scs.Source.GetPath())
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			b, err := scs.Source.GetSecret(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+			written[path] = string(b)
+		}
+		return &backend.SyncCompletion{Name: cfg.Name}, nil
+	}
+
+	p := &Pipeline{
+		config: &Config{
+			MergeStore: MergeStoreConfig{GCP: &MergeStoreGCP{Project: "secrets-prod", Prefix: "merged-"}},
+			Targets:    map[string]Target{"Prod": {AccountID: "111111111111"}},
+		},
+		gcpStore: store,
+	}
+	result := p.syncTarget(context.Background(), "Prod", false)
+	require.NoError(t, result.Error)
+	assert.Equal(t, map[string]string{"Prod/db": `{"password":"s3cret"}`}, written)
+	assert.Equal(t, []string{"gcpsm://secrets-prod/merged-Prod"}, result.Details.SourcePaths)
+}
@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/stores/github"
+	"github.com/jbcom/secretsync/stores/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// githubClient returns a GitHub store client for owner/repo using the configured App credentials
+func (c *Config) githubClient(owner, repo, env string) *github.GitHubClient {
+	client := &github.GitHubClient{
+		Owner: owner,
+		Repo:  repo,
+		Env:   env,
+	}
+	if c.GitHub != nil {
+		client.AppId = c.GitHub.AppID
+		client.InstallId = c.GitHub.InstallID
+		client.PrivateKeyPath = c.GitHub.PrivateKeyPath
+		client.PrivateKeyString = c.GitHub.PrivateKey
+	}
+	return client
+}
+
+// githubDestinationClient returns a GitHub store client writing a
+// destination's repository, environment or organization secrets
+func (c *Config) githubDestinationClient(dest *GitHubDestination) *github.GitHubClient {
+	client := c.githubClient(dest.Owner, dest.Repo, dest.Environment)
+	if dest.Repo == "" {
+		client.Org = true
+		client.Visibility = dest.Visibility
+		client.SelectedRepos = dest.Repositories
+	}
+	return client
+}
+
+// validate checks that a GitHub destination names a repository, or a
+// visibility for organization secrets
+func (d *GitHubDestination) validate() error {
+	if d.Owner == "" {
+		return fmt.Errorf("github.owner is required")
+	}
+	if d.Repo != "" {
+		if d.Visibility != "" || len(d.Repositories) > 0 {
+			return fmt.Errorf("github.visibility and github.repositories only apply to organization secrets, without github.repo")
+		}
+		return nil
+	}
+	if d.Environment != "" {
+		return fmt.Errorf("github.environment requires github.repo")
+	}
+	switch d.Visibility {
+	case "":
+		return fmt.Errorf("github.repo is required, or github.visibility for organization secrets")
+	case "all", "private":
+		if len(d.Repositories) > 0 {
+			return fmt.Errorf("github.repositories requires github.visibility selected")
+		}
+	case "selected":
+		if len(d.Repositories) == 0 {
+			return fmt.Errorf("github.visibility selected requires github.repositories")
+		}
+	default:
+		return fmt.Errorf("github.visibility must be all, private or selected, got %q", d.Visibility)
+	}
+	return nil
+}
+
+// discoverFromGitHub lists repositories in the org and maps them to GitHub destination targets
+func (d *DiscoveryService) discoverFromGitHub(dt DynamicTarget) (map[string]Target, error) {
+	cfg := dt.Discovery.GitHub
+	l := log.WithFields(log.Fields{
+		"action": "discoverFromGitHub",
+		"org":    cfg.Org,
+		"team":   cfg.Team,
+	})
+	l.Debug("Discovering repositories from GitHub")
+
+	// The org-level client is only used for listing; Repo is set per target
+	client := d.config.githubClient(cfg.Org, "", "")
+	if err := client.CreateClient(d.ctx); err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+	repos, err := client.ListOrgRepositories(d.ctx, cfg.Team)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+
+	targets := mapGitHubRepos(dt, repos)
+	l.WithField("count", len(targets)).Debug("Mapped GitHub repositories to targets")
+	return targets, nil
+}
+
+// mapGitHubRepos filters repositories by topic, archive state and exclusions and
+// converts them to GitHub destination targets named after the repository
+func mapGitHubRepos(dt DynamicTarget, repos []github.RepoInfo) map[string]Target {
+	cfg := dt.Discovery.GitHub
+	targets := make(map[string]Target)
+
+	sorted := append([]github.RepoInfo(nil), repos...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, repo := range sorted {
+		if repo.Archived && !cfg.IncludeArchived {
+			continue
+		}
+		if isExcluded(repo.Name, dt.Exclude) {
+			continue
+		}
+		if len(cfg.Topics) > 0 && !hasAnyTopic(repo.Topics, cfg.Topics) {
+			continue
+		}
+
+		name := sanitizeTargetName(repo.Name)
+		if _, exists := targets[name]; exists {
+			log.WithField("repo", repo.Name).Warn("Repository name collides after sanitizing, skipping")
+			continue
+		}
+		targets[name] = Target{
+			Imports:        dt.Imports,
+			Classification: dt.Classification,
+			Owners:         dt.Owners,
+			Tags:           dt.Tags,
+			Labels:         dt.Labels,
+			GitHub: &GitHubDestination{
+				Owner:       cfg.Org,
+				Repo:        repo.Name,
+				Environment: cfg.Environment,
+			},
+		}
+	}
+	return targets
+}
+
+func hasAnyTopic(topics, wanted []string) bool {
+	for _, t := range topics {
+		if containsString(wanted, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// createGitHubSync creates a VaultSecretSync for syncing to GitHub Actions secrets
+func (p *Pipeline) createGitHubSync(targetName, sourcePath string, dest *GitHubDestination, dryRun bool) v1alpha1.VaultSecretSync {
+	client := p.config.githubDestinationClient(dest)
+	client.Merge = boolPtr(true)
+
+	sync := v1alpha1.VaultSecretSync{
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			DryRun:     boolPtr(dryRun),
+			SyncDelete: boolPtr(p.config.Pipeline.Sync.DeleteOrphans),
+			Source: &vault.VaultClient{
+				Address:   p.config.Vault.Address,
+				Namespace: p.config.Vault.Namespace,
+				Path:      fmt.Sprintf("%s/(.*)", sourcePath),
+			},
+			Dest: []*v1alpha1.StoreConfig{
+				{
+					GitHub: client,
+				},
+			},
+		},
+	}
+	sync.Name = fmt.Sprintf("sync-%s", targetName)
+	sync.Namespace = "pipeline"
+	return sync
+}
@@ -60,6 +60,7 @@ func BuildGraph(cfg *Config) (*Graph, error) {
 	for name, target := range cfg.Targets {
 		node := g.Nodes[name]
 		for _, imp := range target.Imports {
+			imp = ImportName(imp)
 			depNode, ok := g.Nodes[imp]
 			if !ok {
 				return nil, fmt.Errorf("target %q imports unknown source/target %q", name, imp)
@@ -69,6 +70,11 @@ func BuildGraph(cfg *Config) (*Graph, error) {
 		}
 	}
 
+	// Dynamic targets are only known once discovered, so policies are checked here too
+	if err := cfg.authorizeImports(); err != nil {
+		return nil, err
+	}
+
 	// Calculate levels using BFS
 	if err := g.calculateLevels(); err != nil {
 		return nil, err
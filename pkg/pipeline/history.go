@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+)
+
+// HistorySettings keeps a record of every pipeline run on disk, one JSON file
+// per run, for dashboards such as `vss graph --serve`
+//
+//	pipeline:
+//	  history:
+//	    dir: /var/lib/vss/runs
+//	    keep: 500
+type HistorySettings struct {
+	Dir string `mapstructure:"dir" yaml:"dir"`
+	// Keep is the number of most recent runs retained (default: all)
+	Keep int `mapstructure:"keep" yaml:"keep,omitempty"`
+}
+
+// RunRecord is a pipeline run as stored in the run history
+type RunRecord struct {
+	ID          string      `json:"id"`
+	Operation   Operation   `json:"operation"`
+	DryRun      bool        `json:"dry_run"`
+	Parallelism int         `json:"parallelism,omitempty"` // Concurrency limit of each phase
+	Started     time.Time   `json:"started"`
+	Finished    time.Time   `json:"finished"`
+	Error       string      `json:"error,omitempty"`
+	Results     []RunResult `json:"results"`
+}
+
+// RunResult is a target's Result as stored in the run history
+type RunResult struct {
+	Target   string        `json:"target"`
+	Phase    string        `json:"phase"`
+	Success  bool          `json:"success"`
+	Error    string        `json:"error,omitempty"`
+	Started  time.Time     `json:"started,omitempty"`
+	Duration time.Duration `json:"duration"`
+	// Level is the target's dependency level when the run was recorded
+	Level int `json:"level"`
+	// Changes summarizes the secret changes of runs that computed a diff
+	Changes *diff.ChangeSummary `json:"changes,omitempty"`
+}
+
+// TargetStatus is a target's outcome in the most recent run that processed it
+type TargetStatus struct {
+	Target   string    `json:"target"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	RunID    string    `json:"run_id"`
+	Finished time.Time `json:"finished"`
+}
+
+// runID identifies the run started at started. IDs are UTC timestamps, so
+// they sort chronologically.
+func runID(started time.Time) string {
+	return started.UTC().Format("20060102T150405.000000000Z")
+}
+
+// newRunRecord converts a run's results for the run history
+func newRunRecord(opts Options, g *Graph, started, finished time.Time, results []Result, err error) RunRecord {
+	rec := RunRecord{
+		ID:          runID(started),
+		Operation:   opts.Operation,
+		DryRun:      opts.DryRun,
+		Parallelism: opts.Parallelism,
+		Started:     started,
+		Finished:    finished,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	for _, r := range results {
+		rr := RunResult{Target: r.Target, Phase: r.Phase, Success: r.Success, Started: r.Started, Duration: r.Duration}
+		if g != nil && g.Nodes[r.Target] != nil {
+			rr.Level = g.Nodes[r.Target].Level
+		}
+		if r.Error != nil {
+			rr.Error = r.Error.Error()
+		}
+		if r.Diff != nil {
+			summary := r.Diff.Summary
+			rr.Changes = &summary
+		}
+		rec.Results = append(rec.Results, rr)
+	}
+	return rec
+}
+
+// WriteRunRecord saves rec in the history directory, then removes the oldest
+// runs beyond keep (0 keeps all)
+func WriteRunRecord(dir string, keep int, rec RunRecord) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run record: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, rec.ID+".json"), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write run record: %w", err)
+	}
+	if keep <= 0 {
+		return nil
+	}
+	files, err := runRecordFiles(dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > keep {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("failed to prune run history: %w", err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// LoadRunHistory reads every run in the history directory, oldest first.
+// A missing directory is an empty history.
+func LoadRunHistory(dir string) ([]RunRecord, error) {
+	files, err := runRecordFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]RunRecord, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read run record: %w", err)
+		}
+		var rec RunRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse run record %s: %w", filepath.Base(f), err)
+		}
+		runs = append(runs, rec)
+	}
+	return runs, nil
+}
+
+// runRecordFiles lists the run record files in dir, oldest first
+func runRecordFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	// IDs are UTC timestamps, so names sort chronologically
+	sort.Strings(files)
+	return files, nil
+}
+
+// LatestTargetStatus returns each target's outcome in the most recent
+// apply-mode run that processed it. A target fails if any of its phases failed.
+func LatestTargetStatus(runs []RunRecord) map[string]TargetStatus {
+	status := make(map[string]TargetStatus)
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if run.DryRun {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, r := range run.Results {
+			if _, done := status[r.Target]; done && !seen[r.Target] {
+				continue
+			}
+			s, ok := status[r.Target]
+			if !ok {
+				s = TargetStatus{Target: r.Target, Success: true, RunID: run.ID, Finished: run.Finished}
+				seen[r.Target] = true
+			}
+			if !r.Success {
+				s.Success = false
+				s.Error = r.Error
+			}
+			status[r.Target] = s
+		}
+	}
+	return status
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHistory(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	opts := Options{Operation: OperationPipeline}
+
+	first := newRunRecord(opts, nil, start, start.Add(time.Minute), []Result{
+		{Target: "Serverless_Stg", Phase: "merge", Success: true, Duration: time.Second},
+		{Target: "Serverless_Stg", Phase: "sync", Success: true, Duration: 2 * time.Second},
+		{Target: "Serverless_Prod", Phase: "merge", Success: true},
+		{Target: "Serverless_Prod", Phase: "sync", Success: false, Error: errors.New("access denied")},
+	}, nil)
+	require.NoError(t, WriteRunRecord(dir, 2, first))
+
+	second := newRunRecord(opts, nil, start.Add(time.Hour), start.Add(time.Hour+time.Minute), []Result{
+		{Target: "Serverless_Prod", Phase: "merge", Success: true},
+		{Target: "Serverless_Prod", Phase: "sync", Success: true},
+	}, nil)
+	require.NoError(t, WriteRunRecord(dir, 2, second))
+
+	// Dry runs are recorded but do not change a target's status
+	dry := newRunRecord(Options{Operation: OperationPipeline, DryRun: true}, nil, start.Add(2*time.Hour), start.Add(2*time.Hour), []Result{
+		{Target: "Serverless_Stg", Phase: "merge", Success: false, Error: errors.New("boom")},
+	}, errors.New("1 target failed"))
+	require.NoError(t, WriteRunRecord(dir, 2, dry))
+
+	// Only the two most recent runs are kept
+	runs, err := LoadRunHistory(dir)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, second.ID, runs[0].ID)
+	assert.Equal(t, "1 target failed", runs[1].Error)
+	assert.Equal(t, "boom", runs[1].Results[0].Error)
+
+	status := LatestTargetStatus(runs)
+	assert.Equal(t, TargetStatus{Target: "Serverless_Prod", Success: true, RunID: second.ID, Finished: second.Finished}, status["Serverless_Prod"])
+	assert.NotContains(t, status, "Serverless_Stg")
+
+	status = LatestTargetStatus([]RunRecord{first})
+	assert.True(t, status["Serverless_Stg"].Success)
+	assert.False(t, status["Serverless_Prod"].Success)
+	assert.Equal(t, "access denied", status["Serverless_Prod"].Error)
+}
+
+func TestLoadRunHistoryMissingDir(t *testing.T) {
+	runs, err := LoadRunHistory(t.TempDir() + "/missing")
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(dir+"/bad.json", []byte("{"), 0o644))
+	_, err = LoadRunHistory(dir)
+	assert.ErrorContains(t, err, "failed to parse run record bad.json")
+}
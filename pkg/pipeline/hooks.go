@@ -0,0 +1,317 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HookEvent is a point in a run at which hooks are called
+type HookEvent string
+
+const (
+	HookPreRun     HookEvent = "pre_run"
+	HookPostRun    HookEvent = "post_run"
+	HookPreMerge   HookEvent = "pre_merge"
+	HookPostMerge  HookEvent = "post_merge"
+	HookPreSync    HookEvent = "pre_sync"
+	HookPostSync   HookEvent = "post_sync"
+	HookPreTarget  HookEvent = "pre_target"
+	HookPostTarget HookEvent = "post_target"
+	// HookBreakglass is called before a `vss breakglass get` read, outside any run
+	HookBreakglass HookEvent = "breakglass"
+	// HookOffboard is called before `vss offboard` removes a target's secrets
+	HookOffboard HookEvent = "offboard"
+)
+
+var hookEvents = []HookEvent{
+	HookPreRun, HookPostRun, HookPreMerge, HookPostMerge,
+	HookPreSync, HookPostSync, HookPreTarget, HookPostTarget,
+	HookBreakglass, HookOffboard,
+}
+
+// defaultHookTimeout bounds a hook call without a timeout of its own
+const defaultHookTimeout = 30 * time.Second
+
+// Hook runs a local command or calls an HTTP endpoint with a JSON HookPayload
+// at points in a run, e.g. to warm a cache after a target syncs or tell a
+// bespoke system a run started. Exactly one of Exec and HTTP is set.
+//
+//	pipeline:
+//	  hooks:
+//	    - name: warm-cache
+//	      on: [post_target]
+//	      targets: [Serverless_Prod]
+//	      exec: ["./scripts/warm-cache.sh"]
+//	    - name: change-log
+//	      on: [pre_run, post_run]
+//	      http: https://changes.example.com/api/events
+//	      headers:
+//	        Authorization: "Bearer ${CHANGES_TOKEN}"
+type Hook struct {
+	Name string      `mapstructure:"name" yaml:"name"`
+	On   []HookEvent `mapstructure:"on" yaml:"on"`
+	// Targets limits pre_target, post_target, breakglass and offboard calls
+	// to these targets (default: all)
+	Targets []string `mapstructure:"targets" yaml:"targets,omitempty"`
+
+	// Exec is a command and its arguments; the payload is written to its stdin
+	// and VSS_HOOK_EVENT (and VSS_HOOK_TARGET) are set in its environment
+	Exec []string `mapstructure:"exec" yaml:"exec,omitempty"`
+	// HTTP is a URL the payload is POSTed to; any non-2xx status is a failure
+	HTTP    string            `mapstructure:"http" yaml:"http,omitempty"`
+	Headers map[string]string `mapstructure:"headers" yaml:"headers,omitempty"`
+
+	// Timeout bounds each call (default 30s)
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
+	// Required makes a failing pre_ hook stop what it precedes: the run, the
+	// phase, or the target. A failing required breakglass or offboard hook
+	// refuses the read or the offboarding. Failures are otherwise only logged.
+	Required bool `mapstructure:"required" yaml:"required,omitempty"`
+}
+
+// HookPayload is the context a hook is called with
+type HookPayload struct {
+	Event     HookEvent `json:"event"`
+	Operation Operation `json:"operation"`
+	DryRun    bool      `json:"dry_run"`
+	// Targets are the targets of the run or phase
+	Targets []string `json:"targets,omitempty"`
+	// Phase is merge or sync, for phase and target events
+	Phase string `json:"phase,omitempty"`
+	// Target is set for pre_target and post_target
+	Target string    `json:"target,omitempty"`
+	Time   time.Time `json:"time"`
+
+	// Set for post_ events
+	Succeeded int           `json:"succeeded,omitempty"`
+	Failed    int           `json:"failed,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Error     string        `json:"error,omitempty"`
+
+	// Breakglass is set for breakglass events
+	Breakglass *BreakglassRecord `json:"breakglass,omitempty"`
+	// Offboard is set for offboard events
+	Offboard *OffboardRecord `json:"offboard,omitempty"`
+}
+
+// HookError reports a required hook that failed
+type HookError struct {
+	Hook  string
+	Event HookEvent
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %q (%s) failed: %v", e.Hook, e.Event, e.Err)
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+func (h Hook) validate(c *Config) error {
+	if len(h.On) == 0 {
+		return fmt.Errorf("on is required")
+	}
+	for _, ev := range h.On {
+		if !containsHookEvent(hookEvents, ev) {
+			return fmt.Errorf("unknown event %q", ev)
+		}
+	}
+	if (len(h.Exec) > 0) == (h.HTTP != "") {
+		return fmt.Errorf("exactly one of exec and http is required")
+	}
+	if len(h.Exec) > 0 && strings.TrimSpace(h.Exec[0]) == "" {
+		return fmt.Errorf("exec command must not be empty")
+	}
+	if h.HTTP != "" {
+		u, err := url.Parse(h.HTTP)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("http %q must be an http or https URL", h.HTTP)
+		}
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	for _, t := range h.Targets {
+		if _, ok := c.Targets[t]; !ok {
+			return fmt.Errorf("target %q not found", t)
+		}
+	}
+	return nil
+}
+
+// hooksFor returns the hooks called on event, for target if it is a target event
+func (c *Config) hooksFor(event HookEvent, target string) []Hook {
+	var hooks []Hook
+	for _, h := range c.Pipeline.Hooks {
+		if !containsHookEvent(h.On, event) {
+			continue
+		}
+		if target != "" && len(h.Targets) > 0 && !containsString(h.Targets, target) {
+			continue
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks
+}
+
+// runHooks calls every hook for the payload's event in order. Only a failing
+// required pre_, breakglass or offboard hook is returned, as a *HookError;
+// other failures are logged.
+func (p *Pipeline) runHooks(ctx context.Context, payload HookPayload) error {
+	hooks := p.config.hooksFor(payload.Event, payload.Target)
+	if len(hooks) == 0 {
+		return nil
+	}
+	payload.Time = time.Now().UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hook payload: %w", err)
+	}
+
+	for _, h := range hooks {
+		l := log.WithFields(log.Fields{
+			"action": "Pipeline.runHooks",
+			"hook":   h.Name,
+			"event":  payload.Event,
+		})
+		if payload.Target != "" {
+			l = l.WithField("target", payload.Target)
+		}
+		err := callHook(ctx, h, payload, body)
+		if err == nil {
+			l.Debug("Hook succeeded")
+			continue
+		}
+		if h.Required && (strings.HasPrefix(string(payload.Event), "pre_") || payload.Event == HookBreakglass || payload.Event == HookOffboard) {
+			l.WithError(err).Error("Required hook failed")
+			return &HookError{Hook: h.Name, Event: payload.Event, Err: err}
+		}
+		l.WithError(err).Warn("Hook failed")
+	}
+	return nil
+}
+
+// callHook makes one hook call with its timeout
+func callHook(ctx context.Context, h Hook, payload HookPayload, body []byte) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if len(h.Exec) > 0 {
+		cmd := exec.CommandContext(ctx, h.Exec[0], h.Exec[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Env = append(os.Environ(), "VSS_HOOK_EVENT="+string(payload.Event))
+		if payload.Target != "" {
+			cmd.Env = append(cmd.Env, "VSS_HOOK_TARGET="+payload.Target)
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				if len(msg) > 512 {
+					msg = msg[:512] + "..."
+				}
+				return fmt.Errorf("%w: %s", err, msg)
+			}
+			return err
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.HTTP, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// hookPayload starts the payload for an event of the run described by opts
+func hookPayload(event HookEvent, opts Options) HookPayload {
+	return HookPayload{Event: event, Operation: opts.Operation, DryRun: opts.DryRun}
+}
+
+// withPostCounts fills in a post_ payload from results and the error that ended the step
+func (hp HookPayload) withPostCounts(results []Result, started time.Time, err error) HookPayload {
+	for _, r := range results {
+		if r.Success {
+			hp.Succeeded++
+		} else {
+			hp.Failed++
+		}
+	}
+	hp.Duration = time.Since(started)
+	if err != nil {
+		hp.Error = err.Error()
+	}
+	return hp
+}
+
+// runPhaseWithHooks runs a phase between its pre_ and post_ hooks
+func (p *Pipeline) runPhaseWithHooks(ctx context.Context, phase string, targets []string, opts Options,
+	run func(context.Context, []string, Options) ([]Result, error)) ([]Result, error) {
+	pre, post := HookPreMerge, HookPostMerge
+	if phase == "sync" {
+		pre, post = HookPreSync, HookPostSync
+	}
+	payload := hookPayload(pre, opts)
+	payload.Phase = phase
+	payload.Targets = targets
+	if err := p.runHooks(ctx, payload); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	results, err := run(ctx, targets, opts)
+	payload.Event = post
+	_ = p.runHooks(ctx, payload.withPostCounts(results, started, err))
+	return results, err
+}
+
+// runTargetWithHooks runs one target's phase between the pre_target and
+// post_target hooks. A failing required pre_target hook fails the target.
+func (p *Pipeline) runTargetWithHooks(ctx context.Context, phase, target string, opts Options, run func() Result) Result {
+	payload := hookPayload(HookPreTarget, opts)
+	payload.Phase = phase
+	payload.Target = target
+	if err := p.runHooks(ctx, payload); err != nil {
+		return Result{Target: target, Phase: phase, Success: false, Error: err}
+	}
+
+	started := time.Now()
+	result := run()
+	payload.Event = HookPostTarget
+	_ = p.runHooks(ctx, payload.withPostCounts([]Result{result}, started, result.Error))
+	return result
+}
+
+func containsHookEvent(list []HookEvent, ev HookEvent) bool {
+	for _, v := range list {
+		if v == ev {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookValidate(t *testing.T) {
+	cfg := &Config{Targets: map[string]Target{"Prod": {}}}
+	tests := []struct {
+		name   string
+		hook   Hook
+		errMsg string
+	}{
+		{name: "exec", hook: Hook{On: []HookEvent{HookPostTarget}, Targets: []string{"Prod"}, Exec: []string{"./warm.sh"}}},
+		{name: "http", hook: Hook{On: []HookEvent{HookPreRun, HookPostRun}, HTTP: "https://changes.example.com/events"}},
+		{name: "no events", hook: Hook{Exec: []string{"true"}}, errMsg: "on is required"},
+		{name: "unknown event", hook: Hook{On: []HookEvent{"after_everything"}, Exec: []string{"true"}}, errMsg: `unknown event "after_everything"`},
+		{name: "exec and http", hook: Hook{On: []HookEvent{HookPreRun}, Exec: []string{"true"}, HTTP: "https://a.example.com"}, errMsg: "exactly one of exec and http"},
+		{name: "neither", hook: Hook{On: []HookEvent{HookPreRun}}, errMsg: "exactly one of exec and http"},
+		{name: "bad url", hook: Hook{On: []HookEvent{HookPreRun}, HTTP: "changes.example.com"}, errMsg: "must be an http or https URL"},
+		{name: "unknown target", hook: Hook{On: []HookEvent{HookPreTarget}, Targets: []string{"Stg"}, Exec: []string{"true"}}, errMsg: `target "Stg" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hook.validate(cfg)
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestRunHooksHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var received []HookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload HookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		mu.Lock()
+		received = append(received, payload)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	p := &Pipeline{config: &Config{Pipeline: PipelineSettings{Hooks: []Hook{
+		{Name: "prod-only", On: []HookEvent{HookPostTarget}, Targets: []string{"Prod"}, HTTP: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}},
+	}}}}
+	opts := Options{Operation: OperationPipeline, DryRun: true}
+
+	for _, target := range []string{"Stg", "Prod"} {
+		result := p.runTargetWithHooks(context.Background(), "sync", target, opts, func() Result {
+			return Result{Target: target, Phase: "sync", Success: true}
+		})
+		assert.True(t, result.Success)
+	}
+
+	require.Len(t, received, 1)
+	assert.Equal(t, HookPostTarget, received[0].Event)
+	assert.Equal(t, "Prod", received[0].Target)
+	assert.Equal(t, "sync", received[0].Phase)
+	assert.True(t, received[0].DryRun)
+	assert.Equal(t, 1, received[0].Succeeded)
+}
+
+func TestRunHooksExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "payload.json")
+	p := &Pipeline{config: &Config{Pipeline: PipelineSettings{Hooks: []Hook{
+		{Name: "record", On: []HookEvent{HookPreMerge}, Exec: []string{"sh", "-c", `cat > "$0"; test "$VSS_HOOK_EVENT" = pre_merge`, out}},
+		{Name: "flaky", On: []HookEvent{HookPreMerge}, Exec: []string{"sh", "-c", "exit 1"}},
+	}}}}
+
+	called := false
+	results, err := p.runPhaseWithHooks(context.Background(), "merge", []string{"Stg"}, Options{Operation: OperationMerge},
+		func(context.Context, []string, Options) ([]Result, error) {
+			called = true
+			return []Result{{Target: "Stg", Success: true}}, nil
+		})
+	require.NoError(t, err, "optional hook failures are only logged")
+	assert.True(t, called)
+	assert.Len(t, results, 1)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var payload HookPayload
+	require.NoError(t, json.Unmarshal(data, &payload))
+	assert.Equal(t, HookPreMerge, payload.Event)
+	assert.Equal(t, []string{"Stg"}, payload.Targets)
+}
+
+func TestRequiredHookFailure(t *testing.T) {
+	p := &Pipeline{config: &Config{Pipeline: PipelineSettings{Hooks: []Hook{
+		{Name: "gate", On: []HookEvent{HookPreTarget, HookPostTarget}, Required: true, Exec: []string{"sh", "-c", "echo account locked >&2; exit 3"}},
+	}}}}
+
+	called := false
+	result := p.runTargetWithHooks(context.Background(), "sync", "Prod", Options{}, func() Result {
+		called = true
+		return Result{Success: true}
+	})
+	assert.False(t, called, "the target does not run when a required pre_target hook fails")
+	assert.False(t, result.Success)
+	var hookErr *HookError
+	require.True(t, errors.As(result.Error, &hookErr))
+	assert.Equal(t, "gate", hookErr.Hook)
+	assert.ErrorContains(t, result.Error, "account locked")
+
+	// Required only applies to pre_ events
+	assert.NoError(t, p.runHooks(context.Background(), HookPayload{Event: HookPostTarget, Target: "Prod"}))
+}
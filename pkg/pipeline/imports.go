@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ImportRef is a parsed target import. Imports of a Vault source that reads
+// a single secret may pin a version with an `@` suffix, e.g. `db-password@v42`:
+// the merge reads KV v2 version 42 of that secret. Unpinned imports always
+// read the latest version.
+type ImportRef struct {
+	Name    string
+	Version int
+}
+
+// ParseImportRef splits an import into its source/target name and pinned version
+func ParseImportRef(imp string) (ImportRef, error) {
+	name, version, pinned := strings.Cut(imp, "@")
+	if !pinned {
+		return ImportRef{Name: imp}, nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if err != nil || n < 1 {
+		return ImportRef{}, fmt.Errorf("invalid version %q in import %q: expected v<N> with N >= 1", version, imp)
+	}
+	return ImportRef{Name: name, Version: n}, nil
+}
+
+// ImportName returns the source/target name of an import, ignoring any pinned version
+func ImportName(imp string) string {
+	name, _, _ := strings.Cut(imp, "@")
+	return name
+}
+
+// Pinned reports whether the import reads a fixed version
+func (r ImportRef) Pinned() bool {
+	return r.Version > 0
+}
+
+func (r ImportRef) String() string {
+	if !r.Pinned() {
+		return r.Name
+	}
+	return fmt.Sprintf("%s@v%d", r.Name, r.Version)
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportRef(t *testing.T) {
+	tests := []struct {
+		imp     string
+		want    ImportRef
+		wantErr bool
+	}{
+		{imp: "analytics", want: ImportRef{Name: "analytics"}},
+		{imp: "analytics@v42", want: ImportRef{Name: "analytics", Version: 42}},
+		{imp: "Serverless_Stg@7", want: ImportRef{Name: "Serverless_Stg", Version: 7}},
+		{imp: "analytics@", wantErr: true},
+		{imp: "analytics@v0", wantErr: true},
+		{imp: "analytics@latest", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.imp, func(t *testing.T) {
+			ref, err := ParseImportRef(tt.imp)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref)
+			assert.Equal(t, tt.want.Name, ImportName(tt.imp))
+		})
+	}
+
+	assert.Equal(t, "analytics@v42", ImportRef{Name: "analytics", Version: 42}.String())
+	assert.Equal(t, "analytics", ImportRef{Name: "analytics"}.String())
+}
+
+func TestPinnedImportsInGraph(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg":  {Imports: []string{"analytics"}},
+			"Prod": {Imports: []string{"Stg@v3"}},
+		},
+	}
+
+	g, err := BuildGraph(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Stg"}, g.Nodes["Prod"].Deps)
+	assert.True(t, cfg.IsInheritedTarget("Prod"))
+	assert.Equal(t, "merged/Stg", cfg.GetSourcePath("Stg@v3"))
+}
@@ -0,0 +1,314 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	log "github.com/sirupsen/logrus"
+)
+
+// InventoryPayloadType identifies an inventory inside its signed envelope
+const InventoryPayloadType = "application/vnd.vss.inventory+json"
+
+// Inventory lists every secret vss manages, per destination account, as
+// compliance evidence. It holds names, hashes and provenance, never values.
+type Inventory struct {
+	Version   int                `json:"version"`
+	Generated time.Time          `json:"generated"`
+	Accounts  []InventoryAccount `json:"accounts"`
+}
+
+// InventoryAccount is the destinations in one AWS account. Destinations
+// outside AWS (GitHub, Doppler, gRPC, non-EKS Kubernetes) are grouped in an
+// entry without an account ID.
+type InventoryAccount struct {
+	AccountID    string                 `json:"account_id,omitempty"`
+	Destinations []InventoryDestination `json:"destinations"`
+}
+
+// InventoryDestination is one destination of a target and the secrets the
+// target's merge store holds for it
+type InventoryDestination struct {
+	Target      string            `json:"target"`
+	Destination string            `json:"destination"`
+	Sources     []InventorySource `json:"sources"`
+	// LastSync is when the last apply-mode run that processed the target
+	// finished, from the run history
+	LastSync        *time.Time        `json:"last_sync,omitempty"`
+	LastSyncRun     string            `json:"last_sync_run,omitempty"`
+	LastSyncSuccess *bool             `json:"last_sync_success,omitempty"`
+	Secrets         []InventorySecret `json:"secrets"`
+	// Error is why the target's secrets or the destination could not be listed
+	Error string `json:"error,omitempty"`
+}
+
+// InventorySource is an import of a target and where it reads from
+type InventorySource struct {
+	Import  string   `json:"import"`
+	Type    string   `json:"type"`
+	Paths   []string `json:"paths,omitempty"`
+	Version int      `json:"version,omitempty"`
+}
+
+// InventorySecret is a merged secret. InDestination and LastChanged come from
+// listing the destination and are only set for Secrets Manager destinations.
+type InventorySecret struct {
+	Name string `json:"name"`
+	// SHA256 is the hash of the merged secret's JSON
+	SHA256        string     `json:"sha256"`
+	InDestination *bool      `json:"in_destination,omitempty"`
+	LastChanged   *time.Time `json:"last_changed,omitempty"`
+}
+
+// Errors returns the destinations that could not be fully inventoried
+func (inv *Inventory) Errors() []string {
+	var errs []string
+	for _, a := range inv.Accounts {
+		for _, d := range a.Destinations {
+			if d.Error != "" {
+				errs = append(errs, fmt.Sprintf("%s -> %s: %s", d.Target, d.Destination, d.Error))
+			}
+		}
+	}
+	return errs
+}
+
+// Inventory decodes a signed inventory. It does not verify signatures.
+func (s *SignedManifest) Inventory() (*Inventory, error) {
+	if s.PayloadType != InventoryPayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", s.PayloadType)
+	}
+	var inv Inventory
+	if err := json.Unmarshal(s.Payload, &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory payload: %w", err)
+	}
+	return &inv, nil
+}
+
+// secretLister lists the secrets in a Secrets Manager destination with their
+// last change time
+type secretLister interface {
+	SecretsManagerSecrets(ctx context.Context, target Target, d Destination) (map[string]time.Time, error)
+}
+
+// Inventory reads the merged secrets of the given targets (all when empty)
+// and lists their Secrets Manager destinations. Failures are recorded on the
+// affected destinations rather than aborting the inventory.
+func (p *Pipeline) Inventory(ctx context.Context, targets []string) (*Inventory, error) {
+	targets, err := p.selectTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var status map[string]TargetStatus
+	if h := p.config.Pipeline.History; h != nil && h.Dir != "" {
+		runs, err := LoadRunHistory(h.Dir)
+		if err != nil {
+			return nil, err
+		}
+		status = LatestTargetStatus(runs)
+	}
+	if p.inventoryLister == nil {
+		p.inventoryLister = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	return buildInventory(ctx, p.config, store, p.inventoryLister, status, targets, time.Now().UTC()), nil
+}
+
+// selectTargets validates the named targets, or returns every target when
+// none are named, sorted
+func (p *Pipeline) selectTargets(targets []string) ([]string, error) {
+	for _, t := range targets {
+		if _, ok := p.config.Targets[t]; !ok {
+			return nil, &OptionError{Option: "target", Value: fmt.Sprintf("%q", t), Reason: "not found in configuration"}
+		}
+	}
+	if len(targets) == 0 {
+		for name := range p.config.Targets {
+			targets = append(targets, name)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+func buildInventory(ctx context.Context, c *Config, store mergeStore, lister secretLister, status map[string]TargetStatus, targets []string, now time.Time) *Inventory {
+	accounts := make(map[string]*InventoryAccount)
+	for _, name := range targets {
+		target := c.Targets[name]
+		l := log.WithFields(log.Fields{
+			"action": "buildInventory",
+			"target": name,
+		})
+
+		secrets, snapErr := inventorySecrets(ctx, store, name)
+		if snapErr != nil {
+			l.WithError(snapErr).Error("Failed to read merged secrets")
+		}
+		sources := c.inventorySources(target)
+		for _, d := range target.ResolvedDestinations() {
+			dest := InventoryDestination{
+				Target:      name,
+				Destination: d.Label(),
+				Sources:     sources,
+				Secrets:     append([]InventorySecret{}, secrets...),
+			}
+			if s, ok := status[name]; ok {
+				finished, success := s.Finished, s.Success
+				dest.LastSync, dest.LastSyncRun, dest.LastSyncSuccess = &finished, s.RunID, &success
+			}
+			switch {
+			case snapErr != nil:
+				dest.Error = snapErr.Error()
+			case d.requiresAccountID() && d.Kubernetes == nil:
+				if err := listDestination(ctx, lister, target, d, dest.Secrets); err != nil {
+					l.WithError(err).WithField("destination", dest.Destination).Error("Failed to list destination")
+					dest.Error = err.Error()
+				}
+			}
+
+			accountID := ""
+			if d.requiresAccountID() {
+				accountID = d.AccountID
+			}
+			a, ok := accounts[accountID]
+			if !ok {
+				a = &InventoryAccount{AccountID: accountID}
+				accounts[accountID] = a
+			}
+			a.Destinations = append(a.Destinations, dest)
+		}
+	}
+
+	inv := &Inventory{Version: 1, Generated: now, Accounts: []InventoryAccount{}}
+	for _, a := range accounts {
+		inv.Accounts = append(inv.Accounts, *a)
+	}
+	sort.Slice(inv.Accounts, func(i, j int) bool { return inv.Accounts[i].AccountID < inv.Accounts[j].AccountID })
+	return inv
+}
+
+// inventorySecrets reads a target's merged secrets as names and hashes
+func inventorySecrets(ctx context.Context, store mergeStore, targetName string) ([]InventorySecret, error) {
+	snapshot, err := readSnapshot(ctx, store, targetName)
+	if err != nil {
+		return nil, err
+	}
+	secrets := make([]InventorySecret, 0, len(snapshot))
+	for name, data := range snapshot {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %q: %w", name, err)
+		}
+		sum := sha256.Sum256(b)
+		secrets = append(secrets, InventorySecret{Name: name, SHA256: hex.EncodeToString(sum[:])})
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
+
+// listDestination marks which secrets exist in a Secrets Manager destination
+func listDestination(ctx context.Context, lister secretLister, target Target, d Destination, secrets []InventorySecret) error {
+	listed, err := lister.SecretsManagerSecrets(ctx, target, d)
+	if err != nil {
+		return err
+	}
+	for i := range secrets {
+		changed, ok := listed[secrets[i].Name]
+		present := ok
+		secrets[i].InDestination = &present
+		if ok && !changed.IsZero() {
+			secrets[i].LastChanged = &changed
+		}
+	}
+	return nil
+}
+
+// inventorySources describes where each of a target's imports reads from
+func (c *Config) inventorySources(target Target) []InventorySource {
+	sources := make([]InventorySource, 0, len(target.Imports))
+	for _, imp := range target.Imports {
+		ref, err := ParseImportRef(imp)
+		if err != nil {
+			continue
+		}
+		s := InventorySource{Import: ref.Name, Version: ref.Version}
+		src, isSource := c.Sources[ref.Name]
+		switch {
+		case !isSource:
+			s.Type = "target"
+		case src.Vault != nil:
+			s.Type = "vault"
+			s.Paths = []string{src.Vault.Mount + "/"}
+			if len(src.Vault.Paths) > 0 {
+				s.Paths = nil
+				for _, path := range src.Vault.Paths {
+					s.Paths = append(s.Paths, src.Vault.Mount+"/"+strings.TrimPrefix(path, "/"))
+				}
+			}
+		case src.Doppler != nil:
+			s.Type = "doppler"
+			s.Paths = []string{src.Doppler.Project + "/" + src.Doppler.Config}
+		case src.AWS != nil:
+			s.Type = "aws"
+			s.Paths = []string{fmt.Sprintf("%s/%s", src.AWS.AccountID, src.AWS.Prefix)}
+		}
+		sources = append(sources, s)
+	}
+	return sources
+}
+
+// SecretsManagerSecrets lists the secrets in a destination's account and
+// region, reached the same way as the target's preconditions
+func (r *liveReadinessProbe) SecretsManagerSecrets(ctx context.Context, target Target, d Destination) (map[string]time.Time, error) {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]time.Time)
+	paginator := secretsmanager.NewListSecretsPaginator(secretsmanager.NewFromConfig(cfg), &secretsmanager.ListSecretsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		for _, s := range page.SecretList {
+			secrets[aws.ToString(s.Name)] = aws.ToTime(s.LastChangedDate)
+		}
+	}
+	return secrets, nil
+}
+
+// destinationTarget returns the target as seen from one of its AWS
+// destinations, so awsConfig reaches the destination's account and region
+func destinationTarget(target Target, d Destination) Target {
+	t := target
+	t.AccountID = d.AccountID
+	if d.RoleARN != "" {
+		t.RoleARN = d.RoleARN
+	}
+	if d.Region != "" {
+		t.Region = d.Region
+	}
+	return t
+}
+
+// WriteInventory writes an inventory to path, signed with the
+// pipeline.manifest signer
+func (p *Pipeline) WriteInventory(ctx context.Context, inv *Inventory, path string) error {
+	settings := p.config.Pipeline.Manifest
+	if settings == nil {
+		return fmt.Errorf("signing an inventory requires pipeline.manifest.kms_key_id or pipeline.manifest.cosign")
+	}
+	return p.writeSigned(ctx, settings, path, InventoryPayloadType, inv)
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretLister answers Secrets Manager listings keyed by account ID
+type fakeSecretLister map[string]map[string]time.Time
+
+func (f fakeSecretLister) SecretsManagerSecrets(_ context.Context, _ Target, d Destination) (map[string]time.Time, error) {
+	secrets, ok := f[d.AccountID]
+	if !ok {
+		return nil, fmt.Errorf("AccessDenied")
+	}
+	return secrets, nil
+}
+
+func TestBuildInventory(t *testing.T) {
+	ctx := context.Background()
+	changed := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	synced := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cfg := &Config{
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics", Paths: []string{"db"}}},
+		},
+		Targets: map[string]Target{
+			"Stg":  {AccountID: "111111111111", Imports: []string{"analytics"}},
+			"Prod": {AccountID: "222222222222", Imports: []string{"Stg@3"}},
+			"Repo": {GitHub: &GitHubDestination{Owner: "org", Repo: "app"}, Imports: []string{"Stg"}},
+		},
+	}
+	store := memMergeStore{
+		"Stg":  {"db": {"password": "hunter2"}, "api": {"token": "abc"}},
+		"Prod": {"db": {"password": "hunter2"}},
+		"Repo": {"db": {"password": "hunter2"}},
+	}
+	lister := fakeSecretLister{
+		"111111111111": {"db": changed},
+	}
+	status := map[string]TargetStatus{
+		"Stg": {Target: "Stg", Success: true, RunID: "run-1", Finished: synced},
+	}
+
+	inv := buildInventory(ctx, cfg, store, lister, status, []string{"Prod", "Repo", "Stg"}, synced)
+	require.Len(t, inv.Accounts, 3)
+
+	// Non-AWS destinations sort first, without an account
+	assert.Empty(t, inv.Accounts[0].AccountID)
+	repo := inv.Accounts[0].Destinations[0]
+	assert.Equal(t, "github:org/app", repo.Destination)
+	require.Len(t, repo.Secrets, 1)
+	assert.Nil(t, repo.Secrets[0].InDestination)
+
+	stg := inv.Accounts[1].Destinations[0]
+	assert.Equal(t, "111111111111", inv.Accounts[1].AccountID)
+	assert.Equal(t, []InventorySource{{Import: "analytics", Type: "vault", Paths: []string{"analytics/db"}}}, stg.Sources)
+	assert.Equal(t, synced, *stg.LastSync)
+	assert.Equal(t, "run-1", stg.LastSyncRun)
+	require.Len(t, stg.Secrets, 2)
+	assert.Equal(t, "api", stg.Secrets[0].Name)
+	assert.False(t, *stg.Secrets[0].InDestination)
+	assert.True(t, *stg.Secrets[1].InDestination)
+	assert.Equal(t, changed, *stg.Secrets[1].LastChanged)
+	// Same value, same hash, and the value itself never appears
+	assert.Equal(t, stg.Secrets[1].SHA256, repo.Secrets[0].SHA256)
+	assert.Len(t, stg.Secrets[1].SHA256, 64)
+
+	prod := inv.Accounts[2].Destinations[0]
+	assert.Equal(t, []InventorySource{{Import: "Stg", Type: "target", Version: 3}}, prod.Sources)
+	assert.Nil(t, prod.LastSync)
+	assert.Equal(t, "AccessDenied", prod.Error)
+	assert.Equal(t, []string{"Prod -> aws:222222222222: AccessDenied"}, inv.Errors())
+}
+
+func TestWriteInventory(t *testing.T) {
+	signer := newFakeSigningKMS(t)
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	p := &Pipeline{config: &Config{}, manifestKMS: signer}
+	inv := &Inventory{Version: 1, Accounts: []InventoryAccount{{AccountID: "111111111111"}}}
+
+	assert.ErrorContains(t, p.WriteInventory(context.Background(), inv, path), "requires pipeline.manifest")
+
+	p.config.Pipeline.Manifest = &ManifestSettings{Dir: t.TempDir(), KMSKeyID: "alias/vss-manifests"}
+	require.NoError(t, p.WriteInventory(context.Background(), inv, path))
+	sm, err := LoadSignedManifest(path)
+	require.NoError(t, err)
+	require.NoError(t, VerifyManifestPublicKey(sm, signer.publicKeyPEM(t)))
+	_, err = sm.Manifest()
+	assert.ErrorContains(t, err, "unexpected payload type")
+	got, err := sm.Inventory()
+	require.NoError(t, err)
+	assert.Equal(t, "111111111111", got.Accounts[0].AccountID)
+}
@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/stores/kubernetes"
+	"github.com/jbcom/secretsync/stores/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// discoveredCluster is a cluster found during discovery along with where it was found
+type discoveredCluster struct {
+	kubernetes.ClusterInfo
+	AccountID string // EKS
+	Project   string // GKE
+}
+
+// discoverFromKubernetes lists EKS clusters per account and region (or GKE clusters
+// per project) and maps them to Kubernetes destination targets
+func (d *DiscoveryService) discoverFromKubernetes(dynamicName string, dt DynamicTarget) (map[string]Target, error) {
+	cfg := dt.Discovery.Kubernetes
+	l := log.WithFields(log.Fields{
+		"action":   "discoverFromKubernetes",
+		"provider": cfg.Provider,
+	})
+	l.Debug("Discovering Kubernetes clusters")
+
+	var clusters []discoveredCluster
+	switch cfg.Provider {
+	case kubernetes.ProviderEKS:
+		accounts := cfg.Accounts
+		if len(accounts) == 0 {
+			if d.awsCtx == nil || d.awsCtx.CallerIdentity == nil {
+				return nil, fmt.Errorf("kubernetes.accounts is required when the AWS execution context is unavailable")
+			}
+			accounts = []string{d.awsCtx.CallerIdentity.AccountID}
+		}
+		regions := cfg.Regions
+		if len(regions) == 0 {
+			region := dt.Region
+			if region == "" {
+				region = d.config.AWS.Region
+			}
+			regions = []string{region}
+		}
+		for _, accountID := range accounts {
+			if isExcluded(accountID, dt.Exclude) {
+				continue
+			}
+			for _, region := range regions {
+				awsCfg, err := d.eksConfig(dt, accountID, region)
+				if err != nil {
+					return nil, err
+				}
+				found, err := kubernetes.ListEKSClusters(d.ctx, awsCfg)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list EKS clusters in %s/%s: %w", accountID, region, err)
+				}
+				for _, c := range found {
+					clusters = append(clusters, discoveredCluster{ClusterInfo: c, AccountID: accountID})
+				}
+			}
+		}
+	case kubernetes.ProviderGKE:
+		for _, project := range cfg.Projects {
+			found, err := kubernetes.ListGKEClusters(d.ctx, project, cfg.Location)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list GKE clusters in %s: %w", project, err)
+			}
+			for _, c := range found {
+				clusters = append(clusters, discoveredCluster{ClusterInfo: c, Project: project})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kubernetes provider %q", cfg.Provider)
+	}
+
+	targets := mapKubernetesClusters(dynamicName, dt, clusters)
+	l.WithField("count", len(targets)).Debug("Mapped Kubernetes clusters to targets")
+	return targets, nil
+}
+
+// eksConfig returns AWS config for listing clusters in an account, assuming the
+// dynamic target's role (or the configured role pattern) for other accounts
+func (d *DiscoveryService) eksConfig(dt DynamicTarget, accountID, region string) (aws.Config, error) {
+	var base aws.Config
+	if d.awsCtx != nil {
+		base = d.awsCtx.BaseConfig.Copy()
+	} else {
+		cfg, err := config.LoadDefaultConfig(d.ctx, withRateLimit())
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		base = cfg
+	}
+	base.Region = region
+
+	if d.awsCtx != nil && d.awsCtx.CallerIdentity != nil && d.awsCtx.CallerIdentity.AccountID == accountID {
+		return base, nil
+	}
+	roleARN := strings.ReplaceAll(dt.RoleARN, "{{.AccountID}}", accountID)
+	if roleARN == "" {
+		roleARN = d.config.GetRoleARN(accountID)
+	}
+	target := Target{RoleChain: dt.RoleChain, AssumeRole: dt.AssumeRole}
+	base.Credentials = assumeRoleCredentials(base, d.config.roleChain(target), roleARN, d.config.assumeRole(target).stsOptions(d.config.AWS.session, "", accountID))
+	return base, nil
+}
+
+// mapKubernetesClusters filters clusters by status, tags and exclusions and converts
+// them to Kubernetes destination targets named "<dynamic>_<cluster>"
+func mapKubernetesClusters(dynamicName string, dt DynamicTarget, clusters []discoveredCluster) map[string]Target {
+	cfg := dt.Discovery.Kubernetes
+	targets := make(map[string]Target)
+
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	sorted := append([]discoveredCluster(nil), clusters...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].AccountID+sorted[i].Project+sorted[i].Location <
+			sorted[j].AccountID+sorted[j].Project+sorted[j].Location
+	})
+
+	for _, c := range sorted {
+		l := log.WithField("cluster", c.Name)
+		if !c.Active() {
+			l.WithField("status", c.Status).Debug("Cluster is not active, skipping")
+			continue
+		}
+		if isExcluded(c.Name, dt.Exclude) {
+			continue
+		}
+		if !hasAllLabels(c.Labels, cfg.Tags) {
+			continue
+		}
+
+		name := sanitizeTargetName(fmt.Sprintf("%s_%s", dynamicName, c.Name))
+		if _, exists := targets[name]; exists {
+			l.Warn("Cluster name collides with another discovered cluster, skipping")
+			continue
+		}
+
+		target := Target{
+			Imports:        dt.Imports,
+			SecretPrefix:   dt.SecretPrefix,
+			Classification: dt.Classification,
+			Owners:         dt.Owners,
+			Tags:           dt.Tags,
+			Labels:         dt.Labels,
+			Kubernetes: &KubernetesDestination{
+				Provider:  cfg.Provider,
+				Cluster:   c.Name,
+				Namespace: namespace,
+			},
+		}
+		if cfg.Provider == kubernetes.ProviderEKS {
+			target.AccountID = c.AccountID
+			target.Region = c.Location
+			target.RoleARN = strings.ReplaceAll(dt.RoleARN, "{{.AccountID}}", c.AccountID)
+		} else {
+			target.Kubernetes.Project = c.Project
+			target.Kubernetes.Location = c.Location
+		}
+		targets[name] = target
+	}
+	return targets
+}
+
+func hasAllLabels(labels, wanted map[string]string) bool {
+	for k, v := range wanted {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// createKubernetesSync creates a VaultSecretSync for syncing to Kubernetes Secrets
+func (p *Pipeline) createKubernetesSync(targetName, sourcePath, roleARN, region string, dest *KubernetesDestination, dryRun bool) v1alpha1.VaultSecretSync {
+	client := &kubernetes.KubernetesClient{
+		Provider:  dest.Provider,
+		Cluster:   dest.Cluster,
+		Project:   dest.Project,
+		Location:  dest.Location,
+		Namespace: dest.Namespace,
+		Name:      "$1",
+		Merge:     boolPtr(true),
+	}
+	if dest.Provider == kubernetes.ProviderEKS {
+		client.Region = region
+		client.RoleArn = roleARN
+	}
+
+	sync := v1alpha1.VaultSecretSync{
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			DryRun:     boolPtr(dryRun),
+			SyncDelete: boolPtr(p.config.Pipeline.Sync.DeleteOrphans),
+			Source: &vault.VaultClient{
+				Address:   p.config.Vault.Address,
+				Namespace: p.config.Vault.Namespace,
+				Path:      fmt.Sprintf("%s/(.*)", sourcePath),
+			},
+			Dest: []*v1alpha1.StoreConfig{
+				{
+					Kubernetes: client,
+				},
+			},
+		},
+	}
+	sync.Name = fmt.Sprintf("sync-%s", targetName)
+	sync.Namespace = "pipeline"
+	return sync
+}
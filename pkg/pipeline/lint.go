@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// credentialKeys match the config keys that hold credentials. They must be
+// ${VAR} references so the values never live in a file committed to Git.
+var credentialKeys = []*regexp.Regexp{
+	regexp.MustCompile(`^vault\.auth\.approle\.secret_id$`),
+	regexp.MustCompile(`^vault\.auth\.token\.token$`),
+	regexp.MustCompile(`^github\.private_key$`),
+	regexp.MustCompile(`\.doppler\.token$`),
+	regexp.MustCompile(`^pipeline\.diff_hash_salt$`),
+}
+
+// credentialFormats recognize well-known credentials wherever they appear
+var credentialFormats = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"Vault token", regexp.MustCompile(`^(hv[sbr]\.[A-Za-z0-9_-]{20,}|s\.[A-Za-z0-9]{24})$`)},
+	{"Doppler token", regexp.MustCompile(`^dp\.[a-z]+\.[A-Za-z0-9_.-]{20,}$`)},
+	{"GitHub token", regexp.MustCompile(`^(gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{22,})$`)},
+	{"AWS access key ID", regexp.MustCompile(`^(AKIA|ASIA)[0-9A-Z]{16}$`)},
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+}
+
+var envReferencePattern = regexp.MustCompile(`^\$\{[A-Za-z_][A-Za-z0-9_]*\}$`)
+
+// LiteralSecret is a credential written directly into a config file
+type LiteralSecret struct {
+	Key    string
+	Line   int
+	Column int
+	// Kind describes the credential, e.g. "Vault token" or "credential"
+	Kind string
+}
+
+// Guidance explains how to move the credential out of the config file
+func (s LiteralSecret) Guidance() string {
+	ref := "use a ${VAR} reference and supply the value from the environment or your CI secret store"
+	// List items cannot be overridden directly from the environment
+	if !strings.Contains(s.Key, "[") {
+		ref = fmt.Sprintf("remove it and set %s, or %s", envVarName(s.Key), ref)
+	}
+	return ref
+}
+
+func (s LiteralSecret) String() string {
+	return fmt.Sprintf("line %d, column %d: %s contains a literal %s; %s", s.Line, s.Column, s.Key, s.Kind, s.Guidance())
+}
+
+// LiteralSecretsError is returned by Validate when the config file contains
+// credentials instead of references to them
+type LiteralSecretsError struct {
+	Secrets []LiteralSecret
+}
+
+func (e *LiteralSecretsError) Error() string {
+	lines := make([]string, 0, len(e.Secrets))
+	for _, s := range e.Secrets {
+		lines = append(lines, "  "+s.String())
+	}
+	return fmt.Sprintf("config contains %d literal credential(s):\n%s", len(e.Secrets), strings.Join(lines, "\n"))
+}
+
+// FindLiteralSecrets returns the credentials written directly into a YAML config
+// document: credential fields that are not ${VAR} references, and any value that
+// looks like a well-known token or private key
+func FindLiteralSecrets(data []byte) ([]LiteralSecret, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var secrets []LiteralSecret
+	collectLiteralSecrets(&doc, "", &secrets)
+	return secrets, nil
+}
+
+func collectLiteralSecrets(node *yaml.Node, path string, out *[]LiteralSecret) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			collectLiteralSecrets(n, path, out)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			collectLiteralSecrets(node.Content[i+1], joinKey(path, node.Content[i].Value), out)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			collectLiteralSecrets(item, fmt.Sprintf("%s[%d]", path, i), out)
+		}
+	case yaml.ScalarNode:
+		if kind := literalSecretKind(path, node.Value); kind != "" {
+			*out = append(*out, LiteralSecret{Key: path, Line: node.Line, Column: node.Column, Kind: kind})
+		}
+	}
+}
+
+// literalSecretKind describes the credential in value, or returns "" if there is none
+func literalSecretKind(path, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || envReferencePattern.MatchString(value) {
+		return ""
+	}
+	for _, f := range credentialFormats {
+		if f.pattern.MatchString(value) {
+			return f.name
+		}
+	}
+	for _, k := range credentialKeys {
+		if k.MatchString(path) {
+			return "credential"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,375 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/jbcom/secretsync/internal/fips"
+	"github.com/jbcom/secretsync/pkg/diff"
+)
+
+// ManifestPayloadType identifies a run manifest inside its signed envelope
+const ManifestPayloadType = "application/vnd.vss.run-manifest+json"
+
+// ManifestSettings writes a signed manifest of every pipeline run: the run's
+// results and diff, the sha256 of the vss binary and of the config file. The
+// manifest is signed with an AWS KMS asymmetric key or keyless with cosign,
+// and `vss verify-run` checks it.
+//
+//	pipeline:
+//	  manifest:
+//	    dir: /var/lib/vss/manifests
+//	    kms_key_id: alias/vss-manifests
+type ManifestSettings struct {
+	Dir string `mapstructure:"dir" yaml:"dir"`
+	// KMSKeyID is an ECC_NIST_P256 SIGN_VERIFY key that signs manifests
+	KMSKeyID string `mapstructure:"kms_key_id" yaml:"kms_key_id,omitempty"`
+	// Cosign signs manifests keyless with the cosign CLI, using the ambient
+	// OIDC identity (e.g. GitHub Actions) for the signing certificate. The
+	// Sigstore bundle is written next to the manifest.
+	Cosign bool `mapstructure:"cosign" yaml:"cosign,omitempty"`
+}
+
+func (m *ManifestSettings) validate() error {
+	if m.Dir == "" {
+		return fmt.Errorf("dir is required")
+	}
+	if m.KMSKeyID == "" && !m.Cosign {
+		return fmt.Errorf("one of kms_key_id or cosign is required")
+	}
+	if m.KMSKeyID != "" && m.Cosign {
+		return fmt.Errorf("kms_key_id and cosign are mutually exclusive")
+	}
+	return nil
+}
+
+// Provenance identifies who and what produced a run, for its manifest
+type Provenance struct {
+	// Version is the vss release
+	Version string
+	// ConfigFile is the config file the run loaded
+	ConfigFile string
+	// Operator is the user or CI actor running vss
+	Operator string
+	// GitSHA is the commit of the repository holding the config file, if any
+	GitSHA string
+}
+
+// RunManifest describes a pipeline run and what produced it
+type RunManifest struct {
+	Version  int        `json:"version"`
+	Run      RunRecord  `json:"run"`
+	Binary   BinaryInfo `json:"binary"`
+	Config   FileDigest `json:"config"`
+	Operator string     `json:"operator,omitempty"`
+	// Changes is the run's diff when one was computed; secret values only
+	// appear as hashes
+	Changes *diff.PipelineDiff `json:"changes,omitempty"`
+}
+
+// BinaryInfo identifies the vss binary that ran
+type BinaryInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+	FIPS      string `json:"fips"`
+	SHA256    string `json:"sha256"`
+}
+
+// FileDigest is a file and the sha256 of its contents
+type FileDigest struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// SignedManifest is a run manifest or inventory in a DSSE envelope. Payload
+// is its JSON; each signature covers the DSSE pre-authentication encoding.
+// Files signed with cosign have no signatures here, the Sigstore bundle sits
+// next to the file instead.
+type SignedManifest struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     []byte              `json:"payload"`
+	Signatures  []ManifestSignature `json:"signatures"`
+}
+
+// ManifestSignature is an ECDSA P-256 SHA-256 signature by a KMS key
+type ManifestSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   []byte `json:"sig"`
+}
+
+// Manifest decodes the signed payload. It does not verify signatures.
+func (s *SignedManifest) Manifest() (*RunManifest, error) {
+	if s.PayloadType != ManifestPayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", s.PayloadType)
+	}
+	var m RunManifest
+	if err := json.Unmarshal(s.Payload, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest payload: %w", err)
+	}
+	return &m, nil
+}
+
+// digest returns the sha256 of the envelope's DSSE pre-authentication encoding
+func (s *SignedManifest) digest() []byte {
+	pae := fmt.Sprintf("DSSEv1 %d %s %d ", len(s.PayloadType), s.PayloadType, len(s.Payload))
+	h := sha256.New()
+	h.Write([]byte(pae))
+	h.Write(s.Payload)
+	return h.Sum(nil)
+}
+
+// manifestKMS is the subset of the KMS API manifest signing needs
+type manifestKMS interface {
+	Sign(ctx context.Context, params *kms.SignInput, optFns ...func(*kms.Options)) (*kms.SignOutput, error)
+}
+
+// ManifestVerifier is the subset of the KMS API needed to verify a manifest
+// online; *kms.Client implements it
+type ManifestVerifier interface {
+	Verify(ctx context.Context, params *kms.VerifyInput, optFns ...func(*kms.Options)) (*kms.VerifyOutput, error)
+}
+
+func newManifestKMS(ctx context.Context, region string) (manifestKMS, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), withRateLimit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return kms.NewFromConfig(awsCfg), nil
+}
+
+// runCosign runs the cosign CLI; tests replace it
+var runCosign = func(ctx context.Context, args ...string) error {
+	out, err := exec.CommandContext(ctx, "cosign", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cosign %s: %w: %s", args[0], err, out)
+	}
+	return nil
+}
+
+// executableDigest hashes the running binary
+var executableDigest = func() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return fileSHA256(path)
+}
+
+// NewRunManifest describes a recorded run. The config file is hashed as it
+// is on disk now, so it should be called right after the run.
+func NewRunManifest(rec RunRecord, prov Provenance, changes *diff.PipelineDiff) (*RunManifest, error) {
+	binSum, err := executableDigest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash vss binary: %w", err)
+	}
+	m := &RunManifest{
+		Version: 1,
+		Run:     rec,
+		Binary: BinaryInfo{
+			Version:   prov.Version,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+			FIPS:      fips.Mode(),
+			SHA256:    binSum,
+		},
+		Operator: prov.Operator,
+		Changes:  changes,
+	}
+	if prov.ConfigFile != "" {
+		m.Config, err = DigestFile(prov.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash config file: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// DigestFile hashes a file for comparison with a manifest
+func DigestFile(path string) (FileDigest, error) {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return FileDigest{}, err
+	}
+	return FileDigest{Path: path, SHA256: sum}, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// signPayload wraps v in an envelope of the given payload type, signed by
+// keyID when set
+func signPayload(ctx context.Context, client manifestKMS, keyID, payloadType string, v interface{}) (*SignedManifest, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	sm := &SignedManifest{PayloadType: payloadType, Payload: payload, Signatures: []ManifestSignature{}}
+	if keyID == "" {
+		return sm, nil
+	}
+	out, err := client.Sign(ctx, &kms.SignInput{
+		KeyId:            aws.String(keyID),
+		Message:          sm.digest(),
+		MessageType:      kmstypes.MessageTypeDigest,
+		SigningAlgorithm: kmstypes.SigningAlgorithmSpecEcdsaSha256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign payload: %w", err)
+	}
+	sm.Signatures = append(sm.Signatures, ManifestSignature{KeyID: aws.ToString(out.KeyId), Sig: out.Signature})
+	return sm, nil
+}
+
+// writeSigned signs v as configured in settings and writes the envelope to
+// path, with its Sigstore bundle beside it when signing with cosign
+func (p *Pipeline) writeSigned(ctx context.Context, settings *ManifestSettings, path, payloadType string, v interface{}) error {
+	if settings.KMSKeyID != "" && p.manifestKMS == nil {
+		var err error
+		p.manifestKMS, err = newManifestKMS(ctx, p.config.AWS.Region)
+		if err != nil {
+			return fmt.Errorf("failed to create KMS client: %w", err)
+		}
+	}
+	sm, err := signPayload(ctx, p.manifestKMS, settings.KMSKeyID, payloadType, v)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if settings.Cosign {
+		if err := runCosign(ctx, "sign-blob", "--yes", "--bundle", CosignBundlePath(path), path); err != nil {
+			return fmt.Errorf("failed to sign %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// writeRunManifest writes and signs the manifest of a recorded run as
+// <dir>/<run id>.manifest.json and returns its path
+func (p *Pipeline) writeRunManifest(ctx context.Context, settings *ManifestSettings, rec RunRecord, prov Provenance) (string, error) {
+	m, err := NewRunManifest(rec, prov, p.Diff())
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(settings.Dir, rec.ID+".manifest.json")
+	if err := p.writeSigned(ctx, settings, path, ManifestPayloadType, m); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// CosignBundlePath returns where the Sigstore bundle of a manifest is written
+func CosignBundlePath(manifestPath string) string {
+	return manifestPath + ".sigstore.json"
+}
+
+// LoadSignedManifest reads a manifest written by a pipeline run
+func LoadSignedManifest(path string) (*SignedManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var sm SignedManifest
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return &sm, nil
+}
+
+// VerifyManifestKMS checks every signature with kms:Verify
+func VerifyManifestKMS(ctx context.Context, client ManifestVerifier, sm *SignedManifest) error {
+	if len(sm.Signatures) == 0 {
+		return fmt.Errorf("manifest has no KMS signatures")
+	}
+	for _, sig := range sm.Signatures {
+		out, err := client.Verify(ctx, &kms.VerifyInput{
+			KeyId:            aws.String(sig.KeyID),
+			Message:          sm.digest(),
+			MessageType:      kmstypes.MessageTypeDigest,
+			Signature:        sig.Sig,
+			SigningAlgorithm: kmstypes.SigningAlgorithmSpecEcdsaSha256,
+		})
+		var invalid *kmstypes.KMSInvalidSignatureException
+		if errors.As(err, &invalid) || (err == nil && !out.SignatureValid) {
+			return fmt.Errorf("signature by %s is invalid", sig.KeyID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to verify signature by %s: %w", sig.KeyID, err)
+		}
+	}
+	return nil
+}
+
+// VerifyManifestPublicKey checks every signature offline against the
+// signing key's public key, PEM or DER encoded as returned by
+// `aws kms get-public-key`
+func VerifyManifestPublicKey(sm *SignedManifest, publicKey []byte) error {
+	if len(sm.Signatures) == 0 {
+		return fmt.Errorf("manifest has no KMS signatures")
+	}
+	if block, _ := pem.Decode(publicKey); block != nil {
+		publicKey = block.Bytes
+	}
+	key, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("public key is %T, want an ECDSA key", key)
+	}
+	for _, sig := range sm.Signatures {
+		if !ecdsa.VerifyASN1(ecKey, sm.digest(), sig.Sig) {
+			return fmt.Errorf("signature by %s is invalid", sig.KeyID)
+		}
+	}
+	return nil
+}
+
+// VerifyManifestCosign checks a manifest's Sigstore bundle with the cosign
+// CLI, requiring the given certificate identity and OIDC issuer
+func VerifyManifestCosign(ctx context.Context, manifestPath, bundlePath, identity, issuer string) error {
+	if err := runCosign(ctx, "verify-blob",
+		"--bundle", bundlePath,
+		"--certificate-identity", identity,
+		"--certificate-oidc-issuer", issuer,
+		manifestPath); err != nil {
+		return fmt.Errorf("cosign verification failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifestKeyARN = "arn:aws:kms:us-east-1:111111111111:key/manifest"
+
+// fakeSigningKMS signs digests with a local P-256 key like an ECC_NIST_P256 KMS key
+type fakeSigningKMS struct {
+	key *ecdsa.PrivateKey
+}
+
+func newFakeSigningKMS(t *testing.T) *fakeSigningKMS {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &fakeSigningKMS{key: key}
+}
+
+func (f *fakeSigningKMS) Sign(_ context.Context, in *kms.SignInput, _ ...func(*kms.Options)) (*kms.SignOutput, error) {
+	sig, err := ecdsa.SignASN1(rand.Reader, f.key, in.Message)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.SignOutput{KeyId: aws.String(testManifestKeyARN), Signature: sig}, nil
+}
+
+func (f *fakeSigningKMS) Verify(_ context.Context, in *kms.VerifyInput, _ ...func(*kms.Options)) (*kms.VerifyOutput, error) {
+	if !ecdsa.VerifyASN1(&f.key.PublicKey, in.Message, in.Signature) {
+		return nil, &kmstypes.KMSInvalidSignatureException{}
+	}
+	return &kms.VerifyOutput{SignatureValid: true}, nil
+}
+
+func (f *fakeSigningKMS) publicKeyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&f.key.PublicKey)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func manifestTestRun(t *testing.T) (RunRecord, Provenance) {
+	t.Helper()
+	orig := executableDigest
+	executableDigest = func() (string, error) { return "b1nary", nil }
+	t.Cleanup(func() { executableDigest = orig })
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(cfgPath, []byte("targets: {}\n"), 0o644))
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	rec := newRunRecord(Options{Operation: OperationPipeline}, nil, start, start.Add(time.Minute), []Result{
+		{Target: "Serverless_Prod", Phase: "sync", Success: true},
+	}, nil)
+	return rec, Provenance{Version: "v1.4.0", ConfigFile: cfgPath, Operator: "octocat"}
+}
+
+func TestWriteRunManifestKMS(t *testing.T) {
+	rec, prov := manifestTestRun(t)
+	signer := newFakeSigningKMS(t)
+	dir := t.TempDir()
+	p := &Pipeline{config: &Config{}, manifestKMS: signer}
+	p.initDiff(false, "")
+	p.addTargetDiff(diff.TargetDiff{Target: "Serverless_Prod", Summary: diff.ChangeSummary{Added: 2, Total: 2}})
+
+	path, err := p.writeRunManifest(context.Background(), &ManifestSettings{Dir: dir, KMSKeyID: "alias/vss-manifests"}, rec, prov)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, rec.ID+".manifest.json"), path)
+
+	sm, err := LoadSignedManifest(path)
+	require.NoError(t, err)
+	require.Len(t, sm.Signatures, 1)
+	assert.Equal(t, testManifestKeyARN, sm.Signatures[0].KeyID)
+	require.NoError(t, VerifyManifestKMS(context.Background(), signer, sm))
+	require.NoError(t, VerifyManifestPublicKey(sm, signer.publicKeyPEM(t)))
+
+	m, err := sm.Manifest()
+	require.NoError(t, err)
+	assert.Equal(t, rec.ID, m.Run.ID)
+	assert.Equal(t, "v1.4.0", m.Binary.Version)
+	assert.Equal(t, "b1nary", m.Binary.SHA256)
+	assert.Equal(t, "octocat", m.Operator)
+	assert.Equal(t, 2, m.Changes.Summary.Added)
+	cfg, err := DigestFile(prov.ConfigFile)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, m.Config)
+
+	// Any change to the payload breaks the signature
+	sm.Payload = []byte(string(sm.Payload[:len(sm.Payload)-1]) + " }")
+	assert.ErrorContains(t, VerifyManifestKMS(context.Background(), signer, sm), "is invalid")
+	assert.ErrorContains(t, VerifyManifestPublicKey(sm, signer.publicKeyPEM(t)), "is invalid")
+
+	// As does a different key
+	other := newFakeSigningKMS(t)
+	sm, err = LoadSignedManifest(path)
+	require.NoError(t, err)
+	assert.ErrorContains(t, VerifyManifestPublicKey(sm, other.publicKeyPEM(t)), "is invalid")
+}
+
+func TestWriteRunManifestCosign(t *testing.T) {
+	rec, prov := manifestTestRun(t)
+	var calls [][]string
+	orig := runCosign
+	runCosign = func(_ context.Context, args ...string) error {
+		calls = append(calls, args)
+		return nil
+	}
+	t.Cleanup(func() { runCosign = orig })
+
+	p := &Pipeline{config: &Config{}}
+	path, err := p.writeRunManifest(context.Background(), &ManifestSettings{Dir: t.TempDir(), Cosign: true}, rec, prov)
+	require.NoError(t, err)
+
+	sm, err := LoadSignedManifest(path)
+	require.NoError(t, err)
+	assert.Empty(t, sm.Signatures)
+	assert.ErrorContains(t, VerifyManifestPublicKey(sm, nil), "no KMS signatures")
+
+	require.NoError(t, VerifyManifestCosign(context.Background(), path, CosignBundlePath(path), "https://github.com/org/secrets/.github/workflows/sync.yml@refs/heads/main", "https://token.actions.githubusercontent.com"))
+	assert.Equal(t, [][]string{
+		{"sign-blob", "--yes", "--bundle", path + ".sigstore.json", path},
+		{"verify-blob", "--bundle", path + ".sigstore.json",
+			"--certificate-identity", "https://github.com/org/secrets/.github/workflows/sync.yml@refs/heads/main",
+			"--certificate-oidc-issuer", "https://token.actions.githubusercontent.com", path},
+	}, calls)
+}
+
+func TestManifestSettingsValidate(t *testing.T) {
+	assert.NoError(t, (&ManifestSettings{Dir: "runs", KMSKeyID: "alias/vss"}).validate())
+	assert.NoError(t, (&ManifestSettings{Dir: "runs", Cosign: true}).validate())
+	assert.ErrorContains(t, (&ManifestSettings{KMSKeyID: "alias/vss"}).validate(), "dir is required")
+	assert.ErrorContains(t, (&ManifestSettings{Dir: "runs"}).validate(), "one of kms_key_id or cosign")
+	assert.ErrorContains(t, (&ManifestSettings{Dir: "runs", KMSKeyID: "alias/vss", Cosign: true}).validate(), "mutually exclusive")
+}
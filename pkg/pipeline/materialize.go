@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jbcom/secretsync/pkg/utils"
+	"github.com/jbcom/secretsync/stores/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// vaultReader is the subset of the Vault client used to read source secrets;
+// *vault.VaultClient implements it
+type vaultReader interface {
+	ListSecrets(ctx context.Context, p string) ([]string, error)
+	GetSecret(ctx context.Context, s string) ([]byte, error)
+}
+
+// vaultSourceReader returns a client for a Vault source, falling back to the
+// pipeline's Vault address and namespace
+func (p *Pipeline) vaultSourceReader(ctx context.Context, src *VaultSource) (vaultReader, error) {
+	if p.openVaultSource != nil {
+		return p.openVaultSource(ctx, src)
+	}
+	vc := &vault.VaultClient{
+		Address:      cmp.Or(src.Address, p.config.Vault.Address),
+		Namespace:    cmp.Or(src.Namespace, p.config.Vault.Namespace),
+		ListPageSize: src.PageSize,
+	}
+	if err := vc.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to initialize vault client: %w", err)
+	}
+	return vc, nil
+}
+
+// readImport reads the secrets an import contributes to a target merged in
+// memory: a Vault source's secrets with its key transforms applied, or an
+// inherited target's merged output, which the dependency order has already
+// written (or, in a dry run, recorded)
+func (p *Pipeline) readImport(ctx context.Context, store mergeStore, importName string) (map[string]map[string]interface{}, error) {
+	if _, ok := p.config.Targets[importName]; ok {
+		p.diffMu.Lock()
+		snapshot, pending := p.pendingMerges[importName]
+		p.diffMu.Unlock()
+		if !pending {
+			var err error
+			snapshot, err = readSnapshot(ctx, store, importName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read merged secrets of %q: %w", importName, err)
+			}
+		}
+		secrets := make(map[string]map[string]interface{}, len(snapshot))
+		for name, v := range snapshot {
+			data, _ := v.(map[string]interface{})
+			secrets[name] = data
+		}
+		return secrets, nil
+	}
+
+	src, ok := p.config.Sources[importName]
+	if !ok || src.Vault == nil {
+		return nil, fmt.Errorf("only vault sources and targets can be imported into an s3 or gcp merge store")
+	}
+	reader, err := p.vaultSourceReader(ctx, src.Vault)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := readVaultSource(ctx, reader, src.Vault)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err = applyKeyRules(src.Transforms.rules(), secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transform keys of %q: %w", importName, err)
+	}
+	return secrets, nil
+}
+
+// readVaultSource reads a Vault source's secrets, keyed by path within the
+// mount, as selected by listVaultSource
+func readVaultSource(ctx context.Context, r vaultReader, src *VaultSource) (map[string]map[string]interface{}, error) {
+	names, err := listVaultSource(ctx, r, src)
+	if err != nil {
+		return nil, err
+	}
+	secrets := make(map[string]map[string]interface{}, len(names))
+	for _, name := range names {
+		b, err := r.GetSecret(ctx, fmt.Sprintf("%s/%s", src.Mount, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s/%s: %w", src.Mount, name, err)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s/%s: %w", src.Mount, name, err)
+		}
+		secrets[name] = data
+	}
+	return secrets, nil
+}
+
+// mergeSecrets deep-merges src into dst secret by secret, with the same
+// semantics as the Vault merge store: lists append, maps merge and scalars
+// from later imports win
+func mergeSecrets(dst, src map[string]map[string]interface{}) {
+	for name, data := range src {
+		dst[name] = utils.DeepMerge(dst[name], data)
+	}
+}
+
+// writeMerged replaces a target's secrets in an S3 or GCP merge store with
+// their merged values and returns the names that failed to write
+func writeMerged(ctx context.Context, store mergeStore, targetName string, merged map[string]map[string]interface{}) ([]string, error) {
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failed []string
+	var lastErr error
+	for _, name := range names {
+		if err := store.WriteSecret(ctx, targetName, name, merged[name]); err != nil {
+			log.WithFields(log.Fields{
+				"action": "writeMerged",
+				"target": targetName,
+				"secret": name,
+			}).WithError(err).Error("Failed to write merged secret")
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			lastErr = fmt.Errorf("failed to write %q: %w", name, err)
+		}
+	}
+	return failed, lastErr
+}
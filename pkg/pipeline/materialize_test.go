@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault is a KV2 tree keyed by full secret path
+type fakeVault map[string]map[string]interface{}
+
+func (f fakeVault) ListSecrets(_ context.Context, p string) ([]string, error) {
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	seen := map[string]bool{}
+	var keys []string
+	for path := range f {
+		rest, ok := strings.CutPrefix(path, prefix)
+		if !ok {
+			continue
+		}
+		if dir, _, nested := strings.Cut(rest, "/"); nested {
+			rest = dir + "/"
+		}
+		if !seen[rest] {
+			seen[rest] = true
+			keys = append(keys, rest)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (f fakeVault) GetSecret(_ context.Context, p string) ([]byte, error) {
+	data, ok := f[p]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", p)
+	}
+	return json.Marshal(data)
+}
+
+func TestReadVaultSource(t *testing.T) {
+	vault := fakeVault{
+		"kv/app/db":         {"password": "hunter2"},
+		"kv/app/nested/api": {"token": "abc"},
+		"kv/other":          {"key": "value"},
+	}
+
+	all, err := readVaultSource(context.Background(), vault, &VaultSource{Mount: "kv"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"app/db":         {"password": "hunter2"},
+		"app/nested/api": {"token": "abc"},
+		"other":          {"key": "value"},
+	}, all)
+
+	some, err := readVaultSource(context.Background(), vault, &VaultSource{Mount: "kv", Paths: []string{"app/nested/", "/other"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"app/nested/api": {"token": "abc"},
+		"other":          {"key": "value"},
+	}, some)
+
+	_, err = readVaultSource(context.Background(), vault, &VaultSource{Mount: "kv", Paths: []string{"missing"}})
+	assert.ErrorContains(t, err, "failed to read kv/missing")
+}
+
+func TestMaterializeMergeWithInheritance(t *testing.T) {
+	ctx := context.Background()
+	p := &Pipeline{
+		config: &Config{
+			Sources: map[string]Source{
+				"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+				"overrides": {Vault: &VaultSource{Mount: "overrides", Paths: []string{"db"}}},
+				"external":  {AWS: &AWSSource{AccountID: "111111111111"}},
+			},
+			Targets: map[string]Target{
+				"Stg":  {Imports: []string{"analytics"}},
+				"Prod": {Imports: []string{"Stg", "overrides"}},
+			},
+		},
+		openVaultSource: func(_ context.Context, _ *VaultSource) (vaultReader, error) {
+			return fakeVault{
+				"analytics/db":  {"host": "db.internal", "replicas": []interface{}{"a"}},
+				"analytics/api": {"token": "stg"},
+				"overrides/db":  {"host": "db.prod", "replicas": []interface{}{"b"}},
+			}, nil
+		},
+	}
+	store := memMergeStore{
+		// A stale merge of the parent must be replaced, not merged into
+		"Stg": {"db": {"host": "old"}},
+	}
+
+	merged := map[string]map[string]interface{}{}
+	secrets, err := p.readImport(ctx, store, "analytics")
+	require.NoError(t, err)
+	mergeSecrets(merged, secrets)
+	failed, err := writeMerged(ctx, store, "Stg", merged)
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+	assert.Equal(t, map[string]interface{}{"host": "db.internal", "replicas": []interface{}{"a"}}, store["Stg"]["db"])
+
+	// Prod inherits Stg's merged output, then deep-merges its own overrides
+	merged = map[string]map[string]interface{}{}
+	for _, imp := range []string{"Stg", "overrides"} {
+		secrets, err := p.readImport(ctx, store, imp)
+		require.NoError(t, err)
+		mergeSecrets(merged, secrets)
+	}
+	_, err = writeMerged(ctx, store, "Prod", merged)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"db":  {"host": "db.prod", "replicas": []interface{}{"a", "b"}},
+		"api": {"token": "stg"},
+	}, store["Prod"])
+	// Merging Prod must not change Stg's secrets
+	assert.Equal(t, []interface{}{"a"}, store["Stg"]["db"]["replicas"])
+
+	_, err = p.readImport(ctx, store, "external")
+	assert.ErrorContains(t, err, "only vault sources and targets")
+}
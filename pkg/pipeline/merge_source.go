@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// mergeStoreSource serves a target's merged secrets from an S3 or GCP merge
+// store to the sync engine, which otherwise reads its sources from Vault.
+// Secrets are addressed as <target>/<secret>; see syncSourcePath.
+type mergeStoreSource struct {
+	store      directMergeStore
+	targetName string
+}
+
+func newMergeStoreSource(store directMergeStore, targetName string) *mergeStoreSource {
+	return &mergeStoreSource{store: store, targetName: targetName}
+}
+
+func (s *mergeStoreSource) Meta() map[string]any {
+	return map[string]any{"path": s.store.GetMergePath(s.targetName)}
+}
+
+func (s *mergeStoreSource) Init(context.Context) error { return nil }
+
+func (s *mergeStoreSource) Validate() error { return nil }
+
+// Driver names the service the merged secrets are kept in
+func (s *mergeStoreSource) Driver() driver.DriverName {
+	if _, ok := s.store.(*GCPMergeStore); ok {
+		return driver.DriverNameGcp
+	}
+	return driver.DriverNameAws
+}
+
+func (s *mergeStoreSource) GetPath() string {
+	return s.targetName + "/(.*)"
+}
+
+// secretName returns the merged secret a source path names
+func (s *mergeStoreSource) secretName(path string) (string, error) {
+	name, ok := strings.CutPrefix(path, s.targetName+"/")
+	if !ok || name == "" {
+		return "", fmt.Errorf("%s is not a secret of target %s", path, s.targetName)
+	}
+	return name, nil
+}
+
+func (s *mergeStoreSource) GetSecret(ctx context.Context, path string) ([]byte, error) {
+	name, err := s.secretName(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.store.ReadSecret(ctx, s.targetName, name)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// ListSecrets lists the target's merged secrets; the store keeps no other
+// hierarchy
+func (s *mergeStoreSource) ListSecrets(ctx context.Context, path string) ([]string, error) {
+	if strings.Trim(path, "/") != s.targetName {
+		return nil, nil
+	}
+	return s.store.ListSecrets(ctx, s.targetName)
+}
+
+var errMergeSourceReadOnly = errors.New("merge store sources are read-only")
+
+func (s *mergeStoreSource) WriteSecret(context.Context, metav1.ObjectMeta, string, []byte) ([]byte, error) {
+	return nil, errMergeSourceReadOnly
+}
+
+func (s *mergeStoreSource) DeleteSecret(context.Context, string) error {
+	return errMergeSourceReadOnly
+}
+
+func (s *mergeStoreSource) SetDefaults(any) error { return nil }
+
+func (s *mergeStoreSource) Close() error { return nil }
@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// MinVersionError is returned when loading a config whose min_vss_version is
+// newer than the running vss
+type MinVersionError struct {
+	Required string
+	Running  string
+}
+
+func (e *MinVersionError) Error() string {
+	return fmt.Sprintf("config requires vss %s or newer, this is %s; run `vss self-update`", e.Required, e.Running)
+}
+
+// checkMinVersion enforces a config's min_vss_version against the running
+// version. It runs before the rest of the config is decoded, so keys only a
+// newer vss understands fail with this error rather than as unknown keys.
+// Development builds, whose version is not a release, are not checked.
+func checkMinVersion(data []byte, running string) error {
+	var doc struct {
+		MinVSSVersion string `yaml:"min_vss_version"`
+	}
+	// Malformed YAML is reported by the full decode
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc.MinVSSVersion == "" {
+		return nil
+	}
+	required, err := version.NewVersion(doc.MinVSSVersion)
+	if err != nil {
+		return fmt.Errorf("invalid min_vss_version %q: %w", doc.MinVSSVersion, err)
+	}
+	if running == "" {
+		return nil
+	}
+	current, err := version.NewVersion(running)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"action":   "checkMinVersion",
+			"version":  running,
+			"required": doc.MinVSSVersion,
+		}).Warn("Not enforcing min_vss_version for a development build")
+		return nil
+	}
+	if current.LessThan(required) {
+		return &MinVersionError{Required: doc.MinVSSVersion, Running: running}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigMinVersion(t *testing.T) {
+	path := writeTestConfig(t, `
+min_vss_version: v1.5.0
+vault:
+  address: https://vault.example.com
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+    feature_from_v1_5: true
+`)
+
+	// The version error wins over keys this binary does not know
+	_, err := LoadConfigWithOptions(path, LoadOptions{Strict: true, Version: "v1.4.2"})
+	var tooOld *MinVersionError
+	require.True(t, errors.As(err, &tooOld))
+	assert.Equal(t, "v1.5.0", tooOld.Required)
+	assert.Equal(t, "v1.4.2", tooOld.Running)
+	assert.ErrorContains(t, err, "vss self-update")
+
+	cfg, err := LoadConfigWithOptions(path, LoadOptions{Version: "v1.5.0"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.5.0", cfg.MinVSSVersion)
+
+	// Development builds and callers without a version are not checked
+	_, err = LoadConfigWithOptions(path, LoadOptions{Version: "dev"})
+	assert.NoError(t, err)
+	_, err = LoadConfig(path)
+	assert.NoError(t, err)
+
+	invalid := writeTestConfig(t, `
+min_vss_version: latest
+vault:
+  address: https://vault.example.com
+`)
+	_, err = LoadConfigWithOptions(invalid, LoadOptions{Version: "v1.5.0"})
+	assert.ErrorContains(t, err, `invalid min_vss_version "latest"`)
+}
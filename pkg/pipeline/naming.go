@@ -0,0 +1,184 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/transforms"
+	log "github.com/sirupsen/logrus"
+)
+
+// secretNamePattern returns the name a target's AWS destinations write each
+// secret under, with $1 standing for the secret's merge store name: its
+// transforms.name template, or secret_prefix followed by the name
+func (t Target) secretNamePattern(targetName string) (string, error) {
+	if t.Transforms != nil && t.Transforms.Name != "" {
+		return transforms.NameData{Target: targetName, Prefix: t.SecretPrefix}.Pattern(t.Transforms.Name, "$1")
+	}
+	return t.SecretPrefix + "$1", nil
+}
+
+// isAWS reports whether the destination is AWS Secrets Manager
+func (d Destination) isAWS() bool {
+	return d.Doppler == nil && d.GitHub == nil && d.Kubernetes == nil && d.GRPC == nil
+}
+
+// destinationRegion returns the region a destination is synced to
+func (c *Config) destinationRegion(target Target, dest Destination) string {
+	if dest.Region != "" {
+		return dest.Region
+	}
+	if target.Region != "" {
+		return target.Region
+	}
+	return c.AWS.Region
+}
+
+// awsNaming is how one target names secrets in one AWS destination
+type awsNaming struct {
+	target      string
+	destination string
+	// location is the account and region the names live in
+	location string
+	pattern  string
+}
+
+// awsNamings lists the AWS destinations of the given targets with their
+// name patterns, in target order. Envelope targets and targets with an
+// invalid name template are left out.
+func (c *Config) awsNamings(targets []string) []awsNaming {
+	var namings []awsNaming
+	for _, name := range targets {
+		target, ok := c.Targets[name]
+		if !ok || target.Envelope != nil {
+			continue
+		}
+		pattern, err := target.secretNamePattern(name)
+		if err != nil {
+			continue
+		}
+		for _, dest := range target.ResolvedDestinations() {
+			if !dest.isAWS() {
+				continue
+			}
+			namings = append(namings, awsNaming{
+				target:      name,
+				destination: dest.Label(),
+				location:    dest.AccountID + "/" + c.destinationRegion(target, dest),
+				pattern:     pattern,
+			})
+		}
+	}
+	return namings
+}
+
+// validateSecretNames rejects targets whose secret_prefix or name template
+// gives them the same names as another target in the same account and
+// region. Targets without either keep the merge store names and are only
+// checked by the dry-run preview.
+func (c *Config) validateSecretNames() error {
+	targets := make([]string, 0, len(c.Targets))
+	for name := range c.Targets {
+		targets = append(targets, name)
+	}
+	sort.Strings(targets)
+
+	owners := make(map[string]string)
+	for _, n := range c.awsNamings(targets) {
+		if n.pattern == "$1" {
+			continue
+		}
+		key := n.location + "\x00" + n.pattern
+		if other, ok := owners[key]; ok && other != n.target {
+			return fmt.Errorf("targets %q and %q both name secrets %q in %s; give them distinct secret_prefix or transforms.name",
+				other, n.target, strings.Replace(n.pattern, "$1", "{{.Name}}", 1), n.location)
+		}
+		owners[key] = n.target
+	}
+	return nil
+}
+
+// previewNames lists the destination names the targets' merged secrets are
+// written under in AWS, for the dry-run diff: secrets whose name changes on
+// the way, and every secret that another target writes under the same name
+// in the same account and region
+func (c *Config) previewNames(ctx context.Context, store mergeStore, targets []string) ([]diff.DestinationName, error) {
+	namings := c.awsNamings(targets)
+	var err error
+	secrets := make(map[string][]string)
+	var names []diff.DestinationName
+	var keys []string
+	writers := make(map[string][]string)
+	for _, n := range namings {
+		list, ok := secrets[n.target]
+		if !ok {
+			list, err = store.ListSecrets(ctx, n.target)
+			if err != nil {
+				return nil, fmt.Errorf("target %q: failed to list merged secrets: %w", n.target, err)
+			}
+			sort.Strings(list)
+			secrets[n.target] = list
+		}
+		for _, secret := range list {
+			name := strings.Replace(n.pattern, "$1", secret, 1)
+			key := n.location + "\x00" + name
+			if !containsString(writers[key], n.target) {
+				writers[key] = append(writers[key], n.target)
+			}
+			names = append(names, diff.DestinationName{
+				Target:      n.target,
+				Destination: n.destination,
+				Secret:      secret,
+				Name:        name,
+			})
+			keys = append(keys, key)
+		}
+	}
+
+	var preview []diff.DestinationName
+	for i, dn := range names {
+		for _, other := range writers[keys[i]] {
+			if other != dn.Target {
+				dn.CollidesWith = append(dn.CollidesWith, other)
+			}
+		}
+		if len(dn.CollidesWith) > 0 {
+			log.WithFields(log.Fields{
+				"action":       "previewNames",
+				"target":       dn.Target,
+				"name":         dn.Name,
+				"collidesWith": dn.CollidesWith,
+			}).Warn("Secret name collides with another target")
+		}
+		if dn.Name != dn.Secret || len(dn.CollidesWith) > 0 {
+			preview = append(preview, dn)
+		}
+	}
+	return preview, nil
+}
+
+// recordNamePreview adds the destination name preview to the run's diff
+func (p *Pipeline) recordNamePreview(ctx context.Context, targets []string) {
+	if len(p.config.awsNamings(targets)) == 0 {
+		return
+	}
+	l := log.WithField("action", "recordNamePreview")
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		l.WithError(err).Warn("Failed to preview destination names")
+		return
+	}
+	names, err := p.config.previewNames(ctx, store, targets)
+	if err != nil {
+		l.WithError(err).Warn("Failed to preview destination names")
+		return
+	}
+	p.diffMu.Lock()
+	defer p.diffMu.Unlock()
+	if p.pipelineDiff != nil {
+		p.pipelineDiff.Names = names
+	}
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretPrefixDestinationName(t *testing.T) {
+	cfg := &Config{AWS: AWSConfig{Region: "us-east-1"}}
+	p := &Pipeline{config: cfg}
+	dest := Destination{AccountID: "111111111111"}
+
+	sync, _ := p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", Target{}, dest, false)
+	assert.Equal(t, "$1", sync.Spec.Dest[0].AWS.Name)
+
+	target := Target{SecretPrefix: "analytics/"}
+	sync, _ = p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", target, dest, false)
+	assert.Equal(t, "analytics/$1", sync.Spec.Dest[0].AWS.Name)
+
+	target.Transforms = &KeyTransforms{Name: "{{.Prefix}}{{.Target}}/{{.SecretName}}"}
+	sync, _ = p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", target, dest, false)
+	assert.Equal(t, "analytics/Analytics_Prod/$1", sync.Spec.Dest[0].AWS.Name)
+}
+
+func TestValidateSecretNames(t *testing.T) {
+	cfg := &Config{
+		AWS: AWSConfig{Region: "us-east-1"},
+		Targets: map[string]Target{
+			"Analytics": {AccountID: "111111111111", SecretPrefix: "shared/"},
+			"Payments":  {AccountID: "111111111111", SecretPrefix: "payments/"},
+			"Legacy":    {AccountID: "111111111111"},
+			"Unscoped":  {AccountID: "111111111111"},
+		},
+	}
+	require.NoError(t, cfg.validateSecretNames())
+
+	// The same prefix in another region never collides
+	cfg.Targets["Reporting"] = Target{AccountID: "111111111111", Region: "eu-west-1", SecretPrefix: "shared/"}
+	require.NoError(t, cfg.validateSecretNames())
+
+	cfg.Targets["Reporting"] = Target{AccountID: "111111111111", Transforms: &KeyTransforms{Name: "shared/{{.Name}}"}}
+	assert.ErrorContains(t, cfg.validateSecretNames(),
+		`targets "Analytics" and "Reporting" both name secrets "shared/{{.Name}}" in 111111111111/us-east-1`)
+}
+
+func TestPreviewNames(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{
+		AWS: AWSConfig{Region: "us-east-1"},
+		Targets: map[string]Target{
+			"Analytics": {AccountID: "111111111111", SecretPrefix: "analytics/"},
+			"Legacy":    {AccountID: "222222222222"},
+			"Unscoped":  {AccountID: "222222222222"},
+			"Doppler":   {Destinations: []Destination{{Doppler: &DopplerDestination{Project: "web", Config: "prd"}}}},
+		},
+	}
+	store := memMergeStore{
+		"Analytics": {"api": {"token": "a"}},
+		"Legacy":    {"db": {"password": "b"}, "legacy-only": {"key": "c"}},
+		"Unscoped":  {"db": {"password": "d"}},
+		"Doppler":   {"web": {"key": "e"}},
+	}
+
+	names, err := cfg.previewNames(ctx, store, []string{"Analytics", "Doppler", "Legacy", "Unscoped"})
+	require.NoError(t, err)
+	assert.Equal(t, []diff.DestinationName{
+		{Target: "Analytics", Destination: "aws:111111111111", Secret: "api", Name: "analytics/api"},
+		{Target: "Legacy", Destination: "aws:222222222222", Secret: "db", Name: "db", CollidesWith: []string{"Unscoped"}},
+		{Target: "Unscoped", Destination: "aws:222222222222", Secret: "db", Name: "db", CollidesWith: []string{"Legacy"}},
+	}, names)
+
+	// Without renames or collisions there is nothing to show
+	names, err = cfg.previewNames(ctx, store, []string{"Legacy"})
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMaxDependencyAge is how old a skipped dependency's last merge may be
+// before --no-deps warns that its merged output may be stale
+const DefaultMaxDependencyAge = 24 * time.Hour
+
+// SkippedDependency describes the merged output of a dependency that a
+// Options.NoDeps run did not merge
+type SkippedDependency struct {
+	Target string
+	// Secrets is the number of merged secrets found in the merge store
+	Secrets int
+	// LastMerged is the end of the dependency's last successful apply-mode
+	// merge in the run history, zero if none is recorded
+	LastMerged time.Time
+	// Stale explains why the merged output may be out of date, empty if it looks current
+	Stale string
+}
+
+// skippedDependencies returns the target dependencies of requested that
+// targets leaves out, in dependency order
+func (p *Pipeline) skippedDependencies(requested, targets []string) []string {
+	var skipped []string
+	for _, name := range p.graph.IncludeDependencies(requested) {
+		if !containsString(targets, name) {
+			skipped = append(skipped, name)
+		}
+	}
+	return skipped
+}
+
+// checkSkippedDependencies reads the merged output of each skipped dependency
+// and, with a run history, when it was last merged
+func (p *Pipeline) checkSkippedDependencies(ctx context.Context, names []string, maxAge time.Duration, now time.Time) ([]SkippedDependency, error) {
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var runs []RunRecord
+	if h := p.config.Pipeline.History; h != nil && h.Dir != "" {
+		if runs, err = LoadRunHistory(h.Dir); err != nil {
+			return nil, err
+		}
+	}
+	return assessDependencies(ctx, store, runs, names, maxAge, now)
+}
+
+// assessDependencies checks each dependency's merged output in store against
+// its last merge recorded in runs. With no run history (runs is nil) only the
+// presence of merged output can be checked.
+func assessDependencies(ctx context.Context, store mergeStore, runs []RunRecord, names []string, maxAge time.Duration, now time.Time) ([]SkippedDependency, error) {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxDependencyAge
+	}
+
+	deps := make([]SkippedDependency, 0, len(names))
+	for _, name := range names {
+		secrets, err := store.ListSecrets(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list merged secrets of %q: %w", name, err)
+		}
+		dep := SkippedDependency{Target: name, Secrets: len(secrets), LastMerged: lastMerged(runs, name)}
+		switch {
+		case dep.Secrets == 0:
+			dep.Stale = "no merged output in the merge store"
+		case runs == nil:
+		case dep.LastMerged.IsZero():
+			dep.Stale = "no successful merge in the run history"
+		case now.Sub(dep.LastMerged) > maxAge:
+			dep.Stale = fmt.Sprintf("last merged %s ago", now.Sub(dep.LastMerged).Round(time.Minute))
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// lastMerged returns when target last merged successfully outside a dry run
+func lastMerged(runs []RunRecord, target string) time.Time {
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].DryRun {
+			continue
+		}
+		for _, r := range runs[i].Results {
+			if r.Target == target && r.Phase == "merge" && r.Success {
+				if r.Started.IsZero() {
+					return runs[i].Finished
+				}
+				return r.Started.Add(r.Duration)
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// warnSkippedDependencies logs what a NoDeps run leaves out. Applying on top
+// of a dependency with no merged output is refused, since every target
+// importing it would merge without its secrets.
+func warnSkippedDependencies(deps []SkippedDependency, dryRun bool) error {
+	names := make([]string, len(deps))
+	for i, d := range deps {
+		names[i] = d.Target
+	}
+	log.WithFields(log.Fields{
+		"action":       "Pipeline.Run",
+		"dependencies": names,
+	}).Warn("--no-deps: skipping dependencies, using their current merged output")
+
+	for _, d := range deps {
+		if d.Stale == "" {
+			continue
+		}
+		l := log.WithFields(log.Fields{
+			"action":     "Pipeline.Run",
+			"dependency": d.Target,
+			"secrets":    d.Secrets,
+			"reason":     d.Stale,
+		})
+		if !d.LastMerged.IsZero() {
+			l = l.WithField("lastMerged", d.LastMerged.Format(time.RFC3339))
+		}
+		l.Warn("--no-deps: skipped dependency's merged output may be stale")
+		if d.Secrets == 0 && !dryRun {
+			return &OptionError{Option: "no-deps", Value: fmt.Sprintf("%q", d.Target), Reason: "dependency has no merged output; run it first or drop --no-deps"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveTargetsNoDeps(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{"analytics": {}},
+		Targets: map[string]Target{
+			"Stg":     {Imports: []string{"analytics"}},
+			"Prod":    {Imports: []string{"Stg"}},
+			"Prod_DR": {Imports: []string{"Prod"}},
+		},
+	}
+	g, err := BuildGraph(cfg)
+	require.NoError(t, err)
+	p := &Pipeline{config: cfg, graph: g}
+
+	targets := p.resolveTargets([]string{"Prod_DR", "Prod"}, TargetSelector{}, true)
+	assert.Equal(t, []string{"Prod", "Prod_DR"}, targets)
+	assert.Equal(t, []string{"Stg"}, p.skippedDependencies([]string{"Prod_DR", "Prod"}, targets))
+
+	targets = p.resolveTargets([]string{"Prod_DR"}, TargetSelector{}, true)
+	assert.Equal(t, []string{"Prod_DR"}, targets)
+	assert.Equal(t, []string{"Stg", "Prod"}, p.skippedDependencies([]string{"Prod_DR"}, targets))
+}
+
+func TestAssessDependencies(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	store := memMergeStore{
+		"Fresh": {"api": {"token": "x"}},
+		"Old":   {"api": {"token": "y"}},
+		"Unrun": {"api": {"token": "z"}},
+	}
+	runs := []RunRecord{
+		{Finished: now.Add(-48 * time.Hour), Results: []RunResult{
+			{Target: "Old", Phase: "merge", Success: true, Started: now.Add(-49 * time.Hour), Duration: time.Hour},
+			{Target: "Fresh", Phase: "merge", Success: true, Started: now.Add(-49 * time.Hour), Duration: time.Hour},
+		}},
+		{Finished: now.Add(-2 * time.Hour), Results: []RunResult{
+			{Target: "Fresh", Phase: "merge", Success: true, Started: now.Add(-3 * time.Hour), Duration: time.Hour},
+			{Target: "Old", Phase: "merge", Success: false, Started: now.Add(-3 * time.Hour), Duration: time.Hour},
+		}},
+		// Dry runs merge nothing
+		{DryRun: true, Finished: now.Add(-time.Hour), Results: []RunResult{
+			{Target: "Old", Phase: "merge", Success: true, Started: now.Add(-time.Hour)},
+		}},
+	}
+
+	deps, err := assessDependencies(ctx, store, runs, []string{"Fresh", "Old", "Unrun", "Empty"}, 0, now)
+	require.NoError(t, err)
+	assert.Equal(t, []SkippedDependency{
+		{Target: "Fresh", Secrets: 1, LastMerged: now.Add(-2 * time.Hour)},
+		{Target: "Old", Secrets: 1, LastMerged: now.Add(-48 * time.Hour), Stale: "last merged 48h0m0s ago"},
+		{Target: "Unrun", Secrets: 1, Stale: "no successful merge in the run history"},
+		{Target: "Empty", Stale: "no merged output in the merge store"},
+	}, deps)
+
+	// Without a run history only missing merged output is reported
+	deps, err = assessDependencies(ctx, store, nil, []string{"Old", "Empty"}, time.Hour, now)
+	require.NoError(t, err)
+	assert.Empty(t, deps[0].Stale)
+	assert.NotEmpty(t, deps[1].Stale)
+}
+
+func TestWarnSkippedDependencies(t *testing.T) {
+	deps := []SkippedDependency{
+		{Target: "Old", Secrets: 3, Stale: "last merged 48h0m0s ago"},
+		{Target: "Empty", Stale: "no merged output in the merge store"},
+	}
+
+	// Stale output only warns, as does missing output in a dry run
+	assert.NoError(t, warnSkippedDependencies(deps[:1], false))
+	assert.NoError(t, warnSkippedDependencies(deps, true))
+
+	err := warnSkippedDependencies(deps, false)
+	var optErr *OptionError
+	require.True(t, errors.As(err, &optErr))
+	assert.Equal(t, "no-deps", optErr.Option)
+	assert.Contains(t, err.Error(), `"Empty"`)
+}
@@ -0,0 +1,413 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// NotificationEvent is a point in a run at which notifications are sent
+type NotificationEvent string
+
+const (
+	NotifyStart NotificationEvent = "start"
+	// NotifyFinish is sent when a run ends, whether or not it succeeded
+	NotifyFinish NotificationEvent = "finish"
+	// NotifyFailure is sent when a run ends with an error or a failed target
+	NotifyFailure NotificationEvent = "failure"
+)
+
+var notificationEvents = []NotificationEvent{NotifyStart, NotifyFinish, NotifyFailure}
+
+// Webhook payload formats
+const (
+	NotificationFormatJSON  = "json"
+	NotificationFormatSlack = "slack"
+)
+
+// maxNotifiedFailures bounds the failures listed in a Slack message
+const maxNotifiedFailures = 10
+
+// NotificationSettings configures notifications sent when a run starts and ends.
+// Unlike the sync engine's notifications, which report each
+// VaultSecretSync, these report the run as a whole.
+//
+//	pipeline:
+//	  notifications:
+//	    webhooks:
+//	      - name: platform-slack
+//	        url: ${SLACK_WEBHOOK_URL}
+//	        format: slack
+//	        on: [failure]
+type NotificationSettings struct {
+	Webhooks []NotificationWebhook `mapstructure:"webhooks" yaml:"webhooks,omitempty"`
+}
+
+// NotificationWebhook POSTs a payload describing the run to a URL
+type NotificationWebhook struct {
+	Name string `mapstructure:"name" yaml:"name"`
+	URL  string `mapstructure:"url" yaml:"url"`
+	// On lists the events sent (default: finish). A failed run is sent once
+	// even if both finish and failure are listed.
+	On      []NotificationEvent `mapstructure:"on" yaml:"on,omitempty"`
+	Headers map[string]string   `mapstructure:"headers" yaml:"headers,omitempty"`
+	// Format is json (a NotificationPayload, the default) or slack (a
+	// {"text": ...} message for Slack incoming webhooks and compatible services)
+	Format string `mapstructure:"format" yaml:"format,omitempty"`
+	// Template is a Go template over the NotificationPayload. For json it
+	// renders the whole body; for slack it renders the message text.
+	Template string `mapstructure:"template" yaml:"template,omitempty"`
+	// Templates override Template for single events
+	Templates map[NotificationEvent]string `mapstructure:"templates" yaml:"templates,omitempty"`
+	// TemplatesDir holds <event>.tmpl files overriding Template for their
+	// events; templates set in config take precedence
+	TemplatesDir string `mapstructure:"templates_dir" yaml:"templates_dir,omitempty"`
+	// Timeout bounds each call (default 30s)
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
+}
+
+// NotificationPayload describes a run to notification webhooks and templates
+type NotificationPayload struct {
+	Event     NotificationEvent `json:"event"`
+	Operation Operation         `json:"operation"`
+	DryRun    bool              `json:"dry_run"`
+	Targets   []string          `json:"targets,omitempty"`
+	Started   time.Time         `json:"started"`
+
+	// Set when the run has ended
+	Finished  time.Time     `json:"finished,omitzero"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Success   bool          `json:"success"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Error     string        `json:"error,omitempty"`
+	Results   []Result      `json:"results,omitempty"`
+
+	// Accounts maps the run's targets to their AWS account IDs
+	Accounts map[string]string `json:"accounts,omitempty"`
+	// RunURL links to the CI run, when there is one
+	RunURL string `json:"run_url,omitempty"`
+	// Diff totals the run's changes when a diff was computed, and
+	// DiffSummary describes them in one line
+	Diff        *diff.ChangeSummary `json:"diff,omitempty"`
+	DiffSummary string              `json:"diff_summary,omitempty"`
+	// PendingApproval lists newly discovered targets that were not synced
+	// because they await `vss targets approve`
+	PendingApproval []string `json:"pending_approval,omitempty"`
+}
+
+// Failures returns the failed results
+func (np NotificationPayload) Failures() []Result {
+	var failed []Result
+	for _, r := range np.Results {
+		if !r.Success {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Account returns a target's AWS account ID, or "" for targets without one
+func (np NotificationPayload) Account(target string) string {
+	return np.Accounts[target]
+}
+
+func (w NotificationWebhook) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	for _, ev := range w.On {
+		if !containsNotificationEvent(notificationEvents, ev) {
+			return fmt.Errorf("unknown event %q", ev)
+		}
+	}
+	if w.Format != "" && w.Format != NotificationFormatJSON && w.Format != NotificationFormatSlack {
+		return fmt.Errorf("format must be json or slack, got %q", w.Format)
+	}
+	for ev := range w.Templates {
+		if !containsNotificationEvent(notificationEvents, ev) {
+			return fmt.Errorf("templates: unknown event %q", ev)
+		}
+	}
+	if w.TemplatesDir != "" {
+		if info, err := os.Stat(w.TemplatesDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("templates_dir %s is not a directory", w.TemplatesDir)
+		}
+	}
+	if _, err := parseNotificationTemplate(w.Name, w.Template); err != nil {
+		return err
+	}
+	for _, ev := range notificationEvents {
+		text, ok, err := w.eventTemplate(ev)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if _, err := parseNotificationTemplate(w.Name, text); err != nil {
+			return fmt.Errorf("%s: %w", ev, err)
+		}
+	}
+	if w.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// sends reports whether the webhook is sent for a run's event. A run's end is
+// sent once, as failure or finish.
+func (w NotificationWebhook) sends(event NotificationEvent) bool {
+	on := w.On
+	if len(on) == 0 {
+		on = []NotificationEvent{NotifyFinish}
+	}
+	if containsNotificationEvent(on, event) {
+		return true
+	}
+	return event == NotifyFailure && containsNotificationEvent(on, NotifyFinish)
+}
+
+// eventTemplate returns the webhook's template for one event, from
+// templates or else templates_dir
+func (w NotificationWebhook) eventTemplate(event NotificationEvent) (string, bool, error) {
+	if text, ok := w.Templates[event]; ok {
+		return text, true, nil
+	}
+	if w.TemplatesDir == "" {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(filepath.Join(w.TemplatesDir, string(event)+".tmpl"))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s template: %w", event, err)
+	}
+	return string(data), true, nil
+}
+
+// template parses the webhook's template for an event, or returns nil
+// without one
+func (w NotificationWebhook) template(event NotificationEvent) (*template.Template, error) {
+	text, ok, err := w.eventTemplate(event)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		text = w.Template
+	}
+	return parseNotificationTemplate(w.Name, text)
+}
+
+func parseNotificationTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New(name).Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return t, nil
+}
+
+// body renders the request body for a payload
+func (w NotificationWebhook) body(payload NotificationPayload) ([]byte, error) {
+	t, err := w.template(payload.Event)
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if t != nil {
+		if err := t.Execute(&rendered, payload); err != nil {
+			return nil, fmt.Errorf("failed to render template: %w", err)
+		}
+	}
+
+	if w.Format == NotificationFormatSlack {
+		text := rendered.String()
+		if t == nil {
+			text = slackText(payload)
+		}
+		return json.Marshal(map[string]string{"text": text})
+	}
+	if t != nil {
+		return rendered.Bytes(), nil
+	}
+	return json.Marshal(payload)
+}
+
+// slackText is the default Slack message for a payload
+func slackText(p NotificationPayload) string {
+	run := "vss " + string(p.Operation)
+	if p.DryRun {
+		run += " (dry run)"
+	}
+	if p.Event == NotifyStart {
+		return fmt.Sprintf(":arrow_forward: %s started for %d targets", run, len(p.Targets))
+	}
+
+	var b strings.Builder
+	duration := p.Duration.Round(time.Second)
+	if p.Success {
+		fmt.Fprintf(&b, ":white_check_mark: %s succeeded in %s: %d succeeded", run, duration, p.Succeeded)
+		return b.String()
+	}
+	fmt.Fprintf(&b, ":x: %s failed in %s: %d succeeded, %d failed", run, duration, p.Succeeded, p.Failed)
+	if p.Error != "" {
+		fmt.Fprintf(&b, "\n%s", p.Error)
+	}
+	failures := p.Failures()
+	for i, r := range failures {
+		if i == maxNotifiedFailures {
+			fmt.Fprintf(&b, "\n• …and %d more", len(failures)-maxNotifiedFailures)
+			break
+		}
+		fmt.Fprintf(&b, "\n• %s %s", r.Target, r.Phase)
+		if r.Error != nil {
+			fmt.Fprintf(&b, ": %s", r.Error)
+		}
+	}
+	return b.String()
+}
+
+// startNotification describes a run starting
+func startNotification(opts Options, targets []string, started time.Time) NotificationPayload {
+	return NotificationPayload{
+		Event:     NotifyStart,
+		Operation: opts.Operation,
+		DryRun:    opts.DryRun,
+		Targets:   targets,
+		Started:   started.UTC(),
+		RunURL:    ciRunURL(),
+	}
+}
+
+// targetAccounts maps the targets with an AWS account to its ID
+func (p *Pipeline) targetAccounts(targets []string) map[string]string {
+	accounts := make(map[string]string)
+	for _, name := range targets {
+		if t, ok := p.config.Targets[name]; ok && t.AccountID != "" {
+			accounts[name] = t.AccountID
+		}
+	}
+	return accounts
+}
+
+// withDiff adds the run's diff to an outcome payload
+func (np NotificationPayload) withDiff(d *diff.PipelineDiff) NotificationPayload {
+	if d == nil {
+		return np
+	}
+	summary := d.Summary
+	np.Diff = &summary
+	np.DiffSummary = diff.FormatDiff(d, diff.OutputFormatCompact)
+	return np
+}
+
+// withOutcome turns a start payload into the run's finish or failure payload
+func (np NotificationPayload) withOutcome(results []Result, err error) NotificationPayload {
+	np.Finished = time.Now().UTC()
+	np.Duration = np.Finished.Sub(np.Started)
+	np.Results = results
+	np.PendingApproval = PendingApprovalTargets(results)
+	np.Success = err == nil
+	if err != nil {
+		np.Error = err.Error()
+	}
+	for _, r := range results {
+		if r.Success {
+			np.Succeeded++
+		} else {
+			np.Failed++
+			np.Success = false
+		}
+	}
+	np.Event = NotifyFinish
+	if !np.Success {
+		np.Event = NotifyFailure
+	}
+	return np
+}
+
+// notify sends the payload to every webhook listening for its event.
+// Failures are logged and never fail the run.
+func (p *Pipeline) notify(ctx context.Context, payload NotificationPayload) {
+	n := p.config.Pipeline.Notifications
+	if n == nil {
+		return
+	}
+	for _, w := range n.Webhooks {
+		if !w.sends(payload.Event) {
+			continue
+		}
+		l := log.WithFields(log.Fields{
+			"action":  "Pipeline.notify",
+			"webhook": w.Name,
+			"event":   payload.Event,
+		})
+		if err := sendNotification(ctx, w, payload); err != nil {
+			l.WithError(err).Warn("Notification failed")
+			continue
+		}
+		l.Debug("Notification sent")
+	}
+}
+
+// sendNotification makes one webhook call with its timeout
+func sendNotification(ctx context.Context, w NotificationWebhook, payload NotificationPayload) error {
+	body, err := w.body(payload)
+	if err != nil {
+		return err
+	}
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func containsNotificationEvent(list []NotificationEvent, ev NotificationEvent) bool {
+	for _, v := range list {
+		if v == ev {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationWebhookValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook NotificationWebhook
+		errMsg  string
+	}{
+		{name: "json", webhook: NotificationWebhook{URL: "https://ci.example.com/vss"}},
+		{name: "slack", webhook: NotificationWebhook{URL: "https://hooks.slack.com/services/T0/B0/x", Format: "slack", On: []NotificationEvent{NotifyFailure}}},
+		{name: "template", webhook: NotificationWebhook{URL: "https://ci.example.com/vss", Template: `{"ok": {{.Success}}}`}},
+		{name: "bad url", webhook: NotificationWebhook{URL: "ci.example.com"}, errMsg: "must be an http or https URL"},
+		{name: "unknown event", webhook: NotificationWebhook{URL: "https://ci.example.com", On: []NotificationEvent{"done"}}, errMsg: `unknown event "done"`},
+		{name: "unknown format", webhook: NotificationWebhook{URL: "https://ci.example.com", Format: "teams"}, errMsg: "format must be json or slack"},
+		{name: "bad template", webhook: NotificationWebhook{URL: "https://ci.example.com", Template: "{{.Success"}, errMsg: "invalid template"},
+		{name: "event template", webhook: NotificationWebhook{URL: "https://ci.example.com", Templates: map[NotificationEvent]string{NotifyFailure: "{{.Error}}"}}},
+		{name: "bad event template", webhook: NotificationWebhook{URL: "https://ci.example.com", Templates: map[NotificationEvent]string{NotifyStart: "{{.Targets"}}, errMsg: "start: invalid template"},
+		{name: "template for unknown event", webhook: NotificationWebhook{URL: "https://ci.example.com", Templates: map[NotificationEvent]string{"done": "x"}}, errMsg: `templates: unknown event "done"`},
+		{name: "missing templates dir", webhook: NotificationWebhook{URL: "https://ci.example.com", TemplatesDir: "/nonexistent/vss-templates"}, errMsg: "is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.webhook.validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestNotificationWebhookSends(t *testing.T) {
+	def := NotificationWebhook{}
+	assert.False(t, def.sends(NotifyStart))
+	assert.True(t, def.sends(NotifyFinish))
+	assert.True(t, def.sends(NotifyFailure))
+
+	failures := NotificationWebhook{On: []NotificationEvent{NotifyFailure}}
+	assert.False(t, failures.sends(NotifyFinish))
+	assert.True(t, failures.sends(NotifyFailure))
+
+	start := NotificationWebhook{On: []NotificationEvent{NotifyStart}}
+	assert.True(t, start.sends(NotifyStart))
+	assert.False(t, start.sends(NotifyFailure))
+}
+
+func TestNotificationBody(t *testing.T) {
+	opts := Options{Operation: OperationPipeline}
+	started := time.Now().Add(-90 * time.Second)
+	failed := startNotification(opts, []string{"Stg", "Prod"}, started).withOutcome([]Result{
+		{Target: "Stg", Phase: "merge", Success: true},
+		{Target: "Prod", Phase: "sync", Success: false, Error: errors.New("AccessDenied")},
+	}, nil)
+	assert.Equal(t, NotifyFailure, failed.Event)
+	assert.Equal(t, 1, failed.Succeeded)
+	assert.Equal(t, 1, failed.Failed)
+
+	body, err := NotificationWebhook{Format: NotificationFormatSlack}.body(failed)
+	require.NoError(t, err)
+	var slack map[string]string
+	require.NoError(t, json.Unmarshal(body, &slack))
+	assert.Equal(t, ":x: vss pipeline failed in 1m30s: 1 succeeded, 1 failed\n• Prod sync: AccessDenied", slack["text"])
+
+	body, err = NotificationWebhook{Format: NotificationFormatSlack, Template: "{{len .Failures}} failed, first {{(index .Failures 0).Target}}"}.body(failed)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, &slack))
+	assert.Equal(t, "1 failed, first Prod", slack["text"])
+
+	body, err = NotificationWebhook{Template: `{"status": {{json .Event}}, "targets": {{json .Targets}}}`}.body(failed)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status": "failure", "targets": ["Stg", "Prod"]}`, string(body))
+
+	ok := startNotification(opts, []string{"Stg"}, started).withOutcome([]Result{{Target: "Stg", Phase: "sync", Success: true}}, nil)
+	body, err = NotificationWebhook{}.body(ok)
+	require.NoError(t, err)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "finish", payload["event"])
+	assert.Equal(t, true, payload["success"])
+	assert.Len(t, payload["results"], 1)
+}
+
+func TestNotify(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload struct {
+			Event string `json:"event"`
+			Text  string `json:"text"`
+		}
+		require.NoError(t, json.Unmarshal(body, &payload))
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], payload.Event+payload.Text)
+		mu.Unlock()
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	p := &Pipeline{config: &Config{Pipeline: PipelineSettings{Notifications: &NotificationSettings{Webhooks: []NotificationWebhook{
+		{Name: "ci", URL: srv.URL + "/ci", On: []NotificationEvent{NotifyStart, NotifyFinish, NotifyFailure}},
+		{Name: "slack", URL: srv.URL + "/slack", Format: NotificationFormatSlack, On: []NotificationEvent{NotifyFailure}},
+		{Name: "down", URL: srv.URL + "/down"},
+	}}}}}
+	opts := Options{Operation: OperationSync, DryRun: true}
+
+	start := startNotification(opts, []string{"Stg"}, time.Now())
+	p.notify(context.Background(), start)
+	p.notify(context.Background(), start.withOutcome(nil, nil))
+	p.notify(context.Background(), start.withOutcome(nil, errors.New("vault sealed")))
+
+	assert.Equal(t, []string{"start", "finish", "failure"}, received["/ci"])
+	require.Len(t, received["/slack"], 1)
+	assert.Contains(t, received["/slack"][0], ":x: vss sync (dry run) failed")
+	assert.Contains(t, received["/slack"][0], "vault sealed")
+	// A failing webhook is only logged
+	assert.Len(t, received["/down"], 2)
+}
+
+func TestNotificationEventTemplates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "failure.tmpl"),
+		[]byte(`{{range .Failures}}{{.Target}} ({{$.Account .Target}}) failed{{end}}; {{.RunURL}}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "finish.tmpl"), []byte("from dir"), 0o600))
+
+	w := NotificationWebhook{
+		Name:         "slack",
+		URL:          "https://hooks.slack.com/services/T0/B0/x",
+		Format:       NotificationFormatSlack,
+		Template:     "{{.Event}}",
+		Templates:    map[NotificationEvent]string{NotifyFinish: "{{.DiffSummary}}"},
+		TemplatesDir: dir,
+	}
+	require.NoError(t, w.validate())
+
+	started := time.Now().Add(-time.Minute)
+	text := func(payload NotificationPayload) string {
+		body, err := w.body(payload)
+		require.NoError(t, err)
+		var slack map[string]string
+		require.NoError(t, json.Unmarshal(body, &slack))
+		return slack["text"]
+	}
+
+	start := startNotification(Options{Operation: OperationSync}, []string{"Prod"}, started)
+	start.Accounts = map[string]string{"Prod": "222222222222"}
+	start.RunURL = "https://github.com/acme/secrets/actions/runs/42"
+	// Events without their own template fall back to template
+	assert.Equal(t, "start", text(start))
+
+	failed := start.withOutcome([]Result{{Target: "Prod", Phase: "sync", Error: errors.New("AccessDenied")}}, nil)
+	assert.Equal(t, "Prod (222222222222) failed; https://github.com/acme/secrets/actions/runs/42", text(failed))
+
+	// Templates in config take precedence over templates_dir
+	d := &diff.PipelineDiff{}
+	d.AddTargetDiff(diff.TargetDiff{Target: "Prod", Summary: diff.ChangeSummary{Added: 2, Total: 2}})
+	finished := start.withOutcome([]Result{{Target: "Prod", Phase: "sync", Success: true}}, nil).withDiff(d)
+	require.NotNil(t, finished.Diff)
+	assert.Equal(t, 2, finished.Diff.Added)
+	assert.Equal(t, "CHANGES: +2 -0 ~0 =0 (total: 2)", text(finished))
+}
@@ -0,0 +1,357 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/jbcom/secretsync/internal/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+// Secrets Manager's bounds on a scheduled deletion's recovery window, in days
+const (
+	minRecoveryWindowDays     = 7
+	defaultRecoveryWindowDays = 30
+)
+
+// Tags set on secrets quarantined by offboarding
+const (
+	QuarantineTag     = "vss:quarantined"
+	QuarantineFromTag = "vss:offboarded-from"
+)
+
+// OffboardPlan lists what offboarding a target removes
+type OffboardPlan struct {
+	Target string `json:"target"`
+	// MergedSecrets are the target's secrets in the merge store
+	MergedSecrets []string `json:"merged_secrets"`
+	// Destinations are the target's Secrets Manager destinations and the
+	// secrets vss wrote to them that still exist
+	Destinations []OffboardDestination `json:"destinations"`
+	// Unsupported are destinations vss cannot clean up, as
+	// "<target>/<destination>"; they must be cleaned up by hand
+	Unsupported []string `json:"unsupported,omitempty"`
+	// SyncConfigs are the target's sync configs registered with the sync engine
+	SyncConfigs []string `json:"sync_configs,omitempty"`
+}
+
+// OffboardDestination is one destination's secrets removed by offboarding
+type OffboardDestination struct {
+	Destination string   `json:"destination"`
+	Secrets     []string `json:"secrets"`
+
+	dest Destination
+}
+
+// OffboardOptions controls how a planned offboarding is carried out
+type OffboardOptions struct {
+	// Quarantine tags destination secrets and leaves them in place instead of
+	// scheduling their deletion
+	Quarantine bool
+	// RecoveryWindowDays is how long deleted secrets can be restored
+	// (7-30, default 30)
+	RecoveryWindowDays int
+	// Reason is the mandatory justification recorded with the offboarding
+	Reason string
+	// Operator is who offboarded the target (GITHUB_ACTOR or the local user)
+	Operator string
+}
+
+// OffboardRecord is the audit record of an offboarding, sent to offboard
+// hooks before anything is removed and written to the audit log once done
+type OffboardRecord struct {
+	Target string `json:"target"`
+	// Action is delete or quarantine
+	Action             string                `json:"action"`
+	RecoveryWindowDays int                   `json:"recovery_window_days,omitempty"`
+	Reason             string                `json:"reason"`
+	Operator           string                `json:"operator"`
+	CallerARN          string                `json:"caller_arn,omitempty"`
+	Time               time.Time             `json:"time"`
+	Destinations       []OffboardDestination `json:"destinations"`
+	MergedSecrets      int                   `json:"merged_secrets"`
+	SyncConfigs        []string              `json:"sync_configs,omitempty"`
+	Unsupported        []string              `json:"unsupported,omitempty"`
+	// Errors are the removals that failed; the merge store is left as it was
+	// if any destination secret could not be removed, so the offboarding can
+	// be planned and run again
+	Errors []string `json:"errors,omitempty"`
+}
+
+// destinationCleaner removes secrets from Secrets Manager destinations
+type destinationCleaner interface {
+	// DeleteSecret schedules a secret's deletion after a recovery window
+	DeleteSecret(ctx context.Context, target Target, d Destination, name string, recoveryDays int) error
+	TagSecret(ctx context.Context, target Target, d Destination, name string, tags map[string]string) error
+}
+
+func (o *OffboardOptions) validate() error {
+	if strings.TrimSpace(o.Reason) == "" {
+		return &OptionError{Option: "reason", Value: `""`, Reason: "a justification is required"}
+	}
+	if o.RecoveryWindowDays == 0 {
+		o.RecoveryWindowDays = defaultRecoveryWindowDays
+	}
+	if o.RecoveryWindowDays < minRecoveryWindowDays || o.RecoveryWindowDays > defaultRecoveryWindowDays {
+		return &OptionError{Option: "recovery window", Value: o.RecoveryWindowDays, Reason: "must be between 7 and 30 days"}
+	}
+	return nil
+}
+
+// PlanOffboard lists what offboarding a target would remove: its merged
+// secrets, the secrets vss wrote to its Secrets Manager destinations and its
+// registered sync configs. A target other targets import cannot be offboarded.
+func (p *Pipeline) PlanOffboard(ctx context.Context, name string) (*OffboardPlan, error) {
+	if _, ok := p.config.Targets[name]; !ok {
+		return nil, &OptionError{Option: "target", Value: fmt.Sprintf("%q", name), Reason: "not found in configuration"}
+	}
+	if importers := p.config.importersOf(name); len(importers) > 0 {
+		return nil, fmt.Errorf("%s is imported by %s; remove those imports first", name, strings.Join(importers, ", "))
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.inventoryLister == nil {
+		p.inventoryLister = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	return p.planOffboard(ctx, store, p.inventoryLister, name)
+}
+
+func (p *Pipeline) planOffboard(ctx context.Context, store mergeStore, lister secretLister, name string) (*OffboardPlan, error) {
+	target := p.config.Targets[name]
+	plan := &OffboardPlan{Target: name, Destinations: []OffboardDestination{}}
+
+	merged, err := store.ListSecrets(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merged secrets: %w", err)
+	}
+	plan.MergedSecrets = merged
+
+	sourcePath, err := p.syncSourcePath(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range target.ResolvedDestinations() {
+		label := d.Label()
+		// Envelope targets write ciphertext under their own names
+		if !d.requiresAccountID() || d.Kubernetes != nil || target.Envelope != nil {
+			plan.Unsupported = append(plan.Unsupported, name+"/"+label)
+			continue
+		}
+		existing, err := lister.SecretsManagerSecrets(ctx, target, d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets in %s: %w", label, err)
+		}
+
+		// Secrets under a target's own name prefix are all its; anything else
+		// in the account may belong to someone else, so only merged names count
+		sync, _ := p.destinationSync(name, sourcePath, target, d, true)
+		pattern := sync.Spec.Dest[0].AWS.Name
+		prefix, _, _ := strings.Cut(pattern, "$1")
+		secrets := []string{}
+		for _, secret := range merged {
+			destName := strings.ReplaceAll(pattern, "$1", secret)
+			if _, ok := existing[destName]; ok && !slices.Contains(secrets, destName) {
+				secrets = append(secrets, destName)
+			}
+		}
+		if prefix != "" && strings.Contains(pattern, "$1") {
+			for destName := range existing {
+				if strings.HasPrefix(destName, prefix) && !slices.Contains(secrets, destName) {
+					secrets = append(secrets, destName)
+				}
+			}
+		}
+		slices.Sort(secrets)
+		plan.Destinations = append(plan.Destinations, OffboardDestination{Destination: label, Secrets: secrets, dest: d})
+	}
+
+	for _, internal := range syncConfigNames(name, target) {
+		if _, ok := backend.SyncConfigs[internal]; ok {
+			plan.SyncConfigs = append(plan.SyncConfigs, internal)
+		}
+	}
+	return plan, nil
+}
+
+// syncConfigNames returns the internal names of the sync configs the
+// pipeline registers for a target's merges and syncs
+func syncConfigNames(name string, target Target) []string {
+	var names []string
+	for _, imp := range target.Imports {
+		if ref, err := ParseImportRef(imp); err == nil {
+			names = append(names, backend.InternalName("pipeline", fmt.Sprintf("merge-%s-to-%s", ref.Name, name)))
+		}
+	}
+	dests := target.ResolvedDestinations()
+	for i := range dests {
+		syncName := "sync-" + name
+		if len(dests) > 1 {
+			syncName = fmt.Sprintf("sync-%s-%d", name, i)
+		}
+		names = append(names, backend.InternalName("pipeline", syncName))
+	}
+	return names
+}
+
+// Offboard carries out a plan: destination secrets are scheduled for
+// deletion (or quarantined), then the target's merged secrets and sync configs
+// are removed. Offboard hooks are called first; a failing required hook
+// refuses the offboarding. The target must then be removed from the config.
+func (p *Pipeline) Offboard(ctx context.Context, plan *OffboardPlan, opts OffboardOptions) (*OffboardRecord, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.offboarder == nil {
+		p.offboarder = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	return p.offboard(ctx, store, p.offboarder, plan, opts)
+}
+
+func (p *Pipeline) offboard(ctx context.Context, store mergeStore, cleaner destinationCleaner, plan *OffboardPlan, opts OffboardOptions) (*OffboardRecord, error) {
+	target := p.config.Targets[plan.Target]
+	rec := &OffboardRecord{
+		Target:        plan.Target,
+		Action:        "delete",
+		Reason:        opts.Reason,
+		Operator:      opts.Operator,
+		Time:          time.Now().UTC(),
+		Destinations:  plan.Destinations,
+		MergedSecrets: len(plan.MergedSecrets),
+		SyncConfigs:   plan.SyncConfigs,
+		Unsupported:   plan.Unsupported,
+	}
+	if opts.Quarantine {
+		rec.Action = "quarantine"
+	} else {
+		rec.RecoveryWindowDays = opts.RecoveryWindowDays
+	}
+	if p.awsCtx != nil && p.awsCtx.CallerIdentity != nil {
+		rec.CallerARN = p.awsCtx.CallerIdentity.ARN
+	}
+
+	l := log.WithFields(log.Fields{
+		"action":   "Pipeline.Offboard",
+		"audit":    true,
+		"target":   rec.Target,
+		"mode":     rec.Action,
+		"reason":   rec.Reason,
+		"operator": rec.Operator,
+	})
+	l.Warn("Offboarding requested")
+
+	if err := p.runHooks(ctx, HookPayload{Event: HookOffboard, Target: plan.Target, Offboard: rec}); err != nil {
+		l.WithError(err).Error("Offboarding refused")
+		return nil, err
+	}
+
+	tags := map[string]string{QuarantineTag: rec.Time.Format(time.RFC3339), QuarantineFromTag: plan.Target}
+	for _, od := range plan.Destinations {
+		for _, secret := range od.Secrets {
+			var err error
+			if opts.Quarantine {
+				err = cleaner.TagSecret(ctx, target, od.dest, secret, tags)
+			} else {
+				err = cleaner.DeleteSecret(ctx, target, od.dest, secret, opts.RecoveryWindowDays)
+			}
+			if err != nil {
+				l.WithError(err).WithFields(log.Fields{"destination": od.Destination, "secret": secret}).Error("Failed to remove destination secret")
+				rec.Errors = append(rec.Errors, fmt.Sprintf("%s %s: %s", od.Destination, secret, err))
+			}
+		}
+	}
+
+	// Keep the merged secrets while destinations still hold some, so the
+	// offboarding can be planned again
+	if len(rec.Errors) == 0 {
+		for _, secret := range plan.MergedSecrets {
+			if err := store.DeleteSecret(ctx, plan.Target, secret); err != nil {
+				l.WithError(err).WithField("secret", secret).Error("Failed to delete merged secret")
+				rec.Errors = append(rec.Errors, fmt.Sprintf("merge store/%s: %s", secret, err))
+			}
+		}
+		if err := forgetSyncs(ctx, store, plan.Target); err != nil {
+			l.WithError(err).Error("Failed to delete sync hashes")
+			rec.Errors = append(rec.Errors, fmt.Sprintf("merge store/%s: %s", syncHashesNamespace, err))
+		}
+	}
+	for _, name := range plan.SyncConfigs {
+		if err := backend.RemoveSyncConfig(name); err != nil {
+			rec.Errors = append(rec.Errors, fmt.Sprintf("sync config %s: %s", name, err))
+		}
+	}
+
+	l = l.WithFields(log.Fields{
+		"destinations":  len(rec.Destinations),
+		"mergedSecrets": rec.MergedSecrets,
+		"syncConfigs":   rec.SyncConfigs,
+		"errors":        len(rec.Errors),
+	})
+	if len(rec.Errors) > 0 {
+		l.Error("Offboarding incomplete")
+		return rec, fmt.Errorf("offboarding %s is incomplete: %d removals failed", plan.Target, len(rec.Errors))
+	}
+	l.Warn("Offboarding completed")
+	return rec, nil
+}
+
+// importersOf returns the targets that import name, sorted
+func (c *Config) importersOf(name string) []string {
+	var importers []string
+	for targetName, t := range c.Targets {
+		for _, imp := range t.Imports {
+			if ref, err := ParseImportRef(imp); err == nil && ref.Name == name {
+				importers = append(importers, targetName)
+				break
+			}
+		}
+	}
+	slices.Sort(importers)
+	return importers
+}
+
+func (r *liveReadinessProbe) DeleteSecret(ctx context.Context, target Target, d Destination, name string, recoveryDays int) error {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return err
+	}
+	_, err = secretsmanager.NewFromConfig(cfg).DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{
+		SecretId:             aws.String(name),
+		RecoveryWindowInDays: aws.Int64(int64(recoveryDays)),
+	})
+	var notFound *smtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+	return nil
+}
+
+func (r *liveReadinessProbe) TagSecret(ctx context.Context, target Target, d Destination, name string, tags map[string]string) error {
+	cfg, err := r.awsConfig(ctx, destinationTarget(target, d))
+	if err != nil {
+		return err
+	}
+	input := &secretsmanager.TagResourceInput{SecretId: aws.String(name)}
+	for k, v := range tags {
+		input.Tags = append(input.Tags, smtypes.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	if _, err := secretsmanager.NewFromConfig(cfg).TagResource(ctx, input); err != nil {
+		return fmt.Errorf("failed to tag secret: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDestinationCleaner records removals and fails those of secrets in fail
+type fakeDestinationCleaner struct {
+	deleted      []string
+	tagged       map[string]map[string]string
+	fail         map[string]bool
+	recoveryDays int
+}
+
+func (f *fakeDestinationCleaner) DeleteSecret(_ context.Context, _ Target, d Destination, name string, recoveryDays int) error {
+	if f.fail[name] {
+		return errors.New("AccessDenied")
+	}
+	f.deleted = append(f.deleted, d.AccountID+":"+name)
+	f.recoveryDays = recoveryDays
+	return nil
+}
+
+func (f *fakeDestinationCleaner) TagSecret(_ context.Context, _ Target, d Destination, name string, tags map[string]string) error {
+	if f.tagged == nil {
+		f.tagged = make(map[string]map[string]string)
+	}
+	f.tagged[d.AccountID+":"+name] = tags
+	return nil
+}
+
+func offboardTestPipeline() *Pipeline {
+	return &Pipeline{config: &Config{
+		Vault:      VaultConfig{Address: "https://vault.example.com"},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Sources:    map[string]Source{"analytics": {Vault: &VaultSource{Mount: "analytics"}}},
+		Targets: map[string]Target{
+			"Stg": {
+				AccountID:  "111111111111",
+				Imports:    []string{"analytics"},
+				Transforms: &KeyTransforms{Name: "/{{.Target}}/app/{{.Name}}"},
+			},
+			"Prod":     {AccountID: "222222222222", Imports: []string{"Stg"}},
+			"Repo":     {GitHub: &GitHubDestination{Owner: "org", Repo: "app"}},
+			"Unlisted": {AccountID: "333333333333"},
+		},
+	}}
+}
+
+func TestPlanOffboard(t *testing.T) {
+	p := offboardTestPipeline()
+	store := memMergeStore{
+		"Stg":  {"db": {"password": "x"}, "api": {"token": "y"}},
+		"Repo": {"db": {"password": "x"}},
+	}
+	lister := fakeSecretLister{
+		"111111111111": {
+			"/Stg/app/db": time.Time{},
+			// No longer merged, but under the target's prefix
+			"/Stg/app/legacy": time.Time{},
+			"unrelated":       time.Time{},
+		},
+	}
+	backend.SyncConfigs["pipeline/sync-Stg"] = v1alpha1.VaultSecretSync{}
+	defer delete(backend.SyncConfigs, "pipeline/sync-Stg")
+
+	plan, err := p.planOffboard(context.Background(), store, lister, "Stg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "db"}, plan.MergedSecrets)
+	require.Len(t, plan.Destinations, 1)
+	assert.Equal(t, []string{"/Stg/app/db", "/Stg/app/legacy"}, plan.Destinations[0].Secrets)
+	assert.Equal(t, []string{"pipeline/sync-Stg"}, plan.SyncConfigs)
+
+	plan, err = p.planOffboard(context.Background(), store, lister, "Repo")
+	require.NoError(t, err)
+	assert.Empty(t, plan.Destinations)
+	assert.Equal(t, []string{"Repo/github:org/app"}, plan.Unsupported)
+
+	_, err = p.planOffboard(context.Background(), store, lister, "Unlisted")
+	assert.ErrorContains(t, err, "AccessDenied")
+
+	// Targets that others import cannot be offboarded
+	_, err = p.PlanOffboard(context.Background(), "Stg")
+	assert.ErrorContains(t, err, "Stg is imported by Prod")
+	var optErr *OptionError
+	_, err = p.PlanOffboard(context.Background(), "Missing")
+	assert.ErrorAs(t, err, &optErr)
+}
+
+func TestOffboard(t *testing.T) {
+	p := offboardTestPipeline()
+	newStore := func() memMergeStore {
+		return memMergeStore{"Stg": {"db": {"password": "x"}, "api": {"token": "y"}}}
+	}
+	lister := fakeSecretLister{"111111111111": {"/Stg/app/db": time.Time{}, "/Stg/app/api": time.Time{}}}
+	opts := OffboardOptions{Reason: "account closed", Operator: "alice"}
+	require.NoError(t, opts.validate())
+	assert.Equal(t, 30, opts.RecoveryWindowDays)
+
+	store := newStore()
+	plan, err := p.planOffboard(context.Background(), store, lister, "Stg")
+	require.NoError(t, err)
+	cleaner := &fakeDestinationCleaner{}
+	rec, err := p.offboard(context.Background(), store, cleaner, plan, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"111111111111:/Stg/app/api", "111111111111:/Stg/app/db"}, cleaner.deleted)
+	assert.Equal(t, 30, cleaner.recoveryDays)
+	assert.Empty(t, store["Stg"])
+	assert.Equal(t, "delete", rec.Action)
+	assert.Equal(t, "alice", rec.Operator)
+	assert.Equal(t, 2, rec.MergedSecrets)
+
+	// Quarantined secrets are tagged and left in place
+	store = newStore()
+	cleaner = &fakeDestinationCleaner{}
+	rec, err = p.offboard(context.Background(), store, cleaner, plan, OffboardOptions{Reason: "account closed", Quarantine: true})
+	require.NoError(t, err)
+	assert.Empty(t, cleaner.deleted)
+	assert.Equal(t, "Stg", cleaner.tagged["111111111111:/Stg/app/db"][QuarantineFromTag])
+	assert.Equal(t, "quarantine", rec.Action)
+	assert.Zero(t, rec.RecoveryWindowDays)
+
+	// A failed removal keeps the merged secrets so the offboarding can be retried
+	store = newStore()
+	cleaner = &fakeDestinationCleaner{fail: map[string]bool{"/Stg/app/db": true}}
+	rec, err = p.offboard(context.Background(), store, cleaner, plan, opts)
+	assert.ErrorContains(t, err, "incomplete")
+	assert.Equal(t, []string{"aws:111111111111 /Stg/app/db: AccessDenied"}, rec.Errors)
+	assert.Len(t, store["Stg"], 2)
+}
+
+func TestOffboardOptionsValidate(t *testing.T) {
+	var optErr *OptionError
+	err := (&OffboardOptions{}).validate()
+	require.ErrorAs(t, err, &optErr)
+	assert.Equal(t, "reason", optErr.Option)
+
+	err = (&OffboardOptions{Reason: "closed", RecoveryWindowDays: 3}).validate()
+	require.ErrorAs(t, err, &optErr)
+	assert.Equal(t, "recovery window", optErr.Option)
+}
@@ -0,0 +1,422 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretsManagerSecretQuota is Secrets Manager's default limit on secrets per
+// account and region
+const SecretsManagerSecretQuota = 500000
+
+// quotaWarnFraction is how full the secrets quota may get before onboarding warns
+const quotaWarnFraction = 0.9
+
+// OnboardOptions describes an account to add as a target
+type OnboardOptions struct {
+	AccountID string
+	// Name is the new target's name (default: Account_<account ID>)
+	Name string
+	// Imports are the sources and targets the new target imports
+	Imports []string
+	// Region overrides aws.region for the new target
+	Region string
+}
+
+// CheckStatus is the outcome of an onboarding check
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+	// CheckSkip means the check does not apply or could not be run
+	CheckSkip CheckStatus = "skip"
+)
+
+// OnboardCheck is one item of an onboarding checklist
+type OnboardCheck struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
+	// Fix says what to do when the check did not pass
+	Fix string `json:"fix,omitempty"`
+}
+
+// OnboardReport is the checklist for adding an account as a target, and the
+// config that adds it
+type OnboardReport struct {
+	AccountID string         `json:"account_id"`
+	Target    string         `json:"target"`
+	Checks    []OnboardCheck `json:"checks"`
+	// Config is the target's entry, indented to go under targets:
+	Config string `json:"config"`
+}
+
+// Ready reports whether no check failed
+func (r *OnboardReport) Ready() bool {
+	for _, c := range r.Checks {
+		if c.Status == CheckFail {
+			return false
+		}
+	}
+	return true
+}
+
+// onboardProbe checks a new account and the Vault policies it would need
+type onboardProbe interface {
+	// AccountIdentity returns the ARN vss acts as in the target's account
+	// and that account's ID
+	AccountIdentity(ctx context.Context, target Target) (arn, accountID string, err error)
+	// SecretCount counts the secrets in the target's Secrets Manager
+	SecretCount(ctx context.Context, target Target) (int, error)
+	// VaultCapabilities returns the Vault token's capabilities on a path
+	VaultCapabilities(ctx context.Context, path string) ([]string, error)
+}
+
+func (o OnboardOptions) validate(c *Config) error {
+	if len(o.AccountID) != 12 || strings.Trim(o.AccountID, "0123456789") != "" {
+		return &OptionError{Option: "account", Value: fmt.Sprintf("%q", o.AccountID), Reason: "must be a 12-digit AWS account ID"}
+	}
+	if _, ok := c.Targets[o.Name]; ok {
+		return &OptionError{Option: "name", Value: fmt.Sprintf("%q", o.Name), Reason: "is already a target"}
+	}
+	for _, imp := range o.Imports {
+		ref, err := ParseImportRef(imp)
+		if err != nil {
+			return &OptionError{Option: "import", Value: fmt.Sprintf("%q", imp), Reason: err.Error()}
+		}
+		_, isSource := c.Sources[ref.Name]
+		_, isTarget := c.Targets[ref.Name]
+		if !isSource && !isTarget {
+			return &OptionError{Option: "import", Value: fmt.Sprintf("%q", imp), Reason: "is not a source or target in the config"}
+		}
+	}
+	return nil
+}
+
+// Onboard checks that an account is ready to be added as a target: that vss
+// can assume its role, that Secrets Manager has room for the secrets it would
+// receive and that the Vault token can read its imports and write its merged
+// secrets. Checks that cannot run are reported, not returned as errors.
+func (p *Pipeline) Onboard(ctx context.Context, opts OnboardOptions) (*OnboardReport, error) {
+	if opts.Name == "" {
+		opts.Name = "Account_" + opts.AccountID
+	}
+	if err := opts.validate(p.config); err != nil {
+		return nil, err
+	}
+	if p.onboardProbe == nil {
+		p.onboardProbe = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	// Without a merge store the quota check cannot count incoming secrets,
+	// which it reports
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		log.WithFields(log.Fields{"action": "onboard"}).WithError(err).Warn("merge store unavailable")
+		store = nil
+	}
+	return p.onboard(ctx, p.onboardProbe, store, opts), nil
+}
+
+func (p *Pipeline) onboard(ctx context.Context, probe onboardProbe, store mergeStore, opts OnboardOptions) *OnboardReport {
+	target := Target{AccountID: opts.AccountID, Imports: opts.Imports, Region: opts.Region}
+	report := &OnboardReport{
+		AccountID: opts.AccountID,
+		Target:    opts.Name,
+		Config:    targetSnippet(opts.Name, target),
+	}
+	add := func(c OnboardCheck) { report.Checks = append(report.Checks, c) }
+
+	// Not yet synced
+	var existing []string
+	for name, t := range p.config.Targets {
+		if t.AccountID == opts.AccountID {
+			existing = append(existing, name)
+		}
+	}
+	slices.Sort(existing)
+	if len(existing) > 0 {
+		add(OnboardCheck{Name: "not yet a target", Status: CheckWarn,
+			Detail: fmt.Sprintf("already synced by %s", strings.Join(existing, ", ")),
+			Fix:    "add imports to the existing target instead, unless a second target is intended"})
+	} else {
+		add(OnboardCheck{Name: "not yet a target", Status: CheckPass})
+	}
+
+	if len(opts.Imports) == 0 {
+		add(OnboardCheck{Name: "imports", Status: CheckWarn, Detail: "the target imports nothing", Fix: "pass --import for each source or target to inherit from"})
+	} else {
+		add(OnboardCheck{Name: "imports", Status: CheckPass, Detail: strings.Join(opts.Imports, ", ")})
+	}
+
+	// Execution role
+	roleARN := p.config.GetRoleARN(opts.AccountID)
+	arn, account, err := probe.AccountIdentity(ctx, target)
+	switch {
+	case err != nil:
+		add(OnboardCheck{Name: "execution role", Status: CheckFail, Detail: err.Error(),
+			Fix: fmt.Sprintf("create %s and let the pipeline's identity assume it", roleARN)})
+	case account != opts.AccountID:
+		add(OnboardCheck{Name: "execution role", Status: CheckFail,
+			Detail: fmt.Sprintf("credentials resolve to account %s (%s)", account, arn),
+			Fix:    fmt.Sprintf("check that %s is in account %s", roleARN, opts.AccountID)})
+	default:
+		add(OnboardCheck{Name: "execution role", Status: CheckPass, Detail: arn})
+	}
+
+	// Secrets Manager quota
+	if err != nil {
+		add(OnboardCheck{Name: "secrets quota", Status: CheckSkip, Detail: "the account could not be reached"})
+	} else {
+		add(p.quotaCheck(ctx, probe, store, target, opts.Imports))
+	}
+
+	// Vault policy coverage
+	for _, c := range p.vaultPolicyChecks(ctx, probe, opts) {
+		add(c)
+	}
+	return report
+}
+
+// quotaCheck compares the account's secrets, plus those it would receive from
+// imported targets, with the Secrets Manager quota
+func (p *Pipeline) quotaCheck(ctx context.Context, probe onboardProbe, store mergeStore, target Target, imports []string) OnboardCheck {
+	check := OnboardCheck{Name: "secrets quota"}
+	count, err := probe.SecretCount(ctx, target)
+	if err != nil {
+		check.Status, check.Detail = CheckFail, err.Error()
+		check.Fix = "grant the execution role secretsmanager:ListSecrets"
+		return check
+	}
+
+	// Secrets from sources are only known once merged, so only imported
+	// targets' merged secrets are counted
+	incoming, uncounted := 0, []string{}
+	for _, imp := range imports {
+		ref, _ := ParseImportRef(imp)
+		if _, ok := p.config.Targets[ref.Name]; !ok || store == nil {
+			uncounted = append(uncounted, ref.Name)
+			continue
+		}
+		names, err := store.ListSecrets(ctx, ref.Name)
+		if err != nil {
+			uncounted = append(uncounted, ref.Name)
+			continue
+		}
+		incoming += len(names)
+	}
+
+	total := count + incoming
+	check.Detail = fmt.Sprintf("%d existing + %d incoming of %d", count, incoming, SecretsManagerSecretQuota)
+	if len(uncounted) > 0 {
+		check.Detail += fmt.Sprintf(" (not counted: %s)", strings.Join(uncounted, ", "))
+	}
+	switch {
+	case total >= SecretsManagerSecretQuota:
+		check.Status = CheckFail
+		check.Fix = "request a Secrets Manager quota increase or remove unused secrets"
+	case float64(total) >= quotaWarnFraction*SecretsManagerSecretQuota:
+		check.Status = CheckWarn
+		check.Fix = "request a Secrets Manager quota increase before the account fills up"
+	default:
+		check.Status = CheckPass
+	}
+	return check
+}
+
+// vaultPolicyChecks checks that the Vault token can list and read each
+// imported Vault source and, with a Vault merge store, write the new target's
+// merged secrets
+func (p *Pipeline) vaultPolicyChecks(ctx context.Context, probe onboardProbe, opts OnboardOptions) []OnboardCheck {
+	var needs []vaultNeed
+	for _, imp := range opts.Imports {
+		ref, _ := ParseImportRef(imp)
+		if src, ok := p.config.Sources[ref.Name]; ok && src.Vault != nil {
+			needs = append(needs, vaultSourceNeeds(ref.Name, src.Vault)...)
+		}
+	}
+	if mv := p.config.MergeStore.Vault; mv != nil {
+		needs = append(needs, vaultMergeNeed(mv, opts.Name))
+	}
+	if len(needs) == 0 {
+		return []OnboardCheck{{Name: "vault policy", Status: CheckSkip, Detail: "no Vault sources imported and no Vault merge store"}}
+	}
+
+	var checks []OnboardCheck
+	for _, n := range needs {
+		checks = append(checks, checkVaultNeed(ctx, probe.VaultCapabilities, n))
+	}
+	return checks
+}
+
+// vaultNeed is capabilities the Vault token needs under a path
+type vaultNeed struct {
+	path string
+	caps []string
+	what string
+}
+
+// vaultSourceNeeds are what the Vault token needs to list and read a source
+func vaultSourceNeeds(name string, src *VaultSource) []vaultNeed {
+	return []vaultNeed{
+		{src.Mount + "/metadata/", []string{"list"}, "list source " + name},
+		{src.Mount + "/data/", []string{"read"}, "read source " + name},
+	}
+}
+
+// vaultMergeNeed is what the Vault token needs to write a target's merged
+// secrets to a Vault merge store
+func vaultMergeNeed(mv *MergeStoreVault, targetName string) vaultNeed {
+	return vaultNeed{fmt.Sprintf("%s/data/%s/", mv.Mount, targetName), []string{"create", "update"}, "write merged secrets"}
+}
+
+// checkVaultNeed looks up the Vault token's capabilities on a path and checks
+// they include those needed
+func checkVaultNeed(ctx context.Context, lookup func(ctx context.Context, path string) ([]string, error), n vaultNeed) OnboardCheck {
+	check := OnboardCheck{Name: "vault policy: " + n.what}
+	caps, err := lookup(ctx, n.path)
+	if err != nil {
+		check.Status, check.Detail = CheckSkip, err.Error()
+		return check
+	}
+	var missing []string
+	for _, c := range n.caps {
+		if !slices.Contains(caps, c) && !slices.Contains(caps, "root") {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("missing %s on %s*", strings.Join(missing, ", "), n.path)
+		check.Fix = fmt.Sprintf(`add path "%s*" { capabilities = ["%s"] } to the pipeline's Vault policy`, n.path, strings.Join(n.caps, `", "`))
+	} else {
+		check.Status, check.Detail = CheckPass, n.path+"*"
+	}
+	return check
+}
+
+// targetSnippet renders a target's config entry, indented to go under targets:
+func targetSnippet(name string, t Target) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "  %s:\n", name)
+	fmt.Fprintf(&b, "    account_id: %q\n", t.AccountID)
+	if t.Region != "" {
+		fmt.Fprintf(&b, "    region: %s\n", t.Region)
+	}
+	if len(t.Imports) > 0 {
+		b.WriteString("    imports:\n")
+		for _, imp := range t.Imports {
+			fmt.Fprintf(&b, "      - %s\n", imp)
+		}
+	}
+	return b.String()
+}
+
+// AddTargetToConfig inserts a target entry rendered by Onboard at the end of
+// the targets section of a config file, leaving the rest of the file as it
+// was, and checks that the result parses with the new target in it
+func AddTargetToConfig(data []byte, name, snippet string) ([]byte, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	start := -1
+	for i, line := range lines {
+		if trimmed := strings.TrimRight(line, "\r\n"); trimmed == "targets:" || strings.HasPrefix(trimmed, "targets: #") {
+			start = i
+			break
+		}
+	}
+
+	var out string
+	if start < 0 {
+		out = string(data)
+		if out != "" && !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		out += "targets:\n" + snippet
+	} else {
+		// The section ends at the next top-level key; trailing blank lines and
+		// comments stay after the new entry
+		end := len(lines)
+		for i := start + 1; i < len(lines); i++ {
+			l := lines[i]
+			if l != "" && l[0] != ' ' && l[0] != '\t' && l[0] != '\n' && l[0] != '\r' && l[0] != '#' {
+				end = i
+				break
+			}
+		}
+		for end > start+1 {
+			l := strings.TrimSpace(lines[end-1])
+			if l != "" && !strings.HasPrefix(l, "#") {
+				break
+			}
+			end--
+		}
+		if end > 0 && !strings.HasSuffix(lines[end-1], "\n") {
+			lines[end-1] += "\n"
+		}
+		out = strings.Join(lines[:end], "") + snippet + strings.Join(lines[end:], "")
+	}
+
+	var parsed struct {
+		Targets map[string]interface{} `yaml:"targets"`
+	}
+	if err := yaml.NewDecoder(bytes.NewReader([]byte(out))).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to add target: config no longer parses: %w", err)
+	}
+	if _, ok := parsed.Targets[name]; !ok {
+		return nil, fmt.Errorf("failed to add target: %s is not under targets in the result", name)
+	}
+	return []byte(out), nil
+}
+
+func (r *liveReadinessProbe) AccountIdentity(ctx context.Context, target Target) (string, string, error) {
+	cfg, err := r.awsConfig(ctx, target)
+	if err != nil {
+		return "", "", err
+	}
+	out, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to assume role: %w", err)
+	}
+	return aws.ToString(out.Arn), aws.ToString(out.Account), nil
+}
+
+func (r *liveReadinessProbe) SecretCount(ctx context.Context, target Target) (int, error) {
+	cfg, err := r.awsConfig(ctx, target)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	paginator := secretsmanager.NewListSecretsPaginator(secretsmanager.NewFromConfig(cfg), &secretsmanager.ListSecretsInput{
+		MaxResults: aws.Int32(100),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		count += len(page.SecretList)
+	}
+	return count, nil
+}
+
+func (r *liveReadinessProbe) VaultCapabilities(ctx context.Context, path string) ([]string, error) {
+	vc, err := r.vaultClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	caps, err := vc.Client.Sys().CapabilitiesSelfWithContext(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up capabilities on %s: %w", path, err)
+	}
+	return caps, nil
+}
@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type fakeOnboardProbe struct {
+	account string
+	err     error
+	secrets int
+	// caps are the Vault token's capabilities by path
+	caps map[string][]string
+}
+
+func (f *fakeOnboardProbe) AccountIdentity(_ context.Context, target Target) (string, string, error) {
+	if f.err != nil {
+		return "", "", f.err
+	}
+	return "arn:aws:sts::" + f.account + ":assumed-role/AWSControlTowerExecution/vault-secret-sync", f.account, nil
+}
+
+func (f *fakeOnboardProbe) SecretCount(context.Context, Target) (int, error) {
+	return f.secrets, nil
+}
+
+func (f *fakeOnboardProbe) VaultCapabilities(_ context.Context, path string) ([]string, error) {
+	if caps, ok := f.caps[path]; ok {
+		return caps, nil
+	}
+	return []string{"deny"}, nil
+}
+
+func onboardChecks(r *OnboardReport) map[string]CheckStatus {
+	checks := make(map[string]CheckStatus)
+	for _, c := range r.Checks {
+		checks[c.Name] = c.Status
+	}
+	return checks
+}
+
+func TestOnboard(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Serverless_Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+		},
+	}}
+	store := memMergeStore{
+		"Serverless_Stg": {"db": {"password": "x"}, "api": {"token": "y"}},
+	}
+	opts := OnboardOptions{AccountID: "333333333333", Name: "Sandbox", Imports: []string{"Serverless_Stg", "analytics"}}
+	probe := &fakeOnboardProbe{
+		account: "333333333333",
+		secrets: 10,
+		caps: map[string][]string{
+			"analytics/metadata/":  {"list"},
+			"analytics/data/":      {"read", "list"},
+			"merged/data/Sandbox/": {"create", "read"},
+		},
+	}
+
+	report := p.onboard(context.Background(), probe, store, opts)
+	assert.Equal(t, map[string]CheckStatus{
+		"not yet a target":                    CheckPass,
+		"imports":                             CheckPass,
+		"execution role":                      CheckPass,
+		"secrets quota":                       CheckPass,
+		"vault policy: list source analytics": CheckPass,
+		"vault policy: read source analytics": CheckPass,
+		"vault policy: write merged secrets":  CheckFail,
+	}, onboardChecks(report))
+	assert.False(t, report.Ready())
+	for _, c := range report.Checks {
+		switch c.Name {
+		case "secrets quota":
+			assert.Equal(t, "10 existing + 2 incoming of 500000 (not counted: analytics)", c.Detail)
+		case "vault policy: write merged secrets":
+			assert.Equal(t, "missing update on merged/data/Sandbox/*", c.Detail)
+			assert.Equal(t, `add path "merged/data/Sandbox/*" { capabilities = ["create", "update"] } to the pipeline's Vault policy`, c.Fix)
+		}
+	}
+
+	// An unassumable role fails, and the quota is not checked
+	probe.err = errors.New("AccessDenied")
+	probe.caps["merged/data/Sandbox/"] = []string{"create", "update"}
+	report = p.onboard(context.Background(), probe, store, opts)
+	assert.Equal(t, CheckFail, onboardChecks(report)["execution role"])
+	assert.Equal(t, CheckSkip, onboardChecks(report)["secrets quota"])
+
+	// Credentials for another account fail, a nearly full account warns
+	probe.err = nil
+	probe.account = "444444444444"
+	probe.secrets = SecretsManagerSecretQuota - 100
+	report = p.onboard(context.Background(), probe, store, opts)
+	assert.Equal(t, CheckFail, onboardChecks(report)["execution role"])
+	assert.Equal(t, CheckWarn, onboardChecks(report)["secrets quota"])
+
+	probe.account = "333333333333"
+	report = p.onboard(context.Background(), probe, store, opts)
+	assert.True(t, report.Ready())
+	assert.Equal(t, "  Sandbox:\n    account_id: \"333333333333\"\n    imports:\n      - Serverless_Stg\n      - analytics\n", report.Config)
+
+	// An account that is already a target warns
+	report = p.onboard(context.Background(), probe, store, OnboardOptions{AccountID: "111111111111", Name: "Stg2"})
+	assert.Equal(t, CheckWarn, onboardChecks(report)["not yet a target"])
+	assert.Equal(t, CheckWarn, onboardChecks(report)["imports"])
+}
+
+func TestOnboardOptionsValidate(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{"analytics": {}},
+		Targets: map[string]Target{"Serverless_Stg": {AccountID: "111111111111"}},
+	}
+	assert.NoError(t, OnboardOptions{AccountID: "333333333333", Name: "Sandbox", Imports: []string{"analytics", "Serverless_Stg"}}.validate(cfg))
+
+	var optErr *OptionError
+	err := OnboardOptions{AccountID: "12345", Name: "Sandbox"}.validate(cfg)
+	require.ErrorAs(t, err, &optErr)
+	assert.Equal(t, "account", optErr.Option)
+	err = OnboardOptions{AccountID: "333333333333", Name: "Serverless_Stg"}.validate(cfg)
+	require.ErrorAs(t, err, &optErr)
+	assert.Equal(t, "name", optErr.Option)
+	err = OnboardOptions{AccountID: "333333333333", Name: "Sandbox", Imports: []string{"missing"}}.validate(cfg)
+	require.ErrorAs(t, err, &optErr)
+	assert.Equal(t, "import", optErr.Option)
+}
+
+func TestAddTargetToConfig(t *testing.T) {
+	snippet := targetSnippet("Sandbox", Target{AccountID: "333333333333", Imports: []string{"analytics"}})
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "appends to targets",
+			in: "sources:\n  analytics:\n    vault:\n      mount: analytics\n\n" +
+				"targets:\n  # Staging\n  Stg:\n    account_id: \"111111111111\"\n\n" +
+				"# Pipeline settings\npipeline:\n  merge:\n    parallel: 4\n",
+			want: "sources:\n  analytics:\n    vault:\n      mount: analytics\n\n" +
+				"targets:\n  # Staging\n  Stg:\n    account_id: \"111111111111\"\n" + snippet + "\n" +
+				"# Pipeline settings\npipeline:\n  merge:\n    parallel: 4\n",
+		},
+		{
+			name: "targets last, no trailing newline",
+			in:   "targets:\n  Stg:\n    account_id: \"111111111111\"",
+			want: "targets:\n  Stg:\n    account_id: \"111111111111\"\n" + snippet,
+		},
+		{
+			name: "no targets",
+			in:   "sources:\n  analytics: {}\n",
+			want: "sources:\n  analytics: {}\ntargets:\n" + snippet,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := AddTargetToConfig([]byte(tt.in), "Sandbox", snippet)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(out))
+
+			var cfg Config
+			require.NoError(t, yaml.Unmarshal(out, &cfg))
+			assert.Equal(t, []string{"analytics"}, cfg.Targets["Sandbox"].Imports)
+		})
+	}
+
+	// Flow-style targets cannot be appended to
+	_, err := AddTargetToConfig([]byte("targets: {Stg: {account_id: \"1\"}}\n"), "Sandbox", snippet)
+	assert.Error(t, err)
+}
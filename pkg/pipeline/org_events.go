@@ -0,0 +1,415 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/jbcom/secretsync/internal/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// DiscoveryEventsSettings make `vss serve --discover` update the targets it
+// discovered from Organizations as accounts are created, moved between OUs,
+// tagged and removed, instead of only discovering them at startup. An
+// EventBridge rule delivers the Organizations CloudTrail events to an SQS
+// queue that vss consumes.
+//
+//	aws:
+//	  discovery_events:
+//	    queue_url: https://sqs.us-east-1.amazonaws.com/123456789012/vss-org-events
+type DiscoveryEventsSettings struct {
+	QueueURL string `mapstructure:"queue_url" yaml:"queue_url"`
+	// Region is the queue's region (default: aws.region)
+	Region string `mapstructure:"region" yaml:"region,omitempty"`
+}
+
+func (s *DiscoveryEventsSettings) validate() error {
+	return validateQueueURL(s.QueueURL)
+}
+
+func validateQueueURL(queueURL string) error {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("queue_url must be an https SQS queue URL")
+	}
+	return nil
+}
+
+// Organizations events that change an account's membership, OU or tags
+const (
+	orgEventCreateAccountResult = "CreateAccountResult"
+	orgEventMoveAccount         = "MoveAccount"
+	orgEventTagResource         = "TagResource"
+	orgEventUntagResource       = "UntagResource"
+	orgEventRemoveAccount       = "RemoveAccountFromOrganization"
+	orgEventCloseAccount        = "CloseAccount"
+)
+
+// accountEvent is an Organizations change to one account, or an account
+// vended by Account Factory
+type accountEvent struct {
+	AccountID string
+	EventName string
+	// Vended accounts are synced as soon as their targets are added
+	Vended bool
+	// Profile is the AFT account customizations the account was vended with
+	Profile string
+	// done acknowledges the event once it has been applied
+	done func()
+}
+
+// orgCloudTrailEvent is the part of an EventBridge event for an
+// Organizations API call that identifies the account it changed
+type orgCloudTrailEvent struct {
+	Source string `json:"source"`
+	Detail struct {
+		EventName         string `json:"eventName"`
+		RequestParameters struct {
+			AccountID  string `json:"accountId"`
+			ResourceID string `json:"resourceId"`
+		} `json:"requestParameters"`
+		ServiceEventDetails struct {
+			CreateAccountStatus struct {
+				AccountID string `json:"accountId"`
+				State     string `json:"state"`
+			} `json:"createAccountStatus"`
+		} `json:"serviceEventDetails"`
+	} `json:"detail"`
+}
+
+// parseAccountEvent reads the account an EventBridge event changed. It
+// reports false for events that do not change an account's discovery, such
+// as failed account creations or tags on OUs and policies.
+func parseAccountEvent(body []byte) (accountEvent, bool) {
+	var e orgCloudTrailEvent
+	if err := json.Unmarshal(body, &e); err != nil || e.Source != "aws.organizations" {
+		return accountEvent{}, false
+	}
+	ev := accountEvent{EventName: e.Detail.EventName}
+	switch ev.EventName {
+	case orgEventCreateAccountResult:
+		status := e.Detail.ServiceEventDetails.CreateAccountStatus
+		if status.State != "SUCCEEDED" {
+			return accountEvent{}, false
+		}
+		ev.AccountID = status.AccountID
+	case orgEventMoveAccount, orgEventRemoveAccount, orgEventCloseAccount:
+		ev.AccountID = e.Detail.RequestParameters.AccountID
+	case orgEventTagResource, orgEventUntagResource:
+		ev.AccountID = e.Detail.RequestParameters.ResourceID
+	}
+	if !isAccountID(ev.AccountID) {
+		return accountEvent{}, false
+	}
+	return ev, true
+}
+
+func isAccountID(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// accountLookup describes accounts for incremental discovery
+type accountLookup interface {
+	DescribeAccount(ctx context.Context, accountID string) (AccountInfo, error)
+	ParentOUs(ctx context.Context, childID string) ([]string, error)
+}
+
+// organizationsMatch reports whether Organizations discovery finds an account
+// with the given parent OUs, nearest first
+func organizationsMatch(cfg *OrganizationsDiscovery, acct AccountInfo, parents []string) bool {
+	if cfg.OU == "" && len(cfg.Tags) == 0 {
+		return false
+	}
+	if cfg.OU != "" {
+		switch {
+		case len(parents) == 0:
+			return false
+		case cfg.Recursive:
+			if !containsString(parents, cfg.OU) {
+				return false
+			}
+		case parents[0] != cfg.OU:
+			return false
+		}
+	}
+	if len(cfg.Tags) > 0 && len(filterAccountsByTags([]AccountInfo{acct}, cfg.Tags)) == 0 {
+		return false
+	}
+	return true
+}
+
+// incrementalDynamicTargets returns the dynamic targets whose accounts are
+// kept up to date from events: those discovered from Organizations alone
+func (c *Config) incrementalDynamicTargets() []string {
+	var names []string
+	for name, dt := range c.DynamicTargets {
+		d := dt.Discovery
+		if d.Organizations != nil && d.IdentityCenter == nil && d.AccountsList == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyAccountEvent adds, updates or removes the targets dynamic targets
+// discover for the event's account, and rebuilds the dependency graph. It
+// reports whether the targets changed. Events are applied between runs, so
+// a run never sees the targets change under it. A vended account is also
+// added to its Account Factory profile, whatever that profile's discovery.
+func (p *Pipeline) applyAccountEvent(ctx context.Context, lookup accountLookup, ev accountEvent) (bool, error) {
+	l := log.WithFields(log.Fields{
+		"action":    "Pipeline.applyAccountEvent",
+		"accountID": ev.AccountID,
+		"event":     ev.EventName,
+	})
+	dynamicNames := p.config.incrementalDynamicTargets()
+	profile := ""
+	if ev.Vended {
+		profile = p.config.vendedProfile(ev.Profile)
+	}
+	if profile != "" && !containsString(dynamicNames, profile) {
+		dynamicNames = append(dynamicNames, profile)
+	}
+	if len(dynamicNames) == 0 {
+		return false, nil
+	}
+
+	// Removed and closing accounts are dropped from every dynamic target
+	var acct AccountInfo
+	var parents []string
+	active := false
+	if ev.EventName != orgEventRemoveAccount {
+		var err error
+		acct, err = lookup.DescribeAccount(ctx, ev.AccountID)
+		if err != nil {
+			return false, err
+		}
+		if active = acct.Status == "ACTIVE"; active {
+			parents, err = lookup.ParentOUs(ctx, ev.AccountID)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	targets := maps.Clone(p.config.Targets)
+	if targets == nil {
+		targets = make(map[string]Target)
+	}
+	discoveredFrom := maps.Clone(p.config.discoveredFrom)
+	if discoveredFrom == nil {
+		discoveredFrom = make(map[string]string)
+	}
+	discovery := NewDiscoveryService(ctx, nil, p.config)
+
+	changed := false
+	for _, dynamicName := range dynamicNames {
+		dynamicTarget := p.config.DynamicTargets[dynamicName]
+		existing := ""
+		for name, origin := range discoveredFrom {
+			if origin == dynamicName && targets[name].AccountID == ev.AccountID {
+				existing = name
+				break
+			}
+		}
+		org := dynamicTarget.Discovery.Organizations
+		matches := active &&
+			(dynamicName == profile || (org != nil && organizationsMatch(org, acct, parents))) &&
+			!isExcluded(ev.AccountID, dynamicTarget.Exclude)
+
+		switch {
+		case matches:
+			// Like full discovery, only accounts found through an OU are
+			// placed by it
+			found := acct
+			if org == nil || org.OU == "" {
+				found.OU = ""
+			}
+			target := discovery.accountTarget(dynamicTarget, found)
+			name := existing
+			if name == "" {
+				name = accountTargetName(acct)
+				if _, taken := targets[name]; taken {
+					name = fmt.Sprintf("%s_%s", name, acct.ID[:6])
+				}
+				if _, taken := targets[name]; taken {
+					l.WithField("target", name).Warn("Discovered target name already in use, skipping")
+					continue
+				}
+			}
+			if reflect.DeepEqual(targets[name], target) {
+				continue
+			}
+			change := "updated"
+			if existing == "" {
+				change = "added"
+			}
+			targets[name] = target
+			discoveredFrom[name] = dynamicName
+			changed = true
+			metrics.RegisterDiscoveredTargetChange(change)
+			l.WithFields(log.Fields{
+				"target":        name,
+				"dynamicTarget": dynamicName,
+				"ou":            target.OU,
+			}).Infof("Discovered target %s", change)
+		case existing != "":
+			delete(targets, existing)
+			delete(discoveredFrom, existing)
+			changed = true
+			metrics.RegisterDiscoveredTargetChange("removed")
+			l.WithFields(log.Fields{
+				"target":        existing,
+				"dynamicTarget": dynamicName,
+			}).Info("Discovered target removed")
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	prevTargets, prevFrom := p.config.Targets, p.config.discoveredFrom
+	p.config.Targets, p.config.discoveredFrom = targets, discoveredFrom
+	graph, err := BuildGraph(p.config)
+	if err != nil {
+		p.config.Targets, p.config.discoveredFrom = prevTargets, prevFrom
+		return false, fmt.Errorf("failed to rebuild dependency graph: %w", err)
+	}
+	p.graph = graph
+	return true, nil
+}
+
+// sqsReceiver is the part of the SQS API discovery events use
+type sqsReceiver interface {
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+// watchAccountEvents long-polls the queue and sends the account events parse
+// finds until ctx is cancelled. Messages are deleted once their event is
+// done, or at once when they carry no account event; a message whose event
+// is not done before vss stops is delivered again.
+func watchAccountEvents(ctx context.Context, client sqsReceiver, queueURL string, parse func([]byte) (accountEvent, bool), out chan<- accountEvent) {
+	l := log.WithFields(log.Fields{
+		"action": "watchAccountEvents",
+		"queue":  queueURL,
+	})
+	remove := func(handle *string) {
+		_, err := client.DeleteMessage(context.WithoutCancel(ctx), &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(queueURL),
+			ReceiptHandle: handle,
+		})
+		if err != nil {
+			l.WithError(err).Warn("Failed to delete discovery event")
+		}
+	}
+
+	for ctx.Err() == nil {
+		output, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     20,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			l.WithError(err).Warn("Failed to receive discovery events")
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for _, msg := range output.Messages {
+			ev, ok := parse([]byte(aws.ToString(msg.Body)))
+			if !ok {
+				l.WithField("messageID", aws.ToString(msg.MessageId)).Debug("Ignoring event that changes no account")
+				remove(msg.ReceiptHandle)
+				continue
+			}
+			handle := msg.ReceiptHandle
+			ev.done = func() { remove(handle) }
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// accountEvents starts consuming the queues of aws.discovery_events and
+// aws.control_tower.account_factory, or returns nil when neither is
+// configured or there is no AWS context to describe accounts with
+func (p *Pipeline) accountEvents(ctx context.Context) <-chan accountEvent {
+	l := log.WithField("action", "Pipeline.accountEvents")
+	discovery := p.config.AWS.DiscoveryEvents
+	factory := &p.config.AWS.ControlTower.AccountFactory
+
+	// Queues by URL, with their region; both may use the same queue
+	queues := make(map[string]string)
+	if factory.listening() {
+		queues[factory.QueueURL] = factory.Region
+	}
+	if discovery != nil {
+		if _, ok := queues[discovery.QueueURL]; !ok {
+			queues[discovery.QueueURL] = discovery.Region
+		}
+	}
+	if len(queues) == 0 {
+		return nil
+	}
+	if p.awsCtx == nil {
+		l.Warn("aws.discovery_events and aws.control_tower.account_factory need dynamic target discovery (--discover), ignoring them")
+		return nil
+	}
+
+	parse := func(body []byte) (accountEvent, bool) {
+		if factory.listening() {
+			if ev, ok := parseAccountFactoryEvent(body, factory); ok {
+				return ev, true
+			}
+		}
+		if discovery != nil {
+			return parseAccountEvent(body)
+		}
+		return accountEvent{}, false
+	}
+	urls := make([]string, 0, len(queues))
+	for u := range queues {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	events := make(chan accountEvent)
+	for _, queueURL := range urls {
+		region := queues[queueURL]
+		if region == "" {
+			region = p.config.AWS.Region
+		}
+		client := sqs.NewFromConfig(p.awsCtx.BaseConfig, func(o *sqs.Options) {
+			o.Region = region
+		})
+		go watchAccountEvents(ctx, client, queueURL, parse, events)
+		l.WithField("queue", queueURL).Info("Watching account events")
+	}
+	return events
+}
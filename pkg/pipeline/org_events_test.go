@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccountEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		accountID string
+		ok        bool
+	}{
+		{
+			name:      "account created",
+			body:      `{"source": "aws.organizations", "detail": {"eventName": "CreateAccountResult", "serviceEventDetails": {"createAccountStatus": {"accountId": "111111111111", "state": "SUCCEEDED"}}}}`,
+			accountID: "111111111111",
+			ok:        true,
+		},
+		{
+			name: "account creation failed",
+			body: `{"source": "aws.organizations", "detail": {"eventName": "CreateAccountResult", "serviceEventDetails": {"createAccountStatus": {"state": "FAILED"}}}}`,
+		},
+		{
+			name:      "account moved",
+			body:      `{"source": "aws.organizations", "detail": {"eventName": "MoveAccount", "requestParameters": {"accountId": "222222222222", "sourceParentId": "ou-a", "destinationParentId": "ou-b"}}}`,
+			accountID: "222222222222",
+			ok:        true,
+		},
+		{
+			name:      "account tagged",
+			body:      `{"source": "aws.organizations", "detail": {"eventName": "TagResource", "requestParameters": {"resourceId": "333333333333"}}}`,
+			accountID: "333333333333",
+			ok:        true,
+		},
+		{
+			name: "OU tagged",
+			body: `{"source": "aws.organizations", "detail": {"eventName": "TagResource", "requestParameters": {"resourceId": "ou-abcd-12345678"}}}`,
+		},
+		{
+			name: "other service",
+			body: `{"source": "aws.ec2", "detail": {"eventName": "TagResource", "requestParameters": {"resourceId": "333333333333"}}}`,
+		},
+		{name: "not json", body: "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, ok := parseAccountEvent([]byte(tt.body))
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.accountID, ev.AccountID)
+		})
+	}
+}
+
+func TestOrganizationsMatch(t *testing.T) {
+	acct := AccountInfo{ID: "111111111111", Tags: map[string]string{"team": "analytics"}}
+	parents := []string{"ou-sandbox", "ou-workloads", "r-root"}
+
+	assert.True(t, organizationsMatch(&OrganizationsDiscovery{OU: "ou-sandbox"}, acct, parents))
+	assert.False(t, organizationsMatch(&OrganizationsDiscovery{OU: "ou-workloads"}, acct, parents))
+	assert.True(t, organizationsMatch(&OrganizationsDiscovery{OU: "ou-workloads", Recursive: true}, acct, parents))
+	assert.True(t, organizationsMatch(&OrganizationsDiscovery{Tags: map[string]string{"team": "analytics"}}, acct, parents))
+	assert.False(t, organizationsMatch(&OrganizationsDiscovery{OU: "ou-sandbox", Tags: map[string]string{"team": "payments"}}, acct, parents))
+	assert.False(t, organizationsMatch(&OrganizationsDiscovery{}, acct, parents))
+}
+
+// fakeAccountLookup describes accounts from a map
+type fakeAccountLookup struct {
+	accounts map[string]AccountInfo
+	parents  map[string][]string
+}
+
+func (f fakeAccountLookup) DescribeAccount(_ context.Context, id string) (AccountInfo, error) {
+	acct, ok := f.accounts[id]
+	if !ok {
+		return AccountInfo{}, fmt.Errorf("AccountNotFoundException: %s", id)
+	}
+	return acct, nil
+}
+
+func (f fakeAccountLookup) ParentOUs(_ context.Context, id string) ([]string, error) {
+	return f.parents[id], nil
+}
+
+func TestApplyAccountEvent(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{
+		AWS:     AWSConfig{Region: "us-east-1"},
+		Sources: map[string]Source{"analytics": {}},
+		Targets: map[string]Target{"Shared": {AccountID: "999999999999", Imports: []string{"analytics"}}},
+		DynamicTargets: map[string]DynamicTarget{
+			"sandboxes": {
+				Discovery: DiscoveryConfig{Organizations: &OrganizationsDiscovery{OU: "ou-sandbox", Recursive: true}},
+				Imports:   []string{"analytics"},
+				RoleARN:   "arn:aws:iam::{{.AccountID}}:role/SecretsSync",
+				Exclude:   []string{"444444444444"},
+			},
+		},
+	}
+	graph, err := BuildGraph(cfg)
+	require.NoError(t, err)
+	p := &Pipeline{config: cfg, graph: graph}
+
+	lookup := fakeAccountLookup{
+		accounts: map[string]AccountInfo{
+			"111111111111": {ID: "111111111111", Name: "Sandbox Alice", Status: "ACTIVE"},
+			"444444444444": {ID: "444444444444", Name: "Sandbox Excluded", Status: "ACTIVE"},
+		},
+		parents: map[string][]string{
+			"111111111111": {"ou-team", "ou-sandbox", "r-root"},
+			"444444444444": {"ou-sandbox", "r-root"},
+		},
+	}
+
+	// A new account in the OU becomes a target
+	changed, err := p.applyAccountEvent(ctx, lookup, accountEvent{AccountID: "111111111111", EventName: orgEventCreateAccountResult})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	target, ok := cfg.Targets["Sandbox_Alice"]
+	require.True(t, ok)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/SecretsSync", target.RoleARN)
+	assert.Equal(t, "us-east-1", target.Region)
+	assert.Contains(t, p.graph.Nodes, "Sandbox_Alice")
+
+	// Applying the same event again changes nothing
+	changed, err = p.applyAccountEvent(ctx, lookup, accountEvent{AccountID: "111111111111", EventName: orgEventTagResource})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Excluded accounts are never added
+	changed, err = p.applyAccountEvent(ctx, lookup, accountEvent{AccountID: "444444444444", EventName: orgEventMoveAccount})
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// Moving the account out of the OU removes its target
+	lookup.parents["111111111111"] = []string{"ou-suspended", "r-root"}
+	changed, err = p.applyAccountEvent(ctx, lookup, accountEvent{AccountID: "111111111111", EventName: orgEventMoveAccount})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotContains(t, cfg.Targets, "Sandbox_Alice")
+	assert.NotContains(t, p.graph.Nodes, "Sandbox_Alice")
+	assert.Contains(t, cfg.Targets, "Shared")
+
+	// Lookup failures leave the targets alone
+	_, err = p.applyAccountEvent(ctx, lookup, accountEvent{AccountID: "555555555555", EventName: orgEventMoveAccount})
+	assert.ErrorContains(t, err, "AccountNotFoundException")
+}
+
+// fakeSQS returns its messages once, then blocks until cancelled
+type fakeSQS struct {
+	mu       sync.Mutex
+	messages []sqstypes.Message
+	deleted  []string
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	msgs := f.messages
+	f.messages = nil
+	f.mu.Unlock()
+	if len(msgs) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &sqs.ReceiveMessageOutput{Messages: msgs}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(_ context.Context, in *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, aws.ToString(in.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestWatchAccountEvents(t *testing.T) {
+	client := &fakeSQS{messages: []sqstypes.Message{
+		{ReceiptHandle: aws.String("ignored"), Body: aws.String(`{"source": "aws.ec2"}`)},
+		{ReceiptHandle: aws.String("moved"), Body: aws.String(`{"source": "aws.organizations", "detail": {"eventName": "MoveAccount", "requestParameters": {"accountId": "222222222222"}}}`)},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan accountEvent)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		watchAccountEvents(ctx, client, "https://sqs.us-east-1.amazonaws.com/123456789012/vss-org-events", parseAccountEvent, events)
+	}()
+
+	ev := <-events
+	assert.Equal(t, "222222222222", ev.AccountID)
+	client.mu.Lock()
+	// Messages without an account event are deleted at once; the others once done
+	assert.Equal(t, []string{"ignored"}, client.deleted)
+	client.mu.Unlock()
+	ev.done()
+	cancel()
+	<-stopped
+	assert.Equal(t, []string{"ignored", "moved"}, client.deleted)
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OUDefaults are target fields set once per Organizational Unit in
+// aws.organizations.ous. A target inherits the defaults of every OU from the
+// root down to its own: region and role_arn come from the nearest OU that sets
+// them, and imports accumulate from the root down, ahead of the target's own.
+// Fields the target sets itself always win.
+//
+//	aws:
+//	  organizations:
+//	    ous:
+//	      workloads:
+//	        id: ou-abcd-11111111
+//	        defaults:
+//	          region: us-east-1
+//	          role_arn: "arn:aws:iam::{{.AccountID}}:role/SecretsSync"
+//	          imports: [platform]
+//	        children:
+//	          prod:
+//	            id: ou-abcd-22222222
+//	            accounts: ["222222222222"]
+//	            defaults:
+//	              imports: [prod-db]
+type OUDefaults struct {
+	Region  string   `mapstructure:"region" yaml:"region,omitempty"`
+	RoleARN string   `mapstructure:"role_arn" yaml:"role_arn,omitempty"` // Supports {{.AccountID}}
+	Imports []string `mapstructure:"imports" yaml:"imports,omitempty"`
+}
+
+// ouNode is a configured OU along with its path, e.g. workloads/prod
+type ouNode struct {
+	Path string
+	OUConfig
+}
+
+// findOU returns the OUs from the root down to the first OU matching match,
+// or nil if none does. Siblings are searched in name order.
+func (c *Config) findOU(match func(ouNode) bool) []ouNode {
+	var walk func(prefix string, ous map[string]OUConfig, parents []ouNode) []ouNode
+	walk = func(prefix string, ous map[string]OUConfig, parents []ouNode) []ouNode {
+		names := make([]string, 0, len(ous))
+		for name := range ous {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			node := ouNode{Path: prefix + name, OUConfig: ous[name]}
+			path := append(parents[:len(parents):len(parents)], node)
+			if match(node) {
+				return path
+			}
+			if found := walk(node.Path+"/", node.Children, path); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return walk("", c.AWS.Organizations.OUs, nil)
+}
+
+// ouPath returns the OUs from the root down to the OU named by ref (a path or
+// OU ID), falling back to the OU that lists accountID
+func (c *Config) ouPath(ref, accountID string) []ouNode {
+	if ref != "" {
+		if path := c.findOU(func(n ouNode) bool { return n.Path == ref || n.ID == ref }); path != nil {
+			return path
+		}
+	}
+	if accountID == "" {
+		return nil
+	}
+	return c.findOU(func(n ouNode) bool { return containsString(n.Accounts, accountID) })
+}
+
+// withOUDefaults fills in what t leaves unset from the defaults along path
+func withOUDefaults(t Target, path []ouNode) Target {
+	if len(path) == 0 {
+		return t
+	}
+	t.OU = path[len(path)-1].Path
+
+	for i := len(path) - 1; i >= 0; i-- {
+		d := path[i].Defaults
+		if t.Region == "" {
+			t.Region = d.Region
+		}
+		if t.RoleARN == "" && d.RoleARN != "" {
+			t.RoleARN = strings.ReplaceAll(d.RoleARN, "{{.AccountID}}", t.AccountID)
+		}
+	}
+
+	var imports []string
+	for _, ou := range path {
+		for _, imp := range ou.Defaults.Imports {
+			imports = appendUniqueString(imports, imp)
+		}
+	}
+	if len(imports) > 0 {
+		for _, imp := range t.Imports {
+			imports = appendUniqueString(imports, imp)
+		}
+		t.Imports = imports
+	}
+	return t
+}
+
+// applyOUDefaults applies OU defaults to static targets placed in an OU by
+// their ou field or by their account being listed under one
+func (c *Config) applyOUDefaults() error {
+	for name, t := range c.Targets {
+		var path []ouNode
+		if t.OU != "" {
+			path = c.findOU(func(n ouNode) bool { return n.Path == t.OU || n.ID == t.OU })
+			if path == nil {
+				return fmt.Errorf("target %q: ou %q not found in aws.organizations.ous", name, t.OU)
+			}
+		} else if t.AccountID != "" {
+			path = c.ouPath("", t.AccountID)
+		}
+		c.Targets[name] = withOUDefaults(t, path)
+	}
+	return nil
+}
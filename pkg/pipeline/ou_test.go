@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ouTestConfig = `
+vault:
+  address: https://vault.example.com
+aws:
+  region: us-east-1
+  organizations:
+    ous:
+      workloads:
+        id: ou-abcd-11111111
+        defaults:
+          region: us-west-2
+          role_arn: "arn:aws:iam::{{.AccountID}}:role/SecretsSync"
+          imports: [platform]
+        children:
+          prod:
+            id: ou-abcd-22222222
+            accounts: ["222222222222"]
+            defaults:
+              region: eu-west-1
+              imports: [prod-db]
+      sandboxes:
+        id: ou-abcd-33333333
+        defaults:
+          imports: [analytics]
+sources:
+  platform:
+    vault:
+      mount: platform
+  prod-db:
+    vault:
+      mount: prod-db
+  analytics:
+    vault:
+      mount: analytics
+merge_store:
+  vault:
+    mount: merged
+targets:
+  Payments_Prod:
+    account_id: "222222222222"
+    imports: [analytics]
+  Payments_Stg:
+    account_id: "111111111111"
+    ou: workloads
+    region: us-east-2
+  Sandbox_Alice:
+    account_id: "333333333333"
+    ou: ou-abcd-33333333
+    role_arn: arn:aws:iam::333333333333:role/Custom
+  Unplaced:
+    account_id: "444444444444"
+    imports: [analytics]
+`
+
+func TestLoadConfigOUDefaults(t *testing.T) {
+	cfg, err := LoadConfig(writeTestConfig(t, ouTestConfig))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	// Placed by account ID; the nearest OU's region wins and imports accumulate
+	prod := cfg.Targets["Payments_Prod"]
+	assert.Equal(t, "workloads/prod", prod.OU)
+	assert.Equal(t, "eu-west-1", prod.Region)
+	assert.Equal(t, "arn:aws:iam::222222222222:role/SecretsSync", prod.RoleARN)
+	assert.Equal(t, []string{"platform", "prod-db", "analytics"}, prod.Imports)
+
+	// Placed by path; its own region wins
+	stg := cfg.Targets["Payments_Stg"]
+	assert.Equal(t, "us-east-2", stg.Region)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/SecretsSync", stg.RoleARN)
+	assert.Equal(t, []string{"platform"}, stg.Imports)
+
+	// Placed by OU ID, which is normalized to the path
+	alice := cfg.Targets["Sandbox_Alice"]
+	assert.Equal(t, "sandboxes", alice.OU)
+	assert.Equal(t, "arn:aws:iam::333333333333:role/Custom", alice.RoleARN)
+	assert.Equal(t, []string{"analytics"}, alice.Imports)
+
+	unplaced := cfg.Targets["Unplaced"]
+	assert.Empty(t, unplaced.OU)
+	assert.Empty(t, unplaced.Region)
+	assert.Equal(t, []string{"analytics"}, unplaced.Imports)
+}
+
+func TestLoadConfigOUNotFound(t *testing.T) {
+	_, err := LoadConfig(writeTestConfig(t, ouTestConfig+`
+  Orphan:
+    account_id: "555555555555"
+    ou: workloads/dev
+`))
+	assert.EqualError(t, err, `target "Orphan": ou "workloads/dev" not found in aws.organizations.ous`)
+}
+
+func TestOUPathDiscoveredAccounts(t *testing.T) {
+	cfg, err := LoadConfig(writeTestConfig(t, ouTestConfig))
+	require.NoError(t, err)
+
+	// Discovered through the OU
+	target := withOUDefaults(Target{AccountID: "666666666666", Imports: []string{"analytics"}}, cfg.ouPath("ou-abcd-22222222", "666666666666"))
+	assert.Equal(t, "workloads/prod", target.OU)
+	assert.Equal(t, "eu-west-1", target.Region)
+	assert.Equal(t, []string{"platform", "prod-db", "analytics"}, target.Imports)
+
+	// An unconfigured parent OU falls back to the account's listing
+	target = withOUDefaults(Target{AccountID: "222222222222"}, cfg.ouPath("ou-abcd-99999999", "222222222222"))
+	assert.Equal(t, "workloads/prod", target.OU)
+
+	assert.Nil(t, cfg.ouPath("ou-abcd-99999999", "777777777777"))
+}
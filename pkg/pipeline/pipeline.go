@@ -32,13 +32,18 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/jbcom/secretsync/api/v1alpha1"
 	"github.com/jbcom/secretsync/internal/backend"
+	"github.com/jbcom/secretsync/internal/metrics"
+	"github.com/jbcom/secretsync/internal/notifications"
 	"github.com/jbcom/secretsync/internal/queue"
 	internalSync "github.com/jbcom/secretsync/internal/sync"
 	"github.com/jbcom/secretsync/pkg/diff"
@@ -71,6 +76,56 @@ type Pipeline struct {
 
 	// S3 merge store (if configured)
 	s3Store *S3MergeStore
+	// GCP Secret Manager merge store (if configured)
+	gcpStore *GCPMergeStore
+	// In-memory merge store used by simulations
+	memStore directMergeStore
+	// Reads the Vault merge store (nil: vault.VaultClient)
+	vaultStore mergeStore
+	// Generates data keys and grants for envelope targets
+	envelopeKMS envelopeKMS
+	// Signs run manifests
+	manifestKMS manifestKMS
+	// Opens Vault sources for S3 and GCP merges (nil: vault.VaultClient)
+	openVaultSource func(ctx context.Context, src *VaultSource) (vaultReader, error)
+
+	// Checks target preconditions before sync
+	readiness readinessProbe
+	// Lists Secrets Manager destinations for the inventory
+	inventoryLister secretLister
+	// Resolves who can read synced secrets for access reviews
+	accessSimulator accessSimulator
+	// Reads the CloudTrail events of destination accounts for audits
+	cloudTrail cloudTrailSource
+	// Reads secret values for break-glass access
+	breakglass breakglassReader
+	// Reads Secrets Manager destinations for drift detection
+	driftReader destinationReader
+	// Probes destinations; syncs skip those whose last probe failed
+	destinationProber destinationProber
+	destHealth        destinationHealthTracker
+	// Checks accounts being onboarded and the Vault policies they need
+	onboardProbe onboardProbe
+	// Checks the access runs need before they start
+	preflightProbe preflightProbe
+	// Removes offboarded targets' secrets from their destinations
+	offboarder destinationCleaner
+	// Runs a destination's sync in place of the sync engine (simulations)
+	runSyncConfig func(ctx context.Context, targetName string, sc v1alpha1.VaultSecretSync) (*backend.SyncCompletion, error)
+
+	// Bounds target operations across phases; see workers
+	pool     *workerPool
+	poolOnce sync.Once
+
+	// Targets whose sync waits for approval in the current run
+	pendingApproval map[string]bool
+	// Skips syncing unchanged destinations in the current run
+	unchanged *unchangedSyncs
+
+	// Keeps run states for --resume (pipeline.state)
+	stateStore runStateStore
+	// Records the current run's state
+	runState *runStateTracker
 
 	// Execution tracking
 	results   []Result
@@ -79,6 +134,11 @@ type Pipeline struct {
 	// Diff tracking for dry-run and CI/CD integration
 	pipelineDiff *diff.PipelineDiff
 	diffMu       sync.Mutex
+	// Merged secrets dry runs did not write to their merge stores
+	pendingMerges map[string]map[string]interface{}
+
+	// Logs in to and renews the token of the Vault auth method (nil: none)
+	vaultAuth *vaultAuthenticator
 }
 
 // New creates a new Pipeline from configuration
@@ -91,19 +151,65 @@ func New(cfg *Config) (*Pipeline, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to build dependency graph: %w", err)
 	}
+	// Rate limits apply to every AWS client, including the sync engine's
+	configureRateLimits(&cfg.AWS)
 
 	return &Pipeline{
-		config: cfg,
-		graph:  graph,
+		config:    cfg,
+		graph:     graph,
+		vaultAuth: configureVaultAuth(context.Background(), cfg.Vault),
 	}, nil
 }
 
+var (
+	// vaultAuths is the authenticator each Vault address's clients take
+	// their tokens from
+	vaultAuths   = make(map[string]*vaultAuthenticator)
+	vaultAuthsMu sync.Mutex
+)
+
+// configureVaultAuth makes every client of the pipeline's Vault, including
+// the sync engine's, take its token from the configured auth method. The
+// token is renewed until ctx ends or the returned authenticator is closed;
+// it is nil when no auth method is configured.
+func configureVaultAuth(ctx context.Context, cfg VaultConfig) *vaultAuthenticator {
+	if cfg.Auth.method() == "" {
+		return nil
+	}
+	a := newVaultAuthenticator(ctx, cfg)
+	vaultAuthsMu.Lock()
+	defer vaultAuthsMu.Unlock()
+	vaultAuths[strings.TrimRight(cfg.Address, "/")] = a
+	vault.SetTokenSource(cfg.Address, a.Token)
+	return a
+}
+
+// Close stops renewing the pipeline's Vault token and revokes it. Vault
+// clients stop using the token unless a newer pipeline has configured its own
+// for the same Vault.
+func (p *Pipeline) Close() {
+	if p.vaultAuth == nil {
+		return
+	}
+	address := strings.TrimRight(p.config.Vault.Address, "/")
+	vaultAuthsMu.Lock()
+	if vaultAuths[address] == p.vaultAuth {
+		delete(vaultAuths, address)
+		vault.SetTokenSource(address, nil)
+	}
+	vaultAuthsMu.Unlock()
+	p.vaultAuth.Close()
+}
+
 // NewWithContext creates a Pipeline with AWS context for dynamic target discovery
 func NewWithContext(ctx context.Context, cfg *Config) (*Pipeline, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	// Rate limits apply to every AWS client, including the sync engine's
+	configureRateLimits(&cfg.AWS)
+
 	// Initialize AWS execution context if we have AWS config
 	var awsCtx *AWSExecutionContext
 	var err error
@@ -114,8 +220,8 @@ func NewWithContext(ctx context.Context, cfg *Config) (*Pipeline, error) {
 		}
 	}
 
-	// Expand dynamic targets if AWS context is available
-	if awsCtx != nil && len(cfg.DynamicTargets) > 0 {
+	// Expand dynamic targets (AWS-based discovery is skipped without an AWS context)
+	if len(cfg.DynamicTargets) > 0 {
 		if err := ExpandDynamicTargets(ctx, cfg, awsCtx); err != nil {
 			log.WithError(err).Warn("Failed to expand dynamic targets")
 		}
@@ -139,8 +245,23 @@ func NewWithContext(ctx context.Context, cfg *Config) (*Pipeline, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create S3 merge store: %w", err)
 		}
+		if cfg.MergeStore.S3.EnvelopeKeyID != "" {
+			p.envelopeKMS, err = newEnvelopeKMS(ctx, cfg.AWS.Region)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create KMS client: %w", err)
+			}
+		}
 	}
 
+	// Initialize GCP Secret Manager merge store if configured
+	if cfg.MergeStore.GCP != nil {
+		p.gcpStore, err = NewGCPMergeStore(ctx, cfg.MergeStore.GCP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCP merge store: %w", err)
+		}
+	}
+
+	p.vaultAuth = configureVaultAuth(ctx, cfg.Vault)
 	return p, nil
 }
 
@@ -168,9 +289,14 @@ type Options struct {
 	// Operation to perform (merge, sync, or pipeline)
 	Operation Operation
 
-	// Targets to process (empty = all targets)
+	// Targets to process, with their dependencies (empty = all targets unless
+	// Selector is set)
 	Targets []string
 
+	// Selector adds the targets on the given levels and with the given labels,
+	// without their dependencies
+	Selector TargetSelector
+
 	// DryRun performs all operations without making changes
 	DryRun bool
 
@@ -186,6 +312,40 @@ type Options struct {
 	// ComputeDiff enables diff computation even for non-dry-run executions
 	// Useful for audit trails and CI/CD reporting
 	ComputeDiff bool
+
+	// FreezeOverride allows non-dry-run executions during active freeze windows;
+	// the override is recorded in the audit log
+	FreezeOverride *FreezeOverride
+
+	// NoDeps processes only the named Targets, not their dependencies. The
+	// dependencies' current merged output is used; a warning lists them and
+	// flags any whose output is missing or older than MaxDependencyAge.
+	NoDeps bool
+
+	// MaxDependencyAge is how long ago a skipped dependency may have last
+	// merged before it is reported as stale (0 uses DefaultMaxDependencyAge)
+	MaxDependencyAge time.Duration
+
+	// Provenance identifies the vss binary, config file and operator in the
+	// signed run manifest
+	Provenance Provenance
+
+	// ProbeDestinations probes every destination before the sync phase and
+	// skips those that are down, reporting the run as degraded
+	ProbeDestinations bool
+
+	// AllowNewTargets lets a run that exceeds pipeline.discovery_limits add up
+	// to this many newly discovered targets; the override is audit logged
+	AllowNewTargets int
+
+	// Resume is the ID of a run recorded in pipeline.state to resume. Its
+	// operation and targets are rerun, skipping the target phases that
+	// succeeded.
+	Resume string
+
+	// ForceSync syncs destinations that pipeline.sync.skip_unchanged would
+	// skip because their secrets and config are unchanged
+	ForceSync bool
 }
 
 // DefaultOptions returns sensible defaults
@@ -200,6 +360,67 @@ func DefaultOptions() Options {
 	}
 }
 
+// OptionError reports an invalid Options field
+type OptionError struct {
+	Option string
+	Value  interface{}
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("invalid %s %v: %s", e.Option, e.Value, e.Reason)
+}
+
+// ParseOperation converts a string to an Operation, rejecting unknown operations
+func ParseOperation(s string) (Operation, error) {
+	switch op := Operation(strings.ToLower(strings.TrimSpace(s))); op {
+	case OperationMerge, OperationSync, OperationPipeline:
+		return op, nil
+	}
+	return "", &OptionError{Option: "operation", Value: fmt.Sprintf("%q", s), Reason: "must be merge, sync or pipeline"}
+}
+
+// Validate checks Options before any work is started. Errors are *OptionError.
+func (o Options) Validate() error {
+	if _, err := ParseOperation(string(o.Operation)); err != nil {
+		return err
+	}
+	if o.Parallelism < 0 {
+		return &OptionError{Option: "parallelism", Value: o.Parallelism, Reason: "must not be negative (0 uses the configured default)"}
+	}
+	if o.OutputFormat != "" {
+		if _, err := diff.ParseOutputFormat(string(o.OutputFormat)); err != nil {
+			return &OptionError{Option: "output format", Value: fmt.Sprintf("%q", o.OutputFormat), Reason: err.Error()}
+		}
+	}
+	for _, t := range o.Targets {
+		if strings.TrimSpace(t) == "" {
+			return &OptionError{Option: "targets", Value: fmt.Sprintf("%q", o.Targets), Reason: "target names must not be empty"}
+		}
+	}
+	for _, l := range o.Selector.Levels {
+		if l < 0 {
+			return &OptionError{Option: "levels", Value: o.Selector.Levels, Reason: "levels must not be negative"}
+		}
+	}
+	if o.AllowNewTargets < 0 {
+		return &OptionError{Option: "allow new targets", Value: o.AllowNewTargets, Reason: "must not be negative"}
+	}
+	if o.FreezeOverride != nil && strings.TrimSpace(o.FreezeOverride.Reason) == "" {
+		return &OptionError{Option: "freeze override", Value: `""`, Reason: "a reason is required"}
+	}
+	if o.Resume != "" && (len(o.Targets) > 0 || !o.Selector.IsZero()) {
+		return &OptionError{Option: "resume", Value: fmt.Sprintf("%q", o.Resume), Reason: "reruns the resumed run's targets and cannot be combined with targets or a selector"}
+	}
+	if o.NoDeps && len(o.Targets) == 0 {
+		return &OptionError{Option: "no-deps", Value: o.NoDeps, Reason: "requires explicitly named targets"}
+	}
+	if o.MaxDependencyAge < 0 {
+		return &OptionError{Option: "max dependency age", Value: o.MaxDependencyAge, Reason: "must not be negative"}
+	}
+	return nil
+}
+
 // Result represents the outcome of a single target operation
 type Result struct {
 	Target    string        `json:"target"`
@@ -207,9 +428,12 @@ type Result struct {
 	Operation string        `json:"operation"`
 	Success   bool          `json:"success"`
 	Error     error         `json:"error,omitempty"`
+	Started   time.Time     `json:"started"`
 	Duration  time.Duration `json:"duration"`
 	Details   ResultDetails `json:"details,omitempty"`
 	Diff      *diff.TargetDiff `json:"diff,omitempty"`
+	// Attempts is how many tries the retry policy made
+	Attempts int `json:"attempts,omitempty"`
 }
 
 // ResultDetails contains additional information about the operation
@@ -223,24 +447,145 @@ type ResultDetails struct {
 	DestinationPath  string   `json:"destination_path,omitempty"`
 	RoleARN          string   `json:"role_arn,omitempty"`
 	FailedImports    []string `json:"failed_imports,omitempty"`
+	// FailedPaths lists each source path that failed to sync, with its error
+	FailedPaths []string `json:"failed_paths,omitempty"`
+	// Destinations holds per-destination outcomes for targets with a destinations list
+	Destinations []DestinationResult `json:"destinations,omitempty"`
+	// SkippedDestinations lists destinations not synced because their last
+	// health probe failed
+	SkippedDestinations []string `json:"skipped_destinations,omitempty"`
+	// VerificationFailures lists each path that was written but did not read
+	// back as written, with its error; they are also in FailedPaths
+	VerificationFailures []string `json:"verification_failures,omitempty"`
+	// PendingApproval is set when a newly discovered target was not synced
+	// because it has not been approved
+	PendingApproval bool `json:"pending_approval,omitempty"`
+	// Resumed is set when the phase was not rerun because it succeeded in
+	// the run being resumed
+	Resumed bool `json:"resumed,omitempty"`
+	// Unchanged is set when no destination was synced because each was last
+	// synced with the same merged secrets and config
+	// (pipeline.sync.skip_unchanged)
+	Unchanged bool `json:"unchanged,omitempty"`
 }
 
 // Run executes the pipeline with the given options
 func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	for _, t := range opts.Targets {
+		if _, ok := p.config.Targets[t]; !ok {
+			return nil, &OptionError{Option: "target", Value: fmt.Sprintf("%q", t), Reason: "not found in configuration"}
+		}
+	}
+
+	// A resumed run reruns the operation and targets it resumes
+	p.runState = nil
+	var resumed *RunState
+	if opts.Resume != "" {
+		var err error
+		resumed, err = p.loadResumeState(ctx, opts.Resume)
+		if err != nil {
+			return nil, err
+		}
+		opts.Operation = resumed.Operation
+	}
+
 	l := log.WithFields(log.Fields{
 		"action":    "Pipeline.Run",
 		"operation": opts.Operation,
 		"dryRun":    opts.DryRun,
 	})
 
+	// Resolve targets
+	var targets []string
+	if resumed != nil {
+		targets = p.resumeTargets(resumed)
+		l = l.WithField("resume", resumed.ID)
+	} else {
+		targets = p.resolveTargets(opts.Targets, opts.Selector, opts.NoDeps)
+	}
+	if len(targets) == 0 && !opts.Selector.IsZero() {
+		return nil, &OptionError{Option: "selector", Value: fmt.Sprintf("%q", opts.Selector), Reason: "matches no targets"}
+	}
+	l.WithField("targets", targets).Info("Starting pipeline execution")
+
+	// Freeze windows only block runs that change something
+	if !opts.DryRun {
+		if err := p.config.CheckFreeze(time.Now(), targets, opts.FreezeOverride); err != nil {
+			return nil, err
+		}
+	}
+
+	// Claim the targets so no other controller writes them at the same time
+	if !opts.DryRun && p.config.Pipeline.Coordination != nil {
+		claims, err := p.claimTargets(ctx, targets)
+		if err != nil {
+			return nil, err
+		}
+		defer claims.release(context.WithoutCancel(ctx))
+	}
+
+	// Discovery that matches more accounts than expected stops the run
+	// before anything is synced to them
+	if opts.Operation != OperationMerge {
+		if err := p.checkDiscoveryLimits(ctx, targets, opts.AllowNewTargets, !opts.DryRun, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Newly discovered targets are not synced until they are approved
+	p.pendingApproval = nil
+	if opts.Operation != OperationMerge {
+		pending, err := p.pendingApprovals(ctx, targets, !opts.DryRun, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to check target approvals: %w", err)
+		}
+		p.pendingApproval = pending
+	}
+
+	// Dry runs sync every destination: their merges do not update the
+	// merged secrets an unchanged sync is judged by
+	p.unchanged = nil
+	if opts.Operation != OperationMerge && !opts.DryRun && p.config.Pipeline.Sync.SkipUnchanged {
+		unchanged, err := p.startUnchangedSyncs(ctx, opts.ForceSync)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sync hashes: %w", err)
+		}
+		p.unchanged = unchanged
+	}
+
 	// Initialize infrastructure
 	if err := p.initialize(ctx); err != nil {
 		return nil, fmt.Errorf("failed to initialize pipeline: %w", err)
 	}
 
+	// Staged secrets whose activation time has passed go live before anything
+	// reads or syncs them
+	if !opts.DryRun {
+		if err := p.activateStaged(ctx, targets, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to activate staged secrets: %w", err)
+		}
+	}
+
+	// Skipped dependencies are only read by merges
+	if opts.NoDeps && opts.Operation != OperationSync {
+		if skipped := p.skippedDependencies(opts.Targets, targets); len(skipped) > 0 {
+			deps, err := p.checkSkippedDependencies(ctx, skipped, opts.MaxDependencyAge, time.Now())
+			if err != nil {
+				return nil, fmt.Errorf("failed to check skipped dependencies: %w", err)
+			}
+			if err := warnSkippedDependencies(deps, opts.DryRun); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// Reset results (protected by mutex for concurrent safety)
 	p.resultsMu.Lock()
 	p.results = nil
@@ -249,12 +594,13 @@ func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
 	// Initialize diff tracking for dry-run or when explicitly requested
 	if opts.DryRun || opts.ComputeDiff {
 		p.initDiff(opts.DryRun, "")
+	} else {
+		p.diffMu.Lock()
+		p.pipelineDiff = nil
+		p.pendingMerges = nil
+		p.diffMu.Unlock()
 	}
 
-	// Resolve targets
-	targets := p.resolveTargets(opts.Targets)
-	l.WithField("targets", targets).Info("Starting pipeline execution")
-
 	// Apply options from config if not specified
 	if opts.Parallelism <= 0 {
 		opts.Parallelism = p.config.Pipeline.Merge.Parallel
@@ -263,17 +609,112 @@ func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
 		}
 	}
 
+	// The roles assumed from here on are tagged with the run
+	started := time.Now()
+	p.config.AWS.session = p.config.runSession(runID(started), opts.Provenance)
+
+	if opts.ProbeDestinations && opts.Operation != OperationMerge {
+		if _, err := p.ProbeDestinations(ctx, targets); err != nil {
+			return nil, fmt.Errorf("failed to probe destinations: %w", err)
+		}
+	}
+
+	notification := startNotification(opts, targets, time.Now())
+	notification.Accounts = p.targetAccounts(targets)
+	runPayload := hookPayload(HookPreRun, opts)
+	runPayload.Targets = targets
+	if err := p.runHooks(ctx, runPayload); err != nil {
+		p.notify(ctx, notification.withOutcome(nil, err))
+		return nil, err
+	}
+	p.notify(ctx, notification)
+
+	// Diffs read destinations the way drift detection does
+	if p.diffing() && p.driftReader == nil {
+		p.driftReader = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+
 	// Execute based on operation
+	if p.config.Pipeline.State != nil {
+		tracker, err := p.startRunState(ctx, runID(started), opts, targets, resumed)
+		if err != nil {
+			p.notify(ctx, notification.withOutcome(nil, err))
+			return nil, err
+		}
+		p.runState = tracker
+	}
+	var results []Result
+	var err error
 	switch opts.Operation {
 	case OperationMerge:
-		return p.runMerge(ctx, targets, opts)
+		results, err = p.runMerge(ctx, targets, opts)
 	case OperationSync:
-		return p.runSync(ctx, targets, opts)
+		results, err = p.runSync(ctx, targets, opts)
 	case OperationPipeline:
-		return p.runPipeline(ctx, targets, opts)
+		results, err = p.runPipeline(ctx, targets, opts)
 	default:
 		return nil, fmt.Errorf("unknown operation: %s", opts.Operation)
 	}
+
+	// List the names a dry run would write secrets under
+	if opts.DryRun && opts.Operation != OperationMerge {
+		p.recordNamePreview(ctx, targets)
+	}
+
+	for _, r := range results {
+		// Targets cancelled before they started have no phase
+		if r.Phase != "" {
+			metrics.RegisterPipelineResult(r.Target, r.Phase, r.Success, r.Duration)
+		}
+	}
+
+	if id := p.ResumeID(); id != "" {
+		for _, r := range results {
+			if !r.Success {
+				l.WithField("run", id).Warnf("Retry the failed targets with: vss pipeline --resume %s", id)
+				break
+			}
+		}
+	}
+
+	if degraded := DegradedDestinations(results); len(degraded) > 0 {
+		l.WithField("skipped", degraded).Warnf("Degraded run: %d destinations known to be down were skipped", len(degraded))
+	}
+
+	// Send the run's notification digest now instead of waiting out its window
+	diffSummary := ""
+	if opts.DryRun || opts.ComputeDiff {
+		diffSummary = p.FormatDiff(diff.OutputFormatCompact)
+	}
+	if notifyErr := notifications.FlushDigest(ctx, diffSummary); notifyErr != nil {
+		l.WithError(notifyErr).Error("Failed to send notification digest")
+	}
+
+	runPayload.Event = HookPostRun
+	_ = p.runHooks(ctx, runPayload.withPostCounts(results, started, err))
+
+	rec := newRunRecord(opts, p.graph, started, time.Now(), results, err)
+	if h := p.config.Pipeline.History; h != nil && h.Dir != "" {
+		if histErr := WriteRunRecord(h.Dir, h.Keep, rec); histErr != nil {
+			l.WithError(histErr).Error("Failed to record run history")
+		}
+	}
+	// An unsigned run cannot be audited, so a manifest failure fails the run
+	if m := p.config.Pipeline.Manifest; m != nil {
+		path, manifestErr := p.writeRunManifest(ctx, m, rec, opts.Provenance)
+		if manifestErr != nil {
+			l.WithError(manifestErr).Error("Failed to write run manifest")
+			err = errors.Join(err, fmt.Errorf("run manifest: %w", manifestErr))
+		} else {
+			l.WithField("manifest", path).Info("Run manifest written")
+		}
+	}
+	outcome := notification.withOutcome(results, err)
+	if opts.DryRun || opts.ComputeDiff {
+		outcome = outcome.withDiff(p.Diff())
+	}
+	p.notify(ctx, outcome)
+	return results, err
 }
 
 // initialize sets up the sync infrastructure
@@ -289,6 +730,7 @@ func (p *Pipeline) initialize(ctx context.Context) error {
 
 	// Initialize ManualTrigger
 	backend.ManualTrigger = internalSync.ManualTrigger
+	backend.ManualTriggerAndWait = internalSync.ManualTriggerAndWait
 
 	// Initialize queue
 	if queue.Q == nil {
@@ -300,20 +742,27 @@ func (p *Pipeline) initialize(ctx context.Context) error {
 	// Set default stores
 	p.setDefaultStores()
 
-	// Start event processor
+	if p.readiness == nil {
+		p.readiness = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+
+	// Start event processor and wait until it is consuming
+	workerPoolSize := p.config.Pipeline.Merge.Parallel
+	if workerPoolSize <= 0 {
+		workerPoolSize = 4
+	}
+	ready := make(chan struct{})
+	errCh := make(chan error, 1)
 	go func() {
-		workerPoolSize := p.config.Pipeline.Merge.Parallel
-		if workerPoolSize <= 0 {
-			workerPoolSize = 4
-		}
-		if err := internalSync.EventProcessor(ctx, workerPoolSize, workerPoolSize); err != nil {
-			l.WithError(err).Error("Event processor exited")
-		}
+		errCh <- internalSync.EventProcessorWithReady(ctx, workerPoolSize, workerPoolSize, ready)
 	}()
-
-	// Allow processor to start
-	// TODO: Replace with proper synchronization - EventProcessor should signal readiness via channel
-	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-ready:
+	case err := <-errCh:
+		return fmt.Errorf("failed to start event processor: %w", err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 
 	p.initialized = true
 	l.Info("Pipeline infrastructure initialized")
@@ -334,12 +783,37 @@ func (p *Pipeline) setDefaultStores() {
 	internalSync.SetStoreDefaults(stores)
 }
 
-// resolveTargets returns the targets to process, including dependencies
-func (p *Pipeline) resolveTargets(requested []string) []string {
-	if len(requested) == 0 {
+// resolveTargets returns the targets to process: the requested targets with
+// their dependencies (unless noDeps), plus the targets the selector picks
+func (p *Pipeline) resolveTargets(requested []string, sel TargetSelector, noDeps bool) []string {
+	if len(requested) == 0 && sel.IsZero() {
 		return p.graph.TopologicalOrder()
 	}
-	return p.graph.IncludeDependencies(requested)
+	if !noDeps && sel.IsZero() {
+		return p.graph.IncludeDependencies(requested)
+	}
+	candidates := requested
+	if !noDeps {
+		candidates = p.graph.IncludeDependencies(requested)
+	}
+	if !sel.IsZero() {
+		candidates = append(append([]string(nil), candidates...), p.graph.SelectTargets(p.config, sel)...)
+	}
+	var targets []string
+	for _, name := range candidates {
+		if !containsString(targets, name) {
+			targets = append(targets, name)
+		}
+	}
+	// Keep dependency order across both sets
+	sort.Slice(targets, func(i, j int) bool {
+		li, lj := p.graph.Nodes[targets[i]].Level, p.graph.Nodes[targets[j]].Level
+		if li != lj {
+			return li < lj
+		}
+		return targets[i] < targets[j]
+	})
+	return targets
 }
 
 // runMerge executes only the merge phase
@@ -350,7 +824,7 @@ func (p *Pipeline) runMerge(ctx context.Context, targets []string, opts Options)
 	})
 	l.Info("Starting merge phase")
 
-	results, err := p.executeMergePhase(ctx, targets, opts)
+	results, err := p.runPhaseWithHooks(ctx, "merge", targets, opts, p.executeMergePhase)
 	p.resultsMu.Lock()
 	p.results = results
 	p.resultsMu.Unlock()
@@ -365,7 +839,7 @@ func (p *Pipeline) runSync(ctx context.Context, targets []string, opts Options)
 	})
 	l.Info("Starting sync phase")
 
-	results, err := p.executeSyncPhase(ctx, targets, opts)
+	results, err := p.runPhaseWithHooks(ctx, "sync", targets, opts, p.executeSyncPhase)
 	p.resultsMu.Lock()
 	p.results = results
 	p.resultsMu.Unlock()
@@ -384,10 +858,12 @@ func (p *Pipeline) runPipeline(ctx context.Context, targets []string, opts Optio
 
 	// Merge phase
 	l.Info("Phase 1: Merge")
-	mergeResults, mergeErr := p.executeMergePhase(ctx, targets, opts)
+	mergeResults, mergeErr := p.runPhaseWithHooks(ctx, "merge", targets, opts, p.executeMergePhase)
 	allResults = append(allResults, mergeResults...)
 
-	if mergeErr != nil && !opts.ContinueOnError {
+	// A required pre_merge hook failing stops the run, not just the phase
+	var hookErr *HookError
+	if mergeErr != nil && (!opts.ContinueOnError || errors.As(mergeErr, &hookErr)) {
 		p.resultsMu.Lock()
 		p.results = allResults
 		p.resultsMu.Unlock()
@@ -396,7 +872,7 @@ func (p *Pipeline) runPipeline(ctx context.Context, targets []string, opts Optio
 
 	// Sync phase
 	l.Info("Phase 2: Sync")
-	syncResults, syncErr := p.executeSyncPhase(ctx, targets, opts)
+	syncResults, syncErr := p.runPhaseWithHooks(ctx, "sync", targets, opts, p.executeSyncPhase)
 	allResults = append(allResults, syncResults...)
 
 	p.resultsMu.Lock()
@@ -440,8 +916,18 @@ func (p *Pipeline) executeMergePhase(ctx context.Context, targets []string, opts
 		}).Debug("Processing merge level")
 
 		// Execute level in parallel
-		levelResults := p.executeParallel(ctx, levelTargets, opts.Parallelism, func(target string) Result {
-			return p.mergeTarget(ctx, target, opts.DryRun)
+		parallel := p.config.Pipeline.Merge.levelParallelism(levelIdx, opts.Parallelism)
+		levelResults := p.executeParallel(ctx, levelTargets, parallel, func(target string) Result {
+			if r, ok := p.runState.skip(ctx, "merge", target, p.graph.Nodes[target].Deps); ok {
+				return r
+			}
+			r := p.runTargetWithHooks(ctx, "merge", target, opts, func() Result {
+				return p.runWithRetry(ctx, "merge", target, func() Result {
+					return p.mergeTarget(ctx, target, opts.DryRun)
+				})
+			})
+			p.runState.done(ctx, r)
+			return r
 		})
 
 		results = append(results, levelResults...)
@@ -463,7 +949,16 @@ func (p *Pipeline) executeMergePhase(ctx context.Context, targets []string, opts
 // executeSyncPhase runs sync operations (can be fully parallel)
 func (p *Pipeline) executeSyncPhase(ctx context.Context, targets []string, opts Options) ([]Result, error) {
 	results := p.executeParallel(ctx, targets, opts.Parallelism, func(target string) Result {
-		return p.syncTarget(ctx, target, opts.DryRun)
+		if r, ok := p.runState.skip(ctx, "sync", target, nil); ok {
+			return r
+		}
+		r := p.runTargetWithHooks(ctx, "sync", target, opts, func() Result {
+			return p.runWithRetry(ctx, "sync", target, func() Result {
+				return p.syncTarget(ctx, target, opts.DryRun)
+			})
+		})
+		p.runState.done(ctx, r)
+		return r
 	})
 
 	var lastErr error
@@ -479,40 +974,6 @@ func (p *Pipeline) executeSyncPhase(ctx context.Context, targets []string, opts
 	return results, lastErr
 }
 
-// executeParallel runs a function for each target with limited concurrency
-func (p *Pipeline) executeParallel(ctx context.Context, targets []string, maxParallel int, fn func(string) Result) []Result {
-	if maxParallel <= 0 {
-		maxParallel = 1
-	}
-
-	results := make([]Result, len(targets))
-	sem := make(chan struct{}, maxParallel)
-	var wg sync.WaitGroup
-
-	for i, target := range targets {
-		select {
-		case <-ctx.Done():
-			results[i] = Result{
-				Target:  target,
-				Success: false,
-				Error:   ctx.Err(),
-			}
-			continue
-		case sem <- struct{}{}:
-		}
-
-		wg.Add(1)
-		go func(idx int, t string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-			results[idx] = fn(t)
-		}(i, target)
-	}
-
-	wg.Wait()
-	return results
-}
-
 // mergeTarget executes merge operations for a single target
 func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bool) Result {
 	start := time.Now()
@@ -533,12 +994,14 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 		}
 	}
 
-	// Determine merge path based on merge store type
+	// Determine merge path based on merge store type. Staged targets merge
+	// into their staged snapshot until it activates.
+	storeName := p.config.mergeStoreName(targetName, time.Now())
 	var mergePath string
 	if p.config.MergeStore.Vault != nil {
-		mergePath = fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName)
-	} else if p.s3Store != nil {
-		mergePath = p.s3Store.GetMergePath(targetName)
+		mergePath = fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, storeName)
+	} else if store := p.directStore(); store != nil {
+		mergePath = store.GetMergePath(storeName)
 	} else {
 		return Result{
 			Target:   targetName,
@@ -548,25 +1011,91 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 			Duration: time.Since(start),
 		}
 	}
+	if storeName != targetName {
+		l = l.WithField("activateAt", target.Staging.ActivateAt)
+	}
 	l.WithField("mergePath", mergePath).Info("Starting merge")
 
 	var sourcePaths []string
 	var failedImports []string
+	var failedPaths []string
 	var lastErr error
 	successCount := 0
+	// S3 and GCP merges are built in memory, import by import
+	merged := make(map[string]map[string]interface{})
+	// So are dry runs' Vault merges, for the sync phase to diff against
+	var preview mergeStore
+	if p.config.MergeStore.Vault != nil {
+		store, current, err := p.previewVaultMerge(ctx, storeName, dryRun)
+		if err != nil {
+			l.WithError(err).Warn("Cannot preview merge, diffs will compare against the current merged secrets")
+		} else if store != nil {
+			preview, merged = store, current
+		}
+	}
 
-	for _, importName := range target.Imports {
+	for _, imp := range target.Imports {
+		ref, err := ParseImportRef(imp)
+		if err != nil {
+			l.WithError(err).WithField("import", imp).Error("Invalid import")
+			failedImports = append(failedImports, imp)
+			lastErr = err
+			continue
+		}
+		importName := ref.Name
 		sourcePath := p.config.GetSourcePath(importName)
 		sourcePaths = append(sourcePaths, sourcePath)
 
 		l.WithFields(log.Fields{
 			"import":     importName,
+			"version":    ref.Version,
 			"sourcePath": sourcePath,
 		}).Debug("Processing import")
 
+		// Doppler sources are read directly and written into the merge store
+		if src, ok := p.config.Sources[importName]; ok && src.Doppler != nil {
+			if err := p.mergeDopplerSource(ctx, src.Doppler, src.Transforms, storeName, mergePath, dryRun); err != nil {
+				l.WithError(err).WithField("import", importName).Error("Failed to merge Doppler source")
+				failedImports = append(failedImports, importName)
+				lastErr = err
+				continue
+			}
+			successCount++
+			continue
+		}
+
 		// Use Vault merge store (standard path)
 		if p.config.MergeStore.Vault != nil {
-			syncConfig := p.createMergeSync(importName, targetName, sourcePath, mergePath, dryRun)
+			syncConfig := p.createMergeSync(ref, targetName, sourcePath, mergePath, dryRun)
+			owners := append([]string(nil), target.Owners...)
+			for _, owner := range p.config.Owners(importName) {
+				owners = appendUniqueString(owners, owner)
+			}
+			setOwners(&syncConfig, owners)
+			if src, ok := p.config.Sources[importName]; ok {
+				src.Transforms.applyTo(&syncConfig)
+			}
+
+			// The sync engine walks the whole mount, so a source's paths and
+			// filters are resolved here and passed on as an exact path list
+			if src, ok := p.config.Sources[importName]; ok && src.Vault != nil {
+				syncConfig.Spec.Source.ListPageSize = src.Vault.PageSize
+				if src.Vault.filtered() {
+					paths, err := p.selectVaultPaths(ctx, src.Vault)
+					if err != nil {
+						l.WithError(err).WithField("import", importName).Error("Failed to list source")
+						failedImports = append(failedImports, importName)
+						lastErr = err
+						continue
+					}
+					if len(paths) == 0 {
+						l.WithField("import", importName).Warn("Source paths and filters select no secrets")
+						successCount++
+						continue
+					}
+					syncConfig.Spec.Filters = &v1alpha1.FilterConfig{Path: &v1alpha1.PathFilterConfig{Include: paths}}
+				}
+			}
 
 			if err := backend.AddSyncConfig(syncConfig); err != nil {
 				l.WithError(err).WithField("import", importName).Error("Failed to add sync config")
@@ -575,39 +1104,58 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 				continue
 			}
 
-			if err := backend.ManualTrigger(ctx, syncConfig, logical.UpdateOperation); err != nil {
-				l.WithError(err).WithField("import", importName).Error("Failed to trigger merge")
+			// Imports merge in order, so each must finish before the next starts
+			completion, err := backend.ManualTriggerAndWait(ctx, syncConfig, logical.UpdateOperation)
+			if err != nil {
+				l.WithError(err).WithField("import", importName).Error("Merge failed")
 				failedImports = append(failedImports, importName)
+				failedPaths = append(failedPaths, completionFailures(completion)...)
 				lastErr = err
 				continue
 			}
+			if preview != nil {
+				if err := p.previewImport(ctx, preview, ref, merged); err != nil {
+					l.WithError(err).WithField("import", importName).Warn("Cannot preview merge, diffs will compare against the current merged secrets")
+					preview = nil
+				}
+			}
 		}
 
-		// Use S3 merge store
-		if p.s3Store != nil && !dryRun {
-			// For S3, we need to read secrets from Vault and write to S3
-			// This is a simplified implementation - in production you'd want
-			// to properly read the secret data from the source
-			secretData := map[string]interface{}{
-				"_source":    importName,
-				"_target":    targetName,
-				"_timestamp": time.Now().UTC().Format(time.RFC3339),
-			}
-			if err := p.s3Store.WriteSecret(ctx, targetName, importName, secretData); err != nil {
-				l.WithError(err).WithField("import", importName).Error("Failed to write to S3 merge store")
+		// Use S3 or GCP Secret Manager merge store
+		if store := p.directStore(); store != nil {
+			secrets, err := p.readImport(ctx, store, importName)
+			if err != nil {
+				l.WithError(err).WithField("import", importName).Error("Failed to read import")
 				failedImports = append(failedImports, importName)
 				lastErr = err
 				continue
 			}
+			mergeSecrets(merged, secrets)
 		}
 
 		successCount++
 	}
 
-	// Allow time for async processing (only for Vault merge store)
-	// TODO: Replace with proper synchronization mechanism (channels/WaitGroups)
-	if p.config.MergeStore.Vault != nil {
-		time.Sleep(time.Duration(len(target.Imports)*300) * time.Millisecond)
+	// A partial merge would replace good merged secrets with ones missing the
+	// failed imports' keys, so the previous output is kept instead
+	if store := p.directStore(); store != nil {
+		switch {
+		case lastErr != nil:
+			l.Warn("Not writing merged secrets because an import failed")
+		case dryRun:
+			l.WithField("secrets", len(merged)).Info("Dry run: would write merged secrets")
+			p.pendingMerge(storeName, merged)
+		default:
+			failed, err := writeMerged(ctx, store, storeName, merged)
+			failedPaths = append(failedPaths, failed...)
+			if err != nil {
+				lastErr = err
+			}
+		}
+	}
+
+	if preview != nil && lastErr == nil {
+		p.pendingMerge(storeName, merged)
 	}
 
 	success := lastErr == nil
@@ -629,11 +1177,12 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 			SourcePaths:      sourcePaths,
 			DestinationPath:  mergePath,
 			FailedImports:    failedImports,
+			FailedPaths:      failedPaths,
 		},
 	}
 }
 
-// syncTarget syncs merged secrets to AWS for a single target
+// syncTarget syncs merged secrets to each of a target's destinations
 func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun bool) Result {
 	start := time.Now()
 	l := log.WithFields(log.Fields{
@@ -653,81 +1202,249 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 		}
 	}
 
-	roleARN := p.config.GetRoleARN(target.AccountID)
-
-	// Determine source path based on merge store type
-	var sourcePath string
-	if p.config.MergeStore.Vault != nil {
-		sourcePath = fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName)
-	} else if p.s3Store != nil {
-		sourcePath = p.s3Store.GetMergePath(targetName)
-	} else {
+	// Secrets also wait in the merge store until the target is approved
+	if p.pendingApproval[targetName] {
+		l.Warn("Target is pending approval, skipping sync")
 		return Result{
 			Target:   targetName,
 			Phase:    "sync",
-			Success:  false,
-			Error:    fmt.Errorf("no merge store configured"),
+			Success:  true,
 			Duration: time.Since(start),
+			Details:  ResultDetails{PendingApproval: true},
 		}
 	}
 
-	region := target.Region
-	if region == "" {
-		region = p.config.AWS.Region
-	}
-
-	l.WithFields(log.Fields{
-		"accountID":  target.AccountID,
-		"roleARN":    roleARN,
-		"sourcePath": sourcePath,
-		"region":     region,
-	}).Info("Starting sync to AWS")
-
-	// Create and execute sync
-	syncConfig := p.createAWSSync(targetName, sourcePath, roleARN, region, dryRun)
-
-	if err := backend.AddSyncConfig(syncConfig); err != nil {
+	// Secrets wait in the merge store until the target is ready for them
+	if err := checkPreconditions(ctx, p.readiness, targetName, target); err != nil {
+		l.WithError(err).Warn("Target preconditions not met, skipping sync")
 		return Result{
 			Target:   targetName,
 			Phase:    "sync",
 			Success:  false,
-			Error:    fmt.Errorf("failed to add sync config: %w", err),
+			Error:    err,
 			Duration: time.Since(start),
 		}
 	}
 
-	if err := backend.ManualTrigger(ctx, syncConfig, logical.UpdateOperation); err != nil {
+	// Envelope targets receive ciphertext and a decrypt grant, not values
+	if target.Envelope != nil {
+		return p.syncEnvelope(ctx, targetName, target, dryRun)
+	}
+
+	sourcePath, err := p.syncSourcePath(targetName)
+	if err != nil {
 		return Result{
 			Target:   targetName,
 			Phase:    "sync",
 			Success:  false,
-			Error:    fmt.Errorf("failed to trigger sync: %w", err),
+			Error:    err,
 			Duration: time.Since(start),
 		}
 	}
 
-	// Allow time for async processing
-	// TODO: Replace with proper synchronization - ManualTrigger should return completion signal
-	time.Sleep(500 * time.Millisecond)
+	// Each destination's outcome, in order; up to target.Parallelism are
+	// synced at once
+	type destOutcome struct {
+		result      DestinationResult
+		skipped     bool
+		unchanged   bool
+		fingerprint string
+		diff        *diff.TargetDiff
+		failedPaths []string
+		unverified  []string
+		err         error
+	}
+	dests := target.ResolvedDestinations()
+	outcomes := make([]destOutcome, len(dests))
+	content, synced := p.unchanged.load(ctx, targetName)
+	var snapshot map[string]interface{}
+	var snapshotErr error
+	if p.diffing() {
+		snapshot, snapshotErr = p.syncSnapshot(ctx, targetName)
+	}
+	p.runBounded(target.Parallelism, len(dests), func(i int) {
+		dest := dests[i]
+		label := dest.Label()
+		// Known-down destinations are left for a later run instead of failing this one
+		if h, down := p.destHealth.down(targetName, label); down {
+			l.WithFields(log.Fields{
+				"destination": label,
+				"since":       h.Since,
+				"probeError":  h.Error,
+			}).Warn("Destination is down, skipping sync")
+			outcomes[i] = destOutcome{result: DestinationResult{Name: label, Skipped: true, Error: "destination down: " + h.Error}, skipped: true}
+			return
+		}
+		fingerprint := syncFingerprint(content, target, dest, p.config.destinationRegion(target, dest))
+		if p.unchanged.unchanged(synced, label, fingerprint) {
+			l.WithField("destination", label).Info("Destination was last synced with the same secrets and config, skipping sync")
+			outcomes[i] = destOutcome{result: DestinationResult{Name: label, Success: true, Unchanged: true}, unchanged: true, fingerprint: fingerprint}
+			return
+		}
+		syncConfig, roleARN := p.destinationSync(targetName, sourcePath, target, dest, dryRun)
+		if len(dests) > 1 {
+			syncConfig.Name = fmt.Sprintf("sync-%s-%d", targetName, i)
+		}
+
+		l.WithFields(log.Fields{
+			"destination": label,
+			"roleARN":     roleARN,
+			"sourcePath":  sourcePath,
+		}).Info("Starting sync to destination")
+
+		dr := DestinationResult{Name: label, Success: true, RoleARN: roleARN}
+		// A dry run is run for its diff, so one that cannot be computed fails it
+		td, err := p.syncDiff(ctx, targetName, sourcePath, target, dest, snapshot, snapshotErr)
+		if err != nil && dryRun {
+			l.WithField("destination", label).WithError(err).Error("Failed to compute diff")
+			dr.Success = false
+			dr.Error = err.Error()
+			outcomes[i] = destOutcome{result: dr, err: err}
+			return
+		} else if err != nil {
+			l.WithField("destination", label).WithError(err).Warn("Failed to compute diff, syncing without one")
+		}
+		completion, err := p.triggerSync(ctx, targetName, syncConfig)
+		if err != nil {
+			l.WithField("destination", label).WithError(err).Error("Sync to destination failed")
+			dr.Success = false
+			dr.Error = err.Error()
+			unverified := completionUnverified(completion)
+			dr.Unverified = len(unverified) > 0
+			outcomes[i] = destOutcome{result: dr, failedPaths: completionFailures(completion), unverified: unverified, err: err}
+			return
+		}
+		dr.Diffed = td != nil
+		outcomes[i] = destOutcome{result: dr, fingerprint: fingerprint, diff: td}
+	})
+
+	destResults := make([]DestinationResult, 0, len(dests))
+	var failed []string
+	var failedPaths []string
+	var unverified []string
+	var skipped []string
+	var lastErr error
+	unchanged := 0
+	nowSynced := make(map[string]string)
+	var diffs []diff.TargetDiff
+	for _, o := range outcomes {
+		destResults = append(destResults, o.result)
+		if o.diff != nil {
+			td := *o.diff
+			if len(dests) > 1 {
+				td.Target = fmt.Sprintf("%s (%s)", targetName, o.result.Name)
+			}
+			p.addTargetDiff(td)
+			diffs = append(diffs, td)
+		}
+		switch {
+		case o.skipped:
+			skipped = append(skipped, o.result.Name)
+			if fp, ok := synced[o.result.Name]; ok {
+				nowSynced[o.result.Name] = fp
+			}
+		case o.err != nil:
+			lastErr = o.err
+			failed = append(failed, o.result.Name)
+			failedPaths = append(failedPaths, o.failedPaths...)
+			unverified = append(unverified, o.unverified...)
+		default:
+			if o.unchanged {
+				unchanged++
+			}
+			if o.fingerprint != "" {
+				nowSynced[o.result.Name] = o.fingerprint
+			}
+		}
+	}
+	// Dry runs sync nothing, so there is nothing new to record
+	if content != "" && !dryRun && unchanged < len(dests) {
+		p.unchanged.save(ctx, targetName, nowSynced)
+	}
 
 	l.WithField("duration", time.Since(start)).Info("Sync completed")
 
-	return Result{
+	result := Result{
 		Target:    targetName,
 		Phase:     "sync",
 		Operation: string(OperationSync),
-		Success:   true,
+		Success:   len(failed) == 0,
 		Duration:  time.Since(start),
 		Details: ResultDetails{
-			SourcePaths:     []string{sourcePath},
-			DestinationPath: fmt.Sprintf("aws:%s", target.AccountID),
-			RoleARN:         roleARN,
+			SourcePaths:          []string{p.mergePath(targetName, sourcePath)},
+			FailedPaths:          failedPaths,
+			SkippedDestinations:  skipped,
+			VerificationFailures: unverified,
+			Unchanged:            unchanged == len(dests),
 		},
+		Diff: combineDiffs(targetName, p.config.Owners(targetName), diffs),
+	}
+	if len(target.Destinations) > 0 {
+		result.Details.Destinations = destResults
+	} else {
+		result.Details.DestinationPath = destResults[0].Name
+		result.Details.RoleARN = destResults[0].RoleARN
+	}
+	if len(failed) == 1 && len(dests) == 1 {
+		result.Error = lastErr
+	} else if len(failed) > 0 {
+		result.Error = fmt.Errorf("sync failed for %d of %d destinations: %s", len(failed), len(dests), strings.Join(failed, ", "))
+	}
+	return result
+}
+
+// triggerSync registers a destination's sync config and runs it to completion
+func (p *Pipeline) triggerSync(ctx context.Context, targetName string, sc v1alpha1.VaultSecretSync) (*backend.SyncCompletion, error) {
+	if p.runSyncConfig != nil {
+		completion, err := p.runSyncConfig(ctx, targetName, sc)
+		if err != nil {
+			return completion, fmt.Errorf("sync failed: %w", err)
+		}
+		return completion, nil
+	}
+	// The engine reads S3 and GCP merge stores through the pipeline
+	if store := p.directStore(); store != nil {
+		internalSync.SetSource(sc.Namespace, sc.Name, newMergeStoreSource(store, targetName))
+		defer internalSync.SetSource(sc.Namespace, sc.Name, nil)
+	}
+	if err := backend.AddSyncConfig(sc); err != nil {
+		return nil, fmt.Errorf("failed to add sync config: %w", err)
+	}
+	completion, err := backend.ManualTriggerAndWait(ctx, sc, logical.UpdateOperation)
+	if err != nil {
+		return completion, fmt.Errorf("sync failed: %w", err)
+	}
+	return completion, nil
+}
+
+// completionFailures describes each failed path of a completed sync as
+// "source -> driver:destination: error"
+func completionFailures(c *backend.SyncCompletion) []string {
+	if c == nil {
+		return nil
+	}
+	var failures []string
+	for _, p := range c.Failed() {
+		failures = append(failures, fmt.Sprintf("%s -> %s:%s: %v", p.SourcePath, p.Driver, p.DestPath, p.Error))
+	}
+	return failures
+}
+
+// completionUnverified describes each path of a completed sync that failed
+// read-back verification, like completionFailures
+func completionUnverified(c *backend.SyncCompletion) []string {
+	if c == nil {
+		return nil
+	}
+	var unverified []string
+	for _, p := range c.Unverified() {
+		unverified = append(unverified, fmt.Sprintf("%s -> %s:%s: %v", p.SourcePath, p.Driver, p.DestPath, p.Error))
 	}
+	return unverified
 }
 
 // createMergeSync creates a VaultSecretSync for merging sources
-func (p *Pipeline) createMergeSync(importName, targetName, sourcePath, mergePath string, dryRun bool) v1alpha1.VaultSecretSync {
+func (p *Pipeline) createMergeSync(ref ImportRef, targetName, sourcePath, mergePath string, dryRun bool) v1alpha1.VaultSecretSync {
 	sync := v1alpha1.VaultSecretSync{
 		Spec: v1alpha1.VaultSecretSyncSpec{
 			DryRun:     boolPtr(dryRun),
@@ -736,6 +1453,7 @@ func (p *Pipeline) createMergeSync(importName, targetName, sourcePath, mergePath
 				Address:   p.config.Vault.Address,
 				Namespace: p.config.Vault.Namespace,
 				Path:      fmt.Sprintf("%s/(.*)", sourcePath),
+				Version:   ref.Version,
 			},
 			Dest: []*v1alpha1.StoreConfig{
 				{
@@ -749,7 +1467,7 @@ func (p *Pipeline) createMergeSync(importName, targetName, sourcePath, mergePath
 			},
 		},
 	}
-	sync.Name = fmt.Sprintf("merge-%s-to-%s", importName, targetName)
+	sync.Name = fmt.Sprintf("merge-%s-to-%s", ref.Name, targetName)
 	sync.Namespace = "pipeline"
 	return sync
 }
@@ -805,6 +1523,12 @@ func (p *Pipeline) Diff() *diff.PipelineDiff {
 	return p.pipelineDiff
 }
 
+// diffHasher hashes secret values in diffs with pipeline.diff_hash_salt, or
+// is nil (no hashes) without one
+func (p *Pipeline) diffHasher() *diff.Hasher {
+	return diff.NewHasher(p.config.Pipeline.DiffHashSalt)
+}
+
 // initDiff initializes diff tracking for a run
 func (p *Pipeline) initDiff(dryRun bool, configPath string) {
 	p.diffMu.Lock()
@@ -813,10 +1537,10 @@ func (p *Pipeline) initDiff(dryRun bool, configPath string) {
 		DryRun:     dryRun,
 		ConfigPath: configPath,
 	}
+	p.pendingMerges = nil
 }
 
 // addTargetDiff adds a target diff to the pipeline diff
-// nolint:unused // Will be used when diff tracking is fully integrated into merge/sync phases
 func (p *Pipeline) addTargetDiff(td diff.TargetDiff) {
 	p.diffMu.Lock()
 	defer p.diffMu.Unlock()
@@ -827,12 +1551,18 @@ func (p *Pipeline) addTargetDiff(td diff.TargetDiff) {
 
 // FormatDiff returns the formatted diff output
 func (p *Pipeline) FormatDiff(format diff.OutputFormat) string {
+	return p.FormatDiffLines(format, diff.DefaultMaxLines)
+}
+
+// FormatDiffLines returns the formatted diff output, sampling human and
+// GitHub output down to maxLines (0 for no limit)
+func (p *Pipeline) FormatDiffLines(format diff.OutputFormat, maxLines int) string {
 	p.diffMu.Lock()
 	defer p.diffMu.Unlock()
 	if p.pipelineDiff == nil {
 		return ""
 	}
-	return diff.FormatDiff(p.pipelineDiff, format)
+	return diff.FormatDiffLines(p.pipelineDiff, format, maxLines)
 }
 
 // ExitCode returns the appropriate exit code based on diff results
@@ -853,28 +1583,28 @@ func (p *Pipeline) ExitCode() int {
 	p.resultsMu.Unlock()
 	
 	if hasErrors {
-		return 2
+		return diff.ExitCodeError
 	}
 	
 	if p.pipelineDiff != nil {
 		return p.pipelineDiff.ExitCode()
 	}
 	
-	return 0
+	return diff.ExitCodeNoChanges
 }
 
 // GenerateConfigs generates VaultSecretSync configs without executing them
 // Useful for GitOps workflows or Kubernetes CRD generation
-// Note: S3 merge store doesn't generate VaultSecretSync configs (it's handled differently)
+// Note: S3 and GCP merge stores don't generate VaultSecretSync configs (they're handled differently)
 func (p *Pipeline) GenerateConfigs(opts Options) ([]v1alpha1.VaultSecretSync, error) {
 	var configs []v1alpha1.VaultSecretSync
 
-	// S3 merge store doesn't use VaultSecretSync for the merge phase
+	// S3 and GCP merge stores don't use VaultSecretSync for the merge phase
 	if p.config.MergeStore.Vault == nil {
-		log.Warn("GenerateConfigs only supports Vault merge store; S3 merge store operations are handled inline")
+		log.Warn("GenerateConfigs only supports Vault merge store; S3 and GCP merge store operations are handled inline")
 	}
 
-	targets := p.resolveTargets(opts.Targets)
+	targets := p.resolveTargets(opts.Targets, opts.Selector, opts.NoDeps)
 
 	// Generate merge configs (only for Vault merge store)
 	if (opts.Operation == OperationMerge || opts.Operation == OperationPipeline) && p.config.MergeStore.Vault != nil {
@@ -882,9 +1612,13 @@ func (p *Pipeline) GenerateConfigs(opts Options) ([]v1alpha1.VaultSecretSync, er
 			target := p.config.Targets[targetName]
 			mergePath := fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName)
 
-			for _, importName := range target.Imports {
-				sourcePath := p.config.GetSourcePath(importName)
-				cfg := p.createMergeSync(importName, targetName, sourcePath, mergePath, opts.DryRun)
+			for _, imp := range target.Imports {
+				ref, err := ParseImportRef(imp)
+				if err != nil {
+					return nil, fmt.Errorf("target %q: %w", targetName, err)
+				}
+				sourcePath := p.config.GetSourcePath(ref.Name)
+				cfg := p.createMergeSync(ref, targetName, sourcePath, mergePath, opts.DryRun)
 				configs = append(configs, cfg)
 			}
 		}
@@ -894,26 +1628,26 @@ func (p *Pipeline) GenerateConfigs(opts Options) ([]v1alpha1.VaultSecretSync, er
 	if opts.Operation == OperationSync || opts.Operation == OperationPipeline {
 		for _, targetName := range targets {
 			target := p.config.Targets[targetName]
-			roleARN := p.config.GetRoleARN(target.AccountID)
 
 			// Determine source path based on merge store
 			var sourcePath string
 			if p.config.MergeStore.Vault != nil {
 				sourcePath = fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName)
-			} else if p.config.MergeStore.S3 != nil {
-				// S3 merge store - sync configs would need to read from S3
+			} else if p.config.MergeStore.S3 != nil || p.config.MergeStore.GCP != nil {
+				// S3 and GCP merge stores - sync configs would need to read from the store
 				// This is a limitation: VaultSecretSync expects Vault as source
-				log.WithField("target", targetName).Warn("S3 merge store sync requires custom handling")
+				log.WithField("target", targetName).Warn("S3 and GCP merge store sync requires custom handling")
 				continue
 			}
 
-			region := target.Region
-			if region == "" {
-				region = p.config.AWS.Region
+			dests := target.ResolvedDestinations()
+			for i, dest := range dests {
+				cfg, _ := p.destinationSync(targetName, sourcePath, target, dest, opts.DryRun)
+				if len(dests) > 1 {
+					cfg.Name = fmt.Sprintf("sync-%s-%d", targetName, i)
+				}
+				configs = append(configs, cfg)
 			}
-
-			cfg := p.createAWSSync(targetName, sourcePath, roleARN, region, opts.DryRun)
-			configs = append(configs, cfg)
 		}
 	}
 
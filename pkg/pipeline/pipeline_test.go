@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Options)
+		option string
+	}{
+		{name: "defaults", modify: func(*Options) {}},
+		{name: "unknown operation", modify: func(o *Options) { o.Operation = "deploy" }, option: "operation"},
+		{name: "empty operation", modify: func(o *Options) { o.Operation = "" }, option: "operation"},
+		{name: "negative parallelism", modify: func(o *Options) { o.Parallelism = -1 }, option: "parallelism"},
+		{name: "unknown output format", modify: func(o *Options) { o.OutputFormat = "yaml" }, option: "output format"},
+		{name: "empty target", modify: func(o *Options) { o.Targets = []string{"Stg", " "} }, option: "targets"},
+		{name: "negative level", modify: func(o *Options) { o.Selector.Levels = []int{-1} }, option: "levels"},
+		{name: "override without reason", modify: func(o *Options) { o.FreezeOverride = &FreezeOverride{By: "alice"} }, option: "freeze override"},
+		{name: "no-deps without targets", modify: func(o *Options) { o.NoDeps = true }, option: "no-deps"},
+		{name: "resume with targets", modify: func(o *Options) { o.Resume = "20260301T120000.000000000Z"; o.Targets = []string{"Stg"} }, option: "resume"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.modify(&opts)
+			err := opts.Validate()
+			if tt.option == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var oe *OptionError
+			require.True(t, errors.As(err, &oe), "expected *OptionError, got %v", err)
+			assert.Equal(t, tt.option, oe.Option)
+		})
+	}
+}
+
+func TestParseOperation(t *testing.T) {
+	op, err := ParseOperation(" Merge ")
+	require.NoError(t, err)
+	assert.Equal(t, OperationMerge, op)
+
+	_, err = ParseOperation("apply")
+	assert.ErrorContains(t, err, "must be merge, sync or pipeline")
+}
+
+func TestRunRejectsUnknownTarget(t *testing.T) {
+	p := &Pipeline{config: &Config{Targets: map[string]Target{"Stg": {}}}}
+	opts := DefaultOptions()
+	opts.Targets = []string{"Prod"}
+
+	_, err := p.Run(context.Background(), opts)
+	var oe *OptionError
+	require.True(t, errors.As(err, &oe), "expected *OptionError, got %v", err)
+	assert.Equal(t, `invalid target "Prod": not found in configuration`, err.Error())
+}
+
+func TestSyncTargetWaitsForCompletion(t *testing.T) {
+	wait := backend.ManualTriggerAndWait
+	t.Cleanup(func() { backend.ManualTriggerAndWait = wait })
+
+	var synced []string
+	backend.ManualTriggerAndWait = func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*backend.SyncCompletion, error) {
+		synced = append(synced, cfg.Name)
+		completion := &backend.SyncCompletion{Name: cfg.Name, Paths: []backend.PathResult{
+			{SourcePath: "merged/Prod/db", Driver: "doppler", DestPath: "analytics/prd"},
+		}}
+		if cfg.Name == "sync-Prod-1" {
+			completion.Paths = append(completion.Paths, backend.PathResult{
+				SourcePath: "merged/Prod/api", Driver: "doppler", DestPath: "web/prd", Error: errors.New("API error: status=403"),
+			})
+			return completion, errors.New("errors: [API error: status=403]")
+		}
+		return completion, nil
+	}
+
+	p := &Pipeline{config: &Config{
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{"Prod": {Destinations: []Destination{
+			{Doppler: &DopplerDestination{Project: "analytics", Config: "prd"}},
+			{Doppler: &DopplerDestination{Project: "web", Config: "prd"}},
+		}}},
+	}}
+
+	result := p.syncTarget(context.Background(), "Prod", false)
+	assert.Equal(t, []string{"sync-Prod-0", "sync-Prod-1"}, synced)
+	assert.False(t, result.Success)
+	require.Len(t, result.Details.Destinations, 2)
+	assert.True(t, result.Details.Destinations[0].Success)
+	assert.False(t, result.Details.Destinations[1].Success)
+	assert.Equal(t, "sync failed: errors: [API error: status=403]", result.Details.Destinations[1].Error)
+	assert.Equal(t, []string{"merged/Prod/api -> doppler:web/prd: API error: status=403"}, result.Details.FailedPaths)
+}
+
+func TestSyncTargetVerificationFailures(t *testing.T) {
+	wait := backend.ManualTriggerAndWait
+	t.Cleanup(func() { backend.ManualTriggerAndWait = wait })
+
+	var verify *bool
+	backend.ManualTriggerAndWait = func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*backend.SyncCompletion, error) {
+		verify = cfg.Spec.Verify
+		err := fmt.Errorf("%w: app/db does not match what was written", backend.ErrVerificationFailed)
+		return &backend.SyncCompletion{Name: cfg.Name, Paths: []backend.PathResult{
+			{SourcePath: "merged/Prod/db", Driver: "aws", DestPath: "app/db", Error: err},
+		}}, fmt.Errorf("errors: [%v]", err)
+	}
+
+	p := &Pipeline{config: &Config{
+		Pipeline:   PipelineSettings{Sync: SyncSettings{Verify: true}},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{"Prod": {Destinations: []Destination{
+			{AccountID: "111111111111"},
+		}}},
+	}}
+
+	result := p.syncTarget(context.Background(), "Prod", false)
+	require.NotNil(t, verify)
+	assert.True(t, *verify)
+	assert.False(t, result.Success)
+	require.Len(t, result.Details.Destinations, 1)
+	assert.True(t, result.Details.Destinations[0].Unverified)
+	assert.Equal(t, []string{"merged/Prod/db -> aws:app/db: read-back verification failed: app/db does not match what was written"}, result.Details.VerificationFailures)
+	assert.Equal(t, result.Details.VerificationFailures, result.Details.FailedPaths)
+}
@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/internal/wasm"
+)
+
+// ImportPolicy restricts which targets may import sensitive sources. A target
+// that imports a listed source, directly or through the targets it inherits
+// from, must have one of the allowed tags (a key, or key=value), have an
+// allowed classification or be named in targets.
+//
+//	pipeline:
+//	  import_policies:
+//	    - name: pci-only
+//	      sources: [payments]
+//	      allow:
+//	        tags: [data-classification=pci]
+//	    - name: prod-secrets
+//	      sources: [prod-db, prod-api-keys]
+//	      allow:
+//	        classifications: [production]
+//	        targets: [Disaster_Recovery]
+//	    - name: data-residency
+//	      sources: [customer-pii]
+//	      wasm: policies/residency.wasm
+type ImportPolicy struct {
+	Name    string            `mapstructure:"name" yaml:"name"`
+	Sources []string          `mapstructure:"sources" yaml:"sources"`
+	Allow   ImportPolicyAllow `mapstructure:"allow" yaml:"allow"`
+	// Wasm is the path to a WebAssembly plugin whose check_import function
+	// decides instead of Allow, given a wasmImportRequest
+	Wasm string `mapstructure:"wasm" yaml:"wasm,omitempty"`
+}
+
+// ImportPolicyAllow lists the targets an ImportPolicy permits
+type ImportPolicyAllow struct {
+	Tags            []string `mapstructure:"tags" yaml:"tags,omitempty"`
+	Classifications []string `mapstructure:"classifications" yaml:"classifications,omitempty"`
+	Targets         []string `mapstructure:"targets" yaml:"targets,omitempty"`
+}
+
+// ImportPolicyError reports a target importing a source its policy does not allow
+type ImportPolicyError struct {
+	Target string
+	Source string
+	// Via is the inherited target the source arrives through, empty for direct imports
+	Via    string
+	Policy ImportPolicy
+	// Reason is the denial reason given by a wasm policy
+	Reason string
+}
+
+func (e *ImportPolicyError) Error() string {
+	via := ""
+	if e.Via != "" {
+		via = fmt.Sprintf(" (inherited through %q)", e.Via)
+	}
+	if e.Policy.Wasm != "" {
+		reason := e.Reason
+		if reason == "" {
+			reason = "denied"
+		}
+		return fmt.Sprintf("target %q may not import source %q%s: import policy %q: %s",
+			e.Target, e.Source, via, e.Policy.Name, reason)
+	}
+	return fmt.Sprintf("target %q may not import source %q%s: import policy %q allows only %s",
+		e.Target, e.Source, via, e.Policy.Name, e.Policy.Allow)
+}
+
+func (a ImportPolicyAllow) String() string {
+	var parts []string
+	if len(a.Tags) > 0 {
+		parts = append(parts, "targets tagged "+strings.Join(a.Tags, " or "))
+	}
+	if len(a.Classifications) > 0 {
+		parts = append(parts, "targets classified "+strings.Join(a.Classifications, " or "))
+	}
+	if len(a.Targets) > 0 {
+		parts = append(parts, "targets "+strings.Join(a.Targets, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (p ImportPolicy) validate(c *Config) error {
+	if len(p.Sources) == 0 {
+		return fmt.Errorf("sources is required")
+	}
+	for _, s := range p.Sources {
+		if _, ok := c.Sources[s]; !ok {
+			return fmt.Errorf("source %q not found", s)
+		}
+	}
+	allowSet := len(p.Allow.Tags) > 0 || len(p.Allow.Classifications) > 0 || len(p.Allow.Targets) > 0
+	if p.Wasm != "" {
+		if allowSet {
+			return fmt.Errorf("allow and wasm cannot be combined")
+		}
+		return nil
+	}
+	if !allowSet {
+		return fmt.Errorf("allow must list tags, classifications or targets")
+	}
+	for _, t := range p.Allow.Targets {
+		if _, ok := c.Targets[t]; !ok {
+			return fmt.Errorf("target %q not found", t)
+		}
+	}
+	return nil
+}
+
+// allows reports whether the policy permits the target to import its sources
+func (p ImportPolicy) allows(name string, target Target) bool {
+	if containsString(p.Allow.Targets, name) {
+		return true
+	}
+	if target.Classification != "" && containsString(p.Allow.Classifications, target.Classification) {
+		return true
+	}
+	for _, tag := range p.Allow.Tags {
+		k, v, withValue := strings.Cut(tag, "=")
+		if got, ok := target.Tags[k]; ok && (!withValue || got == v) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeImports checks every target's sources, including those inherited
+// from other targets, against the import policies. Errors are *ImportPolicyError.
+func (c *Config) authorizeImports() error {
+	if len(c.Pipeline.ImportPolicies) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(c.Targets))
+	for name := range c.Targets {
+		names = append(names, name)
+	}
+	// Report errors in a stable order
+	sort.Strings(names)
+
+	for _, name := range names {
+		target := c.Targets[name]
+		sources := c.inheritedSources(name)
+		for _, policy := range c.Pipeline.ImportPolicies {
+			if policy.Wasm == "" && policy.allows(name, target) {
+				continue
+			}
+			for _, source := range policy.Sources {
+				via, ok := sources[source]
+				if !ok {
+					continue
+				}
+				if policy.Wasm == "" {
+					return &ImportPolicyError{Target: name, Source: source, Via: via, Policy: policy}
+				}
+				decision, err := policy.checkWasm(wasmImportRequest{
+					Policy: policy.Name,
+					Source: source,
+					Via:    via,
+					Target: wasmImportTarget{
+						Name:           name,
+						AccountID:      target.AccountID,
+						Classification: target.Classification,
+						Tags:           target.Tags,
+						Labels:         target.Labels,
+					},
+				})
+				if err != nil {
+					return fmt.Errorf("import policy %q: %w", policy.Name, err)
+				}
+				if !decision.Allow {
+					return &ImportPolicyError{Target: name, Source: source, Via: via, Policy: policy, Reason: decision.Reason}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// wasmImportRequest is the JSON input of a wasm policy's check_import
+type wasmImportRequest struct {
+	Policy string           `json:"policy"`
+	Source string           `json:"source"`
+	Via    string           `json:"via,omitempty"`
+	Target wasmImportTarget `json:"target"`
+}
+
+type wasmImportTarget struct {
+	Name           string            `json:"name"`
+	AccountID      string            `json:"account_id,omitempty"`
+	Classification string            `json:"classification,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+}
+
+// wasmImportDecision is the JSON output of check_import
+type wasmImportDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// checkWasm asks the policy's plugin whether the import is allowed
+func (p ImportPolicy) checkWasm(req wasmImportRequest) (wasmImportDecision, error) {
+	var decision wasmImportDecision
+	ctx := context.Background()
+	plugin, err := wasm.Load(ctx, p.Wasm)
+	if err != nil {
+		return decision, err
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return decision, err
+	}
+	out, err := plugin.Call(ctx, "check_import", input)
+	if err != nil {
+		return decision, err
+	}
+	if err := json.Unmarshal(out, &decision); err != nil {
+		return decision, fmt.Errorf("wasm plugin %s returned an invalid decision: %w", p.Wasm, err)
+	}
+	return decision, nil
+}
+
+// inheritedSources maps each source a target receives to the inherited target
+// it arrives through, or "" when the target imports it directly
+func (c *Config) inheritedSources(name string) map[string]string {
+	sources := make(map[string]string)
+	visited := map[string]bool{name: true}
+	var walk func(target, via string)
+	walk = func(target, via string) {
+		for _, imp := range c.Targets[target].Imports {
+			imp = ImportName(imp)
+			if _, isSource := c.Sources[imp]; isSource {
+				if _, seen := sources[imp]; !seen || via == "" {
+					sources[imp] = via
+				}
+				continue
+			}
+			if _, isTarget := c.Targets[imp]; isTarget && !visited[imp] {
+				visited[imp] = true
+				next := via
+				if next == "" {
+					next = imp
+				}
+				walk(imp, next)
+			}
+		}
+	}
+	walk(name, "")
+	return sources
+}
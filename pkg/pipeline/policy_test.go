@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func policyTestConfig(policies ...ImportPolicy) *Config {
+	return &Config{
+		Sources: map[string]Source{
+			"analytics": {},
+			"payments":  {},
+		},
+		Targets: map[string]Target{
+			"Payments_Prod": {Imports: []string{"payments", "analytics"}, Tags: map[string]string{"pci": "true"}, Classification: "production"},
+			"Payments_Base": {Imports: []string{"payments"}, Tags: map[string]string{"pci": "true"}},
+			"Sandbox_Alice": {Imports: []string{"analytics"}},
+		},
+		Pipeline: PipelineSettings{ImportPolicies: policies},
+	}
+}
+
+var pciOnly = ImportPolicy{
+	Name:    "pci-only",
+	Sources: []string{"payments"},
+	Allow:   ImportPolicyAllow{Tags: []string{"pci"}},
+}
+
+func TestAuthorizeImports(t *testing.T) {
+	cfg := policyTestConfig(pciOnly)
+	require.NoError(t, cfg.authorizeImports())
+
+	// A direct import by an untagged target is refused
+	cfg.Targets["Sandbox_Alice"] = Target{Imports: []string{"analytics", "payments@v3"}}
+	err := cfg.authorizeImports()
+	var policyErr *ImportPolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "Sandbox_Alice", policyErr.Target)
+	assert.Equal(t, "payments", policyErr.Source)
+	assert.Empty(t, policyErr.Via)
+	assert.EqualError(t, err, `target "Sandbox_Alice" may not import source "payments": import policy "pci-only" allows only targets tagged pci`)
+
+	// Inheriting from a target that imports the source is refused too
+	cfg.Targets["Sandbox_Alice"] = Target{Imports: []string{"analytics", "Payments_Base"}}
+	err = cfg.authorizeImports()
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "Payments_Base", policyErr.Via)
+	assert.Contains(t, err.Error(), `(inherited through "Payments_Base")`)
+
+	// Classifications and target names allow imports as well
+	cfg.Pipeline.ImportPolicies[0].Allow.Classifications = []string{"staging"}
+	cfg.Targets["Sandbox_Alice"] = Target{Imports: []string{"Payments_Base"}, Classification: "staging"}
+	assert.NoError(t, cfg.authorizeImports())
+	cfg.Pipeline.ImportPolicies[0].Allow.Targets = []string{"Payments_DR"}
+	cfg.Targets["Payments_DR"] = Target{Imports: []string{"Payments_Prod"}}
+	assert.NoError(t, cfg.authorizeImports())
+}
+
+func TestAuthorizeImportsTagValue(t *testing.T) {
+	policy := ImportPolicy{
+		Name:    "pci-only",
+		Sources: []string{"payments"},
+		Allow:   ImportPolicyAllow{Tags: []string{"data-classification=pci"}},
+	}
+	cfg := policyTestConfig(policy)
+	cfg.Targets["Payments_Prod"] = Target{Imports: []string{"payments"}, Tags: map[string]string{"data-classification": "pci"}}
+	cfg.Targets["Payments_Base"] = Target{Imports: []string{"payments"}, Tags: map[string]string{"data-classification": "internal"}}
+	err := cfg.authorizeImports()
+	var policyErr *ImportPolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "Payments_Base", policyErr.Target)
+
+	// A key alone allows any value
+	cfg.Pipeline.ImportPolicies[0].Allow.Tags = []string{"data-classification"}
+	assert.NoError(t, cfg.authorizeImports())
+}
+
+func TestAuthorizeImportsInheritanceCycle(t *testing.T) {
+	cfg := policyTestConfig(pciOnly)
+	cfg.Targets["A"] = Target{Imports: []string{"B"}}
+	cfg.Targets["B"] = Target{Imports: []string{"A", "payments"}}
+	err := cfg.authorizeImports()
+	var policyErr *ImportPolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "A", policyErr.Target)
+	assert.Equal(t, "B", policyErr.Via)
+}
+
+func TestImportPolicyValidate(t *testing.T) {
+	cfg := policyTestConfig()
+	assert.NoError(t, pciOnly.validate(cfg))
+	assert.EqualError(t, ImportPolicy{Allow: pciOnly.Allow}.validate(cfg), "sources is required")
+	assert.EqualError(t, ImportPolicy{Sources: []string{"ledger"}, Allow: pciOnly.Allow}.validate(cfg), `source "ledger" not found`)
+	assert.EqualError(t, ImportPolicy{Sources: []string{"payments"}}.validate(cfg), "allow must list tags, classifications or targets")
+	assert.EqualError(t, ImportPolicy{Sources: []string{"payments"}, Allow: ImportPolicyAllow{Targets: []string{"Nope"}}}.validate(cfg), `target "Nope" not found`)
+	assert.NoError(t, ImportPolicy{Sources: []string{"payments"}, Wasm: "policy.wasm"}.validate(cfg))
+	assert.EqualError(t, ImportPolicy{Sources: []string{"payments"}, Allow: pciOnly.Allow, Wasm: "policy.wasm"}.validate(cfg), "allow and wasm cannot be combined")
+}
+
+func TestAuthorizeImportsWasm(t *testing.T) {
+	// The test plugin denies every import it is asked about
+	cfg := policyTestConfig(ImportPolicy{
+		Name:    "residency",
+		Sources: []string{"payments"},
+		Wasm:    "../../internal/wasm/testdata/plugin.wasm",
+	})
+	err := cfg.authorizeImports()
+	var policyErr *ImportPolicyError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "payments", policyErr.Source)
+	assert.Equal(t, "denied by test", policyErr.Reason)
+	assert.Contains(t, err.Error(), `import policy "residency": denied by test`)
+
+	cfg.Pipeline.ImportPolicies[0].Wasm = "testdata/missing.wasm"
+	assert.ErrorContains(t, cfg.authorizeImports(), `import policy "residency": failed to read wasm plugin`)
+}
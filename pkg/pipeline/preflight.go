@@ -0,0 +1,418 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// PreflightAction is the action preflight simulates on each target's secrets
+const PreflightAction = "secretsmanager:PutSecretValue"
+
+// Preflight checks, the columns of a preflight report
+const (
+	PreflightAssumeRole      = "assume role"
+	PreflightPutSecret       = "put secret value"
+	PreflightRolePermissions = "role permissions"
+	PreflightVaultSources    = "vault sources"
+	PreflightMergeStore      = "merge store"
+)
+
+// PreflightChecks are the checks preflight runs for every target, in order
+var PreflightChecks = []string{PreflightAssumeRole, PreflightPutSecret, PreflightRolePermissions, PreflightVaultSources, PreflightMergeStore}
+
+// syncSecretsActions are the Secrets Manager actions a sync needs on the
+// secrets a target writes
+var syncSecretsActions = []string{
+	"secretsmanager:DescribeSecret",
+	"secretsmanager:GetSecretValue",
+	"secretsmanager:CreateSecret",
+	"secretsmanager:PutSecretValue",
+	"secretsmanager:UpdateSecret",
+}
+
+// broadSecretsActions are the actions a role is over-privileged with when it
+// may take them on secrets the target does not write
+var broadSecretsActions = []string{
+	"secretsmanager:GetSecretValue",
+	"secretsmanager:PutSecretValue",
+	"secretsmanager:DeleteSecret",
+}
+
+// unneededSecretsActions are never needed by a sync, even on the target's own
+// secrets: they change who may read them
+var unneededSecretsActions = []string{
+	"secretsmanager:PutResourcePolicy",
+	"secretsmanager:DeleteResourcePolicy",
+}
+
+// PreflightReport is the pass/fail matrix of targets and checks
+type PreflightReport struct {
+	Targets []PreflightTarget `json:"targets"`
+}
+
+// PreflightTarget is one target's row of the matrix: one check for each of
+// PreflightChecks, in order
+type PreflightTarget struct {
+	Target string         `json:"target"`
+	Checks []OnboardCheck `json:"checks"`
+}
+
+// Passed reports whether no check failed
+func (r *PreflightReport) Passed() bool {
+	for _, t := range r.Targets {
+		for _, c := range t.Checks {
+			if c.Status == CheckFail {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// preflightProbe checks the access a run needs
+type preflightProbe interface {
+	// AccountIdentity returns the ARN vss acts as in the target's account
+	// and that account's ID
+	AccountIdentity(ctx context.Context, target Target) (arn, accountID string, err error)
+	// CanPutSecretValue simulates PreflightAction by principal on the
+	// secrets matching resourceARN
+	CanPutSecretValue(ctx context.Context, target Target, principal, resourceARN string) (bool, error)
+	// AllowedActions simulates actions by principal on resourceARN and
+	// returns those allowed
+	AllowedActions(ctx context.Context, target Target, principal string, actions []string, resourceARN string) ([]string, error)
+	// VaultCapabilities returns the Vault token's capabilities on a path
+	VaultCapabilities(ctx context.Context, path string) ([]string, error)
+}
+
+// Preflight checks, for the given targets (all when empty), everything a run
+// needs before it writes anything: that vss can assume each AWS destination's
+// role, that the role may put secret values under the target's names and
+// take the other actions a sync needs there without being over-privileged,
+// that the Vault token can list and read the target's Vault sources and that
+// the target's merged secrets can be read and written in the merge store.
+// Checks that cannot run are reported, not returned as errors.
+func (p *Pipeline) Preflight(ctx context.Context, targets []string) (*PreflightReport, error) {
+	targets, err := p.selectTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+	if p.preflightProbe == nil {
+		p.preflightProbe = newLiveReadinessProbe(p.config, p.awsCtx)
+	}
+	store, storeErr := p.openMergeStore(ctx)
+	if storeErr != nil {
+		log.WithFields(log.Fields{"action": "preflight"}).WithError(storeErr).Error("Failed to open merge store")
+	}
+	return p.preflight(ctx, p.preflightProbe, store, storeErr, targets), nil
+}
+
+func (p *Pipeline) preflight(ctx context.Context, probe preflightProbe, store mergeStore, storeErr error, targets []string) *PreflightReport {
+	report := &PreflightReport{Targets: []PreflightTarget{}}
+	// Identities and capabilities are looked up once however many targets
+	// share them
+	type identity struct {
+		arn, account string
+		err          error
+	}
+	identities := make(map[string]identity)
+	capabilities := make(map[string]vaultLookup)
+	lookupCaps := func(ctx context.Context, path string) ([]string, error) {
+		l, ok := capabilities[path]
+		if !ok {
+			l.caps, l.err = probe.VaultCapabilities(ctx, path)
+			capabilities[path] = l
+		}
+		return l.caps, l.err
+	}
+
+	for _, name := range targets {
+		target := p.config.Targets[name]
+		var roles, puts, perms []OnboardCheck
+		for _, d := range target.ResolvedDestinations() {
+			if !d.requiresAccountID() {
+				continue
+			}
+			label := d.Label()
+			dt := destinationTarget(target, d)
+			roleARN := dt.RoleARN
+			if roleARN == "" {
+				roleARN = p.config.GetRoleARN(d.AccountID)
+			}
+			key := d.AccountID + "|" + roleARN
+			id, ok := identities[key]
+			if !ok {
+				id.arn, id.account, id.err = probe.AccountIdentity(ctx, dt)
+				identities[key] = id
+			}
+			switch {
+			case id.err != nil:
+				roles = append(roles, OnboardCheck{Status: CheckFail, Detail: fmt.Sprintf("%s: %s", label, id.err),
+					Fix: fmt.Sprintf("create %s and let the pipeline's identity assume it", roleARN)})
+			case id.account != d.AccountID:
+				roles = append(roles, OnboardCheck{Status: CheckFail,
+					Detail: fmt.Sprintf("%s: credentials resolve to account %s (%s)", label, id.account, id.arn),
+					Fix:    fmt.Sprintf("check that %s is in account %s", roleARN, d.AccountID)})
+			default:
+				roles = append(roles, OnboardCheck{Status: CheckPass, Detail: id.arn})
+			}
+
+			if !d.isAWS() {
+				continue
+			}
+			if id.err != nil || id.account != d.AccountID {
+				puts = append(puts, OnboardCheck{Status: CheckSkip, Detail: label + ": the role could not be assumed"})
+				perms = append(perms, OnboardCheck{Status: CheckSkip, Detail: label + ": the role could not be assumed"})
+				continue
+			}
+			puts = append(puts, p.putSecretCheck(ctx, probe, name, target, d, id.arn, roleARN))
+			perms = append(perms, p.rolePermissionsCheck(ctx, probe, name, target, d, id.arn, roleARN))
+		}
+
+		var sources []OnboardCheck
+		for _, imp := range target.Imports {
+			ref, _ := ParseImportRef(imp)
+			if src, ok := p.config.Sources[ref.Name]; ok && src.Vault != nil {
+				for _, n := range vaultSourceNeeds(ref.Name, src.Vault) {
+					sources = append(sources, checkVaultNeed(ctx, lookupCaps, n))
+				}
+			}
+		}
+
+		report.Targets = append(report.Targets, PreflightTarget{
+			Target: name,
+			Checks: []OnboardCheck{
+				combineChecks(PreflightAssumeRole, roles, "no AWS destinations"),
+				combineChecks(PreflightPutSecret, puts, "no Secrets Manager destinations"),
+				combineChecks(PreflightRolePermissions, perms, "no Secrets Manager destinations"),
+				combineChecks(PreflightVaultSources, sources, "no Vault sources imported"),
+				p.mergeStoreCheck(ctx, store, storeErr, name, lookupCaps),
+			},
+		})
+	}
+	return report
+}
+
+// vaultLookup is a looked up Vault capability
+type vaultLookup struct {
+	caps []string
+	err  error
+}
+
+// putSecretCheck simulates PreflightAction by the destination's role on the
+// secrets the target writes
+func (p *Pipeline) putSecretCheck(ctx context.Context, probe preflightProbe, name string, target Target, d Destination, callerARN, roleARN string) OnboardCheck {
+	label := d.Label()
+	resource, _, err := p.secretsResource(name, target, d, callerARN)
+	if err != nil {
+		return OnboardCheck{Status: CheckFail, Detail: fmt.Sprintf("%s: %s", label, err)}
+	}
+	principal := simulationPrincipal(callerARN, roleARN)
+
+	allowed, err := probe.CanPutSecretValue(ctx, destinationTarget(target, d), principal, resource)
+	switch {
+	case err != nil:
+		return OnboardCheck{Status: CheckSkip, Detail: fmt.Sprintf("%s: failed to simulate %s: %s", label, PreflightAction, err),
+			Fix: fmt.Sprintf("grant %s iam:SimulatePrincipalPolicy on itself", principal)}
+	case !allowed:
+		return OnboardCheck{Status: CheckFail, Detail: fmt.Sprintf("%s: %s is not allowed %s on %s", label, principal, PreflightAction, resource),
+			Fix: fmt.Sprintf("allow %s on %s in the role's policy", PreflightAction, resource)}
+	}
+	return OnboardCheck{Status: CheckPass, Detail: resource}
+}
+
+// secretsResource returns the ARN pattern of the secrets a target writes to a
+// destination and the ARN pattern of every secret in the destination, which
+// are the same when the target's secret names have no prefix
+func (p *Pipeline) secretsResource(name string, target Target, d Destination, callerARN string) (resource, all string, err error) {
+	pattern, err := target.secretNamePattern(name)
+	if err != nil {
+		return "", "", err
+	}
+	prefix, _, _ := strings.Cut(pattern, "$1")
+	all = fmt.Sprintf("arn:%s:secretsmanager:%s:%s:secret:",
+		arnPartition(callerARN), p.config.destinationRegion(target, d), d.AccountID)
+	return all + prefix + "*", all + "*", nil
+}
+
+// rolePermissionsCheck simulates the destination role's permissions: it
+// fails when the role may not take every action a sync needs on the secrets
+// the target writes, and warns when it may also change other secrets or the
+// resource policies of its own
+func (p *Pipeline) rolePermissionsCheck(ctx context.Context, probe preflightProbe, name string, target Target, d Destination, callerARN, roleARN string) OnboardCheck {
+	label := d.Label()
+	resource, all, err := p.secretsResource(name, target, d, callerARN)
+	if err != nil {
+		return OnboardCheck{Status: CheckFail, Detail: fmt.Sprintf("%s: %s", label, err)}
+	}
+	principal := simulationPrincipal(callerARN, roleARN)
+	skip := func(err error) OnboardCheck {
+		return OnboardCheck{Status: CheckSkip, Detail: fmt.Sprintf("%s: failed to simulate the role's permissions: %s", label, err),
+			Fix: fmt.Sprintf("grant %s iam:SimulatePrincipalPolicy on itself", principal)}
+	}
+
+	needed := syncSecretsActions
+	if p.config.Pipeline.Sync.DeleteOrphans {
+		needed = append(needed[:len(needed):len(needed)], "secretsmanager:DeleteSecret")
+	}
+	allowed, err := probe.AllowedActions(ctx, destinationTarget(target, d), principal, needed, resource)
+	if err != nil {
+		return skip(err)
+	}
+	var missing []string
+	for _, action := range needed {
+		if !containsString(allowed, action) {
+			missing = append(missing, action)
+		}
+	}
+	if len(missing) > 0 {
+		return OnboardCheck{Status: CheckFail, Detail: fmt.Sprintf("%s: %s is not allowed %s on %s", label, principal, strings.Join(missing, ", "), resource),
+			Fix: fmt.Sprintf("allow %s on %s in the role's policy", strings.Join(missing, ", "), resource)}
+	}
+
+	var excess []string
+	if all != resource {
+		broad, err := probe.AllowedActions(ctx, destinationTarget(target, d), principal, broadSecretsActions, all)
+		if err != nil {
+			return skip(err)
+		}
+		if len(broad) > 0 {
+			excess = append(excess, fmt.Sprintf("%s on %s", strings.Join(broad, ", "), all))
+		}
+	}
+	unneeded, err := probe.AllowedActions(ctx, destinationTarget(target, d), principal, unneededSecretsActions, resource)
+	if err != nil {
+		return skip(err)
+	}
+	if len(unneeded) > 0 {
+		excess = append(excess, fmt.Sprintf("%s on %s", strings.Join(unneeded, ", "), resource))
+	}
+	if len(excess) > 0 {
+		return OnboardCheck{Status: CheckWarn, Detail: fmt.Sprintf("%s: %s is over-privileged, it is also allowed %s", label, principal, strings.Join(excess, " and ")),
+			Fix: fmt.Sprintf("scope the role's secretsmanager permissions to %s on %s", strings.Join(needed, ", "), resource)}
+	}
+	return OnboardCheck{Status: CheckPass, Detail: resource}
+}
+
+// mergeStoreCheck checks that a target's merged secrets can be listed and,
+// with a Vault merge store, written
+func (p *Pipeline) mergeStoreCheck(ctx context.Context, store mergeStore, storeErr error, name string, lookup func(ctx context.Context, path string) ([]string, error)) OnboardCheck {
+	if storeErr != nil {
+		return OnboardCheck{Name: PreflightMergeStore, Status: CheckFail, Detail: storeErr.Error(),
+			Fix: "check the merge_store config and the pipeline's access to it"}
+	}
+	var checks []OnboardCheck
+	if _, err := store.ListSecrets(ctx, name); err != nil {
+		checks = append(checks, OnboardCheck{Status: CheckFail, Detail: fmt.Sprintf("failed to list merged secrets: %s", err),
+			Fix: "grant the pipeline read access to the merge store"})
+	} else {
+		checks = append(checks, OnboardCheck{Status: CheckPass})
+	}
+	if mv := p.config.MergeStore.Vault; mv != nil {
+		checks = append(checks, checkVaultNeed(ctx, lookup, vaultMergeNeed(mv, name)))
+	}
+	return combineChecks(PreflightMergeStore, checks, "")
+}
+
+// combineChecks folds the checks behind one cell of the matrix into a check
+// with the given name: failed when any failed, skipped when all were or there
+// are none
+func combineChecks(name string, checks []OnboardCheck, none string) OnboardCheck {
+	combined := OnboardCheck{Name: name, Status: CheckSkip, Detail: none}
+	if len(checks) == 0 {
+		return combined
+	}
+	rank := map[CheckStatus]int{CheckSkip: 0, CheckPass: 1, CheckWarn: 2, CheckFail: 3}
+	for _, c := range checks {
+		if rank[c.Status] > rank[combined.Status] {
+			combined.Status = c.Status
+		}
+	}
+	// Passing cells list what passed; others only what did not
+	var details []string
+	for _, c := range checks {
+		if combined.Status != CheckPass && c.Status == CheckPass {
+			continue
+		}
+		if c.Detail != "" && !containsString(details, c.Detail) {
+			details = append(details, c.Detail)
+		}
+		if combined.Fix == "" && c.Status == combined.Status {
+			combined.Fix = c.Fix
+		}
+	}
+	combined.Detail = strings.Join(details, "; ")
+	return combined
+}
+
+// simulationPrincipal returns the IAM principal to simulate policies for:
+// the role vss assumed, when the caller is that role's session, otherwise
+// the caller itself
+func simulationPrincipal(callerARN, roleARN string) string {
+	// arn:aws:sts::<account>:assumed-role/<name>/<session>
+	parts := strings.SplitN(callerARN, ":", 6)
+	if len(parts) < 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return callerARN
+	}
+	roleName := strings.SplitN(strings.TrimPrefix(parts[5], "assumed-role/"), "/", 2)[0]
+	if strings.HasSuffix(roleARN, "/"+roleName) {
+		return roleARN
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], roleName)
+}
+
+// arnPartition returns the partition of an ARN (aws when it has none)
+func arnPartition(arn string) string {
+	if parts := strings.SplitN(arn, ":", 3); len(parts) == 3 && parts[1] != "" {
+		return parts[1]
+	}
+	return "aws"
+}
+
+func (r *liveReadinessProbe) AllowedActions(ctx context.Context, target Target, principal string, actions []string, resourceARN string) ([]string, error) {
+	cfg, err := r.awsConfig(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	output, err := iam.NewFromConfig(cfg).SimulatePrincipalPolicy(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     actions,
+		ResourceArns:    []string{resourceARN},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var allowed []string
+	for _, result := range output.EvaluationResults {
+		if result.EvalDecision == iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			allowed = append(allowed, aws.ToString(result.EvalActionName))
+		}
+	}
+	return allowed, nil
+}
+
+func (r *liveReadinessProbe) CanPutSecretValue(ctx context.Context, target Target, principal, resourceARN string) (bool, error) {
+	cfg, err := r.awsConfig(ctx, target)
+	if err != nil {
+		return false, err
+	}
+	output, err := iam.NewFromConfig(cfg).SimulatePrincipalPolicy(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     []string{PreflightAction},
+		ResourceArns:    []string{resourceARN},
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, result := range output.EvaluationResults {
+		if result.EvalDecision != iamtypes.PolicyEvaluationDecisionTypeAllowed {
+			return false, nil
+		}
+	}
+	return len(output.EvaluationResults) > 0, nil
+}
@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePreflightProbe struct {
+	// denied are the accounts whose role cannot be assumed
+	denied map[string]bool
+	// allowed are the resource ARNs PutSecretValue is allowed on
+	allowed map[string]bool
+	// caps are the Vault token's capabilities by path
+	caps map[string][]string
+	// actions are the actions allowed by resource ARN; resources without
+	// an entry allow every action a sync needs
+	actions map[string][]string
+	// simulateErr fails every simulation of more than one action
+	simulateErr error
+
+	identityCalls int
+}
+
+func (f *fakePreflightProbe) AccountIdentity(_ context.Context, target Target) (string, string, error) {
+	f.identityCalls++
+	if f.denied[target.AccountID] {
+		return "", "", errors.New("AccessDenied")
+	}
+	return "arn:aws:sts::" + target.AccountID + ":assumed-role/AWSControlTowerExecution/vault-secret-sync", target.AccountID, nil
+}
+
+func (f *fakePreflightProbe) CanPutSecretValue(_ context.Context, _ Target, _, resourceARN string) (bool, error) {
+	return f.allowed[resourceARN], nil
+}
+
+func (f *fakePreflightProbe) AllowedActions(_ context.Context, _ Target, _ string, actions []string, resourceARN string) ([]string, error) {
+	if f.simulateErr != nil {
+		return nil, f.simulateErr
+	}
+	granted, ok := f.actions[resourceARN]
+	if !ok {
+		granted = append(syncSecretsActions, "secretsmanager:DeleteSecret")
+	}
+	var allowed []string
+	for _, a := range actions {
+		if containsString(granted, a) {
+			allowed = append(allowed, a)
+		}
+	}
+	return allowed, nil
+}
+
+func (f *fakePreflightProbe) VaultCapabilities(_ context.Context, path string) ([]string, error) {
+	if caps, ok := f.caps[path]; ok {
+		return caps, nil
+	}
+	return []string{"deny"}, nil
+}
+
+func preflightMatrix(r *PreflightReport) map[string][]CheckStatus {
+	matrix := make(map[string][]CheckStatus)
+	for _, t := range r.Targets {
+		for _, c := range t.Checks {
+			matrix[t.Target] = append(matrix[t.Target], c.Status)
+		}
+	}
+	return matrix
+}
+
+func TestPreflight(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		AWS: AWSConfig{Region: "us-east-1", ControlTower: ControlTowerConfig{Enabled: true}},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+			"payments":  {Vault: &VaultSource{Mount: "payments"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg":    {AccountID: "111111111111", Imports: []string{"analytics"}, SecretPrefix: "app/"},
+			"Stg2":   {AccountID: "111111111111", Imports: []string{"Stg"}},
+			"Prod":   {AccountID: "222222222222", Imports: []string{"Stg", "payments"}},
+			"GitHub": {GitHub: &GitHubDestination{Owner: "org", Repo: "app"}, Imports: []string{"analytics"}},
+		},
+	}}
+	probe := &fakePreflightProbe{
+		denied: map[string]bool{"222222222222": true},
+		allowed: map[string]bool{
+			"arn:aws:secretsmanager:us-east-1:111111111111:secret:app/*": true,
+		},
+		actions: map[string][]string{
+			"arn:aws:secretsmanager:us-east-1:111111111111:secret:*": nil,
+		},
+		caps: map[string][]string{
+			"analytics/metadata/": {"list"},
+			"analytics/data/":     {"read"},
+			"payments/metadata/":  {"list"},
+			"merged/data/Stg/":    {"create", "update"},
+			"merged/data/Stg2/":   {"create", "update"},
+			"merged/data/Prod/":   {"root"},
+		},
+	}
+
+	report := p.preflight(context.Background(), probe, memMergeStore{}, nil, []string{"GitHub", "Prod", "Stg", "Stg2"})
+	assert.Equal(t, map[string][]CheckStatus{
+		"GitHub": {CheckSkip, CheckSkip, CheckSkip, CheckPass, CheckFail},
+		"Prod":   {CheckFail, CheckSkip, CheckSkip, CheckFail, CheckPass},
+		"Stg":    {CheckPass, CheckPass, CheckPass, CheckPass, CheckPass},
+		"Stg2":   {CheckPass, CheckFail, CheckFail, CheckSkip, CheckPass},
+	}, preflightMatrix(report))
+	assert.False(t, report.Passed())
+	assert.Equal(t, 2, probe.identityCalls, "identities are looked up once per account and role")
+
+	for _, tr := range report.Targets {
+		for i, c := range tr.Checks {
+			assert.Equal(t, PreflightChecks[i], c.Name)
+		}
+	}
+	prod := report.Targets[1].Checks
+	assert.Equal(t, "aws:222222222222: AccessDenied", prod[0].Detail)
+	assert.Equal(t, "missing read on payments/data/*", prod[3].Detail)
+	assert.Contains(t, prod[3].Fix, `path "payments/data/*"`)
+	assert.Contains(t, report.Targets[3].Checks[1].Detail, "arn:aws:iam::111111111111:role/AWSControlTowerExecution is not allowed")
+
+	// An unreachable merge store fails every target's merge store check
+	report = p.preflight(context.Background(), probe, nil, errors.New("connection refused"), []string{"Stg"})
+	require.Len(t, report.Targets, 1)
+	assert.Equal(t, CheckFail, report.Targets[0].Checks[4].Status)
+	assert.Equal(t, "connection refused", report.Targets[0].Checks[4].Detail)
+}
+
+func TestPreflightRolePermissions(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		AWS:     AWSConfig{Region: "us-east-1", ControlTower: ControlTowerConfig{Enabled: true}},
+		Targets: map[string]Target{"Stg": {AccountID: "111111111111", SecretPrefix: "app/"}},
+	}}
+	const (
+		scoped = "arn:aws:secretsmanager:us-east-1:111111111111:secret:app/*"
+		all    = "arn:aws:secretsmanager:us-east-1:111111111111:secret:*"
+	)
+	check := func(probe *fakePreflightProbe) OnboardCheck {
+		t.Helper()
+		report := p.preflight(context.Background(), probe, memMergeStore{}, nil, []string{"Stg"})
+		require.Len(t, report.Targets, 1)
+		return report.Targets[0].Checks[2]
+	}
+
+	// Exactly the actions a sync needs, on the target's secrets only
+	probe := &fakePreflightProbe{actions: map[string][]string{scoped: syncSecretsActions, all: nil}}
+	c := check(probe)
+	assert.Equal(t, CheckPass, c.Status)
+	assert.Equal(t, scoped, c.Detail)
+
+	// secretsmanager:* on *
+	probe.actions = map[string][]string{}
+	c = check(probe)
+	assert.Equal(t, CheckWarn, c.Status)
+	assert.Equal(t, "aws:111111111111: arn:aws:iam::111111111111:role/AWSControlTowerExecution is over-privileged, it is also allowed "+
+		"secretsmanager:GetSecretValue, secretsmanager:PutSecretValue, secretsmanager:DeleteSecret on "+all, c.Detail)
+
+	probe.actions = map[string][]string{
+		scoped: append([]string{"secretsmanager:PutResourcePolicy"}, syncSecretsActions...),
+		all:    nil,
+	}
+	assert.Contains(t, check(probe).Detail, "secretsmanager:PutResourcePolicy on "+scoped)
+
+	// Deleting orphans needs DeleteSecret too
+	p.config.Pipeline.Sync.DeleteOrphans = true
+	probe.actions = map[string][]string{scoped: syncSecretsActions[1:], all: nil}
+	c = check(probe)
+	assert.Equal(t, CheckFail, c.Status)
+	assert.Contains(t, c.Detail, "is not allowed secretsmanager:DescribeSecret, secretsmanager:DeleteSecret on "+scoped)
+
+	probe.simulateErr = errors.New("AccessDenied: iam:SimulatePrincipalPolicy")
+	c = check(probe)
+	assert.Equal(t, CheckSkip, c.Status)
+	assert.Contains(t, c.Fix, "iam:SimulatePrincipalPolicy")
+}
+
+func TestSimulationPrincipal(t *testing.T) {
+	session := "arn:aws:sts::111111111111:assumed-role/vss/vault-secret-sync"
+	assert.Equal(t, "arn:aws:iam::111111111111:role/team/vss", simulationPrincipal(session, "arn:aws:iam::111111111111:role/team/vss"))
+	assert.Equal(t, "arn:aws:iam::111111111111:role/vss", simulationPrincipal(session, ""))
+	assert.Equal(t, "arn:aws:iam::111111111111:user/ci", simulationPrincipal("arn:aws:iam::111111111111:user/ci", ""))
+	assert.Equal(t, "aws-us-gov", arnPartition("arn:aws-us-gov:sts::111111111111:assumed-role/vss/x"))
+}
@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/stores/vault"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// mergeStore is the per-target view of the merge store used to copy snapshots
+// between targets. S3MergeStore implements it directly; vaultMergeStore adapts
+// a Vault KV2 mount.
+type mergeStore interface {
+	ListSecrets(ctx context.Context, targetName string) ([]string, error)
+	ReadSecret(ctx context.Context, targetName, secretName string) (map[string]interface{}, error)
+	WriteSecret(ctx context.Context, targetName, secretName string, data map[string]interface{}) error
+	DeleteSecret(ctx context.Context, targetName, secretName string) error
+}
+
+// PromotionPlan describes copying one target's merged snapshot over another's
+type PromotionPlan struct {
+	From string          `json:"from"`
+	To   string          `json:"to"`
+	Diff diff.TargetDiff `json:"diff"`
+
+	snapshot map[string]interface{}
+}
+
+// HasChanges reports whether applying the plan would modify the destination target
+func (p *PromotionPlan) HasChanges() bool {
+	return p.Diff.Summary.HasChanges()
+}
+
+// PlanPromotion reads the merged snapshots of both targets and diffs them. The
+// destination's current merged secrets are compared against the exact snapshot
+// the source target was last merged to.
+func (p *Pipeline) PlanPromotion(ctx context.Context, from, to string) (*PromotionPlan, error) {
+	if err := p.config.validatePromotion(from, to); err != nil {
+		return nil, err
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := planPromotion(ctx, store, from, to, p.diffHasher())
+	if err != nil {
+		return nil, err
+	}
+	plan.Diff.Owners = p.config.Owners(to)
+	return plan, nil
+}
+
+// Promote copies the planned snapshot into the destination target's merge path,
+// replacing its contents without re-merging its imports. Run the sync phase for
+// the destination afterwards to push the promoted secrets out.
+func (p *Pipeline) Promote(ctx context.Context, plan *PromotionPlan) error {
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return err
+	}
+	return applyPromotion(ctx, store, plan)
+}
+
+func (c *Config) validatePromotion(from, to string) error {
+	if from == to {
+		return fmt.Errorf("cannot promote target %q to itself", from)
+	}
+	if _, ok := c.Targets[from]; !ok {
+		return fmt.Errorf("target %q not found", from)
+	}
+	target, ok := c.Targets[to]
+	if !ok {
+		return fmt.Errorf("target %q not found", to)
+	}
+
+	inherits := false
+	for _, imp := range target.Imports {
+		if ImportName(imp) == from {
+			inherits = true
+			break
+		}
+	}
+	if !inherits {
+		log.WithFields(log.Fields{
+			"action": "validatePromotion",
+			"from":   from,
+			"to":     to,
+		}).Warn("Destination target does not import the source target")
+	}
+	return nil
+}
+
+func planPromotion(ctx context.Context, store mergeStore, from, to string, h *diff.Hasher) (*PromotionPlan, error) {
+	snapshot, err := readSnapshot(ctx, store, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merged secrets for %q: %w", from, err)
+	}
+	if len(snapshot) == 0 {
+		return nil, fmt.Errorf("target %q has no merged secrets; run the merge phase first", from)
+	}
+	current, err := readSnapshot(ctx, store, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merged secrets for %q: %w", to, err)
+	}
+
+	changes := h.DiffSecrets(current, snapshot)
+	for i := range changes {
+		changes[i].Target = to
+	}
+	return &PromotionPlan{
+		From: from,
+		To:   to,
+		Diff: diff.TargetDiff{
+			Target:  to,
+			Changes: changes,
+			Summary: diff.ComputeSummary(changes),
+		},
+		snapshot: snapshot,
+	}, nil
+}
+
+func applyPromotion(ctx context.Context, store mergeStore, plan *PromotionPlan) error {
+	l := log.WithFields(log.Fields{
+		"action": "applyPromotion",
+		"from":   plan.From,
+		"to":     plan.To,
+	})
+
+	for _, change := range plan.Diff.Changes {
+		var err error
+		switch change.ChangeType {
+		case diff.ChangeTypeAdded, diff.ChangeTypeModified:
+			data, _ := plan.snapshot[change.Path].(map[string]interface{})
+			err = store.WriteSecret(ctx, plan.To, change.Path, data)
+		case diff.ChangeTypeRemoved:
+			err = store.DeleteSecret(ctx, plan.To, change.Path)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to promote %q to %q: %w", change.Path, plan.To, err)
+		}
+		l.WithFields(log.Fields{
+			"secret": change.Path,
+			"change": change.ChangeType,
+		}).Debug("Promoted secret")
+	}
+
+	l.WithFields(log.Fields{
+		"added":    plan.Diff.Summary.Added,
+		"modified": plan.Diff.Summary.Modified,
+		"removed":  plan.Diff.Summary.Removed,
+	}).Info("Promotion completed")
+	return nil
+}
+
+// readSnapshot reads every merged secret of a target
+func readSnapshot(ctx context.Context, store mergeStore, targetName string) (map[string]interface{}, error) {
+	names, err := store.ListSecrets(ctx, targetName)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		data, err := store.ReadSecret(ctx, targetName, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", name, err)
+		}
+		snapshot[name] = data
+	}
+	return snapshot, nil
+}
+
+// openMergeStore returns the configured merge store as a mergeStore
+func (p *Pipeline) openMergeStore(ctx context.Context) (mergeStore, error) {
+	if p.config.MergeStore.Vault != nil {
+		if p.vaultStore != nil {
+			return p.vaultStore, nil
+		}
+		vc := &vault.VaultClient{
+			Address:   p.config.Vault.Address,
+			Namespace: p.config.Vault.Namespace,
+		}
+		if err := vc.Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to initialize vault client: %w", err)
+		}
+		return &vaultMergeStore{mount: p.config.MergeStore.Vault.Mount, client: vc}, nil
+	}
+	if p.s3Store == nil && p.config.MergeStore.S3 != nil {
+		s3Store, err := NewS3MergeStore(ctx, p.config.MergeStore.S3, p.config.AWS.Region)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create S3 merge store: %w", err)
+		}
+		p.s3Store = s3Store
+	}
+	if p.gcpStore == nil && p.config.MergeStore.GCP != nil {
+		gcpStore, err := NewGCPMergeStore(ctx, p.config.MergeStore.GCP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCP merge store: %w", err)
+		}
+		p.gcpStore = gcpStore
+	}
+	if store := p.directStore(); store != nil {
+		return store, nil
+	}
+	return nil, fmt.Errorf("no merge store configured")
+}
+
+// directMergeStore is a merge store the pipeline writes to itself rather than
+// through VaultSecretSync: S3 or GCP Secret Manager
+type directMergeStore interface {
+	mergeStore
+	GetMergePath(targetName string) string
+}
+
+// directStore returns the configured S3 or GCP merge store, or nil
+func (p *Pipeline) directStore() directMergeStore {
+	if p.memStore != nil {
+		return p.memStore
+	}
+	if p.s3Store != nil {
+		return p.s3Store
+	}
+	if p.gcpStore != nil {
+		return p.gcpStore
+	}
+	return nil
+}
+
+// vaultMergeStore adapts a Vault KV2 merge store mount to mergeStore
+type vaultMergeStore struct {
+	mount  string
+	client *vault.VaultClient
+}
+
+func (s *vaultMergeStore) path(targetName, secretName string) string {
+	return fmt.Sprintf("%s/%s/%s", s.mount, targetName, secretName)
+}
+
+// ListSecrets lists a target's merged secrets, descending into nested paths
+func (s *vaultMergeStore) ListSecrets(ctx context.Context, targetName string) ([]string, error) {
+	var names []string
+	var walk func(prefix string) error
+	walk = func(prefix string) error {
+		keys, err := s.client.ListSecrets(ctx, fmt.Sprintf("%s/%s/%s", s.mount, targetName, prefix))
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if strings.HasSuffix(k, "/") {
+				if err := walk(prefix + k); err != nil {
+					return err
+				}
+				continue
+			}
+			names = append(names, prefix+k)
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *vaultMergeStore) ReadSecret(ctx context.Context, targetName, secretName string) (map[string]interface{}, error) {
+	b, err := s.client.GetSecret(ctx, s.path(targetName, secretName))
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret: %w", err)
+	}
+	return data, nil
+}
+
+// WriteSecret replaces the secret outright; promotion copies snapshots rather than merging them
+func (s *vaultMergeStore) WriteSecret(ctx context.Context, targetName, secretName string, data map[string]interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.WriteSecret(ctx, metav1.ObjectMeta{Namespace: "pipeline"}, s.path(targetName, secretName), b)
+	return err
+}
+
+func (s *vaultMergeStore) DeleteSecret(ctx context.Context, targetName, secretName string) error {
+	return s.client.DeleteSecret(ctx, s.path(targetName, secretName))
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memMergeStore is an in-memory mergeStore keyed by target then secret name
+type memMergeStore map[string]map[string]map[string]interface{}
+
+func (m memMergeStore) ListSecrets(_ context.Context, targetName string) ([]string, error) {
+	var names []string
+	for name := range m[targetName] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m memMergeStore) ReadSecret(_ context.Context, targetName, secretName string) (map[string]interface{}, error) {
+	data, ok := m[targetName][secretName]
+	if !ok {
+		return nil, fmt.Errorf("secret %q not found", secretName)
+	}
+	return data, nil
+}
+
+func (m memMergeStore) WriteSecret(_ context.Context, targetName, secretName string, data map[string]interface{}) error {
+	if m[targetName] == nil {
+		m[targetName] = make(map[string]map[string]interface{})
+	}
+	m[targetName][secretName] = data
+	return nil
+}
+
+func (m memMergeStore) DeleteSecret(_ context.Context, targetName, secretName string) error {
+	delete(m[targetName], secretName)
+	return nil
+}
+
+func TestPromotion(t *testing.T) {
+	ctx := context.Background()
+	store := memMergeStore{
+		"Stg": {
+			"api":      {"token": "new"},
+			"db":       {"password": "same"},
+			"new-feat": {"flag": "on"},
+		},
+		"Prod": {
+			"api":    {"token": "old"},
+			"db":     {"password": "same"},
+			"legacy": {"key": "gone"},
+		},
+	}
+
+	plan, err := planPromotion(ctx, store, "Stg", "Prod", nil)
+	require.NoError(t, err)
+	assert.True(t, plan.HasChanges())
+	assert.Equal(t, diff.ChangeSummary{Added: 1, Removed: 1, Modified: 1, Unchanged: 1, Total: 4}, plan.Diff.Summary)
+
+	require.NoError(t, applyPromotion(ctx, store, plan))
+	assert.Equal(t, store["Stg"], store["Prod"])
+
+	// Promoting again is a no-op
+	plan, err = planPromotion(ctx, store, "Stg", "Prod", nil)
+	require.NoError(t, err)
+	assert.False(t, plan.HasChanges())
+}
+
+func TestPlanPromotionHashes(t *testing.T) {
+	store := memMergeStore{
+		"Stg":  {"api": {"token": "new", "region": "us-east-1"}},
+		"Prod": {"api": {"token": "old", "region": "us-east-1"}},
+	}
+	plan, err := planPromotion(context.Background(), store, "Stg", "Prod", diff.NewHasher("audit-salt"))
+	require.NoError(t, err)
+	require.Len(t, plan.Diff.Changes, 1)
+	c := plan.Diff.Changes[0]
+	assert.NotEqual(t, c.CurrentHash, c.DesiredHash)
+	assert.Equal(t, c.CurrentKeyHashes["region"], c.DesiredKeyHashes["region"])
+	assert.NotEqual(t, c.CurrentKeyHashes["token"], c.DesiredKeyHashes["token"])
+}
+
+func TestPlanPromotionEmptySource(t *testing.T) {
+	_, err := planPromotion(context.Background(), memMergeStore{}, "Stg", "Prod", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "run the merge phase first")
+}
+
+func TestValidatePromotion(t *testing.T) {
+	cfg := &Config{
+		Targets: map[string]Target{
+			"Stg":  {Imports: []string{"analytics"}},
+			"Prod": {Imports: []string{"Stg@v3"}},
+		},
+	}
+	assert.NoError(t, cfg.validatePromotion("Stg", "Prod"))
+	assert.ErrorContains(t, cfg.validatePromotion("Stg", "Stg"), "to itself")
+	assert.ErrorContains(t, cfg.validatePromotion("Stg", "Missing"), `target "Missing" not found`)
+}
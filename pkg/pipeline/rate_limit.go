@@ -0,0 +1,56 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/jbcom/secretsync/internal/awsrate"
+)
+
+// RateLimitConfig caps AWS API calls per service across every AWS client of
+// the run: discovery, the pipeline's own calls and the Secrets Manager store
+type RateLimitConfig struct {
+	// Services maps an AWS service (secretsmanager, sts, organizations,
+	// ssoadmin, identitystore, ssm, s3, kms) to requests per second. Services
+	// not listed are not limited.
+	Services map[string]float64 `mapstructure:"services" yaml:"services"`
+	// Burst is how many calls to a service may be made at once (default 1)
+	Burst int `mapstructure:"burst" yaml:"burst,omitempty"`
+	// DisableAdaptive keeps the configured rates when AWS throttles. By default
+	// a throttled service's rate is halved and recovers as calls succeed.
+	DisableAdaptive bool `mapstructure:"disable_adaptive" yaml:"disable_adaptive,omitempty"`
+}
+
+func (r *RateLimitConfig) validate() error {
+	for service, rps := range r.Services {
+		if rps <= 0 {
+			return fmt.Errorf("services.%s must be positive", service)
+		}
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	return nil
+}
+
+// configureRateLimits applies the AWS rate limits to every AWS client in the
+// process; without limits calls are not limited
+func configureRateLimits(cfg *AWSConfig) {
+	r := cfg.RateLimits
+	if r == nil {
+		awsrate.Configure(awsrate.Config{})
+		return
+	}
+	awsrate.Configure(awsrate.Config{
+		RPS:             r.Services,
+		Burst:           r.Burst,
+		DisableAdaptive: r.DisableAdaptive,
+	})
+}
+
+// withRateLimit is a LoadDefaultConfig option that subjects the clients made
+// from the config to the AWS rate limits
+func withRateLimit() config.LoadOptionsFunc {
+	return config.WithAPIOptions([]func(*middleware.Stack) error{awsrate.AddMiddleware})
+}
@@ -0,0 +1,42 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/jbcom/secretsync/internal/awsrate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	assert.NoError(t, (&RateLimitConfig{Services: map[string]float64{"secretsmanager": 40}, Burst: 5}).validate())
+	assert.ErrorContains(t, (&RateLimitConfig{Services: map[string]float64{"sts": 0}}).validate(), "services.sts must be positive")
+	assert.ErrorContains(t, (&RateLimitConfig{Burst: -1}).validate(), "burst must not be negative")
+}
+
+func TestConfigureRateLimits(t *testing.T) {
+	defer configureRateLimits(&AWSConfig{})
+
+	configureRateLimits(&AWSConfig{RateLimits: &RateLimitConfig{Services: map[string]float64{"secretsmanager": 40}}})
+	assert.Equal(t, 40.0, awsrate.Limit("Secrets Manager"))
+
+	configureRateLimits(&AWSConfig{})
+	assert.Zero(t, awsrate.Limit("Secrets Manager"))
+}
+
+func TestNewConfiguresRateLimits(t *testing.T) {
+	defer configureRateLimits(&AWSConfig{})
+
+	_, err := New(&Config{
+		Vault: VaultConfig{Address: "https://vault.example.com"},
+		AWS:   AWSConfig{RateLimits: &RateLimitConfig{Services: map[string]float64{"secretsmanager": 40}}},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 40.0, awsrate.Limit("Secrets Manager"))
+}
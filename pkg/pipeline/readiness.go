@@ -0,0 +1,266 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/jbcom/secretsync/stores/vault"
+)
+
+// Precondition is a readiness check that must pass before a target is synced,
+// so secrets are not pushed into an account whose bootstrap has not completed.
+// Exactly one of HTTP, SSMParameter and VaultPath is set.
+//
+//	targets:
+//	  Sandbox_Alice:
+//	    account_id: "111111111111"
+//	    preconditions:
+//	      - http: https://bootstrap.example.com/accounts/111111111111/ready
+//	      - ssm_parameter: /bootstrap/status
+//	        equals: complete
+//	      - vault_path: secret/bootstrap/111111111111
+type Precondition struct {
+	// HTTP is a URL that must answer a GET with a 2xx status
+	HTTP string `mapstructure:"http" yaml:"http,omitempty"`
+	// SSMParameter must exist in the target's account (the pipeline's own
+	// account for targets without account_id)
+	SSMParameter string `mapstructure:"ssm_parameter" yaml:"ssm_parameter,omitempty"`
+	// Equals is the value SSMParameter must have; empty accepts any value
+	Equals string `mapstructure:"equals" yaml:"equals,omitempty"`
+	// VaultPath is a Vault KV secret that must exist, e.g. secret/bootstrap/111111111111
+	VaultPath string `mapstructure:"vault_path" yaml:"vault_path,omitempty"`
+}
+
+// preconditionTimeout bounds each readiness check
+const preconditionTimeout = 10 * time.Second
+
+// String describes the check, e.g. "ssm_parameter /bootstrap/status = complete"
+func (pc Precondition) String() string {
+	switch {
+	case pc.HTTP != "":
+		return "http " + pc.HTTP
+	case pc.SSMParameter != "" && pc.Equals != "":
+		return fmt.Sprintf("ssm_parameter %s = %s", pc.SSMParameter, pc.Equals)
+	case pc.SSMParameter != "":
+		return "ssm_parameter " + pc.SSMParameter
+	default:
+		return "vault_path " + pc.VaultPath
+	}
+}
+
+func (pc Precondition) validate() error {
+	set := 0
+	for _, s := range []string{pc.HTTP, pc.SSMParameter, pc.VaultPath} {
+		if s != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of http, ssm_parameter and vault_path is required")
+	}
+	if pc.Equals != "" && pc.SSMParameter == "" {
+		return fmt.Errorf("equals requires ssm_parameter")
+	}
+	if pc.HTTP != "" {
+		u, err := url.Parse(pc.HTTP)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("http %q must be an http or https URL", pc.HTTP)
+		}
+	}
+	return nil
+}
+
+// preconditionsFor substitutes {{.AccountID}} in preconditions of a discovered target
+func preconditionsFor(list []Precondition, accountID string) []Precondition {
+	if len(list) == 0 {
+		return nil
+	}
+	out := make([]Precondition, len(list))
+	for i, pc := range list {
+		out[i] = Precondition{
+			HTTP:         strings.ReplaceAll(pc.HTTP, "{{.AccountID}}", accountID),
+			SSMParameter: strings.ReplaceAll(pc.SSMParameter, "{{.AccountID}}", accountID),
+			Equals:       strings.ReplaceAll(pc.Equals, "{{.AccountID}}", accountID),
+			VaultPath:    strings.ReplaceAll(pc.VaultPath, "{{.AccountID}}", accountID),
+		}
+	}
+	return out
+}
+
+// NotReadyError reports a target that was not synced because one of its
+// preconditions failed
+type NotReadyError struct {
+	Target       string
+	Precondition Precondition
+	Reason       string
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("target %q not ready: %s: %s", e.Target, e.Precondition, e.Reason)
+}
+
+// readinessProbe performs the lookups preconditions are checked against
+type readinessProbe interface {
+	HTTPStatus(ctx context.Context, rawURL string) (int, error)
+	SSMParameter(ctx context.Context, target Target, name string) (string, error)
+	VaultSecret(ctx context.Context, path string) error
+}
+
+// checkPreconditions checks a target's preconditions in order and returns a
+// *NotReadyError for the first that fails
+func checkPreconditions(ctx context.Context, probe readinessProbe, targetName string, target Target) error {
+	for _, pc := range target.Preconditions {
+		if reason := checkPrecondition(ctx, probe, target, pc); reason != "" {
+			return &NotReadyError{Target: targetName, Precondition: pc, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// checkPrecondition returns why pc is not met, or "" if it is
+func checkPrecondition(ctx context.Context, probe readinessProbe, target Target, pc Precondition) string {
+	ctx, cancel := context.WithTimeout(ctx, preconditionTimeout)
+	defer cancel()
+
+	switch {
+	case pc.HTTP != "":
+		status, err := probe.HTTPStatus(ctx, pc.HTTP)
+		if err != nil {
+			return err.Error()
+		}
+		if status < 200 || status > 299 {
+			return fmt.Sprintf("status %d", status)
+		}
+	case pc.SSMParameter != "":
+		value, err := probe.SSMParameter(ctx, target, pc.SSMParameter)
+		if err != nil {
+			return err.Error()
+		}
+		// The value itself is not reported, it may be a SecureString
+		if pc.Equals != "" && value != pc.Equals {
+			return fmt.Sprintf("value is not %q", pc.Equals)
+		}
+	case pc.VaultPath != "":
+		if err := probe.VaultSecret(ctx, pc.VaultPath); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// liveReadinessProbe checks preconditions against HTTP endpoints, SSM in the
+// target accounts and Vault
+type liveReadinessProbe struct {
+	config *Config
+	awsCtx *AWSExecutionContext
+	client *http.Client
+
+	vaultMu sync.Mutex
+	vault   *vault.VaultClient
+}
+
+func newLiveReadinessProbe(cfg *Config, awsCtx *AWSExecutionContext) *liveReadinessProbe {
+	return &liveReadinessProbe{
+		config: cfg,
+		awsCtx: awsCtx,
+		client: &http.Client{Timeout: preconditionTimeout},
+	}
+}
+
+func (r *liveReadinessProbe) HTTPStatus(ctx context.Context, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (r *liveReadinessProbe) SSMParameter(ctx context.Context, target Target, name string) (string, error) {
+	cfg, err := r.awsConfig(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	output, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get SSM parameter %s: %w", name, err)
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", fmt.Errorf("SSM parameter %s has no value", name)
+	}
+	return aws.ToString(output.Parameter.Value), nil
+}
+
+// awsConfig returns AWS config for the target's account and region, assuming
+// the target's role (or the configured role pattern) for other accounts
+func (r *liveReadinessProbe) awsConfig(ctx context.Context, target Target) (aws.Config, error) {
+	var base aws.Config
+	if r.awsCtx != nil {
+		base = r.awsCtx.BaseConfig.Copy()
+	} else {
+		cfg, err := config.LoadDefaultConfig(ctx, withRateLimit())
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		base = cfg
+	}
+	if target.Region != "" {
+		base.Region = target.Region
+	} else if r.config.AWS.Region != "" {
+		base.Region = r.config.AWS.Region
+	}
+
+	if target.AccountID == "" {
+		return base, nil
+	}
+	if r.awsCtx != nil && r.awsCtx.CallerIdentity != nil && r.awsCtx.CallerIdentity.AccountID == target.AccountID {
+		return base, nil
+	}
+	roleARN := target.RoleARN
+	if roleARN == "" {
+		roleARN = r.config.GetRoleARN(target.AccountID)
+	}
+	base.Credentials = assumeRoleCredentials(base, r.config.roleChain(target), roleARN, r.config.assumeRole(target).stsOptions(r.config.AWS.session, "", target.AccountID))
+	return base, nil
+}
+
+// vaultClient returns the probe's Vault client, initializing it on first use
+func (r *liveReadinessProbe) vaultClient(ctx context.Context) (*vault.VaultClient, error) {
+	r.vaultMu.Lock()
+	defer r.vaultMu.Unlock()
+	if r.vault == nil {
+		vc := &vault.VaultClient{
+			Address:   r.config.Vault.Address,
+			Namespace: r.config.Vault.Namespace,
+		}
+		if err := vc.Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to initialize vault client: %w", err)
+		}
+		r.vault = vc
+	}
+	return r.vault, nil
+}
+
+func (r *liveReadinessProbe) VaultSecret(ctx context.Context, path string) error {
+	vc, err := r.vaultClient(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = vc.GetSecret(ctx, path)
+	return err
+}
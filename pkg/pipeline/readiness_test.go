@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReadinessProbe answers readiness lookups from maps
+type fakeReadinessProbe struct {
+	status map[string]int
+	params map[string]string // keyed by account ID + "|" + name
+	vault  map[string]bool
+}
+
+func (f fakeReadinessProbe) HTTPStatus(_ context.Context, rawURL string) (int, error) {
+	status, ok := f.status[rawURL]
+	if !ok {
+		return 0, fmt.Errorf("connection refused")
+	}
+	return status, nil
+}
+
+func (f fakeReadinessProbe) SSMParameter(_ context.Context, target Target, name string) (string, error) {
+	v, ok := f.params[target.AccountID+"|"+name]
+	if !ok {
+		return "", fmt.Errorf("ParameterNotFound")
+	}
+	return v, nil
+}
+
+func (f fakeReadinessProbe) VaultSecret(_ context.Context, path string) error {
+	if !f.vault[path] {
+		return fmt.Errorf("secret not found: %s", path)
+	}
+	return nil
+}
+
+func TestPreconditionValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		pc     Precondition
+		errMsg string
+	}{
+		{name: "http", pc: Precondition{HTTP: "https://bootstrap.example.com/ready"}},
+		{name: "ssm with value", pc: Precondition{SSMParameter: "/bootstrap/status", Equals: "complete"}},
+		{name: "vault", pc: Precondition{VaultPath: "secret/bootstrap/111111111111"}},
+		{name: "empty", pc: Precondition{}, errMsg: "exactly one of"},
+		{name: "two checks", pc: Precondition{HTTP: "https://a.example.com", VaultPath: "secret/x"}, errMsg: "exactly one of"},
+		{name: "equals without ssm", pc: Precondition{VaultPath: "secret/x", Equals: "yes"}, errMsg: "equals requires ssm_parameter"},
+		{name: "not a url", pc: Precondition{HTTP: "bootstrap.example.com"}, errMsg: "must be an http or https URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pc.validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	ctx := context.Background()
+	probe := fakeReadinessProbe{
+		status: map[string]int{
+			"https://bootstrap.example.com/111111111111": 200,
+			"https://bootstrap.example.com/222222222222": 503,
+		},
+		params: map[string]string{
+			"111111111111|/bootstrap/status": "complete",
+			"222222222222|/bootstrap/status": "in-progress",
+		},
+		vault: map[string]bool{"secret/bootstrap/111111111111": true},
+	}
+	preconditions := func(accountID string) []Precondition {
+		return preconditionsFor([]Precondition{
+			{SSMParameter: "/bootstrap/status", Equals: "complete"},
+			{HTTP: "https://bootstrap.example.com/{{.AccountID}}"},
+			{VaultPath: "secret/bootstrap/{{.AccountID}}"},
+		}, accountID)
+	}
+
+	assert.NoError(t, checkPreconditions(ctx, probe, "Ready", Target{AccountID: "111111111111", Preconditions: preconditions("111111111111")}))
+	assert.NoError(t, checkPreconditions(ctx, probe, "NoChecks", Target{AccountID: "333333333333"}))
+
+	// The first failing precondition is reported, without the parameter's value
+	err := checkPreconditions(ctx, probe, "Bootstrapping", Target{AccountID: "222222222222", Preconditions: preconditions("222222222222")})
+	var notReady *NotReadyError
+	require.True(t, errors.As(err, &notReady))
+	assert.Equal(t, "Bootstrapping", notReady.Target)
+	assert.EqualError(t, err, `target "Bootstrapping" not ready: ssm_parameter /bootstrap/status = complete: value is not "complete"`)
+
+	err = checkPreconditions(ctx, probe, "Bootstrapping", Target{AccountID: "222222222222", Preconditions: preconditions("222222222222")[1:]})
+	assert.EqualError(t, err, `target "Bootstrapping" not ready: http https://bootstrap.example.com/222222222222: status 503`)
+
+	err = checkPreconditions(ctx, probe, "Bootstrapping", Target{AccountID: "222222222222", Preconditions: preconditions("222222222222")[2:]})
+	assert.EqualError(t, err, `target "Bootstrapping" not ready: vault_path secret/bootstrap/222222222222: secret not found: secret/bootstrap/222222222222`)
+}
+
+func TestTargetTemplatePreconditions(t *testing.T) {
+	cfg := &Config{
+		TargetTemplates: map[string]TargetTemplate{
+			"sandbox": {
+				Preconditions: []Precondition{{HTTP: "https://bootstrap.example.com/{{.AccountID}}/ready"}},
+			},
+		},
+		Targets: map[string]Target{
+			"Sandbox_A": {Template: "sandbox", AccountID: "111111111111"},
+			"Sandbox_B": {Template: "sandbox", AccountID: "222222222222", Preconditions: []Precondition{{VaultPath: "secret/{{.Name}}"}}},
+		},
+	}
+	require.NoError(t, cfg.expandTargetTemplates())
+	assert.Equal(t, []Precondition{{HTTP: "https://bootstrap.example.com/111111111111/ready"}}, cfg.Targets["Sandbox_A"].Preconditions)
+	assert.Equal(t, []Precondition{{VaultPath: "secret/Sandbox_B"}}, cfg.Targets["Sandbox_B"].Preconditions)
+}
+
+func TestConfigValidatePreconditions(t *testing.T) {
+	base := func() Config {
+		return Config{
+			Vault:      VaultConfig{Address: "https://vault.example.com"},
+			Sources:    map[string]Source{"analytics": {Vault: &VaultSource{Mount: "analytics"}}},
+			MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+			Targets:    map[string]Target{"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}}},
+		}
+	}
+
+	cfg := base()
+	cfg.Targets["Stg"] = Target{AccountID: "111111111111", Imports: []string{"analytics"}, Preconditions: []Precondition{{Equals: "complete"}}}
+	assert.EqualError(t, cfg.Validate(), `target "Stg": preconditions[0]: exactly one of http, ssm_parameter and vault_path is required`)
+
+	cfg = base()
+	cfg.DynamicTargets = map[string]DynamicTarget{
+		"sandboxes": {
+			Discovery:     DiscoveryConfig{AccountsList: &AccountsListDiscovery{Source: "ssm:/accounts"}},
+			Preconditions: []Precondition{{HTTP: "https://bootstrap.example.com/{{.AccountID}}/ready"}},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.DynamicTargets = map[string]DynamicTarget{
+		"repos": {
+			Discovery:     DiscoveryConfig{GitHub: &GitHubDiscovery{Org: "acme"}},
+			Preconditions: []Precondition{{VaultPath: "secret/ready"}},
+		},
+	}
+	cfg.GitHub = &GitHubConfig{}
+	assert.ErrorContains(t, cfg.Validate(), "preconditions require identity_center, organizations or accounts_list discovery")
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ResultsSchemaVersion is the version of the RunResults schema. It changes
+// only when a field is removed or changes meaning; new fields may be added at
+// any time.
+const ResultsSchemaVersion = 1
+
+// RunResults is the machine-readable record of a run written by
+// `vss pipeline --results-file`, for archiving and auditing runs
+type RunResults struct {
+	SchemaVersion int       `json:"schema_version"`
+	VSSVersion    string    `json:"vss_version,omitempty"`
+	ConfigFile    string    `json:"config_file,omitempty"`
+	Operator      string    `json:"operator,omitempty"`
+	Operation     Operation `json:"operation"`
+	DryRun        bool      `json:"dry_run"`
+	Started       time.Time `json:"started"`
+	Finished      time.Time `json:"finished"`
+	// Success is false if the run failed or any target's phase failed
+	Success bool     `json:"success"`
+	Error   string   `json:"error,omitempty"`
+	Results []Result `json:"results"`
+}
+
+// NewRunResults builds the results file of a run
+func NewRunResults(opts Options, started, finished time.Time, results []Result, err error) RunResults {
+	rr := RunResults{
+		SchemaVersion: ResultsSchemaVersion,
+		VSSVersion:    opts.Provenance.Version,
+		ConfigFile:    opts.Provenance.ConfigFile,
+		Operator:      opts.Provenance.Operator,
+		Operation:     opts.Operation,
+		DryRun:        opts.DryRun,
+		Started:       started,
+		Finished:      finished,
+		Success:       err == nil,
+		Results:       results,
+	}
+	if err != nil {
+		rr.Error = err.Error()
+	}
+	for _, r := range results {
+		if !r.Success {
+			rr.Success = false
+		}
+	}
+	if rr.Results == nil {
+		rr.Results = []Result{}
+	}
+	return rr
+}
+
+// WriteResultsFile writes rr to path as indented JSON. The file is replaced
+// in one step, so readers never see a partial file.
+func WriteResultsFile(path string, rr RunResults) error {
+	data, err := json.MarshalIndent(rr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".results-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	return nil
+}
+
+// MarshalJSON encodes Error as its message, which encoding/json cannot do
+// for an error value
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	out := struct {
+		result
+		Error string `json:"error,omitempty"`
+	}{result: result(r)}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteResultsFile(t *testing.T) {
+	started := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	results := []Result{
+		{
+			Target: "Serverless_Stg", Phase: "merge", Operation: "merge", Success: false,
+			Error:    errors.New("failed to read import"),
+			Duration: 1500 * time.Millisecond,
+			Details:  ResultDetails{FailedImports: []string{"analytics"}},
+		},
+		{
+			Target: "Serverless_Stg", Phase: "sync", Operation: "sync", Success: true,
+			Details: ResultDetails{RoleARN: "arn:aws:iam::111111111111:role/AWSControlTowerExecution"},
+			Diff:    &diff.TargetDiff{Target: "Serverless_Stg", Summary: diff.ChangeSummary{Added: 2, Total: 2}},
+		},
+	}
+	opts := Options{
+		Operation:  OperationPipeline,
+		Provenance: Provenance{Version: "v1.5.0", ConfigFile: "config.yaml", Operator: "ci"},
+	}
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	require.NoError(t, WriteResultsFile(path, NewRunResults(opts, started, started.Add(time.Minute), results, nil)))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, float64(ResultsSchemaVersion), got["schema_version"])
+	assert.Equal(t, "v1.5.0", got["vss_version"])
+	assert.Equal(t, "pipeline", got["operation"])
+	// A failed phase fails the run even without a run error
+	assert.Equal(t, false, got["success"])
+
+	res := got["results"].([]interface{})
+	require.Len(t, res, 2)
+	merge := res[0].(map[string]interface{})
+	assert.Equal(t, "failed to read import", merge["error"])
+	assert.Equal(t, float64(1500*time.Millisecond), merge["duration"])
+	assert.Equal(t, []interface{}{"analytics"}, merge["details"].(map[string]interface{})["failed_imports"])
+	sync := res[1].(map[string]interface{})
+	assert.NotContains(t, sync, "error")
+	assert.Equal(t, "arn:aws:iam::111111111111:role/AWSControlTowerExecution", sync["details"].(map[string]interface{})["role_arn"])
+	assert.Equal(t, float64(2), sync["diff"].(map[string]interface{})["summary"].(map[string]interface{})["added"])
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp file left behind")
+}
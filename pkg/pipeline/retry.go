@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/internal/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy retries a target's merge or sync when it fails with a transient
+// error such as a Vault 503 or STS throttling. Without a policy each target is
+// tried once.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first (default 3)
+	MaxAttempts int `mapstructure:"max_attempts" yaml:"max_attempts,omitempty"`
+	// InitialBackoff is the wait before the first retry, doubled for each
+	// one after it (default 1s)
+	InitialBackoff time.Duration `mapstructure:"initial_backoff" yaml:"initial_backoff,omitempty"`
+	// MaxBackoff caps the wait between tries (default 30s)
+	MaxBackoff time.Duration `mapstructure:"max_backoff" yaml:"max_backoff,omitempty"`
+	// RetryOn lists further error message substrings treated as transient
+	RetryOn []string `mapstructure:"retry_on" yaml:"retry_on,omitempty"`
+}
+
+// transientErrorCodes are AWS API error codes worth retrying
+var transientErrorCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"TooManyRequestsException":               true,
+	"RequestLimitExceeded":                   true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"ProvisionedThroughputExceededException": true,
+	"ServiceUnavailable":                     true,
+	"ServiceUnavailableException":            true,
+	"InternalServiceError":                   true,
+	"InternalFailure":                        true,
+	"IDPCommunicationError":                  true,
+}
+
+// transientErrorMessages are matched against error messages whose types were
+// lost in wrapping, e.g. Vault's "Code: 503" responses
+var transientErrorMessages = []string{
+	"Code: 429",
+	"Code: 502",
+	"Code: 503",
+	"Code: 504",
+	"Vault is sealed",
+	"Throttling",
+	"TooManyRequests",
+	"RequestLimitExceeded",
+	"rate exceeded",
+	"connection reset by peer",
+	"connection refused",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"unexpected EOF",
+}
+
+func (r *RetryPolicy) validate() error {
+	if r.MaxAttempts < 0 {
+		return errors.New("max_attempts must not be negative")
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		return errors.New("backoff must not be negative")
+	}
+	if r.InitialBackoff > 0 && r.MaxBackoff > 0 && r.InitialBackoff > r.MaxBackoff {
+		return fmt.Errorf("initial_backoff %s exceeds max_backoff %s", r.InitialBackoff, r.MaxBackoff)
+	}
+	for _, s := range r.RetryOn {
+		if strings.TrimSpace(s) == "" {
+			return errors.New("retry_on entries must not be empty")
+		}
+	}
+	return nil
+}
+
+// attempts returns the total number of tries; a nil policy tries once
+func (r *RetryPolicy) attempts() int {
+	if r == nil {
+		return 1
+	}
+	if r.MaxAttempts == 0 {
+		return 3
+	}
+	return r.MaxAttempts
+}
+
+// delay returns how long to wait before retry number n (1-based)
+func (r *RetryPolicy) delay(n int) time.Duration {
+	backoff := r.InitialBackoff
+	if backoff == 0 {
+		backoff = time.Second
+	}
+	maxBackoff := r.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = 30 * time.Second
+	}
+	d := backoff << (n - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// retryable reports whether err is transient
+func (r *RetryPolicy) retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var hookErr *HookError
+	if errors.As(err, &hookErr) {
+		return false
+	}
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) && transientErrorCodes[apiErr.ErrorCode()] {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := err.Error()
+	for _, s := range transientErrorMessages {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	for _, s := range r.RetryOn {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// runWithRetry runs one target's phase, trying again under the pipeline's
+// retry policy while it fails with a transient error. The result of the last
+// try is returned with the number of tries made.
+func (p *Pipeline) runWithRetry(ctx context.Context, phase, target string, run func() Result) Result {
+	policy := p.config.Pipeline.Retry
+	attempts := policy.attempts()
+
+	var result Result
+	for attempt := 1; ; attempt++ {
+		result = run()
+		result.Attempts = attempt
+		if result.Success || attempt >= attempts || ctx.Err() != nil || !policy.retryable(result.Error) {
+			return result
+		}
+
+		wait := policy.delay(attempt)
+		log.WithFields(log.Fields{
+			"action":  "runWithRetry",
+			"target":  target,
+			"phase":   phase,
+			"attempt": attempt,
+			"of":      attempts,
+			"wait":    wait.String(),
+		}).WithError(result.Error).Warn("Transient failure, retrying")
+		metrics.RegisterPipelineRetry(target, phase)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPIError mimics an AWS SDK error with an error code
+type fakeAPIError struct{ code string }
+
+func (e fakeAPIError) Error() string     { return "api error " + e.code }
+func (e fakeAPIError) ErrorCode() string { return e.code }
+
+func TestRetryPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		errMsg string
+	}{
+		{name: "defaults", policy: RetryPolicy{}},
+		{name: "full", policy: RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute, RetryOn: []string{"lease expired"}}},
+		{name: "negative attempts", policy: RetryPolicy{MaxAttempts: -1}, errMsg: "max_attempts must not be negative"},
+		{name: "negative backoff", policy: RetryPolicy{InitialBackoff: -time.Second}, errMsg: "backoff must not be negative"},
+		{name: "inverted backoff", policy: RetryPolicy{InitialBackoff: time.Minute, MaxBackoff: time.Second}, errMsg: "exceeds max_backoff"},
+		{name: "empty retry_on", policy: RetryPolicy{RetryOn: []string{" "}}, errMsg: "must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	var none *RetryPolicy
+	assert.Equal(t, 1, none.attempts())
+	assert.Equal(t, 3, (&RetryPolicy{}).attempts())
+
+	def := &RetryPolicy{}
+	assert.Equal(t, time.Second, def.delay(1))
+	assert.Equal(t, 4*time.Second, def.delay(3))
+	assert.Equal(t, 30*time.Second, def.delay(10))
+
+	capped := &RetryPolicy{InitialBackoff: 200 * time.Millisecond, MaxBackoff: time.Second}
+	assert.Equal(t, 400*time.Millisecond, capped.delay(2))
+	assert.Equal(t, time.Second, capped.delay(4))
+	assert.Equal(t, time.Second, capped.delay(100))
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := &RetryPolicy{RetryOn: []string{"lease expired"}}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "throttling", err: fmt.Errorf("failed to assume role: %w", fakeAPIError{"ThrottlingException"}), want: true},
+		{name: "access denied", err: fakeAPIError{"AccessDeniedException"}, want: false},
+		{name: "vault 503", err: errors.New("failed to read secret: Error making API request.\n\nCode: 503. Errors:\n\n* Vault is sealed"), want: true},
+		{name: "vault 403", err: errors.New("Code: 403. Errors:\n\n* permission denied"), want: false},
+		{name: "connection reset", err: errors.New("read tcp: connection reset by peer"), want: true},
+		{name: "configured", err: errors.New("lease expired"), want: true},
+		{name: "cancelled", err: fmt.Errorf("failed: %w", context.Canceled), want: false},
+		{name: "hook", err: &HookError{Hook: "change-window", Event: HookPreTarget, Err: errors.New("i/o timeout")}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, policy.retryable(tt.err))
+		})
+	}
+}
+
+func TestRunWithRetry(t *testing.T) {
+	p := &Pipeline{config: &Config{Pipeline: PipelineSettings{Retry: &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}}}}
+	flaky := func(failures int, err error) func() Result {
+		calls := 0
+		return func() Result {
+			calls++
+			if calls <= failures {
+				return Result{Target: "Stg", Phase: "sync", Error: err}
+			}
+			return Result{Target: "Stg", Phase: "sync", Success: true}
+		}
+	}
+	throttled := fakeAPIError{"Throttling"}
+
+	r := p.runWithRetry(context.Background(), "sync", "Stg", flaky(2, throttled))
+	assert.True(t, r.Success)
+	assert.Equal(t, 3, r.Attempts)
+
+	r = p.runWithRetry(context.Background(), "sync", "Stg", flaky(5, throttled))
+	assert.False(t, r.Success)
+	assert.Equal(t, 3, r.Attempts)
+
+	// Permanent errors are not retried
+	r = p.runWithRetry(context.Background(), "sync", "Stg", flaky(5, errors.New("target not found")))
+	assert.False(t, r.Success)
+	assert.Equal(t, 1, r.Attempts)
+
+	// A cancelled run stops waiting for the next try
+	p.config.Pipeline.Retry.InitialBackoff = time.Hour
+	p.config.Pipeline.Retry.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r = p.runWithRetry(ctx, "sync", "Stg", flaky(5, throttled))
+	assert.Equal(t, 1, r.Attempts)
+
+	// Without a policy each target is tried once
+	p.config.Pipeline.Retry = nil
+	r = p.runWithRetry(context.Background(), "sync", "Stg", flaky(1, throttled))
+	require.False(t, r.Success)
+	assert.Equal(t, 1, r.Attempts)
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	awsstore "github.com/jbcom/secretsync/stores/aws"
+)
+
+// maxSessionTags is how many session tags STS accepts on one AssumeRole call
+const maxSessionTags = 50
+
+// validateRoleChain checks hop role ARNs and session tags. Transitive tags
+// cannot be set again by a later hop, so a key may only be used once.
+func validateRoleChain(chain []RoleChainHop) error {
+	seen := make(map[string]int)
+	for i, hop := range chain {
+		parsed, err := arn.Parse(hop.RoleARN)
+		if err != nil || parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+			return fmt.Errorf("[%d]: role_arn %q: not an IAM role ARN", i, hop.RoleARN)
+		}
+		if len(hop.SessionTags) > maxSessionTags {
+			return fmt.Errorf("[%d]: at most %d session_tags are allowed", i, maxSessionTags)
+		}
+		for k, v := range hop.SessionTags {
+			if err := validateSessionTag(k, v); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+			// Session tag keys are case-insensitive
+			if j, ok := seen[strings.ToLower(k)]; ok {
+				return fmt.Errorf("[%d]: session tag %q is already passed on by hop %d", i, k, j)
+			}
+			seen[strings.ToLower(k)] = i
+		}
+	}
+	return nil
+}
+
+// validateSessionTag checks a session tag against the limits of STS
+func validateSessionTag(k, v string) error {
+	switch {
+	case k == "":
+		return fmt.Errorf("session tag keys must not be empty")
+	case strings.HasPrefix(strings.ToLower(k), "aws:"):
+		return fmt.Errorf("session tag %q: the aws: prefix is reserved", k)
+	case len(k) > 128:
+		return fmt.Errorf("session tag %q: keys must be at most 128 characters", k)
+	case len(v) > 256:
+		return fmt.Errorf("session tag %q: values must be at most 256 characters", k)
+	}
+	return nil
+}
+
+// roleChain returns the roles assumed before a target's role: the target's
+// own role_chain, or aws.execution_context.role_chain
+func (c *Config) roleChain(target Target) []RoleChainHop {
+	if len(target.RoleChain) > 0 {
+		return target.RoleChain
+	}
+	return c.AWS.ExecutionContext.RoleChain
+}
+
+// storeRoleChain converts a role chain to the AWS store's
+func storeRoleChain(chain []RoleChainHop) []awsstore.RoleChainHop {
+	if len(chain) == 0 {
+		return nil
+	}
+	hops := make([]awsstore.RoleChainHop, len(chain))
+	for i, hop := range chain {
+		hops[i] = awsstore.RoleChainHop{
+			RoleArn:     hop.RoleARN,
+			ExternalID:  hop.ExternalID,
+			SessionTags: hop.SessionTags,
+		}
+	}
+	return hops
+}
+
+// assumeRoleCredentials returns credentials for roleARN, assumed through
+// chain from cfg's credentials with opts
+func assumeRoleCredentials(cfg aws.Config, chain []RoleChainHop, roleARN string, opts func(*stscreds.AssumeRoleOptions)) aws.CredentialsProvider {
+	return awsstore.AssumeRoleChain(cfg, storeRoleChain(chain), roleARN, opts)
+}
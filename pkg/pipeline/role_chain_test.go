@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssumeRoleCredentialsChain(t *testing.T) {
+	type call struct {
+		accessKey, roleARN, sessionName, externalID, tagKey, tagValue, transitive string
+	}
+	var (
+		mu    sync.Mutex
+		calls []call
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		credential := strings.SplitN(r.Header.Get("Authorization"), "Credential=", 2)[1]
+		accessKey := strings.SplitN(credential, "/", 2)[0]
+		mu.Lock()
+		calls = append(calls, call{
+			accessKey:   accessKey,
+			roleARN:     r.PostForm.Get("RoleArn"),
+			sessionName: r.PostForm.Get("RoleSessionName"),
+			externalID:  r.PostForm.Get("ExternalId"),
+			tagKey:      r.PostForm.Get("Tags.member.1.Key"),
+			tagValue:    r.PostForm.Get("Tags.member.1.Value"),
+			transitive:  r.PostForm.Get("TransitiveTagKeys.member.1"),
+		})
+		n := len(calls)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>HOP%d</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`, n)
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("BASE", "secret", ""),
+		BaseEndpoint: aws.String(srv.URL),
+	}
+	chain := []RoleChainHop{{
+		RoleARN:     "arn:aws:iam::111111111111:role/secrets-hub",
+		ExternalID:  "hub-id",
+		SessionTags: map[string]string{"team": "platform"},
+	}}
+
+	opts := &AssumeRoleOptions{ExternalID: "target-id", SessionName: "vss-{{.Target}}"}
+
+	creds, err := assumeRoleCredentials(cfg, chain, "arn:aws:iam::222222222222:role/AWSControlTowerExecution",
+		opts.stsOptions(roleSession{}, "Serverless_Stg", "222222222222")).Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "HOP2", creds.AccessKeyID)
+	assert.Equal(t, []call{
+		{accessKey: "BASE", roleARN: "arn:aws:iam::111111111111:role/secrets-hub", sessionName: "vss-Serverless_Stg",
+			externalID: "hub-id", tagKey: "team", tagValue: "platform", transitive: "team"},
+		{accessKey: "HOP1", roleARN: "arn:aws:iam::222222222222:role/AWSControlTowerExecution", sessionName: "vss-Serverless_Stg",
+			externalID: "target-id"},
+	}, calls, "the target role is assumed with the hub role's credentials")
+}
+
+func TestValidateRoleChain(t *testing.T) {
+	hub := "arn:aws:iam::111111111111:role/secrets-hub"
+	tests := []struct {
+		name    string
+		chain   []RoleChainHop
+		wantErr string
+	}{
+		{name: "empty"},
+		{name: "valid", chain: []RoleChainHop{{RoleARN: hub, SessionTags: map[string]string{"team": "platform"}}}},
+		{name: "not a role", chain: []RoleChainHop{{RoleARN: "arn:aws:iam::111111111111:user/alice"}}, wantErr: "not an IAM role ARN"},
+		{name: "reserved tag", chain: []RoleChainHop{{RoleARN: hub, SessionTags: map[string]string{"aws:team": "x"}}}, wantErr: "prefix is reserved"},
+		{
+			name: "tag repeated down the chain",
+			chain: []RoleChainHop{
+				{RoleARN: hub, SessionTags: map[string]string{"team": "platform"}},
+				{RoleARN: "arn:aws:iam::333333333333:role/regional-hub", SessionTags: map[string]string{"Team": "data"}},
+			},
+			wantErr: "already passed on by hop 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRoleChain(tt.chain)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoleChainOverride(t *testing.T) {
+	hub := []RoleChainHop{{RoleARN: "arn:aws:iam::111111111111:role/secrets-hub"}}
+	own := []RoleChainHop{{RoleARN: "arn:aws:iam::333333333333:role/regional-hub"}}
+	c := &Config{AWS: AWSConfig{ExecutionContext: ExecutionContextConfig{RoleChain: hub}}}
+
+	assert.Equal(t, hub, c.roleChain(Target{AccountID: "222222222222"}))
+	assert.Equal(t, own, c.roleChain(Target{AccountID: "222222222222", RoleChain: own}))
+}
+
+func TestAssumeRoleCredentialsChainSessionTags(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		forms []map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		form := make(map[string]string)
+		for k := range r.PostForm {
+			if k == "SourceIdentity" || strings.HasPrefix(k, "Tags.") || strings.HasPrefix(k, "TransitiveTagKeys.") {
+				form[k] = r.PostForm.Get(k)
+			}
+		}
+		mu.Lock()
+		forms = append(forms, form)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>HOP</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`)
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("BASE", "secret", ""),
+		BaseEndpoint: aws.String(srv.URL),
+	}
+	chain := []RoleChainHop{{RoleARN: "arn:aws:iam::111111111111:role/secrets-hub", SessionTags: map[string]string{"team": "platform"}}}
+	opts := &AssumeRoleOptions{SessionTags: map[string]string{"vss-run": "{{.RunID}}"}, SourceIdentity: "vss-{{.RunID}}"}
+
+	_, err := assumeRoleCredentials(cfg, chain, "arn:aws:iam::222222222222:role/AWSControlTowerExecution",
+		opts.stsOptions(roleSession{RunID: "run-1"}, "Serverless_Stg", "222222222222")).Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{
+			"SourceIdentity":             "vss-run-1",
+			"Tags.member.1.Key":          "vss-run",
+			"Tags.member.1.Value":        "run-1",
+			"Tags.member.2.Key":          "team",
+			"Tags.member.2.Value":        "platform",
+			"TransitiveTagKeys.member.1": "team",
+		},
+		{
+			"SourceIdentity":      "vss-run-1",
+			"Tags.member.1.Key":   "vss-run",
+			"Tags.member.1.Value": "run-1",
+		},
+	}, forms, "every hop carries the run's source identity and tags")
+}
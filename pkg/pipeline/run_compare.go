@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+)
+
+// RunComparison is what changed, target by target and phase by phase,
+// between two recorded runs
+type RunComparison struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// NewlyFailed failed in To after succeeding in From, with To's error
+	NewlyFailed []PhaseOutcome `json:"newly_failed,omitempty"`
+	// Recovered succeeded in To after failing in From, with From's error
+	Recovered []PhaseOutcome `json:"recovered,omitempty"`
+	// StillFailing failed in both runs, with To's error
+	StillFailing []PhaseOutcome `json:"still_failing,omitempty"`
+	// OnlyInFrom and OnlyInTo were processed by one of the runs only
+	OnlyInFrom []PhaseOutcome `json:"only_in_from,omitempty"`
+	OnlyInTo   []PhaseOutcome `json:"only_in_to,omitempty"`
+
+	// Slower took at least CompareOptions.MinSlowdown longer in To
+	Slower []DurationChange `json:"slower,omitempty"`
+	// Changed made different secret changes in the two runs, as recorded in
+	// their diff summaries
+	Changed []ChangesDelta `json:"changed,omitempty"`
+}
+
+// PhaseOutcome is a target's phase in one of the compared runs
+type PhaseOutcome struct {
+	Target  string `json:"target"`
+	Phase   string `json:"phase"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DurationChange is a target's phase that took longer in the later run
+type DurationChange struct {
+	Target string        `json:"target"`
+	Phase  string        `json:"phase"`
+	From   time.Duration `json:"from"`
+	To     time.Duration `json:"to"`
+}
+
+// Increase is how much longer the phase took, as a fraction of From
+func (d DurationChange) Increase() float64 {
+	if d.From <= 0 {
+		return 0
+	}
+	return float64(d.To-d.From) / float64(d.From)
+}
+
+// ChangesDelta is a target's phase whose secret changes differ between runs
+type ChangesDelta struct {
+	Target string             `json:"target"`
+	Phase  string             `json:"phase"`
+	From   diff.ChangeSummary `json:"from"`
+	To     diff.ChangeSummary `json:"to"`
+}
+
+// String describes the delta as "+1 ~0 -0 → +0 ~2 -0" (added, modified, removed)
+func (d ChangesDelta) String() string {
+	return fmt.Sprintf("+%d ~%d -%d → +%d ~%d -%d",
+		d.From.Added, d.From.Modified, d.From.Removed, d.To.Added, d.To.Modified, d.To.Removed)
+}
+
+// CompareOptions sets how much slower a phase must get to be reported
+type CompareOptions struct {
+	// MinSlowdown is the smallest increase, as a fraction of the earlier
+	// duration, reported as slower (default 0.2)
+	MinSlowdown float64
+	// MinDelta ignores increases shorter than this, which are mostly noise
+	// (default 1s)
+	MinDelta time.Duration
+}
+
+// HasRegressions reports whether any phase newly failed or got slower
+func (c *RunComparison) HasRegressions() bool {
+	return len(c.NewlyFailed) > 0 || len(c.Slower) > 0
+}
+
+// IsEmpty reports whether the runs had the same outcomes
+func (c *RunComparison) IsEmpty() bool {
+	return !c.HasRegressions() && len(c.Recovered) == 0 && len(c.StillFailing) == 0 &&
+		len(c.OnlyInFrom) == 0 && len(c.OnlyInTo) == 0 && len(c.Changed) == 0
+}
+
+type phaseKey struct {
+	target string
+	phase  string
+}
+
+// CompareRuns compares each target's phases in two recorded runs
+func CompareRuns(from, to RunRecord, opts CompareOptions) *RunComparison {
+	if opts.MinSlowdown == 0 {
+		opts.MinSlowdown = 0.2
+	}
+	if opts.MinDelta == 0 {
+		opts.MinDelta = time.Second
+	}
+
+	before := runPhases(from)
+	after := runPhases(to)
+	c := &RunComparison{From: from.ID, To: to.ID}
+	for _, key := range sortedPhaseKeys(before, after) {
+		b, inFrom := before[key]
+		a, inTo := after[key]
+		switch {
+		case !inTo:
+			c.OnlyInFrom = append(c.OnlyInFrom, phaseOutcome(b))
+			continue
+		case !inFrom:
+			c.OnlyInTo = append(c.OnlyInTo, phaseOutcome(a))
+			continue
+		case b.Success && !a.Success:
+			c.NewlyFailed = append(c.NewlyFailed, phaseOutcome(a))
+		case !b.Success && a.Success:
+			c.Recovered = append(c.Recovered, phaseOutcome(b))
+		case !b.Success && !a.Success:
+			c.StillFailing = append(c.StillFailing, phaseOutcome(a))
+		}
+
+		// Failed phases often stop early, so only successes are timed
+		if b.Success && a.Success && a.Duration-b.Duration >= opts.MinDelta &&
+			float64(a.Duration-b.Duration) >= opts.MinSlowdown*float64(b.Duration) {
+			c.Slower = append(c.Slower, DurationChange{Target: key.target, Phase: key.phase, From: b.Duration, To: a.Duration})
+		}
+		// A run that recorded no diff says nothing about what changed
+		if b.Changes != nil && a.Changes != nil && !sameChanges(*b.Changes, *a.Changes) {
+			c.Changed = append(c.Changed, ChangesDelta{Target: key.target, Phase: key.phase, From: *b.Changes, To: *a.Changes})
+		}
+	}
+	// Biggest slowdowns first
+	sort.SliceStable(c.Slower, func(i, j int) bool {
+		return c.Slower[i].Increase() > c.Slower[j].Increase()
+	})
+	return c
+}
+
+// runPhases indexes a run's results by target and phase
+func runPhases(run RunRecord) map[phaseKey]RunResult {
+	phases := make(map[phaseKey]RunResult, len(run.Results))
+	for _, r := range run.Results {
+		phases[phaseKey{r.Target, r.Phase}] = r
+	}
+	return phases
+}
+
+// sortedPhaseKeys returns the keys of both runs by target, merge before sync
+func sortedPhaseKeys(a, b map[phaseKey]RunResult) []phaseKey {
+	seen := make(map[phaseKey]bool, len(a))
+	var keys []phaseKey
+	for _, m := range []map[phaseKey]RunResult{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].target != keys[j].target {
+			return keys[i].target < keys[j].target
+		}
+		return keys[i].phase < keys[j].phase
+	})
+	return keys
+}
+
+func phaseOutcome(r RunResult) PhaseOutcome {
+	return PhaseOutcome{Target: r.Target, Phase: r.Phase, Success: r.Success, Error: r.Error}
+}
+
+// sameChanges compares the secrets added, modified and removed
+func sameChanges(a, b diff.ChangeSummary) bool {
+	return a.Added == b.Added && a.Modified == b.Modified && a.Removed == b.Removed
+}
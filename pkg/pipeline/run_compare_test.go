@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareRuns(t *testing.T) {
+	from := RunRecord{ID: "run-1", Results: []RunResult{
+		{Target: "Serverless_Stg", Phase: "merge", Success: true, Duration: 10 * time.Second},
+		{Target: "Serverless_Stg", Phase: "sync", Success: true, Duration: 2 * time.Second,
+			Changes: &diff.ChangeSummary{Added: 1}},
+		{Target: "Serverless_Prod", Phase: "merge", Success: true, Duration: 4 * time.Second},
+		{Target: "Serverless_Prod", Phase: "sync", Success: false, Error: "AccessDenied"},
+		{Target: "Sandbox_A", Phase: "sync", Success: false, Error: "throttled"},
+		{Target: "Retired", Phase: "sync", Success: true},
+	}}
+	to := RunRecord{ID: "run-2", Results: []RunResult{
+		// 50% slower
+		{Target: "Serverless_Stg", Phase: "merge", Success: true, Duration: 15 * time.Second},
+		// Twice as slow, but by less than MinDelta
+		{Target: "Serverless_Stg", Phase: "sync", Success: true, Duration: 4 * time.Second / 3,
+			Changes: &diff.ChangeSummary{Modified: 2}},
+		{Target: "Serverless_Prod", Phase: "merge", Success: false, Error: "vault sealed"},
+		{Target: "Serverless_Prod", Phase: "sync", Success: true, Duration: 3 * time.Second},
+		{Target: "Sandbox_A", Phase: "sync", Success: false, Error: "throttled"},
+		{Target: "Sandbox_B", Phase: "sync", Success: true},
+	}}
+
+	c := CompareRuns(from, to, CompareOptions{})
+	assert.Equal(t, "run-1", c.From)
+	assert.Equal(t, []PhaseOutcome{{Target: "Serverless_Prod", Phase: "merge", Error: "vault sealed"}}, c.NewlyFailed)
+	assert.Equal(t, []PhaseOutcome{{Target: "Serverless_Prod", Phase: "sync", Error: "AccessDenied"}}, c.Recovered)
+	assert.Equal(t, []PhaseOutcome{{Target: "Sandbox_A", Phase: "sync", Error: "throttled"}}, c.StillFailing)
+	assert.Equal(t, []PhaseOutcome{{Target: "Retired", Phase: "sync", Success: true}}, c.OnlyInFrom)
+	assert.Equal(t, []PhaseOutcome{{Target: "Sandbox_B", Phase: "sync", Success: true}}, c.OnlyInTo)
+	assert.Equal(t, []DurationChange{{Target: "Serverless_Stg", Phase: "merge", From: 10 * time.Second, To: 15 * time.Second}}, c.Slower)
+	assert.InDelta(t, 0.5, c.Slower[0].Increase(), 0.001)
+	assert.Len(t, c.Changed, 1)
+	assert.Equal(t, "+1 ~0 -0 → +0 ~2 -0", c.Changed[0].String())
+	assert.True(t, c.HasRegressions())
+
+	// A run compared with itself has no differences
+	same := CompareRuns(to, to, CompareOptions{})
+	assert.Empty(t, same.Slower)
+	assert.Equal(t, same.StillFailing, []PhaseOutcome{
+		{Target: "Sandbox_A", Phase: "sync", Error: "throttled"},
+		{Target: "Serverless_Prod", Phase: "merge", Error: "vault sealed"},
+	})
+	assert.False(t, same.HasRegressions())
+}
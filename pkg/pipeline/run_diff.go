@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/utils"
+)
+
+// diffing reports whether the current run computes diffs (dry runs and --diff)
+func (p *Pipeline) diffing() bool {
+	return p.Diff() != nil
+}
+
+// pendingMerge records the merged secrets a dry run would have written to its
+// merge store, so the sync phase diffs against them instead of the previous
+// merge
+func (p *Pipeline) pendingMerge(targetName string, merged map[string]map[string]interface{}) {
+	if !p.diffing() {
+		return
+	}
+	snapshot := make(map[string]interface{}, len(merged))
+	for name, data := range merged {
+		snapshot[name] = data
+	}
+	p.diffMu.Lock()
+	defer p.diffMu.Unlock()
+	if p.pendingMerges == nil {
+		p.pendingMerges = make(map[string]map[string]interface{})
+	}
+	p.pendingMerges[targetName] = snapshot
+}
+
+// previewVaultMerge returns the merge store and the target's current merged
+// secrets that a dry run's Vault merge is built on in memory, or a nil store
+// when the run computes no diffs. The sync engine's dry run writes nothing
+// to the Vault merge store, and imports merge into what is already there.
+func (p *Pipeline) previewVaultMerge(ctx context.Context, storeName string, dryRun bool) (mergeStore, map[string]map[string]interface{}, error) {
+	if !dryRun || !p.diffing() {
+		return nil, nil, nil
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshot, err := readSnapshot(ctx, store, storeName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read merged secrets: %w", err)
+	}
+	// Copied, as imports are merged into it in place
+	merged := make(map[string]map[string]interface{}, len(snapshot))
+	for name, v := range snapshot {
+		data, _ := v.(map[string]interface{})
+		merged[name] = utils.DeepMerge(nil, data)
+	}
+	return store, merged, nil
+}
+
+// previewImport merges an import into a dry run's in-memory Vault merge
+func (p *Pipeline) previewImport(ctx context.Context, store mergeStore, ref ImportRef, merged map[string]map[string]interface{}) error {
+	if ref.Version != 0 {
+		return fmt.Errorf("pinned versions cannot be previewed")
+	}
+	secrets, err := p.readImport(ctx, store, ref.Name)
+	if err != nil {
+		return err
+	}
+	mergeSecrets(merged, secrets)
+	return nil
+}
+
+// syncSnapshot returns the merged secrets a target's sync writes: those a dry
+// run merged in memory, or else the merge store's
+func (p *Pipeline) syncSnapshot(ctx context.Context, targetName string) (map[string]interface{}, error) {
+	p.diffMu.Lock()
+	snapshot, ok := p.pendingMerges[targetName]
+	p.diffMu.Unlock()
+	if ok {
+		return snapshot, nil
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err = readSnapshot(ctx, store, targetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merged secrets: %w", err)
+	}
+	return snapshot, nil
+}
+
+// diffable reports whether a destination's current secrets can be read for a
+// diff; only Secrets Manager destinations can
+func (d Destination) diffable() bool {
+	return d.requiresAccountID() && d.Kubernetes == nil
+}
+
+// combineDiffs merges the diffs of a target's destinations into its result's
+// diff
+func combineDiffs(targetName string, owners []string, diffs []diff.TargetDiff) *diff.TargetDiff {
+	if len(diffs) == 0 {
+		return nil
+	}
+	if len(diffs) == 1 {
+		td := diffs[0]
+		td.Target = targetName
+		return &td
+	}
+	td := &diff.TargetDiff{Target: targetName, Changes: []diff.SecretChange{}, Owners: owners}
+	for _, d := range diffs {
+		td.Changes = append(td.Changes, d.Changes...)
+	}
+	td.Summary = diff.ComputeSummary(td.Changes)
+	return td
+}
+
+// syncDiff diffs a destination before its sync writes it, or returns nil
+// when the run computes no diffs or the destination cannot be read
+func (p *Pipeline) syncDiff(ctx context.Context, targetName, sourcePath string, target Target, d Destination, snapshot map[string]interface{}, snapshotErr error) (*diff.TargetDiff, error) {
+	if !p.diffing() || !d.diffable() {
+		return nil, nil
+	}
+	if snapshotErr != nil {
+		return nil, snapshotErr
+	}
+	td, err := p.destinationDrift(ctx, p.driftReader, targetName, sourcePath, target, d, snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff destination: %w", err)
+	}
+	return &td, nil
+}
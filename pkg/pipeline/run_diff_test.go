@@ -0,0 +1,148 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncTargetDiff(t *testing.T) {
+	wait := backend.ManualTriggerAndWait
+	t.Cleanup(func() { backend.ManualTriggerAndWait = wait })
+	var synced []string
+	backend.ManualTriggerAndWait = func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*backend.SyncCompletion, error) {
+		synced = append(synced, cfg.Name)
+		return &backend.SyncCompletion{Name: cfg.Name}, nil
+	}
+
+	ctx := context.Background()
+	store := memMergeStore{"Prod": {"db": {"password": "old"}, "api": {"token": "abc"}}}
+	p := &Pipeline{
+		config: &Config{
+			Targets: map[string]Target{"Prod": {
+				Owners: []string{"platform"},
+				Destinations: []Destination{
+					{AccountID: "111111111111"},
+					{AccountID: "222222222222"},
+					{Doppler: &DopplerDestination{Project: "analytics", Config: "prd"}},
+				},
+			}},
+		},
+		memStore: memDirectStore{store},
+		driftReader: fakeDestinationReader{
+			"111111111111": {"db": `{"password":"old"}`},
+			"222222222222": {"db": `{"password":"old"}`, "api": `{"token":"abc"}`},
+		},
+	}
+
+	// Without a diff nothing is read
+	result := p.syncTarget(ctx, "Prod", false)
+	assert.True(t, result.Success)
+	assert.Nil(t, result.Diff)
+
+	// A dry run diffs against what its merge would have written
+	p.initDiff(true, "")
+	p.pendingMerge("Prod", map[string]map[string]interface{}{
+		"db":  {"password": "new"},
+		"api": {"token": "abc"},
+	})
+	result = p.syncTarget(ctx, "Prod", true)
+	assert.True(t, result.Success)
+	require.NotNil(t, result.Diff)
+	assert.Equal(t, "Prod", result.Diff.Target)
+	assert.Equal(t, []string{"platform"}, result.Diff.Owners)
+	assert.Equal(t, diff.ChangeSummary{Added: 1, Modified: 2, Unchanged: 1, Total: 4}, result.Diff.Summary)
+
+	pd := p.Diff()
+	require.Len(t, pd.Targets, 2, "destinations that cannot be read back are not diffed")
+	assert.True(t, result.Details.Destinations[0].Diffed)
+	assert.False(t, result.Details.Destinations[2].Diffed)
+	assert.Equal(t, "Prod (aws:111111111111)", pd.Targets[0].Target)
+	assert.Equal(t, diff.ChangeSummary{Added: 1, Modified: 1, Total: 2}, pd.Targets[0].Summary)
+	assert.Equal(t, "Prod (aws:222222222222)", pd.Targets[1].Target)
+	assert.Equal(t, diff.ExitCodeChanges, p.ExitCode())
+
+	// A dry run whose diff cannot be read fails the destination
+	p.initDiff(true, "")
+	p.driftReader = fakeDestinationReader{"111111111111": {}}
+	synced = nil
+	result = p.syncTarget(ctx, "Prod", true)
+	assert.False(t, result.Success)
+	assert.Equal(t, "failed to diff destination: AccessDenied", result.Details.Destinations[1].Error)
+	assert.Len(t, synced, 2)
+
+	// An apply with --diff syncs anyway, diffing against the merge store
+	p.initDiff(false, "")
+	synced = nil
+	result = p.syncTarget(ctx, "Prod", false)
+	assert.True(t, result.Success)
+	assert.Len(t, synced, 3)
+	require.NotNil(t, result.Diff)
+	assert.Equal(t, diff.ChangeSummary{Added: 2, Total: 2}, result.Diff.Summary)
+}
+
+func TestSyncTargetDiffVaultMergeStore(t *testing.T) {
+	wait := backend.ManualTriggerAndWait
+	t.Cleanup(func() { backend.ManualTriggerAndWait = wait })
+	var synced []string
+	backend.ManualTriggerAndWait = func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*backend.SyncCompletion, error) {
+		synced = append(synced, cfg.Name)
+		return &backend.SyncCompletion{Name: cfg.Name}, nil
+	}
+
+	ctx := context.Background()
+	// The sync engine's dry run leaves the merge store as it is
+	store := memMergeStore{"Prod": {"db": {"password": "old", "user": "app"}}}
+	p := &Pipeline{
+		config: &Config{
+			MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+			Sources: map[string]Source{
+				"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+			},
+			Targets: map[string]Target{"Prod": {
+				Imports:      []string{"analytics"},
+				Destinations: []Destination{{AccountID: "111111111111"}},
+			}},
+		},
+		vaultStore: store,
+		openVaultSource: func(context.Context, *VaultSource) (vaultReader, error) {
+			return fakeVault{
+				"analytics/db":  {"password": "new"},
+				"analytics/api": {"token": "abc"},
+			}, nil
+		},
+		driftReader: fakeDestinationReader{
+			"111111111111": {"db": `{"password":"old","user":"app"}`},
+		},
+	}
+
+	p.initDiff(true, "")
+	merge := p.mergeTarget(ctx, "Prod", true)
+	require.True(t, merge.Success, "%v", merge.Error)
+	assert.Equal(t, []string{"merge-analytics-to-Prod"}, synced)
+	assert.Equal(t, "old", store["Prod"]["db"]["password"])
+
+	// Imports merge into the current merged secrets
+	result := p.syncTarget(ctx, "Prod", true)
+	assert.True(t, result.Success)
+	require.NotNil(t, result.Diff)
+	assert.Equal(t, diff.ChangeSummary{Added: 1, Modified: 1, Total: 2}, result.Diff.Summary)
+
+	// A pinned import cannot be read at its version, so the diff falls back
+	// to the merge store
+	target := p.config.Targets["Prod"]
+	target.Imports = []string{"analytics@2"}
+	p.config.Targets["Prod"] = target
+	p.initDiff(true, "")
+	merge = p.mergeTarget(ctx, "Prod", true)
+	require.True(t, merge.Success)
+	result = p.syncTarget(ctx, "Prod", true)
+	require.NotNil(t, result.Diff)
+	assert.Equal(t, diff.ChangeSummary{Unchanged: 1, Total: 1}, result.Diff.Summary)
+}
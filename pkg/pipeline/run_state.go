@@ -0,0 +1,499 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// StateSettings keeps each run's progress, per target and phase, so a failed
+// run can be resumed with `vss pipeline --resume <run-id>`, which retries
+// only what did not succeed. Exactly one of dir, s3 or dynamodb is set.
+//
+//	pipeline:
+//	  state:
+//	    s3:
+//	      bucket: vss-state
+//	      prefix: runs/
+type StateSettings struct {
+	// Dir keeps each run's state in a local JSON file
+	Dir string `mapstructure:"dir" yaml:"dir,omitempty"`
+	// S3 keeps each run's state in an S3 object
+	S3 *StateS3 `mapstructure:"s3" yaml:"s3,omitempty"`
+	// DynamoDB keeps each run's state in a table item
+	DynamoDB *StateDynamoDB `mapstructure:"dynamodb" yaml:"dynamodb,omitempty"`
+}
+
+// StateS3 is where run states are kept in S3: one object per run under Prefix
+type StateS3 struct {
+	Bucket   string `mapstructure:"bucket" yaml:"bucket"`
+	Prefix   string `mapstructure:"prefix" yaml:"prefix,omitempty"`
+	KMSKeyID string `mapstructure:"kms_key_id" yaml:"kms_key_id,omitempty"`
+}
+
+// StateDynamoDB is the table run states are kept in: one item per run, with
+// the string partition key run_id
+type StateDynamoDB struct {
+	Table string `mapstructure:"table" yaml:"table"`
+}
+
+func (s *StateSettings) validate() error {
+	set := 0
+	if s.Dir != "" {
+		set++
+	}
+	if s.S3 != nil {
+		set++
+		if s.S3.Bucket == "" {
+			return fmt.Errorf("s3.bucket is required")
+		}
+	}
+	if s.DynamoDB != nil {
+		set++
+		if s.DynamoDB.Table == "" {
+			return fmt.Errorf("dynamodb.table is required")
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of dir, s3 or dynamodb is required")
+	}
+	return nil
+}
+
+// RunState is a run's progress as kept in the state store. A resumed run
+// keeps updating the state of the run it resumes.
+type RunState struct {
+	ID        string    `json:"id"`
+	Operation Operation `json:"operation"`
+	// Targets are the run's targets in dependency order
+	Targets []string  `json:"targets"`
+	Started time.Time `json:"started"`
+	Updated time.Time `json:"updated"`
+	// Phases holds each target's latest outcome by phase, then target
+	Phases map[string]map[string]TargetPhaseState `json:"phases"`
+}
+
+// TargetPhaseState is a target's latest outcome in one phase of a run
+type TargetPhaseState struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// ContentHash is the SHA-256 of the target's merged secrets when the
+	// phase finished
+	ContentHash string    `json:"content_hash,omitempty"`
+	Finished    time.Time `json:"finished"`
+}
+
+// runStateStore persists run states
+type runStateStore interface {
+	// load returns the run's state, or nil when no run has the ID
+	load(ctx context.Context, id string) (*RunState, error)
+	save(ctx context.Context, state *RunState) error
+}
+
+// openStateStore returns the store pipeline.state configures
+func (p *Pipeline) openStateStore(ctx context.Context) (runStateStore, error) {
+	if p.stateStore != nil {
+		return p.stateStore, nil
+	}
+	settings := p.config.Pipeline.State
+	if settings.Dir != "" {
+		p.stateStore = fileRunStateStore{dir: settings.Dir}
+		return p.stateStore, nil
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(p.config.AWS.Region), withRateLimit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if settings.S3 != nil {
+		p.stateStore = &s3RunStateStore{location: *settings.S3, client: s3.NewFromConfig(awsCfg)}
+	} else {
+		p.stateStore = &dynamoDBRunStateStore{table: settings.DynamoDB.Table, client: dynamodb.NewFromConfig(awsCfg)}
+	}
+	return p.stateStore, nil
+}
+
+// runStateTracker records a run's target outcomes in the state store as they
+// finish and, when the run resumes another, skips the phases that already
+// succeeded. A nil tracker records and skips nothing.
+type runStateTracker struct {
+	store  runStateStore
+	merged mergeStore
+	// record is false for dry runs, which only report what would be retried
+	record   bool
+	resuming bool
+
+	mu    sync.Mutex
+	state *RunState
+	// rerun holds the targets whose phase ran in this run, by phase
+	rerun map[string]map[string]bool
+}
+
+// startRunState begins tracking a run's state. resumed is the state of the
+// run being resumed, if any; otherwise a new state is started under id.
+func (p *Pipeline) startRunState(ctx context.Context, id string, opts Options, targets []string, resumed *RunState) (*runStateTracker, error) {
+	store, err := p.openStateStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &runStateTracker{
+		store:    store,
+		merged:   merged,
+		record:   !opts.DryRun,
+		resuming: resumed != nil,
+		state:    resumed,
+		rerun:    make(map[string]map[string]bool),
+	}
+	if resumed == nil {
+		t.state = &RunState{
+			ID:        id,
+			Operation: opts.Operation,
+			Targets:   targets,
+			Started:   time.Now(),
+			Phases:    make(map[string]map[string]TargetPhaseState),
+		}
+	}
+	if !t.record {
+		return t, nil
+	}
+	t.state.Updated = time.Now()
+	if err := store.save(ctx, t.state); err != nil {
+		return nil, fmt.Errorf("failed to save run state: %w", err)
+	}
+	return t, nil
+}
+
+// loadResumeState returns the state of the run to resume
+func (p *Pipeline) loadResumeState(ctx context.Context, id string) (*RunState, error) {
+	if p.config.Pipeline.State == nil {
+		return nil, &OptionError{Option: "resume", Value: fmt.Sprintf("%q", id), Reason: "requires pipeline.state"}
+	}
+	store, err := p.openStateStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state, err := store.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, &OptionError{Option: "resume", Value: fmt.Sprintf("%q", id), Reason: "run not found in the state store"}
+	}
+	if state.Phases == nil {
+		state.Phases = make(map[string]map[string]TargetPhaseState)
+	}
+	return state, nil
+}
+
+// resumeTargets returns the resumed run's targets that still exist. Targets
+// discovered by the original run are only found again with --discover.
+func (p *Pipeline) resumeTargets(state *RunState) []string {
+	var targets []string
+	for _, name := range state.Targets {
+		if _, ok := p.graph.Nodes[name]; ok {
+			targets = append(targets, name)
+			continue
+		}
+		log.WithFields(log.Fields{
+			"action": "Pipeline.resumeTargets",
+			"run":    state.ID,
+			"target": name,
+		}).Warn("Target of the resumed run no longer exists, skipping it")
+	}
+	return targets
+}
+
+// skip reports whether a resumed run can skip a target's phase, returning the
+// result to report for it. A merge is skipped when it succeeded and none of
+// its dependencies merged again in this run; a sync when it succeeded and
+// the target's merged secrets are unchanged since.
+func (t *runStateTracker) skip(ctx context.Context, phase, target string, deps []string) (Result, bool) {
+	if t == nil {
+		return Result{}, false
+	}
+	t.mu.Lock()
+	prev, ok := t.state.Phases[phase][target]
+	skip := t.resuming && ok && prev.Success
+	if skip && phase == "merge" {
+		for _, dep := range deps {
+			if t.rerun["merge"][dep] {
+				skip = false
+				break
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	if skip && phase == "sync" {
+		hash, err := mergedContentHash(ctx, t.merged, target)
+		skip = err == nil && prev.ContentHash != "" && hash == prev.ContentHash
+	}
+	if skip {
+		log.WithFields(log.Fields{
+			"action": "runStateTracker.skip",
+			"run":    t.state.ID,
+			"phase":  phase,
+			"target": target,
+		}).Info("Phase succeeded in the resumed run, skipping")
+		return Result{Target: target, Phase: phase, Success: true, Details: ResultDetails{Resumed: true}}, true
+	}
+
+	t.mu.Lock()
+	if t.rerun[phase] == nil {
+		t.rerun[phase] = make(map[string]bool)
+	}
+	t.rerun[phase][target] = true
+	t.mu.Unlock()
+	return Result{}, false
+}
+
+// done records a target's phase outcome. Failing to save it only costs a
+// resumed run a retry of the target, so it is logged, not returned.
+func (t *runStateTracker) done(ctx context.Context, r Result) {
+	if t == nil || !t.record || r.Phase == "" {
+		return
+	}
+	l := log.WithFields(log.Fields{
+		"action": "runStateTracker.done",
+		"phase":  r.Phase,
+		"target": r.Target,
+	})
+	s := TargetPhaseState{Success: r.Success, Finished: time.Now()}
+	if r.Error != nil {
+		s.Error = r.Error.Error()
+	}
+	if r.Success {
+		hash, err := mergedContentHash(ctx, t.merged, r.Target)
+		if err != nil {
+			l.WithError(err).Warn("Failed to hash merged secrets, a resumed run will retry the target")
+		}
+		s.ContentHash = hash
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state.Phases[r.Phase] == nil {
+		t.state.Phases[r.Phase] = make(map[string]TargetPhaseState)
+	}
+	t.state.Phases[r.Phase][r.Target] = s
+	t.state.Updated = s.Finished
+	if err := t.store.save(ctx, t.state); err != nil {
+		l.WithError(err).Warn("Failed to save run state")
+	}
+}
+
+// id returns the ID the run's state is recorded under, or "" if it is not
+func (t *runStateTracker) id() string {
+	if t == nil || !t.record {
+		return ""
+	}
+	return t.state.ID
+}
+
+// ResumeID returns the ID the last run's state was recorded under, for
+// `vss pipeline --resume`, or "" when pipeline.state is not set or the run
+// was a dry run
+func (p *Pipeline) ResumeID() string {
+	return p.runState.id()
+}
+
+// mergedContentHash returns the SHA-256 of a target's merged secrets
+func mergedContentHash(ctx context.Context, store mergeStore, target string) (string, error) {
+	snapshot, err := readSnapshot(ctx, store, target)
+	if err != nil {
+		return "", err
+	}
+	// Maps marshal with sorted keys, so equal secrets hash equally
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fileRunStateStore keeps each run's state in dir/<run-id>.json
+type fileRunStateStore struct {
+	dir string
+}
+
+func (f fileRunStateStore) load(ctx context.Context, id string) (*RunState, error) {
+	data, err := os.ReadFile(filepath.Join(f.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state: %w", err)
+	}
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode run state %s: %w", id, err)
+	}
+	return &state, nil
+}
+
+func (f fileRunStateStore) save(ctx context.Context, state *RunState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	// Write and rename so a crash never leaves a partial state
+	path := filepath.Join(f.dir, state.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write run state: %w", err)
+	}
+	return nil
+}
+
+// runStateS3 is the part of the S3 API the state store uses
+type runStateS3 interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// s3RunStateStore keeps each run's state in <prefix><run-id>.json
+type s3RunStateStore struct {
+	location StateS3
+	client   runStateS3
+}
+
+func (s *s3RunStateStore) key(id string) string {
+	prefix := s.location.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + id + ".json"
+}
+
+func (s *s3RunStateStore) load(ctx context.Context, id string) (*RunState, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.location.Bucket),
+		Key:    aws.String(s.key(id)),
+	})
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run state: %w", err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run state: %w", err)
+	}
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode run state %s: %w", id, err)
+	}
+	return &state, nil
+}
+
+func (s *s3RunStateStore) save(ctx context.Context, state *RunState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.location.Bucket),
+		Key:         aws.String(s.key(state.ID)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if s.location.KMSKeyID != "" {
+		input.ServerSideEncryption = "aws:kms"
+		input.SSEKMSKeyId = aws.String(s.location.KMSKeyID)
+	} else {
+		input.ServerSideEncryption = "AES256"
+	}
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put run state: %w", err)
+	}
+	return nil
+}
+
+// runStateDynamoDB is the part of the DynamoDB API the state store uses
+type runStateDynamoDB interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// dynamoDBRunStateStore keeps each run's state as JSON in the state
+// attribute of the item keyed by run_id
+type dynamoDBRunStateStore struct {
+	table  string
+	client runStateDynamoDB
+}
+
+func (d *dynamoDBRunStateStore) load(ctx context.Context, id string) (*RunState, error) {
+	output, err := d.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            map[string]ddbtypes.AttributeValue{"run_id": &ddbtypes.AttributeValueMemberS{Value: id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get run state: %w", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+	data, ok := output.Item["state"].(*ddbtypes.AttributeValueMemberS)
+	if !ok {
+		return nil, fmt.Errorf("run state %s has no state attribute", id)
+	}
+	var state RunState
+	if err := json.Unmarshal([]byte(data.Value), &state); err != nil {
+		return nil, fmt.Errorf("failed to decode run state %s: %w", id, err)
+	}
+	return &state, nil
+}
+
+func (d *dynamoDBRunStateStore) save(ctx context.Context, state *RunState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = d.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item: map[string]ddbtypes.AttributeValue{
+			"run_id":  &ddbtypes.AttributeValueMemberS{Value: state.ID},
+			"state":   &ddbtypes.AttributeValueMemberS{Value: string(data)},
+			"updated": &ddbtypes.AttributeValueMemberS{Value: state.Updated.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put run state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resumeTestPipeline(t *testing.T, store memMergeStore) *Pipeline {
+	cfg := &Config{
+		Sources: map[string]Source{"analytics": {Vault: &VaultSource{Mount: "analytics"}}},
+		Targets: map[string]Target{
+			"Stg":  {AccountID: "111111111111", Imports: []string{"analytics"}},
+			"Prod": {AccountID: "222222222222", Imports: []string{"Stg"}},
+		},
+		Pipeline: PipelineSettings{State: &StateSettings{Dir: t.TempDir()}},
+	}
+	graph, err := BuildGraph(cfg)
+	require.NoError(t, err)
+	return &Pipeline{config: cfg, graph: graph, memStore: memDirectStore{store}}
+}
+
+func TestRunStateResume(t *testing.T) {
+	ctx := context.Background()
+	store := memMergeStore{
+		"Stg":  {"db": {"password": "stg"}},
+		"Prod": {"db": {"password": "prod"}},
+	}
+	p := resumeTestPipeline(t, store)
+	opts := DefaultOptions()
+
+	first, err := p.startRunState(ctx, "run-1", opts, []string{"Stg", "Prod"}, nil)
+	require.NoError(t, err)
+	first.done(ctx, Result{Target: "Stg", Phase: "merge", Success: true})
+	first.done(ctx, Result{Target: "Prod", Phase: "merge", Success: true})
+	first.done(ctx, Result{Target: "Stg", Phase: "sync", Success: true})
+	first.done(ctx, Result{Target: "Prod", Phase: "sync", Success: false, Error: errors.New("AccessDenied")})
+	p.runState = first
+	assert.Equal(t, "run-1", p.ResumeID())
+
+	state, err := p.loadResumeState(ctx, "run-1")
+	require.NoError(t, err)
+	assert.Equal(t, OperationPipeline, state.Operation)
+	assert.Equal(t, []string{"Stg", "Prod"}, p.resumeTargets(state))
+	assert.Equal(t, "AccessDenied", state.Phases["sync"]["Prod"].Error)
+	assert.NotEmpty(t, state.Phases["sync"]["Stg"].ContentHash)
+	assert.Empty(t, state.Phases["sync"]["Prod"].ContentHash)
+
+	resumed, err := p.startRunState(ctx, "run-2", opts, nil, state)
+	require.NoError(t, err)
+	r, ok := resumed.skip(ctx, "merge", "Stg", p.graph.Nodes["Stg"].Deps)
+	require.True(t, ok)
+	assert.Equal(t, Result{Target: "Stg", Phase: "merge", Success: true, Details: ResultDetails{Resumed: true}}, r)
+	_, ok = resumed.skip(ctx, "merge", "Prod", p.graph.Nodes["Prod"].Deps)
+	assert.True(t, ok)
+	_, ok = resumed.skip(ctx, "sync", "Prod", nil)
+	assert.False(t, ok, "failed phases are retried")
+
+	// A sync is retried when the merged secrets changed since it succeeded
+	store["Stg"]["db"] = map[string]interface{}{"password": "rotated"}
+	_, ok = resumed.skip(ctx, "sync", "Stg", nil)
+	assert.False(t, ok)
+
+	resumed.done(ctx, Result{Target: "Prod", Phase: "sync", Success: true})
+	assert.Equal(t, "run-1", resumed.id(), "a resumed run keeps updating the state it resumes")
+	state, err = p.loadResumeState(ctx, "run-1")
+	require.NoError(t, err)
+	assert.True(t, state.Phases["sync"]["Prod"].Success)
+}
+
+func TestRunStateResumeRemergesDependents(t *testing.T) {
+	ctx := context.Background()
+	p := resumeTestPipeline(t, memMergeStore{"Stg": {"db": {"password": "stg"}}})
+	state := &RunState{
+		ID:        "run-1",
+		Operation: OperationMerge,
+		Targets:   []string{"Stg", "Prod", "Retired"},
+		Phases: map[string]map[string]TargetPhaseState{"merge": {
+			"Stg":  {Success: false, Error: "permission denied"},
+			"Prod": {Success: true},
+		}},
+	}
+	assert.Equal(t, []string{"Stg", "Prod"}, p.resumeTargets(state), "targets that no longer exist are dropped")
+
+	dryRun := DefaultOptions()
+	dryRun.DryRun = true
+	tracker, err := p.startRunState(ctx, "run-2", dryRun, nil, state)
+	require.NoError(t, err)
+	_, ok := tracker.skip(ctx, "merge", "Stg", p.graph.Nodes["Stg"].Deps)
+	assert.False(t, ok)
+	_, ok = tracker.skip(ctx, "merge", "Prod", p.graph.Nodes["Prod"].Deps)
+	assert.False(t, ok, "a target whose dependency merges again merges again too")
+
+	// Dry runs record nothing
+	tracker.done(ctx, Result{Target: "Stg", Phase: "merge", Success: true})
+	assert.False(t, state.Phases["merge"]["Stg"].Success)
+	assert.Empty(t, tracker.id())
+}
+
+func TestLoadResumeState(t *testing.T) {
+	ctx := context.Background()
+	p := resumeTestPipeline(t, memMergeStore{})
+	_, err := p.loadResumeState(ctx, "missing")
+	var oe *OptionError
+	require.True(t, errors.As(err, &oe))
+	assert.Equal(t, `invalid resume "missing": run not found in the state store`, err.Error())
+
+	p.config.Pipeline.State = nil
+	p.stateStore = nil
+	_, err = p.loadResumeState(ctx, "run-1")
+	assert.ErrorContains(t, err, "requires pipeline.state")
+}
+
+type fakeStateS3 map[string][]byte
+
+func (f fakeStateS3) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := f[aws.ToString(in.Bucket)+"/"+aws.ToString(in.Key)]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (f fakeStateS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f[aws.ToString(in.Bucket)+"/"+aws.ToString(in.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+type fakeStateDynamoDB map[string]map[string]ddbtypes.AttributeValue
+
+func (f fakeStateDynamoDB) GetItem(_ context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	key := in.Key["run_id"].(*ddbtypes.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: f[aws.ToString(in.TableName)+"/"+key]}, nil
+}
+
+func (f fakeStateDynamoDB) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	key := in.Item["run_id"].(*ddbtypes.AttributeValueMemberS).Value
+	f[aws.ToString(in.TableName)+"/"+key] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestRunStateStores(t *testing.T) {
+	s3Objects := fakeStateS3{}
+	stores := map[string]runStateStore{
+		"file":     fileRunStateStore{dir: t.TempDir()},
+		"s3":       &s3RunStateStore{location: StateS3{Bucket: "vss-state", Prefix: "runs"}, client: s3Objects},
+		"dynamodb": &dynamoDBRunStateStore{table: "vss-runs", client: fakeStateDynamoDB{}},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			missing, err := store.load(ctx, "run-1")
+			require.NoError(t, err)
+			assert.Nil(t, missing)
+
+			state := &RunState{
+				ID:        "run-1",
+				Operation: OperationSync,
+				Targets:   []string{"Stg"},
+				Phases:    map[string]map[string]TargetPhaseState{"sync": {"Stg": {Success: true, ContentHash: "abc"}}},
+			}
+			require.NoError(t, store.save(ctx, state))
+			loaded, err := store.load(ctx, "run-1")
+			require.NoError(t, err)
+			assert.Equal(t, state, loaded)
+		})
+	}
+	assert.Contains(t, s3Objects, "vss-state/runs/run-1.json")
+}
+
+func TestStateSettingsValidate(t *testing.T) {
+	tests := []struct {
+		settings StateSettings
+		wantErr  string
+	}{
+		{settings: StateSettings{Dir: "/var/lib/vss/state"}},
+		{settings: StateSettings{DynamoDB: &StateDynamoDB{Table: "vss-runs"}}},
+		{settings: StateSettings{}, wantErr: "exactly one of dir, s3 or dynamodb is required"},
+		{settings: StateSettings{Dir: "state", S3: &StateS3{Bucket: "vss-state"}}, wantErr: "exactly one of"},
+		{settings: StateSettings{S3: &StateS3{}}, wantErr: "s3.bucket is required"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			err := tt.settings.validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -36,7 +36,7 @@ func NewS3MergeStore(ctx context.Context, cfg *MergeStoreS3, region string) (*S3
 	})
 	l.Debug("Creating S3 merge store")
 
-	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), withRateLimit())
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
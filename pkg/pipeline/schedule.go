@@ -0,0 +1,394 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/jbcom/secretsync/internal/metrics"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScheduleSettings configures when `vss serve` runs the pipeline. Targets
+// listed under targets run on their own cron expression; every other target
+// runs on cron.
+//
+//	pipeline:
+//	  schedule:
+//	    cron: "*/15 * * * *"
+//	    jitter: 30s
+//	    targets:
+//	      Serverless_Prod: "0 * * * *"
+type ScheduleSettings struct {
+	// Cron is the standard cron expression for targets without their own
+	// (optionally prefixed CRON_TZ=<zone>)
+	Cron string `mapstructure:"cron" yaml:"cron,omitempty"`
+	// Jitter delays each run by a random duration up to this long, so many
+	// instances do not hit Vault and AWS at the same moment
+	Jitter time.Duration `mapstructure:"jitter" yaml:"jitter,omitempty"`
+	// Targets maps target names to their own cron expressions
+	Targets map[string]string `mapstructure:"targets" yaml:"targets,omitempty"`
+}
+
+func (s *ScheduleSettings) validate(c *Config) error {
+	if s.Cron == "" && len(s.Targets) == 0 {
+		return fmt.Errorf("cron or targets is required")
+	}
+	if s.Cron != "" {
+		if _, err := cron.ParseStandard(s.Cron); err != nil {
+			return fmt.Errorf("invalid cron %q: %w", s.Cron, err)
+		}
+	}
+	if s.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	for name, expr := range s.Targets {
+		if _, ok := c.Targets[name]; !ok {
+			return fmt.Errorf("target %q not found", name)
+		}
+		if _, err := cron.ParseStandard(expr); err != nil {
+			return fmt.Errorf("target %q: invalid cron %q: %w", name, expr, err)
+		}
+	}
+	return nil
+}
+
+// schedule is a group of targets that run on the same cron expression
+type schedule struct {
+	spec    string
+	sched   cron.Schedule
+	targets []string
+	// due is the next cron tick; the run starts at due plus jitter
+	due   time.Time
+	start time.Time
+}
+
+// schedules groups the configured targets by cron expression, ordered by
+// expression so runs due at the same moment start in a stable order. Staged
+// targets also run once at their activation time.
+func (s *ScheduleSettings) schedules(c *Config) ([]*schedule, error) {
+	bySpec := make(map[string][]string)
+	for name := range c.Targets {
+		spec, ok := s.Targets[name]
+		if !ok {
+			spec = s.Cron
+		}
+		if spec == "" {
+			continue
+		}
+		bySpec[spec] = append(bySpec[spec], name)
+	}
+
+	var out []*schedule
+	for spec, targets := range bySpec {
+		sched, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron %q: %w", spec, err)
+		}
+		sort.Strings(targets)
+		out = append(out, &schedule{spec: spec, sched: sched, targets: targets})
+	}
+
+	activations := make(map[time.Time][]string)
+	for name, target := range c.Targets {
+		if target.Staging != nil {
+			at := target.Staging.ActivateAt.UTC()
+			activations[at] = append(activations[at], name)
+		}
+	}
+	for at, targets := range activations {
+		sort.Strings(targets)
+		out = append(out, &schedule{
+			spec:    "activate_at " + at.Format(time.RFC3339),
+			sched:   activationSchedule(at),
+			targets: targets,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].spec < out[j].spec })
+	return out, nil
+}
+
+// advance moves the schedule to its first tick after now and returns how
+// many ticks passed without a run, e.g. while the previous run was going.
+// A schedule without further ticks is left with a zero due time.
+func (s *schedule) advance(now time.Time, jitter time.Duration, randDuration func(time.Duration) time.Duration) int {
+	skipped := 0
+	if s.due.IsZero() {
+		s.due = s.sched.Next(now)
+	} else {
+		s.due = s.sched.Next(s.due)
+		for !s.due.IsZero() && !s.due.After(now) {
+			skipped++
+			s.due = s.sched.Next(s.due)
+		}
+	}
+	if s.due.IsZero() {
+		s.start = time.Time{}
+		return skipped
+	}
+	s.start = s.due
+	if jitter > 0 {
+		s.start = s.start.Add(randDuration(jitter))
+	}
+	return skipped
+}
+
+// scheduler runs schedules one at a time. Runs never overlap: a schedule
+// due while another run is going starts once that run finishes, and ticks
+// of a schedule that pass while its own run is going are skipped.
+type scheduler struct {
+	schedules []*schedule
+	jitter    time.Duration
+
+	now          func() time.Time
+	after        func(time.Duration) <-chan time.Time
+	randDuration func(time.Duration) time.Duration
+	run          func(ctx context.Context, targets []string) map[string]bool
+
+	// events, when set, deliver account changes, which apply updates the
+	// targets for. When the targets change, plan regroups them into schedules.
+	// The targets of vended accounts run at once.
+	events         <-chan accountEvent
+	apply          func(ctx context.Context, ev accountEvent) (bool, error)
+	plan           func() ([]*schedule, error)
+	accountTargets func(accountID string) []string
+
+	// recorded holds the next run exported for each target
+	recorded map[string]time.Time
+}
+
+// loop runs schedules as they come due until ctx is cancelled
+func (s *scheduler) loop(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action": "scheduler.loop",
+	})
+	now := s.now()
+	for _, sc := range s.schedules {
+		sc.advance(now, s.jitter, s.randDuration)
+	}
+	s.schedules = upcoming(s.schedules)
+	if len(s.schedules) == 0 && s.events == nil {
+		return fmt.Errorf("no targets are scheduled")
+	}
+	for _, sc := range s.schedules {
+		l.WithFields(log.Fields{
+			"schedule": sc.spec,
+			"targets":  sc.targets,
+			"next":     sc.start,
+		}).Info("Scheduled targets")
+	}
+	s.recordNextRuns()
+
+	for {
+		// With every target removed, only events can schedule runs again
+		var next *schedule
+		var due <-chan time.Time
+		if len(s.schedules) > 0 {
+			next = s.schedules[0]
+			for _, sc := range s.schedules[1:] {
+				if sc.start.Before(next.start) {
+					next = sc
+				}
+			}
+			due = s.after(next.start.Sub(s.now()))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-s.events:
+			changed, err := s.apply(ctx, ev)
+			if err != nil {
+				// The event is delivered again after the queue's visibility timeout
+				l.WithFields(log.Fields{
+					"accountID": ev.AccountID,
+					"event":     ev.EventName,
+				}).WithError(err).Error("Failed to apply account event")
+				continue
+			}
+			if changed {
+				s.replan(l)
+			}
+			// A failed run of a vended account is retried on its schedule
+			if ev.Vended {
+				if targets := s.accountTargets(ev.AccountID); len(targets) > 0 {
+					l.WithFields(log.Fields{
+						"accountID": ev.AccountID,
+						"targets":   targets,
+					}).Info("Starting run for vended account")
+					s.runTargets(ctx, targets)
+					if ctx.Err() != nil {
+						return nil
+					}
+				}
+			}
+			ev.done()
+			continue
+		case <-due:
+		}
+
+		l.WithFields(log.Fields{
+			"schedule": next.spec,
+			"targets":  next.targets,
+		}).Info("Starting scheduled run")
+		finished := s.runTargets(ctx, next.targets)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if skipped := next.advance(finished, s.jitter, s.randDuration); skipped > 0 {
+			l.WithFields(log.Fields{
+				"schedule": next.spec,
+				"skipped":  skipped,
+			}).Warn("Skipped scheduled runs while the previous run was still going")
+			for _, t := range next.targets {
+				metrics.ScheduledRunsSkipped.WithLabelValues(t).Add(float64(skipped))
+			}
+		}
+		s.schedules = upcoming(s.schedules)
+		s.recordNextRuns()
+	}
+}
+
+// runTargets runs targets, records the outcome of each and returns when the
+// run finished
+func (s *scheduler) runTargets(ctx context.Context, targets []string) time.Time {
+	success := s.run(ctx, targets)
+	finished := s.now()
+	for _, t := range targets {
+		metrics.RegisterScheduledRun(t, finished, success[t])
+	}
+	return finished
+}
+
+// replan regroups the targets into schedules after they changed. Schedules
+// that still exist keep their next run; new ones are due on their next tick.
+func (s *scheduler) replan(l *log.Entry) {
+	schedules, err := s.plan()
+	if err != nil {
+		l.WithError(err).Error("Failed to reschedule changed targets, keeping the current schedules")
+		return
+	}
+	prev := make(map[string]*schedule, len(s.schedules))
+	for _, sc := range s.schedules {
+		prev[sc.spec] = sc
+	}
+	now := s.now()
+	for _, sc := range schedules {
+		if old, ok := prev[sc.spec]; ok {
+			sc.due, sc.start = old.due, old.start
+		} else {
+			sc.advance(now, s.jitter, s.randDuration)
+		}
+	}
+	s.schedules = upcoming(schedules)
+	s.recordNextRuns()
+	l.WithField("schedules", len(s.schedules)).Info("Rescheduled changed targets")
+}
+
+// upcoming drops schedules that will not come due again, such as passed
+// activations
+func upcoming(schedules []*schedule) []*schedule {
+	out := schedules[:0]
+	for _, sc := range schedules {
+		if !sc.due.IsZero() {
+			out = append(out, sc)
+		}
+	}
+	return out
+}
+
+// recordNextRuns exports when each target next runs, on whichever of its
+// schedules comes first, and drops targets that are no longer scheduled
+func (s *scheduler) recordNextRuns() {
+	next := make(map[string]time.Time)
+	for _, sc := range s.schedules {
+		for _, t := range sc.targets {
+			if at, ok := next[t]; !ok || sc.start.Before(at) {
+				next[t] = sc.start
+			}
+		}
+	}
+	for t := range s.recorded {
+		if _, ok := next[t]; !ok {
+			metrics.ScheduledRunNext.DeleteLabelValues(t)
+		}
+	}
+	for t, at := range next {
+		metrics.ScheduledRunNext.WithLabelValues(t).Set(float64(at.Unix()))
+	}
+	s.recorded = next
+}
+
+// RunScheduled runs the pipeline on pipeline.schedule until ctx is
+// cancelled. Each run uses opts with Targets set to the targets that are
+// due; a target succeeds when every merge and sync of it does. Failed runs
+// are logged and reported in metrics, and do not stop the schedule.
+func (p *Pipeline) RunScheduled(ctx context.Context, opts Options) error {
+	settings := p.config.Pipeline.Schedule
+	if settings == nil {
+		return fmt.Errorf("pipeline.schedule is not configured")
+	}
+	schedules, err := settings.schedules(p.config)
+	if err != nil {
+		return err
+	}
+
+	s := &scheduler{
+		schedules: schedules,
+		jitter:    settings.Jitter,
+		now:       time.Now,
+		after:     time.After,
+		randDuration: func(d time.Duration) time.Duration {
+			return rand.N(d)
+		},
+		run: func(ctx context.Context, targets []string) map[string]bool {
+			runOpts := opts
+			runOpts.Targets = targets
+			runOpts.Selector = TargetSelector{}
+			return p.runScheduled(ctx, runOpts)
+		},
+	}
+	if events := p.accountEvents(ctx); events != nil {
+		s.events = events
+		s.apply = func(ctx context.Context, ev accountEvent) (bool, error) {
+			return p.applyAccountEvent(ctx, p.awsCtx, ev)
+		}
+		s.plan = func() ([]*schedule, error) {
+			return settings.schedules(p.config)
+		}
+		s.accountTargets = p.config.accountTargets
+	}
+	return s.loop(ctx)
+}
+
+// runScheduled runs the pipeline once and reports which targets succeeded
+func (p *Pipeline) runScheduled(ctx context.Context, opts Options) map[string]bool {
+	l := log.WithFields(log.Fields{
+		"action":  "runScheduled",
+		"targets": opts.Targets,
+	})
+	success := make(map[string]bool, len(opts.Targets))
+	results, err := p.Run(ctx, opts)
+	if err != nil {
+		l.WithError(err).Error("Scheduled run failed")
+	}
+	// Targets without results, e.g. after a failed dependency, did not succeed
+	for _, r := range results {
+		ok, seen := success[r.Target]
+		success[r.Target] = r.Success && (ok || !seen)
+	}
+	failed := 0
+	for _, ok := range success {
+		if !ok {
+			failed++
+		}
+	}
+	l.WithFields(log.Fields{
+		"results": len(results),
+		"failed":  failed,
+	}).Info("Scheduled run finished")
+	return success
+}
@@ -0,0 +1,292 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func scheduleTestConfig(settings *ScheduleSettings) *Config {
+	return &Config{
+		Targets: map[string]Target{
+			"Serverless_Stg":  {},
+			"Serverless_Prod": {},
+			"livequery_demos": {},
+		},
+		Pipeline: PipelineSettings{Schedule: settings},
+	}
+}
+
+func TestScheduleSettings(t *testing.T) {
+	content := `
+schedule:
+  cron: "*/15 * * * *"
+  jitter: 30s
+  targets:
+    Serverless_Prod: "0 * * * *"
+`
+	var settings PipelineSettings
+	require.NoError(t, yaml.Unmarshal([]byte(content), &settings))
+	require.NotNil(t, settings.Schedule)
+	assert.Equal(t, 30*time.Second, settings.Schedule.Jitter)
+
+	cfg := scheduleTestConfig(settings.Schedule)
+	require.NoError(t, settings.Schedule.validate(cfg))
+	schedules, err := settings.Schedule.schedules(cfg)
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	assert.Equal(t, "*/15 * * * *", schedules[0].spec)
+	assert.Equal(t, []string{"Serverless_Stg", "livequery_demos"}, schedules[0].targets)
+	assert.Equal(t, []string{"Serverless_Prod"}, schedules[1].targets)
+
+	// Without cron, only the listed targets are scheduled
+	only := &ScheduleSettings{Targets: map[string]string{"Serverless_Prod": "0 * * * *"}}
+	schedules, err = only.schedules(scheduleTestConfig(only))
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, []string{"Serverless_Prod"}, schedules[0].targets)
+
+	assert.ErrorContains(t, (&ScheduleSettings{}).validate(cfg), "cron or targets is required")
+	assert.ErrorContains(t, (&ScheduleSettings{Cron: "every minute"}).validate(cfg), "invalid cron")
+	assert.ErrorContains(t, (&ScheduleSettings{Cron: "* * * * *", Jitter: -time.Second}).validate(cfg), "jitter must not be negative")
+	assert.ErrorContains(t, (&ScheduleSettings{Targets: map[string]string{"missing": "* * * * *"}}).validate(cfg), `target "missing" not found`)
+}
+
+func TestScheduleAdvance(t *testing.T) {
+	only := &ScheduleSettings{Cron: "*/15 * * * *"}
+	schedules, err := only.schedules(scheduleTestConfig(only))
+	require.NoError(t, err)
+	s := schedules[0]
+	half := func(d time.Duration) time.Duration { return d / 2 }
+
+	start := time.Date(2026, 10, 15, 10, 0, 30, 0, time.UTC)
+	assert.Zero(t, s.advance(start, 30*time.Second, half))
+	assert.Equal(t, time.Date(2026, 10, 15, 10, 15, 0, 0, time.UTC), s.due)
+	assert.Equal(t, time.Date(2026, 10, 15, 10, 15, 15, 0, time.UTC), s.start)
+
+	// A run that finishes at 10:55 skips the 10:30 and 10:45 ticks
+	assert.Equal(t, 2, s.advance(time.Date(2026, 10, 15, 10, 55, 0, 0, time.UTC), 0, half))
+	assert.Equal(t, time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC), s.start)
+}
+
+func TestSchedulerLoop(t *testing.T) {
+	settings := &ScheduleSettings{
+		Cron:    "*/15 * * * *",
+		Targets: map[string]string{"Serverless_Prod": "0 * * * *"},
+	}
+	schedules, err := settings.schedules(scheduleTestConfig(settings))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := time.Date(2026, 10, 15, 10, 0, 30, 0, time.UTC)
+	durations := []time.Duration{40 * time.Minute, time.Minute, time.Minute}
+	var runs [][]string
+	var started []time.Time
+	s := &scheduler{
+		schedules: schedules,
+		now:       func() time.Time { return clock },
+		after: func(d time.Duration) <-chan time.Time {
+			if d > 0 {
+				clock = clock.Add(d)
+			}
+			ch := make(chan time.Time, 1)
+			ch <- clock
+			return ch
+		},
+		randDuration: func(d time.Duration) time.Duration { return 0 },
+		run: func(_ context.Context, targets []string) map[string]bool {
+			runs = append(runs, targets)
+			started = append(started, clock)
+			clock = clock.Add(durations[len(runs)-1])
+			if len(runs) == len(durations) {
+				cancel()
+			}
+			return map[string]bool{targets[0]: true}
+		},
+	}
+	require.NoError(t, s.loop(ctx))
+
+	assert.Equal(t, [][]string{
+		{"Serverless_Stg", "livequery_demos"},
+		{"Serverless_Stg", "livequery_demos"},
+		{"Serverless_Prod"},
+	}, runs)
+	// The 40 minute run skips two ticks; Serverless_Prod, due at 11:00 while
+	// the second run is going, starts as soon as it finishes
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 10, 15, 10, 15, 0, 0, time.UTC),
+		time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 15, 11, 1, 0, 0, time.UTC),
+	}, started)
+
+	s.schedules = nil
+	assert.ErrorContains(t, s.loop(context.Background()), "no targets are scheduled")
+}
+
+func TestSchedulerEvents(t *testing.T) {
+	settings := &ScheduleSettings{Cron: "0 * * * *"}
+	cfg := scheduleTestConfig(settings)
+	schedules, err := settings.schedules(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := time.Date(2026, 10, 15, 10, 0, 30, 0, time.UTC)
+	events := make(chan accountEvent, 2)
+	var acked []string
+	done := func(id string) func() { return func() { acked = append(acked, id) } }
+	events <- accountEvent{AccountID: "111111111111", done: done("111111111111")}
+	events <- accountEvent{AccountID: "222222222222", done: done("222222222222")}
+
+	var runs [][]string
+	s := &scheduler{
+		schedules: schedules,
+		now:       func() time.Time { return clock },
+		after: func(d time.Duration) <-chan time.Time {
+			// Time only passes once every event is handled
+			if len(events) > 0 {
+				return nil
+			}
+			clock = clock.Add(d)
+			ch := make(chan time.Time, 1)
+			ch <- clock
+			return ch
+		},
+		randDuration: func(d time.Duration) time.Duration { return 0 },
+		run: func(_ context.Context, targets []string) map[string]bool {
+			runs = append(runs, targets)
+			cancel()
+			return nil
+		},
+		events: events,
+		apply: func(_ context.Context, ev accountEvent) (bool, error) {
+			if ev.AccountID == "222222222222" {
+				return false, fmt.Errorf("throttled")
+			}
+			cfg.Targets["Sandbox_Alice"] = Target{AccountID: ev.AccountID}
+			return true, nil
+		},
+		plan: func() ([]*schedule, error) { return settings.schedules(cfg) },
+	}
+	require.NoError(t, s.loop(ctx))
+
+	// The new target runs with the rest on the next tick; the event that
+	// failed to apply is not acknowledged
+	assert.Equal(t, [][]string{{"Sandbox_Alice", "Serverless_Prod", "Serverless_Stg", "livequery_demos"}}, runs)
+	assert.Equal(t, []string{"111111111111"}, acked)
+	assert.Equal(t, time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC), clock)
+}
+
+func TestSchedulerActivation(t *testing.T) {
+	settings := &ScheduleSettings{Cron: "0 * * * *"}
+	cfg := scheduleTestConfig(settings)
+	cfg.Targets["Serverless_Prod"] = Target{Staging: &TargetStaging{ActivateAt: time.Date(2026, 10, 15, 10, 20, 0, 0, time.UTC)}}
+	cfg.Targets["livequery_demos"] = Target{Staging: &TargetStaging{ActivateAt: time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)}}
+	schedules, err := settings.schedules(cfg)
+	require.NoError(t, err)
+	require.Len(t, schedules, 3)
+	assert.Equal(t, "activate_at 2026-10-15T10:20:00Z", schedules[2].spec)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := time.Date(2026, 10, 15, 10, 0, 30, 0, time.UTC)
+	var runs [][]string
+	var started []time.Time
+	s := &scheduler{
+		schedules: schedules,
+		now:       func() time.Time { return clock },
+		after: func(d time.Duration) <-chan time.Time {
+			clock = clock.Add(d)
+			ch := make(chan time.Time, 1)
+			ch <- clock
+			return ch
+		},
+		randDuration: func(d time.Duration) time.Duration { return 0 },
+		run: func(_ context.Context, targets []string) map[string]bool {
+			runs = append(runs, targets)
+			started = append(started, clock)
+			if len(runs) == 3 {
+				cancel()
+			}
+			return nil
+		},
+	}
+	require.NoError(t, s.loop(ctx))
+
+	// Serverless_Prod also runs at its activation time, once; the activation
+	// that already passed is not scheduled
+	assert.Equal(t, [][]string{
+		{"Serverless_Prod"},
+		{"Serverless_Prod", "Serverless_Stg", "livequery_demos"},
+		{"Serverless_Prod", "Serverless_Stg", "livequery_demos"},
+	}, runs)
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 10, 15, 10, 20, 0, 0, time.UTC),
+		time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+	}, started)
+}
+
+func TestSchedulerVendedAccount(t *testing.T) {
+	settings := &ScheduleSettings{Cron: "0 * * * *"}
+	cfg := scheduleTestConfig(settings)
+	schedules, err := settings.schedules(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := time.Date(2026, 10, 15, 10, 0, 30, 0, time.UTC)
+	events := make(chan accountEvent, 1)
+	acked := false
+	events <- accountEvent{AccountID: "111111111111", Vended: true, done: func() { acked = true }}
+
+	var runs [][]string
+	var started []time.Time
+	s := &scheduler{
+		schedules: schedules,
+		now:       func() time.Time { return clock },
+		after: func(d time.Duration) <-chan time.Time {
+			if len(events) > 0 {
+				return nil
+			}
+			clock = clock.Add(d)
+			ch := make(chan time.Time, 1)
+			ch <- clock
+			return ch
+		},
+		randDuration: func(d time.Duration) time.Duration { return 0 },
+		run: func(_ context.Context, targets []string) map[string]bool {
+			runs = append(runs, targets)
+			started = append(started, clock)
+			if len(runs) == 2 {
+				cancel()
+			}
+			return nil
+		},
+		events: events,
+		apply: func(_ context.Context, ev accountEvent) (bool, error) {
+			cfg.Targets["Sandbox_Alice"] = Target{AccountID: ev.AccountID}
+			return true, nil
+		},
+		plan:           func() ([]*schedule, error) { return settings.schedules(cfg) },
+		accountTargets: cfg.accountTargets,
+	}
+	require.NoError(t, s.loop(ctx))
+
+	// The vended account runs at once, then with the rest on the next tick
+	assert.Equal(t, [][]string{
+		{"Sandbox_Alice"},
+		{"Sandbox_Alice", "Serverless_Prod", "Serverless_Stg", "livequery_demos"},
+	}, runs)
+	assert.Equal(t, []time.Time{
+		time.Date(2026, 10, 15, 10, 0, 30, 0, time.UTC),
+		time.Date(2026, 10, 15, 11, 0, 0, 0, time.UTC),
+	}, started)
+	assert.True(t, acked)
+}
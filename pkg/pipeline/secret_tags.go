@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tags vss puts on every secret it writes to Secrets Manager
+const (
+	tagManagedBy  = "vss:managed-by"
+	tagTarget     = "vss:target"
+	tagSourceHash = "vss:source-hash"
+
+	managedByValue = "secretsync"
+	// maxSecretTags is the Secrets Manager limit on tags per secret
+	maxSecretTags = 50
+)
+
+// validateSecretTags checks tags against the Secrets Manager limits,
+// leaving room for the vss: tags
+func validateSecretTags(tags map[string]string) error {
+	if len(tags) > maxSecretTags-3 {
+		return fmt.Errorf("at most %d tags are allowed", maxSecretTags-3)
+	}
+	for k, v := range tags {
+		switch {
+		case k == "":
+			return fmt.Errorf("tag keys must not be empty")
+		case strings.HasPrefix(strings.ToLower(k), "aws:"), strings.HasPrefix(k, "vss:"):
+			return fmt.Errorf("tag %q: the aws: and vss: prefixes are reserved", k)
+		case len(k) > 128:
+			return fmt.Errorf("tag %q: keys must be at most 128 characters", k)
+		case len(v) > 256:
+			return fmt.Errorf("tag %q: values must be at most 256 characters", k)
+		}
+	}
+	return nil
+}
+
+// SecretTags returns the tags a target's Secrets Manager destinations put on
+// its secrets: the tags of everything it imports, directly or through
+// other targets (later imports winning), then its own, then the vss: tags
+func (c *Config) SecretTags(targetName string) map[string]string {
+	tags := make(map[string]string)
+	c.collectSecretTags(targetName, tags, make(map[string]bool))
+	tags[tagManagedBy] = managedByValue
+	tags[tagTarget] = targetName
+	tags[tagSourceHash] = sourceHash(c.Targets[targetName].Imports)
+	return tags
+}
+
+// collectSecretTags adds the tags a target imports and its own to tags
+func (c *Config) collectSecretTags(targetName string, tags map[string]string, seen map[string]bool) {
+	if seen[targetName] {
+		return
+	}
+	seen[targetName] = true
+	target := c.Targets[targetName]
+	for _, imp := range target.Imports {
+		name := ImportName(imp)
+		if src, ok := c.Sources[name]; ok {
+			for k, v := range src.Tags {
+				tags[k] = v
+			}
+		} else if _, ok := c.Targets[name]; ok {
+			c.collectSecretTags(name, tags, seen)
+		}
+	}
+	for k, v := range target.Tags {
+		tags[k] = v
+	}
+}
+
+// sourceHash fingerprints a target's imports, so secrets show which set of
+// sources (and pinned versions) they were merged from
+func sourceHash(imports []string) string {
+	sorted := append([]string(nil), imports...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
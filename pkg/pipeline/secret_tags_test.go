@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretTags(t *testing.T) {
+	cfg := &Config{
+		AWS: AWSConfig{Region: "us-east-1"},
+		Sources: map[string]Source{
+			"analytics": {Tags: map[string]string{"cost-center": "data", "team": "analytics"}},
+			"payments":  {Tags: map[string]string{"team": "payments", "pci": "true"}},
+		},
+		Targets: map[string]Target{
+			"Stg":  {AccountID: "111111111111", Imports: []string{"analytics", "payments"}},
+			"Prod": {AccountID: "222222222222", Imports: []string{"Stg"}, Tags: map[string]string{"env": "prod", "team": "platform"}},
+		},
+	}
+
+	stg := cfg.SecretTags("Stg")
+	assert.Equal(t, "payments", stg["team"], "later imports win")
+	assert.Equal(t, "data", stg["cost-center"])
+	assert.Equal(t, "secretsync", stg["vss:managed-by"])
+	assert.Equal(t, "Stg", stg["vss:target"])
+	assert.Len(t, stg["vss:source-hash"], 16)
+
+	prod := cfg.SecretTags("Prod")
+	assert.Equal(t, "platform", prod["team"], "the target's own tags win")
+	assert.Equal(t, "true", prod["pci"], "tags are inherited through imported targets")
+	assert.Equal(t, "prod", prod["env"])
+	assert.NotEqual(t, stg["vss:source-hash"], prod["vss:source-hash"])
+
+	// The source hash only depends on the set of imports
+	assert.Equal(t, sourceHash([]string{"a", "b"}), sourceHash([]string{"b", "a"}))
+	assert.NotEqual(t, sourceHash([]string{"a@v1"}), sourceHash([]string{"a@v2"}))
+
+	p := &Pipeline{config: cfg}
+	sync, _ := p.destinationSync("Prod", "merged/Prod", cfg.Targets["Prod"], cfg.Targets["Prod"].ResolvedDestinations()[0], false)
+	assert.Equal(t, prod, sync.Spec.Dest[0].AWS.Tags)
+}
+
+func TestValidateSecretTags(t *testing.T) {
+	require.NoError(t, validateSecretTags(nil))
+	require.NoError(t, validateSecretTags(map[string]string{"team": "payments"}))
+	assert.ErrorContains(t, validateSecretTags(map[string]string{"vss:target": "x"}), "reserved")
+	assert.ErrorContains(t, validateSecretTags(map[string]string{"AWS:cost": "x"}), "reserved")
+	assert.ErrorContains(t, validateSecretTags(map[string]string{"team": strings.Repeat("x", 257)}), "at most 256")
+
+	many := make(map[string]string)
+	for i := 0; i < maxSecretTags; i++ {
+		many[strings.Repeat("k", i+1)] = "v"
+	}
+	assert.ErrorContains(t, validateSecretTags(many), "at most 47 tags")
+}
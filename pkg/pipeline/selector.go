@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TargetSelector picks a slice of the graph by dependency level and labels.
+// A target is selected when it is on one of Levels (any level if empty) and
+// has every label in Labels. Unlike explicitly named targets, selected targets
+// do not pull in their dependencies, so a slice can be re-run on its own.
+type TargetSelector struct {
+	Levels []int
+	Labels map[string]string
+}
+
+// IsZero reports whether the selector selects nothing by itself
+func (s TargetSelector) IsZero() bool {
+	return len(s.Levels) == 0 && len(s.Labels) == 0
+}
+
+// String describes the selection, e.g. "levels 0,1; label team=payments"
+func (s TargetSelector) String() string {
+	var parts []string
+	if len(s.Levels) > 0 {
+		levels := make([]string, len(s.Levels))
+		for i, l := range s.Levels {
+			levels[i] = strconv.Itoa(l)
+		}
+		parts = append(parts, "levels "+strings.Join(levels, ","))
+	}
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, "label "+k+"="+s.Labels[k])
+	}
+	return strings.Join(parts, "; ")
+}
+
+// matches reports whether a target at level with labels is selected
+func (s TargetSelector) matches(level int, labels map[string]string) bool {
+	if len(s.Levels) > 0 && !containsInt(s.Levels, level) {
+		return false
+	}
+	for k, v := range s.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLevels parses a level selection such as "1", "0-1" or "1,3-4", using
+// the levels shown by `vss graph`
+func ParseLevels(s string) ([]int, error) {
+	var levels []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid level %q: levels are non-negative integers or ranges like 0-1", part)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(hi))
+			if err != nil || last < first {
+				return nil, fmt.Errorf("invalid level range %q", part)
+			}
+		}
+		for l := first; l <= last; l++ {
+			if !containsInt(levels, l) {
+				levels = append(levels, l)
+			}
+		}
+	}
+	sort.Ints(levels)
+	return levels, nil
+}
+
+// ParseLabelSelector parses key=value pairs, e.g. from repeated --label flags
+func ParseLabelSelector(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label %q: expected key=value", pair)
+		}
+		labels[k] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
+
+// SelectTargets returns the targets sel selects, in dependency order
+func (g *Graph) SelectTargets(cfg *Config, sel TargetSelector) []string {
+	var selected []string
+	for _, name := range g.TopologicalOrder() {
+		if sel.matches(g.Nodes[name].Level, cfg.Targets[name].Labels) {
+			selected = append(selected, name)
+		}
+	}
+	return selected
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("0-1")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, levels)
+
+	levels, err = ParseLevels("3, 1-2,2")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, levels)
+
+	for _, bad := range []string{"", "a", "-1", "2-1", "1-x"} {
+		_, err := ParseLevels(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	labels, err := ParseLabelSelector([]string{"team=payments", "tier = prod"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "payments", "tier": "prod"}, labels)
+
+	_, err = ParseLabelSelector([]string{"team"})
+	assert.EqualError(t, err, `invalid label "team": expected key=value`)
+}
+
+func TestResolveTargetsWithSelector(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{"payments": {}, "analytics": {}},
+		Targets: map[string]Target{
+			"Payments_Stg":  {Imports: []string{"payments"}, Labels: map[string]string{"team": "payments"}},
+			"Payments_Prod": {Imports: []string{"Payments_Stg"}, Labels: map[string]string{"team": "payments", "tier": "prod"}},
+			"Analytics":     {Imports: []string{"analytics"}, Labels: map[string]string{"team": "data"}},
+			"Analytics_DR":  {Imports: []string{"Analytics"}},
+		},
+	}
+	g, err := BuildGraph(cfg)
+	require.NoError(t, err)
+	p := &Pipeline{config: cfg, graph: g}
+
+	assert.Equal(t, []string{"Analytics", "Payments_Stg", "Analytics_DR", "Payments_Prod"}, p.resolveTargets(nil, TargetSelector{}, false))
+
+	// Selected targets do not pull in their dependencies
+	assert.Equal(t, []string{"Analytics_DR", "Payments_Prod"}, p.resolveTargets(nil, TargetSelector{Levels: []int{2}}, false))
+	assert.Equal(t, []string{"Payments_Prod"}, p.resolveTargets(nil, TargetSelector{Labels: map[string]string{"tier": "prod"}}, false))
+	assert.Equal(t, []string{"Payments_Stg"}, p.resolveTargets(nil, TargetSelector{Levels: []int{0, 1}, Labels: map[string]string{"team": "payments"}}, false))
+
+	// Named targets still do, and are merged with the selection in dependency order
+	assert.Equal(t, []string{"Analytics", "Payments_Stg", "Analytics_DR"},
+		p.resolveTargets([]string{"Analytics_DR"}, TargetSelector{Labels: map[string]string{"team": "payments"}, Levels: []int{1}}, false))
+
+	assert.Empty(t, p.resolveTargets(nil, TargetSelector{Labels: map[string]string{"team": "nobody"}}, false))
+	assert.Equal(t, "levels 0,1; label team=payments; label tier=prod",
+		TargetSelector{Levels: []int{0, 1}, Labels: map[string]string{"tier": "prod", "team": "payments"}}.String())
+}
@@ -0,0 +1,375 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
+)
+
+// SimulationOptions sizes a synthetic pipeline run
+type SimulationOptions struct {
+	Targets          int
+	SecretsPerTarget int
+	// KeysPerSecret is the number of keys in each source secret (default 5)
+	KeysPerSecret int
+	// Sources is the number of Vault sources targets import from (default 10)
+	Sources int
+	// Depth is the number of inheritance levels targets are spread over
+	// (default 3)
+	Depth int
+	// Latency is added to every call to a simulated store
+	Latency time.Duration
+	// Parallelism is passed to the run (default 4)
+	Parallelism int
+}
+
+func (o SimulationOptions) withDefaults() SimulationOptions {
+	if o.KeysPerSecret == 0 {
+		o.KeysPerSecret = 5
+	}
+	if o.Sources == 0 {
+		o.Sources = 10
+	}
+	if o.Depth == 0 {
+		o.Depth = 3
+	}
+	if o.Depth > o.Targets {
+		o.Depth = o.Targets
+	}
+	if o.Parallelism == 0 {
+		o.Parallelism = 4
+	}
+	return o
+}
+
+func (o SimulationOptions) validate() error {
+	switch {
+	case o.Targets <= 0:
+		return &OptionError{Option: "targets", Value: o.Targets, Reason: "must be positive"}
+	case o.SecretsPerTarget <= 0:
+		return &OptionError{Option: "secrets per target", Value: o.SecretsPerTarget, Reason: "must be positive"}
+	case o.KeysPerSecret < 0:
+		return &OptionError{Option: "keys per secret", Value: o.KeysPerSecret, Reason: "must not be negative"}
+	case o.Sources < 0:
+		return &OptionError{Option: "sources", Value: o.Sources, Reason: "must not be negative"}
+	case o.Depth < 0:
+		return &OptionError{Option: "depth", Value: o.Depth, Reason: "must not be negative"}
+	case o.Latency < 0:
+		return &OptionError{Option: "latency", Value: o.Latency, Reason: "must not be negative"}
+	case o.Parallelism < 0:
+		return &OptionError{Option: "parallelism", Value: o.Parallelism, Reason: "must not be negative"}
+	}
+	return nil
+}
+
+// SyntheticConfig generates a config with opts.Sources Vault sources and
+// opts.Targets AWS account targets merged in an S3 merge store. Targets are
+// spread evenly over opts.Depth levels: the first level imports two sources,
+// and each target on a later level inherits from one on the level before and
+// imports one more source.
+func SyntheticConfig(opts SimulationOptions) *Config {
+	opts = opts.withDefaults()
+	cfg := &Config{
+		Vault:      VaultConfig{Address: "https://vault.simulated"},
+		AWS:        AWSConfig{Region: "us-east-1"},
+		Sources:    make(map[string]Source, opts.Sources),
+		MergeStore: MergeStoreConfig{S3: &MergeStoreS3{Bucket: "vss-simulation"}},
+		Targets:    make(map[string]Target, opts.Targets),
+	}
+	cfg.Pipeline.Merge.Parallel = opts.Parallelism
+	cfg.Pipeline.ContinueOnError = true
+
+	sources := make([]string, opts.Sources)
+	for i := range sources {
+		sources[i] = fmt.Sprintf("src_%03d", i)
+		cfg.Sources[sources[i]] = Source{Vault: &VaultSource{Mount: sources[i]}}
+	}
+	source := func(i int) []string {
+		if len(sources) == 0 {
+			return nil
+		}
+		return []string{sources[i%len(sources)]}
+	}
+
+	levels := make([][]string, opts.Depth)
+	for i := 0; i < opts.Targets; i++ {
+		name := fmt.Sprintf("Sim_%04d", i)
+		level := i * opts.Depth / opts.Targets
+		levels[level] = append(levels[level], name)
+
+		var imports []string
+		if level == 0 {
+			imports = append(source(i), source(i+1)...)
+		} else {
+			parents := levels[level-1]
+			imports = append([]string{parents[i%len(parents)]}, source(i)...)
+		}
+		cfg.Targets[name] = Target{
+			AccountID: fmt.Sprintf("%012d", 100000000000+i),
+			Imports:   imports,
+		}
+	}
+	return cfg
+}
+
+// SimulationReport is the outcome of a simulated run
+type SimulationReport struct {
+	Options SimulationOptions
+	// GraphBuild is how long the dependency graph took to build
+	GraphBuild time.Duration
+	Wall       time.Duration
+	Failed     int
+	// SecretsMerged and SecretsSynced count the secrets written to the
+	// merge store and to destinations
+	SecretsMerged int64
+	SecretsSynced int64
+	Stats         RunStats
+	Stores        []StoreLoad
+}
+
+// StoreLoad is the work a simulated store did during the run
+type StoreLoad struct {
+	Store string
+	Calls int64
+	// Busy is the total time spent in the store's calls, across workers
+	Busy time.Duration
+}
+
+// MergeThroughput is secrets merged per second of the merge phase
+func (r *SimulationReport) MergeThroughput() float64 {
+	return perSecond(r.SecretsMerged, r.Stats.MergeWall)
+}
+
+// SyncThroughput is secrets synced per second of the sync phase
+func (r *SimulationReport) SyncThroughput() float64 {
+	return perSecond(r.SecretsSynced, r.Stats.SyncWall)
+}
+
+// Bottleneck is the store the run spent the most time in
+func (r *SimulationReport) Bottleneck() StoreLoad {
+	var busiest StoreLoad
+	for _, s := range r.Stores {
+		if s.Busy > busiest.Busy {
+			busiest = s
+		}
+	}
+	return busiest
+}
+
+func perSecond(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// Simulate runs the merge and sync phases over a SyntheticConfig, with Vault
+// sources, the merge store and destinations replaced by in-memory stores, to
+// measure the graph, merge and scheduling code at scale
+func Simulate(ctx context.Context, opts SimulationOptions) (*SimulationReport, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+	cfg := SyntheticConfig(opts)
+
+	start := time.Now()
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	graphBuild := time.Since(start)
+
+	sim := &simulation{
+		vault: &simVault{simStore: simStore{latency: opts.Latency}, secrets: opts.SecretsPerTarget, keys: opts.KeysPerSecret},
+		merge: &simMergeStore{simStore: simStore{latency: opts.Latency}, secrets: make(map[string][]byte)},
+		dest:  &simStore{latency: opts.Latency},
+	}
+	// The simulated stores replace the sync engine, so it is not started
+	p.initialized = true
+	p.openVaultSource = func(context.Context, *VaultSource) (vaultReader, error) { return sim.vault, nil }
+	p.memStore = sim.merge
+	p.runSyncConfig = func(ctx context.Context, targetName string, sc v1alpha1.VaultSecretSync) (*backend.SyncCompletion, error) {
+		return nil, sim.sync(ctx, p, targetName, sc)
+	}
+
+	runOpts := DefaultOptions()
+	runOpts.Parallelism = opts.Parallelism
+	started := time.Now()
+	results, err := p.Run(ctx, runOpts)
+	finished := time.Now()
+	if err != nil && len(results) == 0 {
+		return nil, err
+	}
+
+	report := &SimulationReport{
+		Options:       opts,
+		GraphBuild:    graphBuild,
+		Wall:          finished.Sub(started),
+		SecretsMerged: sim.merge.writes.Load(),
+		SecretsSynced: sim.dest.calls.Load(),
+		Stats:         AnalyzeRun(newRunRecord(runOpts, p.graph, started, finished, results, err), p.graph),
+		Stores: []StoreLoad{
+			sim.vault.load("vault sources"),
+			sim.merge.load("merge store"),
+			sim.dest.load("destinations"),
+		},
+	}
+	for _, r := range results {
+		if !r.Success {
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// simulation holds the in-memory stores of a simulated run
+type simulation struct {
+	vault *simVault
+	merge *simMergeStore
+	dest  *simStore
+}
+
+// sync writes a target's merged secrets, transformed and named as the sync
+// engine would, to the simulated destinations
+func (s *simulation) sync(ctx context.Context, p *Pipeline, targetName string, sc v1alpha1.VaultSecretSync) error {
+	sourcePath, err := p.syncSourcePath(targetName)
+	if err != nil {
+		return err
+	}
+	snapshot, err := readSnapshot(ctx, s.merge, targetName)
+	if err != nil {
+		return err
+	}
+	rendered, err := renderSecrets(sc, sourcePath, snapshot)
+	if err != nil {
+		return err
+	}
+	for range rendered {
+		s.dest.call()()
+	}
+	return nil
+}
+
+// simStore counts calls to a simulated store and the time spent in them
+type simStore struct {
+	latency time.Duration
+	calls   atomic.Int64
+	busy    atomic.Int64
+}
+
+// call records a call and waits out the store's latency; the caller defers
+// the returned func so its own work is counted as busy time
+func (s *simStore) call() func() {
+	start := time.Now()
+	if s.latency > 0 {
+		time.Sleep(s.latency)
+	}
+	s.calls.Add(1)
+	return func() { s.busy.Add(int64(time.Since(start))) }
+}
+
+func (s *simStore) load(name string) StoreLoad {
+	return StoreLoad{Store: name, Calls: s.calls.Load(), Busy: time.Duration(s.busy.Load())}
+}
+
+// simVault serves the same secret names from every mount, with values that
+// differ by mount so later imports override earlier ones key by key
+type simVault struct {
+	simStore
+	secrets int
+	keys    int
+}
+
+func (v *simVault) ListSecrets(ctx context.Context, p string) ([]string, error) {
+	defer v.call()()
+	names := make([]string, v.secrets)
+	for i := range names {
+		names[i] = fmt.Sprintf("secret-%04d", i)
+	}
+	return names, nil
+}
+
+func (v *simVault) GetSecret(ctx context.Context, s string) ([]byte, error) {
+	defer v.call()()
+	mount, name, _ := strings.Cut(s, "/")
+	data := make(map[string]string, v.keys+1)
+	for k := 0; k < v.keys; k++ {
+		data[fmt.Sprintf("key_%02d", k)] = fmt.Sprintf("%s/%s/%d", mount, name, k)
+	}
+	data[mount] = name
+	return json.Marshal(data)
+}
+
+// simMergeStore keeps merged secrets as JSON, so reads return fresh values
+// the way the S3 and GCP merge stores do
+type simMergeStore struct {
+	simStore
+	writes atomic.Int64
+
+	mu      sync.RWMutex
+	secrets map[string][]byte
+}
+
+func (m *simMergeStore) GetMergePath(targetName string) string {
+	return "simulated/" + targetName
+}
+
+func (m *simMergeStore) ListSecrets(ctx context.Context, targetName string) ([]string, error) {
+	defer m.call()()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	prefix := targetName + "/"
+	var names []string
+	for key := range m.secrets {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *simMergeStore) ReadSecret(ctx context.Context, targetName, secretName string) (map[string]interface{}, error) {
+	defer m.call()()
+	m.mu.RLock()
+	b, ok := m.secrets[targetName+"/"+secretName]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s not found", targetName, secretName)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (m *simMergeStore) WriteSecret(ctx context.Context, targetName, secretName string, data map[string]interface{}) error {
+	defer m.call()()
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.secrets[targetName+"/"+secretName] = b
+	m.mu.Unlock()
+	m.writes.Add(1)
+	return nil
+}
+
+func (m *simMergeStore) DeleteSecret(ctx context.Context, targetName, secretName string) error {
+	defer m.call()()
+	m.mu.Lock()
+	delete(m.secrets, targetName+"/"+secretName)
+	m.mu.Unlock()
+	return nil
+}
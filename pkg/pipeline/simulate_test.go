@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyntheticConfig(t *testing.T) {
+	cfg := SyntheticConfig(SimulationOptions{Targets: 12, SecretsPerTarget: 5, Sources: 4})
+	require.NoError(t, cfg.Validate())
+	assert.Len(t, cfg.Sources, 4)
+	assert.Len(t, cfg.Targets, 12)
+
+	// Four targets per level; later levels inherit from the level before
+	assert.Equal(t, []string{"src_000", "src_001"}, cfg.Targets["Sim_0000"].Imports)
+	assert.Equal(t, []string{"Sim_0000", "src_000"}, cfg.Targets["Sim_0004"].Imports)
+	assert.Equal(t, []string{"Sim_0004", "src_000"}, cfg.Targets["Sim_0008"].Imports)
+	assert.Equal(t, "100000000011", cfg.Targets["Sim_0011"].AccountID)
+}
+
+func TestSimulate(t *testing.T) {
+	report, err := Simulate(context.Background(), SimulationOptions{Targets: 12, SecretsPerTarget: 5, Sources: 4, Parallelism: 3})
+	require.NoError(t, err)
+
+	assert.Zero(t, report.Failed)
+	assert.Equal(t, int64(60), report.SecretsMerged)
+	assert.Equal(t, int64(60), report.SecretsSynced)
+	assert.Len(t, report.Stats.Levels, 3)
+	assert.Equal(t, 12, report.Stats.SyncTargets)
+	assert.Equal(t, 3, report.Stats.Parallelism)
+	assert.Len(t, report.Stats.CriticalPath, 3)
+	require.Len(t, report.Stores, 3)
+	for _, s := range report.Stores {
+		assert.Positive(t, s.Calls, s.Store)
+	}
+	assert.Equal(t, int64(60), report.Stores[2].Calls)
+	assert.Positive(t, report.MergeThroughput())
+
+	_, err = Simulate(context.Background(), SimulationOptions{SecretsPerTarget: 5})
+	var optErr *OptionError
+	assert.True(t, errors.As(err, &optErr))
+}
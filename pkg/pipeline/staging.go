@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TargetStaging holds a target's new secret values back until a change
+// window. Until activate_at, merges write to a staged snapshot beside the
+// target's merged secrets, and its destinations keep receiving the live ones.
+// The first apply run at or after activate_at promotes the staged snapshot to
+// the live merged secrets before syncing them; `vss serve` runs the target at
+// activate_at for this.
+//
+//	targets:
+//	  Serverless_Prod:
+//	    staging:
+//	      activate_at: "2026-11-01T02:00:00Z"
+type TargetStaging struct {
+	ActivateAt time.Time `mapstructure:"activate_at" yaml:"activate_at"`
+}
+
+func (s *TargetStaging) validate() error {
+	if s.ActivateAt.IsZero() {
+		return fmt.Errorf("activate_at is required")
+	}
+	return nil
+}
+
+// pending reports whether merges are still staged at now
+func (s *TargetStaging) pending(now time.Time) bool {
+	return s != nil && now.Before(s.ActivateAt)
+}
+
+// stagedTargetName is the merge store name of a target's staged snapshot
+func stagedTargetName(targetName string) string {
+	return targetName + ".staged"
+}
+
+// mergeStoreName returns the merge store name a target's merge writes to at
+// now: its staged snapshot while staging is pending, otherwise the target
+func (c *Config) mergeStoreName(targetName string, now time.Time) string {
+	if c.Targets[targetName].Staging.pending(now) {
+		return stagedTargetName(targetName)
+	}
+	return targetName
+}
+
+// activationSchedule comes due once, when a target's staged secrets activate
+type activationSchedule time.Time
+
+func (a activationSchedule) Next(t time.Time) time.Time {
+	if at := time.Time(a); t.Before(at) {
+		return at
+	}
+	return time.Time{}
+}
+
+// activateStaged promotes the staged snapshots of targets whose activation
+// time has passed to their live merged secrets, so the run syncs them
+func (p *Pipeline) activateStaged(ctx context.Context, targets []string, now time.Time) error {
+	var due []string
+	for _, name := range targets {
+		if s := p.config.Targets[name].Staging; s != nil && !s.pending(now) {
+			due = append(due, name)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range due {
+		if err := activateStagedTarget(ctx, store, name); err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// activateStagedTarget copies a target's staged snapshot over its merged
+// secrets and removes it. A target with nothing staged is left alone.
+func activateStagedTarget(ctx context.Context, store mergeStore, targetName string) error {
+	staged := stagedTargetName(targetName)
+	names, err := store.ListSecrets(ctx, staged)
+	if err != nil {
+		return fmt.Errorf("failed to list staged secrets: %w", err)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	plan, err := planPromotion(ctx, store, staged, targetName, nil)
+	if err != nil {
+		return err
+	}
+	if err := applyPromotion(ctx, store, plan); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := store.DeleteSecret(ctx, staged, name); err != nil {
+			return fmt.Errorf("failed to remove staged secret %q: %w", name, err)
+		}
+	}
+	log.WithFields(log.Fields{
+		"action":   "activateStagedTarget",
+		"target":   targetName,
+		"added":    plan.Diff.Summary.Added,
+		"modified": plan.Diff.Summary.Modified,
+		"removed":  plan.Diff.Summary.Removed,
+	}).Info("Activated staged secrets")
+	return nil
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTargetStaging(t *testing.T) {
+	var target Target
+	require.NoError(t, yaml.Unmarshal([]byte(`
+account_id: "222222222222"
+staging:
+  activate_at: "2026-11-01T02:00:00Z"
+`), &target))
+	require.NotNil(t, target.Staging)
+	activateAt := time.Date(2026, 11, 1, 2, 0, 0, 0, time.UTC)
+	assert.Equal(t, activateAt, target.Staging.ActivateAt)
+	assert.NoError(t, target.Staging.validate())
+	assert.ErrorContains(t, (&TargetStaging{}).validate(), "activate_at is required")
+
+	cfg := &Config{Targets: map[string]Target{"Serverless_Prod": target, "Serverless_Stg": {}}}
+	assert.Equal(t, "Serverless_Prod.staged", cfg.mergeStoreName("Serverless_Prod", activateAt.Add(-time.Minute)))
+	assert.Equal(t, "Serverless_Prod", cfg.mergeStoreName("Serverless_Prod", activateAt))
+	assert.Equal(t, "Serverless_Stg", cfg.mergeStoreName("Serverless_Stg", activateAt.Add(-time.Minute)))
+
+	sched := activationSchedule(activateAt)
+	assert.Equal(t, activateAt, sched.Next(activateAt.Add(-time.Hour)))
+	assert.True(t, sched.Next(activateAt).IsZero())
+}
+
+func TestActivateStagedTarget(t *testing.T) {
+	ctx := context.Background()
+	store := memMergeStore{
+		"Serverless_Prod.staged": {
+			"api": {"token": "rotated"},
+			"db":  {"password": "same"},
+		},
+		"Serverless_Prod": {
+			"api":    {"token": "old"},
+			"db":     {"password": "same"},
+			"legacy": {"key": "gone"},
+		},
+	}
+
+	require.NoError(t, activateStagedTarget(ctx, store, "Serverless_Prod"))
+	assert.Equal(t, map[string]map[string]interface{}{
+		"api": {"token": "rotated"},
+		"db":  {"password": "same"},
+	}, store["Serverless_Prod"])
+	assert.Empty(t, store["Serverless_Prod.staged"])
+
+	// With nothing staged, the merged secrets are left alone
+	require.NoError(t, activateStagedTarget(ctx, store, "Serverless_Prod"))
+	assert.Len(t, store["Serverless_Prod"], 2)
+}
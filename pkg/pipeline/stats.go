@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RunStats breaks down where a recorded run spent its wall-clock time
+type RunStats struct {
+	RunID       string
+	Parallelism int
+
+	// MergeWall and SyncWall are each phase's first start to last finish
+	MergeWall time.Duration
+	SyncWall  time.Duration
+	Levels    []LevelTiming
+
+	// CriticalPath is the chain of dependent targets whose merges took
+	// longest end to end; no amount of parallelism makes the merge phase
+	// shorter than CriticalPathDuration
+	CriticalPath         []PathStep
+	CriticalPathDuration time.Duration
+
+	SyncTargets int
+	SlowestSync PathStep
+
+	Suggestions []string
+}
+
+// LevelTiming is the merge phase's time on one dependency level. Levels run
+// one after another, so the merge phase takes the sum of their wall times.
+type LevelTiming struct {
+	Level   int
+	Targets int
+	Wall    time.Duration
+	Slowest PathStep
+}
+
+// PathStep is a target and how long its phase took
+type PathStep struct {
+	Target   string
+	Duration time.Duration
+}
+
+// slackFactor is how much longer than its slowest target a phase or level may
+// take before targets are considered to have queued for a worker
+const slackFactor = 1.2
+
+// AnalyzeRun computes per-level timing and the critical path of a recorded
+// run. g supplies dependencies between targets; targets the run did not
+// process are ignored.
+func AnalyzeRun(run RunRecord, g *Graph) RunStats {
+	stats := RunStats{RunID: run.ID, Parallelism: run.Parallelism}
+
+	merges := make(map[string]RunResult)
+	byLevel := make(map[int][]RunResult)
+	var mergeResults, syncResults []RunResult
+	for _, r := range run.Results {
+		switch r.Phase {
+		case "merge":
+			merges[r.Target] = r
+			byLevel[r.Level] = append(byLevel[r.Level], r)
+			mergeResults = append(mergeResults, r)
+		case "sync":
+			syncResults = append(syncResults, r)
+			if r.Duration > stats.SlowestSync.Duration {
+				stats.SlowestSync = PathStep{Target: r.Target, Duration: r.Duration}
+			}
+		}
+	}
+	stats.MergeWall = phaseWall(mergeResults)
+	stats.SyncWall = phaseWall(syncResults)
+	stats.SyncTargets = len(syncResults)
+
+	levels := make([]int, 0, len(byLevel))
+	for level := range byLevel {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+	for _, level := range levels {
+		results := byLevel[level]
+		lt := LevelTiming{Level: level, Targets: len(results), Wall: phaseWall(results)}
+		for _, r := range results {
+			if r.Duration > lt.Slowest.Duration {
+				lt.Slowest = PathStep{Target: r.Target, Duration: r.Duration}
+			}
+		}
+		stats.Levels = append(stats.Levels, lt)
+	}
+
+	stats.CriticalPath, stats.CriticalPathDuration = criticalPath(merges, g)
+	stats.Suggestions = suggestParallelism(stats)
+	return stats
+}
+
+// phaseWall returns the time from the first start to the last finish of
+// results. Results recorded without start times are assumed to have run
+// concurrently.
+func phaseWall(results []RunResult) time.Duration {
+	var first, last time.Time
+	var longest time.Duration
+	timed := true
+	for _, r := range results {
+		if r.Duration > longest {
+			longest = r.Duration
+		}
+		if r.Started.IsZero() {
+			timed = false
+			continue
+		}
+		if first.IsZero() || r.Started.Before(first) {
+			first = r.Started
+		}
+		if end := r.Started.Add(r.Duration); end.After(last) {
+			last = end
+		}
+	}
+	if !timed {
+		return longest
+	}
+	return last.Sub(first)
+}
+
+// criticalPath returns the chain of merged targets, each depending on the one
+// before it, with the greatest total merge duration
+func criticalPath(merges map[string]RunResult, g *Graph) ([]PathStep, time.Duration) {
+	names := make([]string, 0, len(merges))
+	for name := range merges {
+		names = append(names, name)
+	}
+	// Dependencies come before their dependents; names break ties
+	sort.Slice(names, func(i, j int) bool {
+		li, lj := merges[names[i]].Level, merges[names[j]].Level
+		if li != lj {
+			return li < lj
+		}
+		return names[i] < names[j]
+	})
+
+	total := make(map[string]time.Duration)
+	prev := make(map[string]string)
+	var end string
+	for _, name := range names {
+		var deps []string
+		if g != nil && g.Nodes[name] != nil {
+			deps = append(deps, g.Nodes[name].Deps...)
+			sort.Strings(deps)
+		}
+		var best time.Duration
+		for _, dep := range deps {
+			if d, ok := total[dep]; ok && (prev[name] == "" || d > best) {
+				best = d
+				prev[name] = dep
+			}
+		}
+		total[name] = best + merges[name].Duration
+		if end == "" || total[name] > total[end] {
+			end = name
+		}
+	}
+	if end == "" {
+		return nil, 0
+	}
+
+	var path []PathStep
+	for name := end; name != ""; name = prev[name] {
+		path = append([]PathStep{{Target: name, Duration: merges[name].Duration}}, path...)
+	}
+	return path, total[end]
+}
+
+// suggestParallelism explains what limited the run and how parallelism could help
+func suggestParallelism(stats RunStats) []string {
+	var suggestions []string
+
+	// A level that took well over its slowest target had targets waiting for a worker
+	widest := 0
+	for _, lt := range stats.Levels {
+		if lt.Targets > widest {
+			widest = lt.Targets
+		}
+		if stats.Parallelism > 0 && lt.Targets > stats.Parallelism &&
+			float64(lt.Wall) > slackFactor*float64(lt.Slowest.Duration) {
+			suggestions = append(suggestions, fmt.Sprintf(
+				"level %d: %d targets queued for %d workers (%s wall vs %s slowest target); --parallel %d would run them at once",
+				lt.Level, lt.Targets, stats.Parallelism, lt.Wall.Round(time.Millisecond), lt.Slowest.Duration.Round(time.Millisecond), lt.Targets))
+		}
+	}
+
+	if stats.Parallelism > 0 && stats.SyncTargets > stats.Parallelism &&
+		float64(stats.SyncWall) > slackFactor*float64(stats.SlowestSync.Duration) {
+		suggestions = append(suggestions, fmt.Sprintf(
+			"sync: %s wall vs %s slowest target (%s); raising --parallel above %d would shorten the sync phase",
+			stats.SyncWall.Round(time.Millisecond), stats.SlowestSync.Duration.Round(time.Millisecond), stats.SlowestSync.Target, stats.Parallelism))
+	}
+
+	if stats.MergeWall > 0 && stats.CriticalPathDuration > 0 &&
+		float64(stats.MergeWall) <= slackFactor*float64(stats.CriticalPathDuration) && len(stats.CriticalPath) > 1 {
+		suggestions = append(suggestions, fmt.Sprintf(
+			"merge: bound by the %d-target critical path (%s of %s); more parallelism will not help, speed up or flatten that chain instead",
+			len(stats.CriticalPath), stats.CriticalPathDuration.Round(time.Millisecond), stats.MergeWall.Round(time.Millisecond)))
+	}
+
+	if len(suggestions) == 0 && stats.Parallelism > widest && widest > 0 {
+		suggestions = append(suggestions, fmt.Sprintf(
+			"no level has more than %d targets; --parallel %d is enough for this graph", widest, widest))
+	}
+	return suggestions
+}
@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeRun(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{"analytics": {}},
+		Targets: map[string]Target{
+			"Serverless_Stg":  {Imports: []string{"analytics"}},
+			"Serverless_Prod": {Imports: []string{"Serverless_Stg"}},
+			"Sandbox_A":       {Imports: []string{"analytics"}},
+			"Sandbox_B":       {Imports: []string{"analytics"}},
+			"Sandbox_C":       {Imports: []string{"analytics"}},
+		},
+	}
+	g, err := BuildGraph(cfg)
+	require.NoError(t, err)
+
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+	run := RunRecord{
+		ID:          "run-1",
+		Parallelism: 2,
+		Results: []RunResult{
+			// Level 1: four targets on two workers, so two of them queued
+			{Target: "Serverless_Stg", Phase: "merge", Level: 1, Started: at(0), Duration: 10 * time.Second},
+			{Target: "Sandbox_A", Phase: "merge", Level: 1, Started: at(0), Duration: 4 * time.Second},
+			{Target: "Sandbox_B", Phase: "merge", Level: 1, Started: at(4), Duration: 4 * time.Second},
+			{Target: "Sandbox_C", Phase: "merge", Level: 1, Started: at(10), Duration: 5 * time.Second},
+			{Target: "Serverless_Prod", Phase: "merge", Level: 2, Started: at(15), Duration: 20 * time.Second},
+			{Target: "Serverless_Stg", Phase: "sync", Started: at(35), Duration: 3 * time.Second},
+			{Target: "Serverless_Prod", Phase: "sync", Started: at(35), Duration: 2 * time.Second},
+		},
+	}
+
+	stats := AnalyzeRun(run, g)
+	assert.Equal(t, 35*time.Second, stats.MergeWall)
+	assert.Equal(t, 3*time.Second, stats.SyncWall)
+	assert.Equal(t, []LevelTiming{
+		{Level: 1, Targets: 4, Wall: 15 * time.Second, Slowest: PathStep{"Serverless_Stg", 10 * time.Second}},
+		{Level: 2, Targets: 1, Wall: 20 * time.Second, Slowest: PathStep{"Serverless_Prod", 20 * time.Second}},
+	}, stats.Levels)
+	assert.Equal(t, []PathStep{{"Serverless_Stg", 10 * time.Second}, {"Serverless_Prod", 20 * time.Second}}, stats.CriticalPath)
+	assert.Equal(t, 30*time.Second, stats.CriticalPathDuration)
+	assert.Equal(t, PathStep{"Serverless_Stg", 3 * time.Second}, stats.SlowestSync)
+	assert.Equal(t, []string{
+		"level 1: 4 targets queued for 2 workers (15s wall vs 10s slowest target); --parallel 4 would run them at once",
+		"merge: bound by the 2-target critical path (30s of 35s); more parallelism will not help, speed up or flatten that chain instead",
+	}, stats.Suggestions)
+}
+
+func TestAnalyzeRunWithoutStartTimes(t *testing.T) {
+	run := RunRecord{Results: []RunResult{
+		{Target: "A", Phase: "merge", Level: 1, Duration: time.Second},
+		{Target: "B", Phase: "merge", Level: 1, Duration: 3 * time.Second},
+	}}
+	stats := AnalyzeRun(run, NewGraph())
+	assert.Equal(t, 3*time.Second, stats.MergeWall)
+	assert.Equal(t, []PathStep{{"B", 3 * time.Second}}, stats.CriticalPath)
+	assert.Empty(t, stats.Suggestions)
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DopplerCredential is the account vss login stores the Doppler token under
+const DopplerCredential = "doppler"
+
+// unsetEnvVar matches the ${VAR} references expandEnvVars leaves in place
+// because the variable is not set
+var unsetEnvVar = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// VaultCredential is the account vss login stores the token of the Vault at
+// address under
+func VaultCredential(address string) string {
+	return "vault:" + strings.TrimRight(address, "/")
+}
+
+// applyStoredCredentials fills in the tokens the environment does not set
+// from those vss login stored, so operators need not export them in a shell:
+//   - the Vault token, when vault.auth.token's ${VAR} is unset, or when no
+//     auth method is configured and VAULT_TOKEN is unset
+//   - every Doppler token that is empty or whose ${VAR} is unset
+func (c *Config) applyStoredCredentials(stored func(account string) string) {
+	auth := &c.Vault.Auth
+	switch {
+	case auth.Token != nil && unsetToken(auth.Token.Token):
+		if token := stored(VaultCredential(c.Vault.Address)); token != "" {
+			auth.Token.Token = token
+		}
+	case auth.method() == "" && os.Getenv("VAULT_TOKEN") == "":
+		if token := stored(VaultCredential(c.Vault.Address)); token != "" {
+			auth.Token = &TokenAuth{Token: token}
+		}
+	}
+
+	doppler := func(token *string) {
+		if !unsetToken(*token) {
+			return
+		}
+		if t := stored(DopplerCredential); t != "" {
+			*token = t
+		}
+	}
+	for _, src := range c.Sources {
+		if src.Doppler != nil {
+			doppler(&src.Doppler.Token)
+		}
+	}
+	for _, dt := range c.DynamicTargets {
+		if dt.Discovery.Doppler != nil {
+			doppler(&dt.Discovery.Doppler.Token)
+		}
+	}
+	for _, target := range c.Targets {
+		for _, d := range target.Destinations {
+			if d.Doppler != nil {
+				doppler(&d.Doppler.Token)
+			}
+		}
+	}
+}
+
+// unsetToken reports whether a token is empty or names an unset variable
+func unsetToken(token string) bool {
+	return token == "" || unsetEnvVar.MatchString(token)
+}
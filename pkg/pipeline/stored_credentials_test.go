@@ -0,0 +1,42 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyStoredCredentials(t *testing.T) {
+	stored := map[string]string{
+		"vault:https://vault.example.com": "hvs.stored",
+		DopplerCredential:                 "dp.st.stored",
+	}
+	lookup := func(account string) string { return stored[account] }
+
+	t.Setenv("VAULT_TOKEN", "")
+	c := &Config{
+		Vault: VaultConfig{Address: "https://vault.example.com/"},
+		Targets: map[string]Target{"Stg": {Destinations: []Destination{
+			{Doppler: &DopplerDestination{Project: "web", Config: "stg"}},
+			{Doppler: &DopplerDestination{Project: "api", Config: "stg", Token: "dp.st.literal"}},
+		}}},
+	}
+	c.applyStoredCredentials(lookup)
+	assert.Equal(t, &TokenAuth{Token: "hvs.stored"}, c.Vault.Auth.Token, "no auth method falls back to the stored token")
+	assert.Equal(t, "dp.st.stored", c.Targets["Stg"].Destinations[0].Doppler.Token)
+	assert.Equal(t, "dp.st.literal", c.Targets["Stg"].Destinations[1].Doppler.Token)
+
+	// Other auth methods and set tokens are left alone
+	c = &Config{Vault: VaultConfig{Address: "https://vault.example.com", Auth: VaultAuthConfig{Kubernetes: &KubernetesAuth{Role: "vss"}}}}
+	c.applyStoredCredentials(lookup)
+	assert.Nil(t, c.Vault.Auth.Token)
+	c = &Config{Vault: VaultConfig{Address: "https://vault.example.com", Auth: VaultAuthConfig{Token: &TokenAuth{Token: "hvs.env"}}}}
+	c.applyStoredCredentials(lookup)
+	assert.Equal(t, "hvs.env", c.Vault.Auth.Token.Token)
+
+	// VAULT_TOKEN wins when no auth method is configured
+	t.Setenv("VAULT_TOKEN", "hvs.env")
+	c = &Config{Vault: VaultConfig{Address: "https://vault.example.com"}}
+	c.applyStoredCredentials(lookup)
+	assert.Nil(t, c.Vault.Auth.Token)
+}
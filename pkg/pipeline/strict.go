@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOptions controls how a configuration file is decoded
+type LoadOptions struct {
+	// Strict rejects keys that do not map to a config setting. Without it unknown
+	// keys are logged as warnings and otherwise ignored.
+	Strict bool
+	// Version is the running vss version, checked against the config's
+	// min_vss_version (empty skips the check)
+	Version string
+	// StoredCredential returns the token vss login stored for an account
+	// (see VaultCredential and DopplerCredential), or "" when none is.
+	// Vault and Doppler tokens the environment does not set fall back to it.
+	StoredCredential func(account string) string
+}
+
+// UnknownField is a config key that does not map to any setting
+type UnknownField struct {
+	// Key is the dotted path of the key, e.g. targets.Serverless_Stg.acount_id
+	Key    string
+	Line   int
+	Column int
+}
+
+func (f UnknownField) String() string {
+	return fmt.Sprintf("line %d, column %d: unknown key %q", f.Line, f.Column, f.Key)
+}
+
+// UnknownFieldsError is returned by strict loading when the config contains
+// keys that would otherwise be silently ignored
+type UnknownFieldsError struct {
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldsError) Error() string {
+	lines := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		lines = append(lines, "  "+f.String())
+	}
+	return fmt.Sprintf("config has %d unknown key(s):\n%s", len(e.Fields), strings.Join(lines, "\n"))
+}
+
+// FindUnknownFields returns every key in a YAML config document that does not
+// map to a Config setting, in document order
+func FindUnknownFields(data []byte) ([]UnknownField, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var fields []UnknownField
+	collectUnknownFields(&doc, reflect.TypeOf(Config{}), "", &fields)
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].Line != fields[j].Line {
+			return fields[i].Line < fields[j].Line
+		}
+		return fields[i].Column < fields[j].Column
+	})
+
+	// Keys under an anchor are reported once even when merged into several places
+	deduped := fields[:0]
+	for i, f := range fields {
+		if i > 0 && f.Line == fields[i-1].Line && f.Column == fields[i-1].Column {
+			continue
+		}
+		deduped = append(deduped, f)
+	}
+	return deduped, nil
+}
+
+func collectUnknownFields(node *yaml.Node, t reflect.Type, path string, out *[]UnknownField) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			collectUnknownFields(n, t, path, out)
+		}
+		return
+	case yaml.AliasNode:
+		// Anchored content is checked where it is defined
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		// Scalars such as timestamps and the target shorthand list have no keys
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFieldTypes(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				collectMergedFields(value, t, path, out)
+				continue
+			}
+			ft, ok := fields[key.Value]
+			if !ok {
+				*out = append(*out, UnknownField{Key: joinKey(path, key.Value), Line: key.Line, Column: key.Column})
+				continue
+			}
+			collectUnknownFields(value, ft, joinKey(path, key.Value), out)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				collectMergedFields(value, t, path, out)
+				continue
+			}
+			collectUnknownFields(value, t.Elem(), joinKey(path, key.Value), out)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			collectUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), out)
+		}
+	}
+}
+
+// collectMergedFields checks the mappings pulled in by a YAML merge key (<<)
+func collectMergedFields(value *yaml.Node, t reflect.Type, path string, out *[]UnknownField) {
+	items := []*yaml.Node{value}
+	if value.Kind == yaml.SequenceNode {
+		items = value.Content
+	}
+	for _, item := range items {
+		if item.Kind == yaml.AliasNode {
+			item = item.Alias
+		}
+		collectUnknownFields(item, t, path, out)
+	}
+}
+
+// yamlFieldTypes maps the YAML key of each field of a struct to its type,
+// following the same naming rules as yaml.v3
+func yamlFieldTypes(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, inline, ok := yamlFieldName(f)
+		if !ok {
+			continue
+		}
+		if inline {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range yamlFieldTypes(ft) {
+					fields[k] = v
+				}
+			}
+			continue
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// yamlFieldName returns the YAML key of a struct field and whether it is
+// inlined; ok is false for fields yaml.v3 skips
+func yamlFieldName(f reflect.StructField) (name string, inline, ok bool) {
+	if f.PkgPath != "" && !f.Anonymous {
+		return "", false, false
+	}
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true, true
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, false, true
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
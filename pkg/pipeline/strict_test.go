@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const strictTestConfig = `
+vault:
+  address: https://vault.example.com
+sources:
+  analytics:
+    vault:
+      mount: analytics
+merge_stor:
+  vault:
+    mount: merged
+targets:
+  Serverless_Stg:
+    acount_id: "111111111111"
+    imports: [analytics]
+  Serverless_Prod:
+    - Serverless_Stg
+pipeline:
+  freeze_windows:
+    - name: year-end
+      start: "2026-12-20T00:00:00Z"
+      end: "2027-01-04T00:00:00Z"
+      clasifications: [production]
+`
+
+func TestFindUnknownFields(t *testing.T) {
+	fields, err := FindUnknownFields([]byte(strictTestConfig))
+	require.NoError(t, err)
+	assert.Equal(t, []UnknownField{
+		{Key: "merge_stor", Line: 8, Column: 1},
+		{Key: "targets.Serverless_Stg.acount_id", Line: 13, Column: 5},
+		{Key: "pipeline.freeze_windows[0].clasifications", Line: 22, Column: 7},
+	}, fields)
+}
+
+func TestFindUnknownFieldsMergeKeys(t *testing.T) {
+	content := `
+target_templates:
+  base: &base
+    regoin: us-east-1
+targets:
+  Serverless_Stg:
+    <<: *base
+    imports: [analytics]
+  Serverless_Prod:
+    <<: *base
+    imports: [Serverless_Stg]
+`
+	fields, err := FindUnknownFields([]byte(content))
+	require.NoError(t, err)
+	require.Len(t, fields, 1)
+	assert.Equal(t, "target_templates.base.regoin", fields[0].Key)
+}
+
+func TestLoadConfigStrict(t *testing.T) {
+	path := writeTestConfig(t, strictTestConfig)
+
+	// Unknown keys are ignored by default
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Serverless_Stg"}, cfg.Targets["Serverless_Prod"].Imports)
+
+	_, err = LoadConfigWithOptions(path, LoadOptions{Strict: true})
+	var unknown *UnknownFieldsError
+	require.True(t, errors.As(err, &unknown))
+	assert.Len(t, unknown.Fields, 3)
+	assert.Contains(t, err.Error(), `line 8, column 1: unknown key "merge_stor"`)
+
+	valid := writeTestConfig(t, `
+vault:
+  address: https://vault.example.com
+targets:
+  Serverless_Stg:
+    account_id: "111111111111"
+`)
+	_, err = LoadConfigWithOptions(valid, LoadOptions{Strict: true})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// syncHashesNamespace is where the content each destination was last synced
+// with is kept in the merge store, one secret per target mapping destination
+// labels to fingerprints
+const syncHashesNamespace = "_vss_sync_hashes"
+
+// unchangedSyncs skips syncing destinations that were last synced with the
+// target's current merged secrets and config (pipeline.sync.skip_unchanged),
+// and records what each destination is synced with. A nil tracker skips and
+// records nothing.
+type unchangedSyncs struct {
+	store mergeStore
+	// force syncs every destination, still recording what it was synced with
+	force bool
+	// recorded holds the targets with recorded fingerprints
+	recorded map[string]bool
+}
+
+// startUnchangedSyncs reads which targets have recorded fingerprints
+func (p *Pipeline) startUnchangedSyncs(ctx context.Context, force bool) (*unchangedSyncs, error) {
+	store, err := p.openMergeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names, err := store.ListSecrets(ctx, syncHashesNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sync hashes: %w", err)
+	}
+	u := &unchangedSyncs{store: store, force: force, recorded: make(map[string]bool, len(names))}
+	for _, name := range names {
+		u.recorded[name] = true
+	}
+	return u, nil
+}
+
+// load returns the hash of a target's merged secrets and the fingerprints its
+// destinations were last synced with. Failing to read either only costs a
+// sync, so it is logged, not returned, and nothing is skipped.
+func (u *unchangedSyncs) load(ctx context.Context, target string) (string, map[string]string) {
+	if u == nil {
+		return "", nil
+	}
+	l := log.WithFields(log.Fields{
+		"action": "unchangedSyncs.load",
+		"target": target,
+	})
+	content, err := mergedContentHash(ctx, u.store, target)
+	if err != nil {
+		l.WithError(err).Warn("Failed to hash merged secrets, syncing every destination")
+		return "", nil
+	}
+	synced := make(map[string]string)
+	if !u.recorded[target] {
+		return content, synced
+	}
+	data, err := u.store.ReadSecret(ctx, syncHashesNamespace, target)
+	if err != nil {
+		l.WithError(err).Warn("Failed to read sync hashes, syncing every destination")
+		return content, synced
+	}
+	for label, v := range data {
+		if fp, ok := v.(string); ok {
+			synced[label] = fp
+		}
+	}
+	return content, synced
+}
+
+// unchanged reports whether a destination was last synced with fingerprint
+func (u *unchangedSyncs) unchanged(synced map[string]string, label, fingerprint string) bool {
+	return u != nil && !u.force && fingerprint != "" && synced[label] == fingerprint
+}
+
+// save records the fingerprints a target's destinations are now synced with
+func (u *unchangedSyncs) save(ctx context.Context, target string, synced map[string]string) {
+	if u == nil {
+		return
+	}
+	data := make(map[string]interface{}, len(synced))
+	for label, fp := range synced {
+		data[label] = fp
+	}
+	if err := u.store.WriteSecret(ctx, syncHashesNamespace, target, data); err != nil {
+		log.WithFields(log.Fields{
+			"action": "unchangedSyncs.save",
+			"target": target,
+		}).WithError(err).Warn("Failed to record sync hashes, the next run syncs the target again")
+	}
+}
+
+// forgetSyncs removes a target's recorded fingerprints, so a target of the
+// same name added later is synced in full
+func forgetSyncs(ctx context.Context, store mergeStore, target string) error {
+	names, err := store.ListSecrets(ctx, syncHashesNamespace)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if name == target {
+			return store.DeleteSecret(ctx, syncHashesNamespace, target)
+		}
+	}
+	return nil
+}
+
+// syncFingerprint identifies what a destination is synced with: the hash of
+// the target's merged secrets and the target and destination config, so a
+// config change such as a new transform syncs again. Other destinations'
+// config is left out. It is "" without a content hash.
+func syncFingerprint(content string, target Target, dest Destination, region string) string {
+	if content == "" {
+		return ""
+	}
+	target.Destinations = nil
+	data, err := json.Marshal(struct {
+		Content     string
+		Target      Target
+		Destination Destination
+		Region      string
+	}{content, target, dest, region})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
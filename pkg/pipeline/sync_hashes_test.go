@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/internal/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncSkipsUnchangedDestinations(t *testing.T) {
+	wait := backend.ManualTriggerAndWait
+	t.Cleanup(func() { backend.ManualTriggerAndWait = wait })
+
+	var synced []string
+	fail := map[string]bool{}
+	backend.ManualTriggerAndWait = func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) (*backend.SyncCompletion, error) {
+		synced = append(synced, cfg.Name)
+		if fail[cfg.Name] {
+			return &backend.SyncCompletion{Name: cfg.Name}, errors.New("API error: status=500")
+		}
+		return &backend.SyncCompletion{Name: cfg.Name}, nil
+	}
+
+	ctx := context.Background()
+	store := memMergeStore{"Prod": {"db": {"password": "v1"}}}
+	p := &Pipeline{
+		config: &Config{
+			Pipeline: PipelineSettings{Sync: SyncSettings{SkipUnchanged: true}},
+			Targets: map[string]Target{"Prod": {Destinations: []Destination{
+				{Doppler: &DopplerDestination{Project: "analytics", Config: "prd"}},
+				{Doppler: &DopplerDestination{Project: "web", Config: "prd"}},
+			}}},
+		},
+		memStore: memDirectStore{store},
+	}
+	sync := func(force bool) Result {
+		t.Helper()
+		synced = nil
+		u, err := p.startUnchangedSyncs(ctx, force)
+		require.NoError(t, err)
+		p.unchanged = u
+		return p.syncTarget(ctx, "Prod", false)
+	}
+
+	result := sync(false)
+	assert.True(t, result.Success)
+	assert.False(t, result.Details.Unchanged)
+	assert.Equal(t, []string{"sync-Prod-0", "sync-Prod-1"}, synced)
+	assert.Len(t, store[syncHashesNamespace]["Prod"], 2)
+
+	result = sync(false)
+	assert.True(t, result.Success)
+	assert.True(t, result.Details.Unchanged)
+	assert.Empty(t, synced)
+	require.Len(t, result.Details.Destinations, 2)
+	assert.Equal(t, DestinationResult{Name: "doppler:analytics/prd", Success: true, Unchanged: true}, result.Details.Destinations[0])
+
+	// Changed secrets sync every destination, changed config only its own
+	store["Prod"]["db"] = map[string]interface{}{"password": "v2"}
+	sync(false)
+	assert.Equal(t, []string{"sync-Prod-0", "sync-Prod-1"}, synced)
+	p.config.Targets["Prod"].Destinations[1].Doppler.Config = "prd_v2"
+	fail["sync-Prod-1"] = true
+	result = sync(false)
+	assert.False(t, result.Success)
+	assert.Equal(t, []string{"sync-Prod-1"}, synced)
+	assert.True(t, result.Details.Destinations[0].Unchanged)
+
+	// A failed destination is synced again
+	delete(fail, "sync-Prod-1")
+	result = sync(false)
+	assert.True(t, result.Success)
+	assert.False(t, result.Details.Unchanged)
+	assert.Equal(t, []string{"sync-Prod-1"}, synced)
+
+	result = sync(true)
+	assert.Equal(t, []string{"sync-Prod-0", "sync-Prod-1"}, synced, "forced syncs skip nothing")
+	assert.False(t, result.Details.Destinations[0].Unchanged)
+
+	// Without a tracker every destination syncs and nothing is recorded
+	p.unchanged = nil
+	synced = nil
+	delete(store, syncHashesNamespace)
+	p.syncTarget(ctx, "Prod", false)
+	assert.Equal(t, []string{"sync-Prod-0", "sync-Prod-1"}, synced)
+	assert.Empty(t, store[syncHashesNamespace])
+}
+
+func TestForgetSyncs(t *testing.T) {
+	ctx := context.Background()
+	store := memMergeStore{syncHashesNamespace: {"Prod": {"doppler:analytics/prd": "abc"}}}
+	require.NoError(t, forgetSyncs(ctx, store, "Stg"))
+	require.NoError(t, forgetSyncs(ctx, store, "Prod"))
+	assert.Empty(t, store[syncHashesNamespace])
+}
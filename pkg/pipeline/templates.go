@@ -0,0 +1,200 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// TargetTemplate declares the fields shared by a family of near-identical targets.
+// A target that sets `template: <name>` inherits every field it leaves unset; its
+// imports are appended to the template's. String fields may reference {{.Name}}
+// (the target name), {{.AccountID}} and {{.Params.<key>}}, which are substituted
+// per target when the config is loaded.
+//
+//	target_templates:
+//	  sandbox:
+//	    imports: [analytics, analytics-engineers]
+//	    secret_prefix: "sandboxes/{{.Params.owner}}/"
+//	    role_arn: "arn:aws:iam::{{.AccountID}}:role/SecretsSync"
+//
+//	targets:
+//	  Sandbox_Alice: {template: sandbox, account_id: "111111111111", params: {owner: alice}}
+//	  Sandbox_Bob:   {template: sandbox, account_id: "222222222222", params: {owner: bob}}
+type TargetTemplate struct {
+	AccountID    string        `mapstructure:"account_id" yaml:"account_id,omitempty"`
+	Imports      []string      `mapstructure:"imports" yaml:"imports,omitempty"`
+	Region       string        `mapstructure:"region" yaml:"region,omitempty"`
+	SecretPrefix string        `mapstructure:"secret_prefix" yaml:"secret_prefix,omitempty"`
+	RoleARN      string        `mapstructure:"role_arn" yaml:"role_arn,omitempty"`
+	Destinations []Destination `mapstructure:"destinations" yaml:"destinations,omitempty"`
+	Parallelism  int           `mapstructure:"parallelism" yaml:"parallelism,omitempty"`
+
+	Classification string            `mapstructure:"classification" yaml:"classification,omitempty"`
+	Owners         []string          `mapstructure:"owners" yaml:"owners,omitempty"`
+	Tags           map[string]string `mapstructure:"tags" yaml:"tags,omitempty"`
+	Labels         map[string]string `mapstructure:"labels" yaml:"labels,omitempty"`
+
+	// Preconditions apply to targets that declare none of their own
+	Preconditions []Precondition `mapstructure:"preconditions" yaml:"preconditions,omitempty"`
+
+	// Params are default parameter values; targets override them individually
+	Params map[string]string `mapstructure:"params" yaml:"params,omitempty"`
+}
+
+// templateData is what template fields are rendered against
+type templateData struct {
+	Name      string
+	AccountID string
+	Params    map[string]string
+}
+
+// expandTargetTemplates merges each templated target with its template and renders
+// parameter references, so the rest of the pipeline only ever sees concrete targets
+func (c *Config) expandTargetTemplates() error {
+	names := make([]string, 0, len(c.Targets))
+	for name, t := range c.Targets {
+		if t.Template != "" {
+			names = append(names, name)
+		}
+	}
+	// Report errors in a stable order
+	sort.Strings(names)
+
+	for _, name := range names {
+		expanded, err := c.expandTargetTemplate(name, c.Targets[name])
+		if err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+		c.Targets[name] = expanded
+	}
+	return nil
+}
+
+func (c *Config) expandTargetTemplate(name string, t Target) (Target, error) {
+	tmpl, ok := c.TargetTemplates[t.Template]
+	if !ok {
+		return t, fmt.Errorf("template %q not found in target_templates", t.Template)
+	}
+
+	params := make(map[string]string, len(tmpl.Params)+len(t.Params))
+	for k, v := range tmpl.Params {
+		params[k] = v
+	}
+	for k, v := range t.Params {
+		params[k] = v
+	}
+
+	if t.AccountID == "" {
+		t.AccountID = tmpl.AccountID
+	}
+	if t.Region == "" {
+		t.Region = tmpl.Region
+	}
+	if t.SecretPrefix == "" {
+		t.SecretPrefix = tmpl.SecretPrefix
+	}
+	if t.RoleARN == "" {
+		t.RoleARN = tmpl.RoleARN
+	}
+	if t.Classification == "" {
+		t.Classification = tmpl.Classification
+	}
+	if len(t.Owners) == 0 {
+		t.Owners = append([]string(nil), tmpl.Owners...)
+	}
+	if len(tmpl.Tags) > 0 {
+		tags := make(map[string]string, len(tmpl.Tags)+len(t.Tags))
+		for k, v := range tmpl.Tags {
+			tags[k] = v
+		}
+		for k, v := range t.Tags {
+			tags[k] = v
+		}
+		t.Tags = tags
+	}
+	if len(tmpl.Labels) > 0 {
+		labels := make(map[string]string, len(tmpl.Labels)+len(t.Labels))
+		for k, v := range tmpl.Labels {
+			labels[k] = v
+		}
+		for k, v := range t.Labels {
+			labels[k] = v
+		}
+		t.Labels = labels
+	}
+	if len(t.Preconditions) == 0 {
+		t.Preconditions = append([]Precondition(nil), tmpl.Preconditions...)
+	}
+	if len(t.Destinations) == 0 && t.AccountID == "" && t.GitHub == nil && t.Kubernetes == nil {
+		t.Destinations = append([]Destination(nil), tmpl.Destinations...)
+	}
+	if t.Parallelism == 0 {
+		t.Parallelism = tmpl.Parallelism
+	}
+	imports := append([]string{}, tmpl.Imports...)
+	for _, imp := range t.Imports {
+		imports = appendUniqueString(imports, imp)
+	}
+	t.Imports = imports
+
+	// The account ID is rendered first so other fields can reference it
+	data := templateData{Name: name, Params: params}
+	var err error
+	render := func(field, s string) string {
+		if err != nil || !strings.Contains(s, "{{") {
+			return s
+		}
+		var out string
+		out, err = renderTargetTemplate(field, s, data)
+		return out
+	}
+	t.AccountID = render("account_id", t.AccountID)
+	data.AccountID = t.AccountID
+	t.Region = render("region", t.Region)
+	t.SecretPrefix = render("secret_prefix", t.SecretPrefix)
+	t.RoleARN = render("role_arn", t.RoleARN)
+	for i, imp := range t.Imports {
+		t.Imports[i] = render("imports", imp)
+	}
+	for i, owner := range t.Owners {
+		t.Owners[i] = render("owners", owner)
+	}
+	for i := range t.Preconditions {
+		pc := t.Preconditions[i]
+		pc.HTTP = render("preconditions.http", pc.HTTP)
+		pc.SSMParameter = render("preconditions.ssm_parameter", pc.SSMParameter)
+		pc.Equals = render("preconditions.equals", pc.Equals)
+		pc.VaultPath = render("preconditions.vault_path", pc.VaultPath)
+		t.Preconditions[i] = pc
+	}
+	for i := range t.Destinations {
+		d := t.Destinations[i]
+		d.AccountID = render("destinations.account_id", d.AccountID)
+		d.Region = render("destinations.region", d.Region)
+		d.RoleARN = render("destinations.role_arn", d.RoleARN)
+		t.Destinations[i] = d
+	}
+	return t, err
+}
+
+func renderTargetTemplate(field, s string, data templateData) (string, error) {
+	tpl, err := template.New(field).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid template in %s: %w", field, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", field, err)
+	}
+	return buf.String(), nil
+}
+
+func appendUniqueString(list []string, s string) []string {
+	if containsString(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigTargetTemplates(t *testing.T) {
+	path := writeTestConfig(t, `
+vault:
+  address: https://vault.example.com
+sources:
+  analytics:
+    vault:
+      mount: analytics
+  analytics-engineers:
+    vault:
+      mount: analytics-engineers
+  payments:
+    vault:
+      mount: payments
+merge_store:
+  vault:
+    mount: merged
+target_templates:
+  sandbox:
+    imports: [analytics, analytics-engineers]
+    region: us-west-2
+    secret_prefix: "sandboxes/{{.Params.owner}}/"
+    role_arn: "arn:aws:iam::{{.AccountID}}:role/SecretsSync"
+    owners: ["{{.Params.owner}}@example.com"]
+    params:
+      owner: shared
+targets:
+  Sandbox_Alice: {template: sandbox, account_id: "111111111111", params: {owner: alice}}
+  Sandbox_Bob: {template: sandbox, account_id: "222222222222", imports: [payments, analytics]}
+  Sandbox_Carol: {template: sandbox, account_id: "333333333333", region: eu-west-1, owners: ["@carol"]}
+`)
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	alice := cfg.Targets["Sandbox_Alice"]
+	assert.Equal(t, []string{"analytics", "analytics-engineers"}, alice.Imports)
+	assert.Equal(t, "us-west-2", alice.Region)
+	assert.Equal(t, "sandboxes/alice/", alice.SecretPrefix)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/SecretsSync", alice.RoleARN)
+	assert.Equal(t, []string{"alice@example.com"}, alice.Owners)
+
+	// Target imports are appended to the template's without duplicates
+	bob := cfg.Targets["Sandbox_Bob"]
+	assert.Equal(t, []string{"analytics", "analytics-engineers", "payments"}, bob.Imports)
+	assert.Equal(t, "sandboxes/shared/", bob.SecretPrefix)
+
+	// Explicit target fields win over the template
+	assert.Equal(t, "eu-west-1", cfg.Targets["Sandbox_Carol"].Region)
+	assert.Equal(t, []string{"@carol"}, cfg.Owners("Sandbox_Carol"))
+	assert.Equal(t, []string{"shared@example.com"}, cfg.Owners("Sandbox_Bob"))
+
+	// Templates are not shared between targets after expansion
+	assert.NotSame(t, &alice.Imports[0], &bob.Imports[0])
+}
+
+func TestLoadConfigTargetTemplateErrors(t *testing.T) {
+	base := `
+vault:
+  address: https://vault.example.com
+merge_store:
+  vault:
+    mount: merged
+`
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{
+			name: "unknown template",
+			content: `
+targets:
+  web: {template: missing, account_id: "111111111111"}
+`,
+			errMsg: `template "missing" not found`,
+		},
+		{
+			name: "missing parameter",
+			content: `
+target_templates:
+  sandbox:
+    secret_prefix: "{{.Params.owner}}/"
+targets:
+  web: {template: sandbox, account_id: "111111111111"}
+`,
+			errMsg: "failed to render secret_prefix",
+		},
+		{
+			name: "invalid template syntax",
+			content: `
+target_templates:
+  sandbox:
+    role_arn: "arn:aws:iam::{{.AccountID:role/x"
+targets:
+  web: {template: sandbox, account_id: "111111111111"}
+`,
+			errMsg: "invalid template in role_arn",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeTestConfig(t, base+tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Contains(t, err.Error(), `target "web"`)
+		})
+	}
+}
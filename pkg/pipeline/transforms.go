@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/pkg/transforms"
+	log "github.com/sirupsen/logrus"
+)
+
+// KeyTransforms reshape secret keys on their way through the pipeline. On a
+// source they apply as the source is merged into each importing target; on a
+// target they apply to everything its destinations receive, after any
+// destination transforms' renames.
+type KeyTransforms struct {
+	// Rename maps existing key names to new names
+	Rename map[string]string `mapstructure:"rename" yaml:"rename,omitempty"`
+	// StripPrefix and StripSuffix are removed from each key after renaming,
+	// e.g. APP_ so APP_TOKEN becomes TOKEN
+	StripPrefix string `mapstructure:"strip_prefix" yaml:"strip_prefix,omitempty"`
+	StripSuffix string `mapstructure:"strip_suffix" yaml:"strip_suffix,omitempty"`
+	// Case converts each key to upper or lower case after stripping
+	Case string `mapstructure:"case" yaml:"case,omitempty"`
+	// Name is a Go template for the name each secret is written under, with
+	// .Name or .SecretName (the secret's name in the merge store), .Target
+	// and .Prefix (the target's secret_prefix), e.g.
+	// "{{.Prefix}}/{{.Target}}/{{.SecretName}}". It applies to AWS,
+	// Kubernetes and gRPC destinations (unless a gRPC path is set) and is
+	// only supported on targets.
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
+}
+
+// rules returns the key rules; nil transforms change nothing
+func (t *KeyTransforms) rules() transforms.KeyRules {
+	if t == nil {
+		return transforms.KeyRules{}
+	}
+	return transforms.KeyRules{
+		Rename:      t.Rename,
+		StripPrefix: t.StripPrefix,
+		StripSuffix: t.StripSuffix,
+		Case:        t.Case,
+	}
+}
+
+// validate checks the key rules and, for a target, that the name template
+// renders a distinct name per secret
+func (t *KeyTransforms) validate(targetName string) error {
+	if err := t.rules().Validate(); err != nil {
+		return err
+	}
+	if t.Name == "" {
+		return nil
+	}
+	if targetName == "" {
+		return fmt.Errorf("name is only supported on targets")
+	}
+	_, err := transforms.NamePattern(t.Name, targetName, "$1")
+	return err
+}
+
+// applyTo adds the key rules to a sync's transform spec. Renames are listed
+// before the spec's own, so a destination rename of the same key wins.
+func (t *KeyTransforms) applyTo(sync *v1alpha1.VaultSecretSync) {
+	rules := t.rules()
+	if rules.IsZero() {
+		return
+	}
+	spec := sync.Spec.Transforms
+	if spec == nil {
+		spec = &v1alpha1.TransformSpec{}
+		sync.Spec.Transforms = spec
+	}
+	from := make([]string, 0, len(rules.Rename))
+	for k := range rules.Rename {
+		from = append(from, k)
+	}
+	sort.Strings(from)
+	var rename []v1alpha1.RenameTransform
+	for _, k := range from {
+		rename = append(rename, v1alpha1.RenameTransform{From: k, To: rules.Rename[k]})
+	}
+	spec.Rename = append(rename, spec.Rename...)
+	if rules.StripPrefix != "" {
+		spec.StripPrefix = &rules.StripPrefix
+	}
+	if rules.StripSuffix != "" {
+		spec.StripSuffix = &rules.StripSuffix
+	}
+	if rules.Case != "" {
+		spec.Case = &rules.Case
+	}
+}
+
+// applyName points a sync's AWS, Kubernetes or gRPC destination at the
+// target's name template, rendered with the target's secret prefix. Doppler
+// and GitHub destinations have no secret names, only keys.
+func (t *KeyTransforms) applyName(sync *v1alpha1.VaultSecretSync, targetName, prefix string) {
+	if t == nil || t.Name == "" {
+		return
+	}
+	pattern, err := transforms.NameData{Target: targetName, Prefix: prefix}.Pattern(t.Name, "$1")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"action": "applyName",
+			"target": targetName,
+		}).WithError(err).Warn("Ignoring invalid name template")
+		return
+	}
+	for _, d := range sync.Spec.Dest {
+		switch {
+		case d.AWS != nil:
+			d.AWS.Name = pattern
+		case d.Kubernetes != nil:
+			d.Kubernetes.Name = pattern
+		case d.GRPC != nil && d.GRPC.Path == "$1":
+			d.GRPC.Path = pattern
+		}
+	}
+}
+
+// applyKeyRules transforms the keys of each secret read for a merge
+func applyKeyRules(rules transforms.KeyRules, secrets map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
+	if rules.IsZero() {
+		return secrets, nil
+	}
+	out := make(map[string]map[string]interface{}, len(secrets))
+	for name, data := range secrets {
+		transformed, err := rules.Apply(data)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %w", name, err)
+		}
+		out[name] = transformed
+	}
+	return out, nil
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTargetTransformsSync(t *testing.T) {
+	content := `
+account_id: "111111111111"
+imports: [analytics]
+transforms:
+  rename:
+    pw: APP_password
+  strip_prefix: APP_
+  case: lower
+  name: "/{{.Target}}/app/{{.Name}}"
+`
+	var target Target
+	require.NoError(t, yaml.Unmarshal([]byte(content), &target))
+	require.NotNil(t, target.Transforms)
+	require.NoError(t, target.Transforms.validate("Analytics_Prod"))
+
+	p := &Pipeline{config: &Config{Vault: VaultConfig{Address: "https://vault.example.com"}}}
+	dest := Destination{
+		AccountID:  "111111111111",
+		Transforms: &DestinationTransforms{Rename: map[string]string{"pw": "PASS"}},
+	}
+	sync, _ := p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", target, dest, false)
+	spec := sync.Spec.Transforms
+	require.NotNil(t, spec)
+	// The destination's rename of the same key is listed last, so it wins
+	assert.Equal(t, []v1alpha1.RenameTransform{{From: "pw", To: "APP_password"}, {From: "pw", To: "PASS"}}, spec.Rename)
+	require.NotNil(t, spec.StripPrefix)
+	assert.Equal(t, "APP_", *spec.StripPrefix)
+	require.NotNil(t, spec.Case)
+	assert.Equal(t, "lower", *spec.Case)
+	assert.Nil(t, spec.StripSuffix)
+	assert.Equal(t, "/Analytics_Prod/app/$1", sync.Spec.Dest[0].AWS.Name)
+
+	// An explicit gRPC path is kept
+	sync, _ = p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", target, Destination{GRPC: &GRPCDestination{Address: "store:8443", Path: "apps/$1"}}, false)
+	assert.Equal(t, "apps/$1", sync.Spec.Dest[0].GRPC.Path)
+
+	// Targets without transforms are unchanged
+	sync, _ = p.destinationSync("Analytics_Prod", "merged/Analytics_Prod", Target{}, Destination{AccountID: "111111111111"}, false)
+	assert.Nil(t, sync.Spec.Transforms)
+	assert.Equal(t, "$1", sync.Spec.Dest[0].AWS.Name)
+}
+
+func TestKeyTransformsValidate(t *testing.T) {
+	assert.NoError(t, (&KeyTransforms{StripSuffix: "_PROD", Case: "upper"}).validate(""))
+	assert.ErrorContains(t, (&KeyTransforms{Case: "camel"}).validate(""), `got "camel"`)
+	assert.ErrorContains(t, (&KeyTransforms{Name: "{{.Name}}"}).validate(""), "only supported on targets")
+	assert.ErrorContains(t, (&KeyTransforms{Name: "{{.Target}}/static"}).validate("Prod"), "exactly once")
+
+	cfg := &Config{
+		Vault:      VaultConfig{Address: "https://vault.example.com"},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}, Transforms: &KeyTransforms{Name: "{{.Name}}"}},
+		},
+		Targets: map[string]Target{
+			"Prod": {AccountID: "111111111111", Imports: []string{"analytics"}},
+		},
+	}
+	assert.ErrorContains(t, cfg.Validate(), `source "analytics": transforms: name is only supported on targets`)
+}
+
+func TestSourceTransformsMerge(t *testing.T) {
+	p := &Pipeline{
+		config: &Config{
+			Sources: map[string]Source{
+				"analytics": {
+					Vault:      &VaultSource{Mount: "analytics"},
+					Transforms: &KeyTransforms{Rename: map[string]string{"legacy": "TOKEN"}, StripSuffix: "_STG", Case: "upper"},
+				},
+			},
+		},
+		openVaultSource: func(_ context.Context, _ *VaultSource) (vaultReader, error) {
+			return fakeVault{
+				"analytics/db":  {"host_STG": "db.internal"},
+				"analytics/api": {"legacy": "abc"},
+			}, nil
+		},
+	}
+	secrets, err := p.readImport(context.Background(), memMergeStore{}, "analytics")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"db":  {"HOST": "db.internal"},
+		"api": {"TOKEN": "abc"},
+	}, secrets)
+}
@@ -0,0 +1,458 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/hashicorp/vault/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// serviceAccountTokenPath is where Kubernetes mounts a pod's service account token
+const serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// vaultRetryInterval is how long the authenticator waits after a failed
+// renewal or login before trying again
+const vaultRetryInterval = 30 * time.Second
+
+// stsGetCallerIdentityBody is the request Vault's AWS auth method has STS
+// answer to learn the caller's IAM principal
+const stsGetCallerIdentityBody = "Action=GetCallerIdentity&Version=2011-06-15"
+
+// oidcHTTPClient requests GitHub Actions OIDC tokens
+var oidcHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// method returns the configured auth method, or "" when none is
+func (a VaultAuthConfig) method() string {
+	switch {
+	case a.AppRole != nil:
+		return "approle"
+	case a.Kubernetes != nil:
+		return "kubernetes"
+	case a.Token != nil:
+		return "token"
+	case a.AWS != nil:
+		return "aws"
+	case a.JWT != nil:
+		return "jwt"
+	}
+	return ""
+}
+
+func (a VaultAuthConfig) validate() error {
+	set := 0
+	for _, m := range []bool{a.AppRole != nil, a.Kubernetes != nil, a.Token != nil, a.AWS != nil, a.JWT != nil} {
+		if m {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return fmt.Errorf("set only one of approle, kubernetes, token, aws or jwt")
+	case a.AppRole != nil && (a.AppRole.RoleID == "" || a.AppRole.SecretID == ""):
+		return fmt.Errorf("approle: role_id and secret_id are required")
+	case a.Kubernetes != nil && a.Kubernetes.Role == "":
+		return fmt.Errorf("kubernetes: role is required")
+	case a.Token != nil && a.Token.Token == "":
+		return fmt.Errorf("token: token is required")
+	case a.JWT != nil && a.JWT.Role == "":
+		return fmt.Errorf("jwt: role is required")
+	}
+	return nil
+}
+
+// vaultAuthenticator logs in to Vault with the configured auth method and
+// keeps the token alive: it renews the token at two thirds of its TTL, logs
+// in again once the token cannot be renewed any further and revokes tokens
+// it logged in for when its context ends or it is closed. Every Vault client
+// of the pipeline's Vault takes its token from Token; see
+// vault.SetTokenSource.
+type vaultAuthenticator struct {
+	config VaultConfig
+	// ctx bounds the renewal loop; cancel ends it and done is closed once it
+	// has returned
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// jwtPath is the Kubernetes service account token file
+	jwtPath string
+	// awsCredentials signs AWS logins instead of the default credential chain
+	awsCredentials aws.CredentialsProvider
+
+	mu        sync.Mutex
+	client    *api.Client
+	token     string
+	renewable bool
+	// ttl is the token's TTL when it was last issued or renewed, and loginTTL
+	// the TTL it was issued with
+	ttl      time.Duration
+	loginTTL time.Duration
+	// refreshed is when the token was last issued or renewed, and next when
+	// it is to be refreshed
+	refreshed time.Time
+	next      time.Time
+	running   bool
+}
+
+func newVaultAuthenticator(ctx context.Context, cfg VaultConfig) *vaultAuthenticator {
+	a := &vaultAuthenticator{config: cfg, jwtPath: serviceAccountTokenPath, done: make(chan struct{})}
+	a.ctx, a.cancel = context.WithCancel(ctx)
+	return a
+}
+
+// Close stops the renewal loop and revokes a token the authenticator logged
+// in for, waiting for both so the token is gone when Close returns
+func (a *vaultAuthenticator) Close() {
+	a.cancel()
+	a.mu.Lock()
+	running := a.running
+	a.mu.Unlock()
+	if running {
+		<-a.done
+	}
+	a.revoke()
+}
+
+// Token returns a valid token, logging in on first use or once the token has
+// expired, and starts the renewal loop
+func (a *vaultAuthenticator) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == "" || a.expired(time.Now()) {
+		if err := a.ctx.Err(); err != nil {
+			return "", fmt.Errorf("vault auth stopped: %w", err)
+		}
+		if err := a.login(ctx); err != nil {
+			return "", err
+		}
+	}
+	if !a.running && a.ttl > 0 {
+		a.running = true
+		go a.run()
+	}
+	return a.token, nil
+}
+
+// expired reports whether the token's TTL has run out; tokens without a TTL
+// never expire
+func (a *vaultAuthenticator) expired(now time.Time) bool {
+	return a.ttl > 0 && !now.Before(a.refreshed.Add(a.ttl))
+}
+
+func (a *vaultAuthenticator) apiClient() (*api.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	client, err := api.NewClient(&api.Config{Address: a.config.Address})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault client: %w", err)
+	}
+	if a.config.Namespace != "" {
+		client.SetNamespace(a.config.Namespace)
+	}
+	a.client = client
+	return client, nil
+}
+
+// login obtains a new token with the configured auth method
+func (a *vaultAuthenticator) login(ctx context.Context) error {
+	client, err := a.apiClient()
+	if err != nil {
+		return err
+	}
+	method := a.config.Auth.method()
+	l := log.WithFields(log.Fields{
+		"action": "vaultAuthenticator.login",
+		"method": method,
+	})
+
+	var secret *api.Secret
+	switch method {
+	case "approle":
+		auth := a.config.Auth.AppRole
+		secret, err = client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", mountOr(auth.Mount, "approle")), map[string]interface{}{
+			"role_id":   auth.RoleID,
+			"secret_id": auth.SecretID,
+		})
+	case "kubernetes":
+		auth := a.config.Auth.Kubernetes
+		jwt, readErr := os.ReadFile(a.jwtPath)
+		if readErr != nil {
+			return fmt.Errorf("failed to read service account token: %w", readErr)
+		}
+		secret, err = client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", mountOr(auth.MountPath, "kubernetes")), map[string]interface{}{
+			"role": auth.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		})
+	case "token":
+		client.SetToken(a.config.Auth.Token.Token)
+		secret, err = client.Auth().Token().LookupSelfWithContext(ctx)
+	case "aws":
+		data, dataErr := a.awsLoginData(ctx)
+		if dataErr != nil {
+			return fmt.Errorf("failed to sign AWS login request: %w", dataErr)
+		}
+		secret, err = client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", mountOr(a.config.Auth.AWS.Mount, "aws")), data)
+	case "jwt":
+		auth := a.config.Auth.JWT
+		jwt, jwtErr := oidcToken(ctx, auth)
+		if jwtErr != nil {
+			return jwtErr
+		}
+		secret, err = client.Logical().WriteWithContext(ctx, fmt.Sprintf("auth/%s/login", mountOr(auth.Mount, "jwt")), map[string]interface{}{
+			"role": auth.Role,
+			"jwt":  jwt,
+		})
+	default:
+		return fmt.Errorf("no Vault auth method configured")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to authenticate to Vault with %s: %w", method, err)
+	}
+	if secret == nil {
+		return fmt.Errorf("failed to authenticate to Vault with %s: empty response", method)
+	}
+
+	if method == "token" {
+		a.token = a.config.Auth.Token.Token
+	} else {
+		if secret.Auth == nil {
+			return fmt.Errorf("failed to authenticate to Vault with %s: no token in response", method)
+		}
+		a.token = secret.Auth.ClientToken
+	}
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return fmt.Errorf("failed to read token TTL: %w", err)
+	}
+	renewable, err := secret.TokenIsRenewable()
+	if err != nil {
+		return fmt.Errorf("failed to read whether the token is renewable: %w", err)
+	}
+	client.SetToken(a.token)
+	a.ttl, a.loginTTL, a.renewable = ttl, ttl, renewable
+	a.setRefreshed(time.Now())
+	l.WithFields(log.Fields{"ttl": ttl, "renewable": renewable}).Info("Authenticated to Vault")
+	return nil
+}
+
+// refresh renews the token, or logs in again when it cannot be renewed or
+// renewing it no longer extends it by a third of the TTL it was issued with
+func (a *vaultAuthenticator) refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l := log.WithFields(log.Fields{"action": "vaultAuthenticator.refresh"})
+
+	if a.renewable {
+		secret, err := a.client.Auth().Token().RenewSelfWithContext(ctx, int(a.loginTTL.Seconds()))
+		switch {
+		case err != nil:
+			l.WithError(err).Warn("Failed to renew Vault token")
+		case secret == nil || secret.Auth == nil:
+			l.Warn("Failed to renew Vault token: empty response")
+		default:
+			a.ttl = time.Duration(secret.Auth.LeaseDuration) * time.Second
+			a.setRefreshed(time.Now())
+			// A configured token cannot be replaced, so it is renewed for
+			// as long as it can be
+			if a.ttl >= a.loginTTL/3 || a.config.Auth.method() == "token" {
+				l.WithField("ttl", a.ttl).Debug("Renewed Vault token")
+				return nil
+			}
+			l.WithField("ttl", a.ttl).Info("Vault token is near its max TTL")
+		}
+	}
+	if a.config.Auth.method() == "token" {
+		return fmt.Errorf("vault.auth.token cannot be renewed and expires at %s", a.refreshed.Add(a.ttl).Format(time.RFC3339))
+	}
+	return a.login(ctx)
+}
+
+// setRefreshed records that the token was issued or renewed at now, to be
+// refreshed at two thirds of its TTL
+func (a *vaultAuthenticator) setRefreshed(now time.Time) {
+	a.refreshed = now
+	a.next = now.Add(a.ttl * 2 / 3)
+}
+
+// run refreshes the token until the authenticator's context ends, then
+// revokes it
+func (a *vaultAuthenticator) run() {
+	defer close(a.done)
+	l := log.WithFields(log.Fields{"action": "vaultAuthenticator.run"})
+	for {
+		a.mu.Lock()
+		wait, ttl := time.Until(a.next), a.ttl
+		a.mu.Unlock()
+		if ttl == 0 {
+			// Tokens without a TTL need no renewal
+			return
+		}
+
+		select {
+		case <-a.ctx.Done():
+			a.revoke()
+			return
+		case <-time.After(wait):
+		}
+		if err := a.refresh(a.ctx); err != nil {
+			l.WithError(err).Warn("Failed to refresh Vault token")
+			a.mu.Lock()
+			a.next = time.Now().Add(vaultRetryInterval)
+			expired := a.expired(time.Now())
+			a.mu.Unlock()
+			if expired && a.config.Auth.method() == "token" {
+				return
+			}
+		}
+	}
+}
+
+// revoke revokes a token the authenticator logged in for; configured tokens
+// are left alone
+func (a *vaultAuthenticator) revoke() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == "" || a.config.Auth.method() == "token" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.client.Auth().Token().RevokeSelfWithContext(ctx, ""); err != nil {
+		log.WithFields(log.Fields{"action": "vaultAuthenticator.revoke"}).WithError(err).Warn("Failed to revoke Vault token")
+	}
+	a.token = ""
+}
+
+// awsLoginData signs an sts:GetCallerIdentity request with the pipeline's AWS
+// credentials, for Vault to send to STS and learn the IAM principal from
+func (a *vaultAuthenticator) awsLoginData(ctx context.Context) (map[string]interface{}, error) {
+	auth := a.config.Auth.AWS
+	region, endpoint := "us-east-1", "https://sts.amazonaws.com/"
+	if auth.Region != "" {
+		region, endpoint = auth.Region, fmt.Sprintf("https://sts.%s.amazonaws.com/", auth.Region)
+	}
+	provider := a.awsCredentials
+	if provider == nil {
+		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		provider = cfg.Credentials
+	}
+	creds, err := provider.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(stsGetCallerIdentityBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if auth.ServerIDHeader != "" {
+		req.Header.Set("X-Vault-AWS-IAM-Server-ID", auth.ServerIDHeader)
+	}
+	sum := sha256.Sum256([]byte(stsGetCallerIdentityBody))
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), "sts", region, time.Now()); err != nil {
+		return nil, err
+	}
+	headers, err := json.Marshal(req.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"iam_http_request_method": http.MethodPost,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(endpoint)),
+		"iam_request_body":        base64.StdEncoding.EncodeToString([]byte(stsGetCallerIdentityBody)),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+	}
+	if auth.Role != "" {
+		data["role"] = auth.Role
+	}
+	return data, nil
+}
+
+// oidcToken returns the JWT method's token: the contents of its token file
+// or IRSA's web identity token file, or else a GitHub Actions OIDC token.
+// Files are read on every login so rotated tokens are picked up.
+func oidcToken(ctx context.Context, auth *JWTAuth) (string, error) {
+	path := auth.TokenFile
+	if path == "" {
+		path = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	}
+	if path != "" {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read OIDC token: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+
+	requestURL, requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("no OIDC token: set vault.auth.jwt.token_file or AWS_WEB_IDENTITY_TOKEN_FILE, or run in a GitHub Actions job with id-token: write")
+	}
+	return githubActionsToken(ctx, requestURL, requestToken, auth.Audience)
+}
+
+// githubActionsToken requests an OIDC token for audience from the GitHub
+// Actions token endpoint
+func githubActionsToken(ctx context.Context, requestURL, requestToken, audience string) (string, error) {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	if audience != "" {
+		q := u.Query()
+		q.Set("audience", audience)
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request GitHub Actions OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to request GitHub Actions OIDC token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var out struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub Actions OIDC token: %w", err)
+	}
+	if out.Value == "" {
+		return "", fmt.Errorf("GitHub Actions returned an empty OIDC token")
+	}
+	return out.Value, nil
+}
+
+// mountOr returns mount, or def when it is empty
+func mountOr(mount, def string) string {
+	if mount = strings.Trim(mount, "/"); mount != "" {
+		return mount
+	}
+	return def
+}
@@ -0,0 +1,279 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVaultAuth serves the auth endpoints the authenticator uses
+type fakeVaultAuth struct {
+	mu     sync.Mutex
+	logins int
+	// renewTTL is the lease renew-self grants
+	renewTTL int
+	requests []string
+	bodies   []map[string]interface{}
+	revoked  chan string
+}
+
+func (f *fakeVaultAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.URL.Path)
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	f.bodies = append(f.bodies, body)
+
+	auth := func(token string, ttl int) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"auth": map[string]interface{}{"client_token": token, "lease_duration": ttl, "renewable": true},
+		})
+	}
+	switch r.URL.Path {
+	case "/v1/auth/approle/login", "/v1/auth/k8s/login", "/v1/auth/aws/login", "/v1/auth/jwt/login":
+		f.logins++
+		auth("hvs.login"+string(rune('0'+f.logins)), 3600)
+	case "/v1/auth/token/renew-self":
+		auth(r.Header.Get("X-Vault-Token"), f.renewTTL)
+	case "/v1/auth/token/lookup-self":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"ttl": 600, "renewable": false},
+		})
+	case "/v1/auth/token/revoke-self":
+		w.WriteHeader(http.StatusNoContent)
+		f.revoked <- r.Header.Get("X-Vault-Token")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestVaultAuthenticatorAppRole(t *testing.T) {
+	fv := &fakeVaultAuth{renewTTL: 3600, revoked: make(chan string, 1)}
+	srv := httptest.NewServer(fv)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a := newVaultAuthenticator(ctx, VaultConfig{
+		Address: srv.URL,
+		Auth:    VaultAuthConfig{AppRole: &AppRoleAuth{RoleID: "role", SecretID: "secret"}},
+	})
+	token, err := a.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hvs.login1", token)
+	assert.Equal(t, map[string]interface{}{"role_id": "role", "secret_id": "secret"}, fv.bodies[0])
+	assert.Equal(t, time.Hour, a.ttl)
+
+	// The token is reused until it expires
+	token, err = a.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "hvs.login1", token)
+	assert.Equal(t, 1, fv.logins)
+
+	// Renewing keeps the token
+	require.NoError(t, a.refresh(ctx))
+	assert.Equal(t, "hvs.login1", a.token)
+	assert.Equal(t, 1, fv.logins)
+
+	// Near its max TTL, the token is replaced by logging in again
+	fv.renewTTL = 60
+	require.NoError(t, a.refresh(ctx))
+	assert.Equal(t, "hvs.login2", a.token)
+	assert.Equal(t, 2, fv.logins)
+
+	// Ending the context revokes the token
+	cancel()
+	select {
+	case revoked := <-fv.revoked:
+		assert.Equal(t, "hvs.login2", revoked)
+	case <-time.After(5 * time.Second):
+		t.Fatal("token was not revoked")
+	}
+}
+
+func TestPipelineCloseRevokesVaultToken(t *testing.T) {
+	fv := &fakeVaultAuth{renewTTL: 3600, revoked: make(chan string, 1)}
+	srv := httptest.NewServer(fv)
+	defer srv.Close()
+
+	p, err := New(&Config{
+		Vault: VaultConfig{
+			Address: srv.URL,
+			Auth:    VaultAuthConfig{AppRole: &AppRoleAuth{RoleID: "role", SecretID: "secret"}},
+		},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, p.vaultAuth)
+	token, err := p.vaultAuth.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "hvs.login1", token)
+
+	// Closing stops the renewal loop, revokes the token and unsets the
+	// Vault's token source
+	p.Close()
+	select {
+	case revoked := <-fv.revoked:
+		assert.Equal(t, "hvs.login1", revoked)
+	default:
+		t.Fatal("token was not revoked")
+	}
+	vaultAuthsMu.Lock()
+	assert.NotContains(t, vaultAuths, srv.URL)
+	vaultAuthsMu.Unlock()
+	_, err = p.vaultAuth.Token(context.Background())
+	assert.Error(t, err)
+}
+
+func TestVaultAuthenticatorKubernetes(t *testing.T) {
+	fv := &fakeVaultAuth{revoked: make(chan string, 1)}
+	srv := httptest.NewServer(fv)
+	defer srv.Close()
+
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(jwtPath, []byte("eyJhbGciOi.jwt\n"), 0o600))
+	a := newVaultAuthenticator(context.Background(), VaultConfig{
+		Address: srv.URL,
+		Auth:    VaultAuthConfig{Kubernetes: &KubernetesAuth{Role: "secretsync", MountPath: "/k8s/"}},
+	})
+	a.jwtPath = jwtPath
+	require.NoError(t, a.login(context.Background()))
+	assert.Equal(t, "hvs.login1", a.token)
+	assert.Equal(t, map[string]interface{}{"role": "secretsync", "jwt": "eyJhbGciOi.jwt"}, fv.bodies[0])
+}
+
+func TestVaultAuthenticatorToken(t *testing.T) {
+	fv := &fakeVaultAuth{revoked: make(chan string, 1)}
+	srv := httptest.NewServer(fv)
+	defer srv.Close()
+
+	a := newVaultAuthenticator(context.Background(), VaultConfig{
+		Address: srv.URL,
+		Auth:    VaultAuthConfig{Token: &TokenAuth{Token: "hvs.configured"}},
+	})
+	require.NoError(t, a.login(context.Background()))
+	assert.Equal(t, "hvs.configured", a.token)
+	assert.Equal(t, 10*time.Minute, a.ttl)
+	assert.False(t, a.renewable)
+	assert.Equal(t, []string{"/v1/auth/token/lookup-self"}, fv.requests)
+
+	// A configured token that cannot be renewed is not replaced
+	assert.ErrorContains(t, a.refresh(context.Background()), "cannot be renewed")
+}
+
+func TestVaultAuthenticatorAWS(t *testing.T) {
+	fv := &fakeVaultAuth{revoked: make(chan string, 1)}
+	srv := httptest.NewServer(fv)
+	defer srv.Close()
+
+	a := newVaultAuthenticator(context.Background(), VaultConfig{
+		Address: srv.URL,
+		Auth:    VaultAuthConfig{AWS: &VaultAWSAuth{Role: "secretsync", ServerIDHeader: "vault.example.com"}},
+	})
+	a.awsCredentials = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}, nil
+	})
+	require.NoError(t, a.login(context.Background()))
+	assert.Equal(t, "hvs.login1", a.token)
+
+	body := fv.bodies[0]
+	assert.Equal(t, "secretsync", body["role"])
+	assert.Equal(t, "POST", body["iam_http_request_method"])
+	decode := func(key string) string {
+		b, err := base64.StdEncoding.DecodeString(body[key].(string))
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.Equal(t, "https://sts.amazonaws.com/", decode("iam_request_url"))
+	assert.Equal(t, stsGetCallerIdentityBody, decode("iam_request_body"))
+
+	var headers http.Header
+	require.NoError(t, json.Unmarshal([]byte(decode("iam_request_headers")), &headers))
+	assert.Equal(t, "vault.example.com", headers.Get("X-Vault-AWS-IAM-Server-ID"))
+	assert.Equal(t, "session", headers.Get("X-Amz-Security-Token"))
+	assert.Contains(t, headers.Get("Authorization"), "Credential=AKIDEXAMPLE/")
+	assert.Contains(t, headers.Get("Authorization"), "/us-east-1/sts/aws4_request")
+	assert.Contains(t, headers.Get("Authorization"), "x-vault-aws-iam-server-id")
+}
+
+func TestVaultAuthenticatorJWT(t *testing.T) {
+	fv := &fakeVaultAuth{revoked: make(chan string, 1)}
+	srv := httptest.NewServer(fv)
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("eyJ.irsa\n"), 0o600))
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+
+	a := newVaultAuthenticator(context.Background(), VaultConfig{
+		Address: srv.URL,
+		Auth:    VaultAuthConfig{JWT: &JWTAuth{Role: "ci"}},
+	})
+	require.NoError(t, a.login(context.Background()))
+	assert.Equal(t, "hvs.login1", a.token)
+	assert.Equal(t, map[string]interface{}{"role": "ci", "jwt": "eyJ.irsa"}, fv.bodies[0])
+}
+
+func TestOIDCToken(t *testing.T) {
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"value": "eyJ.github." + r.URL.Query().Get("audience")})
+	}))
+	defer gh.Close()
+
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "")
+	_, err := oidcToken(context.Background(), &JWTAuth{})
+	assert.ErrorContains(t, err, "no OIDC token")
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", gh.URL+"/token?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+	token, err := oidcToken(context.Background(), &JWTAuth{Audience: "vault.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "eyJ.github.vault.example.com", token)
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "wrong")
+	_, err = oidcToken(context.Background(), &JWTAuth{})
+	assert.ErrorContains(t, err, "403 Forbidden")
+
+	// A configured token file wins over the environment
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("eyJ.file"), 0o600))
+	token, err = oidcToken(context.Background(), &JWTAuth{TokenFile: tokenFile})
+	require.NoError(t, err)
+	assert.Equal(t, "eyJ.file", token)
+}
+
+func TestVaultAuthConfigValidate(t *testing.T) {
+	assert.NoError(t, VaultAuthConfig{}.validate())
+	assert.NoError(t, VaultAuthConfig{Kubernetes: &KubernetesAuth{Role: "secretsync"}}.validate())
+	assert.ErrorContains(t, VaultAuthConfig{
+		AppRole: &AppRoleAuth{RoleID: "r", SecretID: "s"},
+		Token:   &TokenAuth{Token: "t"},
+	}.validate(), "set only one")
+	assert.ErrorContains(t, VaultAuthConfig{AppRole: &AppRoleAuth{RoleID: "r"}}.validate(), "role_id and secret_id are required")
+	assert.ErrorContains(t, VaultAuthConfig{Kubernetes: &KubernetesAuth{}}.validate(), "role is required")
+	assert.NoError(t, VaultAuthConfig{AWS: &VaultAWSAuth{}}.validate())
+	assert.ErrorContains(t, VaultAuthConfig{JWT: &JWTAuth{}}.validate(), "jwt: role is required")
+	assert.ErrorContains(t, VaultAuthConfig{AWS: &VaultAWSAuth{}, JWT: &JWTAuth{Role: "ci"}}.validate(), "set only one")
+}
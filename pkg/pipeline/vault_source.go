@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// regexPatternPrefix marks an include or exclude pattern as a regular
+// expression instead of a glob
+const regexPatternPrefix = "regex:"
+
+// pathPatterns matches secret paths within a Vault mount
+type pathPatterns []*regexp.Regexp
+
+// compilePathPatterns compiles globs ("*" matches within a path segment, "**"
+// across segments) and "regex:"-prefixed regular expressions. Globs match the
+// whole path; regular expressions match anywhere unless anchored.
+func compilePathPatterns(patterns []string) (pathPatterns, error) {
+	compiled := make(pathPatterns, 0, len(patterns))
+	for _, pattern := range patterns {
+		expr, isRegex := strings.CutPrefix(pattern, regexPatternPrefix)
+		if !isRegex {
+			expr = globRegexp(pattern)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// globRegexp converts a path glob to an anchored regular expression
+func globRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			// Zero or more whole segments, so "**/db" also matches "db"
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+func (ps pathPatterns) match(p string) bool {
+	for _, re := range ps {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// filtered reports whether the source reads only part of its mount
+func (s *VaultSource) filtered() bool {
+	return len(s.Paths) > 0 || len(s.Include) > 0 || len(s.Exclude) > 0
+}
+
+// singleSecret reports whether the source reads exactly one secret, the only
+// kind of import a version can be pinned on: KV v2 versions are per secret
+func (s *VaultSource) singleSecret() bool {
+	return len(s.Paths) == 1 && strings.Trim(s.Paths[0], "/") != "" && !strings.HasSuffix(s.Paths[0], "/")
+}
+
+func (s *VaultSource) validate() error {
+	if s.PageSize < 0 {
+		return fmt.Errorf("page_size must not be negative")
+	}
+	if _, err := compilePathPatterns(s.Include); err != nil {
+		return fmt.Errorf("include: %w", err)
+	}
+	if _, err := compilePathPatterns(s.Exclude); err != nil {
+		return fmt.Errorf("exclude: %w", err)
+	}
+	return nil
+}
+
+// listVaultSource returns the paths within the mount of the secrets a Vault
+// source reads, sorted. Each of Paths is a secret, or a directory listed
+// recursively when it ends in "/"; with no Paths the whole mount is listed.
+// Include and Exclude then filter the result.
+func listVaultSource(ctx context.Context, r vaultReader, src *VaultSource) ([]string, error) {
+	include, err := compilePathPatterns(src.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compilePathPatterns(src.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if seen[name] || (len(include) > 0 && !include.match(name)) || exclude.match(name) {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	var walk func(prefix string) error
+	walk = func(prefix string) error {
+		keys, err := r.ListSecrets(ctx, fmt.Sprintf("%s/%s", src.Mount, prefix))
+		if err != nil {
+			return fmt.Errorf("failed to list %s/%s: %w", src.Mount, prefix, err)
+		}
+		for _, k := range keys {
+			if strings.HasSuffix(k, "/") {
+				if err := walk(prefix + k); err != nil {
+					return err
+				}
+				continue
+			}
+			add(prefix + k)
+		}
+		return nil
+	}
+
+	paths := src.Paths
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	for _, path := range paths {
+		path = strings.TrimPrefix(path, "/")
+		if path != "" && !strings.HasSuffix(path, "/") {
+			add(path)
+			continue
+		}
+		if err := walk(path); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// selectVaultPaths lists the full paths (mount included) of the secrets a
+// filtered Vault source reads, for the Vault merge store's sync filter
+func (p *Pipeline) selectVaultPaths(ctx context.Context, src *VaultSource) ([]string, error) {
+	reader, err := p.vaultSourceReader(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	names, err := listVaultSource(ctx, reader, src)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s/%s", src.Mount, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePathPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{pattern: "db", match: []string{"db"}, noMatch: []string{"db2", "app/db"}},
+		{pattern: "app/*", match: []string{"app/db", "app/"}, noMatch: []string{"app/nested/db", "app"}},
+		{pattern: "app/**", match: []string{"app/db", "app/nested/db"}, noMatch: []string{"other/db"}},
+		{pattern: "**/db", match: []string{"db", "app/db", "app/nested/db"}, noMatch: []string{"app/db2"}},
+		{pattern: "svc-?", match: []string{"svc-a"}, noMatch: []string{"svc-ab", "svc-/"}},
+		{pattern: "a.b", match: []string{"a.b"}, noMatch: []string{"axb"}},
+		{pattern: "regex:^team-[0-9]+/", match: []string{"team-42/db"}, noMatch: []string{"team-x/db", "old/team-42/db"}},
+		{pattern: "regex:tmp", match: []string{"app/tmp/db", "tmp"}, noMatch: []string{"app/db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			ps, err := compilePathPatterns([]string{tt.pattern})
+			require.NoError(t, err)
+			for _, p := range tt.match {
+				assert.True(t, ps.match(p), "%s should match %s", tt.pattern, p)
+			}
+			for _, p := range tt.noMatch {
+				assert.False(t, ps.match(p), "%s should not match %s", tt.pattern, p)
+			}
+		})
+	}
+
+	_, err := compilePathPatterns([]string{"regex:("})
+	assert.ErrorContains(t, err, `invalid pattern "regex:("`)
+}
+
+func TestListVaultSourceFilters(t *testing.T) {
+	vault := fakeVault{
+		"analytics/team-1/db":        {"password": "a"},
+		"analytics/team-1/api":       {"token": "b"},
+		"analytics/team-2/db":        {"password": "c"},
+		"analytics/team-2/tmp/cache": {"url": "d"},
+		"analytics/shared/db":        {"password": "e"},
+	}
+	ctx := context.Background()
+
+	names, err := listVaultSource(ctx, vault, &VaultSource{Mount: "analytics", Include: []string{"team-*/**"}, Exclude: []string{"regex:/tmp/"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-1/api", "team-1/db", "team-2/db"}, names)
+
+	// Filters also apply to explicit paths, and duplicates are listed once
+	names, err = listVaultSource(ctx, vault, &VaultSource{
+		Mount:   "analytics",
+		Paths:   []string{"team-2/", "shared/db", "team-2/db"},
+		Exclude: []string{"**/cache"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shared/db", "team-2/db"}, names)
+
+	p := &Pipeline{openVaultSource: func(context.Context, *VaultSource) (vaultReader, error) { return vault, nil }}
+	paths, err := p.selectVaultPaths(ctx, &VaultSource{Mount: "analytics", Include: []string{"**/db"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"analytics/shared/db", "analytics/team-1/db", "analytics/team-2/db"}, paths)
+}
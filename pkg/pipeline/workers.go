@@ -0,0 +1,181 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxWorkers bounds the target operations running at once when
+// pipeline.max_workers is not set
+const DefaultMaxWorkers = 64
+
+// LevelLimit caps the concurrency of one dependency level of the merge phase
+type LevelLimit struct {
+	Level    int `mapstructure:"level" yaml:"level"`
+	Parallel int `mapstructure:"parallel" yaml:"parallel"`
+}
+
+// validateLevelLimits checks merge.levels
+func validateLevelLimits(limits []LevelLimit) error {
+	seen := make(map[int]bool, len(limits))
+	for _, ll := range limits {
+		if ll.Level < 0 {
+			return fmt.Errorf("level must not be negative, got %d", ll.Level)
+		}
+		if ll.Parallel < 1 {
+			return fmt.Errorf("level %d: parallel must be at least 1", ll.Level)
+		}
+		if seen[ll.Level] {
+			return fmt.Errorf("level %d is listed twice", ll.Level)
+		}
+		seen[ll.Level] = true
+	}
+	return nil
+}
+
+// levelParallelism returns the concurrency of a merge level: parallel, capped
+// by the level's limit
+func (s MergeSettings) levelParallelism(level, parallel int) int {
+	for _, ll := range s.Levels {
+		if ll.Level == level && ll.Parallel < parallel {
+			return ll.Parallel
+		}
+	}
+	return parallel
+}
+
+// workerPool bounds the goroutines running target operations across all
+// phases and levels of a pipeline
+type workerPool struct {
+	slots chan struct{}
+}
+
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		size = DefaultMaxWorkers
+	}
+	return &workerPool{slots: make(chan struct{}, size)}
+}
+
+func (w *workerPool) size() int {
+	return cap(w.slots)
+}
+
+// acquire waits for a free worker
+func (w *workerPool) acquire(ctx context.Context) error {
+	select {
+	case w.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tryAcquire takes a free worker if there is one
+func (w *workerPool) tryAcquire() bool {
+	select {
+	case w.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *workerPool) release() {
+	<-w.slots
+}
+
+// workers returns the pipeline's worker pool, sized by pipeline.max_workers
+func (p *Pipeline) workers() *workerPool {
+	p.poolOnce.Do(func() {
+		p.pool = newWorkerPool(p.config.Pipeline.MaxWorkers)
+	})
+	return p.pool
+}
+
+// executeParallel runs a function for each target with limited concurrency.
+// At most maxParallel goroutines are started however many targets there are,
+// and each target also waits for a worker from the pipeline's pool.
+func (p *Pipeline) executeParallel(ctx context.Context, targets []string, maxParallel int, fn func(string) Result) []Result {
+	if maxParallel <= 0 {
+		maxParallel = 1
+	}
+	pool := p.workers()
+
+	results := make([]Result, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(maxParallel, len(targets), pool.size()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				t := targets[idx]
+				if err := pool.acquire(ctx); err != nil {
+					results[idx] = Result{Target: t, Success: false, Error: err}
+					continue
+				}
+				started := time.Now()
+				results[idx] = fn(t)
+				results[idx].Started = started
+				pool.release()
+			}
+		}()
+	}
+
+	for i, target := range targets {
+		select {
+		case <-ctx.Done():
+			results[i] = Result{
+				Target:  target,
+				Success: false,
+				Error:   ctx.Err(),
+			}
+			continue
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+	return results
+}
+
+// runBounded calls fn for 0..n-1 with up to parallel calls at once. The
+// caller's goroutine runs calls itself; the others run on extra workers
+// taken from the pool when one is free, so nested work never waits for a
+// worker held by its own caller.
+func (p *Pipeline) runBounded(parallel, n int, fn func(int)) {
+	if parallel <= 1 || n <= 1 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+	pool := p.workers()
+	extra := make(chan struct{}, parallel-1)
+	var wg sync.WaitGroup
+	for i := range n {
+		select {
+		case extra <- struct{}{}:
+			if pool.tryAcquire() {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() {
+						pool.release()
+						<-extra
+					}()
+					fn(i)
+				}()
+				continue
+			}
+			<-extra
+		default:
+		}
+		fn(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyProbe records the most calls in progress at once
+type concurrencyProbe struct {
+	current, peak atomic.Int32
+}
+
+func (c *concurrencyProbe) run() {
+	n := c.current.Add(1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	c.current.Add(-1)
+}
+
+func workerTestPipeline(maxWorkers int) *Pipeline {
+	return &Pipeline{config: &Config{Pipeline: PipelineSettings{MaxWorkers: maxWorkers}}}
+}
+
+func TestExecuteParallel(t *testing.T) {
+	targets := make([]string, 40)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("sandbox-%02d", i)
+	}
+
+	var probe concurrencyProbe
+	results := workerTestPipeline(0).executeParallel(context.Background(), targets, 4, func(target string) Result {
+		probe.run()
+		return Result{Target: target, Success: true}
+	})
+	require.Len(t, results, len(targets))
+	for i, r := range results {
+		assert.Equal(t, targets[i], r.Target)
+		assert.False(t, r.Started.IsZero())
+	}
+	assert.Equal(t, int32(4), probe.peak.Load())
+
+	// The shared pool caps phases asking for more
+	probe = concurrencyProbe{}
+	workerTestPipeline(2).executeParallel(context.Background(), targets, 16, func(target string) Result {
+		probe.run()
+		return Result{Target: target, Success: true}
+	})
+	assert.Equal(t, int32(2), probe.peak.Load())
+
+	// Targets not started before cancellation fail with the context's error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = workerTestPipeline(0).executeParallel(ctx, targets, 4, func(target string) Result {
+		return Result{Target: target, Success: true}
+	})
+	require.Len(t, results, len(targets))
+	var cancelled int
+	for _, r := range results {
+		if r.Error != nil {
+			assert.ErrorIs(t, r.Error, context.Canceled)
+			cancelled++
+		}
+	}
+	assert.Positive(t, cancelled)
+}
+
+func TestRunBounded(t *testing.T) {
+	var probe concurrencyProbe
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	workerTestPipeline(0).runBounded(3, 12, func(i int) {
+		probe.run()
+		mu.Lock()
+		seen[i] = true
+		mu.Unlock()
+	})
+	assert.Len(t, seen, 12)
+	assert.Equal(t, int32(3), probe.peak.Load())
+
+	// With every worker taken by the caller's phase, nested work runs in the
+	// caller's goroutine instead of waiting
+	p := workerTestPipeline(1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.executeParallel(context.Background(), []string{"Stg"}, 1, func(target string) Result {
+			p.runBounded(4, 3, func(int) {})
+			return Result{Target: target, Success: true}
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runBounded waited for a worker held by its caller")
+	}
+}
+
+func TestLevelParallelism(t *testing.T) {
+	merge := MergeSettings{Parallel: 8, Levels: []LevelLimit{{Level: 0, Parallel: 2}, {Level: 2, Parallel: 16}}}
+	assert.Equal(t, 2, merge.levelParallelism(0, 8))
+	assert.Equal(t, 8, merge.levelParallelism(1, 8))
+	// Limits only lower the concurrency
+	assert.Equal(t, 8, merge.levelParallelism(2, 8))
+
+	assert.NoError(t, validateLevelLimits(merge.Levels))
+	assert.ErrorContains(t, validateLevelLimits([]LevelLimit{{Level: 1, Parallel: 0}}), "parallel must be at least 1")
+	assert.ErrorContains(t, validateLevelLimits([]LevelLimit{{Level: -1, Parallel: 1}}), "must not be negative")
+	assert.ErrorContains(t, validateLevelLimits([]LevelLimit{{Level: 1, Parallel: 1}, {Level: 1, Parallel: 2}}), "listed twice")
+}
@@ -0,0 +1,130 @@
+// Package redact keeps secret values out of logs and diffs: a logrus
+// formatter that masks the values of sensitive fields, and masked previews
+// that show only the ends of a value.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Redacted replaces the value of a sensitive field in logs
+const Redacted = "[REDACTED]"
+
+// DefaultFields match the names of fields whose values are secret. They are
+// matched case-insensitively against whole field names, so "secret" (a
+// secret's name in most log lines) and "key" are not redacted but
+// "client_secret" and "api_key" are.
+var DefaultFields = []string{
+	`.*(password|passwd|passphrase)`,
+	`.*token`,
+	`.*(api|access|secret|private|signing|encryption)[_-]?key`,
+	`.*client[_-]?secret`,
+	`secret[_-]?(value|string|data)`,
+	`.*credentials?`,
+	`authorization`,
+	`cookie`,
+}
+
+// inlineField finds name=value, name: value and "name":"value" pairs in log
+// messages and string values, such as a URL query or a quoted API error
+var inlineField = regexp.MustCompile(`([A-Za-z0-9_.-]+)("?\s*[=:]\s*"?)([^\s"&,;]+)`)
+
+// Fields compiles field name patterns: DefaultFields followed by extra
+func Fields(extra []string) ([]*regexp.Regexp, error) {
+	exprs := append(append([]string{}, DefaultFields...), extra...)
+	fields := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(`(?i)^(?:` + expr + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field pattern %q: %w", expr, err)
+		}
+		fields = append(fields, re)
+	}
+	return fields, nil
+}
+
+// RedactingFormatter masks the values of sensitive fields before handing
+// entries to Formatter: fields whose names match Fields, and name=value pairs
+// with matching names inside the message and string or error fields
+type RedactingFormatter struct {
+	Formatter log.Formatter
+	Fields    []*regexp.Regexp
+}
+
+// NewRedactingFormatter wraps a formatter, redacting DefaultFields and the
+// extra field name patterns
+func NewRedactingFormatter(formatter log.Formatter, extra []string) (*RedactingFormatter, error) {
+	fields, err := Fields(extra)
+	if err != nil {
+		return nil, err
+	}
+	return &RedactingFormatter{Formatter: formatter, Fields: fields}, nil
+}
+
+// Format implements logrus.Formatter
+func (f *RedactingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	data := make(log.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = f.value(k, v)
+	}
+	redacted := *entry
+	redacted.Data = data
+	redacted.Message = f.String(entry.Message)
+	return f.Formatter.Format(&redacted)
+}
+
+// value redacts one field's value
+func (f *RedactingFormatter) value(name string, v interface{}) interface{} {
+	if f.Sensitive(name) {
+		return Redacted
+	}
+	switch val := v.(type) {
+	case string:
+		return f.String(val)
+	case error:
+		if s := val.Error(); f.String(s) != s {
+			return f.String(s)
+		}
+	}
+	return v
+}
+
+// Sensitive reports whether a field's value is secret
+func (f *RedactingFormatter) Sensitive(name string) bool {
+	for _, re := range f.Fields {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// String redacts the values of sensitive name=value pairs in s
+func (f *RedactingFormatter) String(s string) string {
+	if !strings.ContainsAny(s, "=:") {
+		return s
+	}
+	return inlineField.ReplaceAllStringFunc(s, func(pair string) string {
+		m := inlineField.FindStringSubmatch(pair)
+		if !f.Sensitive(m[1]) {
+			return pair
+		}
+		return m[1] + m[2] + Redacted
+	})
+}
+
+// Preview masks a value down to its first and last two characters, so
+// operators can tell values apart without seeing them. Values shorter than
+// 8 characters are masked entirely, since their ends would give most of them
+// away.
+func Preview(value string) string {
+	r := []rune(value)
+	if len(r) < 8 {
+		return "****"
+	}
+	return string(r[:2]) + "****" + string(r[len(r)-2:])
+}
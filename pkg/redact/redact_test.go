@@ -0,0 +1,68 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestRedactingFormatter(t *testing.T) {
+	f, err := NewRedactingFormatter(&log.JSONFormatter{}, []string{"x-.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(f)
+
+	fields := log.Fields{
+		"secret":        "prod/db",
+		"key":           "password",
+		"password":      "hunter2-hunter2",
+		"vault_token":   "hvs.abcdef",
+		"client_secret": "s3cr3t-value",
+		"X-Api-Sig":     "sig-value",
+		"url":           "https://example.com/cb?code=1&access_token=tok-value",
+	}
+	entry := logger.WithFields(fields)
+	entry.WithError(errors.New(`API error: {"api_key":"key-value"}`)).Info("Login with password=pw-value as app")
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "hvs.abcdef", "s3cr3t-value", "sig-value", "tok-value", "key-value", "pw-value"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q redacted, got %s", secret, out)
+		}
+	}
+	for _, kept := range []string{`"secret":"prod/db"`, `"key":"password"`, "code=1", "as app"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %q kept, got %s", kept, out)
+		}
+	}
+	if entry.Data["password"] != "hunter2-hunter2" {
+		t.Error("expected the entry's own fields untouched")
+	}
+}
+
+func TestFieldsInvalid(t *testing.T) {
+	if _, err := Fields([]string{"("}); err == nil {
+		t.Error("expected an invalid pattern to fail")
+	}
+}
+
+func TestPreview(t *testing.T) {
+	for value, want := range map[string]string{
+		"":              "****",
+		"short":         "****",
+		"8chars!!":      "8c****!!",
+		"sk_live_12345": "sk****45",
+		"пароль-секрет": "па****ет",
+	} {
+		if got := Preview(value); got != want {
+			t.Errorf("Preview(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
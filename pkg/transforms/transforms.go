@@ -0,0 +1,146 @@
+// Package transforms reshapes secret keys and names for the pipeline. The
+// functions are pure, so the merge phase, the sync engine and tests all apply
+// the same rules.
+package transforms
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Key cases
+const (
+	CaseUpper = "upper"
+	CaseLower = "lower"
+)
+
+// KeyRules rename and reshape the keys of a secret. Rules apply in order:
+// Rename (matching the original key), then StripPrefix and StripSuffix, then
+// Case.
+type KeyRules struct {
+	Rename      map[string]string
+	StripPrefix string
+	StripSuffix string
+	Case        string
+}
+
+// IsZero reports whether the rules leave every key unchanged
+func (r KeyRules) IsZero() bool {
+	return len(r.Rename) == 0 && r.StripPrefix == "" && r.StripSuffix == "" && r.Case == ""
+}
+
+// Validate checks the case and that no key is renamed to an empty name
+func (r KeyRules) Validate() error {
+	switch r.Case {
+	case "", CaseUpper, CaseLower:
+	default:
+		return fmt.Errorf("case must be %s or %s, got %q", CaseUpper, CaseLower, r.Case)
+	}
+	for from, to := range r.Rename {
+		if from == "" || to == "" {
+			return fmt.Errorf("rename %q to %q: key names must not be empty", from, to)
+		}
+	}
+	return nil
+}
+
+// Key returns the transformed name of a key. A key that would be stripped to
+// nothing keeps its name.
+func (r KeyRules) Key(key string) string {
+	if to, ok := r.Rename[key]; ok {
+		key = to
+	}
+	if k := strings.TrimPrefix(key, r.StripPrefix); k != "" {
+		key = k
+	}
+	if k := strings.TrimSuffix(key, r.StripSuffix); k != "" {
+		key = k
+	}
+	switch r.Case {
+	case CaseUpper:
+		key = strings.ToUpper(key)
+	case CaseLower:
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// Apply returns a copy of data with every top-level key transformed. Keys
+// that transform to the same name are an error rather than one silently
+// replacing the other.
+func (r KeyRules) Apply(data map[string]interface{}) (map[string]interface{}, error) {
+	if r.IsZero() {
+		return data, nil
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]interface{}, len(data))
+	from := make(map[string]string, len(data))
+	for _, k := range keys {
+		nk := r.Key(k)
+		if prev, ok := from[nk]; ok {
+			return nil, fmt.Errorf("keys %q and %q both transform to %q", prev, k, nk)
+		}
+		from[nk] = k
+		out[nk] = data[k]
+	}
+	return out, nil
+}
+
+// NameData is the data a destination name template is rendered with
+type NameData struct {
+	// Name is the secret's name in the merge store
+	Name string
+	// SecretName is the same as Name, for templates that read better with it
+	SecretName string
+	// Target is the pipeline target being synced
+	Target string
+	// Prefix is the target's secret prefix
+	Prefix string
+}
+
+// RenderName renders a destination name template, e.g.
+// "/{{.Target}}/app/{{.Name}}"
+func RenderName(tmpl string, data NameData) (string, error) {
+	t, err := template.New("name").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid name template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid name template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// namePlaceholder stands in for the secret name while a pattern is rendered
+const namePlaceholder = "\x00name\x00"
+
+// NamePattern renders a name template for a target with the secret name left
+// as placeholder, for sync engines that substitute each secret's name
+// themselves (e.g. "$1"). The template must use {{.Name}} exactly once and
+// unmodified, so every secret still gets a distinct name.
+func NamePattern(tmpl, target, placeholder string) (string, error) {
+	return NameData{Target: target}.Pattern(tmpl, placeholder)
+}
+
+// Pattern renders a name template like NamePattern, with the rest of the data
+// (target and prefix) taken from d. {{.SecretName}} may stand in for {{.Name}}.
+func (d NameData) Pattern(tmpl, placeholder string) (string, error) {
+	d.Name, d.SecretName = namePlaceholder, namePlaceholder
+	name, err := RenderName(tmpl, d)
+	if err != nil {
+		return "", err
+	}
+	if strings.Count(name, namePlaceholder) != 1 {
+		return "", fmt.Errorf("name template %q must use {{.Name}} exactly once, unmodified", tmpl)
+	}
+	return strings.Replace(name, namePlaceholder, placeholder, 1), nil
+}
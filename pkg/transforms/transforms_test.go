@@ -0,0 +1,73 @@
+package transforms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules KeyRules
+		in    string
+		want  string
+	}{
+		{name: "zero", in: "db_password", want: "db_password"},
+		{name: "rename", rules: KeyRules{Rename: map[string]string{"pw": "password"}}, in: "pw", want: "password"},
+		{name: "strip prefix", rules: KeyRules{StripPrefix: "APP_"}, in: "APP_TOKEN", want: "TOKEN"},
+		{name: "strip suffix", rules: KeyRules{StripSuffix: "_PROD"}, in: "TOKEN_PROD", want: "TOKEN"},
+		{name: "strip to empty keeps key", rules: KeyRules{StripPrefix: "APP_"}, in: "APP_", want: "APP_"},
+		{name: "upper", rules: KeyRules{Case: CaseUpper}, in: "db_password", want: "DB_PASSWORD"},
+		{name: "lower", rules: KeyRules{Case: CaseLower}, in: "DB_PASSWORD", want: "db_password"},
+		{
+			name:  "rename then strip then case",
+			rules: KeyRules{Rename: map[string]string{"legacy": "APP_new_key"}, StripPrefix: "APP_", Case: CaseUpper},
+			in:    "legacy",
+			want:  "NEW_KEY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rules.Key(tt.in))
+		})
+	}
+
+	assert.ErrorContains(t, KeyRules{Case: "title"}.Validate(), `got "title"`)
+	assert.Error(t, KeyRules{Rename: map[string]string{"a": ""}}.Validate())
+	assert.NoError(t, KeyRules{Case: CaseLower}.Validate())
+}
+
+func TestKeyRulesApply(t *testing.T) {
+	rules := KeyRules{StripPrefix: "APP_", Case: CaseLower}
+	out, err := rules.Apply(map[string]interface{}{"APP_USER": "u", "APP_PASS": "p", "region": "us-east-1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "u", "pass": "p", "region": "us-east-1"}, out)
+
+	_, err = rules.Apply(map[string]interface{}{"APP_USER": "u", "user": "v"})
+	assert.ErrorContains(t, err, `keys "APP_USER" and "user" both transform to "user"`)
+}
+
+func TestNames(t *testing.T) {
+	name, err := RenderName("/{{.Target}}/app/{{.Name}}", NameData{Name: "db", Target: "Prod"})
+	require.NoError(t, err)
+	assert.Equal(t, "/Prod/app/db", name)
+
+	pattern, err := NamePattern("{{.Target}}/{{.Name}}", "Prod", "$1")
+	require.NoError(t, err)
+	assert.Equal(t, "Prod/$1", pattern)
+
+	_, err = NamePattern("{{.Target}}/static", "Prod", "$1")
+	assert.ErrorContains(t, err, "exactly once")
+	_, err = NamePattern("{{.Name}}-{{.Name}}", "Prod", "$1")
+	assert.ErrorContains(t, err, "exactly once")
+	_, err = NamePattern("{{.Nme}}", "Prod", "$1")
+	assert.ErrorContains(t, err, "invalid name template")
+
+	pattern, err = NameData{Target: "Prod", Prefix: "apps"}.Pattern("{{.Prefix}}/{{.Target}}/{{.SecretName}}", "$1")
+	require.NoError(t, err)
+	assert.Equal(t, "apps/Prod/$1", pattern)
+	_, err = NameData{Target: "Prod"}.Pattern("{{.Name}}/{{.SecretName}}", "$1")
+	assert.ErrorContains(t, err, "exactly once")
+}
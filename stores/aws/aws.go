@@ -3,23 +3,34 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
-	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/jbcom/secretsync/internal/awsrate"
 	"github.com/jbcom/secretsync/pkg/driver"
 	"github.com/jbcom/secretsync/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	stageCurrent  = "AWSCURRENT"
+	stagePrevious = "AWSPREVIOUS"
+)
+
 type AwsClient struct {
 	Name           string            `yaml:"name,omitempty" json:"name,omitempty"`
 	RoleArn        string            `yaml:"roleArn,omitempty" json:"roleArn,omitempty"`
+	RoleChain      []RoleChainHop    `yaml:"roleChain,omitempty" json:"roleChain,omitempty"` // assumed, in order, before RoleArn
 	Region         string            `yaml:"region,omitempty" json:"region,omitempty"`
 	EncryptionKey  string            `yaml:"encryptionKey,omitempty" json:"encryptionKey,omitempty"`
 	ReplicaRegions []string          `yaml:"replicaRegions,omitempty" json:"replicaRegions,omitempty"`
@@ -33,6 +44,25 @@ type AwsClient struct {
 	// Uses JSON-aware comparison for proper equality checking
 	SkipUnchanged bool `yaml:"skipUnchanged,omitempty" json:"skipUnchanged,omitempty"`
 
+	// RotationOverlap is how long the value replaced by an update stays
+	// readable as AWSPREVIOUS (e.g. "24h"). Once the current version is
+	// older than this the AWSPREVIOUS label is removed. Unset leaves the
+	// label to Secrets Manager.
+	RotationOverlap string `yaml:"rotationOverlap,omitempty" json:"rotationOverlap,omitempty"`
+
+	// ExternalID, SessionName, SessionDuration (e.g. "1h") and SessionPolicy
+	// (an IAM policy document that narrows the session's permissions) apply
+	// to the RoleArn session
+	ExternalID      string `yaml:"externalId,omitempty" json:"externalId,omitempty"`
+	SessionName     string `yaml:"sessionName,omitempty" json:"sessionName,omitempty"`
+	SessionDuration string `yaml:"sessionDuration,omitempty" json:"sessionDuration,omitempty"`
+	SessionPolicy   string `yaml:"sessionPolicy,omitempty" json:"sessionPolicy,omitempty"`
+
+	// SessionTags and SourceIdentity are set on the RoleArn session and the
+	// sessions of its RoleChain, so CloudTrail attributes the writes
+	SessionTags    map[string]string `yaml:"sessionTags,omitempty" json:"sessionTags,omitempty"`
+	SourceIdentity string            `yaml:"sourceIdentity,omitempty" json:"sourceIdentity,omitempty"`
+
 	client *secretsmanager.Client `yaml:"-" json:"-"`
 
 	accountSecretArns map[string]string `yaml:"-" json:"-"`
@@ -46,6 +76,26 @@ func (in *AwsClient) DeepCopyInto(out *AwsClient) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RoleChain != nil {
+		in, out := &in.RoleChain, &out.RoleChain
+		*out = make([]RoleChainHop, len(*in))
+		for i := range *in {
+			(*out)[i] = (*in)[i]
+			if (*in)[i].SessionTags != nil {
+				(*out)[i].SessionTags = make(map[string]string, len((*in)[i].SessionTags))
+				for key, val := range (*in)[i].SessionTags {
+					(*out)[i].SessionTags[key] = val
+				}
+			}
+		}
+	}
+	if in.SessionTags != nil {
+		in, out := &in.SessionTags, &out.SessionTags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Tags != nil {
 		in, out := &in.Tags, &out.Tags
 		*out = make(map[string]string, len(*in))
@@ -80,9 +130,39 @@ func (c *AwsClient) Validate() error {
 	if c.Name == "" {
 		return driver.ErrPathRequired
 	}
+	if _, err := c.rotationOverlap(); err != nil {
+		return err
+	}
+	if _, err := c.sessionDuration(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// sessionDuration parses SessionDuration; zero leaves the STS default
+func (c *AwsClient) sessionDuration() (time.Duration, error) {
+	if c.SessionDuration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.SessionDuration)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid sessionDuration %q", c.SessionDuration)
+	}
+	return d, nil
+}
+
+// rotationOverlap parses RotationOverlap; zero leaves AWSPREVIOUS alone
+func (c *AwsClient) rotationOverlap() (time.Duration, error) {
+	if c.RotationOverlap == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.RotationOverlap)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid rotationOverlap %q", c.RotationOverlap)
+	}
+	return d, nil
+}
+
 func NewClient(cfg *AwsClient) (*AwsClient, error) {
 	l := log.WithFields(log.Fields{
 		"action": "NewClient",
@@ -113,20 +193,46 @@ func (c *AwsClient) CreateClient(ctx context.Context) error {
 		"action": "CreateClient",
 	})
 	l.Trace("start")
-	awscfg, err := config.LoadDefaultConfig(ctx)
+	awscfg, err := config.LoadDefaultConfig(ctx, config.WithAPIOptions([]func(*middleware.Stack) error{awsrate.AddMiddleware}))
 	if err != nil {
 		l.Debugf("error: %v", err)
 		return err
 	}
-	var provider aws.CredentialsProvider
 	if c.RoleArn != "" {
-		stsclient := sts.NewFromConfig(awscfg)
-		provider = stscreds.NewAssumeRoleProvider(stsclient, c.RoleArn)
-		awscfg.Credentials = provider
+		duration, err := c.sessionDuration()
+		if err != nil {
+			return err
+		}
+		awscfg.Credentials = AssumeRoleChain(awscfg, c.RoleChain, c.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+			if c.ExternalID != "" {
+				o.ExternalID = aws.String(c.ExternalID)
+			}
+			if c.SessionName != "" {
+				o.RoleSessionName = c.SessionName
+			}
+			if duration > 0 {
+				o.Duration = duration
+			}
+			if c.SessionPolicy != "" {
+				o.Policy = aws.String(c.SessionPolicy)
+			}
+			if c.SourceIdentity != "" {
+				o.SourceIdentity = aws.String(c.SourceIdentity)
+			}
+			keys := make([]string, 0, len(c.SessionTags))
+			for k := range c.SessionTags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				o.Tags = append(o.Tags, ststypes.Tag{Key: aws.String(k), Value: aws.String(c.SessionTags[k])})
+			}
+		})
 	}
 	svc := secretsmanager.New(secretsmanager.Options{
 		Region:      c.Region,
 		Credentials: awscfg.Credentials,
+		APIOptions:  []func(*middleware.Stack) error{awsrate.AddMiddleware},
 	})
 	c.client = svc
 	l.Trace("end")
@@ -211,15 +317,8 @@ func (c *AwsClient) createSecret(ctx context.Context, name string, secret []byte
 		}
 		csi.AddReplicaRegions = rep
 	}
-	if c.Tags != nil {
-		var tags []types.Tag
-		for k, v := range c.Tags {
-			tags = append(tags, types.Tag{
-				Key:   aws.String(k),
-				Value: aws.String(v),
-			})
-		}
-		csi.Tags = tags
+	if len(c.Tags) > 0 {
+		csi.Tags = secretTags(c.Tags)
 	}
 	_, err := c.client.CreateSecret(ctx, csi)
 	if err != nil {
@@ -279,6 +378,7 @@ func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pat
 	// If there is an existing secret, check if update is needed
 	if arn, ok := g.accountSecretArns[path]; ok {
 		// Idempotency: skip if value unchanged
+		unchanged := false
 		if g.SkipUnchanged {
 			existingValue, err := g.getSecretValue(ctx, arn)
 			if err != nil {
@@ -289,13 +389,23 @@ func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pat
 					l.WithError(err).Debug("Error comparing secrets")
 				} else if equal {
 					l.Debug("Secret unchanged, skipping update")
-					return nil, nil
+					unchanged = true
 				}
 			}
 		}
 
-		err := g.updateSecret(ctx, path, secrets)
-		if err != nil {
+		if !unchanged {
+			err := g.updateSecret(ctx, path, secrets)
+			if err != nil {
+				l.Errorf("error: %v", err)
+				return nil, err
+			}
+		}
+		if err := g.expirePrevious(ctx, arn); err != nil {
+			l.Errorf("error: %v", err)
+			return nil, err
+		}
+		if err := g.reconcileTags(ctx, arn); err != nil {
 			l.Errorf("error: %v", err)
 			return nil, err
 		}
@@ -309,6 +419,80 @@ func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pat
 	return nil, nil
 }
 
+// expirePrevious removes the AWSPREVIOUS label from a secret once its current
+// version has been current for longer than the rotation overlap, so the
+// replaced value stops being readable when consumers no longer need it
+func (g *AwsClient) expirePrevious(ctx context.Context, arn string) error {
+	overlap, err := g.rotationOverlap()
+	if err != nil || overlap == 0 {
+		return err
+	}
+	var versions []types.SecretVersionsListEntry
+	var nextToken *string
+	for {
+		resp, err := g.client.ListSecretVersionIds(ctx, &secretsmanager.ListSecretVersionIdsInput{
+			SecretId:  &arn,
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list secret versions: %w", err)
+		}
+		versions = append(versions, resp.Versions...)
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+	id := expiredPreviousVersion(versions, overlap, time.Now())
+	if id == "" {
+		return nil
+	}
+	_, err = g.client.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            &arn,
+		VersionStage:        aws.String(stagePrevious),
+		RemoveFromVersionId: aws.String(id),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to expire %s: %w", stagePrevious, err)
+	}
+	return nil
+}
+
+// expiredPreviousVersion returns the version labelled AWSPREVIOUS when the
+// AWSCURRENT version was created more than overlap before now, or ""
+func expiredPreviousVersion(versions []types.SecretVersionsListEntry, overlap time.Duration, now time.Time) string {
+	var current *time.Time
+	previous := ""
+	for _, v := range versions {
+		for _, stage := range v.VersionStages {
+			switch stage {
+			case stageCurrent:
+				current = v.CreatedDate
+			case stagePrevious:
+				previous = aws.ToString(v.VersionId)
+			}
+		}
+	}
+	if previous == "" || current == nil || now.Sub(*current) <= overlap {
+		return ""
+	}
+	return previous
+}
+
+// VerifySecret reads a written secret back and reports whether it holds
+// the written value, comparing JSON secrets by content
+func (g *AwsClient) VerifySecret(ctx context.Context, path string, written []byte) (bool, error) {
+	id := path
+	if arn, ok := g.accountSecretArns[path]; ok {
+		id = arn
+	}
+	current, err := g.getSecretValue(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return utils.CompareSecretsJSON(current, written)
+}
+
 // getAlternatePath returns the alternate path format (/foo vs foo)
 // Returns empty string if path is empty
 func (g *AwsClient) getAlternatePath(path string) string {
@@ -448,6 +632,27 @@ func (c *AwsClient) SetDefaults(defaults any) error {
 	if c.RoleArn == "" && dc.RoleArn != "" {
 		c.RoleArn = dc.RoleArn
 	}
+	if len(c.RoleChain) == 0 && len(dc.RoleChain) > 0 {
+		c.RoleChain = dc.RoleChain
+	}
+	if c.ExternalID == "" && dc.ExternalID != "" {
+		c.ExternalID = dc.ExternalID
+	}
+	if c.SessionName == "" && dc.SessionName != "" {
+		c.SessionName = dc.SessionName
+	}
+	if c.SessionDuration == "" && dc.SessionDuration != "" {
+		c.SessionDuration = dc.SessionDuration
+	}
+	if c.SessionPolicy == "" && dc.SessionPolicy != "" {
+		c.SessionPolicy = dc.SessionPolicy
+	}
+	if len(c.SessionTags) == 0 && len(dc.SessionTags) > 0 {
+		c.SessionTags = dc.SessionTags
+	}
+	if c.SourceIdentity == "" && dc.SourceIdentity != "" {
+		c.SourceIdentity = dc.SourceIdentity
+	}
 	if c.EncryptionKey == "" && dc.EncryptionKey != "" {
 		c.EncryptionKey = dc.EncryptionKey
 	}
@@ -462,6 +667,12 @@ func (c *AwsClient) SetDefaults(defaults any) error {
 	if dc.SkipUnchanged {
 		c.SkipUnchanged = dc.SkipUnchanged
 	}
+	if c.RotationOverlap == "" && dc.RotationOverlap != "" {
+		c.RotationOverlap = dc.RotationOverlap
+	}
+	if len(c.Tags) == 0 && len(dc.Tags) > 0 {
+		c.Tags = dc.Tags
+	}
 	return nil
 }
 
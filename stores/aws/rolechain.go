@@ -0,0 +1,64 @@
+package aws
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// RoleChainHop is a role assumed on the way to RoleArn, such as a role in a
+// hub account that the target account's role trusts
+type RoleChainHop struct {
+	RoleArn    string `yaml:"roleArn" json:"roleArn"`
+	ExternalID string `yaml:"externalId,omitempty" json:"externalId,omitempty"`
+	// SessionTags are passed as transitive session tags, so they stay on the
+	// sessions of the roles assumed after this one
+	SessionTags map[string]string `yaml:"sessionTags,omitempty" json:"sessionTags,omitempty"`
+}
+
+// AssumeRoleChain returns credentials for roleArn, reached by assuming each
+// hop of chain in turn starting from cfg's credentials. Each hop is assumed
+// with the previous hop's credentials. optFns apply to roleArn; the hops
+// share its session name, source identity and session tags so CloudTrail
+// ties the sessions together.
+func AssumeRoleChain(cfg aws.Config, chain []RoleChainHop, roleArn string, optFns ...func(*stscreds.AssumeRoleOptions)) aws.CredentialsProvider {
+	var target stscreds.AssumeRoleOptions
+	for _, fn := range optFns {
+		fn(&target)
+	}
+
+	creds := cfg.Credentials
+	for _, hop := range chain {
+		hop := hop
+		creds = assumeRole(cfg, creds, hop.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+			if target.RoleSessionName != "" {
+				o.RoleSessionName = target.RoleSessionName
+			}
+			o.SourceIdentity = target.SourceIdentity
+			o.Tags = append(o.Tags, target.Tags...)
+			if hop.ExternalID != "" {
+				o.ExternalID = aws.String(hop.ExternalID)
+			}
+			keys := make([]string, 0, len(hop.SessionTags))
+			for k := range hop.SessionTags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				o.Tags = append(o.Tags, ststypes.Tag{Key: aws.String(k), Value: aws.String(hop.SessionTags[k])})
+				o.TransitiveTagKeys = append(o.TransitiveTagKeys, k)
+			}
+		})
+	}
+	return assumeRole(cfg, creds, roleArn, optFns...)
+}
+
+// assumeRole returns cached credentials for roleArn, assumed with creds
+func assumeRole(cfg aws.Config, creds aws.CredentialsProvider, roleArn string, optFns ...func(*stscreds.AssumeRoleOptions)) aws.CredentialsProvider {
+	stsCfg := cfg.Copy()
+	stsCfg.Credentials = creds
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(stsCfg), roleArn, optFns...))
+}
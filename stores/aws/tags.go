@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// managedTagPrefix marks the tags vss sets itself. Those no longer wanted are
+// removed; tags with other keys are left to whoever added them.
+const managedTagPrefix = "vss:"
+
+// secretTags converts a tag map to Secrets Manager tags, sorted by key
+func secretTags(m map[string]string) []types.Tag {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]types.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, types.Tag{Key: aws.String(k), Value: aws.String(m[k])})
+	}
+	return tags
+}
+
+// reconcileTags restores an existing secret's tags when they have drifted
+// from Tags: missing or changed tags are set again, and vss: tags that are no
+// longer wanted are removed
+func (g *AwsClient) reconcileTags(ctx context.Context, arn string) error {
+	if len(g.Tags) == 0 {
+		return nil
+	}
+	l := log.WithFields(log.Fields{
+		"action": "reconcileTags",
+		"arn":    arn,
+	})
+	resp, err := g.client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: &arn})
+	if err != nil {
+		return fmt.Errorf("failed to describe secret: %w", err)
+	}
+	set, remove := tagDrift(resp.Tags, g.Tags)
+	if len(set) == 0 && len(remove) == 0 {
+		return nil
+	}
+	l.WithFields(log.Fields{
+		"set":    set,
+		"remove": remove,
+	}).Warn("Secret tags drifted, restoring")
+	if len(set) > 0 {
+		_, err := g.client.TagResource(ctx, &secretsmanager.TagResourceInput{
+			SecretId: &arn,
+			Tags:     secretTags(set),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to tag secret: %w", err)
+		}
+	}
+	if len(remove) > 0 {
+		_, err := g.client.UntagResource(ctx, &secretsmanager.UntagResourceInput{
+			SecretId: &arn,
+			TagKeys:  remove,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to untag secret: %w", err)
+		}
+	}
+	return nil
+}
+
+// tagDrift compares a secret's tags with the desired ones, returning the tags
+// to set (missing or with another value) and the vss: keys to remove
+func tagDrift(current []types.Tag, desired map[string]string) (map[string]string, []string) {
+	have := make(map[string]string, len(current))
+	for _, t := range current {
+		have[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	set := make(map[string]string)
+	for k, v := range desired {
+		if cv, ok := have[k]; !ok || cv != v {
+			set[k] = v
+		}
+	}
+	var remove []string
+	for k := range have {
+		if _, ok := desired[k]; !ok && strings.HasPrefix(k, managedTagPrefix) {
+			remove = append(remove, k)
+		}
+	}
+	sort.Strings(remove)
+	return set, remove
+}
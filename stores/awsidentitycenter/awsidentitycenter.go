@@ -12,6 +12,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/identitystore"
 	identitystoretypes "github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/jbcom/secretsync/pkg/driver"
@@ -39,6 +41,11 @@ type IdentityCenterClient struct {
 	// Key is email pattern (supports wildcards), value is account config
 	AccountMapping map[string]AccountConfig `yaml:"accountMapping,omitempty" json:"accountMapping,omitempty"`
 
+	// AccountDiscovery derives the sandbox account of each member that
+	// AccountMapping does not map, from the organization's account names or
+	// owner tags
+	AccountDiscovery *AccountDiscovery `yaml:"accountDiscovery,omitempty" json:"accountDiscovery,omitempty"`
+
 	// OutputFormat controls how discovered accounts are formatted
 	// Options: "json", "yaml", "list"
 	OutputFormat string `yaml:"outputFormat,omitempty" json:"outputFormat,omitempty"`
@@ -48,6 +55,32 @@ type IdentityCenterClient struct {
 
 	identityStoreClient *identitystore.Client `yaml:"-" json:"-"`
 	ssoAdminClient      *ssoadmin.Client      `yaml:"-" json:"-"`
+	orgClient           *organizations.Client `yaml:"-" json:"-"`
+}
+
+// AccountDiscovery finds each group member's account in the organization,
+// producing one account per member without maintaining AccountMapping
+type AccountDiscovery struct {
+	// NamePattern is the name of a member's account, with {username},
+	// {email} and {emailLocal} (the email before the @) replaced by the
+	// member's, e.g. "sandbox-{username}". Names match case-insensitively.
+	NamePattern string `yaml:"namePattern,omitempty" json:"namePattern,omitempty"`
+	// OwnerTag is an account tag holding the owner's email, e.g. "owner".
+	// It is used for members without an account named by NamePattern.
+	OwnerTag string `yaml:"ownerTag,omitempty" json:"ownerTag,omitempty"`
+	// ExecutionRoleName is the role assumed in each account
+	// (default OrganizationAccountAccessRole)
+	ExecutionRoleName string            `yaml:"executionRoleName,omitempty" json:"executionRoleName,omitempty"`
+	Classification    string            `yaml:"classification,omitempty" json:"classification,omitempty"`
+	Tags              map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// orgAccount is an active account of the organization
+type orgAccount struct {
+	ID   string
+	Name string
+	ARN  string
+	Tags map[string]string
 }
 
 // AccountConfig defines the configuration for an AWS account
@@ -85,6 +118,16 @@ func (in *IdentityCenterClient) DeepCopyInto(out *IdentityCenterClient) {
 			out.AccountMapping[k] = v
 		}
 	}
+	if in.AccountDiscovery != nil {
+		ad := *in.AccountDiscovery
+		if ad.Tags != nil {
+			ad.Tags = make(map[string]string, len(in.AccountDiscovery.Tags))
+			for k, v := range in.AccountDiscovery.Tags {
+				ad.Tags[k] = v
+			}
+		}
+		out.AccountDiscovery = &ad
+	}
 	if in.DiscoveredAccounts != nil {
 		out.DiscoveredAccounts = make([]DiscoveredAccount, len(in.DiscoveredAccounts))
 		copy(out.DiscoveredAccounts, in.DiscoveredAccounts)
@@ -112,6 +155,9 @@ func (c *IdentityCenterClient) Validate() error {
 	if c.GroupName == "" && c.GroupID == "" {
 		return errors.New("either groupName or groupId is required")
 	}
+	if ad := c.AccountDiscovery; ad != nil && ad.NamePattern == "" && ad.OwnerTag == "" {
+		return errors.New("accountDiscovery requires namePattern or ownerTag")
+	}
 	return nil
 }
 
@@ -169,6 +215,9 @@ func (c *IdentityCenterClient) Init(ctx context.Context) error {
 	// Create clients
 	c.identityStoreClient = identitystore.NewFromConfig(awscfg)
 	c.ssoAdminClient = ssoadmin.NewFromConfig(awscfg)
+	if c.AccountDiscovery != nil {
+		c.orgClient = organizations.NewFromConfig(awscfg)
+	}
 
 	// Auto-discover Identity Store ID if not provided
 	if c.IdentityStoreID == "" {
@@ -286,6 +335,25 @@ func (c *IdentityCenterClient) ListSecrets(ctx context.Context, path string) ([]
 
 	// Match members to accounts
 	c.DiscoveredAccounts = c.matchMembersToAccounts(members)
+
+	// Derive the accounts of members without a mapping
+	if c.AccountDiscovery != nil {
+		accounts, err := c.listOrgAccounts(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organization accounts: %w", err)
+		}
+		mapped := make(map[string]bool, len(c.DiscoveredAccounts))
+		for _, a := range c.DiscoveredAccounts {
+			mapped[a.UserID] = true
+		}
+		var unmapped []GroupMember
+		for _, m := range members {
+			if !mapped[m.UserID] {
+				unmapped = append(unmapped, m)
+			}
+		}
+		c.DiscoveredAccounts = append(c.DiscoveredAccounts, c.AccountDiscovery.deriveAccounts(unmapped, accounts)...)
+	}
 	l.Infof("matched %d accounts", len(c.DiscoveredAccounts))
 
 	// Return account names as "secrets"
@@ -389,6 +457,122 @@ func (c *IdentityCenterClient) matchMembersToAccounts(members []GroupMember) []D
 	return accounts
 }
 
+// listOrgAccounts returns the organization's active accounts, with their tags
+// when accounts are matched by owner tag
+func (c *IdentityCenterClient) listOrgAccounts(ctx context.Context) ([]orgAccount, error) {
+	var accounts []orgAccount
+	paginator := organizations.NewListAccountsPaginator(c.orgClient, &organizations.ListAccountsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, acct := range page.Accounts {
+			if acct.Status != orgtypes.AccountStatusActive {
+				continue
+			}
+			accounts = append(accounts, orgAccount{
+				ID:   aws.ToString(acct.Id),
+				Name: aws.ToString(acct.Name),
+				ARN:  aws.ToString(acct.Arn),
+			})
+		}
+	}
+
+	if c.AccountDiscovery.OwnerTag == "" {
+		return accounts, nil
+	}
+	for i := range accounts {
+		accounts[i].Tags = make(map[string]string)
+		tags := organizations.NewListTagsForResourcePaginator(c.orgClient, &organizations.ListTagsForResourceInput{
+			ResourceId: aws.String(accounts[i].ID),
+		})
+		for tags.HasMorePages() {
+			page, err := tags.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list tags of account %s: %w", accounts[i].ID, err)
+			}
+			for _, tag := range page.Tags {
+				accounts[i].Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		}
+	}
+	return accounts, nil
+}
+
+// deriveAccounts finds each member's account: the account named by
+// NamePattern, otherwise the one account whose OwnerTag is the member's
+// email. Members with no account, or several owned accounts, are skipped.
+func (ad *AccountDiscovery) deriveAccounts(members []GroupMember, accounts []orgAccount) []DiscoveredAccount {
+	l := log.WithFields(log.Fields{
+		"action": "deriveAccounts",
+		"driver": "awsidentitycenter",
+	})
+	roleName := ad.ExecutionRoleName
+	if roleName == "" {
+		roleName = "OrganizationAccountAccessRole"
+	}
+
+	var discovered []DiscoveredAccount
+	for _, member := range members {
+		var matches []orgAccount
+		if ad.NamePattern != "" {
+			name := ad.accountName(member)
+			for _, acct := range accounts {
+				if strings.EqualFold(acct.Name, name) {
+					matches = append(matches, acct)
+				}
+			}
+		}
+		if len(matches) == 0 && ad.OwnerTag != "" {
+			for _, acct := range accounts {
+				if strings.EqualFold(strings.TrimSpace(acct.Tags[ad.OwnerTag]), member.Email) {
+					matches = append(matches, acct)
+				}
+			}
+		}
+
+		if len(matches) == 0 {
+			l.Debugf("no account found for %s", member.Email)
+			continue
+		}
+		if len(matches) > 1 {
+			l.Warnf("skipping %s: %d accounts match, expected one", member.Email, len(matches))
+			continue
+		}
+		acct := matches[0]
+		discovered = append(discovered, DiscoveredAccount{
+			Email:            member.Email,
+			UserID:           member.UserID,
+			Username:         member.Username,
+			AccountID:        acct.ID,
+			AccountName:      acct.Name,
+			ExecutionRoleArn: fmt.Sprintf("arn:%s:iam::%s:role/%s", arnPartition(acct.ARN), acct.ID, roleName),
+			Classification:   ad.Classification,
+			Tags:             ad.Tags,
+		})
+	}
+	return discovered
+}
+
+// accountName renders NamePattern for a member
+func (ad *AccountDiscovery) accountName(member GroupMember) string {
+	local, _, _ := strings.Cut(member.Email, "@")
+	return strings.NewReplacer(
+		"{username}", member.Username,
+		"{email}", member.Email,
+		"{emailLocal}", local,
+	).Replace(ad.NamePattern)
+}
+
+// arnPartition returns the partition of an ARN, defaulting to aws
+func arnPartition(arn string) string {
+	if parts := strings.SplitN(arn, ":", 3); len(parts) == 3 && parts[1] != "" {
+		return parts[1]
+	}
+	return "aws"
+}
+
 // matchEmailPattern checks if an email matches a pattern (supports * wildcard)
 func matchEmailPattern(email, pattern string) bool {
 	if pattern == "*" {
@@ -408,6 +592,7 @@ func matchEmailPattern(email, pattern string) bool {
 func (c *IdentityCenterClient) Close() error {
 	c.identityStoreClient = nil
 	c.ssoAdminClient = nil
+	c.orgClient = nil
 	return nil
 }
 
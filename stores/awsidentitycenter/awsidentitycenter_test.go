@@ -0,0 +1,55 @@
+package awsidentitycenter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveAccounts(t *testing.T) {
+	accounts := []orgAccount{
+		{ID: "111111111111", Name: "Sandbox-Alice", ARN: "arn:aws:organizations::999999999999:account/o-abc/111111111111"},
+		{ID: "222222222222", Name: "team-data", ARN: "arn:aws-us-gov:organizations::999999999999:account/o-abc/222222222222",
+			Tags: map[string]string{"owner": "Bob@example.com"}},
+		{ID: "333333333333", Name: "carol-1", Tags: map[string]string{"owner": "carol@example.com"}},
+		{ID: "444444444444", Name: "carol-2", Tags: map[string]string{"owner": "carol@example.com"}},
+	}
+	members := []GroupMember{
+		{UserID: "u-alice", Username: "alice", Email: "alice@example.com"},
+		{UserID: "u-bob", Username: "bob", Email: "bob@example.com"},
+		{UserID: "u-carol", Username: "carol", Email: "carol@example.com"},
+		{UserID: "u-dave", Username: "dave", Email: "dave@example.com"},
+	}
+	ad := &AccountDiscovery{NamePattern: "sandbox-{username}", OwnerTag: "owner", Classification: "sandbox"}
+
+	got := ad.deriveAccounts(members, accounts)
+	assert.Equal(t, []DiscoveredAccount{
+		{
+			Email: "alice@example.com", UserID: "u-alice", Username: "alice",
+			AccountID: "111111111111", AccountName: "Sandbox-Alice",
+			ExecutionRoleArn: "arn:aws:iam::111111111111:role/OrganizationAccountAccessRole",
+			Classification:   "sandbox",
+		},
+		{
+			Email: "bob@example.com", UserID: "u-bob", Username: "bob",
+			AccountID: "222222222222", AccountName: "team-data",
+			ExecutionRoleArn: "arn:aws-us-gov:iam::222222222222:role/OrganizationAccountAccessRole",
+			Classification:   "sandbox",
+		},
+	}, got, "carol owns two accounts and dave none, so both are skipped")
+}
+
+func TestAccountDiscoveryAccountName(t *testing.T) {
+	member := GroupMember{Username: "alice.smith", Email: "alice@example.com"}
+	assert.Equal(t, "sandbox-alice.smith", (&AccountDiscovery{NamePattern: "sandbox-{username}"}).accountName(member))
+	assert.Equal(t, "dev-alice", (&AccountDiscovery{NamePattern: "dev-{emailLocal}"}).accountName(member))
+	assert.Equal(t, "alice@example.com", (&AccountDiscovery{NamePattern: "{email}"}).accountName(member))
+}
+
+func TestValidateAccountDiscovery(t *testing.T) {
+	c := &IdentityCenterClient{GroupName: "Developers", AccountDiscovery: &AccountDiscovery{}}
+	assert.ErrorContains(t, c.Validate(), "requires namePattern or ownerTag")
+
+	c.AccountDiscovery.OwnerTag = "owner"
+	assert.NoError(t, c.Validate())
+}
@@ -0,0 +1,185 @@
+package azurekeyvault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthServicePrincipal = "servicePrincipal"
+	AuthManagedIdentity  = "managedIdentity"
+
+	defaultAuthorityHost = "https://login.microsoftonline.com"
+
+	// tokenRefreshWindow renews tokens this long before they expire
+	tokenRefreshWindow = 5 * time.Minute
+)
+
+// imdsEndpoint is the Azure Instance Metadata Service token endpoint used for
+// managed identities on VMs, VM scale sets and AKS nodes
+var imdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// token is a cached access token
+type token struct {
+	mu      sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// tokenResponse is the token endpoint response. Entra ID returns expires_in as
+// a number, IMDS as a string and App Service only returns expires_on.
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+	ExpiresOn   json.RawMessage `json:"expires_on"`
+}
+
+func (t tokenResponse) expiry(now time.Time) time.Time {
+	if s := rawInt(t.ExpiresIn); s > 0 {
+		return now.Add(time.Duration(s) * time.Second)
+	}
+	if s := rawInt(t.ExpiresOn); s > 0 {
+		return time.Unix(s, 0)
+	}
+	return now.Add(tokenRefreshWindow)
+}
+
+func rawInt(raw json.RawMessage) int64 {
+	n, _ := strconv.ParseInt(strings.Trim(string(raw), `"`), 10, 64)
+	return n
+}
+
+// authMethod returns the configured auth method, defaulting to a service
+// principal when a client secret is set and a managed identity otherwise
+func (c *AzureKeyVaultClient) authMethod() string {
+	if c.Auth != "" {
+		return c.Auth
+	}
+	if c.ClientSecret != "" {
+		return AuthServicePrincipal
+	}
+	return AuthManagedIdentity
+}
+
+// resource returns the Key Vault resource the token is requested for, derived
+// from the vault URL so sovereign clouds (vault.usgovcloudapi.net,
+// vault.azure.cn) work without extra configuration
+func (c *AzureKeyVaultClient) resource() string {
+	u, err := url.Parse(c.VaultURL)
+	if err != nil {
+		return "https://vault.azure.net"
+	}
+	_, suffix, found := strings.Cut(u.Hostname(), ".")
+	if !found {
+		return "https://vault.azure.net"
+	}
+	return "https://" + suffix
+}
+
+// accessToken returns a cached token, requesting a new one when it is close
+// to expiry
+func (c *AzureKeyVaultClient) accessToken(ctx context.Context) (string, error) {
+	c.token.mu.Lock()
+	defer c.token.mu.Unlock()
+	if c.token.value != "" && time.Until(c.token.expires) > tokenRefreshWindow {
+		return c.token.value, nil
+	}
+
+	var req *http.Request
+	var err error
+	switch c.authMethod() {
+	case AuthServicePrincipal:
+		req, err = c.servicePrincipalRequest(ctx)
+	case AuthManagedIdentity:
+		req, err = c.managedIdentityRequest(ctx)
+	default:
+		return "", fmt.Errorf("unsupported auth %q", c.Auth)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("%s token request failed: status=%d", c.authMethod(), resp.StatusCode)
+	}
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", fmt.Errorf("%s token response has no access token", c.authMethod())
+	}
+
+	c.token.value = tr.AccessToken
+	c.token.expires = tr.expiry(time.Now())
+	return c.token.value, nil
+}
+
+// servicePrincipalRequest builds a client credentials request to Entra ID
+func (c *AzureKeyVaultClient) servicePrincipalRequest(ctx context.Context) (*http.Request, error) {
+	authority := strings.TrimSuffix(c.AuthorityHost, "/")
+	if authority == "" {
+		authority = defaultAuthorityHost
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"scope":         {c.resource() + "/.default"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/%s/oauth2/v2.0/token", authority, url.PathEscape(c.TenantID)),
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// managedIdentityRequest builds a token request for the managed identity of
+// the host: the App Service / Container Apps identity endpoint when present,
+// otherwise IMDS. ClientID selects a user-assigned identity.
+func (c *AzureKeyVaultClient) managedIdentityRequest(ctx context.Context) (*http.Request, error) {
+	q := url.Values{"resource": {c.resource()}}
+	if c.ClientID != "" {
+		q.Set("client_id", c.ClientID)
+	}
+
+	endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER")
+	if endpoint != "" && header != "" {
+		q.Set("api-version", "2019-08-01")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create token request: %w", err)
+		}
+		req.Header.Set("X-IDENTITY-HEADER", header)
+		return req, nil
+	}
+
+	q.Set("api-version", "2018-02-01")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsEndpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata", "true")
+	return req, nil
+}
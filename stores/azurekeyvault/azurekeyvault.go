@@ -0,0 +1,543 @@
+package azurekeyvault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	apiVersion = "7.4"
+
+	// maxNameLength is the longest secret name Key Vault accepts
+	maxNameLength = 127
+	// listPageSize is the number of secrets requested per list page
+	listPageSize = 25
+	// softDeletePolls bounds how long to wait for soft delete, recover and
+	// purge operations, which Key Vault completes asynchronously
+	softDeletePolls = 30
+)
+
+var (
+	invalidNameChars = regexp.MustCompile(`[^0-9a-zA-Z-]`)
+
+	// softDeletePollInterval is the wait between soft delete status checks
+	softDeletePollInterval = time.Second
+)
+
+// AzureKeyVaultClient implements the secret store interface for Azure Key Vault
+type AzureKeyVaultClient struct {
+	// VaultURL is the vault's URL, e.g. https://my-vault.vault.azure.net
+	VaultURL string `yaml:"vaultUrl,omitempty" json:"vaultUrl,omitempty"`
+	// Name is the secret name; characters Key Vault does not allow become dashes
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Tags are added to every secret written
+	Tags map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	// PurgeOnDelete purges deleted secrets instead of leaving them soft-deleted
+	PurgeOnDelete *bool `yaml:"purgeOnDelete,omitempty" json:"purgeOnDelete,omitempty"`
+
+	// Auth is servicePrincipal or managedIdentity (default: servicePrincipal
+	// when clientSecret is set, otherwise managedIdentity)
+	Auth string `yaml:"auth,omitempty" json:"auth,omitempty"`
+	// TenantID is the Entra ID tenant of the service principal (default: AZURE_TENANT_ID)
+	TenantID string `yaml:"tenantId,omitempty" json:"tenantId,omitempty"`
+	// ClientID is the service principal's application ID, or the client ID of a
+	// user-assigned managed identity (default: AZURE_CLIENT_ID)
+	ClientID string `yaml:"clientId,omitempty" json:"clientId,omitempty"`
+	// ClientSecret is the service principal's secret (default: AZURE_CLIENT_SECRET)
+	ClientSecret string `yaml:"clientSecret,omitempty" json:"clientSecret,omitempty"`
+	// AuthorityHost overrides the Entra ID endpoint for sovereign clouds
+	AuthorityHost string `yaml:"authorityHost,omitempty" json:"authorityHost,omitempty"`
+
+	httpClient *http.Client `yaml:"-" json:"-"`
+	token      *token       `yaml:"-" json:"-"`
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureKeyVaultClient) DeepCopyInto(out *AzureKeyVaultClient) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PurgeOnDelete != nil {
+		in, out := &in.PurgeOnDelete, &out.PurgeOnDelete
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureKeyVaultClient.
+func (in *AzureKeyVaultClient) DeepCopy() *AzureKeyVaultClient {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureKeyVaultClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// Validate ensures required fields are set
+func (c *AzureKeyVaultClient) Validate() error {
+	l := log.WithFields(log.Fields{
+		"action": "Validate",
+		"driver": "azureKeyVault",
+	})
+	l.Trace("start")
+
+	if c.VaultURL == "" {
+		return errors.New("vaultUrl is required")
+	}
+	if u, err := url.Parse(c.VaultURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("vaultUrl %q must be an https URL", c.VaultURL)
+	}
+	if c.Name == "" {
+		return driver.ErrPathRequired
+	}
+	switch c.authMethod() {
+	case AuthServicePrincipal:
+		if c.TenantID == "" || c.ClientID == "" || c.ClientSecret == "" {
+			return errors.New("tenantId, clientId and clientSecret are required for servicePrincipal auth")
+		}
+	case AuthManagedIdentity:
+	default:
+		return fmt.Errorf("auth must be %s or %s", AuthServicePrincipal, AuthManagedIdentity)
+	}
+	return nil
+}
+
+// NewClient creates a new Azure Key Vault client from configuration
+func NewClient(cfg *AzureKeyVaultClient) (*AzureKeyVaultClient, error) {
+	l := log.WithFields(log.Fields{
+		"action": "NewClient",
+		"driver": "azureKeyVault",
+	})
+	l.Trace("start")
+
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	vc := cfg.DeepCopy()
+
+	// Log without exposing the client secret
+	l.Debugf("client created for vault=%s name=%s", vc.VaultURL, vc.Name)
+	l.Trace("end")
+	return vc, nil
+}
+
+// Init fills credentials from the environment, validates the config and
+// acquires a token so bad credentials fail before the first write
+func (c *AzureKeyVaultClient) Init(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action": "Init",
+		"driver": "azureKeyVault",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if c.TenantID == "" {
+		c.TenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+	if c.ClientID == "" {
+		c.ClientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	if c.ClientSecret == "" && c.Auth != AuthManagedIdentity {
+		c.ClientSecret = os.Getenv("AZURE_CLIENT_SECRET")
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	c.initHTTP()
+	if _, err := c.accessToken(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// initHTTP sets up the HTTP client and token cache
+func (c *AzureKeyVaultClient) initHTTP() {
+	c.VaultURL = strings.TrimSuffix(c.VaultURL, "/")
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+	if c.token == nil {
+		c.token = &token{}
+	}
+}
+
+// Driver returns the driver name
+func (c *AzureKeyVaultClient) Driver() driver.DriverName {
+	return driver.DriverNameAzureKeyVault
+}
+
+// GetPath returns the path identifier for this store
+func (c *AzureKeyVaultClient) GetPath() string {
+	return c.Name
+}
+
+// Meta returns metadata about the client configuration
+func (c *AzureKeyVaultClient) Meta() map[string]any {
+	md := make(map[string]any)
+	jd, err := json.Marshal(c)
+	if err != nil {
+		return md
+	}
+	err = json.Unmarshal(jd, &md)
+	if err != nil {
+		return md
+	}
+	// Remove sensitive data
+	delete(md, "clientSecret")
+	return md
+}
+
+// cleanName converts a path to a valid Key Vault secret name: letters,
+// digits and dashes, at most 127 characters
+func (c *AzureKeyVaultClient) cleanName(name string) string {
+	if name == "" {
+		name = c.Name
+	}
+	name = invalidNameChars.ReplaceAllString(name, "-")
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	return name
+}
+
+// apiError is an error response from Key Vault
+type apiError struct {
+	StatusCode int
+	Code       string
+}
+
+func (e *apiError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("API error: status=%d code=%s", e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("API error: status=%d", e.StatusCode)
+}
+
+// hasStatus reports whether err is a Key Vault error with the given status
+func hasStatus(err error, status int) bool {
+	var ae *apiError
+	return errors.As(err, &ae) && ae.StatusCode == status
+}
+
+// doRequest performs an authenticated request to the vault. path is relative
+// to the vault URL unless it is already absolute (list nextLinks).
+func (c *AzureKeyVaultClient) doRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "doRequest",
+		"method": method,
+		"path":   path,
+	})
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	u := path
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		u = fmt.Sprintf("%s%s%sapi-version=%s", c.VaultURL, path, sep, apiVersion)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	tok, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		// Only the error code is surfaced; messages can echo request details
+		var er struct {
+			Error struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(respBody, &er)
+		l.Debugf("API error: status=%d code=%s", resp.StatusCode, er.Error.Code)
+		return nil, &apiError{StatusCode: resp.StatusCode, Code: er.Error.Code}
+	}
+
+	return respBody, nil
+}
+
+// GetSecret returns the current value of a secret
+func (c *AzureKeyVaultClient) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "GetSecret",
+		"driver": "azureKeyVault",
+		"name":   c.cleanName(name),
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/secrets/"+c.cleanName(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return []byte(result.Value), nil
+}
+
+// WriteSecret writes the secrets as a new version of the named secret. Writes
+// are skipped when the value is unchanged so syncs do not pile up versions, and
+// a soft-deleted secret is recovered before it is written.
+func (c *AzureKeyVaultClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secrets []byte) ([]byte, error) {
+	name := c.cleanName(path)
+	l := log.WithFields(log.Fields{
+		"action": "WriteSecret",
+		"driver": "azureKeyVault",
+		"path":   path,
+		"name":   name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	current, err := c.GetSecret(ctx, name)
+	if err != nil && !hasStatus(err, http.StatusNotFound) {
+		return nil, err
+	}
+	if err == nil && bytes.Equal(current, secrets) {
+		l.Debug("secret up to date")
+		return nil, nil
+	}
+
+	tags := map[string]string{
+		"managed-by": "vault-secret-sync",
+	}
+	for k, v := range c.Tags {
+		tags[k] = v
+	}
+	body := map[string]interface{}{
+		"value":       string(secrets),
+		"contentType": "application/json",
+		"tags":        tags,
+	}
+	_, err = c.doRequest(ctx, http.MethodPut, "/secrets/"+name, body)
+	if hasStatus(err, http.StatusConflict) {
+		// The name is held by a soft-deleted secret
+		l.Debug("recovering soft-deleted secret")
+		if err := c.recoverSecret(ctx, name); err != nil {
+			return nil, err
+		}
+		_, err = c.doRequest(ctx, http.MethodPut, "/secrets/"+name, body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write secret %s: %w", name, err)
+	}
+	return nil, nil
+}
+
+// recoverSecret recovers a soft-deleted secret and waits until it is readable
+func (c *AzureKeyVaultClient) recoverSecret(ctx context.Context, name string) error {
+	if _, err := c.doRequest(ctx, http.MethodPost, "/deletedsecrets/"+name+"/recover", nil); err != nil {
+		return fmt.Errorf("failed to recover deleted secret %s: %w", name, err)
+	}
+	return poll(ctx, func() (bool, error) {
+		_, err := c.doRequest(ctx, http.MethodGet, "/secrets/"+name, nil)
+		if hasStatus(err, http.StatusNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// DeleteSecret deletes a secret, purging it when PurgeOnDelete is set. A
+// secret that does not exist is not an error.
+func (c *AzureKeyVaultClient) DeleteSecret(ctx context.Context, secret string) error {
+	name := c.cleanName(secret)
+	l := log.WithFields(log.Fields{
+		"action": "DeleteSecret",
+		"driver": "azureKeyVault",
+		"name":   name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if _, err := c.doRequest(ctx, http.MethodDelete, "/secrets/"+name, nil); err != nil {
+		if hasStatus(err, http.StatusNotFound) {
+			return nil
+		}
+		return err
+	}
+	if c.PurgeOnDelete == nil || !*c.PurgeOnDelete {
+		return nil
+	}
+
+	// Purging fails with a conflict until the delete has completed
+	return poll(ctx, func() (bool, error) {
+		_, err := c.doRequest(ctx, http.MethodDelete, "/deletedsecrets/"+name, nil)
+		if hasStatus(err, http.StatusConflict) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// ListSecrets lists the names of the vault's secrets, limited to names
+// starting with the cleaned path when one is given
+func (c *AzureKeyVaultClient) ListSecrets(ctx context.Context, p string) ([]string, error) {
+	l := log.WithFields(log.Fields{
+		"action": "ListSecrets",
+		"driver": "azureKeyVault",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	prefix := ""
+	if p != "" {
+		prefix = c.cleanName(p)
+	}
+
+	var secrets []string
+	next := fmt.Sprintf("/secrets?maxresults=%d", listPageSize)
+	for next != "" {
+		respBody, err := c.doRequest(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Value []struct {
+				ID string `json:"id"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		for _, s := range result.Value {
+			// IDs are in the format https://my-vault.vault.azure.net/secrets/my-secret
+			name := s.ID[strings.LastIndex(s.ID, "/")+1:]
+			if strings.HasPrefix(name, prefix) {
+				secrets = append(secrets, name)
+			}
+		}
+		next = result.NextLink
+	}
+	sort.Strings(secrets)
+
+	l.WithField("secrets", secrets).Trace("end")
+	return secrets, nil
+}
+
+// poll calls fn until it reports done, fails or the attempts run out
+func poll(ctx context.Context, fn func() (bool, error)) error {
+	for i := 0; i < softDeletePolls; i++ {
+		done, err := fn()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(softDeletePollInterval):
+		}
+	}
+	return errors.New("timed out waiting for soft delete operation")
+}
+
+// Close cleans up the client
+func (c *AzureKeyVaultClient) Close() error {
+	c.httpClient = nil
+	return nil
+}
+
+// SetDefaults applies default values from configuration
+func (c *AzureKeyVaultClient) SetDefaults(defaults any) error {
+	l := log.WithFields(log.Fields{
+		"action": "SetDefaults",
+		"driver": "azureKeyVault",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	jd, err := json.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	nc := &AzureKeyVaultClient{}
+	err = json.Unmarshal(jd, nc)
+	if err != nil {
+		return err
+	}
+	if c.VaultURL == "" && nc.VaultURL != "" {
+		c.VaultURL = nc.VaultURL
+	}
+	if c.Name == "" && nc.Name != "" {
+		c.Name = nc.Name
+	}
+	if c.Tags == nil && nc.Tags != nil {
+		c.Tags = nc.Tags
+	}
+	if c.PurgeOnDelete == nil && nc.PurgeOnDelete != nil {
+		c.PurgeOnDelete = nc.PurgeOnDelete
+	}
+	if c.Auth == "" && nc.Auth != "" {
+		c.Auth = nc.Auth
+	}
+	if c.TenantID == "" && nc.TenantID != "" {
+		c.TenantID = nc.TenantID
+	}
+	if c.ClientID == "" && nc.ClientID != "" {
+		c.ClientID = nc.ClientID
+	}
+	if c.ClientSecret == "" && nc.ClientSecret != "" {
+		c.ClientSecret = nc.ClientSecret
+	}
+	if c.AuthorityHost == "" && nc.AuthorityHost != "" {
+		c.AuthorityHost = nc.AuthorityHost
+	}
+	return nil
+}
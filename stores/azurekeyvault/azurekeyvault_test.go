@@ -0,0 +1,314 @@
+package azurekeyvault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeKeyVault serves the Key Vault secrets API and the token endpoints from memory
+type fakeKeyVault struct {
+	mu      sync.Mutex
+	url     string
+	secrets map[string]string
+	tags    map[string]map[string]string
+	deleted map[string]string
+	// pendingDeletes is the number of purge attempts rejected before a
+	// delete completes
+	pendingDeletes int
+
+	tokens  int
+	tokenRq *http.Request
+	puts    int
+	purged  []string
+}
+
+func (f *fakeKeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if strings.HasSuffix(r.URL.Path, "/token") {
+		f.tokens++
+		f.tokenRq = r.Clone(context.Background())
+		_ = r.ParseForm()
+		f.tokenRq.Form = r.Form
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "tok", "expires_in": "3600"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Query().Get("api-version") != apiVersion {
+		http.Error(w, "missing api-version", http.StatusBadRequest)
+		return
+	}
+
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": "SecretNotFound"}})
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/secrets":
+		names := make([]string, 0, len(f.secrets))
+		for name := range f.secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		// Pages of two, regardless of maxresults
+		skip := 0
+		fmt.Sscanf(r.URL.Query().Get("skip"), "%d", &skip)
+		end := min(skip+2, len(names))
+		var value []map[string]string
+		for _, name := range names[skip:end] {
+			value = append(value, map[string]string{"id": f.url + "/secrets/" + name})
+		}
+		resp := map[string]interface{}{"value": value}
+		if end < len(names) {
+			resp["nextLink"] = fmt.Sprintf("%s/secrets?api-version=%s&skip=%d", f.url, apiVersion, end)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "secrets":
+		value, ok := f.secrets[parts[1]]
+		if !ok {
+			notFound()
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"value": value})
+	case r.Method == http.MethodPut && len(parts) == 2 && parts[0] == "secrets":
+		if _, ok := f.deleted[parts[1]]; ok {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": "Conflict"}})
+			return
+		}
+		var body struct {
+			Value string            `json:"value"`
+			Tags  map[string]string `json:"tags"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.puts++
+		f.secrets[parts[1]] = body.Value
+		f.tags[parts[1]] = body.Tags
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "secrets":
+		value, ok := f.secrets[parts[1]]
+		if !ok {
+			notFound()
+			return
+		}
+		delete(f.secrets, parts[1])
+		f.deleted[parts[1]] = value
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[0] == "deletedsecrets" && parts[2] == "recover":
+		value, ok := f.deleted[parts[1]]
+		if !ok {
+			notFound()
+			return
+		}
+		delete(f.deleted, parts[1])
+		f.secrets[parts[1]] = value
+	case r.Method == http.MethodDelete && len(parts) == 2 && parts[0] == "deletedsecrets":
+		if f.pendingDeletes > 0 {
+			f.pendingDeletes--
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		delete(f.deleted, parts[1])
+		f.purged = append(f.purged, parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestClient(t *testing.T, f *fakeKeyVault, cfg AzureKeyVaultClient) *AzureKeyVaultClient {
+	t.Helper()
+	srv := httptest.NewTLSServer(f)
+	t.Cleanup(srv.Close)
+	f.url = srv.URL
+	if f.secrets == nil {
+		f.secrets = map[string]string{}
+	}
+	f.tags = map[string]map[string]string{}
+	if f.deleted == nil {
+		f.deleted = map[string]string{}
+	}
+
+	cfg.VaultURL = srv.URL
+	if cfg.Name == "" {
+		cfg.Name = "team/app"
+	}
+	if cfg.Auth == "" {
+		cfg.TenantID = "tenant"
+		cfg.ClientID = "client"
+		cfg.ClientSecret = "shh"
+		cfg.AuthorityHost = srv.URL
+	}
+	c, err := NewClient(&cfg)
+	require.NoError(t, err)
+	c.httpClient = srv.Client()
+
+	interval := softDeletePollInterval
+	softDeletePollInterval = time.Millisecond
+	t.Cleanup(func() { softDeletePollInterval = interval })
+
+	require.NoError(t, c.Init(context.Background()))
+	return c
+}
+
+func TestServicePrincipalAuth(t *testing.T) {
+	f := &fakeKeyVault{}
+	c := newTestClient(t, f, AzureKeyVaultClient{})
+
+	assert.Equal(t, AuthServicePrincipal, c.authMethod())
+	require.NotNil(t, f.tokenRq)
+	assert.Equal(t, "/tenant/oauth2/v2.0/token", f.tokenRq.URL.Path)
+	assert.Equal(t, "client_credentials", f.tokenRq.Form.Get("grant_type"))
+	assert.Equal(t, "client", f.tokenRq.Form.Get("client_id"))
+	assert.Equal(t, "shh", f.tokenRq.Form.Get("client_secret"))
+
+	// The token from Init is reused
+	_, err := c.ListSecrets(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, f.tokens)
+}
+
+func TestManagedIdentityAuth(t *testing.T) {
+	f := &fakeKeyVault{}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	endpoint := imdsEndpoint
+	imdsEndpoint = srv.URL + "/metadata/identity/oauth2/token"
+	defer func() { imdsEndpoint = endpoint }()
+	t.Setenv("IDENTITY_ENDPOINT", "")
+	t.Setenv("AZURE_CLIENT_ID", "")
+
+	c := newTestClient(t, f, AzureKeyVaultClient{Auth: AuthManagedIdentity, ClientID: "user-assigned"})
+
+	require.NotNil(t, f.tokenRq)
+	assert.Equal(t, "/metadata/identity/oauth2/token", f.tokenRq.URL.Path)
+	assert.Equal(t, "true", f.tokenRq.Header.Get("Metadata"))
+	assert.Equal(t, "user-assigned", f.tokenRq.URL.Query().Get("client_id"))
+	assert.Equal(t, "tok", c.token.value)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), c.token.expires, time.Minute)
+}
+
+func TestWriteSecret(t *testing.T) {
+	f := &fakeKeyVault{}
+	c := newTestClient(t, f, AzureKeyVaultClient{Tags: map[string]string{"team": "payments"}})
+	ctx := context.Background()
+
+	_, err := c.WriteSecret(ctx, metav1.ObjectMeta{}, "team/app", []byte(`{"user":"admin"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"user":"admin"}`, f.secrets["team-app"])
+	assert.Equal(t, map[string]string{"managed-by": "vault-secret-sync", "team": "payments"}, f.tags["team-app"])
+
+	got, err := c.GetSecret(ctx, "team/app")
+	require.NoError(t, err)
+	assert.Equal(t, `{"user":"admin"}`, string(got))
+
+	// Unchanged values don't create a new version
+	_, err = c.WriteSecret(ctx, metav1.ObjectMeta{}, "team/app", []byte(`{"user":"admin"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, f.puts)
+}
+
+func TestWriteSecretRecoversSoftDeleted(t *testing.T) {
+	f := &fakeKeyVault{deleted: map[string]string{"team-app": `{"old":"value"}`}}
+	c := newTestClient(t, f, AzureKeyVaultClient{})
+
+	_, err := c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "team/app", []byte(`{"new":"value"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"new":"value"}`, f.secrets["team-app"])
+	assert.Empty(t, f.deleted)
+}
+
+func TestDeleteSecret(t *testing.T) {
+	f := &fakeKeyVault{secrets: map[string]string{"a": "1", "b": "2"}, pendingDeletes: 2}
+	purge := true
+	c := newTestClient(t, f, AzureKeyVaultClient{PurgeOnDelete: &purge})
+	ctx := context.Background()
+
+	require.NoError(t, c.DeleteSecret(ctx, "a"))
+	assert.NotContains(t, f.secrets, "a")
+	assert.Equal(t, []string{"a"}, f.purged)
+
+	// Missing secrets are already deleted
+	require.NoError(t, c.DeleteSecret(ctx, "missing"))
+
+	c.PurgeOnDelete = nil
+	require.NoError(t, c.DeleteSecret(ctx, "b"))
+	assert.Contains(t, f.deleted, "b")
+	assert.Equal(t, []string{"a"}, f.purged)
+}
+
+func TestListSecrets(t *testing.T) {
+	f := &fakeKeyVault{secrets: map[string]string{
+		"team-app":    "1",
+		"team-app-db": "2",
+		"team-web":    "3",
+		"other":       "4",
+		"team-api":    "5",
+	}}
+	c := newTestClient(t, f, AzureKeyVaultClient{})
+	ctx := context.Background()
+
+	all, err := c.ListSecrets(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other", "team-api", "team-app", "team-app-db", "team-web"}, all)
+
+	team, err := c.ListSecrets(ctx, "team/app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-app", "team-app-db"}, team)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  AzureKeyVaultClient
+		err  string
+	}{
+		{name: "managed identity", cfg: AzureKeyVaultClient{VaultURL: "https://v.vault.azure.net", Name: "app"}},
+		{name: "service principal", cfg: AzureKeyVaultClient{VaultURL: "https://v.vault.azure.net", Name: "app", TenantID: "t", ClientID: "c", ClientSecret: "s"}},
+		{name: "no vault", cfg: AzureKeyVaultClient{Name: "app"}, err: "vaultUrl is required"},
+		{name: "http vault", cfg: AzureKeyVaultClient{VaultURL: "http://v.vault.azure.net", Name: "app"}, err: `vaultUrl "http://v.vault.azure.net" must be an https URL`},
+		{name: "no name", cfg: AzureKeyVaultClient{VaultURL: "https://v.vault.azure.net"}, err: "path is required"},
+		{name: "partial service principal", cfg: AzureKeyVaultClient{VaultURL: "https://v.vault.azure.net", Name: "app", Auth: AuthServicePrincipal, ClientID: "c"}, err: "tenantId, clientId and clientSecret are required for servicePrincipal auth"},
+		{name: "unknown auth", cfg: AzureKeyVaultClient{VaultURL: "https://v.vault.azure.net", Name: "app", Auth: "password"}, err: "auth must be servicePrincipal or managedIdentity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestResource(t *testing.T) {
+	assert.Equal(t, "https://vault.azure.net", (&AzureKeyVaultClient{VaultURL: "https://v.vault.azure.net"}).resource())
+	assert.Equal(t, "https://vault.usgovcloudapi.net", (&AzureKeyVaultClient{VaultURL: "https://v.vault.usgovcloudapi.net/"}).resource())
+}
+
+func TestCleanNameAndMeta(t *testing.T) {
+	c := &AzureKeyVaultClient{Name: "team/app", ClientSecret: "shh"}
+	assert.Equal(t, "team-app", c.cleanName(""))
+	assert.Equal(t, "a-b-c", c.cleanName("a/b_c"))
+	assert.Len(t, c.cleanName(strings.Repeat("x", 200)), maxNameLength)
+
+	md := c.Meta()
+	assert.Equal(t, "team/app", md["name"])
+	assert.NotContains(t, md, "clientSecret")
+}
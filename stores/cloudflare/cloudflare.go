@@ -0,0 +1,654 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+	// kvBatchSize is the most keys a Workers KV bulk write or delete accepts
+	kvBatchSize = 10000
+	// kvListPageSize is the number of keys requested per list page
+	kvListPageSize = 1000
+)
+
+// CloudflareClient implements the secret store interface for Cloudflare: the
+// secrets of a Workers script, the encrypted environment variables of a Pages
+// project, or the keys of a Workers KV namespace
+type CloudflareClient struct {
+	// AccountID is the Cloudflare account that owns the script, project or namespace
+	AccountID string `yaml:"accountId,omitempty" json:"accountId,omitempty"`
+	// Token is the API token for authentication (default: CLOUDFLARE_API_TOKEN)
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+	// Script is the Workers script whose secrets are synced
+	Script string `yaml:"script,omitempty" json:"script,omitempty"`
+	// PagesProject is the Pages project whose encrypted environment variables are synced
+	PagesProject string `yaml:"pagesProject,omitempty" json:"pagesProject,omitempty"`
+	// PagesEnvironment is production or preview (default production)
+	PagesEnvironment string `yaml:"pagesEnvironment,omitempty" json:"pagesEnvironment,omitempty"`
+	// KVNamespace is the ID of the Workers KV namespace whose keys are synced
+	KVNamespace string `yaml:"kvNamespace,omitempty" json:"kvNamespace,omitempty"`
+	// BaseURL allows overriding the API endpoint (for testing)
+	BaseURL string `yaml:"baseUrl,omitempty" json:"baseUrl,omitempty"`
+	// Merge determines whether to merge with existing secrets or replace
+	Merge *bool `yaml:"merge,omitempty" json:"merge,omitempty"`
+	// NameTransform transforms secret names (upper, lower, none)
+	NameTransform string `yaml:"nameTransform,omitempty" json:"nameTransform,omitempty"`
+
+	httpClient *http.Client `yaml:"-" json:"-"`
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *CloudflareClient) DeepCopyInto(out *CloudflareClient) {
+	*out = *in
+	if in.Merge != nil {
+		in, out := &in.Merge, &out.Merge
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy creates a deep copy of the client
+func (in *CloudflareClient) DeepCopy() *CloudflareClient {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// Validate ensures required fields are set
+func (c *CloudflareClient) Validate() error {
+	l := log.WithFields(log.Fields{
+		"action": "Validate",
+		"driver": "cloudflare",
+	})
+	l.Trace("start")
+
+	if c.AccountID == "" {
+		return errors.New("accountId is required")
+	}
+	if c.Token == "" {
+		return errors.New("token is required")
+	}
+	targets := 0
+	for _, t := range []string{c.Script, c.PagesProject, c.KVNamespace} {
+		if t != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return errors.New("exactly one of script, pagesProject and kvNamespace is required")
+	}
+	switch c.PagesEnvironment {
+	case "", "production", "preview":
+	default:
+		return fmt.Errorf("pagesEnvironment must be production or preview, got %q", c.PagesEnvironment)
+	}
+	if c.PagesEnvironment != "" && c.PagesProject == "" {
+		return errors.New("pagesEnvironment requires pagesProject")
+	}
+	switch strings.ToLower(c.NameTransform) {
+	case "", "upper", "lower", "none":
+	default:
+		return fmt.Errorf("nameTransform must be upper, lower or none, got %q", c.NameTransform)
+	}
+	return nil
+}
+
+// NewClient creates a new Cloudflare client from configuration
+func NewClient(cfg *CloudflareClient) (*CloudflareClient, error) {
+	l := log.WithFields(log.Fields{
+		"action": "NewClient",
+		"driver": "cloudflare",
+	})
+	l.Trace("start")
+
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	vc := cfg.DeepCopy()
+
+	// Log without exposing sensitive token
+	l.Debugf("client created for account=%s path=%s", vc.AccountID, vc.GetPath())
+	l.Trace("end")
+	return vc, nil
+}
+
+// Init fills the token from the environment and validates the config
+func (c *CloudflareClient) Init(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action": "Init",
+		"driver": "cloudflare",
+	})
+	l.Trace("start")
+
+	if c.Token == "" {
+		c.Token = os.Getenv("CLOUDFLARE_API_TOKEN")
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	c.initHTTP()
+
+	l.Trace("end")
+	return nil
+}
+
+// initHTTP sets up the HTTP client and API endpoint
+func (c *CloudflareClient) initHTTP() {
+	if c.BaseURL == "" {
+		c.BaseURL = defaultBaseURL
+	}
+	c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+}
+
+// Driver returns the driver name
+func (c *CloudflareClient) Driver() driver.DriverName {
+	return driver.DriverNameCloudflare
+}
+
+// GetPath returns the path identifier for this store
+func (c *CloudflareClient) GetPath() string {
+	switch {
+	case c.Script != "":
+		return "workers/" + c.Script
+	case c.PagesProject != "":
+		return fmt.Sprintf("pages/%s/%s", c.PagesProject, c.pagesEnvironment())
+	default:
+		return "kv/" + c.KVNamespace
+	}
+}
+
+// Meta returns metadata about the client configuration
+func (c *CloudflareClient) Meta() map[string]any {
+	md := make(map[string]any)
+	jd, err := json.Marshal(c)
+	if err != nil {
+		return md
+	}
+	err = json.Unmarshal(jd, &md)
+	if err != nil {
+		return md
+	}
+	// Remove sensitive data
+	delete(md, "token")
+	return md
+}
+
+// pagesEnvironment returns the Pages deployment environment synced
+func (c *CloudflareClient) pagesEnvironment() string {
+	if c.PagesEnvironment == "" {
+		return "production"
+	}
+	return c.PagesEnvironment
+}
+
+// transformName applies name transformation rules
+func (c *CloudflareClient) transformName(name string) string {
+	switch strings.ToLower(c.NameTransform) {
+	case "upper":
+		return strings.ToUpper(name)
+	case "lower":
+		return strings.ToLower(name)
+	default:
+		return name
+	}
+}
+
+// apiError is an error response from the Cloudflare API
+type apiError struct {
+	StatusCode int
+	Codes      []int
+}
+
+func (e *apiError) Error() string {
+	if len(e.Codes) > 0 {
+		return fmt.Sprintf("API error: status=%d codes=%v", e.StatusCode, e.Codes)
+	}
+	return fmt.Sprintf("API error: status=%d", e.StatusCode)
+}
+
+// hasStatus reports whether err is a Cloudflare error with the given status
+func hasStatus(err error, status int) bool {
+	var ae *apiError
+	return errors.As(err, &ae) && ae.StatusCode == status
+}
+
+// envelope is the wrapper of Cloudflare API responses
+type envelope struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code int `json:"code"`
+	} `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo struct {
+		Cursor string `json:"cursor"`
+	} `json:"result_info"`
+}
+
+// doRequest performs an HTTP request to the Cloudflare API and returns the
+// raw response body
+func (c *CloudflareClient) doRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "doRequest",
+		"method": method,
+		"path":   path,
+	})
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		// Only the error codes are surfaced; messages can echo request details
+		var env envelope
+		_ = json.Unmarshal(respBody, &env)
+		ae := &apiError{StatusCode: resp.StatusCode}
+		for _, e := range env.Errors {
+			ae.Codes = append(ae.Codes, e.Code)
+		}
+		l.Debug(ae.Error())
+		return nil, ae
+	}
+
+	return respBody, nil
+}
+
+// call performs an API request and decodes the result of its response
+// envelope into result, when given, returning the envelope's list cursor
+func (c *CloudflareClient) call(ctx context.Context, method, path string, body, result interface{}) (string, error) {
+	respBody, err := c.doRequest(ctx, method, path, body)
+	if err != nil {
+		return "", err
+	}
+	var env envelope
+	if err := json.Unmarshal(respBody, &env); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !env.Success {
+		ae := &apiError{StatusCode: http.StatusOK}
+		for _, e := range env.Errors {
+			ae.Codes = append(ae.Codes, e.Code)
+		}
+		return "", ae
+	}
+	if result != nil && len(env.Result) > 0 {
+		if err := json.Unmarshal(env.Result, result); err != nil {
+			return "", fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return env.ResultInfo.Cursor, nil
+}
+
+// accountPath returns an API path under the client's account
+func (c *CloudflareClient) accountPath(format string, a ...any) string {
+	return "/accounts/" + url.PathEscape(c.AccountID) + fmt.Sprintf(format, a...)
+}
+
+// GetSecret returns the value of a Workers KV key. Cloudflare never returns
+// the values of Workers secrets or Pages encrypted variables.
+func (c *CloudflareClient) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "GetSecret",
+		"driver": "cloudflare",
+		"name":   name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if c.KVNamespace == "" {
+		return nil, fmt.Errorf("cloudflare does not return the values of %s secrets", c.GetPath())
+	}
+	// Values are returned as they were written, outside the response envelope
+	return c.doRequest(ctx, http.MethodGet, c.accountPath("/storage/kv/namespaces/%s/values/%s",
+		url.PathEscape(c.KVNamespace), url.PathEscape(c.transformName(name))), nil)
+}
+
+// WriteSecret writes secrets to the script, project or namespace. Without
+// merge the target is replaced: secrets missing from the payload are deleted.
+func (c *CloudflareClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, bSecrets []byte) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "WriteSecret",
+		"path":   path,
+		"driver": "cloudflare",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if c == nil {
+		return nil, errors.New("nil client")
+	}
+
+	values, err := c.cloudflareValues(bSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) == 0 {
+		l.Debug("no secrets to write")
+		return nil, nil
+	}
+
+	var removed []string
+	if c.Merge == nil || !*c.Merge {
+		current, err := c.listNames(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read current secrets: %w", err)
+		}
+		for _, name := range current {
+			if _, ok := values[name]; !ok {
+				removed = append(removed, name)
+			}
+		}
+	}
+
+	if err := c.putSecrets(ctx, values); err != nil {
+		return nil, err
+	}
+	if err := c.deleteSecrets(ctx, removed); err != nil {
+		return nil, err
+	}
+
+	l.Infof("successfully wrote %d and deleted %d secrets in Cloudflare %s", len(values), len(removed), c.GetPath())
+	return nil, nil
+}
+
+// cloudflareValues converts a secret payload to the secrets it is written
+// as: names transformed, empty values skipped and complex values JSON encoded
+func (c *CloudflareClient) cloudflareValues(bSecrets []byte) (map[string]string, error) {
+	secrets := make(map[string]interface{})
+	if err := json.Unmarshal(bSecrets, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secrets: %w", err)
+	}
+
+	values := make(map[string]string)
+	for k, v := range secrets {
+		if v == nil || v == "" {
+			log.WithField("driver", "cloudflare").Debugf("skipping empty secret: %s", k)
+			continue
+		}
+
+		name := c.transformName(k)
+		switch val := v.(type) {
+		case string:
+			values[name] = val
+		case map[string]interface{}, []interface{}:
+			jsonVal, err := json.Marshal(val)
+			if err != nil {
+				log.WithField("driver", "cloudflare").Warnf("failed to marshal complex secret %s: %v", k, err)
+				continue
+			}
+			values[name] = string(jsonVal)
+		default:
+			values[name] = fmt.Sprintf("%v", val)
+		}
+	}
+	return values, nil
+}
+
+// putSecrets creates or updates secrets. Workers secrets are written one at a
+// time, as the API has no bulk write; Pages variables in one update; KV keys
+// in bulk writes.
+func (c *CloudflareClient) putSecrets(ctx context.Context, values map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch {
+	case c.Script != "":
+		for _, name := range names {
+			body := map[string]string{"name": name, "text": values[name], "type": "secret_text"}
+			if _, err := c.call(ctx, http.MethodPut, c.accountPath("/workers/scripts/%s/secrets", url.PathEscape(c.Script)), body, nil); err != nil {
+				return fmt.Errorf("failed to write secret %s: %w", name, err)
+			}
+		}
+	case c.PagesProject != "":
+		vars := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			vars[name] = map[string]string{"type": "secret_text", "value": values[name]}
+		}
+		if err := c.updatePagesVars(ctx, vars); err != nil {
+			return fmt.Errorf("failed to write secrets: %w", err)
+		}
+	default:
+		for i := 0; i < len(names); i += kvBatchSize {
+			batch := names[i:min(i+kvBatchSize, len(names))]
+			pairs := make([]map[string]string, 0, len(batch))
+			for _, name := range batch {
+				pairs = append(pairs, map[string]string{"key": name, "value": values[name]})
+			}
+			if _, err := c.call(ctx, http.MethodPut, c.accountPath("/storage/kv/namespaces/%s/bulk", url.PathEscape(c.KVNamespace)), pairs, nil); err != nil {
+				return fmt.Errorf("failed to write secrets: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteSecrets deletes secrets by their Cloudflare names; secrets that do
+// not exist are already deleted
+func (c *CloudflareClient) deleteSecrets(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	switch {
+	case c.Script != "":
+		for _, name := range names {
+			_, err := c.call(ctx, http.MethodDelete, c.accountPath("/workers/scripts/%s/secrets/%s", url.PathEscape(c.Script), url.PathEscape(name)), nil, nil)
+			if err != nil && !hasStatus(err, http.StatusNotFound) {
+				return fmt.Errorf("failed to delete secret %s: %w", name, err)
+			}
+		}
+	case c.PagesProject != "":
+		// Variables set to null are removed
+		vars := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			vars[name] = nil
+		}
+		if err := c.updatePagesVars(ctx, vars); err != nil {
+			return fmt.Errorf("failed to delete secrets: %w", err)
+		}
+	default:
+		for i := 0; i < len(names); i += kvBatchSize {
+			batch := names[i:min(i+kvBatchSize, len(names))]
+			if _, err := c.call(ctx, http.MethodPost, c.accountPath("/storage/kv/namespaces/%s/bulk/delete", url.PathEscape(c.KVNamespace)), batch, nil); err != nil {
+				return fmt.Errorf("failed to delete secrets: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// updatePagesVars patches environment variables of the project's deployment
+// environment, leaving the others as they are
+func (c *CloudflareClient) updatePagesVars(ctx context.Context, vars map[string]interface{}) error {
+	body := map[string]interface{}{
+		"deployment_configs": map[string]interface{}{
+			c.pagesEnvironment(): map[string]interface{}{"env_vars": vars},
+		},
+	}
+	_, err := c.call(ctx, http.MethodPatch, c.accountPath("/pages/projects/%s", url.PathEscape(c.PagesProject)), body, nil)
+	return err
+}
+
+// listNames returns the Cloudflare names of the synced secrets: the script's
+// secrets, the project's encrypted variables (plain text variables are
+// configuration, not secrets, and left alone) or the namespace's keys
+func (c *CloudflareClient) listNames(ctx context.Context) ([]string, error) {
+	var names []string
+	switch {
+	case c.Script != "":
+		var result []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		}
+		if _, err := c.call(ctx, http.MethodGet, c.accountPath("/workers/scripts/%s/secrets", url.PathEscape(c.Script)), nil, &result); err != nil {
+			return nil, err
+		}
+		for _, s := range result {
+			names = append(names, s.Name)
+		}
+	case c.PagesProject != "":
+		var result struct {
+			DeploymentConfigs map[string]struct {
+				EnvVars map[string]*struct {
+					Type string `json:"type"`
+				} `json:"env_vars"`
+			} `json:"deployment_configs"`
+		}
+		if _, err := c.call(ctx, http.MethodGet, c.accountPath("/pages/projects/%s", url.PathEscape(c.PagesProject)), nil, &result); err != nil {
+			return nil, err
+		}
+		for name, v := range result.DeploymentConfigs[c.pagesEnvironment()].EnvVars {
+			if v != nil && v.Type == "secret_text" {
+				names = append(names, name)
+			}
+		}
+	default:
+		cursor := ""
+		for {
+			path := c.accountPath("/storage/kv/namespaces/%s/keys?limit=%d", url.PathEscape(c.KVNamespace), kvListPageSize)
+			if cursor != "" {
+				path += "&cursor=" + url.QueryEscape(cursor)
+			}
+			var result []struct {
+				Name string `json:"name"`
+			}
+			next, err := c.call(ctx, http.MethodGet, path, nil, &result)
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range result {
+				names = append(names, k.Name)
+			}
+			if next == "" || len(result) == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteSecret deletes a secret by name. Given "" or the store's own path, as
+// sync deletes are, it deletes every synced secret.
+func (c *CloudflareClient) DeleteSecret(ctx context.Context, name string) error {
+	l := log.WithFields(log.Fields{
+		"action": "DeleteSecret",
+		"driver": "cloudflare",
+		"name":   name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if name == "" || name == c.GetPath() {
+		names, err := c.listNames(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list secrets: %w", err)
+		}
+		return c.deleteSecrets(ctx, names)
+	}
+	return c.deleteSecrets(ctx, []string{c.transformName(name)})
+}
+
+// ListSecrets lists the names of the synced secrets
+func (c *CloudflareClient) ListSecrets(ctx context.Context, path string) ([]string, error) {
+	l := log.WithFields(log.Fields{
+		"action": "ListSecrets",
+		"driver": "cloudflare",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	return c.listNames(ctx)
+}
+
+// Close cleans up the client
+func (c *CloudflareClient) Close() error {
+	c.httpClient = nil
+	return nil
+}
+
+// SetDefaults applies default values from configuration
+func (c *CloudflareClient) SetDefaults(cfg any) error {
+	jd, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	nc := &CloudflareClient{}
+	err = json.Unmarshal(jd, &nc)
+	if err != nil {
+		return err
+	}
+
+	if c.AccountID == "" && nc.AccountID != "" {
+		c.AccountID = nc.AccountID
+	}
+	if c.Token == "" && nc.Token != "" {
+		c.Token = nc.Token
+	}
+	if c.BaseURL == "" && nc.BaseURL != "" {
+		c.BaseURL = nc.BaseURL
+	}
+	if c.NameTransform == "" && nc.NameTransform != "" {
+		c.NameTransform = nc.NameTransform
+	}
+	if c.Merge == nil {
+		c.Merge = nc.Merge
+	}
+
+	return nil
+}
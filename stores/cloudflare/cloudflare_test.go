@@ -0,0 +1,316 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeCloudflare serves the Workers secrets, Pages project and Workers KV
+// endpoints of the Cloudflare API for account acc-1 from memory
+type fakeCloudflare struct {
+	mu sync.Mutex
+	// workers holds the secrets of script edge-api
+	workers map[string]string
+	// pages holds the production variables of project site as type/value pairs
+	pages map[string][2]string
+	// kv holds the keys of namespace ns-1
+	kv map[string]string
+
+	workerWrites int
+	kvLists      int
+}
+
+func newFakeCloudflare() *fakeCloudflare {
+	return &fakeCloudflare{
+		workers: map[string]string{},
+		pages:   map[string][2]string{},
+		kv:      map[string]string{},
+	}
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = fmt.Fprint(w, `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`)
+		return
+	}
+
+	ok := func(result interface{}, cursor string) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"errors":      []interface{}{},
+			"result":      result,
+			"result_info": map[string]string{"cursor": cursor},
+		})
+	}
+	const prefix = "/accounts/acc-1"
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	switch {
+	case !strings.HasPrefix(r.URL.Path, prefix):
+		http.NotFound(w, r)
+
+	case r.Method == http.MethodGet && path == "/workers/scripts/edge-api/secrets":
+		var result []map[string]string
+		for name := range f.workers {
+			result = append(result, map[string]string{"name": name, "type": "secret_text"})
+		}
+		ok(result, "")
+	case r.Method == http.MethodPut && path == "/workers/scripts/edge-api/secrets":
+		var body struct{ Name, Text, Type string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.workers[body.Name] = body.Text
+		f.workerWrites++
+		ok(map[string]string{"name": body.Name, "type": body.Type}, "")
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, "/workers/scripts/edge-api/secrets/"):
+		name := strings.TrimPrefix(path, "/workers/scripts/edge-api/secrets/")
+		if _, exists := f.workers[name]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"success":false,"errors":[{"code":10056}]}`)
+			return
+		}
+		delete(f.workers, name)
+		ok(nil, "")
+
+	case r.Method == http.MethodGet && path == "/pages/projects/site":
+		vars := map[string]interface{}{}
+		for name, v := range f.pages {
+			vars[name] = map[string]string{"type": v[0], "value": ""}
+		}
+		ok(map[string]interface{}{"deployment_configs": map[string]interface{}{
+			"production": map[string]interface{}{"env_vars": vars},
+			"preview":    map[string]interface{}{"env_vars": nil},
+		}}, "")
+	case r.Method == http.MethodPatch && path == "/pages/projects/site":
+		var body struct {
+			DeploymentConfigs map[string]struct {
+				EnvVars map[string]*struct{ Type, Value string } `json:"env_vars"`
+			} `json:"deployment_configs"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		for name, v := range body.DeploymentConfigs["production"].EnvVars {
+			if v == nil {
+				delete(f.pages, name)
+			} else {
+				f.pages[name] = [2]string{v.Type, v.Value}
+			}
+		}
+		ok(map[string]string{"name": "site"}, "")
+
+	case r.Method == http.MethodGet && path == "/storage/kv/namespaces/ns-1/keys":
+		f.kvLists++
+		names := make([]string, 0, len(f.kv))
+		for name := range f.kv {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		end := min(start+limit, len(names))
+		var result []map[string]string
+		for _, name := range names[start:end] {
+			result = append(result, map[string]string{"name": name})
+		}
+		cursor := ""
+		if end < len(names) {
+			cursor = strconv.Itoa(end)
+		}
+		ok(result, cursor)
+	case r.Method == http.MethodPut && path == "/storage/kv/namespaces/ns-1/bulk":
+		var pairs []struct{ Key, Value string }
+		_ = json.NewDecoder(r.Body).Decode(&pairs)
+		for _, p := range pairs {
+			f.kv[p.Key] = p.Value
+		}
+		ok(nil, "")
+	case r.Method == http.MethodPost && path == "/storage/kv/namespaces/ns-1/bulk/delete":
+		var keys []string
+		_ = json.NewDecoder(r.Body).Decode(&keys)
+		for _, k := range keys {
+			delete(f.kv, k)
+		}
+		ok(nil, "")
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/storage/kv/namespaces/ns-1/values/"):
+		value, exists := f.kv[strings.TrimPrefix(path, "/storage/kv/namespaces/ns-1/values/")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"success":false,"errors":[{"code":10009}]}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, value)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestClient(t *testing.T, f *fakeCloudflare, cfg CloudflareClient) *CloudflareClient {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	cfg.AccountID = "acc-1"
+	cfg.Token = "test-token"
+	cfg.BaseURL = srv.URL
+	c, err := NewClient(&cfg)
+	require.NoError(t, err)
+	require.NoError(t, c.Init(context.Background()))
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     CloudflareClient
+		wantErr string
+	}{
+		{"script", CloudflareClient{AccountID: "a", Token: "t", Script: "s"}, ""},
+		{"pages preview", CloudflareClient{AccountID: "a", Token: "t", PagesProject: "p", PagesEnvironment: "preview"}, ""},
+		{"kv", CloudflareClient{AccountID: "a", Token: "t", KVNamespace: "n", NameTransform: "upper"}, ""},
+		{"no account", CloudflareClient{Token: "t", Script: "s"}, "accountId is required"},
+		{"no token", CloudflareClient{AccountID: "a", Script: "s"}, "token is required"},
+		{"no target", CloudflareClient{AccountID: "a", Token: "t"}, "exactly one of script, pagesProject and kvNamespace is required"},
+		{"two targets", CloudflareClient{AccountID: "a", Token: "t", Script: "s", KVNamespace: "n"}, "exactly one of script, pagesProject and kvNamespace is required"},
+		{"bad environment", CloudflareClient{AccountID: "a", Token: "t", PagesProject: "p", PagesEnvironment: "staging"}, `pagesEnvironment must be production or preview, got "staging"`},
+		{"environment without pages", CloudflareClient{AccountID: "a", Token: "t", Script: "s", PagesEnvironment: "preview"}, "pagesEnvironment requires pagesProject"},
+		{"bad transform", CloudflareClient{AccountID: "a", Token: "t", Script: "s", NameTransform: "camel"}, `nameTransform must be upper, lower or none, got "camel"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInitTokenFromEnv(t *testing.T) {
+	t.Setenv("CLOUDFLARE_API_TOKEN", "env-token")
+	c := &CloudflareClient{AccountID: "a", Script: "s"}
+	require.NoError(t, c.Init(context.Background()))
+	assert.Equal(t, "env-token", c.Token)
+	assert.NotContains(t, c.Meta(), "token")
+}
+
+func TestWorkersSecrets(t *testing.T) {
+	f := newFakeCloudflare()
+	f.workers["STALE"] = "old"
+	c := newTestClient(t, f, CloudflareClient{Script: "edge-api", NameTransform: "upper"})
+	ctx := context.Background()
+	assert.Equal(t, "workers/edge-api", c.GetPath())
+
+	// Without merge the script's secrets are replaced
+	_, err := c.WriteSecret(ctx, metav1.ObjectMeta{}, "app", []byte(`{"api_key":"k1","db":{"user":"u"},"port":5432,"empty":""}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": "k1", "DB": `{"user":"u"}`, "PORT": "5432"}, f.workers)
+	assert.Equal(t, 3, f.workerWrites)
+
+	names, err := c.ListSecrets(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"API_KEY", "DB", "PORT"}, names)
+
+	_, err = c.GetSecret(ctx, "api_key")
+	assert.EqualError(t, err, "cloudflare does not return the values of workers/edge-api secrets")
+
+	// Merge leaves secrets missing from the payload in place
+	merge := true
+	c.Merge = &merge
+	_, err = c.WriteSecret(ctx, metav1.ObjectMeta{}, "app", []byte(`{"api_key":"k2"}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_KEY": "k2", "DB": `{"user":"u"}`, "PORT": "5432"}, f.workers)
+
+	// Named deletes transform the name; missing secrets are already deleted
+	require.NoError(t, c.DeleteSecret(ctx, "port"))
+	require.NoError(t, c.DeleteSecret(ctx, "port"))
+	assert.NotContains(t, f.workers, "PORT")
+
+	// Deleting the store's path deletes every secret
+	require.NoError(t, c.DeleteSecret(ctx, c.GetPath()))
+	assert.Empty(t, f.workers)
+}
+
+func TestPagesSecrets(t *testing.T) {
+	f := newFakeCloudflare()
+	f.pages["NODE_VERSION"] = [2]string{"plain_text", "20"}
+	f.pages["OLD_SECRET"] = [2]string{"secret_text", "x"}
+	c := newTestClient(t, f, CloudflareClient{PagesProject: "site"})
+	ctx := context.Background()
+	assert.Equal(t, "pages/site/production", c.GetPath())
+
+	_, err := c.WriteSecret(ctx, metav1.ObjectMeta{}, "app", []byte(`{"STRIPE_KEY":"sk_live"}`))
+	require.NoError(t, err)
+	// Plain text variables are configuration and survive a replace
+	assert.Equal(t, map[string][2]string{
+		"NODE_VERSION": {"plain_text", "20"},
+		"STRIPE_KEY":   {"secret_text", "sk_live"},
+	}, f.pages)
+
+	names, err := c.ListSecrets(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"STRIPE_KEY"}, names)
+
+	require.NoError(t, c.DeleteSecret(ctx, ""))
+	assert.Equal(t, map[string][2]string{"NODE_VERSION": {"plain_text", "20"}}, f.pages)
+}
+
+func TestKVSecrets(t *testing.T) {
+	f := newFakeCloudflare()
+	for i := 0; i < 2500; i++ {
+		f.kv[fmt.Sprintf("stale-%04d", i)] = "x"
+	}
+	c := newTestClient(t, f, CloudflareClient{KVNamespace: "ns-1"})
+	ctx := context.Background()
+
+	names, err := c.ListSecrets(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, names, 2500)
+	assert.Equal(t, 3, f.kvLists)
+
+	_, err = c.WriteSecret(ctx, metav1.ObjectMeta{}, "app", []byte(`{"db-url":"postgres://db","flag":true}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"db-url": "postgres://db", "flag": "true"}, f.kv)
+
+	value, err := c.GetSecret(ctx, "db-url")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://db", string(value))
+
+	_, err = c.GetSecret(ctx, "missing")
+	assert.True(t, hasStatus(err, http.StatusNotFound))
+	assert.EqualError(t, err, "API error: status=404 codes=[10009]")
+}
+
+func TestAPIErrors(t *testing.T) {
+	f := newFakeCloudflare()
+	c := newTestClient(t, f, CloudflareClient{Script: "edge-api"})
+	c.Token = "wrong-token"
+
+	_, err := c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "app", []byte(`{"a":"b"}`))
+	assert.EqualError(t, err, "failed to read current secrets: API error: status=403 codes=[10000]")
+}
+
+func TestSetDefaults(t *testing.T) {
+	merge := true
+	c := &CloudflareClient{Script: "edge-api", NameTransform: "lower"}
+	require.NoError(t, c.SetDefaults(&CloudflareClient{AccountID: "acc-1", Token: "t", NameTransform: "upper", Merge: &merge}))
+	assert.Equal(t, "acc-1", c.AccountID)
+	assert.Equal(t, "t", c.Token)
+	assert.Equal(t, "lower", c.NameTransform)
+	require.NotNil(t, c.Merge)
+	assert.True(t, *c.Merge)
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,6 +20,11 @@ import (
 
 const (
 	defaultBaseURL = "https://api.doppler.com/v3"
+
+	// defaultBatchSize is the number of secrets sent per update request
+	defaultBatchSize = 500
+	// listPageSize is the number of secrets requested per list page
+	listPageSize = 1000
 )
 
 // DopplerClient implements the secret store interface for Doppler
@@ -35,6 +41,12 @@ type DopplerClient struct {
 	Merge *bool `yaml:"merge,omitempty" json:"merge,omitempty"`
 	// NameTransform transforms secret names (upper, lower, none)
 	NameTransform string `yaml:"nameTransform,omitempty" json:"nameTransform,omitempty"`
+	// BatchSize caps the number of secrets sent per update request (default 500)
+	BatchSize int `yaml:"batchSize,omitempty" json:"batchSize,omitempty"`
+	// RotationOverlap keeps the previous value of each changed secret as
+	// <NAME>_OLD for this long (e.g. "24h"), so consumers can fall back to it
+	// while they pick up the new value
+	RotationOverlap string `yaml:"rotationOverlap,omitempty" json:"rotationOverlap,omitempty"`
 
 	httpClient *http.Client `yaml:"-" json:"-"`
 }
@@ -76,9 +88,24 @@ func (c *DopplerClient) Validate() error {
 	if c.Token == "" {
 		return errors.New("token is required")
 	}
+	if _, err := c.rotationOverlap(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// rotationOverlap parses RotationOverlap; zero disables keeping previous values
+func (c *DopplerClient) rotationOverlap() (time.Duration, error) {
+	if c.RotationOverlap == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.RotationOverlap)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid rotationOverlap %q", c.RotationOverlap)
+	}
+	return d, nil
+}
+
 // NewClient creates a new Doppler client from configuration
 func NewClient(cfg *DopplerClient) (*DopplerClient, error) {
 	l := log.WithFields(log.Fields{
@@ -111,16 +138,22 @@ func (c *DopplerClient) Init(ctx context.Context) error {
 		return err
 	}
 
+	c.initHTTP()
+
+	l.Trace("end")
+	return nil
+}
+
+// initHTTP sets up the HTTP client and API endpoint
+func (c *DopplerClient) initHTTP() {
 	if c.BaseURL == "" {
 		c.BaseURL = defaultBaseURL
 	}
-
-	c.httpClient = &http.Client{
-		Timeout: 30 * time.Second,
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: 30 * time.Second,
+		}
 	}
-
-	l.Trace("end")
-	return nil
 }
 
 // Driver returns the driver name
@@ -255,65 +288,174 @@ func (c *DopplerClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 		return nil, errors.New("nil client")
 	}
 
-	// Parse the secrets
+	dopplerSecrets, err := c.dopplerValues(bSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dopplerSecrets) == 0 {
+		l.Debug("no secrets to write")
+		return nil, nil
+	}
+
+	// Only send what differs from the config's current contents so large
+	// configs don't rewrite thousands of unchanged secrets on every sync
+	current, err := c.listSecretValues(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current secrets: %w", err)
+	}
+
+	changed := make(map[string]string)
+	for name, value := range dopplerSecrets {
+		if cur, ok := current[name]; !ok || cur != value {
+			changed[name] = value
+		}
+	}
+
+	overlap, err := c.rotationOverlap()
+	if err != nil {
+		return nil, err
+	}
+	var rotation rotationPlan
+	if overlap > 0 {
+		rotation, err = planRotation(current, dopplerSecrets, changed, overlap, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Without merge the config is replaced: secrets missing from the payload
+	// are deleted, except the DOPPLER_* secrets Doppler manages itself and the
+	// previous values kept for rotation
+	var removed []string
+	if c.Merge == nil || !*c.Merge {
+		for name := range current {
+			if _, ok := dopplerSecrets[name]; !ok && !strings.HasPrefix(name, "DOPPLER_") && !rotation.managed[name] {
+				removed = append(removed, name)
+			}
+		}
+		sort.Strings(removed)
+	}
+	for name, value := range rotation.writes {
+		changed[name] = value
+	}
+	removed = append(removed, rotation.deletes...)
+
+	if len(changed) == 0 && len(removed) == 0 {
+		l.Debugf("all %d secrets up to date", len(dopplerSecrets))
+		return nil, nil
+	}
+
+	if err := c.writeBatches(ctx, changed); err != nil {
+		return nil, err
+	}
+	for _, name := range removed {
+		if err := c.deleteSingleSecretRaw(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to delete secret %s: %w", name, err)
+		}
+	}
+
+	l.Infof("successfully wrote %d and deleted %d secrets in Doppler project=%s config=%s (%d unchanged)",
+		len(changed), len(removed), c.Project, c.Config, len(dopplerSecrets)-len(changed)+len(rotation.writes))
+	return nil, nil
+}
+
+// dopplerValues converts a secret payload to the Doppler secrets it is
+// written as: names transformed, empty values skipped and complex values
+// JSON encoded
+func (c *DopplerClient) dopplerValues(bSecrets []byte) (map[string]string, error) {
 	secrets := make(map[string]interface{})
 	if err := json.Unmarshal(bSecrets, &secrets); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal secrets: %w", err)
 	}
 
-	// Transform secrets to Doppler format
-	dopplerSecrets := make(map[string]string)
+	values := make(map[string]string)
 	for k, v := range secrets {
 		// Skip empty values
 		if v == nil || v == "" {
-			l.Debugf("skipping empty secret: %s", k)
+			log.WithField("driver", "doppler").Debugf("skipping empty secret: %s", k)
 			continue
 		}
 
 		name := c.transformName(k)
 		switch val := v.(type) {
 		case string:
-			dopplerSecrets[name] = val
+			values[name] = val
 		case map[string]interface{}, []interface{}:
 			// JSON encode complex types
 			jsonVal, err := json.Marshal(val)
 			if err != nil {
-				l.Warnf("failed to marshal complex secret %s: %v", k, err)
+				log.WithField("driver", "doppler").Warnf("failed to marshal complex secret %s: %v", k, err)
 				continue
 			}
-			dopplerSecrets[name] = string(jsonVal)
+			values[name] = string(jsonVal)
 		default:
-			dopplerSecrets[name] = fmt.Sprintf("%v", val)
+			values[name] = fmt.Sprintf("%v", val)
 		}
 	}
+	return values, nil
+}
 
-	if len(dopplerSecrets) == 0 {
-		l.Debug("no secrets to write")
-		return nil, nil
+// VerifySecret reads the config back and reports whether every secret of a
+// written payload holds its written value
+func (c *DopplerClient) VerifySecret(ctx context.Context, path string, written []byte) (bool, error) {
+	want, err := c.dopplerValues(written)
+	if err != nil {
+		return false, err
 	}
-
-	// Use the secrets update endpoint
-	// If merge is true, add ?merge=true to merge with existing secrets
-	// If merge is false (or nil), Doppler replaces all secrets by default
-	apiPath := "/configs/config/secrets"
-	if c.Merge != nil && *c.Merge {
-		apiPath += "?merge=true"
+	current, err := c.listSecretValues(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to read current secrets: %w", err)
 	}
+	for name, value := range want {
+		if cur, ok := current[name]; !ok || cur != value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
 
-	reqBody := map[string]interface{}{
-		"project": c.Project,
-		"config":  c.Config,
-		"secrets": dopplerSecrets,
+// writeBatches merges secrets into the config in requests of at most BatchSize
+// secrets, so very large updates don't exceed request size limits or time out
+func (c *DopplerClient) writeBatches(ctx context.Context, secrets map[string]string) error {
+	l := log.WithFields(log.Fields{
+		"action": "writeBatches",
+		"driver": "doppler",
+	})
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
 	}
 
-	_, err := c.doRequest(ctx, http.MethodPost, apiPath, reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to write secrets: %w", err)
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	l.Infof("successfully wrote %d secrets to Doppler project=%s config=%s",
-		len(dopplerSecrets), c.Project, c.Config)
-	return nil, nil
+	batches := (len(names) + batchSize - 1) / batchSize
+	for i := 0; i < len(names); i += batchSize {
+		end := i + batchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		batch := make(map[string]string, end-i)
+		for _, name := range names[i:end] {
+			batch[name] = secrets[name]
+		}
+
+		reqBody := map[string]interface{}{
+			"project": c.Project,
+			"config":  c.Config,
+			"secrets": batch,
+		}
+		if _, err := c.doRequest(ctx, http.MethodPost, "/configs/config/secrets?merge=true", reqBody); err != nil {
+			return fmt.Errorf("failed to write secrets (batch %d/%d): %w", i/batchSize+1, batches, err)
+		}
+		l.Debugf("wrote batch %d/%d (%d secrets)", i/batchSize+1, batches, len(batch))
+	}
+	return nil
 }
 
 // DeleteSecret deletes secrets from Doppler
@@ -371,31 +513,149 @@ func (c *DopplerClient) ListSecrets(ctx context.Context, path string) ([]string,
 	l.Trace("start")
 	defer l.Trace("end")
 
-	apiPath := fmt.Sprintf("/configs/config/secrets?project=%s&config=%s",
-		url.QueryEscape(c.Project), url.QueryEscape(c.Config))
-
-	respBody, err := c.doRequest(ctx, http.MethodGet, apiPath, nil)
+	values, err := c.listSecretValues(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	var result struct {
-		Secrets map[string]struct {
-			Raw string `json:"raw"`
-		} `json:"secrets"`
+	secrets := make([]string, 0, len(values))
+	for name := range values {
+		secrets = append(secrets, name)
 	}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	sort.Strings(secrets)
+
+	return secrets, nil
+}
+
+// listSecretValues pages through the config's secrets and returns their raw values
+func (c *DopplerClient) listSecretValues(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+	for page := 1; ; page++ {
+		apiPath := fmt.Sprintf("/configs/config/secrets?project=%s&config=%s&page=%d&per_page=%d",
+			url.QueryEscape(c.Project), url.QueryEscape(c.Config), page, listPageSize)
+
+		respBody, err := c.doRequest(ctx, http.MethodGet, apiPath, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Secrets map[string]struct {
+				Raw string `json:"raw"`
+			} `json:"secrets"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		added := 0
+		for name, secret := range result.Secrets {
+			if _, seen := values[name]; !seen {
+				added++
+			}
+			values[name] = secret.Raw
+		}
+		// A short page is the last one; a page with nothing new means the API
+		// returned the whole config at once and ignored the page parameters
+		if len(result.Secrets) < listPageSize || added == 0 {
+			break
+		}
 	}
+	return values, nil
+}
 
-	secrets := make([]string, 0, len(result.Secrets))
-	for name := range result.Secrets {
-		secrets = append(secrets, name)
+// ConfigInfo describes a config within a Doppler project
+type ConfigInfo struct {
+	Name        string `json:"name"`
+	Environment string `json:"environment"`
+	// Root is true for environment root configs (dev, stg, prd) and false for branch configs
+	Root   bool `json:"root"`
+	Locked bool `json:"locked"`
+}
+
+// ListConfigs enumerates all configs in the project, including branch configs.
+// Only Project and Token are required; the token must have project-level read access.
+func (c *DopplerClient) ListConfigs(ctx context.Context) ([]ConfigInfo, error) {
+	l := log.WithFields(log.Fields{
+		"action":  "ListConfigs",
+		"driver":  "doppler",
+		"project": c.Project,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if c.Project == "" || c.Token == "" {
+		return nil, errors.New("project and token are required")
 	}
+	c.initHTTP()
 
+	const perPage = 100
+	var configs []ConfigInfo
+	for page := 1; ; page++ {
+		apiPath := fmt.Sprintf("/configs?project=%s&page=%d&per_page=%d",
+			url.QueryEscape(c.Project), page, perPage)
+
+		respBody, err := c.doRequest(ctx, http.MethodGet, apiPath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list configs: %w", err)
+		}
+
+		var result struct {
+			Configs []ConfigInfo `json:"configs"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		configs = append(configs, result.Configs...)
+		if len(result.Configs) < perPage {
+			break
+		}
+	}
+
+	l.Debugf("found %d configs", len(configs))
+	return configs, nil
+}
+
+// DownloadSecrets returns all secrets in the config as a flat name/value map
+func (c *DopplerClient) DownloadSecrets(ctx context.Context) (map[string]string, error) {
+	l := log.WithFields(log.Fields{
+		"action":  "DownloadSecrets",
+		"driver":  "doppler",
+		"project": c.Project,
+		"config":  c.Config,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	c.initHTTP()
+
+	apiPath := fmt.Sprintf("/configs/config/secrets/download?project=%s&config=%s&format=json&include_dynamic_secrets=false",
+		url.QueryEscape(c.Project), url.QueryEscape(c.Config))
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, apiPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download secrets: %w", err)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(respBody, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
 	return secrets, nil
 }
 
+// CheckToken introspects the client's token, failing if Doppler rejects it
+func (c *DopplerClient) CheckToken(ctx context.Context) error {
+	if _, err := c.doRequest(ctx, http.MethodGet, "/me", nil); err != nil {
+		return fmt.Errorf("token check failed: %w", err)
+	}
+	return nil
+}
+
 // Close cleans up the client
 func (c *DopplerClient) Close() error {
 	c.httpClient = nil
@@ -429,6 +689,12 @@ func (c *DopplerClient) SetDefaults(cfg any) error {
 	if c.NameTransform == "" && nc.NameTransform != "" {
 		c.NameTransform = nc.NameTransform
 	}
+	if c.BatchSize == 0 && nc.BatchSize != 0 {
+		c.BatchSize = nc.BatchSize
+	}
+	if c.RotationOverlap == "" && nc.RotationOverlap != "" {
+		c.RotationOverlap = nc.RotationOverlap
+	}
 	// Default to merge mode
 	if c.Merge == nil {
 		c.Merge = nc.Merge
@@ -0,0 +1,264 @@
+package doppler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeDoppler serves the secrets endpoints of the Doppler API from memory
+type fakeDoppler struct {
+	mu           sync.Mutex
+	secrets      map[string]string
+	ignorePaging bool
+
+	lists   int
+	batches []int
+	deleted []string
+}
+
+func (f *fakeDoppler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/me":
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"slug": "test", "type": "service_token"})
+	case r.Method == http.MethodGet && r.URL.Path == "/configs/config/secrets":
+		f.lists++
+		names := make([]string, 0, len(f.secrets))
+		for name := range f.secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !f.ignorePaging {
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+			start := min((page-1)*perPage, len(names))
+			names = names[start:min(start+perPage, len(names))]
+		}
+		secrets := make(map[string]map[string]string, len(names))
+		for _, name := range names {
+			secrets[name] = map[string]string{"raw": f.secrets[name]}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"secrets": secrets})
+	case r.Method == http.MethodPost && r.URL.Path == "/configs/config/secrets":
+		if r.URL.Query().Get("merge") != "true" {
+			http.Error(w, "replace not expected", http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			Secrets map[string]string `json:"secrets"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		for name, value := range body.Secrets {
+			f.secrets[name] = value
+		}
+		f.batches = append(f.batches, len(body.Secrets))
+	case r.Method == http.MethodDelete && r.URL.Path == "/configs/config/secret":
+		var body struct {
+			Name string `json:"name"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		delete(f.secrets, body.Name)
+		f.deleted = append(f.deleted, body.Name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestClient(t *testing.T, f *fakeDoppler) *DopplerClient {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c := &DopplerClient{Project: "web", Config: "prd", Token: "test-token", BaseURL: srv.URL}
+	require.NoError(t, c.Init(context.Background()))
+	return c
+}
+
+func manySecrets(n int) map[string]string {
+	secrets := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		secrets[fmt.Sprintf("SECRET_%04d", i)] = strconv.Itoa(i)
+	}
+	return secrets
+}
+
+func TestListSecretsPagination(t *testing.T) {
+	f := &fakeDoppler{secrets: manySecrets(2500)}
+	c := newTestClient(t, f)
+
+	names, err := c.ListSecrets(context.Background(), "")
+	require.NoError(t, err)
+	assert.Len(t, names, 2500)
+	assert.Equal(t, "SECRET_0000", names[0])
+	assert.Equal(t, 3, f.lists)
+}
+
+func TestListSecretsUnpaginatedAPI(t *testing.T) {
+	// An API that ignores page parameters must not be polled forever
+	f := &fakeDoppler{secrets: manySecrets(1200), ignorePaging: true}
+	c := newTestClient(t, f)
+
+	names, err := c.ListSecrets(context.Background(), "")
+	require.NoError(t, err)
+	assert.Len(t, names, 1200)
+	assert.Equal(t, 2, f.lists)
+}
+
+func TestWriteSecretPartialUpdate(t *testing.T) {
+	f := &fakeDoppler{secrets: map[string]string{
+		"API_KEY":         "same",
+		"DB_PASSWORD":     "old",
+		"LEGACY_TOKEN":    "remove-me",
+		"DOPPLER_PROJECT": "web",
+	}}
+	c := newTestClient(t, f)
+	c.BatchSize = 2
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"api_key":     "same",
+		"db_password": "new",
+		"redis_url":   "redis://cache",
+		"sentry_dsn":  "https://sentry.example.com/1",
+	})
+	_, err := c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "", payload)
+	require.NoError(t, err)
+
+	// Three changed secrets in batches of two; the unchanged one is not resent
+	assert.Equal(t, []int{2, 1}, f.batches)
+	assert.Equal(t, []string{"LEGACY_TOKEN"}, f.deleted)
+	assert.Equal(t, map[string]string{
+		"API_KEY":         "same",
+		"DB_PASSWORD":     "new",
+		"REDIS_URL":       "redis://cache",
+		"SENTRY_DSN":      "https://sentry.example.com/1",
+		"DOPPLER_PROJECT": "web",
+	}, f.secrets)
+
+	// A second identical write sends nothing
+	f.batches = nil
+	_, err = c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "", payload)
+	require.NoError(t, err)
+	assert.Empty(t, f.batches)
+}
+
+func TestWriteSecretMergeKeepsExisting(t *testing.T) {
+	f := &fakeDoppler{secrets: map[string]string{"LEGACY_TOKEN": "keep-me"}}
+	c := newTestClient(t, f)
+	merge := true
+	c.Merge = &merge
+
+	payload, _ := json.Marshal(map[string]interface{}{"api_key": "value"})
+	_, err := c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "", payload)
+	require.NoError(t, err)
+	assert.Empty(t, f.deleted)
+	assert.Equal(t, "keep-me", f.secrets["LEGACY_TOKEN"])
+}
+
+func TestCheckToken(t *testing.T) {
+	c := newTestClient(t, &fakeDoppler{})
+	require.NoError(t, c.CheckToken(context.Background()))
+
+	c.Token = "revoked"
+	assert.ErrorContains(t, c.CheckToken(context.Background()), "status=401")
+}
+
+func TestWriteSecretRotationOverlap(t *testing.T) {
+	f := &fakeDoppler{secrets: map[string]string{
+		"API_KEY":     "same",
+		"DB_PASSWORD": "old",
+		"LEGACY":      "remove-me",
+	}}
+	c := newTestClient(t, f)
+	c.RotationOverlap = "1h"
+
+	payload, _ := json.Marshal(map[string]interface{}{"api_key": "same", "db_password": "new"})
+	_, err := c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "", payload)
+	require.NoError(t, err)
+
+	// The previous value is kept beside the new one, and its expiry recorded
+	assert.Equal(t, "new", f.secrets["DB_PASSWORD"])
+	assert.Equal(t, "old", f.secrets["DB_PASSWORD_OLD"])
+	assert.NotContains(t, f.secrets, "API_KEY_OLD")
+	assert.Equal(t, []string{"LEGACY"}, f.deleted)
+	var expires map[string]time.Time
+	require.NoError(t, json.Unmarshal([]byte(f.secrets[rotationStateName]), &expires))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires["DB_PASSWORD"], time.Minute)
+
+	// Replacing the config leaves the previous values alone during the overlap
+	f.deleted = nil
+	_, err = c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "", payload)
+	require.NoError(t, err)
+	assert.Empty(t, f.deleted)
+	assert.Contains(t, f.secrets, "DB_PASSWORD_OLD")
+
+	// Once the overlap has passed the copies and the state are removed
+	f.secrets[rotationStateName] = fmt.Sprintf(`{"DB_PASSWORD": %q}`, time.Now().Add(-time.Minute).Format(time.RFC3339))
+	_, err = c.WriteSecret(context.Background(), metav1.ObjectMeta{}, "", payload)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DB_PASSWORD_OLD", rotationStateName}, f.deleted)
+	assert.Equal(t, map[string]string{"API_KEY": "same", "DB_PASSWORD": "new"}, f.secrets)
+}
+
+func TestPlanRotation(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	current := map[string]string{
+		"TOKEN":           "v2",
+		"TOKEN_OLD":       "v1",
+		"SIGNING_KEY":     "k1",
+		"SIGNING_KEY_OLD": "k0",
+		rotationStateName: `{"TOKEN":"2026-10-01T13:00:00Z","SIGNING_KEY":"2026-10-01T11:00:00Z"}`,
+	}
+
+	// Rotating again within the overlap replaces the copy and restarts it;
+	// expired copies are dropped
+	plan, err := planRotation(current, map[string]string{"TOKEN": "v3", "SIGNING_KEY": "k1"}, map[string]string{"TOKEN": "v3"}, time.Hour, now)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"TOKEN_OLD":       "v2",
+		rotationStateName: `{"TOKEN":"2026-10-01T13:00:00Z"}`,
+	}, plan.writes)
+	assert.Equal(t, []string{"SIGNING_KEY_OLD"}, plan.deletes)
+	assert.True(t, plan.managed["TOKEN_OLD"])
+	assert.True(t, plan.managed["SIGNING_KEY_OLD"])
+
+	// New secrets have no previous value to keep, and copies never
+	// overwrite secrets of the payload
+	plan, err = planRotation(map[string]string{"TOKEN": "v1"}, map[string]string{"TOKEN": "v2", "TOKEN_OLD": "mine", "NEW": "x"}, map[string]string{"TOKEN": "v2", "NEW": "x"}, time.Hour, now)
+	require.NoError(t, err)
+	assert.Empty(t, plan.writes)
+	assert.Empty(t, plan.deletes)
+
+	_, err = planRotation(map[string]string{rotationStateName: "garbage"}, nil, nil, time.Hour, now)
+	assert.ErrorContains(t, err, "failed to parse VSS_ROTATION_EXPIRES")
+}
+
+func TestVerifySecret(t *testing.T) {
+	f := &fakeDoppler{secrets: map[string]string{"API_KEY": "value", "OTHER": "x"}}
+	c := newTestClient(t, f)
+
+	ok, err := c.VerifySecret(context.Background(), "", []byte(`{"api_key": "value", "empty": ""}`))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	f.secrets["API_KEY"] = "corrupted"
+	ok, err = c.VerifySecret(context.Background(), "", []byte(`{"api_key": "value"}`))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
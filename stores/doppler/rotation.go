@@ -0,0 +1,87 @@
+package doppler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// previousSuffix names the secret holding a rotated secret's previous value
+	previousSuffix = "_OLD"
+	// rotationStateName is the secret recording when each previous value
+	// expires, as a JSON object of secret name to RFC 3339 time
+	rotationStateName = "VSS_ROTATION_EXPIRES"
+)
+
+// rotationPlan is the work that keeps previous values during a rotation
+// overlap, on top of the secrets a write changes
+type rotationPlan struct {
+	// writes holds the <NAME>_OLD copies and the updated rotation state
+	writes map[string]string
+	// deletes lists the copies whose overlap has passed
+	deletes []string
+	// managed marks the copies and the rotation state, which are left to
+	// the plan rather than removed as secrets missing from the payload
+	managed map[string]bool
+}
+
+// planRotation keeps the current value of each secret a write changes as
+// <NAME>_OLD until overlap has passed, and drops the copies that expired by
+// now. desired is the full payload, whose own secrets are never overwritten.
+func planRotation(current, desired, changed map[string]string, overlap time.Duration, now time.Time) (rotationPlan, error) {
+	plan := rotationPlan{writes: map[string]string{}, managed: map[string]bool{}}
+
+	expires := map[string]time.Time{}
+	state, hasState := current[rotationStateName]
+	if hasState {
+		if err := json.Unmarshal([]byte(state), &expires); err != nil {
+			return plan, fmt.Errorf("failed to parse %s: %w", rotationStateName, err)
+		}
+	}
+
+	for name, value := range changed {
+		prev, ok := current[name]
+		if !ok || prev == value {
+			continue
+		}
+		if _, taken := desired[name+previousSuffix]; taken {
+			continue
+		}
+		plan.writes[name+previousSuffix] = prev
+		expires[name] = now.Add(overlap)
+	}
+
+	for name, at := range expires {
+		if _, taken := desired[name+previousSuffix]; taken {
+			delete(expires, name)
+			continue
+		}
+		plan.managed[name+previousSuffix] = true
+		if now.Before(at) {
+			continue
+		}
+		if _, ok := current[name+previousSuffix]; ok {
+			plan.deletes = append(plan.deletes, name+previousSuffix)
+		}
+		delete(expires, name)
+	}
+	sort.Strings(plan.deletes)
+
+	plan.managed[rotationStateName] = true
+	if len(expires) == 0 {
+		if hasState {
+			plan.deletes = append(plan.deletes, rotationStateName)
+		}
+		return plan, nil
+	}
+	b, err := json.Marshal(expires)
+	if err != nil {
+		return plan, err
+	}
+	if string(b) != state {
+		plan.writes[rotationStateName] = string(b)
+	}
+	return plan, nil
+}
@@ -34,6 +34,12 @@ type GitHubClient struct {
 	Org   bool   `yaml:"org,omitempty" json:"org,omitempty"`
 	Merge *bool  `yaml:"merge,omitempty" json:"merge,omitempty"`
 
+	// Visibility sets which repositories can use org secrets: all (the
+	// default), private (private and internal repositories) or selected
+	// (SelectedRepos)
+	Visibility    string   `yaml:"visibility,omitempty" json:"visibility,omitempty"`
+	SelectedRepos []string `yaml:"selectedRepos,omitempty" json:"selectedRepos,omitempty"`
+
 	InstallId        int    `yaml:"installId,omitempty" json:"installId,omitempty"`
 	AppId            int    `yaml:"appId,omitempty" json:"appId,omitempty"`
 	PrivateKeyPath   string `yaml:"privateKeyPath,omitempty" json:"privateKeyPath,omitempty"`
@@ -59,6 +65,11 @@ func (in *GitHubClient) DeepCopyInto(out *GitHubClient) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.SelectedRepos != nil {
+		in, out := &in.SelectedRepos, &out.SelectedRepos
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubClient.
@@ -89,6 +100,21 @@ func (c *GitHubClient) Validate() error {
 	if c.Repo == "" && !c.Org {
 		return errors.New("either repo or org is required")
 	}
+	if (c.Visibility != "" || len(c.SelectedRepos) > 0) && !c.Org {
+		return errors.New("visibility and selectedRepos only apply to org secrets")
+	}
+	switch c.Visibility {
+	case "", "all", "private":
+		if len(c.SelectedRepos) > 0 {
+			return errors.New("selectedRepos requires visibility selected")
+		}
+	case "selected":
+		if len(c.SelectedRepos) == 0 {
+			return errors.New("visibility selected requires selectedRepos")
+		}
+	default:
+		return fmt.Errorf("visibility must be all, private or selected, got %q", c.Visibility)
+	}
 	return nil
 }
 
@@ -488,26 +514,7 @@ func (g *GitHubClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 		}
 
 		err = g.withRetry(ctx, fmt.Sprintf("WriteSecret-%s", k), func() error {
-			var err error
-			if g.Org {
-				esecret.Visibility = "all"
-				_, err = g.client.Actions.CreateOrUpdateOrgSecret(ctx, g.Owner, esecret)
-			} else if g.Env != "" {
-				rid, err := g.RepoID(ctx)
-				if err != nil {
-					return err
-				}
-				_, err = g.client.Actions.CreateOrUpdateEnvSecret(ctx, int(rid), g.Env, esecret)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("environment %s does not exist", g.Env)
-				}
-			} else {
-				_, err = g.client.Actions.CreateOrUpdateRepoSecret(ctx, g.Owner, g.Repo, esecret)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("repo %s does not exist", g.Repo)
-				}
-			}
-			return err
+			return g.putScopeSecret(ctx, esecret)
 		})
 		if err != nil {
 			writeErrs[k] = err
@@ -520,6 +527,9 @@ func (g *GitHubClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 	return nil, nil
 }
 
+// DeleteSecret deletes a secret by name from the client's scope (the org,
+// the repo or the repo's environment). Given the scope's own path, as sync
+// deletes are, or "", it deletes every secret in the scope.
 func (g *GitHubClient) DeleteSecret(ctx context.Context, secret string) error {
 	l := log.WithFields(log.Fields{
 		"action": "DeleteSecret",
@@ -529,32 +539,17 @@ func (g *GitHubClient) DeleteSecret(ctx context.Context, secret string) error {
 	l.Trace("start")
 	defer l.Trace("end")
 
-	secretList, err := g.ListSecrets(ctx, "")
-	if err != nil {
-		return err
+	secretList := []string{secret}
+	if secret == "" || secret == g.GetPath() {
+		var err error
+		if secretList, err = g.ListSecrets(ctx, ""); err != nil {
+			return err
+		}
 	}
 
 	for _, s := range secretList {
-		err = g.withRetry(ctx, fmt.Sprintf("DeleteSecret-%s", s), func() error {
-			var err error
-			if g.Org {
-				_, err = g.client.Actions.DeleteOrgSecret(ctx, g.Owner, s)
-			} else if g.Env != "" {
-				rid, err := g.RepoID(ctx)
-				if err != nil {
-					return err
-				}
-				_, err = g.client.Actions.DeleteEnvSecret(ctx, int(rid), g.Env, s)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("environment %s does not exist", g.Env)
-				}
-			} else {
-				_, err = g.client.Actions.DeleteRepoSecret(ctx, g.Owner, g.Repo, s)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("repo %s does not exist", g.Repo)
-				}
-			}
-			return err
+		err := g.withRetry(ctx, fmt.Sprintf("DeleteSecret-%s", s), func() error {
+			return g.deleteScopeSecret(ctx, s)
 		})
 		if err != nil {
 			return err
@@ -563,6 +558,7 @@ func (g *GitHubClient) DeleteSecret(ctx context.Context, secret string) error {
 	return nil
 }
 
+// ListSecrets lists the names of the secrets in the client's scope
 func (g *GitHubClient) ListSecrets(ctx context.Context, p string) ([]string, error) {
 	l := log.WithFields(log.Fields{
 		"action": "ListSecrets",
@@ -572,55 +568,202 @@ func (g *GitHubClient) ListSecrets(ctx context.Context, p string) ([]string, err
 	defer l.Trace("end")
 
 	var secretsList []string
-	opt := &github.ListOptions{}
+	opt := &github.ListOptions{PerPage: 100}
 
 	for {
 		var secrets *github.Secrets
+		var resp *github.Response
 		err := g.withRetry(ctx, "ListSecrets", func() error {
 			var err error
-			var resp *github.Response
-
-			if g.Org {
-				secrets, resp, err = g.client.Actions.ListOrgSecrets(ctx, g.Owner, opt)
-			} else if g.Env != "" {
-				rid, err := g.RepoID(ctx)
-				if err != nil {
-					return err
-				}
-				secrets, resp, err = g.client.Actions.ListEnvSecrets(ctx, int(rid), g.Env, opt)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("environment %s does not exist", g.Env)
-				}
-			} else {
-				secrets, resp, err = g.client.Actions.ListRepoSecrets(ctx, g.Owner, g.Repo, opt)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("repo %s does not exist", g.Repo)
-				}
-			}
+			secrets, resp, err = g.listScopeSecrets(ctx, opt)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range secrets.Secrets {
+			secretsList = append(secretsList, s.Name)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return secretsList, nil
+}
+
+// putScopeSecret creates or updates a secret in the client's scope. Org
+// secrets get the client's visibility, all repositories by default.
+func (g *GitHubClient) putScopeSecret(ctx context.Context, es *github.EncryptedSecret) error {
+	switch {
+	case g.Org:
+		es.Visibility = g.Visibility
+		if es.Visibility == "" {
+			es.Visibility = "all"
+		}
+		if es.Visibility == "selected" {
+			ids, err := g.selectedRepoIDs(ctx)
 			if err != nil {
 				return err
 			}
+			es.SelectedRepositoryIDs = ids
+		}
+		_, err := g.client.Actions.CreateOrUpdateOrgSecret(ctx, g.Owner, es)
+		return err
+	case g.Env != "":
+		rid, err := g.RepoID(ctx)
+		if err != nil {
+			return g.scopeError(err)
+		}
+		_, err = g.client.Actions.CreateOrUpdateEnvSecret(ctx, int(rid), g.Env, es)
+		return g.scopeError(err)
+	default:
+		_, err := g.client.Actions.CreateOrUpdateRepoSecret(ctx, g.Owner, g.Repo, es)
+		return g.scopeError(err)
+	}
+}
 
-			for _, s := range secrets.Secrets {
-				secretsList = append(secretsList, s.Name)
-			}
+// deleteScopeSecret deletes a secret from the client's scope; a secret that
+// does not exist in a scope that does is already deleted
+func (g *GitHubClient) deleteScopeSecret(ctx context.Context, name string) error {
+	var resp *github.Response
+	var err error
+	switch {
+	case g.Org:
+		resp, err = g.client.Actions.DeleteOrgSecret(ctx, g.Owner, name)
+	case g.Env != "":
+		var rid int64
+		if rid, err = g.RepoID(ctx); err != nil {
+			return g.scopeError(err)
+		}
+		resp, err = g.client.Actions.DeleteEnvSecret(ctx, int(rid), g.Env, name)
+	default:
+		resp, err = g.client.Actions.DeleteRepoSecret(ctx, g.Owner, g.Repo, name)
+	}
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound && g.scopeExists(ctx) {
+		return nil
+	}
+	return g.scopeError(err)
+}
+
+// listScopeSecrets lists a page of the secrets in the client's scope
+func (g *GitHubClient) listScopeSecrets(ctx context.Context, opt *github.ListOptions) (*github.Secrets, *github.Response, error) {
+	switch {
+	case g.Org:
+		return g.client.Actions.ListOrgSecrets(ctx, g.Owner, opt)
+	case g.Env != "":
+		rid, err := g.RepoID(ctx)
+		if err != nil {
+			return nil, nil, g.scopeError(err)
+		}
+		secrets, resp, err := g.client.Actions.ListEnvSecrets(ctx, int(rid), g.Env, opt)
+		return secrets, resp, g.scopeError(err)
+	default:
+		secrets, resp, err := g.client.Actions.ListRepoSecrets(ctx, g.Owner, g.Repo, opt)
+		return secrets, resp, g.scopeError(err)
+	}
+}
+
+// CheckScope lists a secret of the client's scope, failing if the org, repo
+// or environment does not exist or the App cannot read its secrets
+func (g *GitHubClient) CheckScope(ctx context.Context) error {
+	_, _, err := g.listScopeSecrets(ctx, &github.ListOptions{PerPage: 1})
+	return err
+}
+
+// scopeExists reports whether the client's scope exists, to tell a missing
+// secret from a missing repo or environment after a 404
+func (g *GitHubClient) scopeExists(ctx context.Context) bool {
+	return g.CheckScope(ctx) == nil
+}
+
+// scopeError names the missing repo or environment behind a 404
+func (g *GitHubClient) scopeError(err error) error {
+	var ghErr *github.ErrorResponse
+	if g.Org || !errors.As(err, &ghErr) || ghErr.Response == nil || ghErr.Response.StatusCode != http.StatusNotFound {
+		return err
+	}
+	if g.Env != "" {
+		return fmt.Errorf("environment %s does not exist in repo %s", g.Env, g.Repo)
+	}
+	return fmt.Errorf("repo %s does not exist", g.Repo)
+}
 
-			if resp.NextPage == 0 {
-				opt.Page = 0 // Signal to break the outer loop
+// selectedRepoIDs resolves SelectedRepos to repository IDs
+func (g *GitHubClient) selectedRepoIDs(ctx context.Context) (github.SelectedRepoIDs, error) {
+	ids := make(github.SelectedRepoIDs, 0, len(g.SelectedRepos))
+	for _, name := range g.SelectedRepos {
+		var repo *github.Repository
+		err := g.withRetry(ctx, "SelectedRepoIDs", func() error {
+			var err error
+			repo, _, err = g.client.Repositories.Get(ctx, g.Owner, name)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up selected repo %s: %w", name, err)
+		}
+		ids = append(ids, repo.GetID())
+	}
+	return ids, nil
+}
+
+// RepoInfo describes a repository returned by ListOrgRepositories
+type RepoInfo struct {
+	Name     string
+	Topics   []string
+	Archived bool
+}
+
+// ListOrgRepositories lists the repositories in the Owner org. When team is set,
+// only repositories the team (by slug) has access to are returned.
+func (g *GitHubClient) ListOrgRepositories(ctx context.Context, team string) ([]RepoInfo, error) {
+	l := log.WithFields(log.Fields{
+		"action": "ListOrgRepositories",
+		"driver": g.Driver(),
+		"owner":  g.Owner,
+		"team":   team,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	var repos []RepoInfo
+	opt := github.ListOptions{PerPage: 100}
+
+	for {
+		var page []*github.Repository
+		var resp *github.Response
+		err := g.withRetry(ctx, "ListOrgRepositories", func() error {
+			var err error
+			if team != "" {
+				page, resp, err = g.client.Teams.ListTeamReposBySlug(ctx, g.Owner, team, &opt)
 			} else {
-				opt.Page = resp.NextPage
+				page, resp, err = g.client.Repositories.ListByOrg(ctx, g.Owner, &github.RepositoryListByOrgOptions{
+					Type:        "all",
+					ListOptions: opt,
+				})
 			}
-			return nil
+			return err
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		if opt.Page == 0 {
+		for _, r := range page {
+			repos = append(repos, RepoInfo{
+				Name:     r.GetName(),
+				Topics:   r.Topics,
+				Archived: r.GetArchived(),
+			})
+		}
+
+		if resp.NextPage == 0 {
 			break
 		}
+		opt.Page = resp.NextPage
 	}
-	return secretsList, nil
+	return repos, nil
 }
 
 func (g *GitHubClient) GetOrgPublicKey(ctx context.Context) (*github.PublicKey, error) {
@@ -743,6 +886,11 @@ func (c *GitHubClient) SetDefaults(cfg any) error {
 	if c.Env == "" && nc.Env != "" {
 		c.Env = nc.Env
 	}
+	// A default visibility only applies to org secrets that set none
+	if c.Org && c.Visibility == "" && nc.Visibility != "" {
+		c.Visibility = nc.Visibility
+		c.SelectedRepos = nc.SelectedRepos
+	}
 	if c.AppId == 0 && nc.AppId != 0 {
 		c.AppId = nc.AppId
 	}
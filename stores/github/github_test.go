@@ -2,16 +2,23 @@ package github
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-github/v62/github"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // mockTransport implements http.RoundTripper for testing
@@ -294,3 +301,123 @@ func TestRateLimitedTransport_RoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateVisibility(t *testing.T) {
+	for _, tc := range []struct {
+		client GitHubClient
+		err    string
+	}{
+		{GitHubClient{Owner: "acme", Org: true}, ""},
+		{GitHubClient{Owner: "acme", Org: true, Visibility: "selected", SelectedRepos: []string{"api"}}, ""},
+		{GitHubClient{Owner: "acme", Org: true, Visibility: "selected"}, "visibility selected requires selectedRepos"},
+		{GitHubClient{Owner: "acme", Org: true, Visibility: "private", SelectedRepos: []string{"api"}}, "selectedRepos requires visibility selected"},
+		{GitHubClient{Owner: "acme", Org: true, Visibility: "public"}, `visibility must be all, private or selected, got "public"`},
+		{GitHubClient{Owner: "acme", Repo: "api", Visibility: "all"}, "visibility and selectedRepos only apply to org secrets"},
+	} {
+		err := tc.client.Validate()
+		if tc.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tc.err)
+		}
+	}
+}
+
+// fakeGitHub serves the Actions secrets API for one org, one repo (ID 42,
+// also listed as "web" with ID 43) and its "prod" environment
+type fakeGitHub struct {
+	secrets map[string]map[string]string // scope path -> name -> visibility
+	puts    []string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	path := r.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/public-key"):
+		fmt.Fprintf(w, `{"key_id":"k1","key":%q}`, key)
+		return
+	case path == "/repos/acme/api":
+		fmt.Fprint(w, `{"id":42}`)
+		return
+	case path == "/repos/acme/web":
+		fmt.Fprint(w, `{"id":43}`)
+		return
+	}
+	scope, name := path, ""
+	if i := strings.LastIndex(path, "/secrets/"); i >= 0 {
+		scope, name = path[:i+len("/secrets")], path[i+len("/secrets/"):]
+	}
+	secrets, ok := f.secrets[scope]
+	if !ok {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		var list []string
+		for n, vis := range secrets {
+			list = append(list, fmt.Sprintf(`{"name":%q,"visibility":%q}`, n, vis))
+		}
+		fmt.Fprintf(w, `{"total_count":%d,"secrets":[%s]}`, len(list), strings.Join(list, ","))
+	case http.MethodPut:
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		vis, _ := body["visibility"].(string)
+		secrets[name] = vis
+		f.puts = append(f.puts, fmt.Sprintf("%s/%s %v %v", scope, name, vis, body["selected_repository_ids"]))
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := secrets[name]; !ok {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		delete(secrets, name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newFakeGitHubClient(t *testing.T, f *fakeGitHub, client *GitHubClient) *GitHubClient {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	client.client = github.NewClient(nil)
+	base, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+	client.client.BaseURL = base
+	return client
+}
+
+func TestOrgAndEnvironmentSecrets(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeGitHub{secrets: map[string]map[string]string{
+		"/orgs/acme/actions/secrets":                 {"UNMANAGED": "all"},
+		"/repositories/42/environments/prod/secrets": {"OLD": ""},
+	}}
+
+	org := newFakeGitHubClient(t, f, &GitHubClient{Owner: "acme", Org: true, Visibility: "selected", SelectedRepos: []string{"api", "web"}})
+	_, err := org.WriteSecret(ctx, metav1.ObjectMeta{}, "acme", []byte(`{"DB_PASSWORD":"s3cret"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/orgs/acme/actions/secrets/DB_PASSWORD selected [42 43]"}, f.puts)
+
+	// Deleting by name leaves the org's other secrets alone
+	require.NoError(t, org.DeleteSecret(ctx, "DB_PASSWORD"))
+	require.NoError(t, org.DeleteSecret(ctx, "DB_PASSWORD"), "a deleted secret is already gone")
+	assert.Equal(t, map[string]string{"UNMANAGED": "all"}, f.secrets["/orgs/acme/actions/secrets"])
+
+	env := newFakeGitHubClient(t, f, &GitHubClient{Owner: "acme", Repo: "api", Env: "prod"})
+	_, err = env.WriteSecret(ctx, metav1.ObjectMeta{}, "api", []byte(`{"API_TOKEN":"t0ken"}`))
+	require.NoError(t, err)
+	names, err := env.ListSecrets(ctx, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"OLD", "API_TOKEN"}, names)
+
+	// Sync deletes pass the scope's path and empty the environment
+	require.NoError(t, env.DeleteSecret(ctx, env.GetPath()))
+	assert.Empty(t, f.secrets["/repositories/42/environments/prod/secrets"])
+
+	missing := newFakeGitHubClient(t, f, &GitHubClient{Owner: "acme", Repo: "api", Env: "staging"})
+	_, err = missing.ListSecrets(ctx, "")
+	assert.EqualError(t, err, "environment staging does not exist in repo api")
+	assert.EqualError(t, missing.DeleteSecret(ctx, "API_TOKEN"), "environment staging does not exist in repo api")
+}
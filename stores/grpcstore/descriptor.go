@@ -0,0 +1,112 @@
+package grpcstore
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ServiceName is the fully-qualified name of the SecretStore service defined in
+// proto/vss/secretstore/v1/secretstore.proto
+const ServiceName = "vss.secretstore.v1.SecretStore"
+
+// secretStoreFile describes secretstore.proto. It is built here rather than
+// generated so the driver needs no protoc toolchain; keep the two in sync.
+var secretStoreFile = mustBuildFile()
+
+func mustBuildFile() protoreflect.FileDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  label.Enum(),
+		}
+	}
+	message := func(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+	}
+	method := func(name string) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".vss.secretstore.v1." + name + "Request"),
+			OutputType: proto.String(".vss.secretstore.v1." + name + "Response"),
+		}
+	}
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+	byt := descriptorpb.FieldDescriptorProto_TYPE_BYTES
+
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("vss/secretstore/v1/secretstore.proto"),
+		Package: proto.String("vss.secretstore.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("PutRequest", field("path", 1, str, false), field("value", 2, byt, false)),
+			message("PutResponse"),
+			message("GetRequest", field("path", 1, str, false)),
+			message("GetResponse", field("value", 1, byt, false)),
+			message("ListRequest", field("path", 1, str, false), field("page_token", 2, str, false)),
+			message("ListResponse", field("names", 1, str, true), field("next_page_token", 2, str, false)),
+			message("DeleteRequest", field("path", 1, str, false)),
+			message("DeleteResponse"),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name:   proto.String("SecretStore"),
+			Method: []*descriptorpb.MethodDescriptorProto{method("Put"), method("Get"), method("List"), method("Delete")},
+		}},
+	}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return fd
+}
+
+// newMessage returns an empty message of the named type, e.g. "PutRequest"
+func newMessage(name string) *dynamicpb.Message {
+	return dynamicpb.NewMessage(secretStoreFile.Messages().ByName(protoreflect.Name(name)))
+}
+
+// setField sets a string, bytes or repeated string field on m
+func setField(m *dynamicpb.Message, name string, value any) {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	switch v := value.(type) {
+	case string:
+		m.Set(fd, protoreflect.ValueOfString(v))
+	case []byte:
+		m.Set(fd, protoreflect.ValueOfBytes(v))
+	case []string:
+		list := m.NewField(fd).List()
+		for _, s := range v {
+			list.Append(protoreflect.ValueOfString(s))
+		}
+		m.Set(fd, protoreflect.ValueOfList(list))
+	}
+}
+
+func stringField(m *dynamicpb.Message, name string) string {
+	return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name))).String()
+}
+
+func bytesField(m *dynamicpb.Message, name string) []byte {
+	return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name))).Bytes()
+}
+
+func stringsField(m *dynamicpb.Message, name string) []string {
+	list := m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name))).List()
+	out := make([]string, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		out = append(out, list.Get(i).String())
+	}
+	return out
+}
+
+// fullMethod returns the gRPC method path for a SecretStore RPC, e.g. "/vss.secretstore.v1.SecretStore/Put"
+func fullMethod(name string) string {
+	return "/" + ServiceName + "/" + name
+}
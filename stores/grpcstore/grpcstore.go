@@ -0,0 +1,324 @@
+package grpcstore
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jbcom/secretsync/internal/fips"
+	"github.com/jbcom/secretsync/internal/spiffe"
+	"github.com/jbcom/secretsync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxListPages guards against servers that never stop returning page tokens
+const maxListPages = 10000
+
+// GRPCClient writes secrets to any store that serves the SecretStore gRPC
+// service (proto/vss/secretstore/v1/secretstore.proto). Teams with bespoke
+// stores implement that service instead of adding a driver to this repo.
+type GRPCClient struct {
+	// Address is the server's host:port or any gRPC target, e.g. dns:///secrets.internal:8443
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+	// Path is the destination path sent with each request
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Insecure disables TLS; only use it for servers on localhost or a sidecar
+	Insecure bool `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	// CAFile verifies the server certificate; empty uses the system roots
+	CAFile     string `yaml:"caFile,omitempty" json:"caFile,omitempty"`
+	ServerName string `yaml:"serverName,omitempty" json:"serverName,omitempty"`
+	// SPIFFE authenticates with the workload's X.509 SVID (mTLS) and verifies
+	// the server's SVID instead of its hostname
+	SPIFFE *spiffe.Config `yaml:"spiffe,omitempty" json:"spiffe,omitempty"`
+
+	// Metadata is sent with every call. Values accept ${VAR} references that are
+	// expanded from the environment, e.g. authorization: "Bearer ${STORE_TOKEN}".
+	Metadata map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	// Timeout bounds each call (default 30s)
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	conn *grpc.ClientConn `yaml:"-" json:"-"`
+}
+
+// DeepCopyInto copies all properties from this object into another object of the same type
+func (in *GRPCClient) DeepCopyInto(out *GRPCClient) {
+	*out = *in
+	if in.Metadata != nil {
+		out.Metadata = make(map[string]string, len(in.Metadata))
+		for key, val := range in.Metadata {
+			out.Metadata[key] = val
+		}
+	}
+	out.SPIFFE = in.SPIFFE.DeepCopy()
+	// The connection is shared rather than copied; it is re-created by Init as needed
+	out.conn = in.conn
+}
+
+func (in *GRPCClient) DeepCopy() *GRPCClient {
+	if in == nil {
+		return nil
+	}
+	out := new(GRPCClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func NewClient(cfg *GRPCClient) (*GRPCClient, error) {
+	l := log.WithFields(log.Fields{
+		"action": "NewClient",
+	})
+	l.Trace("start")
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+	vc := cfg.DeepCopy()
+	l.Debugf("client created for address=%s path=%s", vc.Address, vc.Path)
+	l.Trace("end")
+	return vc, nil
+}
+
+// Validate the gRPC client configuration
+func (g *GRPCClient) Validate() error {
+	if g.Address == "" {
+		return errors.New("address is required")
+	}
+	if g.Path == "" {
+		return driver.ErrPathRequired
+	}
+	if g.Insecure && (g.CAFile != "" || g.SPIFFE != nil) {
+		return errors.New("caFile and spiffe cannot be used with insecure")
+	}
+	if g.SPIFFE != nil {
+		if g.CAFile != "" {
+			return errors.New("caFile cannot be used with spiffe; the trust bundle verifies the server")
+		}
+		if err := g.SPIFFE.Validate(); err != nil {
+			return err
+		}
+	}
+	if g.Timeout != "" {
+		if _, err := time.ParseDuration(g.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", g.Timeout, err)
+		}
+	}
+	return nil
+}
+
+// Meta returns metadata for the gRPC client
+func (g *GRPCClient) Meta() map[string]any {
+	jd, err := json.Marshal(g)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(jd, &m); err != nil {
+		return nil
+	}
+	// Remove sensitive data; call metadata usually carries credentials
+	delete(m, "metadata")
+	return m
+}
+
+// Init validates the configuration and connects to the server
+func (g *GRPCClient) Init(ctx context.Context) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if g.conn != nil {
+		return nil
+	}
+	creds, err := g.transportCredentials()
+	if err != nil {
+		return err
+	}
+	conn, err := grpc.NewClient(g.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC client for %s: %w", g.Address, err)
+	}
+	g.conn = conn
+	return nil
+}
+
+func (g *GRPCClient) transportCredentials() (credentials.TransportCredentials, error) {
+	if g.Insecure {
+		return insecure.NewCredentials(), nil
+	}
+	if g.SPIFFE != nil {
+		tlsConfig, err := g.SPIFFE.ClientTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		return credentials.NewTLS(tlsConfig), nil
+	}
+	if g.CAFile != "" {
+		return credentials.NewClientTLSFromFile(g.CAFile, g.ServerName)
+	}
+	return credentials.NewTLS(fips.TLSConfig(&tls.Config{ServerName: g.ServerName, MinVersion: tls.VersionTLS12})), nil
+}
+
+// Driver returns the driver name
+func (g *GRPCClient) Driver() driver.DriverName {
+	return driver.DriverNameGRPC
+}
+
+// GetPath returns the path
+func (g *GRPCClient) GetPath() string {
+	return g.Path
+}
+
+// invoke calls a SecretStore method with the configured metadata and timeout
+func (g *GRPCClient) invoke(ctx context.Context, method string, req, resp any) error {
+	if g.conn == nil {
+		return errors.New("gRPC client is not initialized")
+	}
+	ctx, cancel := context.WithTimeout(ctx, parseDurationOr(g.Timeout, 30*time.Second))
+	defer cancel()
+	if len(g.Metadata) > 0 {
+		md := make(metadata.MD, len(g.Metadata))
+		for key, value := range g.Metadata {
+			md.Set(key, os.ExpandEnv(value))
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+	return g.conn.Invoke(ctx, fullMethod(method), req, resp)
+}
+
+// GetSecret retrieves a secret from the server
+func (g *GRPCClient) GetSecret(ctx context.Context, path string) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "GetSecret",
+		"path":   path,
+		"store":  "grpc",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	req := newMessage("GetRequest")
+	setField(req, "path", path)
+	resp := newMessage("GetResponse")
+	if err := g.invoke(ctx, "Get", req, resp); err != nil {
+		l.Debugf("error: %v", err)
+		return nil, err
+	}
+	return bytesField(resp, "value"), nil
+}
+
+// WriteSecret creates or replaces the secret on the server
+func (g *GRPCClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secrets []byte) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "WriteSecret",
+		"path":   path,
+		"store":  "grpc",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	req := newMessage("PutRequest")
+	setField(req, "path", path)
+	setField(req, "value", secrets)
+	if err := g.invoke(ctx, "Put", req, newMessage("PutResponse")); err != nil {
+		l.WithError(err).Error("failed to write secret")
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// DeleteSecret deletes a secret from the server; secrets that do not exist are ignored
+func (g *GRPCClient) DeleteSecret(ctx context.Context, path string) error {
+	req := newMessage("DeleteRequest")
+	setField(req, "path", path)
+	err := g.invoke(ctx, "Delete", req, newMessage("DeleteResponse"))
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+// ListSecrets lists the secrets under path, following page tokens to the last page
+func (g *GRPCClient) ListSecrets(ctx context.Context, path string) ([]string, error) {
+	var names []string
+	token := ""
+	for page := 1; ; page++ {
+		if page > maxListPages {
+			return nil, fmt.Errorf("failed to list secrets: more than %d pages", maxListPages)
+		}
+		req := newMessage("ListRequest")
+		setField(req, "path", path)
+		setField(req, "page_token", token)
+		resp := newMessage("ListResponse")
+		if err := g.invoke(ctx, "List", req, resp); err != nil {
+			return nil, err
+		}
+		names = append(names, stringsField(resp, "names")...)
+
+		next := stringField(resp, "next_page_token")
+		if next == "" {
+			return names, nil
+		}
+		if next == token {
+			return nil, fmt.Errorf("failed to list secrets: server returned page token %q twice", next)
+		}
+		token = next
+	}
+}
+
+// SetDefaults sets default values for the gRPC client
+func (g *GRPCClient) SetDefaults(defaults any) error {
+	jd, err := json.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	nc := &GRPCClient{}
+	if err := json.Unmarshal(jd, nc); err != nil {
+		return err
+	}
+	if g.Address == "" {
+		g.Address = nc.Address
+	}
+	if !g.Insecure {
+		g.Insecure = nc.Insecure
+	}
+	if g.CAFile == "" {
+		g.CAFile = nc.CAFile
+	}
+	if g.ServerName == "" {
+		g.ServerName = nc.ServerName
+	}
+	if g.Metadata == nil {
+		g.Metadata = nc.Metadata
+	}
+	if g.SPIFFE == nil {
+		g.SPIFFE = nc.SPIFFE
+	}
+	if g.Timeout == "" {
+		g.Timeout = nc.Timeout
+	}
+	return nil
+}
+
+// Close closes the connection to the server
+func (g *GRPCClient) Close() error {
+	if g.conn == nil {
+		return nil
+	}
+	err := g.conn.Close()
+	g.conn = nil
+	return err
+}
+
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,154 @@
+package grpcstore
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// memoryStore is a SecretStoreServer backed by a map, paging List results
+type memoryStore struct {
+	mu       sync.Mutex
+	secrets  map[string][]byte
+	pageSize int
+	lists    int
+	auth     []string
+}
+
+func (m *memoryStore) Put(ctx context.Context, path string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	m.auth = append(m.auth, md.Get("authorization")...)
+	m.secrets[path] = value
+	return nil
+}
+
+func (m *memoryStore) Get(ctx context.Context, path string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.secrets[path]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret %s not found", path)
+	}
+	return value, nil
+}
+
+func (m *memoryStore) List(ctx context.Context, path, pageToken string) ([]string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lists++
+	var names []string
+	for name := range m.secrets {
+		if strings.HasPrefix(name, path) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	start, _ := strconv.Atoi(pageToken)
+	end := min(start+m.pageSize, len(names))
+	next := ""
+	if end < len(names) {
+		next = strconv.Itoa(end)
+	}
+	return names[start:end], next, nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.secrets[path]; !ok {
+		return status.Errorf(codes.NotFound, "secret %s not found", path)
+	}
+	delete(m.secrets, path)
+	return nil
+}
+
+func newTestClient(t *testing.T, store *memoryStore) *GRPCClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	RegisterSecretStoreServer(srv, store)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	c, err := NewClient(&GRPCClient{
+		Address:  lis.Addr().String(),
+		Path:     "team/$1",
+		Insecure: true,
+		Metadata: map[string]string{"authorization": "Bearer ${TEST_STORE_TOKEN}"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.Init(context.Background()))
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestWriteAndGetSecret(t *testing.T) {
+	t.Setenv("TEST_STORE_TOKEN", "s3cret")
+	store := &memoryStore{secrets: map[string][]byte{}, pageSize: 10}
+	c := newTestClient(t, store)
+	ctx := context.Background()
+
+	payload := []byte(`{"password":"hunter2"}`)
+	_, err := c.WriteSecret(ctx, metav1.ObjectMeta{}, "team/db", payload)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer s3cret"}, store.auth)
+
+	got, err := c.GetSecret(ctx, "team/db")
+	require.NoError(t, err)
+	assert.Equal(t, payload, got)
+
+	_, err = c.GetSecret(ctx, "team/missing")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestListSecretsPagination(t *testing.T) {
+	store := &memoryStore{secrets: map[string][]byte{}, pageSize: 2}
+	for _, name := range []string{"team/a", "team/b", "team/c", "team/d", "team/e", "other/x"} {
+		store.secrets[name] = []byte("{}")
+	}
+	c := newTestClient(t, store)
+
+	names, err := c.ListSecrets(context.Background(), "team/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team/a", "team/b", "team/c", "team/d", "team/e"}, names)
+	assert.Equal(t, 3, store.lists)
+}
+
+func TestDeleteSecretIgnoresNotFound(t *testing.T) {
+	store := &memoryStore{secrets: map[string][]byte{"team/db": []byte("{}")}, pageSize: 10}
+	c := newTestClient(t, store)
+	ctx := context.Background()
+
+	require.NoError(t, c.DeleteSecret(ctx, "team/db"))
+	assert.Empty(t, store.secrets)
+	require.NoError(t, c.DeleteSecret(ctx, "team/db"))
+}
+
+func TestValidate(t *testing.T) {
+	assert.EqualError(t, (&GRPCClient{Path: "x"}).Validate(), "address is required")
+	assert.Error(t, (&GRPCClient{Address: "localhost:1", Path: "x", Timeout: "soon"}).Validate())
+	assert.Error(t, (&GRPCClient{Address: "localhost:1", Path: "x", Insecure: true, CAFile: "ca.pem"}).Validate())
+	assert.NoError(t, (&GRPCClient{Address: "localhost:1", Path: "x", Timeout: "5s"}).Validate())
+}
+
+func TestMetaOmitsMetadata(t *testing.T) {
+	c := &GRPCClient{Address: "localhost:1", Path: "x", Metadata: map[string]string{"authorization": "Bearer t"}}
+	meta := c.Meta()
+	assert.Equal(t, "localhost:1", meta["address"])
+	assert.NotContains(t, meta, "metadata")
+}
@@ -0,0 +1,76 @@
+package grpcstore
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// SecretStoreServer is the SecretStore service for stores written in Go.
+// Register it with RegisterSecretStoreServer; stores in other languages
+// implement proto/vss/secretstore/v1/secretstore.proto with their own codegen.
+type SecretStoreServer interface {
+	Put(ctx context.Context, path string, value []byte) error
+	// Get returns a codes.NotFound status error for paths that do not exist
+	Get(ctx context.Context, path string) ([]byte, error)
+	// List returns one page of names under path and the token of the next
+	// page, or "" on the last page
+	List(ctx context.Context, path, pageToken string) (names []string, nextPageToken string, err error)
+	Delete(ctx context.Context, path string) error
+}
+
+// RegisterSecretStoreServer registers srv as the SecretStore service on s
+func RegisterSecretStoreServer(s grpc.ServiceRegistrar, srv SecretStoreServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*SecretStoreServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Put", func(ctx context.Context, srv SecretStoreServer, req, resp *dynamicpb.Message) error {
+			return srv.Put(ctx, stringField(req, "path"), bytesField(req, "value"))
+		}),
+		unaryMethod("Get", func(ctx context.Context, srv SecretStoreServer, req, resp *dynamicpb.Message) error {
+			value, err := srv.Get(ctx, stringField(req, "path"))
+			setField(resp, "value", value)
+			return err
+		}),
+		unaryMethod("List", func(ctx context.Context, srv SecretStoreServer, req, resp *dynamicpb.Message) error {
+			names, next, err := srv.List(ctx, stringField(req, "path"), stringField(req, "page_token"))
+			setField(resp, "names", names)
+			setField(resp, "next_page_token", next)
+			return err
+		}),
+		unaryMethod("Delete", func(ctx context.Context, srv SecretStoreServer, req, resp *dynamicpb.Message) error {
+			return srv.Delete(ctx, stringField(req, "path"))
+		}),
+	},
+	Metadata: "vss/secretstore/v1/secretstore.proto",
+}
+
+// unaryMethod adapts a SecretStoreServer call to a gRPC method handler that
+// decodes <name>Request and encodes <name>Response
+func unaryMethod(name string, call func(ctx context.Context, srv SecretStoreServer, req, resp *dynamicpb.Message) error) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			in := newMessage(name + "Request")
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req any) (any, error) {
+				resp := newMessage(name + "Response")
+				if err := call(ctx, srv.(SecretStoreServer), req.(*dynamicpb.Message), resp); err != nil {
+					return nil, err
+				}
+				return resp, nil
+			}
+			if interceptor == nil {
+				return handler(ctx, in)
+			}
+			return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod(name)}, handler)
+		},
+	}
+}
@@ -11,8 +11,14 @@ import (
 	"io"
 	"net/http"
 	"net/http/httputil"
+	neturl "net/url"
 	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
 
+	"github.com/jbcom/secretsync/internal/spiffe"
 	"github.com/jbcom/secretsync/pkg/driver"
 	"github.com/jbcom/secretsync/pkg/kubesecret"
 	log "github.com/sirupsen/logrus"
@@ -28,6 +34,20 @@ type HTTPClient struct {
 
 	SuccessCodes []int `yaml:"successCodes,omitempty" json:"successCodes,omitempty"`
 
+	// Auth authenticates every request with bearer, basic or HMAC signing
+	Auth *HTTPAuth `yaml:"auth,omitempty" json:"auth,omitempty"`
+	// Retry retries failed requests; without it each request is sent once
+	Retry *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// Pagination pages through ListSecrets responses; without it the list
+	// endpoint must return a JSON array of names
+	Pagination *Pagination `yaml:"pagination,omitempty" json:"pagination,omitempty"`
+	// ResponseTemplate extracts the secret from GetSecret responses. It is a Go
+	// text/template executed against the decoded JSON body, e.g. {{json .data}}.
+	ResponseTemplate string `yaml:"responseTemplate,omitempty" json:"responseTemplate,omitempty"`
+	// SPIFFE authenticates with the workload's X.509 SVID (mTLS) and verifies
+	// the server's SVID, so no API token has to be distributed
+	SPIFFE *spiffe.Config `yaml:"spiffe,omitempty" json:"spiffe,omitempty"`
+
 	client *http.Client `yaml:"-" json:"-"`
 }
 
@@ -48,6 +68,21 @@ func (in *HTTPClient) DeepCopyInto(out *HTTPClient) {
 		copy(out.SuccessCodes, in.SuccessCodes)
 	}
 
+	if in.Auth != nil {
+		out.Auth = new(HTTPAuth)
+		*out.Auth = *in.Auth
+	}
+	if in.Retry != nil {
+		out.Retry = new(RetryPolicy)
+		*out.Retry = *in.Retry
+		out.Retry.StatusCodes = slices.Clone(in.Retry.StatusCodes)
+	}
+	if in.Pagination != nil {
+		out.Pagination = new(Pagination)
+		*out.Pagination = *in.Pagination
+	}
+	out.SPIFFE = in.SPIFFE.DeepCopy()
+
 	// Note: The http.Client is not deep copied because it is typically not a value type and its fields are often unexported.
 	// It is assumed that the client will be re-initialized as needed.
 	out.client = in.client
@@ -88,6 +123,34 @@ func (h *HTTPClient) Validate() error {
 	if h.URL == "" {
 		return errors.New("URL is required")
 	}
+	if h.Auth != nil {
+		if err := h.Auth.validate(); err != nil {
+			return err
+		}
+	}
+	if h.Retry != nil {
+		if err := h.Retry.validate(); err != nil {
+			return err
+		}
+	}
+	if h.Pagination != nil {
+		if err := h.Pagination.validate(); err != nil {
+			return err
+		}
+	}
+	if h.ResponseTemplate != "" {
+		if _, err := h.responseTemplate(); err != nil {
+			return fmt.Errorf("invalid responseTemplate: %w", err)
+		}
+	}
+	if h.SPIFFE != nil {
+		if !strings.HasPrefix(h.URL, "https://") {
+			return errors.New("spiffe requires an https URL")
+		}
+		if err := h.SPIFFE.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -101,13 +164,27 @@ func (h *HTTPClient) Meta() map[string]any {
 	if err := json.Unmarshal(jd, &m); err != nil {
 		return nil
 	}
+	// Remove sensitive data
+	delete(m, "auth")
 	return m
 }
 
 // Init initializes the HTTP client
 func (h *HTTPClient) Init(ctx context.Context) error {
 	h.client = &http.Client{}
-	return h.Validate()
+	if err := h.Validate(); err != nil {
+		return err
+	}
+	if h.SPIFFE != nil {
+		tlsConfig, err := h.SPIFFE.ClientTLSConfig()
+		if err != nil {
+			return err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		h.client.Transport = transport
+	}
+	return nil
 }
 
 // Driver returns the driver name
@@ -157,31 +234,47 @@ func (h *HTTPClient) ApplyTemplate(secrets []byte) (string, error) {
 // GetSecret retrieves a secret from the HTTP URL
 func (h *HTTPClient) GetSecret(ctx context.Context, path string) ([]byte, error) {
 	url := path
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, body, err := h.doRequest(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for key, value := range h.Headers {
-		req.Header.Set(key, value)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get secret: %s", resp.Status)
 	}
 
-	resp, err := h.client.Do(req)
-	if err != nil {
-		return nil, err
+	if h.ResponseTemplate != "" {
+		return h.applyResponseTemplate(body)
 	}
-	defer resp.Body.Close()
+	return body, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get secret: %s", resp.Status)
-	}
+// responseTemplate parses ResponseTemplate. Unlike the request Template it is a
+// text/template: secret values must come back unescaped.
+func (h *HTTPClient) responseTemplate() (*texttemplate.Template, error) {
+	return texttemplate.New("responseTemplate").Funcs(texttemplate.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Option("missingkey=error").Parse(h.ResponseTemplate)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// applyResponseTemplate renders ResponseTemplate against a JSON response body
+func (h *HTTPClient) applyResponseTemplate(body []byte) ([]byte, error) {
+	tmpl, err := h.responseTemplate()
 	if err != nil {
 		return nil, err
 	}
-
-	return body, nil
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("responseTemplate requires a JSON response: %w", err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
 }
 
 // WriteSecret writes a secret to the HTTP URL
@@ -200,11 +293,6 @@ func (h *HTTPClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pa
 		return nil, err
 	}
 	method := cmp.Or(h.Method, http.MethodPost)
-	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer([]byte(payload)))
-	if err != nil {
-		l.WithError(err).Error("failed to create request")
-		return nil, err
-	}
 	if h.HeaderSecret != "" {
 		sc, err := kubesecret.GetSecret(ctx, meta.Namespace, h.HeaderSecret)
 		if err != nil {
@@ -215,22 +303,11 @@ func (h *HTTPClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pa
 			h.Headers[key] = string(value)
 		}
 	}
-	for key, value := range h.Headers {
-		req.Header.Set(key, value)
-	}
-	// debug log the whole request
-	httpReqDump, err := httputil.DumpRequestOut(req, true)
-	if err != nil {
-		l.WithError(err).Error("failed to dump request")
-		return nil, err
-	}
-	l.Debugf("request=%s", string(httpReqDump))
 	// send the request
-	resp, err := h.client.Do(req)
+	resp, _, err := h.doRequest(ctx, method, url, []byte(payload))
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if len(h.SuccessCodes) == 0 {
 		h.SuccessCodes = []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent}
@@ -244,21 +321,11 @@ func (h *HTTPClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pa
 // DeleteSecret deletes a secret from the HTTP URL
 func (h *HTTPClient) DeleteSecret(ctx context.Context, path string) error {
 	url := path
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	resp, _, err := h.doRequest(ctx, http.MethodDelete, url, nil)
 	if err != nil {
 		return err
 	}
 
-	for key, value := range h.Headers {
-		req.Header.Set(key, value)
-	}
-
-	resp, err := h.client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		return fmt.Errorf("failed to delete secret: %s", resp.Status)
 	}
@@ -268,37 +335,180 @@ func (h *HTTPClient) DeleteSecret(ctx context.Context, path string) error {
 
 // ListSecrets lists secrets from the HTTP URL
 func (h *HTTPClient) ListSecrets(ctx context.Context, path string) ([]string, error) {
+	if h.Pagination != nil {
+		return h.listPaginated(ctx, path)
+	}
+
 	url := path
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	resp, body, err := h.doRequest(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for key, value := range h.Headers {
-		req.Header.Set(key, value)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list secrets: %s", resp.Status)
 	}
 
-	resp, err := h.client.Do(req)
+	var secrets []string
+	if err := json.Unmarshal(body, &secrets); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// listPaginated follows the configured pagination scheme until the last page
+func (h *HTTPClient) listPaginated(ctx context.Context, listURL string) ([]string, error) {
+	p := h.Pagination
+	base, err := neturl.Parse(listURL)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to list secrets: %s", resp.Status)
+	var names []string
+	next := base
+	for page := 1; ; page++ {
+		if page > p.maxPages() {
+			return nil, fmt.Errorf("failed to list secrets: more than %d pages", p.maxPages())
+		}
+
+		u := *next
+		q := u.Query()
+		if p.PageSize > 0 {
+			q.Set(cmp.Or(p.SizeParam, "per_page"), strconv.Itoa(p.PageSize))
+		}
+		if p.Type == "page" {
+			q.Set(cmp.Or(p.Param, "page"), strconv.Itoa(page))
+		}
+		u.RawQuery = q.Encode()
+
+		resp, body, err := h.doRequest(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to list secrets: %s", resp.Status)
+		}
+
+		var decoded any
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			return nil, err
+		}
+		items, err := p.itemNames(decoded)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, items...)
+
+		switch p.Type {
+		case "page":
+			if len(items) == 0 || (p.PageSize > 0 && len(items) < p.PageSize) {
+				return names, nil
+			}
+		case "cursor":
+			cursor, _ := lookupPath(decoded, p.Next)
+			c, _ := cursor.(string)
+			if c == "" {
+				return names, nil
+			}
+			if strings.HasPrefix(c, "http://") || strings.HasPrefix(c, "https://") {
+				if next, err = neturl.Parse(c); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			n := *base
+			nq := n.Query()
+			nq.Set(cmp.Or(p.Param, "cursor"), c)
+			n.RawQuery = nq.Encode()
+			next = &n
+		case "link":
+			link := nextLink(resp.Header.Get("Link"))
+			if link == "" {
+				return names, nil
+			}
+			ref, err := neturl.Parse(link)
+			if err != nil {
+				return nil, err
+			}
+			next = u.ResolveReference(ref)
+		}
+	}
+}
+
+// doRequest sends a request with the configured headers and auth, retrying per
+// the retry policy. The returned response's body has already been read and closed.
+func (h *HTTPClient) doRequest(ctx context.Context, method, url string, body []byte) (*http.Response, []byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "doRequest",
+		"method": method,
+		"store":  "http",
+	})
+
+	attempts := h.Retry.attempts()
+	for attempt := 1; ; attempt++ {
+		resp, respBody, err := h.send(ctx, method, url, body)
+		retry := err != nil || (h.Retry != nil && h.Retry.retryable(resp.StatusCode))
+		if !retry || attempt >= attempts || ctx.Err() != nil {
+			return resp, respBody, err
+		}
+
+		delay := h.Retry.delay(attempt, resp)
+		if err != nil {
+			l.WithError(err).Debugf("request failed, retrying in %s (attempt %d/%d)", delay, attempt, attempts)
+		} else {
+			l.Debugf("request returned %s, retrying in %s (attempt %d/%d)", resp.Status, delay, attempt, attempts)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(delay):
+		}
 	}
+}
 
-	body, err := io.ReadAll(resp.Body)
+// send performs a single request attempt
+func (h *HTTPClient) send(ctx context.Context, method, url string, body []byte) (*http.Response, []byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "send",
+		"store":  "http",
+	})
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
-		return nil, err
+		l.WithError(err).Error("failed to create request")
+		return nil, nil, err
+	}
+	for key, value := range h.Headers {
+		req.Header.Set(key, value)
+	}
+	// debug log the whole request, before auth credentials are attached
+	if log.IsLevelEnabled(log.DebugLevel) {
+		if httpReqDump, err := httputil.DumpRequestOut(req, true); err == nil {
+			l.Debugf("request=%s", string(httpReqDump))
+		}
+	}
+	if h.Auth != nil {
+		if err := h.Auth.apply(req, body, time.Now()); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	var secrets []string
-	if err := json.Unmarshal(body, &secrets); err != nil {
-		return nil, err
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	return secrets, nil
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
 }
 
 // SetDefaults sets default values for the HTTP client
@@ -1,8 +1,19 @@
 package httpstore
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestApplyTemplate(t *testing.T) {
@@ -68,3 +79,213 @@ func TestApplyTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthApply(t *testing.T) {
+	t.Setenv("HTTPSTORE_TEST_TOKEN", "abc123")
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"password":"secret"}`)
+
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodPut, "https://secrets.internal/v1/app?env=prd", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newRequest()
+	if err := (&HTTPAuth{Type: "bearer", Token: "${HTTPSTORE_TEST_TOKEN}"}).apply(req, body, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer abc123" {
+		t.Errorf("bearer Authorization = %q", got)
+	}
+
+	req = newRequest()
+	if err := (&HTTPAuth{Type: "basic", Username: "sync", Password: "pw"}).apply(req, body, now); err != nil {
+		t.Fatal(err)
+	}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "sync" || pass != "pw" {
+		t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+	}
+
+	req = newRequest()
+	if err := (&HTTPAuth{Type: "hmac", HMACKey: "k", SignatureHeader: "X-Sig"}).apply(req, body, now); err != nil {
+		t.Fatal(err)
+	}
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write([]byte("PUT\n/v1/app?env=prd\n1700000000\n" + hex.EncodeToString(bodyHash[:])))
+	if got, want := req.Header.Get("X-Sig"), hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("hmac signature = %q, want %q", got, want)
+	}
+	if got := req.Header.Get("X-Timestamp"); got != "1700000000" {
+		t.Errorf("hmac timestamp = %q", got)
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		client HTTPClient
+		errMsg string
+	}{
+		{name: "unknown auth", client: HTTPClient{URL: "https://x", Auth: &HTTPAuth{Type: "digest"}}, errMsg: "unknown auth type"},
+		{name: "bearer without token", client: HTTPClient{URL: "https://x", Auth: &HTTPAuth{Type: "bearer"}}, errMsg: "auth.token is required"},
+		{name: "bad backoff", client: HTTPClient{URL: "https://x", Retry: &RetryPolicy{Backoff: "soon"}}, errMsg: "invalid retry.backoff"},
+		{name: "cursor without next", client: HTTPClient{URL: "https://x", Pagination: &Pagination{Type: "cursor"}}, errMsg: "pagination.next is required"},
+		{name: "bad response template", client: HTTPClient{URL: "https://x", ResponseTemplate: "{{.data"}, errMsg: "invalid responseTemplate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.client.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &HTTPClient{URL: srv.URL, Retry: &RetryPolicy{MaxAttempts: 3, Backoff: "1ms"}}
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetSecret(context.Background(), srv.URL)
+	if err != nil || string(got) != "ok" || calls != 3 {
+		t.Errorf("GetSecret() = %q, %v after %d calls", got, err, calls)
+	}
+
+	// Without a retry policy the first failure is returned
+	calls = 0
+	client.Retry = nil
+	if _, err := client.GetSecret(context.Background(), srv.URL); err == nil || calls != 1 {
+		t.Errorf("GetSecret() error = %v after %d calls, want failure after 1", err, calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	r := &RetryPolicy{Backoff: "100ms", MaxBackoff: "1s"}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: time.Second} {
+		if got := r.delay(n, nil); got != want {
+			t.Errorf("delay(%d) = %s, want %s", n, got, want)
+		}
+	}
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"0"}}}
+	if got := r.delay(3, resp); got != 0 {
+		t.Errorf("delay with Retry-After: 0 = %s", got)
+	}
+}
+
+func TestGetSecretResponseTemplate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"value":"p&ss<word>","meta":{"version":3}}}`))
+	}))
+	defer srv.Close()
+
+	client := &HTTPClient{URL: srv.URL, ResponseTemplate: `{{.data.value}}`}
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetSecret(context.Background(), srv.URL)
+	if err != nil || string(got) != "p&ss<word>" {
+		t.Errorf("GetSecret() = %q, %v", got, err)
+	}
+
+	client.ResponseTemplate = `{{json .data.meta}}`
+	got, err = client.GetSecret(context.Background(), srv.URL)
+	if err != nil || string(got) != `{"version":3}` {
+		t.Errorf("GetSecret() = %q, %v", got, err)
+	}
+}
+
+func TestListSecretsPagination(t *testing.T) {
+	all := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		name       string
+		pagination Pagination
+		handler    func(srvURL string) http.HandlerFunc
+	}{
+		{
+			name:       "cursor",
+			pagination: Pagination{Type: "cursor", Items: "data.keys", Next: "meta.next"},
+			handler: func(string) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+					end := min(start+2, len(all))
+					next := ""
+					if end < len(all) {
+						next = strconv.Itoa(end)
+					}
+					json.NewEncoder(w).Encode(map[string]any{
+						"data": map[string]any{"keys": all[start:end]},
+						"meta": map[string]any{"next": next},
+					})
+				}
+			},
+		},
+		{
+			name:       "page",
+			pagination: Pagination{Type: "page", Items: "items", Name: "name", PageSize: 2},
+			handler: func(string) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+					size, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+					start := min((page-1)*size, len(all))
+					var items []map[string]string
+					for _, name := range all[start:min(start+size, len(all))] {
+						items = append(items, map[string]string{"name": name})
+					}
+					json.NewEncoder(w).Encode(map[string]any{"items": items})
+				}
+			},
+		},
+		{
+			name:       "link",
+			pagination: Pagination{Type: "link"},
+			handler: func(srvURL string) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					start, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+					end := min(start+2, len(all))
+					if end < len(all) {
+						w.Header().Set("Link", fmt.Sprintf(`<%s/list?offset=%d>; rel="next"`, srvURL, end))
+					}
+					json.NewEncoder(w).Encode(all[start:end])
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handler http.HandlerFunc
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handler(w, r) }))
+			defer srv.Close()
+			handler = tt.handler(srv.URL)
+
+			pagination := tt.pagination
+			client := &HTTPClient{URL: srv.URL, Pagination: &pagination}
+			if err := client.Init(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := client.ListSecrets(context.Background(), srv.URL+"/list")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != strings.Join(all, ",") {
+				t.Errorf("ListSecrets() = %v, want %v", got, all)
+			}
+		})
+	}
+}
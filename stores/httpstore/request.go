@@ -0,0 +1,283 @@
+package httpstore
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPAuth configures how requests are authenticated. Credential fields accept
+// ${VAR} references that are expanded from the environment when a request is sent.
+type HTTPAuth struct {
+	// Type is bearer, basic or hmac
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// Token is sent as "Authorization: Bearer <token>"
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// HMACKey signs "METHOD\nREQUEST_URI\nTIMESTAMP\nhex(sha256(body))"; the hex
+	// signature and unix timestamp are sent in SignatureHeader and TimestampHeader
+	HMACKey         string `yaml:"hmacKey,omitempty" json:"hmacKey,omitempty"`
+	Algorithm       string `yaml:"algorithm,omitempty" json:"algorithm,omitempty"` // sha256 (default) or sha512
+	SignatureHeader string `yaml:"signatureHeader,omitempty" json:"signatureHeader,omitempty"`
+	TimestampHeader string `yaml:"timestampHeader,omitempty" json:"timestampHeader,omitempty"`
+}
+
+func (a *HTTPAuth) validate() error {
+	switch strings.ToLower(a.Type) {
+	case "bearer":
+		if a.Token == "" {
+			return errors.New("auth.token is required for bearer auth")
+		}
+	case "basic":
+		if a.Username == "" {
+			return errors.New("auth.username is required for basic auth")
+		}
+	case "hmac":
+		if a.HMACKey == "" {
+			return errors.New("auth.hmacKey is required for hmac auth")
+		}
+		if _, err := a.hashFunc(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown auth type %q (must be bearer, basic or hmac)", a.Type)
+	}
+	return nil
+}
+
+func (a *HTTPAuth) hashFunc() (func() hash.Hash, error) {
+	switch strings.ToLower(a.Algorithm) {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unknown auth algorithm %q (must be sha256 or sha512)", a.Algorithm)
+}
+
+// apply authenticates req, whose body is body
+func (a *HTTPAuth) apply(req *http.Request, body []byte, now time.Time) error {
+	switch strings.ToLower(a.Type) {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+os.ExpandEnv(a.Token))
+	case "basic":
+		req.SetBasicAuth(os.ExpandEnv(a.Username), os.ExpandEnv(a.Password))
+	case "hmac":
+		newHash, err := a.hashFunc()
+		if err != nil {
+			return err
+		}
+		ts := strconv.FormatInt(now.Unix(), 10)
+		bodyHash := sha256.Sum256(body)
+		mac := hmac.New(newHash, []byte(os.ExpandEnv(a.HMACKey)))
+		fmt.Fprintf(mac, "%s\n%s\n%s\n%s", req.Method, req.URL.RequestURI(), ts, hex.EncodeToString(bodyHash[:]))
+
+		req.Header.Set(cmp.Or(a.TimestampHeader, "X-Timestamp"), ts)
+		req.Header.Set(cmp.Or(a.SignatureHeader, "X-Signature"), hex.EncodeToString(mac.Sum(nil)))
+	}
+	return nil
+}
+
+// RetryPolicy retries requests that fail with a network error or a retryable
+// status code, backing off exponentially and honoring Retry-After
+type RetryPolicy struct {
+	// MaxAttempts includes the first request (default 3)
+	MaxAttempts int `yaml:"maxAttempts,omitempty" json:"maxAttempts,omitempty"`
+	// Backoff is the delay before the first retry, doubled on each retry (default 500ms)
+	Backoff string `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+	// MaxBackoff caps the delay between retries (default 30s)
+	MaxBackoff string `yaml:"maxBackoff,omitempty" json:"maxBackoff,omitempty"`
+	// StatusCodes are retried (default 429, 500, 502, 503, 504)
+	StatusCodes []int `yaml:"statusCodes,omitempty" json:"statusCodes,omitempty"`
+}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func (r *RetryPolicy) validate() error {
+	if r.MaxAttempts < 0 {
+		return errors.New("retry.maxAttempts must not be negative")
+	}
+	for name, d := range map[string]string{"backoff": r.Backoff, "maxBackoff": r.MaxBackoff} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("invalid retry.%s %q: %w", name, d, err)
+		}
+	}
+	return nil
+}
+
+// attempts returns the total number of tries; a nil policy tries once
+func (r *RetryPolicy) attempts() int {
+	if r == nil {
+		return 1
+	}
+	if r.MaxAttempts == 0 {
+		return 3
+	}
+	return r.MaxAttempts
+}
+
+func (r *RetryPolicy) retryable(status int) bool {
+	codes := r.StatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryStatusCodes
+	}
+	for _, c := range codes {
+		if c == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before retry number n (1-based)
+func (r *RetryPolicy) delay(n int, resp *http.Response) time.Duration {
+	backoff := parseDurationOr(r.Backoff, 500*time.Millisecond)
+	maxBackoff := parseDurationOr(r.MaxBackoff, 30*time.Second)
+
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, maxBackoff)
+		}
+	}
+	d := backoff << (n - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+// Pagination describes how ListSecrets pages through a list endpoint
+type Pagination struct {
+	// Type is cursor (a token from the response body is sent back as a query
+	// parameter), page (page numbers) or link (RFC 8288 Link: <...>; rel="next")
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	// Items is the dotted path to the array of secrets in the response body,
+	// e.g. data.keys; empty means the body itself is the array
+	Items string `yaml:"items,omitempty" json:"items,omitempty"`
+	// Name is the dotted path to the name within each item when items are objects
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Next is the dotted path to the next cursor (cursor pagination). A cursor
+	// that is a full URL is requested as is.
+	Next string `yaml:"next,omitempty" json:"next,omitempty"`
+	// Param is the query parameter carrying the cursor or page number
+	// (default cursor or page)
+	Param string `yaml:"param,omitempty" json:"param,omitempty"`
+
+	PageSize  int    `yaml:"pageSize,omitempty" json:"pageSize,omitempty"`
+	SizeParam string `yaml:"sizeParam,omitempty" json:"sizeParam,omitempty"` // default per_page
+	// MaxPages guards against endpoints that never stop paging (default 1000)
+	MaxPages int `yaml:"maxPages,omitempty" json:"maxPages,omitempty"`
+}
+
+func (p *Pagination) validate() error {
+	switch p.Type {
+	case "cursor":
+		if p.Next == "" {
+			return errors.New("pagination.next is required for cursor pagination")
+		}
+	case "page", "link":
+	default:
+		return fmt.Errorf("unknown pagination type %q (must be cursor, page or link)", p.Type)
+	}
+	return nil
+}
+
+func (p *Pagination) maxPages() int {
+	if p.MaxPages <= 0 {
+		return 1000
+	}
+	return p.MaxPages
+}
+
+// lookupPath walks a dotted path through decoded JSON objects
+func lookupPath(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// itemNames extracts secret names from a decoded list response
+func (p *Pagination) itemNames(body any) ([]string, error) {
+	v, ok := lookupPath(body, p.Items)
+	if !ok || v == nil {
+		return nil, nil
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("pagination.items %q is not an array", p.Items)
+	}
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		name, ok := lookupPath(item, p.Name)
+		if !ok {
+			return nil, fmt.Errorf("list item has no %q field", p.Name)
+		}
+		switch n := name.(type) {
+		case string:
+			names = append(names, n)
+		case float64:
+			names = append(names, strconv.FormatFloat(n, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("list item name has unsupported type %T", name)
+		}
+	}
+	return names, nil
+}
+
+// nextLink returns the rel="next" target of a Link header
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.ReplaceAll(strings.TrimSpace(param), " ", "") == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,305 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// emptyPayloadHash is the SHA-256 of an empty request body, used when signing GETs
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	gkeScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// ClusterInfo describes a cluster returned by the EKS or GKE API
+type ClusterInfo struct {
+	Name     string
+	Location string // AWS region or GCP location
+	Endpoint string
+	CAData   []byte
+	Status   string
+	Labels   map[string]string // EKS tags or GKE resource labels
+}
+
+// Active reports whether the cluster is ready to accept writes
+func (ci ClusterInfo) Active() bool {
+	return ci.Status == "ACTIVE" || ci.Status == "RUNNING"
+}
+
+// restConfig resolves the cluster endpoint and credentials for the configured provider
+func (c *KubernetesClient) restConfig(ctx context.Context) (*rest.Config, error) {
+	switch c.Provider {
+	case ProviderEKS:
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(c.Region))
+		if err != nil {
+			return nil, err
+		}
+		if c.RoleArn != "" {
+			provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), c.RoleArn, func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = "vault-secret-sync"
+			})
+			awsCfg.Credentials = aws.NewCredentialsCache(provider)
+		}
+		cluster, err := DescribeEKSCluster(ctx, awsCfg, c.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		token, err := eksToken(ctx, awsCfg, c.Cluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate EKS token: %w", err)
+		}
+		return clusterRestConfig(cluster, token), nil
+	case ProviderGKE:
+		ts, err := google.DefaultTokenSource(ctx, gkeScope)
+		if err != nil {
+			return nil, err
+		}
+		cluster, err := getGKECluster(ctx, ts, c.Project, c.Location, c.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		token, err := ts.Token()
+		if err != nil {
+			return nil, err
+		}
+		return clusterRestConfig(cluster, token.AccessToken), nil
+	}
+	return nil, fmt.Errorf("unsupported provider %q", c.Provider)
+}
+
+func clusterRestConfig(cluster ClusterInfo, token string) *rest.Config {
+	host := cluster.Endpoint
+	if u, err := url.Parse(host); err != nil || u.Scheme == "" {
+		host = "https://" + host
+	}
+	return &rest.Config{
+		Host:        host,
+		BearerToken: token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: cluster.CAData,
+		},
+	}
+}
+
+// eksToken generates a bearer token the EKS authenticator accepts: a presigned
+// STS GetCallerIdentity URL bound to the cluster name
+func eksToken(ctx context.Context, cfg aws.Config, cluster string) (string, error) {
+	presigner := sts.NewPresignClient(sts.NewFromConfig(cfg))
+	req, err := presigner.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(o *sts.PresignOptions) {
+		o.ClientOptions = append(o.ClientOptions, func(so *sts.Options) {
+			so.APIOptions = append(so.APIOptions,
+				smithyhttp.AddHeaderValue("x-k8s-aws-id", cluster),
+				smithyhttp.AddHeaderValue("X-Amz-Expires", "60"),
+			)
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return "k8s-aws-v1." + base64.RawURLEncoding.EncodeToString([]byte(req.URL)), nil
+}
+
+// eksRequest performs a SigV4-signed GET against the EKS control plane API
+func eksRequest(ctx context.Context, cfg aws.Config, path string, query url.Values, out interface{}) error {
+	u := url.URL{
+		Scheme:   "https",
+		Host:     fmt.Sprintf("eks.%s.amazonaws.com", cfg.Region),
+		Path:     path,
+		RawQuery: query.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, emptyPayloadHash, "eks", cfg.Region, time.Now()); err != nil {
+		return err
+	}
+	return doJSON(req, http.DefaultClient, out)
+}
+
+type eksCluster struct {
+	Name                 string `json:"name"`
+	Endpoint             string `json:"endpoint"`
+	Status               string `json:"status"`
+	CertificateAuthority struct {
+		Data string `json:"data"`
+	} `json:"certificateAuthority"`
+	Tags map[string]string `json:"tags"`
+}
+
+// ListEKSClusters lists the EKS clusters in cfg's account and region with their endpoints and tags
+func ListEKSClusters(ctx context.Context, cfg aws.Config) ([]ClusterInfo, error) {
+	l := log.WithFields(log.Fields{
+		"action": "ListEKSClusters",
+		"region": cfg.Region,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	var names []string
+	query := url.Values{"maxResults": []string{"100"}}
+	for {
+		var page struct {
+			Clusters  []string `json:"clusters"`
+			NextToken *string  `json:"nextToken"`
+		}
+		if err := eksRequest(ctx, cfg, "/clusters", query, &page); err != nil {
+			return nil, err
+		}
+		names = append(names, page.Clusters...)
+		if page.NextToken == nil || *page.NextToken == "" {
+			break
+		}
+		query.Set("nextToken", *page.NextToken)
+	}
+
+	clusters := make([]ClusterInfo, 0, len(names))
+	for _, name := range names {
+		ci, err := DescribeEKSCluster(ctx, cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, ci)
+	}
+	return clusters, nil
+}
+
+// DescribeEKSCluster returns the endpoint, CA and tags for an EKS cluster
+func DescribeEKSCluster(ctx context.Context, cfg aws.Config, name string) (ClusterInfo, error) {
+	var resp struct {
+		Cluster eksCluster `json:"cluster"`
+	}
+	if err := eksRequest(ctx, cfg, "/clusters/"+url.PathEscape(name), nil, &resp); err != nil {
+		return ClusterInfo{}, fmt.Errorf("failed to describe EKS cluster %s: %w", name, err)
+	}
+	ca, err := base64.StdEncoding.DecodeString(resp.Cluster.CertificateAuthority.Data)
+	if err != nil {
+		return ClusterInfo{}, fmt.Errorf("invalid certificate authority for EKS cluster %s: %w", name, err)
+	}
+	return ClusterInfo{
+		Name:     resp.Cluster.Name,
+		Location: cfg.Region,
+		Endpoint: resp.Cluster.Endpoint,
+		CAData:   ca,
+		Status:   resp.Cluster.Status,
+		Labels:   resp.Cluster.Tags,
+	}, nil
+}
+
+type gkeCluster struct {
+	Name           string            `json:"name"`
+	Location       string            `json:"location"`
+	Endpoint       string            `json:"endpoint"`
+	Status         string            `json:"status"`
+	ResourceLabels map[string]string `json:"resourceLabels"`
+	MasterAuth     struct {
+		ClusterCACertificate string `json:"clusterCaCertificate"`
+	} `json:"masterAuth"`
+}
+
+func (g gkeCluster) info() (ClusterInfo, error) {
+	ca, err := base64.StdEncoding.DecodeString(g.MasterAuth.ClusterCACertificate)
+	if err != nil {
+		return ClusterInfo{}, fmt.Errorf("invalid certificate authority for GKE cluster %s: %w", g.Name, err)
+	}
+	return ClusterInfo{
+		Name:     g.Name,
+		Location: g.Location,
+		Endpoint: g.Endpoint,
+		CAData:   ca,
+		Status:   g.Status,
+		Labels:   g.ResourceLabels,
+	}, nil
+}
+
+// ListGKEClusters lists the GKE clusters in a project. Location "-" (or empty) lists all locations.
+func ListGKEClusters(ctx context.Context, project, location string) ([]ClusterInfo, error) {
+	l := log.WithFields(log.Fields{
+		"action":  "ListGKEClusters",
+		"project": project,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	if location == "" {
+		location = "-"
+	}
+	ts, err := google.DefaultTokenSource(ctx, gkeScope)
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("https://container.googleapis.com/v1/projects/%s/locations/%s/clusters",
+		url.PathEscape(project), url.PathEscape(location))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Clusters []gkeCluster `json:"clusters"`
+	}
+	if err := doJSON(req, oauth2.NewClient(ctx, ts), &resp); err != nil {
+		return nil, fmt.Errorf("failed to list GKE clusters: %w", err)
+	}
+	clusters := make([]ClusterInfo, 0, len(resp.Clusters))
+	for _, gc := range resp.Clusters {
+		ci, err := gc.info()
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, ci)
+	}
+	return clusters, nil
+}
+
+func getGKECluster(ctx context.Context, ts oauth2.TokenSource, project, location, name string) (ClusterInfo, error) {
+	u := fmt.Sprintf("https://container.googleapis.com/v1/projects/%s/locations/%s/clusters/%s",
+		url.PathEscape(project), url.PathEscape(location), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return ClusterInfo{}, err
+	}
+	var gc gkeCluster
+	if err := doJSON(req, oauth2.NewClient(ctx, ts), &gc); err != nil {
+		return ClusterInfo{}, fmt.Errorf("failed to get GKE cluster %s: %w", name, err)
+	}
+	return gc.info()
+}
+
+func doJSON(req *http.Request, client *http.Client, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,382 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jbcom/secretsync/internal/kube"
+	"github.com/jbcom/secretsync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ProviderEKS connects to an EKS cluster using IAM credentials
+	ProviderEKS = "eks"
+	// ProviderGKE connects to a GKE cluster using Google application default credentials
+	ProviderGKE = "gke"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "vault-secret-sync"
+)
+
+// KubernetesClient writes secrets to Kubernetes Secrets in a single namespace.
+// With no provider it uses the local kubeconfig (or in-cluster config); with the
+// eks or gke provider it looks up the cluster endpoint and authenticates with
+// cloud credentials, so no kubeconfig needs to exist for fleet clusters.
+type KubernetesClient struct {
+	Provider  string            `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Cluster   string            `yaml:"cluster,omitempty" json:"cluster,omitempty"`
+	Region    string            `yaml:"region,omitempty" json:"region,omitempty"`
+	RoleArn   string            `yaml:"roleArn,omitempty" json:"roleArn,omitempty"`
+	Project   string            `yaml:"project,omitempty" json:"project,omitempty"`
+	Location  string            `yaml:"location,omitempty" json:"location,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Name      string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Merge     *bool             `yaml:"merge,omitempty" json:"merge,omitempty"`
+
+	client clientset.Interface `yaml:"-" json:"-"`
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesClient) DeepCopyInto(out *KubernetesClient) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Merge != nil {
+		in, out := &in.Merge, &out.Merge
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesClient.
+func (in *KubernetesClient) DeepCopy() *KubernetesClient {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesClient)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (c *KubernetesClient) Validate() error {
+	l := log.WithFields(log.Fields{
+		"action": "Validate",
+	})
+	l.Trace("start")
+	if c.Name == "" {
+		return driver.ErrPathRequired
+	}
+	switch c.Provider {
+	case "":
+	case ProviderEKS:
+		if c.Cluster == "" || c.Region == "" {
+			return errors.New("eks provider requires cluster and region")
+		}
+	case ProviderGKE:
+		if c.Cluster == "" || c.Project == "" || c.Location == "" {
+			return errors.New("gke provider requires cluster, project and location")
+		}
+	default:
+		return fmt.Errorf("unsupported provider %q (must be eks or gke)", c.Provider)
+	}
+	return nil
+}
+
+func NewClient(cfg *KubernetesClient) (*KubernetesClient, error) {
+	l := log.WithFields(log.Fields{
+		"action": "NewClient",
+	})
+	l.Trace("start")
+	if cfg == nil {
+		return nil, errors.New("config is nil")
+	}
+	vc := cfg.DeepCopy()
+	if vc.Namespace == "" {
+		vc.Namespace = "default"
+	}
+	l.Debugf("client created for provider=%s cluster=%s namespace=%s", vc.Provider, vc.Cluster, vc.Namespace)
+	l.Trace("end")
+	return vc, nil
+}
+
+func (c *KubernetesClient) CreateClient(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action":   "CreateClient",
+		"provider": c.Provider,
+		"cluster":  c.Cluster,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	if c.client != nil {
+		return nil
+	}
+	if c.Provider == "" {
+		client, err := kube.CreateKubeClient()
+		if err != nil {
+			return err
+		}
+		c.client = client
+		return nil
+	}
+	restCfg, err := c.restConfig(ctx)
+	if err != nil {
+		l.Debugf("error: %v", err)
+		return err
+	}
+	client, err := clientset.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+	c.client = client
+	return nil
+}
+
+func (c *KubernetesClient) Meta() map[string]any {
+	md := make(map[string]any)
+	jd, err := json.Marshal(c)
+	if err != nil {
+		return md
+	}
+	err = json.Unmarshal(jd, &md)
+	if err != nil {
+		return md
+	}
+	return md
+}
+
+func (c *KubernetesClient) Init(ctx context.Context) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if err := c.CreateClient(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *KubernetesClient) Driver() driver.DriverName {
+	return driver.DriverNameKubernetes
+}
+
+func (c *KubernetesClient) GetPath() string {
+	return c.Name
+}
+
+var invalidSecretNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// SecretName converts a secret path into a valid Kubernetes Secret name
+func SecretName(path string) string {
+	name := invalidSecretNameChars.ReplaceAllString(strings.ToLower(path), "-")
+	name = strings.Trim(name, "-.")
+	if len(name) > 253 {
+		name = strings.TrimRight(name[:253], "-.")
+	}
+	return name
+}
+
+func (c *KubernetesClient) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action": "GetSecret",
+		"name":   name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	s, err := c.client.CoreV1().Secrets(c.Namespace).Get(ctx, SecretName(name), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		data[k] = string(v)
+	}
+	return json.Marshal(data)
+}
+
+// secretData converts a JSON object into Secret data; non-string values are stored as JSON
+func secretData(b []byte) (map[string][]byte, error) {
+	secrets := make(map[string]interface{})
+	if err := json.Unmarshal(b, &secrets); err != nil {
+		return nil, err
+	}
+	data := make(map[string][]byte, len(secrets))
+	for k, v := range secrets {
+		switch val := v.(type) {
+		case string:
+			data[k] = []byte(val)
+		default:
+			jv, err := json.Marshal(val)
+			if err != nil {
+				return nil, err
+			}
+			data[k] = jv
+		}
+	}
+	return data, nil
+}
+
+func (c *KubernetesClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secrets []byte) ([]byte, error) {
+	l := log.WithFields(log.Fields{
+		"action":    "WriteSecret",
+		"driver":    c.Driver(),
+		"path":      path,
+		"namespace": c.Namespace,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	data, err := secretData(secrets)
+	if err != nil {
+		return nil, err
+	}
+	name := SecretName(path)
+	if name == "" {
+		return nil, driver.ErrPathRequired
+	}
+
+	secretsClient := c.client.CoreV1().Secrets(c.Namespace)
+	existing, err := secretsClient.Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: c.Namespace,
+				Labels:    c.labels(),
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}
+		if _, err := secretsClient.Create(ctx, s, metav1.CreateOptions{}); err != nil {
+			l.Errorf("error: %v", err)
+			return nil, err
+		}
+		return nil, nil
+	} else if err != nil {
+		l.Errorf("error: %v", err)
+		return nil, err
+	}
+
+	if c.Merge != nil && *c.Merge && existing.Data != nil {
+		for k, v := range data {
+			existing.Data[k] = v
+		}
+	} else {
+		existing.Data = data
+	}
+	if existing.Labels == nil {
+		existing.Labels = make(map[string]string)
+	}
+	for k, v := range c.labels() {
+		existing.Labels[k] = v
+	}
+	if _, err := secretsClient.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		l.Errorf("error: %v", err)
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (c *KubernetesClient) labels() map[string]string {
+	labels := map[string]string{
+		managedByLabel: managedByValue,
+	}
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+func (c *KubernetesClient) DeleteSecret(ctx context.Context, name string) error {
+	l := log.WithFields(log.Fields{
+		"action": "DeleteSecret",
+		"name":   name,
+		"driver": c.Driver(),
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	err := c.client.CoreV1().Secrets(c.Namespace).Delete(ctx, SecretName(name), metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		l.Errorf("error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ListSecrets lists the Secrets in the namespace managed by vault-secret-sync
+func (c *KubernetesClient) ListSecrets(ctx context.Context, p string) ([]string, error) {
+	l := log.WithFields(log.Fields{
+		"action": "ListSecrets",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	list, err := c.client.CoreV1().Secrets(c.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", managedByLabel, managedByValue),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	prefix := SecretName(p)
+	for _, s := range list.Items {
+		if prefix == "" || strings.HasPrefix(s.Name, prefix) {
+			names = append(names, s.Name)
+		}
+	}
+	return names, nil
+}
+
+func (c *KubernetesClient) Close() error {
+	return nil
+}
+
+func (c *KubernetesClient) SetDefaults(defaults any) error {
+	l := log.WithFields(log.Fields{
+		"action": "SetDefaults",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	jd, err := json.Marshal(defaults)
+	if err != nil {
+		return err
+	}
+	nc := &KubernetesClient{}
+	err = json.Unmarshal(jd, nc)
+	if err != nil {
+		return err
+	}
+	if c.Provider == "" && nc.Provider != "" {
+		c.Provider = nc.Provider
+	}
+	if c.Region == "" && nc.Region != "" {
+		c.Region = nc.Region
+	}
+	if c.RoleArn == "" && nc.RoleArn != "" {
+		c.RoleArn = nc.RoleArn
+	}
+	if c.Project == "" && nc.Project != "" {
+		c.Project = nc.Project
+	}
+	if c.Location == "" && nc.Location != "" {
+		c.Location = nc.Location
+	}
+	if c.Namespace == "" && nc.Namespace != "" {
+		c.Namespace = nc.Namespace
+	}
+	if c.Labels == nil && nc.Labels != nil {
+		c.Labels = nc.Labels
+	}
+	return nil
+}
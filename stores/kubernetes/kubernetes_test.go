@@ -0,0 +1,64 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "api-keys", want: "api-keys"},
+		{path: "Team/API_Keys", want: "team-api-keys"},
+		{path: "/leading/slash/", want: "leading-slash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, SecretName(tt.path))
+		})
+	}
+}
+
+func TestWriteSecret(t *testing.T) {
+	ctx := context.Background()
+	c := &KubernetesClient{
+		Namespace: "apps",
+		Merge:     boolPtr(true),
+		client:    fake.NewSimpleClientset(),
+	}
+
+	_, err := c.WriteSecret(ctx, metav1.ObjectMeta{}, "db/creds", []byte(`{"user":"app","port":5432}`))
+	require.NoError(t, err)
+
+	s, err := c.client.CoreV1().Secrets("apps").Get(ctx, "db-creds", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "app", string(s.Data["user"]))
+	assert.Equal(t, "5432", string(s.Data["port"]))
+	assert.Equal(t, managedByValue, s.Labels[managedByLabel])
+
+	// Merge keeps existing keys and overwrites changed ones
+	_, err = c.WriteSecret(ctx, metav1.ObjectMeta{}, "db/creds", []byte(`{"user":"admin"}`))
+	require.NoError(t, err)
+	s, err = c.client.CoreV1().Secrets("apps").Get(ctx, "db-creds", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", string(s.Data["user"]))
+	assert.Equal(t, "5432", string(s.Data["port"]))
+
+	names, err := c.ListSecrets(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db-creds"}, names)
+
+	require.NoError(t, c.DeleteSecret(ctx, "db/creds"))
+	require.NoError(t, c.DeleteSecret(ctx, "db/creds"), "deleting a missing secret is not an error")
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/jbcom/secretsync/pkg/driver"
 	"github.com/jbcom/secretsync/pkg/utils"
@@ -25,12 +27,43 @@ type VaultClient struct {
 	Namespace  string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
 	TTL        string `yaml:"ttl,omitempty" json:"ttl,omitempty"`
 	Merge      bool   `yaml:"merge,omitempty" json:"merge,omitempty"`
+	// Version pins reads to a specific KV v2 version; zero reads the latest
+	Version int `yaml:"version,omitempty" json:"version,omitempty"`
+	// ListPageSize lists this many keys per request; zero lists all keys at once
+	ListPageSize int `yaml:"listPageSize,omitempty" json:"listPageSize,omitempty"`
 
 	Role string `yaml:"role,omitempty" json:"role,omitempty"`
 
 	Client *api.Client `yaml:"-" json:"-"`
 }
 
+// TokenSource returns a valid token, logging in or renewing it as needed
+type TokenSource func(ctx context.Context) (string, error)
+
+var (
+	tokenSourcesMu sync.RWMutex
+	tokenSources   = make(map[string]TokenSource)
+)
+
+// SetTokenSource makes every client of the Vault at address take its token
+// from src instead of VAULT_TOKEN or Kubernetes login. A nil src removes it.
+func SetTokenSource(address string, src TokenSource) {
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+	address = strings.TrimRight(address, "/")
+	if src == nil {
+		delete(tokenSources, address)
+		return
+	}
+	tokenSources[address] = src
+}
+
+func tokenSourceFor(address string) TokenSource {
+	tokenSourcesMu.RLock()
+	defer tokenSourcesMu.RUnlock()
+	return tokenSources[strings.TrimRight(address, "/")]
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VaultClient) DeepCopyInto(out *VaultClient) {
 	*out = *in
@@ -191,6 +224,19 @@ func (vc *VaultClient) NewToken(ctx context.Context) error {
 		"path":    vc.Path,
 		"method":  vc.AuthMethod,
 	})
+	if src := tokenSourceFor(vc.Address); src != nil {
+		l.Trace("using token source")
+		if vc.Client == nil {
+			_, err := vc.NewClient(ctx)
+			return err
+		}
+		token, err := src(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get Vault token: %w", err)
+		}
+		vc.Client.SetToken(token)
+		return nil
+	}
 	l.Trace("vault.NewToken calling Login")
 	if os.Getenv("VAULT_TOKEN") != "" {
 		l.Trace("using VAULT_TOKEN")
@@ -240,7 +286,15 @@ func (vc *VaultClient) GetKVSecretOnce(ctx context.Context, s string) (map[strin
 	if c == nil {
 		return secrets, errors.New("vault client not initialized")
 	}
-	secret, err := c.ReadWithContext(ctx, s)
+	var secret *api.Secret
+	var err error
+	if vc.Version > 0 {
+		secret, err = c.ReadWithDataWithContext(ctx, s, map[string][]string{
+			"version": {strconv.Itoa(vc.Version)},
+		})
+	} else {
+		secret, err = c.ReadWithContext(ctx, s)
+	}
 	if err != nil {
 		return secrets, err
 	}
@@ -483,6 +537,9 @@ func (vc *VaultClient) ListSecretsOnce(ctx context.Context, p string) ([]string,
 	if !strings.HasSuffix(p, "/") {
 		p = p + "/"
 	}
+	if vc.ListPageSize > 0 {
+		return vc.listSecretsPaged(ctx, p)
+	}
 	secret, err := vc.Client.Logical().ListWithContext(ctx, p)
 	if err != nil {
 		return nil, err
@@ -498,6 +555,46 @@ func (vc *VaultClient) ListSecretsOnce(ctx context.Context, p string) ([]string,
 	return keys, nil
 }
 
+// listSecretsPaged lists a metadata path ListPageSize keys at a time with the
+// after and limit list parameters. Servers that ignore them return every key
+// in the first page, which ends the listing.
+func (vc *VaultClient) listSecretsPaged(ctx context.Context, p string) ([]string, error) {
+	var keys []string
+	after := ""
+	for {
+		params := map[string][]string{
+			"list":  {"true"},
+			"limit": {strconv.Itoa(vc.ListPageSize)},
+		}
+		if after != "" {
+			params["after"] = []string{after}
+		}
+		secret, err := vc.Client.Logical().ReadWithDataWithContext(ctx, p, params)
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil {
+			return keys, nil
+		}
+		raw, _ := secret.Data["keys"].([]interface{})
+		page := make([]string, 0, len(raw))
+		for _, v := range raw {
+			if k, ok := v.(string); ok {
+				page = append(page, k)
+			}
+		}
+		if after != "" && len(page) > 0 && page[0] <= after {
+			// after was ignored and the first page came back again
+			return keys, nil
+		}
+		keys = append(keys, page...)
+		if len(page) != vc.ListPageSize {
+			return keys, nil
+		}
+		after = page[len(page)-1]
+	}
+}
+
 func (vc *VaultClient) ListSecrets(ctx context.Context, p string) ([]string, error) {
 	var keys []string
 	var err error